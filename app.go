@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -26,18 +27,20 @@ func main() {
 	}
 	dataDir := filepath.Join(homeDir, ".crosswire")
 
-	// 初始化数据库
-	db, err := storage.NewDatabase(&storage.Config{
+	// 初始化数据库（已启用静态加密时等待前端输入口令解锁，数据库在 Shutdown 中关闭）
+	storageConfig := storage.Config{
 		DataDir:   dataDir,
 		DebugMode: false,
-	})
-	if err != nil {
+	}
+	db, err := storage.NewDatabase(&storageConfig)
+	if errors.Is(err, storage.ErrLocked) {
+		db = nil
+	} else if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
 
 	// 创建应用实例
-	application := app.NewApp(db)
+	application := app.NewApp(db, storageConfig)
 
 	// 创建 Wails 应用
 	err = wails.Run(&options.App{
@@ -0,0 +1,80 @@
+// arpdump 解析 ARP 模式抓包文件，打印 CrossWire 帧日志
+//
+// 用法:
+//
+//	arpdump -r capture.pcapng [-channel ID -password PASS] [-pubkey BASE64] [-ethertype 0x88b5 -encap snap]
+//
+// 指定频道ID与密码时派生频道密钥，尝试解密收齐的消息；指定服务器公钥时校验广播签名。
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"crosswire/internal/crypto"
+	"crosswire/internal/transport"
+)
+
+func main() {
+	file := flag.String("r", "", "抓包文件（pcap/pcapng）")
+	channelID := flag.String("channel", "", "频道ID（派生频道密钥的盐）")
+	password := flag.String("password", "", "频道密码")
+	pubKey := flag.String("pubkey", "", "服务器Ed25519公钥（base64）")
+	etherType := flag.Uint("ethertype", transport.EtherTypeCustom, "自定义EtherType")
+	encap := flag.String("encap", transport.EncapEtherType, "封装方式（ethertype/snap/arp）")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *etherType > 0xffff {
+		fmt.Fprintln(os.Stderr, "-ethertype must fit in 16 bits")
+		os.Exit(2)
+	}
+	opts := transport.DissectOptions{EtherType: uint16(*etherType), Encapsulation: *encap}
+	if *password != "" {
+		if *channelID == "" {
+			fmt.Fprintln(os.Stderr, "-channel is required with -password")
+			os.Exit(2)
+		}
+		mgr, err := crypto.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "crypto init failed: %v\n", err)
+			os.Exit(1)
+		}
+		key, err := mgr.DeriveKey(*password, []byte(*channelID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "derive key failed: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Decrypt = func(ciphertext []byte) ([]byte, error) {
+			return mgr.AESDecrypt(ciphertext, key)
+		}
+	}
+	if *pubKey != "" {
+		decoded, err := base64.StdEncoding.DecodeString(*pubKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -pubkey: %v\n", err)
+			os.Exit(2)
+		}
+		opts.ServerPublicKey = decoded
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	n, err := transport.Dissect(f, os.Stdout, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dissect failed after %d frames: %v\n", n, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d frames\n", n)
+}
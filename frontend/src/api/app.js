@@ -21,6 +21,27 @@ export async function getNetworkInterfaces() {
   return unwrap(res)
 }
 
+// 本地数据加密
+export async function getStorageStatus() {
+  const res = await App.GetStorageStatus()
+  return unwrap(res)
+}
+
+export async function unlockStorage(passphrase) {
+  const res = await App.UnlockStorage(passphrase)
+  return unwrap(res)
+}
+
+export async function enableStorageEncryption(passphrase) {
+  const res = await App.EnableStorageEncryption(passphrase)
+  return unwrap(res)
+}
+
+export async function changeStoragePassphrase(oldPassphrase, newPassphrase) {
+  const res = await App.ChangeStoragePassphrase(oldPassphrase, newPassphrase)
+  return unwrap(res)
+}
+
 // 服务端
 export async function startServer(config) {
   const res = await App.StartServerMode(config)
@@ -268,6 +289,17 @@ export async function downloadFile(fileId, savePath = null, defaultFilename = nu
   return { ...data, savePath }
 }
 
+// 文件包（目录上传）：浏览条目，解出全部或部分条目到目录
+export async function getBundleEntries(fileId) {
+  const res = await App.GetBundleEntries(fileId)
+  return unwrap(res)
+}
+
+export async function downloadBundleEntries(fileId, saveDir, entries = []) {
+  const res = await App.DownloadBundleEntries(fileId, saveDir, entries)
+  return unwrap(res)
+}
+
 export async function deleteFile(fileId) {
   const res = await App.DeleteFile(fileId)
   return unwrap(res)
@@ -351,6 +383,16 @@ export async function getNetworkStats() {
   return unwrap(res)
 }
 
+export async function getPeerNetworkStats() {
+  const res = await App.GetPeerNetworkStats()
+  return unwrap(res)
+}
+
+export async function getPeerNetworkHistory(peer, since = 0) {
+  const res = await App.GetPeerNetworkHistory(peer, since)
+  return unwrap(res)
+}
+
 export async function testConnection(serverAddress, mode, timeout = 5) {
   const res = await App.TestConnection(serverAddress, mode, timeout)
   return unwrap(res)
@@ -409,6 +451,11 @@ export async function getClientStatus() {
   return unwrap(res)
 }
 
+export async function reconnectClient() {
+  const res = await App.ReconnectClient()
+  return unwrap(res)
+}
+
 export async function stopClient() {
   const res = await App.StopClientMode()
   return unwrap(res)
@@ -11,6 +11,8 @@ export function CancelDownload(arg1:string):Promise<app.Response>;
 
 export function CancelUpload(arg1:string):Promise<app.Response>;
 
+export function ChangeStoragePassphrase(arg1:string,arg2:string):Promise<app.Response>;
+
 export function ClearLogs():Promise<app.Response>;
 
 export function CreateChallenge(arg1:app.CreateChallengeRequest):Promise<app.Response>;
@@ -23,14 +25,20 @@ export function DeleteMessage(arg1:string):Promise<app.Response>;
 
 export function DiscoverServers(arg1:number):Promise<app.Response>;
 
+export function DownloadBundleEntries(arg1:string,arg2:string,arg3:Array<string>):Promise<app.Response>;
+
 export function DownloadFile(arg1:app.DownloadFileRequest):Promise<app.Response>;
 
+export function EnableStorageEncryption(arg1:string):Promise<app.Response>;
+
 export function ExportData(arg1:string,arg2:app.ExportOptions):Promise<app.Response>;
 
 export function FetchHTTPSInfo(arg1:string,arg2:number,arg3:boolean,arg4:number):Promise<app.Response>;
 
 export function GetAppVersion():Promise<string>;
 
+export function GetBundleEntries(arg1:string):Promise<app.Response>;
+
 export function GetChallenge(arg1:string):Promise<app.Response>;
 
 export function GetChallengeProgress(arg1:string,arg2:string):Promise<app.Response>;
@@ -85,14 +93,22 @@ export function GetNetworkInterfaces():Promise<app.Response>;
 
 export function GetNetworkStats():Promise<app.Response>;
 
+export function GetPeerNetworkHistory(arg1:string,arg2:number):Promise<app.Response>;
+
+export function GetPeerNetworkStats():Promise<app.Response>;
+
 export function GetPinnedMessages():Promise<app.Response>;
 
 export function GetRecentChannels():Promise<app.Response>;
 
 export function GetServerStatus():Promise<app.Response>;
 
+export function GetStorageStatus():Promise<app.Response>;
+
 export function GetSubChannels():Promise<app.Response>;
 
+export function GetTransportModes():Promise<app.Response>;
+
 export function GetTypingUsers():Promise<app.Response>;
 
 export function GetUserProfile():Promise<app.Response>;
@@ -107,6 +123,8 @@ export function MuteMember(arg1:string,arg2:number):Promise<app.Response>;
 
 export function PinMessage(arg1:app.PinMessageRequest):Promise<app.Response>;
 
+export function ReconnectClient():Promise<app.Response>;
+
 export function ReactToMessage(arg1:string,arg2:string):Promise<app.Response>;
 
 export function RemoveReaction(arg1:string,arg2:string):Promise<app.Response>;
@@ -139,6 +157,8 @@ export function TestConnection(arg1:string,arg2:models.TransportMode,arg3:number
 
 export function UnbanMember(arg1:string):Promise<app.Response>;
 
+export function UnlockStorage(arg1:string):Promise<app.Response>;
+
 export function UnmuteMember(arg1:string):Promise<app.Response>;
 
 export function UnpinMessage(arg1:string):Promise<app.Response>;
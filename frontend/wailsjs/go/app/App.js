@@ -18,6 +18,10 @@ export function CancelUpload(arg1) {
   return window['go']['app']['App']['CancelUpload'](arg1);
 }
 
+export function ChangeStoragePassphrase(arg1, arg2) {
+  return window['go']['app']['App']['ChangeStoragePassphrase'](arg1, arg2);
+}
+
 export function ClearLogs() {
   return window['go']['app']['App']['ClearLogs']();
 }
@@ -42,10 +46,18 @@ export function DiscoverServers(arg1) {
   return window['go']['app']['App']['DiscoverServers'](arg1);
 }
 
+export function DownloadBundleEntries(arg1, arg2, arg3) {
+  return window['go']['app']['App']['DownloadBundleEntries'](arg1, arg2, arg3);
+}
+
 export function DownloadFile(arg1) {
   return window['go']['app']['App']['DownloadFile'](arg1);
 }
 
+export function EnableStorageEncryption(arg1) {
+  return window['go']['app']['App']['EnableStorageEncryption'](arg1);
+}
+
 export function ExportData(arg1, arg2) {
   return window['go']['app']['App']['ExportData'](arg1, arg2);
 }
@@ -58,6 +70,10 @@ export function GetAppVersion() {
   return window['go']['app']['App']['GetAppVersion']();
 }
 
+export function GetBundleEntries(arg1) {
+  return window['go']['app']['App']['GetBundleEntries'](arg1);
+}
+
 export function GetChallenge(arg1) {
   return window['go']['app']['App']['GetChallenge'](arg1);
 }
@@ -166,6 +182,14 @@ export function GetNetworkStats() {
   return window['go']['app']['App']['GetNetworkStats']();
 }
 
+export function GetPeerNetworkHistory(arg1, arg2) {
+  return window['go']['app']['App']['GetPeerNetworkHistory'](arg1, arg2);
+}
+
+export function GetPeerNetworkStats() {
+  return window['go']['app']['App']['GetPeerNetworkStats']();
+}
+
 export function GetPinnedMessages() {
   return window['go']['app']['App']['GetPinnedMessages']();
 }
@@ -178,10 +202,18 @@ export function GetServerStatus() {
   return window['go']['app']['App']['GetServerStatus']();
 }
 
+export function GetStorageStatus() {
+  return window['go']['app']['App']['GetStorageStatus']();
+}
+
 export function GetSubChannels() {
   return window['go']['app']['App']['GetSubChannels']();
 }
 
+export function GetTransportModes() {
+  return window['go']['app']['App']['GetTransportModes']();
+}
+
 export function GetTypingUsers() {
   return window['go']['app']['App']['GetTypingUsers']();
 }
@@ -214,6 +246,10 @@ export function ReactToMessage(arg1, arg2) {
   return window['go']['app']['App']['ReactToMessage'](arg1, arg2);
 }
 
+export function ReconnectClient() {
+  return window['go']['app']['App']['ReconnectClient']();
+}
+
 export function RemoveReaction(arg1, arg2) {
   return window['go']['app']['App']['RemoveReaction'](arg1, arg2);
 }
@@ -274,6 +310,10 @@ export function UnbanMember(arg1) {
   return window['go']['app']['App']['UnbanMember'](arg1);
 }
 
+export function UnlockStorage(arg1) {
+  return window['go']['app']['App']['UnlockStorage'](arg1);
+}
+
 export function UnmuteMember(arg1) {
   return window['go']['app']['App']['UnmuteMember'](arg1);
 }
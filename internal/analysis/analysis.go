@@ -0,0 +1,184 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 默认限制：分析在服务端同步读取内容，需限制开销
+const (
+	DefaultMaxStrings    = 200
+	DefaultMinStringLen  = 6
+	DefaultMaxEntries    = 100
+	DefaultMaxScanBytes  = 64 << 20
+	DefaultMaxPreviewLen = 4096
+)
+
+// Options 分析选项
+type Options struct {
+	FlagFormat   string // 题目的 Flag 格式（如 flag{...}），为空时使用常见前缀
+	MinStringLen int    // 可打印字符串最小长度
+	MaxStrings   int    // 报告中保留的字符串数量上限
+	MaxEntries   int    // 压缩包条目数量上限
+	MaxScanBytes int64  // 字符串扫描的字节上限
+}
+
+// Report 分析报告
+// 参考: docs/FEATURES.md - 5.3.3 附件自动分析
+type Report struct {
+	Type        string       `json:"type"`        // 识别出的类型（elf/pe/macho/zip/gzip/tar/pcap/pcapng/png/...）
+	MimeType    string       `json:"mime_type"`   // 按魔数推断的MIME类型
+	Description string       `json:"description"` // 类似 file(1) 的简短描述
+	Binary      *BinaryInfo  `json:"binary,omitempty"`
+	Archive     *ArchiveInfo `json:"archive,omitempty"`
+	Pcap        *PcapInfo    `json:"pcap,omitempty"`
+	Strings     []string     `json:"strings,omitempty"`
+	StringCount int          `json:"string_count"`
+	Flags       []string     `json:"flags,omitempty"` // 与 Flag 格式匹配的候选
+	Errors      []string     `json:"errors,omitempty"`
+}
+
+// Analyze 分析一个附件
+// 各阶段互不依赖：某一阶段解析失败只记录到 Errors，不影响其他结果
+func Analyze(r io.ReaderAt, size int64, opts Options) (*Report, error) {
+	opts = opts.withDefaults()
+
+	// 1. 魔数识别
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	kind := DetectType(head[:n])
+	report := &Report{
+		Type:        kind.Name,
+		MimeType:    kind.MimeType,
+		Description: kind.Description,
+	}
+
+	// 2. 按类型解析结构
+	matcher := FlagPattern(opts.FlagFormat)
+	var stageErr error
+	switch kind.Name {
+	case TypeELF, TypePE, TypeMachO, TypeMachOFat:
+		report.Binary, stageErr = AnalyzeBinary(r, kind.Name)
+		if report.Binary != nil {
+			report.Description = report.Binary.Describe()
+		}
+	case TypeZip:
+		report.Archive, stageErr = ListZip(r, size, opts, matcher)
+	case TypeTar:
+		report.Archive, stageErr = ListTar(io.NewSectionReader(r, 0, size), opts, matcher)
+	case TypeGzip:
+		report.Archive, stageErr = ListGzip(io.NewSectionReader(r, 0, size), opts, matcher)
+	case TypePcap, TypePcapNG:
+		report.Pcap, stageErr = SummarizePcap(io.NewSectionReader(r, 0, size), kind.Name)
+	}
+	if stageErr != nil {
+		report.Errors = append(report.Errors, stageErr.Error())
+	}
+
+	// 3. 字符串提取与 Flag 匹配
+	scan := size
+	if scan > opts.MaxScanBytes {
+		scan = opts.MaxScanBytes
+	}
+	result, err := ExtractStrings(io.NewSectionReader(r, 0, scan), opts.MinStringLen, opts.MaxStrings, matcher)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Strings = result.Strings
+	report.StringCount = result.Count
+	report.Flags = result.Flags
+
+	// 压缩包内的小文件同样检查 Flag（CTF 常见的套娃附件）
+	if report.Archive != nil {
+		report.Flags = appendUnique(report.Flags, report.Archive.flags...)
+	}
+
+	return report, nil
+}
+
+// Summary 生成一行摘要（用于系统消息）
+func (r *Report) Summary() string {
+	parts := []string{r.Description}
+	if r.Archive != nil {
+		parts = append(parts, fmt.Sprintf("%d entries", r.Archive.Total))
+	}
+	if r.Pcap != nil {
+		parts = append(parts, fmt.Sprintf("%d packets", r.Pcap.Packets))
+	}
+	if len(r.Flags) > 0 {
+		parts = append(parts, fmt.Sprintf("flag candidates: %s", strings.Join(r.Flags, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Preview 生成文本预览：类型描述、结构信息与前若干条字符串
+func (r *Report) Preview(limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Description)
+	if r.Binary != nil {
+		fmt.Fprintf(&b, "%s\n", r.Binary.Checksec())
+	}
+	if r.Archive != nil {
+		for _, e := range r.Archive.Entries {
+			fmt.Fprintf(&b, "%10d  %s\n", e.Size, e.Name)
+		}
+		if r.Archive.Truncated {
+			fmt.Fprintf(&b, "... (%d entries total)\n", r.Archive.Total)
+		}
+	}
+	if r.Pcap != nil {
+		fmt.Fprintf(&b, "%s\n", r.Pcap.Describe())
+	}
+	for _, f := range r.Flags {
+		fmt.Fprintf(&b, "flag? %s\n", f)
+	}
+	for _, s := range r.Strings {
+		if b.Len()+len(s)+1 > limit {
+			break
+		}
+		fmt.Fprintf(&b, "%s\n", s)
+	}
+	out := b.String()
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return strings.TrimRight(out, "\n")
+}
+
+// withDefaults 填充未设置的选项
+func (o Options) withDefaults() Options {
+	if o.MinStringLen <= 0 {
+		o.MinStringLen = DefaultMinStringLen
+	}
+	if o.MaxStrings <= 0 {
+		o.MaxStrings = DefaultMaxStrings
+	}
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultMaxEntries
+	}
+	if o.MaxScanBytes <= 0 {
+		o.MaxScanBytes = DefaultMaxScanBytes
+	}
+	return o
+}
+
+// appendUnique 追加不重复的元素
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		dup := false
+		for _, existing := range list {
+			if existing == item {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, item)
+		}
+	}
+	return list
+}
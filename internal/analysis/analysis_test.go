@@ -0,0 +1,367 @@
+package analysis
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+// buildPE 构造只有文件头和可选头的 PE32+ 样本
+func buildPE(t *testing.T, characteristics, dllChars uint16, rvaCount uint32) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+
+	oh := pe.OptionalHeader64{
+		Magic:               0x20b,
+		AddressOfEntryPoint: 0x1000,
+		ImageBase:           0x140000000,
+		SectionAlignment:    0x1000,
+		FileAlignment:       0x200,
+		SizeOfImage:         0x2000,
+		SizeOfHeaders:       0x200,
+		Subsystem:           3,
+		DllCharacteristics:  dllChars,
+		NumberOfRvaAndSizes: rvaCount,
+	}
+	fh := pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		SizeOfOptionalHeader: uint16(binary.Size(oh)),
+		Characteristics:      characteristics,
+	}
+	if err := binary.Write(&b, binary.LittleEndian, fh); err != nil {
+		t.Fatalf("write file header: %v", err)
+	}
+	if err := binary.Write(&b, binary.LittleEndian, oh); err != nil {
+		t.Fatalf("write optional header: %v", err)
+	}
+	return b.Bytes()
+}
+
+// buildZip 构造包含目录、带 Flag 的文本与加密标记条目的 zip
+func buildZip(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	if _, err := zw.Create("sub/"); err != nil {
+		t.Fatalf("zip dir: %v", err)
+	}
+	w, err := zw.Create("sub/readme.txt")
+	if err != nil {
+		t.Fatalf("zip entry: %v", err)
+	}
+	w.Write([]byte("the answer is flag{zip_fixture}\n"))
+	w, err = zw.CreateHeader(&zip.FileHeader{Name: "secret.bin", Method: zip.Deflate, Flags: 0x1})
+	if err != nil {
+		t.Fatalf("zip encrypted entry: %v", err)
+	}
+	w.Write([]byte(strings.Repeat("flag{must_not_be_scanned} ", 64)))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return b.Bytes()
+}
+
+// buildTarGz 构造 tar.gz
+func buildTarGz(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	body := []byte("hidden: flag{tgz_fixture}\n")
+	tw.WriteHeader(&tar.Header{Name: "notes.txt", Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	return b.Bytes()
+}
+
+// buildPcap 构造以太网链路的经典 pcap：一个 TCP/80 分组、两个 UDP/53 分组和一个 ARP 分组
+func buildPcap(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], linkEthernet)
+	b.Write(hdr)
+
+	ipv4 := func(proto byte, src, dst byte, sport, dport uint16) []byte {
+		frame := make([]byte, 14+20+8)
+		binary.BigEndian.PutUint16(frame[12:], 0x0800)
+		ip := frame[14:]
+		ip[0] = 0x45
+		ip[9] = proto
+		copy(ip[12:16], []byte{10, 0, 0, src})
+		copy(ip[16:20], []byte{10, 0, 0, dst})
+		binary.BigEndian.PutUint16(ip[20:], sport)
+		binary.BigEndian.PutUint16(ip[22:], dport)
+		return frame
+	}
+	arp := make([]byte, 42)
+	binary.BigEndian.PutUint16(arp[12:], 0x0806)
+
+	frames := [][]byte{
+		ipv4(6, 1, 2, 51000, 80),
+		ipv4(17, 1, 3, 40000, 53),
+		ipv4(17, 3, 1, 53, 40000),
+		arp,
+	}
+	for i, f := range frames {
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:], uint32(1700000000+i))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(f)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		opts     Options
+		wantType string
+		check    func(t *testing.T, r *Report)
+	}{
+		{
+			name:     "hardened elf",
+			data:     readFixture(t, "hello_hardened.elf"),
+			wantType: TypeELF,
+			check: func(t *testing.T, r *Report) {
+				b := r.Binary
+				if b == nil {
+					t.Fatal("no binary info")
+				}
+				if b.Arch != "x86_64" || b.Bits != 64 || b.Static {
+					t.Fatalf("arch=%s bits=%d static=%v", b.Arch, b.Bits, b.Static)
+				}
+				if !b.PIE || !b.NX || !b.Canary || !b.Fortify || b.RELRO != RelroFull || !b.Stripped {
+					t.Fatalf("checksec: %s stripped=%v", b.Checksec(), b.Stripped)
+				}
+				if b.Kind != "pie executable" {
+					t.Fatalf("kind = %q", b.Kind)
+				}
+			},
+		},
+		{
+			name:     "plain elf",
+			data:     readFixture(t, "hello_plain.elf"),
+			wantType: TypeELF,
+			check: func(t *testing.T, r *Report) {
+				b := r.Binary
+				if b == nil {
+					t.Fatal("no binary info")
+				}
+				if b.PIE || b.NX || b.Canary || b.RELRO != RelroNone || b.Stripped {
+					t.Fatalf("checksec: %s stripped=%v", b.Checksec(), b.Stripped)
+				}
+				if b.Kind != "executable" {
+					t.Fatalf("kind = %q", b.Kind)
+				}
+				if len(r.Flags) != 1 || r.Flags[0] != "flag{elf_fixture}" {
+					t.Fatalf("flags = %v", r.Flags)
+				}
+			},
+		},
+		{
+			name:     "pe dll",
+			data:     buildPE(t, peDLL, peDynamicBase|peNXCompat, 16),
+			wantType: TypePE,
+			check: func(t *testing.T, r *Report) {
+				b := r.Binary
+				if b == nil {
+					t.Fatal("no binary info")
+				}
+				if b.Kind != "dll" || b.Arch != "x86_64" || b.Bits != 64 || !b.PIE || !b.NX || b.Canary {
+					t.Fatalf("unexpected pe info: %+v", b)
+				}
+				if b.Entry != 0x140001000 {
+					t.Fatalf("entry = %#x", b.Entry)
+				}
+			},
+		},
+		{
+			name:     "pe with oversized directory count",
+			data:     buildPE(t, 0, 0, 0x40),
+			wantType: TypePE,
+		},
+		{
+			name:     "zip",
+			data:     buildZip(t),
+			wantType: TypeZip,
+			check: func(t *testing.T, r *Report) {
+				a := r.Archive
+				if a == nil || a.Total != 3 || !a.Encrypted {
+					t.Fatalf("archive = %+v", a)
+				}
+				if len(r.Flags) != 1 || r.Flags[0] != "flag{zip_fixture}" {
+					t.Fatalf("flags = %v (encrypted entries must not be scanned)", r.Flags)
+				}
+			},
+		},
+		{
+			name:     "tar.gz",
+			data:     buildTarGz(t),
+			wantType: TypeGzip,
+			check: func(t *testing.T, r *Report) {
+				if r.Archive == nil || r.Archive.Format != "tar.gz" || r.Archive.Total != 1 {
+					t.Fatalf("archive = %+v", r.Archive)
+				}
+				if len(r.Flags) != 1 || r.Flags[0] != "flag{tgz_fixture}" {
+					t.Fatalf("flags = %v", r.Flags)
+				}
+			},
+		},
+		{
+			name:     "pcap",
+			data:     buildPcap(t),
+			wantType: TypePcap,
+			check: func(t *testing.T, r *Report) {
+				p := r.Pcap
+				if p == nil {
+					t.Fatal("no pcap info")
+				}
+				if p.Packets != 4 || p.Hosts != 3 || p.Truncated {
+					t.Fatalf("packets=%d hosts=%d truncated=%v", p.Packets, p.Hosts, p.Truncated)
+				}
+				if p.Protocols["TCP"] != 1 || p.Protocols["UDP"] != 2 || p.Protocols["ARP"] != 1 {
+					t.Fatalf("protocols = %v", p.Protocols)
+				}
+				if len(p.TopPorts) == 0 || p.TopPorts[0].Port != 53 || p.TopPorts[0].Service != "dns" {
+					t.Fatalf("top ports = %+v", p.TopPorts)
+				}
+			},
+		},
+		{
+			name:     "text with custom flag format",
+			data:     []byte("hello\nctf{custom_format}\nflag{ignored}\n"),
+			opts:     Options{FlagFormat: "ctf{...}"},
+			wantType: TypeText,
+			check: func(t *testing.T, r *Report) {
+				if len(r.Flags) != 1 || r.Flags[0] != "ctf{custom_format}" {
+					t.Fatalf("flags = %v", r.Flags)
+				}
+			},
+		},
+		{
+			name:     "empty",
+			data:     nil,
+			wantType: TypeData,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := Analyze(bytes.NewReader(tc.data), int64(len(tc.data)), tc.opts)
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if r.Type != tc.wantType {
+				t.Fatalf("type = %s, want %s", r.Type, tc.wantType)
+			}
+			if tc.check != nil {
+				tc.check(t, r)
+			}
+			if r.Summary() == "" || r.Preview(DefaultMaxPreviewLen) == "" && len(tc.data) > 0 {
+				t.Fatalf("empty summary/preview for %s", tc.name)
+			}
+		})
+	}
+}
+
+// TestAnalyzeTruncated 截断的样本不能让分析 panic：结构解析失败只记录到 Errors
+func TestAnalyzeTruncated(t *testing.T) {
+	fixtures := map[string][]byte{
+		"elf":    readFixture(t, "hello_hardened.elf"),
+		"pe":     buildPE(t, peDLL, peDynamicBase|peNXCompat, 16),
+		"zip":    buildZip(t),
+		"tar.gz": buildTarGz(t),
+		"pcap":   buildPcap(t),
+	}
+	for name, data := range fixtures {
+		cuts := []int{1, 2, 4, 8, 16, 20, 24, 40, 63, 64, 100, 200, 512, len(data) / 2, len(data) - 1}
+		for _, n := range cuts {
+			if n <= 0 || n >= len(data) {
+				continue
+			}
+			part := data[:n]
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Fatalf("%s truncated to %d bytes: panic: %v", name, n, p)
+					}
+				}()
+				if _, err := Analyze(bytes.NewReader(part), int64(n), Options{}); err != nil {
+					t.Fatalf("%s truncated to %d bytes: %v", name, n, err)
+				}
+				if _, err := GeneratePreview(bytes.NewReader(part), int64(n), "sample."+name, PreviewOptions{}); err != nil {
+					t.Fatalf("%s truncated to %d bytes: preview: %v", name, n, err)
+				}
+			}()
+		}
+	}
+}
+
+func TestTruncatedPcapKeepsPartialSummary(t *testing.T) {
+	data := buildPcap(t)
+	// 截掉最后一个分组的一半
+	cut := data[:len(data)-20]
+	r, err := Analyze(bytes.NewReader(cut), int64(len(cut)), Options{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if r.Pcap == nil || !r.Pcap.Truncated || r.Pcap.Packets != 3 {
+		t.Fatalf("pcap = %+v", r.Pcap)
+	}
+	if !strings.Contains(r.Pcap.Describe(), "truncated") {
+		t.Fatalf("describe = %q", r.Pcap.Describe())
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		head []byte
+		want string
+	}{
+		{[]byte("\x7fELF\x02\x01"), TypeELF},
+		{[]byte("MZ\x90\x00"), TypePE},
+		{[]byte("PK\x03\x04"), TypeZip},
+		{[]byte{0x1f, 0x8b, 0x08}, TypeGzip},
+		{[]byte{0xd4, 0xc3, 0xb2, 0xa1}, TypePcap},
+		{[]byte{0x0a, 0x0d, 0x0d, 0x0a}, TypePcapNG},
+		{[]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 2}, TypeMachOFat},
+		{[]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52}, TypeData}, // Java class
+		{[]byte("plain text\n"), TypeText},
+		{[]byte{0x00, 0x01, 0x02}, TypeData},
+	}
+	for _, tc := range tests {
+		if got := DetectType(tc.head).Name; got != tc.want {
+			t.Errorf("DetectType(%q) = %s, want %s", tc.head, got, tc.want)
+		}
+	}
+}
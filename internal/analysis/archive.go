@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// 压缩包内容扫描限制（防止解压炸弹）
+const (
+	maxEntryScan   = 1 << 20
+	maxArchiveScan = 8 << 20
+)
+
+// ArchiveEntry 压缩包条目
+type ArchiveEntry struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	IsDir     bool   `json:"is_dir,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// ArchiveInfo 压缩包内容列表
+type ArchiveInfo struct {
+	Format    string         `json:"format"`
+	Entries   []ArchiveEntry `json:"entries"`
+	Total     int            `json:"total"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Encrypted bool           `json:"encrypted,omitempty"` // 存在加密条目（常见的 zip 密码题）
+
+	flags   []string // 条目内容中匹配到的 Flag
+	scanned int64
+}
+
+// add 登记一个条目
+func (a *ArchiveInfo) add(e ArchiveEntry, maxEntries int) {
+	a.Total++
+	if e.Encrypted {
+		a.Encrypted = true
+	}
+	if len(a.Entries) < maxEntries {
+		a.Entries = append(a.Entries, e)
+	} else {
+		a.Truncated = true
+	}
+}
+
+// scan 扫描小条目内容中的 Flag
+func (a *ArchiveInfo) scan(r io.Reader, size int64, minLen int, flag *regexp.Regexp) {
+	if flag == nil || size > maxEntryScan || a.scanned+size > maxArchiveScan {
+		return
+	}
+	a.scanned += size
+	result, _ := ExtractStrings(io.LimitReader(r, maxEntryScan), minLen, 0, flag)
+	a.flags = appendUnique(a.flags, result.Flags...)
+}
+
+// ListZip 列出 zip 条目
+func ListZip(r io.ReaderAt, size int64, opts Options, flag *regexp.Regexp) (*ArchiveInfo, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip: %w", err)
+	}
+	info := &ArchiveInfo{Format: TypeZip}
+	for _, f := range zr.File {
+		entry := ArchiveEntry{
+			Name:      f.Name,
+			Size:      int64(f.UncompressedSize64),
+			IsDir:     f.FileInfo().IsDir(),
+			Encrypted: f.Flags&0x1 != 0,
+		}
+		info.add(entry, opts.MaxEntries)
+		if entry.IsDir || entry.Encrypted {
+			continue
+		}
+		if rc, err := f.Open(); err == nil {
+			info.scan(rc, entry.Size, opts.MinStringLen, flag)
+			rc.Close()
+		}
+	}
+	return info, nil
+}
+
+// ListTar 列出 tar 条目
+func ListTar(r io.Reader, opts Options, flag *regexp.Regexp) (*ArchiveInfo, error) {
+	info := &ArchiveInfo{Format: TypeTar}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return info, nil
+		}
+		if err != nil {
+			return info, fmt.Errorf("failed to read tar: %w", err)
+		}
+		entry := ArchiveEntry{
+			Name:  hdr.Name,
+			Size:  hdr.Size,
+			IsDir: hdr.Typeflag == tar.TypeDir,
+		}
+		info.add(entry, opts.MaxEntries)
+		if hdr.Typeflag == tar.TypeReg {
+			info.scan(tr, hdr.Size, opts.MinStringLen, flag)
+		}
+	}
+}
+
+// ListGzip 列出 gzip 内容：内层为 tar 时按 tar 列出，否则为单个条目
+func ListGzip(r io.Reader, opts Options, flag *regexp.Regexp) (*ArchiveInfo, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip: %w", err)
+	}
+	defer zr.Close()
+
+	// 解压后内容同样受扫描上限约束
+	inner := bufio.NewReader(io.LimitReader(zr, opts.MaxScanBytes))
+	head, _ := inner.Peek(512)
+	if DetectType(head).Name == TypeTar {
+		info, err := ListTar(inner, opts, flag)
+		if info != nil {
+			info.Format = "tar.gz"
+		}
+		return info, err
+	}
+
+	info := &ArchiveInfo{Format: TypeGzip}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(inner, maxEntryScan+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress gzip: %w", err)
+	}
+	if rest, _ := io.Copy(io.Discard, inner); rest > 0 {
+		n += rest
+	}
+	name := zr.Name
+	if name == "" {
+		name = "(stream)"
+	}
+	info.add(ArchiveEntry{Name: name, Size: n}, opts.MaxEntries)
+	info.scan(&buf, min(int64(buf.Len()), maxEntryScan), opts.MinStringLen, flag)
+	return info, nil
+}
@@ -0,0 +1,414 @@
+package analysis
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RELRO 级别
+const (
+	RelroNone    = "none"
+	RelroPartial = "partial"
+	RelroFull    = "full"
+)
+
+// BinaryInfo 可执行文件头信息与保护机制（对应 file + checksec）
+type BinaryInfo struct {
+	Format   string   `json:"format"` // elf/pe/macho
+	Kind     string   `json:"kind"`   // executable/shared object/relocatable/dll/...
+	Arch     string   `json:"arch"`
+	Bits     int      `json:"bits"`
+	Endian   string   `json:"endian"`
+	Entry    uint64   `json:"entry"`
+	Static   bool     `json:"static,omitempty"`
+	PIE      bool     `json:"pie"`
+	NX       bool     `json:"nx"`
+	Canary   bool     `json:"canary"`
+	RELRO    string   `json:"relro,omitempty"` // 仅 ELF
+	Fortify  bool     `json:"fortify,omitempty"`
+	Stripped bool     `json:"stripped"`
+	Arches   []string `json:"arches,omitempty"` // Mach-O universal 中包含的架构
+	Libs     []string `json:"libs,omitempty"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// AnalyzeBinary 解析 ELF/PE/Mach-O 文件头
+func AnalyzeBinary(r io.ReaderAt, kind string) (*BinaryInfo, error) {
+	switch kind {
+	case TypeELF:
+		return analyzeELF(r)
+	case TypePE:
+		return analyzePE(r)
+	case TypeMachO, TypeMachOFat:
+		return analyzeMachO(r, kind == TypeMachOFat)
+	}
+	return nil, fmt.Errorf("not an executable: %s", kind)
+}
+
+// Describe 生成类似 file(1) 的描述
+func (b *BinaryInfo) Describe() string {
+	parts := []string{fmt.Sprintf("%s %d-bit %s %s", strings.ToUpper(b.Format), b.Bits, b.Endian, b.Kind), b.Arch}
+	if b.Static {
+		parts = append(parts, "statically linked")
+	}
+	if len(b.Arches) > 1 {
+		parts = append(parts, "universal: "+strings.Join(b.Arches, "/"))
+	}
+	if b.Stripped {
+		parts = append(parts, "stripped")
+	} else {
+		parts = append(parts, "not stripped")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Checksec 生成保护机制摘要
+func (b *BinaryInfo) Checksec() string {
+	onOff := func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return "no"
+	}
+	s := fmt.Sprintf("Arch: %s | PIE: %s | NX: %s | Canary: %s", b.Arch, onOff(b.PIE), onOff(b.NX), onOff(b.Canary))
+	if b.RELRO != "" {
+		s += " | RELRO: " + b.RELRO
+	}
+	if b.Format == TypeELF {
+		s += " | Fortify: " + onOff(b.Fortify)
+	}
+	return s
+}
+
+// analyzeELF 解析 ELF（规则与 checksec.sh 一致）
+func analyzeELF(r io.ReaderAt) (*BinaryInfo, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ELF: %w", err)
+	}
+	defer f.Close()
+
+	info := &BinaryInfo{
+		Format: TypeELF,
+		Arch:   strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")),
+		Bits:   32,
+		Endian: endianName(f.ByteOrder),
+		Entry:  f.Entry,
+		RELRO:  RelroNone,
+	}
+	if f.Class == elf.ELFCLASS64 {
+		info.Bits = 64
+	}
+
+	// 1. 程序头：解释器、栈可执行、RELRO
+	hasInterp, hasDynamic, hasStackHdr := false, false, false
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			hasInterp = true
+		case elf.PT_DYNAMIC:
+			hasDynamic = true
+		case elf.PT_GNU_STACK:
+			hasStackHdr = true
+			info.NX = p.Flags&elf.PF_X == 0
+		case elf.PT_GNU_RELRO:
+			info.RELRO = RelroPartial
+		}
+	}
+	if !hasStackHdr {
+		// 缺少 GNU_STACK 时内核按可执行栈处理
+		info.NX = false
+	}
+	info.Static = !hasDynamic
+
+	// 2. 动态段标志：BIND_NOW 使 RELRO 成为 full，DF_1_PIE 标识 PIE
+	flags1 := dynFlag(f, elf.DT_FLAGS_1)
+	if info.RELRO == RelroPartial {
+		if vals, _ := f.DynValue(elf.DT_BIND_NOW); len(vals) > 0 ||
+			dynFlag(f, elf.DT_FLAGS)&uint64(elf.DF_BIND_NOW) != 0 ||
+			flags1&uint64(elf.DF_1_NOW) != 0 {
+			info.RELRO = RelroFull
+		}
+	}
+
+	switch f.Type {
+	case elf.ET_EXEC:
+		info.Kind = "executable"
+	case elf.ET_DYN:
+		info.PIE = true
+		if hasInterp || flags1&uint64(elf.DF_1_PIE) != 0 {
+			info.Kind = "pie executable"
+		} else {
+			info.Kind = "shared object"
+		}
+	case elf.ET_REL:
+		info.Kind = "relocatable"
+	case elf.ET_CORE:
+		info.Kind = "core file"
+	default:
+		info.Kind = f.Type.String()
+	}
+
+	// 3. 符号：canary 与 fortify 通过引用的 libc 符号判断
+	info.Stripped = f.Section(".symtab") == nil
+	var names []string
+	if syms, err := f.DynamicSymbols(); err == nil {
+		for _, s := range syms {
+			names = append(names, s.Name)
+		}
+	}
+	if syms, err := f.Symbols(); err == nil {
+		for _, s := range syms {
+			names = append(names, s.Name)
+		}
+	}
+	for _, name := range names {
+		switch {
+		case name == "__stack_chk_fail" || name == "__stack_chk_guard" || name == "__intel_security_cookie":
+			info.Canary = true
+		case strings.HasPrefix(name, "__") && strings.HasSuffix(name, "_chk") && name != "__stack_chk_fail":
+			info.Fortify = true
+		}
+	}
+	if libs, err := f.ImportedLibraries(); err == nil {
+		info.Libs = libs
+	}
+	if f.Section(".gopclntab") != nil || f.Section(".go.buildinfo") != nil || f.Section(".note.go.buildid") != nil {
+		info.Notes = append(info.Notes, "Go binary")
+	}
+
+	return info, nil
+}
+
+// dynFlag 读取动态段中的标志位（不存在时为 0）
+func dynFlag(f *elf.File, tag elf.DynTag) uint64 {
+	vals, err := f.DynValue(tag)
+	if err != nil || len(vals) == 0 {
+		return 0
+	}
+	return vals[0]
+}
+
+// PE DllCharacteristics 与目录索引
+const (
+	peDynamicBase     = 0x0040
+	peNXCompat        = 0x0100
+	peDLL             = 0x2000
+	peLoadConfigIndex = 10
+	peCLRIndex        = 14
+)
+
+// analyzePE 解析 PE：ASLR（DYNAMIC_BASE）视作 PIE，/GS 通过加载配置中的 SecurityCookie 判断
+func analyzePE(r io.ReaderAt) (*BinaryInfo, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PE: %w", err)
+	}
+	defer f.Close()
+
+	info := &BinaryInfo{
+		Format:   TypePE,
+		Kind:     "executable",
+		Arch:     peMachineName(f.Machine),
+		Bits:     32,
+		Endian:   "little-endian",
+		Stripped: f.NumberOfSymbols == 0,
+	}
+	if f.Characteristics&peDLL != 0 {
+		info.Kind = "dll"
+	}
+
+	var (
+		dllChars   uint16
+		dirs       []pe.DataDirectory
+		cookieOff  uint32
+		cookieSize int
+	)
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dllChars, dirs = oh.DllCharacteristics, dataDirs(oh.DataDirectory[:], oh.NumberOfRvaAndSizes)
+		info.Entry = uint64(oh.ImageBase) + uint64(oh.AddressOfEntryPoint)
+		cookieOff, cookieSize = 0x3c, 4
+	case *pe.OptionalHeader64:
+		info.Bits = 64
+		dllChars, dirs = oh.DllCharacteristics, dataDirs(oh.DataDirectory[:], oh.NumberOfRvaAndSizes)
+		info.Entry = oh.ImageBase + uint64(oh.AddressOfEntryPoint)
+		cookieOff, cookieSize = 0x58, 8
+	default:
+		return info, nil
+	}
+	info.PIE = dllChars&peDynamicBase != 0
+	info.NX = dllChars&peNXCompat != 0
+
+	if len(dirs) > peLoadConfigIndex {
+		dir := dirs[peLoadConfigIndex]
+		if dir.VirtualAddress != 0 && dir.Size >= cookieOff+uint32(cookieSize) {
+			buf := make([]byte, cookieSize)
+			if peReadRVA(f, dir.VirtualAddress+cookieOff, buf) {
+				var cookie uint64
+				if cookieSize == 4 {
+					cookie = uint64(binary.LittleEndian.Uint32(buf))
+				} else {
+					cookie = binary.LittleEndian.Uint64(buf)
+				}
+				info.Canary = cookie != 0
+			}
+		}
+	}
+	if len(dirs) > peCLRIndex && dirs[peCLRIndex].VirtualAddress != 0 {
+		info.Notes = append(info.Notes, ".NET assembly")
+	}
+	if libs, err := f.ImportedLibraries(); err == nil {
+		info.Libs = libs
+	}
+
+	return info, nil
+}
+
+// dataDirs 按 NumberOfRvaAndSizes 截取数据目录，畸形文件声明的数量可能超过 16
+func dataDirs(dirs []pe.DataDirectory, n uint32) []pe.DataDirectory {
+	if int64(n) < int64(len(dirs)) {
+		return dirs[:n]
+	}
+	return dirs
+}
+
+// peReadRVA 按相对虚拟地址读取节内数据
+func peReadRVA(f *pe.File, rva uint32, buf []byte) bool {
+	for _, s := range f.Sections {
+		size := s.VirtualSize
+		if s.Size > size {
+			size = s.Size
+		}
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+size {
+			n, _ := s.ReadAt(buf, int64(rva-s.VirtualAddress))
+			return n == len(buf)
+		}
+	}
+	return false
+}
+
+// peMachineName 将 PE Machine 转为架构名
+func peMachineName(m uint16) string {
+	switch m {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "x86_64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "aarch64"
+	case pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	}
+	return fmt.Sprintf("0x%x", m)
+}
+
+// Mach-O 头标志
+const (
+	machoAllowStackExecution = 0x20000
+	machoPIE                 = 0x200000
+)
+
+// analyzeMachO 解析 Mach-O（universal 文件取第一个架构）
+func analyzeMachO(r io.ReaderAt, fat bool) (*BinaryInfo, error) {
+	var (
+		f      *macho.File
+		arches []string
+	)
+	if fat {
+		ff, err := macho.NewFatFile(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Mach-O universal binary: %w", err)
+		}
+		defer ff.Close()
+		if len(ff.Arches) == 0 {
+			return nil, fmt.Errorf("empty Mach-O universal binary")
+		}
+		for _, a := range ff.Arches {
+			arches = append(arches, machoCPUName(a.Cpu))
+		}
+		f = ff.Arches[0].File
+	} else {
+		var err error
+		if f, err = macho.NewFile(r); err != nil {
+			return nil, fmt.Errorf("failed to parse Mach-O: %w", err)
+		}
+		defer f.Close()
+	}
+
+	info := &BinaryInfo{
+		Format: TypeMachO,
+		Arch:   machoCPUName(f.Cpu),
+		Bits:   32,
+		Endian: endianName(f.ByteOrder),
+		PIE:    f.Flags&machoPIE != 0,
+		NX:     f.Flags&machoAllowStackExecution == 0,
+		Arches: arches,
+	}
+	if f.Magic == macho.Magic64 {
+		info.Bits = 64
+	}
+	switch f.Type {
+	case macho.TypeExec:
+		info.Kind = "executable"
+	case macho.TypeDylib:
+		info.Kind = "dylib"
+		info.PIE = true
+	case macho.TypeBundle:
+		info.Kind = "bundle"
+	case macho.TypeObj:
+		info.Kind = "object"
+	default:
+		info.Kind = f.Type.String()
+	}
+
+	// 本地符号全部移除视为 stripped（strip 会保留导出与导入符号）
+	info.Stripped = true
+	if f.Symtab != nil {
+		for _, s := range f.Symtab.Syms {
+			if s.Name == "___stack_chk_fail" || s.Name == "___stack_chk_guard" {
+				info.Canary = true
+			}
+			if s.Type&0x01 == 0 && s.Type&0x0e == 0x0e {
+				info.Stripped = false
+			}
+		}
+	}
+	if libs, err := f.ImportedLibraries(); err == nil {
+		info.Libs = libs
+	}
+
+	return info, nil
+}
+
+// machoCPUName 将 Mach-O CPU 类型转为架构名
+func machoCPUName(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuPpc:
+		return "ppc"
+	case macho.CpuPpc64:
+		return "ppc64"
+	}
+	return cpu.String()
+}
+
+// endianName 字节序名称
+func endianName(order binary.ByteOrder) string {
+	if order == binary.BigEndian {
+		return "big-endian"
+	}
+	return "little-endian"
+}
@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// 识别出的文件类型
+const (
+	TypeELF      = "elf"
+	TypePE       = "pe"
+	TypeMachO    = "macho"
+	TypeMachOFat = "macho-fat"
+	TypeZip      = "zip"
+	TypeGzip     = "gzip"
+	TypeTar      = "tar"
+	TypeBzip2    = "bzip2"
+	TypeXZ       = "xz"
+	Type7z       = "7z"
+	TypeRar      = "rar"
+	TypePcap     = "pcap"
+	TypePcapNG   = "pcapng"
+	TypePNG      = "png"
+	TypeJPEG     = "jpeg"
+	TypeGIF      = "gif"
+	TypeBMP      = "bmp"
+	TypePDF      = "pdf"
+	TypeWAV      = "wav"
+	TypeSQLite   = "sqlite"
+	TypeText     = "text"
+	TypeData     = "data"
+)
+
+// FileType 魔数识别结果
+type FileType struct {
+	Name        string
+	MimeType    string
+	Description string
+}
+
+// magicEntry 魔数表项
+type magicEntry struct {
+	offset int
+	magic  []byte
+	kind   FileType
+}
+
+// magicTable 按前缀匹配的魔数表（顺序即优先级）
+var magicTable = []magicEntry{
+	{0, []byte("\x7fELF"), FileType{TypeELF, "application/x-executable", "ELF executable"}},
+	{0, []byte("MZ"), FileType{TypePE, "application/vnd.microsoft.portable-executable", "PE executable"}},
+	{0, []byte{0xfe, 0xed, 0xfa, 0xce}, FileType{TypeMachO, "application/x-mach-binary", "Mach-O executable"}},
+	{0, []byte{0xce, 0xfa, 0xed, 0xfe}, FileType{TypeMachO, "application/x-mach-binary", "Mach-O executable"}},
+	{0, []byte{0xfe, 0xed, 0xfa, 0xcf}, FileType{TypeMachO, "application/x-mach-binary", "Mach-O executable"}},
+	{0, []byte{0xcf, 0xfa, 0xed, 0xfe}, FileType{TypeMachO, "application/x-mach-binary", "Mach-O executable"}},
+	{0, []byte("PK\x03\x04"), FileType{TypeZip, "application/zip", "Zip archive"}},
+	{0, []byte("PK\x05\x06"), FileType{TypeZip, "application/zip", "Zip archive (empty)"}},
+	{0, []byte{0x1f, 0x8b}, FileType{TypeGzip, "application/gzip", "gzip compressed data"}},
+	{0, []byte("BZh"), FileType{TypeBzip2, "application/x-bzip2", "bzip2 compressed data"}},
+	{0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, FileType{TypeXZ, "application/x-xz", "XZ compressed data"}},
+	{0, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, FileType{Type7z, "application/x-7z-compressed", "7-zip archive"}},
+	{0, []byte("Rar!\x1a\x07"), FileType{TypeRar, "application/vnd.rar", "RAR archive"}},
+	{0, []byte{0xd4, 0xc3, 0xb2, 0xa1}, FileType{TypePcap, "application/vnd.tcpdump.pcap", "pcap capture file"}},
+	{0, []byte{0xa1, 0xb2, 0xc3, 0xd4}, FileType{TypePcap, "application/vnd.tcpdump.pcap", "pcap capture file"}},
+	{0, []byte{0x4d, 0x3c, 0xb2, 0xa1}, FileType{TypePcap, "application/vnd.tcpdump.pcap", "pcap capture file (ns)"}},
+	{0, []byte{0xa1, 0xb2, 0x3c, 0x4d}, FileType{TypePcap, "application/vnd.tcpdump.pcap", "pcap capture file (ns)"}},
+	{0, []byte{0x0a, 0x0d, 0x0d, 0x0a}, FileType{TypePcapNG, "application/x-pcapng", "pcapng capture file"}},
+	{0, []byte("\x89PNG\r\n\x1a\n"), FileType{TypePNG, "image/png", "PNG image data"}},
+	{0, []byte{0xff, 0xd8, 0xff}, FileType{TypeJPEG, "image/jpeg", "JPEG image data"}},
+	{0, []byte("GIF87a"), FileType{TypeGIF, "image/gif", "GIF image data"}},
+	{0, []byte("GIF89a"), FileType{TypeGIF, "image/gif", "GIF image data"}},
+	{0, []byte("BM"), FileType{TypeBMP, "image/bmp", "BMP image data"}},
+	{0, []byte("%PDF-"), FileType{TypePDF, "application/pdf", "PDF document"}},
+	{0, []byte("SQLite format 3\x00"), FileType{TypeSQLite, "application/vnd.sqlite3", "SQLite 3.x database"}},
+	{257, []byte("ustar"), FileType{TypeTar, "application/x-tar", "POSIX tar archive"}},
+}
+
+// DetectType 根据文件头识别类型
+func DetectType(head []byte) FileType {
+	// Java class 与 Mach-O fat 共用 0xcafebabe，以架构数量区分（class 文件此处为版本号，通常 > 30）
+	if len(head) >= 8 && bytes.Equal(head[:4], []byte{0xca, 0xfe, 0xba, 0xbe}) {
+		if n := binary.BigEndian.Uint32(head[4:8]); n > 0 && n < 20 {
+			return FileType{TypeMachOFat, "application/x-mach-binary", "Mach-O universal binary"}
+		}
+	}
+	if len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")) {
+		return FileType{TypeWAV, "audio/wav", "RIFF WAVE audio"}
+	}
+	for _, e := range magicTable {
+		if len(head) >= e.offset+len(e.magic) && bytes.Equal(head[e.offset:e.offset+len(e.magic)], e.magic) {
+			return e.kind
+		}
+	}
+	if looksLikeText(head) {
+		return FileType{TypeText, "text/plain", "ASCII text"}
+	}
+	return FileType{TypeData, "application/octet-stream", "data"}
+}
+
+// looksLikeText 判断文件头是否为可打印文本（UTF-8，无控制字符）
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	// 文件头可能截断了多字节字符
+	for i := 0; i < utf8.UTFMax-1 && len(head) > 1 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	for _, c := range head {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return utf8.Valid(head)
+}
@@ -0,0 +1,398 @@
+package analysis
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 抓包解析限制
+const (
+	maxPcapPackets = 2_000_000
+	maxPcapRecord  = 256 << 10
+	topPortCount   = 8
+)
+
+// 链路层类型（仅解析常见类型）
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+	linkRawAlt   = 12
+)
+
+// wellKnownPorts 常见服务端口（用于摘要展示）
+var wellKnownPorts = map[uint16]string{
+	20: "ftp-data", 21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "dns",
+	67: "dhcp", 68: "dhcp", 69: "tftp", 80: "http", 110: "pop3", 123: "ntp",
+	143: "imap", 161: "snmp", 389: "ldap", 443: "https", 445: "smb", 993: "imaps",
+	1883: "mqtt", 3306: "mysql", 3389: "rdp", 5060: "sip", 5432: "postgres",
+	6379: "redis", 8080: "http-alt", 502: "modbus", 4444: "metasploit",
+}
+
+// PortCount 端口统计
+type PortCount struct {
+	Port    uint16 `json:"port"`
+	Proto   string `json:"proto"`
+	Service string `json:"service,omitempty"`
+	Packets int    `json:"packets"`
+}
+
+// PcapInfo 抓包摘要
+type PcapInfo struct {
+	Format    string         `json:"format"`
+	LinkType  int            `json:"link_type"`
+	Packets   int            `json:"packets"`
+	Bytes     int64          `json:"bytes"`
+	Start     time.Time      `json:"start,omitempty"`
+	End       time.Time      `json:"end,omitempty"`
+	Protocols map[string]int `json:"protocols"`
+	Hosts     int            `json:"hosts"`
+	TopPorts  []PortCount    `json:"top_ports,omitempty"`
+	Truncated bool           `json:"truncated,omitempty"`
+
+	hosts map[string]struct{}
+	ports map[string]*PortCount
+}
+
+// SummarizePcap 统计 pcap/pcapng 抓包：包数、时间范围、协议分布与主要端口
+func SummarizePcap(r io.Reader, kind string) (*PcapInfo, error) {
+	info := &PcapInfo{
+		Format:    kind,
+		Protocols: make(map[string]int),
+		hosts:     make(map[string]struct{}),
+		ports:     make(map[string]*PortCount),
+	}
+	br := bufio.NewReaderSize(r, 64<<10)
+
+	var err error
+	if kind == TypePcapNG {
+		err = info.readPcapNG(br)
+	} else {
+		err = info.readPcap(br)
+	}
+
+	info.Hosts = len(info.hosts)
+	for _, pc := range info.ports {
+		info.TopPorts = append(info.TopPorts, *pc)
+	}
+	sort.Slice(info.TopPorts, func(i, j int) bool {
+		if info.TopPorts[i].Packets != info.TopPorts[j].Packets {
+			return info.TopPorts[i].Packets > info.TopPorts[j].Packets
+		}
+		return info.TopPorts[i].Port < info.TopPorts[j].Port
+	})
+	if len(info.TopPorts) > topPortCount {
+		info.TopPorts = info.TopPorts[:topPortCount]
+	}
+	// 截断的抓包（常见于题目附件）仍返回已统计部分
+	if err == io.ErrUnexpectedEOF {
+		info.Truncated = true
+		err = nil
+	}
+	return info, err
+}
+
+// Describe 生成摘要文本
+func (p *PcapInfo) Describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d packets, %d bytes", p.Format, p.Packets, p.Bytes)
+	if !p.Start.IsZero() && !p.End.IsZero() {
+		fmt.Fprintf(&b, " over %s", p.End.Sub(p.Start).Round(time.Millisecond))
+	}
+	fmt.Fprintf(&b, ", %d hosts", p.Hosts)
+
+	protos := make([]string, 0, len(p.Protocols))
+	for name := range p.Protocols {
+		protos = append(protos, name)
+	}
+	sort.Strings(protos)
+	for i, name := range protos {
+		protos[i] = fmt.Sprintf("%s %d", name, p.Protocols[name])
+	}
+	if len(protos) > 0 {
+		fmt.Fprintf(&b, "; %s", strings.Join(protos, ", "))
+	}
+
+	if len(p.TopPorts) > 0 {
+		ports := make([]string, 0, len(p.TopPorts))
+		for _, pc := range p.TopPorts {
+			s := fmt.Sprintf("%d/%s", pc.Port, pc.Proto)
+			if pc.Service != "" {
+				s += "(" + pc.Service + ")"
+			}
+			ports = append(ports, fmt.Sprintf("%s %d", s, pc.Packets))
+		}
+		fmt.Fprintf(&b, "; top ports: %s", strings.Join(ports, ", "))
+	}
+	if p.Truncated {
+		b.WriteString(" (truncated)")
+	}
+	return b.String()
+}
+
+// readPcap 解析经典 pcap
+func (p *PcapInfo) readPcap(r io.Reader) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("failed to read pcap header: %w", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	nano := false
+	switch binary.LittleEndian.Uint32(hdr[:4]) {
+	case 0xa1b2c3d4:
+	case 0xa1b23c4d:
+		nano = true
+	case 0xd4c3b2a1:
+		order = binary.BigEndian
+	case 0x4d3cb2a1:
+		order, nano = binary.BigEndian, true
+	default:
+		return fmt.Errorf("invalid pcap magic")
+	}
+	p.LinkType = int(order.Uint32(hdr[20:24]) & 0x0fffffff)
+
+	var rec [16]byte
+	buf := make([]byte, 0, 2048)
+	for p.Packets < maxPcapPackets {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		sec, frac := order.Uint32(rec[0:4]), order.Uint32(rec[4:8])
+		incl, orig := order.Uint32(rec[8:12]), order.Uint32(rec[12:16])
+		if incl > maxPcapRecord {
+			return fmt.Errorf("invalid pcap record length: %d", incl)
+		}
+		buf = grow(buf, int(incl))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		nsec := int64(frac) * 1000
+		if nano {
+			nsec = int64(frac)
+		}
+		p.packetLink(p.LinkType, time.Unix(int64(sec), nsec).UTC(), int64(orig), buf)
+	}
+	p.Truncated = true
+	return nil
+}
+
+// readPcapNG 解析 pcapng（按块遍历，统计 EPB/SPB；时间戳按默认微秒精度）
+func (p *PcapInfo) readPcapNG(r io.Reader) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	linkTypes := make([]int, 0, 1)
+	var hdr [8]byte
+	body := make([]byte, 0, 2048)
+
+	for p.Packets < maxPcapPackets {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		blockType := order.Uint32(hdr[0:4])
+		if blockType == 0x0a0d0d0a {
+			// 节头块：先读字节序魔数再确定长度
+			var bom [4]byte
+			if _, err := io.ReadFull(r, bom[:]); err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			switch binary.LittleEndian.Uint32(bom[:]) {
+			case 0x1a2b3c4d:
+				order = binary.LittleEndian
+			case 0x4d3c2b1a:
+				order = binary.BigEndian
+			default:
+				return fmt.Errorf("invalid pcapng byte-order magic")
+			}
+			length := order.Uint32(hdr[4:8])
+			if length < 16 || length > maxPcapRecord {
+				return fmt.Errorf("invalid pcapng block length: %d", length)
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length-12)); err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			linkTypes = linkTypes[:0]
+			continue
+		}
+
+		length := order.Uint32(hdr[4:8])
+		if length < 12 || length > maxPcapRecord {
+			return fmt.Errorf("invalid pcapng block length: %d", length)
+		}
+		body = grow(body, int(length-8))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		body = body[:len(body)-4] // 去掉尾部长度
+
+		switch blockType {
+		case 1: // 接口描述块
+			if len(body) >= 2 {
+				linkTypes = append(linkTypes, int(order.Uint16(body[0:2])))
+				if len(linkTypes) == 1 {
+					p.LinkType = linkTypes[0]
+				}
+			}
+		case 6: // 增强分组块
+			if len(body) < 20 {
+				continue
+			}
+			iface := order.Uint32(body[0:4])
+			ts := uint64(order.Uint32(body[4:8]))<<32 | uint64(order.Uint32(body[8:12]))
+			capLen, orig := order.Uint32(body[12:16]), order.Uint32(body[16:20])
+			data := body[20:]
+			if int(capLen) < len(data) {
+				data = data[:capLen]
+			}
+			link := p.LinkType
+			if int(iface) < len(linkTypes) {
+				link = linkTypes[iface]
+			}
+			p.packetLink(link, time.UnixMicro(int64(ts)).UTC(), int64(orig), data)
+		case 3: // 简单分组块（无时间戳）
+			if len(body) < 4 {
+				continue
+			}
+			p.packetLink(p.LinkType, time.Time{}, int64(order.Uint32(body[0:4])), body[4:])
+		}
+	}
+	p.Truncated = true
+	return nil
+}
+
+// packetLink 按链路类型统计一个分组
+func (p *PcapInfo) packetLink(link int, ts time.Time, size int64, data []byte) {
+	p.Packets++
+	p.Bytes += size
+	if !ts.IsZero() {
+		if p.Start.IsZero() || ts.Before(p.Start) {
+			p.Start = ts
+		}
+		if ts.After(p.End) {
+			p.End = ts
+		}
+	}
+
+	var etherType uint16
+	switch link {
+	case linkEthernet:
+		if len(data) < 14 {
+			return
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		for etherType == 0x8100 && len(data) >= 4 { // 802.1Q
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case linkRaw, linkRawAlt, linkNull:
+		if link == linkNull {
+			if len(data) < 4 {
+				return
+			}
+			data = data[4:]
+		}
+		if len(data) == 0 {
+			return
+		}
+		switch data[0] >> 4 {
+		case 4:
+			etherType = 0x0800
+		case 6:
+			etherType = 0x86dd
+		}
+	default:
+		p.Protocols["other"]++
+		return
+	}
+
+	switch etherType {
+	case 0x0800:
+		p.ipv4(data)
+	case 0x86dd:
+		p.ipv6(data)
+	case 0x0806:
+		p.Protocols["ARP"]++
+	default:
+		p.Protocols["other"]++
+	}
+}
+
+// ipv4 统计 IPv4 分组
+func (p *PcapInfo) ipv4(data []byte) {
+	if len(data) < 20 {
+		return
+	}
+	ihl := int(data[0]&0x0f) * 4
+	if ihl < 20 || len(data) < ihl {
+		return
+	}
+	p.hosts[net.IP(data[12:16]).String()] = struct{}{}
+	p.hosts[net.IP(data[16:20]).String()] = struct{}{}
+	p.transport(data[9], data[ihl:])
+}
+
+// ipv6 统计 IPv6 分组（不展开扩展头）
+func (p *PcapInfo) ipv6(data []byte) {
+	if len(data) < 40 {
+		return
+	}
+	p.hosts[net.IP(data[8:24]).String()] = struct{}{}
+	p.hosts[net.IP(data[24:40]).String()] = struct{}{}
+	p.transport(data[6], data[40:])
+}
+
+// transport 统计传输层协议与端口
+func (p *PcapInfo) transport(proto byte, data []byte) {
+	var name string
+	switch proto {
+	case 6:
+		name = "tcp"
+	case 17:
+		name = "udp"
+	case 1, 58:
+		p.Protocols["ICMP"]++
+		return
+	default:
+		p.Protocols["other"]++
+		return
+	}
+	p.Protocols[strings.ToUpper(name)]++
+	if len(data) < 4 {
+		return
+	}
+	// 以较小的端口号作为服务端口（客户端通常使用临时高端口）
+	port := binary.BigEndian.Uint16(data[0:2])
+	if dst := binary.BigEndian.Uint16(data[2:4]); dst < port {
+		port = dst
+	}
+	key := fmt.Sprintf("%d/%s", port, name)
+	pc := p.ports[key]
+	if pc == nil {
+		pc = &PortCount{Port: port, Proto: name, Service: wellKnownPorts[port]}
+		p.ports[key] = pc
+	}
+	pc.Packets++
+}
+
+// grow 调整缓冲区长度
+func grow(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
@@ -0,0 +1,242 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 预览类型
+const (
+	PreviewImage = "image"
+	PreviewText  = "text"
+	PreviewHex   = "hex"
+)
+
+// 预览默认限制：预览随文件消息广播，需保持小体积
+const (
+	DefaultPreviewLines   = 40
+	DefaultPreviewLineLen = 200
+	DefaultHexBytes       = 256
+	DefaultThumbnailSize  = 128
+	DefaultMaxImageBytes  = 16 << 20
+	DefaultMaxImagePixels = 40_000_000
+	MaxThumbnailBytes     = 100 << 10
+	previewReadBytes      = 64 << 10
+)
+
+// PreviewOptions 预览选项
+type PreviewOptions struct {
+	MaxLines       int   // 文本预览行数
+	MaxLineLen     int   // 单行最大长度（超出截断）
+	HexBytes       int   // 十六进制预览字节数
+	ThumbnailSize  int   // 缩略图最长边（像素）
+	MaxImageBytes  int64 // 超过此大小的图片不生成缩略图
+	MaxImagePixels int   // 超过此像素数的图片不解码
+}
+
+// FilePreview 文件预览
+type FilePreview struct {
+	Kind      string `json:"kind"`               // image/text/hex
+	Text      string `json:"text,omitempty"`     // 文本前若干行，或十六进制转储
+	Language  string `json:"language,omitempty"` // 文本的语言（按扩展名与内容推断）
+	Lines     int    `json:"lines,omitempty"`    // 预览中的行数
+	Truncated bool   `json:"truncated,omitempty"`
+	Thumbnail []byte `json:"-"`               // PNG 缩略图
+	Width     int    `json:"width,omitempty"` // 原图尺寸
+	Height    int    `json:"height,omitempty"`
+}
+
+// GeneratePreview 生成文件预览：图片生成缩略图，文本取前若干行，其余为十六进制转储
+// 图片解码失败（或过大）时退回十六进制转储
+func GeneratePreview(r io.ReaderAt, size int64, filename string, opts PreviewOptions) (*FilePreview, error) {
+	opts = opts.withDefaults()
+
+	head := make([]byte, min(size, previewReadBytes))
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	head = head[:n]
+
+	switch kind := DetectType(head).Name; {
+	case kind == TypePNG || kind == TypeJPEG || kind == TypeGIF:
+		if size <= opts.MaxImageBytes {
+			if p, err := thumbnailPreview(io.NewSectionReader(r, 0, size), opts); err == nil {
+				return p, nil
+			}
+		}
+	case kind == TypeText || (kind == TypeData && isSourceFile(filename) && looksLikeText(head)):
+		return textPreview(head, size > int64(n), filename, opts), nil
+	}
+	return hexPreview(head, size, opts), nil
+}
+
+// textPreview 截取文本前若干行
+func textPreview(data []byte, more bool, filename string, opts PreviewOptions) *FilePreview {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	p := &FilePreview{Kind: PreviewText, Language: DetectLanguage(filename, data)}
+
+	var b strings.Builder
+	for len(data) > 0 && p.Lines < opts.MaxLines {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+			if more {
+				// 读取窗口末尾的不完整行不展示
+				break
+			}
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > opts.MaxLineLen {
+			line = line[:opts.MaxLineLen]
+			for len(line) > 0 && !utf8.Valid(line) {
+				line = line[:len(line)-1]
+			}
+			line = append(line[:len(line):len(line)], "…"...)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+		p.Lines++
+	}
+	p.Text = strings.TrimRight(b.String(), "\n")
+	p.Truncated = len(data) > 0 || more
+	return p
+}
+
+// hexPreview 生成 xxd 风格的十六进制转储
+func hexPreview(data []byte, size int64, opts PreviewOptions) *FilePreview {
+	if len(data) > opts.HexBytes {
+		data = data[:opts.HexBytes]
+	}
+	var b strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:min(off+16, len(data))]
+		fmt.Fprintf(&b, "%08x: ", off)
+		for i := 0; i < 16; i++ {
+			if i < len(row) {
+				fmt.Fprintf(&b, "%02x", row[i])
+			} else {
+				b.WriteString("  ")
+			}
+			if i%2 == 1 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte(' ')
+		for _, c := range row {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return &FilePreview{
+		Kind:      PreviewHex,
+		Text:      strings.TrimRight(b.String(), "\n"),
+		Lines:     (len(data) + 15) / 16,
+		Truncated: size > int64(len(data)),
+	}
+}
+
+// withDefaults 填充未设置的选项
+func (o PreviewOptions) withDefaults() PreviewOptions {
+	if o.MaxLines <= 0 {
+		o.MaxLines = DefaultPreviewLines
+	}
+	if o.MaxLineLen <= 0 {
+		o.MaxLineLen = DefaultPreviewLineLen
+	}
+	if o.HexBytes <= 0 {
+		o.HexBytes = DefaultHexBytes
+	}
+	if o.ThumbnailSize <= 0 {
+		o.ThumbnailSize = DefaultThumbnailSize
+	}
+	if o.MaxImageBytes <= 0 {
+		o.MaxImageBytes = DefaultMaxImageBytes
+	}
+	if o.MaxImagePixels <= 0 {
+		o.MaxImagePixels = DefaultMaxImagePixels
+	}
+	return o
+}
+
+// languageByExt 扩展名到语言的映射
+var languageByExt = map[string]string{
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp",
+	".cs": "csharp", ".go": "go", ".rs": "rust", ".java": "java", ".kt": "kotlin",
+	".swift": "swift", ".py": "python", ".pyw": "python", ".rb": "ruby", ".php": "php",
+	".pl": "perl", ".lua": "lua", ".js": "javascript", ".mjs": "javascript", ".ts": "typescript",
+	".jsx": "javascript", ".tsx": "typescript", ".sh": "bash", ".bash": "bash", ".zsh": "bash",
+	".ps1": "powershell", ".bat": "batch", ".cmd": "batch", ".asm": "asm", ".s": "asm",
+	".sol": "solidity", ".sql": "sql", ".html": "html", ".htm": "html", ".css": "css",
+	".xml": "xml", ".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".ini": "ini", ".md": "markdown", ".sage": "python", ".v": "verilog", ".vhd": "vhdl",
+	".hs": "haskell", ".ml": "ocaml", ".ex": "elixir", ".erl": "erlang", ".r": "r",
+	".dockerfile": "dockerfile", ".txt": "plaintext", ".log": "plaintext", ".csv": "csv",
+}
+
+// languageByInterpreter shebang 解释器到语言的映射
+var languageByInterpreter = map[string]string{
+	"python": "python", "python2": "python", "python3": "python", "sh": "bash", "bash": "bash",
+	"zsh": "bash", "perl": "perl", "ruby": "ruby", "node": "javascript", "php": "php", "lua": "lua",
+}
+
+// isSourceFile 判断扩展名是否为已知的文本/源码类型
+func isSourceFile(filename string) bool {
+	_, ok := languageByExt[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// DetectLanguage 推断文本语言：优先扩展名，其次 shebang 与特征片段
+func DetectLanguage(filename string, head []byte) string {
+	base := strings.ToLower(filepath.Base(filename))
+	if base == "dockerfile" || base == "makefile" {
+		return base
+	}
+	if lang, ok := languageByExt[strings.ToLower(filepath.Ext(base))]; ok && lang != "plaintext" {
+		return lang
+	}
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		line := head[2:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) > 0 {
+			interp := filepath.Base(fields[0])
+			if interp == "env" && len(fields) > 1 {
+				interp = fields[1]
+			}
+			if lang, ok := languageByInterpreter[interp]; ok {
+				return lang
+			}
+		}
+	}
+
+	trimmed := bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<?php")):
+		return "php"
+	case bytes.HasPrefix(trimmed, []byte("<!DOCTYPE html")) || bytes.HasPrefix(trimmed, []byte("<html")):
+		return "html"
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return "xml"
+	case bytes.Contains(head, []byte("#include <")):
+		return "c"
+	case bytes.Contains(head, []byte("package main")):
+		return "go"
+	case bytes.Contains(head, []byte("def ")) && bytes.Contains(head, []byte("import ")):
+		return "python"
+	}
+	return "plaintext"
+}
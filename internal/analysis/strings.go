@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// defaultFlagPrefix 未设置 Flag 格式时匹配的常见前缀
+const defaultFlagPrefix = `(?i:flag|ctf)`
+
+// flagBody Flag 花括号内容：不含空白与花括号，长度受限避免误匹配大段数据
+const flagBody = `\{[^{}\s]{1,256}\}`
+
+// FlagPattern 根据题目的 Flag 格式构造匹配表达式
+// 格式取花括号前的前缀（如 "picoCTF{...}" → picoCTF），其余部分视为占位说明
+func FlagPattern(format string) *regexp.Regexp {
+	prefix := defaultFlagPrefix
+	if i := strings.Index(format, "{"); i > 0 {
+		prefix = regexp.QuoteMeta(strings.TrimSpace(format[:i]))
+	}
+	return regexp.MustCompile(prefix + flagBody)
+}
+
+// StringsResult 字符串提取结果
+type StringsResult struct {
+	Strings []string // 前 limit 条可打印字符串
+	Count   int      // 字符串总数
+	Flags   []string // 与 Flag 格式匹配的候选（去重）
+}
+
+// ExtractStrings 提取可打印 ASCII 字符串（等价于 strings -n minLen），同时匹配 Flag
+func ExtractStrings(r io.Reader, minLen, limit int, flag *regexp.Regexp) (*StringsResult, error) {
+	result := &StringsResult{}
+	br := bufio.NewReaderSize(r, 64<<10)
+	var cur []byte
+
+	flush := func() {
+		if len(cur) >= minLen {
+			s := string(cur)
+			result.Count++
+			if len(result.Strings) < limit {
+				result.Strings = append(result.Strings, s)
+			}
+			if flag != nil {
+				result.Flags = appendUnique(result.Flags, flag.FindAllString(s, -1)...)
+			}
+		}
+		cur = cur[:0]
+	}
+
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			flush()
+			return result, nil
+		}
+		if err != nil {
+			flush()
+			return result, err
+		}
+		if (c >= 0x20 && c < 0x7f) || c == '\t' {
+			// 超长字符串截断处理，避免单行数据撑大内存
+			if len(cur) < 4096 {
+				cur = append(cur, c)
+			}
+			continue
+		}
+		flush()
+	}
+}
@@ -0,0 +1,8 @@
+#include <stdio.h>
+#include <string.h>
+int main(int argc, char **argv) {
+	char buf[32];
+	strcpy(buf, argc > 1 ? argv[1] : "flag{elf_fixture}");
+	printf("%s\n", buf);
+	return 0;
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"crosswire/internal/client"
@@ -19,7 +20,10 @@ import (
 // App Wails 应用主类
 type App struct {
 	ctx context.Context
-	db  *storage.Database
+	db  *storage.Database // 本地数据库（静态加密未解锁时为 nil）
+
+	// 数据库配置（解锁或启用静态加密时以口令重新打开，不保存口令）
+	storageConfig storage.Config
 
 	// 核心组件
 	mode     Mode             // 运行模式（服务端/客户端）
@@ -32,12 +36,16 @@ type App struct {
 	mu        sync.RWMutex // 读写锁
 	isRunning bool         // 是否运行中
 
+	// 运行中的服务端/客户端提供的频道数据库视图（原子读取，避免在持有 mu 时再次加锁）
+	channelSrc atomic.Pointer[channelSource]
+
 	// 用户配置
 	userProfile *UserProfile // 用户配置
 }
 
 // NewApp 创建应用实例
-func NewApp(db *storage.Database) *App {
+// db 为 nil 表示数据目录已启用静态加密且尚未解锁，前端需先调用 UnlockStorage
+func NewApp(db *storage.Database, storageConfig storage.Config) *App {
 	// 初始化事件总线（使用默认配置）
 	eventBus := events.NewEventBus(nil)
 
@@ -55,13 +63,16 @@ func NewApp(db *storage.Database) *App {
 	// 加载用户配置
 	userProfile := loadUserProfile(db)
 
+	storageConfig.Passphrase = ""
+
 	return &App{
-		db:          db,
-		mode:        ModeIdle,
-		eventBus:    eventBus,
-		logger:      logger,
-		userProfile: userProfile,
-		isRunning:   false,
+		db:            db,
+		storageConfig: storageConfig,
+		mode:          ModeIdle,
+		eventBus:      eventBus,
+		logger:        logger,
+		userProfile:   userProfile,
+		isRunning:     false,
 	}
 }
 
@@ -132,6 +143,7 @@ func (a *App) DomReady(ctx context.Context) {
 		"status":  "initialized",
 		"mode":    string(a.mode),
 		"profile": a.userProfile,
+		"locked":  a.db == nil,
 	})
 }
 
@@ -156,6 +168,7 @@ func (a *App) Shutdown(ctx context.Context) {
 		}
 		a.client = nil
 	}
+	a.channelSrc.Store(nil)
 
 	// 保存用户配置（如果存在）
 	if a.userProfile != nil && a.db != nil && a.db.GetUserDB() != nil {
@@ -181,6 +194,14 @@ func (a *App) Shutdown(ctx context.Context) {
 	a.mode = ModeIdle
 	a.isRunning = false
 
+	// 关闭数据库
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			a.logger.Error("Failed to close database: %v", err)
+		}
+		a.db = nil
+	}
+
 	// 关闭日志文件
 	_ = a.logger.Close()
 
@@ -208,6 +229,22 @@ func (a *App) IsRunning() bool {
 	return a.isRunning
 }
 
+// channelSource 运行中的服务端/客户端的数据库视图来源
+type channelSource struct {
+	db func() *storage.Database
+}
+
+// channelDB 返回当前频道的数据库视图
+// 运行中时为服务端/客户端绑定到其频道的视图（频道库池中可能同时打开多个频道），空闲时回退到默认库
+func (a *App) channelDB() *storage.Database {
+	if src := a.channelSrc.Load(); src != nil {
+		if db := src.db(); db != nil {
+			return db
+		}
+	}
+	return a.db
+}
+
 // ==================== 事件处理 ====================
 
 // emitEvent 向前端发送事件
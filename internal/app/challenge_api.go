@@ -362,7 +362,7 @@ func (a *App) GetChallengeSubmissions(challengeID string) Response {
 
 	// 服务端直接查询频道数据库
 	if mode == ModeServer && srv != nil {
-		subs, err := a.db.ChallengeRepo().GetSubmissions(challengeID)
+		subs, err := a.channelDB().ChallengeRepo().GetSubmissions(challengeID)
 		if err != nil {
 			return NewErrorResponse("query_error", "获取提交记录失败", err.Error())
 		}
@@ -387,7 +387,7 @@ func (a *App) GetChallengeSubmissions(challengeID string) Response {
 
 	// 客户端查询本地缓存数据库（若有同步）
 	if mode == ModeClient && cli != nil {
-		subs, err := a.db.ChallengeRepo().GetSubmissions(challengeID)
+		subs, err := a.channelDB().ChallengeRepo().GetSubmissions(challengeID)
 		if err != nil {
 			return NewErrorResponse("query_error", "获取提交记录失败", err.Error())
 		}
@@ -472,7 +472,7 @@ func (a *App) GetChallengeProgress(challengeID string, memberID string) Response
 	}
 
 	if mode == ModeClient && cli != nil {
-		pr, err := a.db.ChallengeRepo().GetProgress(challengeID, memberID)
+		pr, err := a.channelDB().ChallengeRepo().GetProgress(challengeID, memberID)
 		if err != nil || pr == nil {
 			return NewSuccessResponse(map[string]interface{}{
 				"challenge_id": challengeID,
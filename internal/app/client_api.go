@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"crosswire/internal/client"
-	"crosswire/internal/models"
 	"crosswire/internal/transport"
 )
 
@@ -20,6 +19,9 @@ func (a *App) StartClientMode(config ClientConfig) Response {
 	if a.isRunning {
 		return NewErrorResponse("already_running", "客户端已在运行", fmt.Sprintf("当前模式: %s", a.mode))
 	}
+	if a.db == nil {
+		return NewErrorResponse("storage_locked", "本地数据已加密，请先解锁", "")
+	}
 
 	// 验证配置
 	if err := a.validateClientConfig(&config); err != nil {
@@ -42,6 +44,10 @@ func (a *App) StartClientMode(config ClientConfig) Response {
 			Logger:        a.logger,
 			ServerAddress: config.ServerAddress,
 			SkipTLSVerify: true,
+			EtherType:     config.EtherType,
+			VLANID:        config.VLANID,
+			VLANPriority:  config.VLANPriority,
+			Encapsulation: config.Encapsulation,
 		},
 		SyncInterval:    5 * time.Second,
 		MaxSyncMessages: 1000,
@@ -50,6 +56,15 @@ func (a *App) StartClientMode(config ClientConfig) Response {
 		JoinTimeout:     30 * time.Second,
 		SyncTimeout:     10 * time.Second,
 		DataDir:         "./data",
+
+		HeartbeatInterval:    30 * time.Second,
+		AutoReconnect:        config.AutoReconnect,
+		ReconnectDelay:       time.Second,
+		ReconnectMaxDelay:    30 * time.Second,
+		MaxReconnectAttempts: 10,
+
+		UploadWorkers:  4,
+		PeerListenAddr: ":0",
 	}
 
 	// 创建客户端实例
@@ -66,6 +81,7 @@ func (a *App) StartClientMode(config ClientConfig) Response {
 
 	// 更新状态
 	a.client = cli
+	a.channelSrc.Store(&channelSource{db: cli.GetDatabase})
 	a.mode = ModeClient
 	a.isRunning = true
 
@@ -101,6 +117,7 @@ func (a *App) StopClientMode() Response {
 
 	// 清理状态
 	a.client = nil
+	a.channelSrc.Store(nil)
 	a.mode = ModeIdle
 	a.isRunning = false
 
@@ -129,6 +146,26 @@ func (a *App) GetClientStatus() Response {
 	return NewSuccessResponse(status)
 }
 
+// ReconnectClient 手动重连（连接进入 failed 状态后由用户触发）
+func (a *App) ReconnectClient() Response {
+	a.mu.RLock()
+	cli := a.client
+	mode := a.mode
+	a.mu.RUnlock()
+
+	if mode != ModeClient || cli == nil {
+		return NewErrorResponse("not_running", "客户端未运行", "")
+	}
+
+	if err := cli.Reconnect(); err != nil {
+		return NewErrorResponse("reconnect_error", "重连失败", err.Error())
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return NewSuccessResponse(a.getClientStatus())
+}
+
 // getClientStatus 内部方法：获取客户端状态（需持有锁）
 func (a *App) getClientStatus() *ClientStatus {
 	if a.client == nil {
@@ -138,13 +175,14 @@ func (a *App) getClientStatus() *ClientStatus {
 	channelInfo := a.client.GetChannelInfo()
 
 	return &ClientStatus{
-		Running:       true,
-		Connected:     a.client.IsConnected(),
-		ChannelID:     channelInfo.ID,
-		ChannelName:   channelInfo.Name,
-		MemberID:      a.client.GetMemberID(),
-		TransportMode: string(channelInfo.TransportMode),
-		ConnectTime:   a.client.GetConnectTime().Unix(),
+		Running:         true,
+		Connected:       a.client.IsConnected(),
+		ConnectionState: string(a.client.GetConnectionState()),
+		ChannelID:       channelInfo.ID,
+		ChannelName:     channelInfo.Name,
+		MemberID:        a.client.GetMemberID(),
+		TransportMode:   string(channelInfo.TransportMode),
+		ConnectTime:     a.client.GetConnectTime().Unix(),
 	}
 }
 
@@ -204,26 +242,6 @@ func (a *App) validateClientConfig(config *ClientConfig) error {
 		config.Nickname = "User"
 	}
 
-	// 验证传输模式特定配置
-	switch config.TransportMode {
-	case models.TransportARP:
-		if config.NetworkInterface == "" {
-			return fmt.Errorf("ARP模式需要指定网络接口")
-		}
-	case models.TransportHTTPS:
-		if config.ServerAddress == "" {
-			return fmt.Errorf("HTTPS模式需要指定服务器地址")
-		}
-		if config.Port == 0 {
-			config.Port = 8443
-		}
-	case models.TransportMDNS:
-		if config.NetworkInterface == "" {
-			return fmt.Errorf("mDNS模式需要指定网络接口")
-		}
-	default:
-		return fmt.Errorf("不支持的传输模式: %s", config.TransportMode)
-	}
-
-	return nil
+	// 验证传输模式特定配置（由传输注册表决定）
+	return validateTransportConfig(config.TransportMode, config.NetworkInterface, config.ServerAddress, &config.Port, true)
 }
@@ -1,9 +1,11 @@
 package app
 
 import (
+	"crosswire/internal/client"
 	"crosswire/internal/events"
 	"crosswire/internal/models"
 	"encoding/base64"
+	"io"
 	"mime"
 	"os"
 	"path/filepath"
@@ -38,20 +40,20 @@ func (a *App) UploadFile(req UploadFileRequest) Response {
 		return NewErrorResponse("file_error", "无法访问文件", err.Error())
 	}
 
-	// 检查是否为文件（非目录）
-	if fileInfo.IsDir() {
-		return NewErrorResponse("invalid_file", "不能上传目录", "")
-	}
-
-	a.logger.Info("Uploading file: %s (%d bytes)", req.FilePath, fileInfo.Size())
+	a.logger.Info("Uploading file: %s (%d bytes, folder=%v)", req.FilePath, fileInfo.Size(), fileInfo.IsDir())
 
-	// 上传文件
-	var fileID string
+	// 上传文件（目录打包为一个文件包）
+	var fileID, filename string
+	size := fileInfo.Size()
 	if mode == ModeClient && cli != nil {
-		task, err2 := cli.UploadFile(req.FilePath)
-		err = err2
+		var task *client.FileUploadTask
+		if fileInfo.IsDir() {
+			task, err = cli.UploadFolderToChallenge(req.FilePath, req.ChallengeID)
+		} else {
+			task, err = cli.UploadFileToChallenge(req.FilePath, req.ChallengeID)
+		}
 		if task != nil {
-			fileID = task.ID
+			fileID, filename, size = task.ID, task.Filename, task.Size
 		}
 	} else {
 		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
@@ -63,8 +65,9 @@ func (a *App) UploadFile(req UploadFileRequest) Response {
 
 	return NewSuccessResponse(map[string]interface{}{
 		"file_id":  fileID,
-		"filename": filepath.Base(req.FilePath),
-		"size":     fileInfo.Size(),
+		"filename": filename,
+		"size":     size,
+		"bundle":   fileInfo.IsDir(),
 		"message":  "文件上传已开始",
 	})
 }
@@ -117,6 +120,75 @@ func (a *App) DownloadFile(req DownloadFileRequest) Response {
 	})
 }
 
+// GetBundleEntries 获取文件包（目录上传）的条目清单，前端按路径构建目录树
+func (a *App) GetBundleEntries(fileID string) Response {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.isRunning {
+		return NewErrorResponse("not_running", "未连接到频道", "")
+	}
+
+	file, err := a.channelDB().FileRepo().GetByID(fileID)
+	if err != nil || file == nil {
+		return NewErrorResponse("not_found", "文件不存在", "")
+	}
+	raw, ok := file.Metadata["bundle"]
+	if !ok {
+		return NewErrorResponse("not_bundle", "该文件不是文件包", "")
+	}
+	manifest, err := models.ParseBundleManifest(raw)
+	if err != nil {
+		return NewErrorResponse("invalid_bundle", "文件包清单无效", err.Error())
+	}
+
+	return NewSuccessResponse(map[string]interface{}{
+		"file_id":    file.ID,
+		"root":       manifest.Root,
+		"files":      manifest.Files,
+		"total_size": manifest.TotalSize,
+		"skipped":    manifest.Skipped,
+		"entries":    manifest.Entries,
+	})
+}
+
+// DownloadBundleEntries 下载文件包并解出条目到 saveDir（entries 为空时解出全部，目录路径包含其下全部条目）
+// 下载整个 tar 请使用 DownloadFile
+func (a *App) DownloadBundleEntries(fileID, saveDir string, entries []string) Response {
+	a.mu.RLock()
+	mode := a.mode
+	cli := a.client
+	a.mu.RUnlock()
+
+	if !a.isRunning {
+		return NewErrorResponse("not_running", "未连接到频道", "")
+	}
+	if fileID == "" {
+		return NewErrorResponse("invalid_request", "文件ID不能为空", "")
+	}
+	if saveDir == "" {
+		return NewErrorResponse("invalid_request", "保存目录不能为空", "")
+	}
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		return NewErrorResponse("path_error", "无法创建保存目录", err.Error())
+	}
+	if mode != ModeClient || cli == nil {
+		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
+	}
+
+	a.logger.Info("Downloading bundle entries: %s -> %s (%d selected)", fileID, saveDir, len(entries))
+
+	if _, err := cli.DownloadBundle(fileID, saveDir, entries); err != nil {
+		return NewErrorResponse("download_error", "文件下载失败", err.Error())
+	}
+
+	return NewSuccessResponse(map[string]interface{}{
+		"file_id":  fileID,
+		"save_dir": saveDir,
+		"message":  "文件下载已开始",
+	})
+}
+
 // CancelUpload 取消文件上传
 func (a *App) CancelUpload(fileID string) Response {
 	a.mu.RLock()
@@ -196,7 +268,7 @@ func (a *App) GetFiles(limit, offset int) Response {
 	}
 
 	// 从数据库获取文件列表
-	files, err := a.db.FileRepo().GetByChannelID(channelID, limit, offset)
+	files, err := a.channelDB().FileRepo().GetByChannelID(channelID, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取文件列表失败", err.Error())
 	}
@@ -221,7 +293,7 @@ func (a *App) GetFile(fileID string) Response {
 	}
 
 	// 从数据库获取文件
-	file, err := a.db.FileRepo().GetByID(fileID)
+	file, err := a.channelDB().FileRepo().GetByID(fileID)
 	if err != nil {
 		return NewErrorResponse("not_found", "文件不存在", err.Error())
 	}
@@ -240,24 +312,20 @@ func (a *App) GetFileContent(fileID string) Response {
 		return NewErrorResponse("not_running", "未连接到频道", "")
 	}
 
-	file, err := a.db.FileRepo().GetByID(fileID)
+	file, err := a.channelDB().FileRepo().GetByID(fileID)
 	if err != nil || file == nil {
 		return NewErrorResponse("not_found", "文件不存在", "")
 	}
 
-	// 优先使用存储路径
-	var data []byte
-	if file.StorageType == models.StorageFile && file.StoragePath != "" {
-		b, err := os.ReadFile(file.StoragePath)
-		if err != nil {
-			return NewErrorResponse("read_error", "读取文件失败", err.Error())
-		}
-		data = b
-	} else if len(file.Data) > 0 {
-		data = file.Data
-	} else {
+	content, err := a.channelDB().FileRepo().OpenContent(file)
+	if err != nil {
 		return NewErrorResponse("empty", "文件内容为空", "")
 	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return NewErrorResponse("read_error", "读取文件失败", err.Error())
+	}
 
 	mt := file.MimeType
 	if mt == "" {
@@ -285,6 +353,45 @@ func (a *App) GetFileContent(fileID string) Response {
 	})
 }
 
+// GetFilePreview 获取文件预览（上传完成后生成，无需下载文件内容）
+// 图片返回缩略图 data URL；文本与二进制返回前若干行或十六进制转储
+func (a *App) GetFilePreview(fileID string) Response {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.isRunning {
+		return NewErrorResponse("not_running", "未连接到频道", "")
+	}
+
+	file, err := a.channelDB().FileRepo().GetByID(fileID)
+	if err != nil || file == nil {
+		return NewErrorResponse("not_found", "文件不存在", "")
+	}
+
+	preview, _ := file.Metadata["preview"].(map[string]interface{})
+	if preview == nil {
+		return NewErrorResponse("no_preview", "文件预览尚未生成", "")
+	}
+
+	result := map[string]interface{}{
+		"kind": preview["kind"],
+		"name": file.Filename,
+		"size": file.Size,
+	}
+	for _, key := range []string{"language", "width", "height", "lines", "truncated"} {
+		if v, ok := preview[key]; ok {
+			result[key] = v
+		}
+	}
+	if file.PreviewText != "" {
+		result["text"] = file.PreviewText
+	}
+	if len(file.Thumbnail) > 0 {
+		result["thumbnailUrl"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(file.Thumbnail)
+	}
+	return NewSuccessResponse(result)
+}
+
 // GetFileProgress 获取文件传输进度
 func (a *App) GetFileProgress(fileID string) Response {
 	a.mu.RLock()
@@ -322,7 +429,7 @@ func (a *App) GetFileProgress(fileID string) Response {
 					Transferred: int64(downloadTask.ReceivedChunks) * int64(downloadTask.ChunkSize),
 					Progress:    int(downloadTask.GetProgress() * 100),
 					Speed:       0,
-					Status:      string(downloadTask.Status),
+					Status:      string(downloadTask.GetStatus()),
 				}
 			}
 		}
@@ -392,7 +499,7 @@ func (a *App) DeleteFile(fileID string) Response {
 	a.logger.Info("Deleting file: %s", fileID)
 
 	// 1. 读取文件
-	file, err := a.db.FileRepo().GetByID(fileID)
+	file, err := a.channelDB().FileRepo().GetByID(fileID)
 	if err != nil || file == nil {
 		return NewErrorResponse("not_found", "文件不存在", err.Error())
 	}
@@ -406,7 +513,7 @@ func (a *App) DeleteFile(fileID string) Response {
 		isAdmin = true
 	} else if mode == ModeClient && cli != nil {
 		currentUserID = cli.GetMemberID()
-		member, _ := a.db.MemberRepo().GetByID(currentUserID)
+		member, _ := a.channelDB().MemberRepo().GetByID(currentUserID)
 		isAdmin = (member != nil && member.Role == models.RoleAdmin)
 	} else {
 		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
@@ -416,24 +523,39 @@ func (a *App) DeleteFile(fileID string) Response {
 		return NewErrorResponse("permission_denied", "仅上传者或管理员可删除文件", "")
 	}
 
-	// 3. 处理物理文件
-	switch file.StorageType {
-	case models.StorageFile:
-		if file.StoragePath != "" {
-			if err := os.Remove(file.StoragePath); err != nil {
-				a.logger.Warn("Failed to delete physical file: %v", err)
-			}
+	// 3. 服务端：删除内容与记录、写入审计日志并通知成员
+	if mode == ModeServer {
+		if err := srv.DeleteFile(fileID, "deleted_by_admin", "system"); err != nil {
+			return NewErrorResponse("delete_error", "删除文件失败", err.Error())
 		}
-	default:
-		// 其他存储类型由数据库删除处理
+		a.logger.Info("File deleted: %s", fileID)
+		return NewSuccessResponse(map[string]interface{}{
+			"message": "文件已删除",
+			"file_id": fileID,
+		})
 	}
 
-	// 4. 删除数据库记录（级联删除分块）
-	if err := a.db.FileRepo().Delete(fileID); err != nil {
+	// 4. 客户端：处理物理文件（内容存储中的 blob 仅在无其他引用时删除）
+	if err := a.channelDB().FileRepo().ReleaseContent(file); err != nil {
+		a.logger.Warn("Failed to delete physical file: %v", err)
+	}
+
+	// 5. 删除数据库记录（级联删除分块）
+	if err := a.channelDB().FileRepo().Delete(fileID); err != nil {
 		return NewErrorResponse("delete_error", "删除文件记录失败", err.Error())
 	}
+	if err := a.channelDB().SaveAuditLog(&models.AuditLog{
+		ChannelID:  file.ChannelID,
+		Type:       "file_deleted",
+		OperatorID: currentUserID,
+		TargetID:   fileID,
+		Reason:     "deleted_locally",
+		Details:    models.JSONField{"filename": file.Filename, "size": file.Size, "sha256": file.SHA256},
+	}); err != nil {
+		a.logger.Warn("Failed to write audit log: %v", err)
+	}
 
-	// 5. 广播文件删除事件
+	// 6. 广播文件删除事件
 	a.eventBus.Publish(events.EventFileDeleted, map[string]interface{}{
 		"file_id":  fileID,
 		"filename": file.Filename,
@@ -453,6 +575,7 @@ func (a *App) DeleteFile(fileID string) Response {
 func (a *App) fileToDTO(file *models.File) *FileDTO {
 	// 获取上传者信息
 	uploaderName := "Unknown"
+	_, isBundle := file.Metadata["bundle"]
 
 	return &FileDTO{
 		ID:           file.ID,
@@ -464,6 +587,7 @@ func (a *App) fileToDTO(file *models.File) *FileDTO {
 		UploadStatus: file.UploadStatus,
 		Progress:     int(float64(file.UploadedChunks) / float64(max(1, file.TotalChunks)) * 100),
 		UploadTime:   file.UploadedAt.Unix(),
+		IsBundle:     isBundle,
 	}
 }
 
@@ -35,7 +35,7 @@ func (a *App) GetMembers() Response {
 	}
 
 	// 🔧 批量获取所有成员的贡献统计（性能优化：一次查询）
-	contributionStatsMap, err := a.db.ChallengeRepo().GetAllMembersContributionStats()
+	contributionStatsMap, err := a.channelDB().ChallengeRepo().GetAllMembersContributionStats()
 	if err != nil {
 		a.logger.Warn("[GetMembers] Failed to get contribution stats: %v", err)
 		contributionStatsMap = make(map[string]int)
@@ -64,7 +64,7 @@ func (a *App) GetMember(memberID string) Response {
 	a.logger.Debug("[GetMember] Fetching member info for ID: %s", memberID)
 
 	// 从数据库获取成员
-	member, err := a.db.MemberRepo().GetByID(memberID)
+	member, err := a.channelDB().MemberRepo().GetByID(memberID)
 	if err != nil {
 		a.logger.Error("[GetMember] Failed to get member %s: %v", memberID, err)
 		return NewErrorResponse("not_found", "成员不存在", err.Error())
@@ -105,7 +105,7 @@ func (a *App) GetMyInfo() Response {
 
 	// 从数据库获取成员信息
 	a.logger.Debug("[GetMyInfo] Fetching member info for ID: %s", memberID)
-	member, err := a.db.MemberRepo().GetByID(memberID)
+	member, err := a.channelDB().MemberRepo().GetByID(memberID)
 	if err != nil {
 		// 容错：若本地尚未持久化，则根据当前运行上下文补充一条最小成员记录
 		a.logger.Warn("[GetMyInfo] Member not found in DB, attempting to create fallback record: %s", memberID)
@@ -134,7 +134,7 @@ func (a *App) GetMyInfo() Response {
 			JoinedAt:   time.Now(),
 			LastSeenAt: time.Now(),
 		}
-		if e2 := a.db.MemberRepo().Create(member); e2 != nil {
+		if e2 := a.channelDB().MemberRepo().Create(member); e2 != nil {
 			a.logger.Error("[GetMyInfo] Fallback create member failed: %v", e2)
 			return NewErrorResponse("not_found", "获取用户信息失败", err.Error())
 		}
@@ -366,7 +366,7 @@ func (a *App) UpdateMemberRole(memberID string, role models.MemberRole) Response
 // memberToDTO 转换成员模型为DTO（单个查询，用于GetMember等单个成员查询）
 func (a *App) memberToDTO(member *models.Member) *MemberDTO {
 	// 获取该成员的参与题目数
-	assignedCount, err := a.db.ChallengeRepo().CountAssignedToMember(member.ID)
+	assignedCount, err := a.channelDB().ChallengeRepo().CountAssignedToMember(member.ID)
 	if err != nil {
 		a.logger.Warn("[memberToDTO] Failed to count assigned challenges for %s: %v", member.ID, err)
 		assignedCount = 0
@@ -136,7 +136,7 @@ func (a *App) GetMessages(limit, offset int) Response {
 	}
 
 	// 从数据库获取消息
-	messages, err := a.db.MessageRepo().GetByChannelID(channelID, limit, offset)
+	messages, err := a.channelDB().MessageRepo().GetByChannelID(channelID, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取消息失败", err.Error())
 	}
@@ -164,7 +164,7 @@ func (a *App) GetMessagesByChannel(channelID string, limit, offset int) Response
 		return NewErrorResponse("invalid_request", "channel_id 不能为空", "")
 	}
 
-	messages, err := a.db.MessageRepo().GetByChannelID(channelID, limit, offset)
+	messages, err := a.channelDB().MessageRepo().GetByChannelID(channelID, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取消息失败", err.Error())
 	}
@@ -188,7 +188,7 @@ func (a *App) GetMessage(messageID string) Response {
 	}
 
 	// 从数据库获取消息
-	msg, err := a.db.MessageRepo().GetByID(messageID)
+	msg, err := a.channelDB().MessageRepo().GetByID(messageID)
 	if err != nil {
 		return NewErrorResponse("not_found", "消息不存在", err.Error())
 	}
@@ -218,7 +218,7 @@ func (a *App) SearchMessages(req SearchMessagesRequest) Response {
 	}
 
 	// 搜索消息
-	messages, err := a.db.MessageRepo().Search(channelID, req.Query, req.Limit, req.Offset)
+	messages, err := a.channelDB().MessageRepo().Search(channelID, req.Query, req.Limit, req.Offset)
 	if err != nil {
 		return NewErrorResponse("search_error", "搜索失败", err.Error())
 	}
@@ -266,7 +266,7 @@ func (a *App) GetMessagesByTimeRange(startSec, endSec int64, limit, offset int)
 		end = time.Unix(endSec, 0)
 	}
 
-	list, err := a.db.MessageRepo().GetMessagesByTimeRange(channelID, start, end, limit, offset)
+	list, err := a.channelDB().MessageRepo().GetMessagesByTimeRange(channelID, start, end, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取消息失败", err.Error())
 	}
@@ -305,7 +305,7 @@ func (a *App) GetMessagesByTag(tag string, limit, offset int) Response {
 		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
 	}
 
-	list, err := a.db.MessageRepo().GetMessagesByTag(channelID, tag, limit, offset)
+	list, err := a.channelDB().MessageRepo().GetMessagesByTag(channelID, tag, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取消息失败", err.Error())
 	}
@@ -346,7 +346,7 @@ func (a *App) GetMentionedMessages(limit, offset int) Response {
 		return NewErrorResponse("invalid_state", "缺少当前用户ID", "")
 	}
 
-	list, err := a.db.MessageRepo().GetMentionedMessages(channelID, myID, limit, offset)
+	list, err := a.channelDB().MessageRepo().GetMentionedMessages(channelID, myID, limit, offset)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取消息失败", err.Error())
 	}
@@ -387,7 +387,7 @@ func (a *App) GetMessageStats(fromSec, toSec int64) Response {
 	if toSec > 0 {
 		to = time.Unix(toSec, 0)
 	}
-	m, err := a.db.MessageRepo().GetMessageStats(channelID, from, to)
+	m, err := a.channelDB().MessageRepo().GetMessageStats(channelID, from, to)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取统计失败", err.Error())
 	}
@@ -422,7 +422,7 @@ func (a *App) DeleteMessage(messageID string) Response {
 	}
 
 	// 使用仓库执行软删除
-	if err := a.db.MessageRepo().Delete(messageID, "server"); err != nil {
+	if err := a.channelDB().MessageRepo().Delete(messageID, "server"); err != nil {
 		return NewErrorResponse("delete_error", "删除消息失败", err.Error())
 	}
 
@@ -449,7 +449,7 @@ func (a *App) PinMessage(req PinMessageRequest) Response {
 	if ch == nil {
 		return NewErrorResponse("no_channel", "未初始化频道", "")
 	}
-	if err := a.db.ChannelRepo().PinMessage(ch.ID, req.MessageID, "server", req.Reason); err != nil {
+	if err := a.channelDB().ChannelRepo().PinMessage(ch.ID, req.MessageID, "server", req.Reason); err != nil {
 		return NewErrorResponse("pin_error", "置顶消息失败", err.Error())
 	}
 
@@ -471,7 +471,7 @@ func (a *App) UnpinMessage(messageID string) Response {
 	}
 
 	ch, _ := a.server.GetChannel()
-	if err := a.db.ChannelRepo().UnpinMessage(ch.ID, messageID); err != nil {
+	if err := a.channelDB().ChannelRepo().UnpinMessage(ch.ID, messageID); err != nil {
 		return NewErrorResponse("unpin_error", "取消置顶失败", err.Error())
 	}
 
@@ -507,7 +507,7 @@ func (a *App) GetPinnedMessages() Response {
 	}
 
 	// 获取带内容的置顶消息
-	rows, err := a.db.ChannelRepo().GetPinnedMessagesWithContent(channelID)
+	rows, err := a.channelDB().ChannelRepo().GetPinnedMessagesWithContent(channelID)
 	if err != nil {
 		return NewErrorResponse("query_error", "查询置顶消息失败", err.Error())
 	}
@@ -566,7 +566,7 @@ func (a *App) SetTypingStatus() Response {
 		return NewErrorResponse("invalid_request", "缺少必要的身份信息", "")
 	}
 
-	if err := a.db.MessageRepo().SetTypingStatus(channelID, userID); err != nil {
+	if err := a.channelDB().MessageRepo().SetTypingStatus(channelID, userID); err != nil {
 		return NewErrorResponse("db_error", "设置输入状态失败", err.Error())
 	}
 
@@ -600,7 +600,7 @@ func (a *App) GetTypingUsers() Response {
 		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
 	}
 
-	list, err := a.db.MessageRepo().GetTypingUsers(channelID)
+	list, err := a.channelDB().MessageRepo().GetTypingUsers(channelID)
 	if err != nil {
 		return NewErrorResponse("db_error", "获取输入用户失败", err.Error())
 	}
@@ -670,7 +670,7 @@ func (a *App) messageToDTO(msg *models.Message) *MessageDTO {
 	// 获取发送者信息
 	senderName := "Unknown"
 	if msg.SenderID != "" {
-		member, err := a.db.MemberRepo().GetByID(msg.SenderID)
+		member, err := a.channelDB().MemberRepo().GetByID(msg.SenderID)
 		if err == nil && member != nil {
 			senderName = member.Nickname
 		}
@@ -679,7 +679,7 @@ func (a *App) messageToDTO(msg *models.Message) *MessageDTO {
 	// 加载并聚合 reactions
 	reactions := make([]MessageReaction, 0)
 	if a.db != nil {
-		dbReactions, err := a.db.MessageRepo().GetReactions(msg.ID)
+		dbReactions, err := a.channelDB().MessageRepo().GetReactions(msg.ID)
 		if err == nil && len(dbReactions) > 0 {
 			agg := make(map[string]*MessageReaction)
 			for _, r := range dbReactions {
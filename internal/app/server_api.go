@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"time"
 
 	"crosswire/internal/models"
 	"crosswire/internal/server"
@@ -19,6 +20,9 @@ func (a *App) StartServerMode(config ServerConfig) Response {
 	if a.isRunning {
 		return NewErrorResponse("already_running", "服务已在运行", fmt.Sprintf("当前模式: %s", a.mode))
 	}
+	if a.db == nil {
+		return NewErrorResponse("storage_locked", "本地数据已加密，请先解锁", "")
+	}
 
 	// 验证配置
 	if err := a.validateServerConfig(&config); err != nil {
@@ -34,10 +38,25 @@ func (a *App) StartServerMode(config ServerConfig) Response {
 		ChannelName:     config.ChannelName,
 		MaxMembers:      config.MaxMembers,
 		TransportMode:   config.TransportMode,
+
+		MaxFileSize:       config.MaxFileSize,
+		MaxMemberStorage:  config.MemberQuota,
+		MaxChannelStorage: config.ChannelQuota,
+		FileRetention:     retentionPolicy(config),
+
 		TransportConfig: &transport.Config{
-			Mode:      config.TransportMode,
-			Interface: config.NetworkInterface,
-			Port:      config.Port,
+			Mode:       config.TransportMode,
+			Interface:  config.NetworkInterface,
+			Port:       config.Port,
+			FECEnabled: config.EnableFEC,
+
+			EtherType:     config.EtherType,
+			VLANID:        config.VLANID,
+			VLANPriority:  config.VLANPriority,
+			Encapsulation: config.Encapsulation,
+
+			MetricsPath:  config.MetricsPath,
+			MetricsToken: config.MetricsToken,
 		},
 	}
 
@@ -57,6 +76,7 @@ func (a *App) StartServerMode(config ServerConfig) Response {
 
 	// 更新状态
 	a.server = srv
+	a.channelSrc.Store(&channelSource{db: srv.GetDatabase})
 	a.mode = ModeServer
 	a.isRunning = true
 
@@ -92,6 +112,7 @@ func (a *App) StopServerMode() Response {
 
 	// 清理状态
 	a.server = nil
+	a.channelSrc.Store(nil)
 	a.mode = ModeIdle
 	a.isRunning = false
 
@@ -157,25 +178,12 @@ func (a *App) validateServerConfig(config *ServerConfig) error {
 		return fmt.Errorf("密码长度至少为6个字符")
 	}
 
-	// 验证传输模式特定配置
-	switch config.TransportMode {
-	case models.TransportARP:
-		if config.NetworkInterface == "" {
-			return fmt.Errorf("ARP模式需要指定网络接口")
-		}
-	case models.TransportHTTPS:
-		if config.ListenAddress == "" {
-			config.ListenAddress = "0.0.0.0"
-		}
-		if config.Port == 0 {
-			config.Port = 8443
-		}
-	case models.TransportMDNS:
-		if config.NetworkInterface == "" {
-			return fmt.Errorf("mDNS模式需要指定网络接口")
-		}
-	default:
-		return fmt.Errorf("不支持的传输模式: %s", config.TransportMode)
+	// 验证传输模式特定配置（由传输注册表决定）
+	if err := validateTransportConfig(config.TransportMode, config.NetworkInterface, "", &config.Port, false); err != nil {
+		return err
+	}
+	if config.Port != 0 && config.ListenAddress == "" {
+		config.ListenAddress = "0.0.0.0"
 	}
 
 	// 设置默认值
@@ -187,9 +195,26 @@ func (a *App) validateServerConfig(config *ServerConfig) error {
 		config.MaxFileSize = 100 * 1024 * 1024 // 100MB
 	}
 
+	if config.MemberQuota < 0 || config.ChannelQuota < 0 || config.RetentionDays < 0 || config.ImageRetention < 0 {
+		return fmt.Errorf("存储配额与保留天数不能为负数")
+	}
+
 	return nil
 }
 
+// retentionPolicy 按天数配置构造文件保留策略
+func retentionPolicy(config ServerConfig) server.RetentionPolicy {
+	day := 24 * time.Hour
+	policy := server.RetentionPolicy{
+		MaxAge:     time.Duration(config.RetentionDays) * day,
+		KeepPinned: config.KeepPinnedFiles,
+	}
+	if config.ImageRetention > 0 {
+		policy.ByType = map[string]time.Duration{"image/": time.Duration(config.ImageRetention) * day}
+	}
+	return policy
+}
+
 // GetSubChannels 获取所有题目子频道（服务端和客户端都可查询）
 func (a *App) GetSubChannels() Response {
 	a.mu.RLock()
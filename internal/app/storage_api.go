@@ -0,0 +1,118 @@
+package app
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"crosswire/internal/storage"
+)
+
+// ==================== 本地数据加密 API ====================
+
+// minStoragePassphraseLength 静态加密口令的最小长度（字符）
+const minStoragePassphraseLength = 8
+
+// GetStorageStatus 获取本地数据加密状态
+func (a *App) GetStorageStatus() Response {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return NewSuccessResponse(&StorageStatus{
+		Encrypted: storage.IsEncrypted(a.storageConfig.DataDir),
+		Locked:    a.db == nil,
+	})
+}
+
+// UnlockStorage 以口令解锁已加密的本地数据（启动时调用）
+func (a *App) UnlockStorage(passphrase string) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.db != nil {
+		return NewSuccessResponse(a.userProfile)
+	}
+
+	db, err := a.openStorage(passphrase)
+	if err != nil {
+		return storageErrorResponse("解锁失败", err)
+	}
+	a.db = db
+	a.userProfile = loadUserProfile(db)
+
+	a.logger.Info("Local storage unlocked")
+	return NewSuccessResponse(a.userProfile)
+}
+
+// EnableStorageEncryption 启用本地数据加密（仅空闲时）
+// 以口令重新打开数据库：生成数据密钥，加密用户库、缓存库、全部频道库中的敏感列与文件内容，并删除明文备份
+func (a *App) EnableStorageEncryption(passphrase string) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.isRunning {
+		return NewErrorResponse("already_running", "请先停止服务端或客户端", "")
+	}
+	if a.db == nil {
+		return NewErrorResponse("storage_locked", "本地数据已加密，请先解锁", "")
+	}
+	if storage.IsEncrypted(a.storageConfig.DataDir) {
+		return NewErrorResponse("already_encrypted", "本地数据已启用加密", "")
+	}
+	if utf8.RuneCountInString(passphrase) < minStoragePassphraseLength {
+		return NewErrorResponse("invalid_passphrase", "口令至少需要8个字符", "")
+	}
+
+	if err := a.db.Close(); err != nil {
+		a.logger.Warn("Failed to close database before encryption: %v", err)
+	}
+	// 重新打开失败时保持未解锁状态（密钥文件可能已写入，需以新口令解锁后继续加密）
+	a.db = nil
+
+	db, err := a.openStorage(passphrase)
+	if err != nil {
+		a.logger.Error("Failed to enable storage encryption: %v", err)
+		return storageErrorResponse("启用加密失败", err)
+	}
+	a.db = db
+	if err := db.SealChannels(); err != nil {
+		a.logger.Error("Failed to encrypt channel databases: %v", err)
+		return NewErrorResponse("storage_error", "频道数据加密失败", err.Error())
+	}
+
+	a.logger.Info("Local storage encryption enabled")
+	return NewSuccessResponse(nil)
+}
+
+// ChangeStoragePassphrase 修改本地数据加密口令（只重新包裹数据密钥，已加密的数据不变）
+func (a *App) ChangeStoragePassphrase(oldPassphrase, newPassphrase string) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if utf8.RuneCountInString(newPassphrase) < minStoragePassphraseLength {
+		return NewErrorResponse("invalid_passphrase", "口令至少需要8个字符", "")
+	}
+	if err := storage.ChangePassphrase(a.storageConfig.DataDir, oldPassphrase, newPassphrase); err != nil {
+		return storageErrorResponse("修改口令失败", err)
+	}
+
+	a.logger.Info("Local storage passphrase changed")
+	return NewSuccessResponse(nil)
+}
+
+// openStorage 以口令打开数据库（调用方持有 a.mu）
+func (a *App) openStorage(passphrase string) (*storage.Database, error) {
+	config := a.storageConfig
+	config.Passphrase = passphrase
+	return storage.NewDatabase(&config)
+}
+
+// storageErrorResponse 将存储错误映射为响应
+func storageErrorResponse(message string, err error) Response {
+	switch {
+	case errors.Is(err, storage.ErrWrongPassphrase):
+		return NewErrorResponse("wrong_passphrase", "口令错误", "")
+	case errors.Is(err, storage.ErrLocked):
+		return NewErrorResponse("storage_locked", "本地数据已加密，请先解锁", "")
+	default:
+		return NewErrorResponse("storage_error", message, err.Error())
+	}
+}
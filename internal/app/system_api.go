@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"crosswire/internal/models"
+	"crosswire/internal/transport"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -98,13 +99,13 @@ func (a *App) TestConnection(serverAddress string, mode models.TransportMode, ti
 			message = "连接成功"
 		}
 
-	case models.TransportARP, models.TransportMDNS:
-		// ARP和mDNS模式不支持直接测试
+	default:
+		// 其他已注册模式（如ARP、mDNS）不支持直接测试
+		if !transport.NewFactory().IsModeSupported(mode) {
+			return NewErrorResponse("invalid_mode", "不支持的传输模式", string(mode))
+		}
 		success = false
 		message = "该传输模式不支持连接测试"
-
-	default:
-		return NewErrorResponse("invalid_mode", "不支持的传输模式", string(mode))
 	}
 
 	latency := time.Since(startTime).Seconds() * 1000 // 转换为毫秒
@@ -153,6 +154,36 @@ func (a *App) FetchHTTPSInfo(server string, port int, skipTLSVerify bool, timeou
 	return NewSuccessResponse(data)
 }
 
+// GetTransportModes 获取支持的传输模式及其能力（由传输注册表决定）
+func (a *App) GetTransportModes() Response {
+	factory := transport.NewFactory()
+	modes := factory.GetSupportedModes()
+
+	result := make([]*TransportModeInfo, 0, len(modes))
+	for _, mode := range modes {
+		reg, ok := transport.Lookup(mode)
+		if !ok {
+			continue
+		}
+		caps := reg.Capabilities
+		result = append(result, &TransportModeInfo{
+			Mode:          string(reg.Mode),
+			DisplayName:   reg.DisplayName,
+			Description:   reg.Description,
+			Unicast:       caps.Unicast,
+			Broadcast:     caps.Broadcast,
+			FileTransfer:  caps.FileTransfer,
+			Discovery:     caps.Discovery,
+			MTU:           caps.MTU,
+			NeedInterface: caps.NeedInterface,
+			NeedAddress:   caps.NeedAddress,
+			DefaultPort:   caps.DefaultPort,
+		})
+	}
+
+	return NewSuccessResponse(result)
+}
+
 // GetNetworkStats 获取网络统计
 func (a *App) GetNetworkStats() Response {
 	a.mu.RLock()
@@ -187,9 +218,76 @@ func (a *App) GetNetworkStats() Response {
 		return NewErrorResponse("invalid_mode", "无效的运行模式", "")
 	}
 
+	// 时延与错误数取自传输层对端统计
+	var peers []transport.PeerStats
+	if mode == ModeServer {
+		for _, ps := range srv.GetPeerStats() {
+			peers = append(peers, ps.PeerStats)
+		}
+	} else {
+		peers = cli.GetPeerStats()
+	}
+	var rttSum float64
+	var rttCount int
+	for _, ps := range peers {
+		stats.ErrorCount += int64(ps.SignatureFailures + ps.ReassemblyTimeouts)
+		if ps.RTT > 0 {
+			rttSum += ps.RTT
+			rttCount++
+		}
+	}
+	if rttCount > 0 {
+		stats.AvgLatency = rttSum / float64(rttCount)
+	}
+
 	return NewSuccessResponse(stats)
 }
 
+// GetPeerNetworkStats 获取传输层按对端统计
+func (a *App) GetPeerNetworkStats() Response {
+	a.mu.RLock()
+	mode := a.mode
+	srv := a.server
+	cli := a.client
+	a.mu.RUnlock()
+
+	if !a.isRunning {
+		return NewErrorResponse("not_running", "未运行", "")
+	}
+
+	if mode == ModeServer && srv != nil {
+		return NewSuccessResponse(srv.GetPeerStats())
+	} else if mode == ModeClient && cli != nil {
+		return NewSuccessResponse(cli.GetPeerStats())
+	}
+	return NewErrorResponse("invalid_mode", "无效的运行模式", "")
+}
+
+// GetPeerNetworkHistory 获取对端统计的时间序列（since 为 Unix 秒，0 表示全部保留样本）
+func (a *App) GetPeerNetworkHistory(peer string, since int64) Response {
+	a.mu.RLock()
+	mode := a.mode
+	srv := a.server
+	cli := a.client
+	a.mu.RUnlock()
+
+	if !a.isRunning {
+		return NewErrorResponse("not_running", "未运行", "")
+	}
+
+	var sinceTime time.Time
+	if since > 0 {
+		sinceTime = time.Unix(since, 0)
+	}
+
+	if mode == ModeServer && srv != nil {
+		return NewSuccessResponse(srv.GetPeerStatsHistory(peer, sinceTime))
+	} else if mode == ModeClient && cli != nil {
+		return NewSuccessResponse(cli.GetPeerStatsHistory(peer, sinceTime))
+	}
+	return NewErrorResponse("invalid_mode", "无效的运行模式", "")
+}
+
 // ==================== 用户配置 API ====================
 
 // GetUserProfile 获取用户配置
@@ -228,7 +326,7 @@ func (a *App) UpdateUserProfile(profile UserProfile) Response {
 
 	// 从数据库获取成员信息
 	a.logger.Debug("[UpdateUserProfile] Fetching member info for ID: %s", memberID)
-	member, err := a.db.MemberRepo().GetByID(memberID)
+	member, err := a.channelDB().MemberRepo().GetByID(memberID)
 	if err != nil {
 		a.logger.Error("[UpdateUserProfile] Failed to get member info: %v", err)
 		return NewErrorResponse("not_found", "获取用户信息失败", err.Error())
@@ -278,7 +376,7 @@ func (a *App) UpdateUserProfile(profile UserProfile) Response {
 
 	// 保存到数据库
 	a.logger.Debug("[UpdateUserProfile] Saving member info to database...")
-	if err := a.db.MemberRepo().Update(member); err != nil {
+	if err := a.channelDB().MemberRepo().Update(member); err != nil {
 		a.logger.Error("[UpdateUserProfile] Failed to update member: %v", err)
 		return NewErrorResponse("update_error", "更新用户信息失败", err.Error())
 	}
@@ -303,8 +401,14 @@ func (a *App) UpdateUserProfile(profile UserProfile) Response {
 
 // GetRecentChannels 获取最近的频道
 func (a *App) GetRecentChannels() Response {
-	// 从 user.db 加载最近频道记录，按最近加入时间倒序
-	udb := a.db.GetUserDB()
+	// 从 user.db 加载最近频道记录，按最近加入时间倒序（未解锁时为空）
+	a.mu.RLock()
+	db := a.db
+	a.mu.RUnlock()
+	if db == nil {
+		return NewSuccessResponse([]*RecentChannel{})
+	}
+	udb := db.GetUserDB()
 	if udb == nil {
 		return NewSuccessResponse([]*RecentChannel{})
 	}
@@ -358,7 +462,7 @@ func (a *App) ExportData(exportPath string, options ExportOptions) Response {
 
 	// 导出消息
 	if options.IncludeMessages {
-		messages, err := a.db.MessageRepo().GetByChannelID(channelID, 0, 0)
+		messages, err := a.channelDB().MessageRepo().GetByChannelID(channelID, 0, 0)
 		if err == nil {
 			a.exportToZip(zipWriter, "messages.json", messages)
 		}
@@ -366,7 +470,7 @@ func (a *App) ExportData(exportPath string, options ExportOptions) Response {
 
 	// 导出文件列表
 	if options.IncludeFiles {
-		files, err := a.db.FileRepo().GetByChannelID(channelID, 0, 0)
+		files, err := a.channelDB().FileRepo().GetByChannelID(channelID, 0, 0)
 		if err == nil {
 			a.exportToZip(zipWriter, "files.json", files)
 		}
@@ -374,7 +478,7 @@ func (a *App) ExportData(exportPath string, options ExportOptions) Response {
 
 	// 导出成员
 	if options.IncludeMembers {
-		members, err := a.db.MemberRepo().GetByChannelID(channelID)
+		members, err := a.channelDB().MemberRepo().GetByChannelID(channelID)
 		if err == nil {
 			a.exportToZip(zipWriter, "members.json", members)
 		}
@@ -382,7 +486,7 @@ func (a *App) ExportData(exportPath string, options ExportOptions) Response {
 
 	// 导出题目
 	if options.IncludeChallenges {
-		challenges, err := a.db.ChallengeRepo().GetByChannelID(channelID)
+		challenges, err := a.channelDB().ChallengeRepo().GetByChannelID(channelID)
 		if err == nil {
 			a.exportToZip(zipWriter, "challenges.json", challenges)
 		}
@@ -443,7 +547,7 @@ func (a *App) ImportData(importPath string) Response {
 				for i := range msgs {
 					m := &msgs[i]
 					m.ChannelID = channelID
-					_ = a.db.SaveMessage(m)
+					_ = a.channelDB().SaveMessage(m)
 					count.Messages++
 				}
 			}
@@ -453,7 +557,7 @@ func (a *App) ImportData(importPath string) Response {
 				for i := range files {
 					f := &files[i]
 					f.ChannelID = channelID
-					_ = a.db.SaveFile(f)
+					_ = a.channelDB().SaveFile(f)
 					count.Files++
 				}
 			}
@@ -463,7 +567,7 @@ func (a *App) ImportData(importPath string) Response {
 				for i := range members {
 					mb := &members[i]
 					mb.ChannelID = channelID
-					_ = a.db.AddMember(mb)
+					_ = a.channelDB().AddMember(mb)
 					count.Members++
 				}
 			}
@@ -473,7 +577,7 @@ func (a *App) ImportData(importPath string) Response {
 				for i := range challenges {
 					ch := &challenges[i]
 					ch.ChannelID = channelID
-					_ = a.db.CreateChallenge(ch)
+					_ = a.channelDB().CreateChallenge(ch)
 					count.Challenges++
 				}
 			}
@@ -491,6 +595,28 @@ func (a *App) ImportData(importPath string) Response {
 
 // ==================== 辅助方法 ====================
 
+// validateTransportConfig 按传输注册表校验模式相关配置，并填充默认端口
+// needAddress 为 true 时（客户端）要求面向连接的模式提供服务器地址
+func validateTransportConfig(mode models.TransportMode, networkInterface, serverAddress string, port *int, needAddress bool) error {
+	reg, ok := transport.Lookup(mode)
+	if !ok || reg.Hidden {
+		// 与 Factory.IsModeSupported 一致：仅供测试的隐藏模式不能经 UI/API 保存
+		return fmt.Errorf("不支持的传输模式: %s", mode)
+	}
+
+	caps := reg.Capabilities
+	if caps.NeedInterface && networkInterface == "" {
+		return fmt.Errorf("%s模式需要指定网络接口", reg.DisplayName)
+	}
+	if needAddress && caps.NeedAddress && serverAddress == "" {
+		return fmt.Errorf("%s模式需要指定服务器地址", reg.DisplayName)
+	}
+	if port != nil && *port == 0 && caps.DefaultPort > 0 {
+		*port = caps.DefaultPort
+	}
+	return nil
+}
+
 // exportToZip 将数据导出到ZIP
 func (a *App) exportToZip(zipWriter *zip.Writer, filename string, data interface{}) error {
 	// 创建JSON数据
@@ -27,7 +27,19 @@ type ServerConfig struct {
 	Port             int                  `json:"port"`              // 监听端口（HTTPS模式）
 	MaxMembers       int                  `json:"max_members"`       // 最大成员数
 	MaxFileSize      int64                `json:"max_file_size"`     // 最大文件大小（字节）
+	MemberQuota      int64                `json:"member_quota"`      // 每个成员的文件总量上限（字节，0为不限）
+	ChannelQuota     int64                `json:"channel_quota"`     // 频道文件总量上限（字节，0为不限）
+	RetentionDays    int                  `json:"retention_days"`    // 文件保留天数（0为永久）
+	ImageRetention   int                  `json:"image_retention"`   // 图片保留天数（0为同 retention_days）
+	KeepPinnedFiles  bool                 `json:"keep_pinned"`       // 置顶消息中的文件不过期
 	EnableChallenge  bool                 `json:"enable_challenge"`  // 启用题目功能
+	EnableFEC        bool                 `json:"enable_fec"`        // 广播前向纠错（ARP模式）
+	EtherType        uint16               `json:"ether_type"`        // 自定义EtherType（ARP模式，0为默认0x88B5）
+	VLANID           uint16               `json:"vlan_id"`           // 802.1Q VLAN ID（ARP模式，0不打标签）
+	VLANPriority     uint8                `json:"vlan_priority"`     // 802.1Q 优先级（ARP模式）
+	Encapsulation    string               `json:"encapsulation"`     // 封装方式：ethertype/snap/arp（ARP模式）
+	MetricsPath      string               `json:"metrics_path"`      // Prometheus 指标路径（HTTPS模式，空为不启用）
+	MetricsToken     string               `json:"metrics_token"`     // 指标端点 Bearer 令牌（空为不校验）
 	Description      string               `json:"description"`       // 频道描述
 }
 
@@ -55,17 +67,22 @@ type ClientConfig struct {
 	Nickname         string               `json:"nickname"`          // 用户昵称
 	Avatar           string               `json:"avatar"`            // 用户头像URL
 	AutoReconnect    bool                 `json:"auto_reconnect"`    // 自动重连
+	EtherType        uint16               `json:"ether_type"`        // 自定义EtherType（ARP模式，须与服务端一致）
+	VLANID           uint16               `json:"vlan_id"`           // 802.1Q VLAN ID（ARP模式）
+	VLANPriority     uint8                `json:"vlan_priority"`     // 802.1Q 优先级（ARP模式）
+	Encapsulation    string               `json:"encapsulation"`     // 封装方式：ethertype/snap/arp（ARP模式）
 }
 
 // ClientStatus 客户端状态
 type ClientStatus struct {
-	Running       bool   `json:"running"`
-	Connected     bool   `json:"connected"`
-	ChannelID     string `json:"channel_id"`
-	ChannelName   string `json:"channel_name"`
-	MemberID      string `json:"member_id"`
-	TransportMode string `json:"transport_mode"`
-	ConnectTime   int64  `json:"connect_time"` // Unix timestamp
+	Running         bool   `json:"running"`
+	Connected       bool   `json:"connected"`
+	ConnectionState string `json:"connection_state"` // connecting/joined/degraded/reconnecting/failed
+	ChannelID       string `json:"channel_id"`
+	ChannelName     string `json:"channel_name"`
+	MemberID        string `json:"member_id"`
+	TransportMode   string `json:"transport_mode"`
+	ConnectTime     int64  `json:"connect_time"` // Unix timestamp
 }
 
 // ==================== 消息相关 ====================
@@ -154,12 +171,14 @@ type FileDTO struct {
 	UploadTime    int64               `json:"upload_time"` // Unix timestamp
 	ThumbnailPath *string             `json:"thumbnail_path,omitempty"`
 	LocalPath     *string             `json:"local_path,omitempty"`
+	IsBundle      bool                `json:"is_bundle,omitempty"` // 目录上传的文件包（可通过 GetBundleEntries 浏览）
 }
 
 // UploadFileRequest 上传文件请求
 type UploadFileRequest struct {
 	FilePath    string  `json:"file_path"`
 	Description *string `json:"description,omitempty"`
+	ChallengeID string  `json:"challenge_id,omitempty"` // 上传到题目聊天室（触发附件自动分析）
 }
 
 // DownloadFileRequest 下载文件请求
@@ -325,6 +344,21 @@ type NetworkInterface struct {
 	IsLoopback  bool     `json:"is_loopback"`
 }
 
+// TransportModeInfo 已注册传输模式信息
+type TransportModeInfo struct {
+	Mode          string `json:"mode"`
+	DisplayName   string `json:"display_name"`
+	Description   string `json:"description"`
+	Unicast       bool   `json:"unicast"`
+	Broadcast     bool   `json:"broadcast"`
+	FileTransfer  bool   `json:"file_transfer"`
+	Discovery     bool   `json:"discovery"`
+	MTU           int    `json:"mtu"`
+	NeedInterface bool   `json:"need_interface"`
+	NeedAddress   bool   `json:"need_address"`
+	DefaultPort   int    `json:"default_port"`
+}
+
 // NetworkStats 网络统计
 type NetworkStats struct {
 	BytesSent       int64   `json:"bytes_sent"`
@@ -373,6 +407,12 @@ type RecentChannel struct {
 	Mode        Mode   `json:"mode"`        // server or client
 }
 
+// StorageStatus 本地数据加密状态
+type StorageStatus struct {
+	Encrypted bool `json:"encrypted"` // 是否已启用静态加密
+	Locked    bool `json:"locked"`    // 是否等待口令解锁
+}
+
 // ExportOptions 导出选项
 type ExportOptions struct {
 	IncludeMessages   bool `json:"include_messages"`
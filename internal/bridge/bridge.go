@@ -0,0 +1,505 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"crosswire/internal/client"
+	"crosswire/internal/events"
+	"crosswire/internal/models"
+	"crosswire/internal/server"
+	"crosswire/internal/storage"
+	"crosswire/internal/utils"
+)
+
+// Bridge 桥接器：以客户端身份加入上游频道（传输A），与本地服务端（传输B）双向转发消息
+// 参考: docs/ARCHITECTURE.md - 3.2.4 桥接 (Bridge)
+//
+// 上游 → 本地：上游客户端的 ReceiveManager（实时广播）与 SyncManager（断线追赶）
+// 落库后发布 EventMessageReceived，桥接器将发送者映射为本地成员后交给 Server.RelayMessage，
+// 由本地 BroadcastManager 重新签名广播。
+// 本地 → 上游：本地服务端路由完成后发布 EventMessageReceived，桥接器追加本地服务器来源签名后
+// 通过上游客户端以桥接成员身份转发（原始发送者记录在消息元数据中）。
+//
+// 环路防护：消息跨桥保留原ID，桥接器记录已转发的ID；来源链中已包含目标频道的消息不再转发。
+type Bridge struct {
+	config *Config
+	logger *utils.Logger
+
+	local       *server.Server
+	localBus    *events.EventBus
+	upstream    *client.Client
+	upstreamBus *events.EventBus
+
+	localChannelID    string
+	upstreamChannelID string
+
+	// 环路防护：已处理的消息ID
+	seen      map[string]time.Time
+	seenMutex sync.Mutex
+
+	// 成员映射：上游成员ID -> 本地映射成员ID
+	identities    map[string]string
+	identityMutex sync.Mutex
+
+	// 上行发送失败的消息，定期重试
+	pending      []*models.Message
+	pendingMutex sync.Mutex
+
+	subscriptions []string
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+	isRunning     bool
+	mutex         sync.Mutex
+
+	stats Stats
+}
+
+// Config 桥接配置
+type Config struct {
+	// 上游频道的客户端配置（传输A）
+	Upstream *client.Config
+
+	// 转发的消息类型（为空时转发文本与代码消息）
+	MessageTypes []models.MessageType
+
+	// 上行失败重试间隔
+	RetryInterval time.Duration
+	// 待重发消息上限（超出时丢弃最旧的）
+	MaxPending int
+	// 已处理消息ID的保留时长
+	SeenTTL time.Duration
+
+	Logger *utils.Logger
+}
+
+// Stats 桥接统计
+type Stats struct {
+	RelayedDown   uint64 // 上游 → 本地
+	RelayedUp     uint64 // 本地 → 上游
+	DroppedLoops  uint64 // 因环路防护丢弃
+	FailedRelays  uint64 // 转发失败
+	PendingRelays int    // 待重发
+	mutex         sync.Mutex
+}
+
+// DefaultConfig 默认桥接配置
+func DefaultConfig() *Config {
+	return &Config{
+		MessageTypes:  []models.MessageType{models.MessageTypeText, models.MessageTypeCode},
+		RetryInterval: 5 * time.Second,
+		MaxPending:    1000,
+		SeenTTL:       time.Hour,
+	}
+}
+
+// New 创建桥接器
+// local 为已启动的本地服务端；db 供上游客户端使用，可与本地服务端为同一实例（各频道数据按 ForChannel 分库保存）
+func New(config *Config, local *server.Server, db *storage.Database) (*Bridge, error) {
+	if config == nil || config.Upstream == nil {
+		return nil, errors.New("upstream client config is required")
+	}
+	if local == nil {
+		return nil, errors.New("local server is required")
+	}
+	if db == nil {
+		return nil, errors.New("database is required")
+	}
+
+	defaults := DefaultConfig()
+	if len(config.MessageTypes) == 0 {
+		config.MessageTypes = defaults.MessageTypes
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaults.RetryInterval
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = defaults.MaxPending
+	}
+	if config.SeenTTL <= 0 {
+		config.SeenTTL = defaults.SeenTTL
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logDir := config.Upstream.DataDir
+		if logDir == "" {
+			logDir = "."
+		}
+		var err error
+		logger, err = utils.NewLogger(utils.LogLevelInfo, logDir+"/logs")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create logger: %w", err)
+		}
+	}
+
+	upstreamBus := events.NewEventBus(nil)
+	upstream, err := client.NewClient(config.Upstream, db, upstreamBus)
+	if err != nil {
+		upstreamBus.Close()
+		return nil, fmt.Errorf("failed to create upstream client: %w", err)
+	}
+
+	return &Bridge{
+		config:            config,
+		logger:            logger,
+		local:             local,
+		localBus:          local.GetEventBus(),
+		upstream:          upstream,
+		upstreamBus:       upstreamBus,
+		localChannelID:    local.GetConfig().ChannelID,
+		upstreamChannelID: config.Upstream.ChannelID,
+		seen:              make(map[string]time.Time),
+		identities:        make(map[string]string),
+	}, nil
+}
+
+// Start 加入上游频道并开始双向转发
+func (b *Bridge) Start() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.isRunning {
+		return errors.New("bridge is already running")
+	}
+	if !b.local.IsRunning() {
+		return errors.New("local server is not running")
+	}
+
+	b.logger.Info("[Bridge] Bridging %s <-> %s", b.upstreamChannelID, b.localChannelID)
+
+	// 先订阅再启动：加入后的首次同步即可把上游历史转发到本地
+	b.subscriptions = []string{
+		b.upstreamBus.Subscribe(events.EventMessageReceived, b.handleUpstreamEvent),
+		b.localBus.Subscribe(events.EventMessageReceived, b.handleLocalEvent),
+	}
+
+	if err := b.upstream.Start(); err != nil {
+		b.unsubscribe()
+		return fmt.Errorf("failed to join upstream channel: %w", err)
+	}
+
+	b.stopCh = make(chan struct{})
+	b.wg.Add(1)
+	go b.maintenanceLoop()
+
+	b.isRunning = true
+	b.logger.Info("[Bridge] Bridge started, upstream member: %s", b.upstream.GetMemberID())
+	return nil
+}
+
+// Stop 停止转发并离开上游频道
+func (b *Bridge) Stop() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.isRunning {
+		return errors.New("bridge is not running")
+	}
+	b.isRunning = false
+
+	b.unsubscribe()
+	close(b.stopCh)
+	b.wg.Wait()
+
+	err := b.upstream.Stop()
+	b.upstreamBus.Close()
+
+	b.logger.Info("[Bridge] Bridge stopped")
+	return err
+}
+
+// unsubscribe 取消事件订阅
+func (b *Bridge) unsubscribe() {
+	if len(b.subscriptions) == 2 {
+		b.upstreamBus.Unsubscribe(b.subscriptions[0])
+		b.localBus.Unsubscribe(b.subscriptions[1])
+	}
+	b.subscriptions = nil
+}
+
+// IsRunning 检查是否运行中
+func (b *Bridge) IsRunning() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.isRunning
+}
+
+// GetUpstream 获取上游客户端
+func (b *Bridge) GetUpstream() *client.Client {
+	return b.upstream
+}
+
+// ===== 上游 → 本地 =====
+
+// handleUpstreamEvent 处理上游客户端收到的消息
+func (b *Bridge) handleUpstreamEvent(event *events.Event) {
+	msg := messageFromEvent(event)
+	if msg == nil {
+		return
+	}
+
+	if msg.ChannelID != b.upstreamChannelID || !b.shouldRelay(msg) {
+		return
+	}
+	// 已处理过（包括本地转发上去后的回显）
+	if !b.markSeen(msg.ID) {
+		return
+	}
+	// 桥接成员发出的消息即本地转发上去的消息
+	if msg.SenderID == b.upstream.GetMemberID() || server.HasVisited(msg, b.localChannelID) {
+		b.recordLoop(msg.ID)
+		return
+	}
+
+	senderID, err := b.mapUpstreamSender(msg)
+	if err != nil {
+		b.logger.Error("[Bridge] Failed to map upstream sender %s: %v", msg.SenderID, err)
+		b.recordFailure()
+		return
+	}
+
+	relayed := copyMessage(msg)
+	if err := b.local.RelayMessage(relayed, b.upstreamChannelID, senderID); err != nil {
+		if errors.Is(err, server.ErrAlreadyRelayed) || errors.Is(err, server.ErrRelayLoop) {
+			b.recordLoop(msg.ID)
+			return
+		}
+		b.logger.Error("[Bridge] Failed to relay %s to local channel: %v", msg.ID, err)
+		b.recordFailure()
+		return
+	}
+
+	b.stats.mutex.Lock()
+	b.stats.RelayedDown++
+	b.stats.mutex.Unlock()
+}
+
+// mapUpstreamSender 将上游成员映射为本地成员（首次出现时创建）
+func (b *Bridge) mapUpstreamSender(msg *models.Message) (string, error) {
+	b.identityMutex.Lock()
+	defer b.identityMutex.Unlock()
+
+	if localID, ok := b.identities[msg.SenderID]; ok {
+		return localID, nil
+	}
+
+	localID := fmt.Sprintf("%s@%s", msg.SenderID, b.upstreamChannelID)
+	nickname := b.upstreamNickname(msg)
+	if _, err := b.local.EnsureRelayMember(localID, nickname, b.upstreamChannelID); err != nil {
+		return "", err
+	}
+	b.identities[msg.SenderID] = localID
+	return localID, nil
+}
+
+// upstreamNickname 获取上游发送者昵称（消息未携带时查询上游成员列表）
+func (b *Bridge) upstreamNickname(msg *models.Message) string {
+	if _, nickname := server.OriginSender(msg); nickname != "" {
+		return nickname
+	}
+	if members, err := b.upstream.GetMembers(); err == nil {
+		for _, m := range members {
+			if m.ID == msg.SenderID && m.Nickname != "" {
+				return m.Nickname
+			}
+		}
+	}
+	return msg.SenderID
+}
+
+// ===== 本地 → 上游 =====
+
+// handleLocalEvent 处理本地服务端路由完成的消息
+func (b *Bridge) handleLocalEvent(event *events.Event) {
+	msg := messageFromEvent(event)
+	if msg == nil {
+		return
+	}
+
+	if msg.ChannelID != b.localChannelID || !b.shouldRelay(msg) {
+		return
+	}
+	// 已处理过（包括从上游转发下来的消息）
+	if !b.markSeen(msg.ID) {
+		return
+	}
+	if server.HasVisited(msg, b.upstreamChannelID) || b.isMappedMember(msg.SenderID) {
+		b.recordLoop(msg.ID)
+		return
+	}
+
+	relayed := copyMessage(msg)
+	if relayed.SenderNickname == "" {
+		if member, err := b.local.GetMember(relayed.SenderID); err == nil && member != nil {
+			relayed.SenderNickname = member.Nickname
+		}
+	}
+	if err := b.local.StampProvenance(relayed, b.localChannelID); err != nil {
+		b.recordLoop(msg.ID)
+		return
+	}
+
+	b.sendUpstream(relayed)
+}
+
+// sendUpstream 通过上游客户端转发，失败时加入重发队列
+func (b *Bridge) sendUpstream(msg *models.Message) {
+	if err := b.upstream.RelayMessage(msg); err != nil {
+		b.logger.Warn("[Bridge] Failed to relay %s upstream, will retry: %v", msg.ID, err)
+		b.pendingMutex.Lock()
+		if len(b.pending) >= b.config.MaxPending {
+			b.pending = b.pending[1:]
+			b.recordFailure()
+		}
+		b.pending = append(b.pending, msg)
+		b.pendingMutex.Unlock()
+		return
+	}
+
+	b.stats.mutex.Lock()
+	b.stats.RelayedUp++
+	b.stats.mutex.Unlock()
+}
+
+// isMappedMember 检查是否为上游成员在本地的映射
+func (b *Bridge) isMappedMember(memberID string) bool {
+	b.identityMutex.Lock()
+	defer b.identityMutex.Unlock()
+
+	for _, localID := range b.identities {
+		if localID == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== 维护 =====
+
+// maintenanceLoop 定期重发上行失败的消息并清理过期的消息ID
+func (b *Bridge) maintenanceLoop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+			b.retryPending()
+			b.cleanupSeen()
+		}
+	}
+}
+
+// retryPending 重发上行失败的消息
+func (b *Bridge) retryPending() {
+	b.pendingMutex.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.pendingMutex.Unlock()
+
+	for _, msg := range pending {
+		b.sendUpstream(msg)
+	}
+}
+
+// cleanupSeen 清理过期的消息ID
+func (b *Bridge) cleanupSeen() {
+	b.seenMutex.Lock()
+	defer b.seenMutex.Unlock()
+
+	expiry := time.Now().Add(-b.config.SeenTTL)
+	for id, ts := range b.seen {
+		if ts.Before(expiry) {
+			delete(b.seen, id)
+		}
+	}
+}
+
+// ===== 辅助函数 =====
+
+// shouldRelay 检查消息类型是否需要转发
+func (b *Bridge) shouldRelay(msg *models.Message) bool {
+	for _, t := range b.config.MessageTypes {
+		if msg.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// markSeen 记录消息ID，已处理过时返回 false
+func (b *Bridge) markSeen(messageID string) bool {
+	b.seenMutex.Lock()
+	defer b.seenMutex.Unlock()
+
+	if _, exists := b.seen[messageID]; exists {
+		return false
+	}
+	b.seen[messageID] = time.Now()
+	return true
+}
+
+// recordLoop 记录环路丢弃
+func (b *Bridge) recordLoop(messageID string) {
+	b.logger.Debug("[Bridge] Dropped looped message: %s", messageID)
+	b.stats.mutex.Lock()
+	b.stats.DroppedLoops++
+	b.stats.mutex.Unlock()
+}
+
+// recordFailure 记录转发失败
+func (b *Bridge) recordFailure() {
+	b.stats.mutex.Lock()
+	b.stats.FailedRelays++
+	b.stats.mutex.Unlock()
+}
+
+// GetStats 获取统计信息
+func (b *Bridge) GetStats() Stats {
+	b.pendingMutex.Lock()
+	pending := len(b.pending)
+	b.pendingMutex.Unlock()
+
+	b.stats.mutex.Lock()
+	defer b.stats.mutex.Unlock()
+
+	return Stats{
+		RelayedDown:   b.stats.RelayedDown,
+		RelayedUp:     b.stats.RelayedUp,
+		DroppedLoops:  b.stats.DroppedLoops,
+		FailedRelays:  b.stats.FailedRelays,
+		PendingRelays: pending,
+	}
+}
+
+// messageFromEvent 从消息事件中取出消息
+// 服务端发布的是 NewMessageReceivedEvent 构造的嵌套事件，客户端直接发布 MessageEvent
+func messageFromEvent(event *events.Event) *models.Message {
+	data := event.Data
+	if inner, ok := data.(*events.Event); ok {
+		data = inner.Data
+	}
+	if ev, ok := data.(*events.MessageEvent); ok {
+		return ev.Message
+	}
+	return nil
+}
+
+// copyMessage 复制消息（事件中的消息与发布方共享，转发前需复制元数据）
+func copyMessage(msg *models.Message) *models.Message {
+	copied := *msg
+	if msg.Metadata != nil {
+		copied.Metadata = make(models.JSONField, len(msg.Metadata))
+		for k, v := range msg.Metadata {
+			copied.Metadata[k] = v
+		}
+	}
+	return &copied
+}
@@ -0,0 +1,414 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"crosswire/internal/models"
+	"crosswire/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// 目录上传（文件包）
+// 目录被打包为未压缩 tar，写入本地内容存储的上传临时文件后按普通文件分块上传；
+// 打包时为每个条目记录路径、大小、权限、SHA-256 以及内容在 tar 中的偏移，
+// 清单随 file.metadata 的 bundle 字段发给服务端，出现在文件消息中供成员浏览。
+// 成员可以下载整个 tar，也可以只解出部分条目：包已缓存时按偏移读取，否则只请求
+// 覆盖所选条目字节范围的分块，在临时文件中逐条校验 SHA-256 后解出（不进入缓存）。
+// 参考: docs/PROTOCOL.md - 5.2.8 目录上传
+
+// bundleMimeType 文件包的 MIME 类型
+const bundleMimeType = "application/x-tar"
+
+// UploadFolder 上传目录（打包为一个文件包）
+func (fm *FileManager) UploadFolder(dirPath string) (*FileUploadTask, error) {
+	return fm.UploadFolderToChallenge(dirPath, "")
+}
+
+// UploadFolderToChallenge 上传目录到题目聊天室（challengeID 为空时等同于 UploadFolder）
+func (fm *FileManager) UploadFolderToChallenge(dirPath, challengeID string) (*FileUploadTask, error) {
+	fm.client.logger.Info("[FileManager] Uploading folder: %s", dirPath)
+
+	// 1. 检查目录
+	dirPath = filepath.Clean(dirPath)
+	stat, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat folder: %w", err)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("not a folder: %s", dirPath)
+	}
+
+	// 2. 打包到上传临时文件（上传完成后直接提交为本地缓存）
+	taskID := uuid.New().String()
+	blobs := fm.client.db.GetBlobStore()
+	out, err := blobs.Create(taskID)
+	if err != nil {
+		return nil, err
+	}
+	manifest, size, fileHash, err := packBundle(dirPath, out)
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		blobs.Abort(taskID)
+		return nil, fmt.Errorf("failed to pack folder: %w", err)
+	}
+
+	// 文件句柄交由 executeUpload 关闭
+	file, err := os.Open(out.Name())
+	if err != nil {
+		blobs.Abort(taskID)
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	// 3. 创建上传任务
+	chunkSize := fm.getOptimalChunkSize()
+	totalChunks := int(math.Ceil(float64(size) / float64(chunkSize)))
+
+	task := &FileUploadTask{
+		ID:             taskID,
+		FilePath:       out.Name(),
+		Filename:       manifest.Root + ".tar",
+		Size:           size,
+		MimeType:       bundleMimeType,
+		ChunkSize:      chunkSize,
+		TotalChunks:    totalChunks,
+		UploadedChunks: 0,
+		Status:         models.UploadStatusPending,
+		SHA256:         fileHash,
+		ChallengeID:    challengeID,
+		Bundle:         manifest,
+		StartTime:      time.Now(),
+		chunkStatus:    make([]bool, totalChunks),
+	}
+
+	fm.client.logger.Info("[FileManager] Packed folder %s: %d file(s), %d bytes (%d skipped)",
+		dirPath, manifest.Files, size, manifest.Skipped)
+
+	// 4. 注册任务并异步上传
+	fm.uploadsMutex.Lock()
+	fm.uploads[task.ID] = task
+	fm.uploadsMutex.Unlock()
+
+	fm.statsMutex.Lock()
+	fm.stats.TotalUploads++
+	fm.statsMutex.Unlock()
+
+	go fm.executeUpload(task, file, true)
+
+	return task, nil
+}
+
+// DownloadBundle 下载文件包并解出条目到 destDir（entries 为空时解出全部，目录路径包含其下全部条目）
+// 包内容已在本地缓存时直接解出；否则只请求所选条目所在的分块（同样可由其他成员分担），
+// 所选条目覆盖全部分块时下载整个包并缓存
+func (fm *FileManager) DownloadBundle(fileID, destDir string, entries []string) (*FileDownloadTask, error) {
+	fileInfo, err := fm.client.fileRepo.GetByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	manifest, err := bundleManifestOf(fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	task := fm.newDownloadTask(fileInfo, destDir)
+	task.Bundle = manifest
+	task.Entries = entries
+	if err := task.selectBundleChunks(); err != nil {
+		return nil, err
+	}
+
+	fm.startDownload(task)
+	return task, nil
+}
+
+// selectBundleChunks 计算所选条目字节范围覆盖的分块；覆盖全部分块时保持 wanted 为 nil（下载整个包）
+func (task *FileDownloadTask) selectBundleChunks() error {
+	entries, err := task.Bundle.Select(task.Entries)
+	if err != nil {
+		return err
+	}
+	if task.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size for file %s", task.FileID)
+	}
+
+	wanted := make(map[int]bool)
+	chunkSize := int64(task.ChunkSize)
+	for _, entry := range entries {
+		if entry.IsDir || entry.Size == 0 {
+			continue
+		}
+		first := int(entry.Offset / chunkSize)
+		last := int((entry.Offset + entry.Size - 1) / chunkSize)
+		for index := first; index <= last && index < task.TotalChunks; index++ {
+			wanted[index] = true
+		}
+	}
+	if len(wanted) < task.TotalChunks {
+		task.wanted = wanted
+	}
+	return nil
+}
+
+// bundleManifestOf 读取文件记录中的文件包清单
+func bundleManifestOf(file *models.File) (*models.BundleManifest, error) {
+	raw, ok := file.Metadata["bundle"]
+	if !ok || raw == nil {
+		return nil, fmt.Errorf("file %s is not a bundle", file.ID)
+	}
+	manifest, err := models.ParseBundleManifest(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Validate(file.Size); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// finishBundle 上传结束后处理打包内容：完成则提交为本地缓存并通告，被拒绝或取消则丢弃，暂停时保留以便续传
+func (fm *FileManager) finishBundle(task *FileUploadTask) {
+	task.mutex.RLock()
+	status := task.Status
+	task.mutex.RUnlock()
+
+	blobs := fm.client.db.GetBlobStore()
+	switch status {
+	case models.UploadStatusCompleted:
+		hash, err := blobs.Commit(task.ID, task.Size, task.SHA256)
+		if err != nil {
+			fm.client.logger.Warn("[FileManager] Failed to cache bundle %s: %v", task.ID, err)
+			return
+		}
+		if file, err := fm.client.fileRepo.GetByID(task.ID); err == nil && file != nil {
+			file.StoragePath = blobs.Path(hash)
+			if err := fm.client.fileRepo.Update(file); err != nil {
+				fm.client.logger.Warn("[FileManager] Failed to update storage path of %s: %v", task.ID, err)
+			}
+		}
+		fm.announceCached(hash)
+	case models.UploadStatusFailed:
+		if err := blobs.Abort(task.ID); err != nil {
+			fm.client.logger.Warn("[FileManager] Failed to discard bundle %s: %v", task.ID, err)
+		}
+	}
+}
+
+// extractBundle 从缓存的包内容中按偏移解出所选条目
+func (fm *FileManager) extractBundle(task *FileDownloadTask) error {
+	content, err := fm.client.db.GetBlobStore().Open(task.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to open cached bundle: %w", err)
+	}
+	defer content.Close()
+	return fm.extractBundleFrom(task, content)
+}
+
+// extractPartialBundle 从只含所需分块的下载临时文件解出所选条目，完成后丢弃临时文件
+// 条目校验失败时返回 storage.ErrBlobHashMismatch，由下载流程改向服务端重新请求
+func (fm *FileManager) extractPartialBundle(task *FileDownloadTask) error {
+	blobs := fm.client.db.GetBlobStore()
+	defer blobs.Abort(task.ID)
+
+	if len(task.wanted) == 0 {
+		// 所选条目均为目录或空文件
+		return fm.extractBundleFrom(task, bytes.NewReader(nil))
+	}
+	content, err := blobs.OpenTemp(task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to open partial bundle: %w", err)
+	}
+	defer content.Close()
+	return fm.extractBundleFrom(task, content)
+}
+
+// extractBundleFrom 按偏移解出所选条目，逐条校验 SHA-256
+func (fm *FileManager) extractBundleFrom(task *FileDownloadTask, content io.ReaderAt) error {
+	entries, err := task.Bundle.Select(task.Entries)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		rel := filepath.FromSlash(entry.Path)
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("invalid bundle entry path: %q", entry.Path)
+		}
+		target := filepath.Join(task.SavePath, rel)
+
+		if entry.IsDir {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			continue
+		}
+		if err := extractBundleEntry(content, entry, target); err != nil {
+			return err
+		}
+	}
+
+	fm.client.logger.Info("[FileManager] Extracted %d entr(ies) of %s to %s", len(entries), task.FileID, task.SavePath)
+	return nil
+}
+
+// extractBundleEntry 解出单个文件条目
+func extractBundleEntry(content io.ReaderAt, entry models.BundleEntry, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	perm := fs.FileMode(entry.Mode).Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), io.NewSectionReader(content, entry.Offset, entry.Size))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target)
+		return fmt.Errorf("failed to extract %s: %w", entry.Path, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != entry.SHA256 {
+		os.Remove(target)
+		return fmt.Errorf("%w: bundle entry %s expect=%s actual=%s", storage.ErrBlobHashMismatch, entry.Path, entry.SHA256, actual)
+	}
+
+	if entry.ModTime > 0 {
+		mtime := time.Unix(entry.ModTime, 0)
+		os.Chtimes(target, mtime, mtime)
+	}
+	return nil
+}
+
+// countingWriter 统计已写入字节数（用于记录条目在 tar 中的偏移）
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// packBundle 将目录打包为 tar 写入 out，返回清单、包大小与包的 SHA-256
+// 只打包目录与普通文件，符号链接与设备文件等跳过并计数
+func packBundle(dir string, out io.Writer) (*models.BundleManifest, int64, string, error) {
+	manifest := &models.BundleManifest{
+		Format:  models.BundleFormatTar,
+		Root:    filepath.Base(dir),
+		Entries: make([]models.BundleEntry, 0),
+	}
+
+	bundleHash := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(out, bundleHash)}
+	tw := tar.NewWriter(cw)
+	entryHash := sha256.New()
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsDir() && !info.Mode().IsRegular() {
+			manifest.Skipped++
+			return nil
+		}
+		if len(manifest.Entries) >= models.MaxBundleEntries {
+			return fmt.Errorf("folder has more than %d entries", models.MaxBundleEntries)
+		}
+		return addBundleEntry(tw, cw, entryHash, manifest, p, filepath.ToSlash(rel), info)
+	})
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, "", fmt.Errorf("failed to finish tar: %w", err)
+	}
+	return manifest, cw.n, hex.EncodeToString(bundleHash.Sum(nil)), nil
+}
+
+// addBundleEntry 写入一个条目并登记到清单
+func addBundleEntry(tw *tar.Writer, cw *countingWriter, h hash.Hash, manifest *models.BundleManifest, src, rel string, info fs.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to build tar header for %s: %w", rel, err)
+	}
+	hdr.Name = rel
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// 不泄露上传者本机的用户信息
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	hdr.ModTime = info.ModTime().Truncate(time.Second)
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	hdr.Format = tar.FormatPAX
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", rel, err)
+	}
+	entry := models.BundleEntry{
+		Path:    rel,
+		Mode:    uint32(info.Mode().Perm()),
+		ModTime: hdr.ModTime.Unix(),
+		IsDir:   info.IsDir(),
+	}
+	if info.IsDir() {
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	}
+
+	// WriteHeader 已把头部写入底层，当前位置即条目内容的起始偏移
+	entry.Offset = cw.n
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", rel, err)
+	}
+	defer f.Close()
+
+	h.Reset()
+	n, err := io.Copy(io.MultiWriter(tw, h), f)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s: %w", rel, err)
+	}
+	if n != info.Size() {
+		return fmt.Errorf("%s changed while packing", rel)
+	}
+	entry.Size = n
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	manifest.Entries = append(manifest.Entries, entry)
+	manifest.Files++
+	manifest.TotalSize += n
+	return nil
+}
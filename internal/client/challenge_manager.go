@@ -8,7 +8,6 @@ import (
 
 	"crosswire/internal/events"
 	"crosswire/internal/models"
-	"crosswire/internal/transport"
 
 	"github.com/google/uuid"
 )
@@ -169,24 +168,9 @@ func (cm *ChallengeManager) SubmitFlag(challengeID string, flag string) error {
 	}
 	cm.client.logger.Debug("[ChallengeManager] Submission payload marshaled: bytes=%d", len(payload))
 
-	encrypted, err := cm.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		cm.client.logger.Error("[ChallengeManager] Encrypt submission failed: %v", err)
-		return fmt.Errorf("failed to encrypt submission: %w", err)
-	}
-	cm.client.logger.Debug("[ChallengeManager] Submission encrypted: bytes=%d", len(encrypted))
-
 	// 发送提交
-	msg := &transport.Message{
-		ID:        uuid.New().String(),
-		Type:      transport.MessageTypeControl,
-		SenderID:  cm.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
-	}
-	cm.client.logger.Debug("[ChallengeManager] Sending control message: type=challenge.submit id=%s", msg.ID)
-
-	if err := cm.client.transport.SendMessage(msg); err != nil {
+	cm.client.logger.Debug("[ChallengeManager] Sending control message: type=challenge.submit challenge=%s", challengeID)
+	if err := cm.client.sendControlMessage(payload); err != nil {
 		cm.client.logger.Error("[ChallengeManager] Send submission failed: %v", err)
 		return fmt.Errorf("failed to send submission: %w", err)
 	}
@@ -420,12 +404,12 @@ func (cm *ChallengeManager) handleChallengeUpdated(event *events.Event) {
 		return
 	}
 
-	// 更新内存缓存
+	// 更新内存缓存并本地持久化（存在则保留首次创建时间）
+	// 持锁完成：对象放入缓存后其他事件处理器可能同时修改它
 	cm.challengesMutex.Lock()
+	defer cm.challengesMutex.Unlock()
 	cm.challenges[ch.ID] = ch
-	cm.challengesMutex.Unlock()
 
-	// 本地持久化（存在则保留首次创建时间）
 	if cm.client != nil && cm.client.challengeRepo != nil {
 		if existing, err := cm.client.challengeRepo.GetByID(ch.ID); err == nil && existing != nil {
 			ch.CreatedAt = existing.CreatedAt
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"crosswire/internal/crypto"
@@ -14,6 +15,8 @@ import (
 	"crosswire/internal/storage"
 	"crosswire/internal/transport"
 	"crosswire/internal/utils"
+
+	"github.com/google/uuid"
 )
 
 // Client 客户端核心
@@ -52,8 +55,17 @@ type Client struct {
 	isRunning     bool
 	mutex         sync.RWMutex
 	startTime     time.Time
-	memberID      string // 本地成员ID
-	lastSeenMsgID string // 最后接收的消息ID
+	memberID      string     // 本地成员ID
+	lastSeenMsgID string     // 最后接收的消息ID
+	pendingJoinID string     // 待决加入/续连请求的关联ID（响应为广播，据此过滤他人的响应）
+	failoverMutex sync.Mutex // 串行化重连与自动模式下的传输切换
+
+	// 连接状态机（见 connection_state.go）
+	connState    ConnectionState
+	lastContact  atomic.Int64 // 最近一次收到服务端消息的时间（UnixNano）
+	resumeToken  string       // 服务端签发的续连令牌
+	ephemeralKey []byte       // 待决加入/续连请求的临时X25519私钥（用于解密下发的令牌）
+	resumeResult chan bool    // 待决续连请求的结果
 
 	// 密钥对（用于消息签名）
 	privateKey []byte // Ed25519私钥
@@ -90,6 +102,22 @@ type Config struct {
 	JoinTimeout time.Duration
 	SyncTimeout time.Duration
 
+	// 自动传输模式
+	ProbeTimeout      time.Duration // 每个候选传输的加入超时
+	FailoverThreshold int           // 自动模式下连续多少次发送失败视为连接丢失
+
+	// 心跳与断线重连
+	HeartbeatInterval    time.Duration // 心跳（状态上报）间隔
+	LivenessTimeout      time.Duration // 多久未收到服务端任何消息视为连接丢失（默认三个心跳周期）
+	AutoReconnect        bool          // 连接丢失后自动重连（否则直接进入 failed 状态）
+	ReconnectDelay       time.Duration // 首次重连前的等待（之后指数退避）
+	ReconnectMaxDelay    time.Duration // 重连等待上限
+	MaxReconnectAttempts int           // 连续重连失败多少次后放弃（0 表示不限）
+
+	// 文件传输
+	UploadWorkers  int    // 并行发送分块的上传协程数
+	PeerListenAddr string // 对等分发监听地址（TCP，如 ":0"）；为空时不为其他成员提供缓存分块
+
 	// 数据库路径
 	DataDir string
 }
@@ -108,6 +136,7 @@ type ClientStats struct {
 	BytesSent        uint64
 	SyncCount        uint64
 	LastSyncTime     time.Time
+	HeartbeatRTT     time.Duration // 最近一次心跳往返时延（服务端回显心跳时间戳测得）
 	mutex            sync.RWMutex
 }
 
@@ -118,16 +147,37 @@ type SignedMessage struct {
 	SenderID  string `json:"sender_id"` // 发送者ID
 }
 
+// SignedControl 带成员签名的控制消息（与服务端对应）
+// 参考: docs/PROTOCOL.md - 2.2.5 控制消息认证
+type SignedControl struct {
+	MemberID  string          `json:"member_id"`
+	Nonce     string          `json:"nonce"`
+	Timestamp int64           `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`   // 原始控制消息JSON
+	Signature []byte          `json:"signature"` // Ed25519签名（controlSigningBytes）
+}
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		SyncInterval:    5 * time.Second,
-		MaxSyncMessages: 1000,
-		CacheSize:       5000,
-		CacheDuration:   24 * time.Hour,
-		JoinTimeout:     30 * time.Second,
-		SyncTimeout:     10 * time.Second,
-		TransportMode:   models.TransportHTTPS,
+		SyncInterval:      5 * time.Second,
+		MaxSyncMessages:   1000,
+		CacheSize:         5000,
+		CacheDuration:     24 * time.Hour,
+		JoinTimeout:       30 * time.Second,
+		SyncTimeout:       10 * time.Second,
+		ProbeTimeout:      5 * time.Second,
+		FailoverThreshold: 3,
+		TransportMode:     models.TransportHTTPS,
+
+		HeartbeatInterval:    defaultHeartbeatInterval,
+		AutoReconnect:        true,
+		ReconnectDelay:       time.Second,
+		ReconnectMaxDelay:    30 * time.Second,
+		MaxReconnectAttempts: 10,
+
+		UploadWorkers:  4,
+		PeerListenAddr: ":0",
 	}
 }
 
@@ -212,10 +262,12 @@ func NewClient(config *Config, db *storage.Database, eventBus *events.EventBus)
 
 // initRepositories 初始化仓库
 func (c *Client) initRepositories() error {
-	// 打开频道数据库
-	if err := c.db.OpenChannelDB(c.config.ChannelID); err != nil {
+	// 打开频道数据库并绑定到本频道（同一进程可同时打开其他频道）
+	db, err := c.db.ForChannel(c.config.ChannelID)
+	if err != nil {
 		return fmt.Errorf("failed to open channel database: %w", err)
 	}
+	c.db = db
 
 	// 初始化仓库（传入整个Database对象）
 	c.messageRepo = storage.NewMessageRepository(c.db)
@@ -237,6 +289,7 @@ func (c *Client) Start() error {
 	}
 	c.isRunning = true
 	c.startTime = time.Now()
+	c.connState = StateConnecting
 	c.mutex.Unlock()
 
 	c.logger.Info("[Client] Starting client...")
@@ -246,24 +299,12 @@ func (c *Client) Start() error {
 		return fmt.Errorf("failed to initialize transport: %w", err)
 	}
 
-	// 2. HTTPS模式：先建立到服务器的WebSocket连接，并拉取频道信息
-	if c.config.TransportMode == models.TransportHTTPS {
-		addr := c.config.TransportConfig.ServerAddress
-		port := c.config.TransportConfig.Port
-		if addr == "" || port == 0 {
-			return fmt.Errorf("https server address/port not set")
-		}
-
-		// 连接，例如 1.2.3.4:8443 -> wss://1.2.3.4:8443/ws
-		if httpsTr, ok := c.transport.(*transport.HTTPSTransport); ok {
-			target := fmt.Sprintf("%s:%d", addr, port)
-			c.logger.Info("[Client] Connecting HTTPS transport to %s", target)
-			if err := httpsTr.Connect(target); err != nil {
-				return fmt.Errorf("failed to connect https transport: %w", err)
-			}
-			// 获取频道信息以校验/填充 ChannelID
-			// 简化：期待加入响应携带频道上下文（或后续通过 /info 获取）
-			c.logger.Info("[Client] Connected to server, channel_id=%s (may be empty before join)", c.config.ChannelID)
+	// 2. 面向连接的传输（如HTTPS）：先建立到服务器的连接
+	// 自动模式在加入阶段逐个候选探测连接
+	auto, isAuto := c.transport.(*transport.AutoTransport)
+	if !isAuto {
+		if err := c.connectTransport(); err != nil {
+			return err
 		}
 	}
 
@@ -273,9 +314,15 @@ func (c *Client) Start() error {
 	}
 
 	// 4. 加入频道（发送认证请求并等待响应）
-	if err := c.joinChannel(); err != nil {
+	if isAuto {
+		if err := c.probeTransports(auto); err != nil {
+			return fmt.Errorf("failed to join channel: %w", err)
+		}
+	} else if err := c.joinChannel(c.config.JoinTimeout); err != nil {
 		return fmt.Errorf("failed to join channel: %w", err)
 	}
+	c.markContact()
+	c.setConnectionState(StateJoined, "joined channel", nil)
 
 	// 5. 启动同步管理器
 	if err := c.syncManager.Start(); err != nil {
@@ -305,6 +352,9 @@ func (c *Client) Start() error {
 	// 10. 启动心跳（状态上报）
 	go c.startHeartbeat()
 
+	// 11. 监控连接健康状况，丢失时重连（自动模式下依次切换候选传输）
+	go c.monitorConnection()
+
 	c.logger.Info("[Client] Client started successfully")
 
 	return nil
@@ -341,6 +391,9 @@ func (c *Client) Stop() error {
 	if c.receiveManager != nil {
 		c.receiveManager.Stop()
 	}
+	if c.discoveryManager != nil {
+		c.discoveryManager.Stop()
+	}
 
 	// 发送离开消息
 	c.leaveChannel()
@@ -374,20 +427,21 @@ func (c *Client) initTransport() error {
 		return fmt.Errorf("failed to initialize transport: %w", err)
 	}
 
-	if err := c.transport.Start(); err != nil {
-		return fmt.Errorf("failed to start transport: %w", err)
+	// 设置客户端角色与频道信息（传输层按需实现对应的可选接口）
+	// 如果通过发现拿到了服务器公钥，可在外部调用 SetServerPublicKey；此处保持可选
+	if rs, ok := c.transport.(transport.RoleSetter); ok {
+		rs.SetMode("client")
+	}
+	if cs, ok := c.transport.(transport.ChannelInfoSetter); ok {
+		cs.SetChannelInfo(c.config.ChannelID, "")
+	}
+	// 重连由客户端连接状态机统一负责（需重新续连会话），关闭传输层自身的重连
+	if rs, ok := c.transport.(transport.ReconnectSetter); ok {
+		rs.SetAutoReconnect(false)
 	}
 
-	// 根据模式设置 server 公钥（用于广播验签）
-	switch tr := c.transport.(type) {
-	case *transport.ARPTransport:
-		tr.SetMode("client")
-		// 如果通过发现拿到了服务器公钥，可在外部调用 SetServerPublicKey；此处保持可选
-	case *transport.MDNSTransport:
-		tr.SetMode("client")
-		tr.SetChannelInfo(c.config.ChannelID, "")
-	case *transport.HTTPSTransport:
-		tr.SetMode("client")
+	if err := c.transport.Start(); err != nil {
+		return fmt.Errorf("failed to start transport: %w", err)
 	}
 
 	// 提前订阅传输层消息，避免连接早期帧在订阅前丢失
@@ -412,24 +466,56 @@ func (c *Client) initTransport() error {
 	return nil
 }
 
-// joinChannel 加入频道
-func (c *Client) joinChannel() error {
-	c.logger.Info("[Client] Joining channel: %s (mode=%s)", c.config.ChannelID, c.config.TransportMode)
-	if c.config.ChannelID == "" && c.config.TransportMode == models.TransportHTTPS {
+// connectTransport 面向连接的传输（如HTTPS）：建立到服务器的连接
+func (c *Client) connectTransport() error {
+	mode := c.transport.GetMode()
+	caps, ok := transport.GetCapabilities(mode)
+	if !ok || !caps.NeedConnect {
+		return nil
+	}
+
+	addr := c.config.TransportConfig.ServerAddress
+	port := c.config.TransportConfig.Port
+	if port == 0 {
+		port = caps.DefaultPort
+	}
+	if port == 0 || (caps.NeedAddress && addr == "") {
+		return fmt.Errorf("%s server address/port not set", mode)
+	}
+
+	// 连接，例如 1.2.3.4:8443 -> wss://1.2.3.4:8443/ws
+	target := fmt.Sprintf("%s:%d", addr, port)
+	c.logger.Info("[Client] Connecting %s transport to %s", mode, target)
+	if err := c.transport.Connect(target); err != nil {
+		return fmt.Errorf("failed to connect %s transport: %w", mode, err)
+	}
+	// 获取频道信息以校验/填充 ChannelID
+	// 简化：期待加入响应携带频道上下文（或后续通过 /info 获取）
+	c.logger.Info("[Client] Connected to server, channel_id=%s (may be empty before join)", c.config.ChannelID)
+	return nil
+}
+
+// joinChannel 加入频道（已有成员ID时携带原ID，服务端校验公钥后沿用原成员身份）
+func (c *Client) joinChannel(timeout time.Duration) error {
+	c.logger.Info("[Client] Joining channel: %s (mode=%s)", c.config.ChannelID, c.transport.GetMode())
+	mode := c.transport.GetMode()
+	if c.config.ChannelID == "" && mode == models.TransportHTTPS {
 		c.logger.Warn("[Client] ChannelID is empty before join. Make sure server /info is used to fetch it or rely on join response to set member and channel context.")
 	}
-	if c.config.TransportMode == models.TransportHTTPS {
+	if mode == models.TransportHTTPS {
 		addr := c.config.TransportConfig.ServerAddress
 		port := c.config.TransportConfig.Port
 		c.logger.Debug("[Client] Using HTTPS server %s:%d", addr, port)
 	}
 
-	// 生成临时的用户密钥对（用于将来支持基于X25519的频道密钥加密分发）
-	_, ephPub, _ := c.crypto.GenerateX25519KeyPair()
+	// 生成临时的用户密钥对：服务端以其协商的密钥加密下发续连令牌
+	ephPriv, ephPub, _ := c.crypto.GenerateX25519KeyPair()
 
 	// 构造加入请求
+	requestID := generateMessageID()
 	joinReq := map[string]interface{}{
 		"type":             "auth.join",
+		"request_id":       requestID,
 		"channel_id":       c.config.ChannelID,
 		"nickname":         c.config.Nickname,
 		"avatar":           c.config.Avatar,
@@ -438,6 +524,9 @@ func (c *Client) joinChannel() error {
 		"ephemeral_pubkey": ephPub,      // 可选：服务端可用其加密敏感数据
 		"timestamp":        time.Now().Unix(),
 	}
+	if memberID := c.GetMemberID(); memberID != "" {
+		joinReq["member_id"] = memberID
+	}
 
 	// 序列化
 	reqJSON, err := json.Marshal(joinReq)
@@ -467,7 +556,12 @@ func (c *Client) joinChannel() error {
 		}
 	})
 
+	c.mutex.Lock()
+	c.pendingJoinID = requestID
+	c.ephemeralKey = ephPriv
+	c.mutex.Unlock()
 	if err := c.transport.SendMessage(msg); err != nil {
+		c.setPendingJoinID("")
 		// 加强客户端侧日志：打印加密前后长度与频道信息
 		c.logger.Error("[Client] Send join failed: %v | channel_id=%s plain_len=%d cipher_len=%d", err, c.config.ChannelID, len(reqJSON), len(reqData))
 		c.logger.Error("[Client] Send join request failed: %v", err)
@@ -477,7 +571,6 @@ func (c *Client) joinChannel() error {
 	c.logger.Info("[Client] Join request sent, waiting for response...")
 
 	// 等待加入响应（通过receiveManager接收）
-	timeout := c.config.JoinTimeout
 	if timeout <= 0 {
 		timeout = 30 * time.Second
 	}
@@ -486,6 +579,7 @@ func (c *Client) joinChannel() error {
 		// 成功
 	case <-time.After(timeout):
 		c.eventBus.Unsubscribe(subID)
+		c.setPendingJoinID("")
 		c.logger.Error("[Client] Join response timeout after %s", timeout.String())
 		return fmt.Errorf("join response timeout")
 	}
@@ -494,11 +588,25 @@ func (c *Client) joinChannel() error {
 	return nil
 }
 
+// setPendingJoinID 设置待决加入请求ID（空串表示无待决请求）
+func (c *Client) setPendingJoinID(requestID string) {
+	c.mutex.Lock()
+	c.pendingJoinID = requestID
+	c.mutex.Unlock()
+}
+
+// getPendingJoinID 获取待决加入请求ID
+func (c *Client) getPendingJoinID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.pendingJoinID
+}
+
 // leaveChannel 离开频道
 func (c *Client) leaveChannel() {
 	c.logger.Info("[Client] Leaving channel: %s", c.config.ChannelID)
 
-	// 构造离开请求
+	// 构造离开请求（服务端验证签名后作废会话）
 	leaveReq := map[string]interface{}{
 		"type":       "auth.leave",
 		"channel_id": c.config.ChannelID,
@@ -506,28 +614,13 @@ func (c *Client) leaveChannel() {
 		"timestamp":  time.Now().Unix(),
 	}
 
-	// 序列化并加密
 	reqJSON, err := json.Marshal(leaveReq)
 	if err != nil {
 		c.logger.Error("[Client] Failed to marshal leave request: %v", err)
 		return
 	}
 
-	reqData, err := c.crypto.EncryptMessage(reqJSON)
-	if err != nil {
-		c.logger.Error("[Client] Failed to encrypt leave request: %v", err)
-		return
-	}
-
-	// 发送
-	msg := &transport.Message{
-		Type:      transport.MessageTypeControl,
-		SenderID:  c.memberID,
-		Payload:   reqData,
-		Timestamp: time.Now(),
-	}
-
-	if err := c.transport.SendMessage(msg); err != nil {
+	if err := c.sendControlMessage(reqJSON); err != nil {
 		c.logger.Error("[Client] Failed to send leave request: %v", err)
 	}
 }
@@ -546,12 +639,27 @@ func (c *Client) SendMessage(content string, msgType models.MessageType) error {
 }
 
 // SendMessageToChannel 发送消息到指定频道
+// 连接丢失期间发往主频道的消息进入离线队列，恢复后按序发送
 func (c *Client) SendMessageToChannel(content string, msgType models.MessageType, channelID string) error {
 	if !c.isRunning {
 		return fmt.Errorf("client is not running")
 	}
+	if !c.isOnline() && channelID == c.config.ChannelID {
+		return c.offlineQueue.Enqueue(content, msgType, "")
+	}
+
+	msg := c.newMessage(content, msgType, channelID)
+	if err := c.sendSignedMessage(msg); err != nil {
+		return err
+	}
+
+	c.logger.Debug("[Client] Signed message sent: %s to channel: %s", msg.ID, channelID)
+
+	return nil
+}
 
-	// 构造消息
+// newMessage 按类型构造一条待发送的消息
+func (c *Client) newMessage(content string, msgType models.MessageType, channelID string) *models.Message {
 	msg := &models.Message{
 		ID:        generateMessageID(),
 		ChannelID: channelID,
@@ -582,6 +690,70 @@ func (c *Client) SendMessageToChannel(content string, msgType models.MessageType
 		msg.RoomType = "main"
 	}
 
+	return msg
+}
+
+// RelayMessage 以本成员身份转发一条已有消息（保留消息ID、类型、内容与元数据，供桥接使用）
+func (c *Client) RelayMessage(msg *models.Message) error {
+	if !c.isRunning {
+		return fmt.Errorf("client is not running")
+	}
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+
+	relayed := *msg
+	relayed.ChannelID = c.config.ChannelID
+	relayed.SenderID = c.GetMemberID()
+	relayed.RoomType = "main"
+	relayed.ChallengeID = ""
+
+	if err := c.sendSignedMessage(&relayed); err != nil {
+		return err
+	}
+
+	c.logger.Debug("[Client] Relayed message sent: %s", relayed.ID)
+
+	return nil
+}
+
+// controlSigningBytes 控制消息的签名内容（与服务端一致）
+func controlSigningBytes(memberID, nonce string, timestamp int64, payload []byte) []byte {
+	return append([]byte(fmt.Sprintf("control|%s|%s|%d|", memberID, nonce, timestamp)), payload...)
+}
+
+// sendControlMessage 签名并加密发送控制消息（服务端拒收未签名的控制消息）
+func (c *Client) sendControlMessage(payload []byte) error {
+	nonce := uuid.New().String()
+	timestamp := time.Now().Unix()
+	envelope, err := json.Marshal(&SignedControl{
+		MemberID:  c.memberID,
+		Nonce:     nonce,
+		Timestamp: timestamp,
+		Payload:   payload,
+		Signature: ed25519.Sign(c.privateKey, controlSigningBytes(c.memberID, nonce, timestamp, payload)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal control message: %w", err)
+	}
+
+	encrypted, err := c.crypto.EncryptMessage(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt control message: %w", err)
+	}
+
+	msg := &transport.Message{
+		ID:        nonce,
+		Type:      transport.MessageTypeControl,
+		SenderID:  c.memberID,
+		Payload:   encrypted,
+		Timestamp: time.Now(),
+	}
+	return c.transport.SendMessage(msg)
+}
+
+// sendSignedMessage 签名、加密并发送消息
+func (c *Client) sendSignedMessage(msg *models.Message) error {
 	// 1. 序列化消息
 	msgJSON, err := json.Marshal(msg)
 	if err != nil {
@@ -629,8 +801,6 @@ func (c *Client) SendMessageToChannel(content string, msgType models.MessageType
 	c.stats.BytesSent += uint64(len(encrypted))
 	c.stats.mutex.Unlock()
 
-	c.logger.Debug("[Client] Signed message sent: %s to channel: %s", msg.ID, channelID)
-
 	return nil
 }
 
@@ -738,16 +908,16 @@ func (c *Client) RemoveReactionNetwork(messageID, emoji string) error {
 	return nil
 }
 
-// startHeartbeat 周期性发送状态更新作为心跳
+// startHeartbeat 周期性发送状态更新作为心跳（服务端的回显同时用于连接保活判定与 RTT 测量）
 func (c *Client) startHeartbeat() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(c.heartbeatInterval())
 	defer ticker.Stop()
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			if !c.IsRunning() || c.memberID == "" {
+			if !c.IsRunning() || c.memberID == "" || !c.isOnline() {
 				continue
 			}
 			// 获取当前状态，默认online
@@ -794,30 +964,24 @@ func (c *Client) UpdateStatus(status models.UserStatus) error {
 	}
 
 	// 发送控制消息到服务器
+	// sent_at 由服务端在 member.status 中回显以测量 RTT；rtt_ms 上报最近一次测得的 RTT
 	payload := map[string]interface{}{
 		"type":       "status.update",
 		"channel_id": c.config.ChannelID,
 		"member_id":  c.memberID,
 		"status":     status,
 		"timestamp":  time.Now().Unix(),
+		"sent_at":    time.Now().UnixNano(),
+	}
+	if rtt := c.GetStats().HeartbeatRTT; rtt > 0 {
+		payload["rtt_ms"] = float64(rtt) / float64(time.Millisecond)
 	}
 	reqJSON, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal status update: %w", err)
 	}
 
-	encrypted, err := c.crypto.EncryptMessage(reqJSON)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt status update: %w", err)
-	}
-
-	msg := &transport.Message{
-		Type:      transport.MessageTypeControl,
-		SenderID:  c.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
-	}
-	if err := c.transport.SendMessage(msg); err != nil {
+	if err := c.sendControlMessage(reqJSON); err != nil {
 		return fmt.Errorf("failed to send status update: %w", err)
 	}
 
@@ -888,9 +1052,39 @@ func (c *Client) GetStats() ClientStats {
 		BytesSent:        c.stats.BytesSent,
 		SyncCount:        c.stats.SyncCount,
 		LastSyncTime:     c.stats.LastSyncTime,
+		HeartbeatRTT:     c.stats.HeartbeatRTT,
 	}
 }
 
+// peerMetrics 当前传输层的按对端统计（不支持时为 nil）
+func (c *Client) peerMetrics() *transport.PeerMetrics {
+	if p, ok := c.transport.(transport.PeerStatsProvider); ok {
+		return p.PeerMetrics()
+	}
+	return nil
+}
+
+// GetPeerStats 获取传输层按对端统计（客户端通常只有服务端一个对端）
+func (c *Client) GetPeerStats() []transport.PeerStats {
+	return c.peerMetrics().Snapshot()
+}
+
+// GetPeerStatsHistory 获取对端统计的时间序列
+func (c *Client) GetPeerStatsHistory(peer string, since time.Time) []transport.PeerSample {
+	return c.peerMetrics().History(peer, since)
+}
+
+// recordHeartbeatRTT 记录心跳往返时延（同时计入传输层对端统计）
+func (c *Client) recordHeartbeatRTT(peer string, rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+	c.stats.mutex.Lock()
+	c.stats.HeartbeatRTT = rtt
+	c.stats.mutex.Unlock()
+	c.peerMetrics().RecordRTT(peer, rtt)
+}
+
 // IsRunning 检查是否运行中
 func (c *Client) IsRunning() bool {
 	c.mutex.RLock()
@@ -900,6 +1094,11 @@ func (c *Client) IsRunning() bool {
 
 // ===== 辅助Getter =====
 
+// GetDatabase 获取绑定到当前频道的数据库视图
+func (c *Client) GetDatabase() *storage.Database {
+	return c.db
+}
+
 // GetChannelID 获取频道ID
 func (c *Client) GetChannelID() string {
 	if c.config == nil {
@@ -939,11 +1138,31 @@ func (c *Client) UploadFile(filePath string) (*FileUploadTask, error) {
 	return c.fileManager.UploadFile(filePath)
 }
 
+// UploadFileToChallenge 上传文件到题目聊天室（服务端会自动分析附件并在聊天室发布结果）
+func (c *Client) UploadFileToChallenge(filePath, challengeID string) (*FileUploadTask, error) {
+	return c.fileManager.UploadFileToChallenge(filePath, challengeID)
+}
+
+// UploadFolder 上传目录（打包为一个文件包，成员可浏览并下载单个条目）
+func (c *Client) UploadFolder(dirPath string) (*FileUploadTask, error) {
+	return c.fileManager.UploadFolder(dirPath)
+}
+
+// UploadFolderToChallenge 上传目录到题目聊天室
+func (c *Client) UploadFolderToChallenge(dirPath, challengeID string) (*FileUploadTask, error) {
+	return c.fileManager.UploadFolderToChallenge(dirPath, challengeID)
+}
+
 // DownloadFile 下载文件
 func (c *Client) DownloadFile(fileID string, savePath string) (*FileDownloadTask, error) {
 	return c.fileManager.DownloadFile(fileID, savePath)
 }
 
+// DownloadBundle 下载文件包并解出条目到 destDir（entries 为空时解出全部）
+func (c *Client) DownloadBundle(fileID, destDir string, entries []string) (*FileDownloadTask, error) {
+	return c.fileManager.DownloadBundle(fileID, destDir, entries)
+}
+
 // GetUploadTask 获取上传任务
 func (c *Client) GetUploadTask(taskID string) (*FileUploadTask, bool) {
 	return c.fileManager.GetUploadTask(taskID)
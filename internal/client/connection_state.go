@@ -0,0 +1,449 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crosswire/internal/events"
+	"crosswire/internal/transport"
+)
+
+// 连接状态机与断线续连
+// 参考: docs/ARCHITECTURE.md - 3.1.3 客户端模块
+// 参考: docs/PROTOCOL.md - 2.2.4 断线续连
+//
+// 所有传输共用一套状态：connecting → joined ⇄ degraded → reconnecting → joined | failed。
+// 连接丢失的判定不依赖具体传输：面向连接的传输断开、自动模式下连续发送失败，
+// 或超过 LivenessTimeout 未收到服务端任何消息（服务端至少每个心跳周期回显一次本成员的心跳）。
+// 重连时优先以服务端签发的续连令牌恢复原会话（auth.resume），令牌失效时回退为携带原成员ID的完整加入；
+// 恢复后立即强制同步并发送离线队列。
+
+// ConnectionState 客户端连接状态
+type ConnectionState string
+
+const (
+	StateConnecting   ConnectionState = "connecting"   // 首次连接并加入中
+	StateJoined       ConnectionState = "joined"       // 已加入，服务端消息正常到达
+	StateDegraded     ConnectionState = "degraded"     // 已加入，但服务端消息迟到或发送出现失败
+	StateReconnecting ConnectionState = "reconnecting" // 连接丢失，正在重连
+	StateFailed       ConnectionState = "failed"       // 重连放弃（需调用 Reconnect 手动重试）
+)
+
+const (
+	connectionCheckInterval  = 2 * time.Second  // 连接健康检查间隔
+	defaultHeartbeatInterval = 30 * time.Second // 默认心跳间隔
+)
+
+var (
+	errNoResumeToken  = errors.New("no resume token")
+	errResumeRejected = errors.New("resume rejected by server")
+)
+
+// GetConnectionState 获取当前连接状态
+func (c *Client) GetConnectionState() ConnectionState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.connState
+}
+
+// isOnline 是否可直接发送（已加入或降级）
+func (c *Client) isOnline() bool {
+	state := c.GetConnectionState()
+	return state == StateJoined || state == StateDegraded
+}
+
+// setConnectionState 切换连接状态并发布事件（状态未变化时不发布）
+func (c *Client) setConnectionState(state ConnectionState, reason string, data map[string]interface{}) {
+	c.mutex.Lock()
+	previous := c.connState
+	c.connState = state
+	c.mutex.Unlock()
+	if previous == state {
+		return
+	}
+
+	c.logger.Info("[Client] Connection state: %s -> %s (%s)", previous, state, reason)
+
+	// 首次加入由上层发布连接事件
+	if previous == StateConnecting && state == StateJoined {
+		return
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["state"] = state
+	data["previous"] = previous
+
+	eventType := events.EventSystemReconnect
+	if state == StateFailed {
+		eventType = events.EventSystemDisconnect
+	}
+	c.eventBus.Publish(eventType, &events.SystemEvent{
+		Type:    string(state),
+		Message: reason,
+		Data:    data,
+	})
+}
+
+// markContact 记录收到服务端消息的时间
+func (c *Client) markContact() {
+	c.lastContact.Store(time.Now().UnixNano())
+}
+
+// sinceContact 距最近一次收到服务端消息的时长
+func (c *Client) sinceContact() time.Duration {
+	return time.Since(time.Unix(0, c.lastContact.Load()))
+}
+
+// heartbeatInterval 心跳间隔
+func (c *Client) heartbeatInterval() time.Duration {
+	if c.config.HeartbeatInterval > 0 {
+		return c.config.HeartbeatInterval
+	}
+	return defaultHeartbeatInterval
+}
+
+// livenessTimeout 判定连接丢失的静默时长（默认三个心跳周期）
+func (c *Client) livenessTimeout() time.Duration {
+	if c.config.LivenessTimeout > 0 {
+		return c.config.LivenessTimeout
+	}
+	return 3*c.heartbeatInterval() + 5*time.Second
+}
+
+// rejoinTimeout 重连时单次续连/加入的等待时长
+func (c *Client) rejoinTimeout() time.Duration {
+	if _, isAuto := c.transport.(*transport.AutoTransport); isAuto && c.config.ProbeTimeout > 0 {
+		return c.config.ProbeTimeout
+	}
+	if c.config.JoinTimeout > 0 {
+		return c.config.JoinTimeout
+	}
+	return 30 * time.Second
+}
+
+// monitorConnection 周期检查连接健康状况，驱动 joined/degraded/reconnecting 之间的切换
+func (c *Client) monitorConnection() {
+	ticker := time.NewTicker(connectionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !c.IsRunning() {
+			return
+		}
+
+		state := c.GetConnectionState()
+		if state != StateJoined && state != StateDegraded {
+			continue
+		}
+
+		lost, degraded, reason := c.checkConnection()
+		switch {
+		case lost:
+			if err := c.reconnect(reason); err != nil {
+				c.logger.Error("[Client] Reconnect failed: %v", err)
+			}
+		case degraded:
+			c.setConnectionState(StateDegraded, reason, nil)
+		default:
+			c.setConnectionState(StateJoined, "connection recovered", nil)
+		}
+	}
+}
+
+// checkConnection 判断连接是否丢失或降级
+func (c *Client) checkConnection() (lost, degraded bool, reason string) {
+	mode := c.transport.GetMode()
+	if caps, ok := transport.GetCapabilities(mode); ok && caps.NeedConnect && !c.transport.IsConnected() {
+		return true, false, fmt.Sprintf("%s transport disconnected", mode)
+	}
+
+	failures := 0
+	if auto, ok := c.transport.(*transport.AutoTransport); ok {
+		failures = auto.ConsecutiveFailures()
+		if failures >= c.failoverThreshold() {
+			return true, false, fmt.Sprintf("%d consecutive send failures on %s", failures, mode)
+		}
+	}
+
+	silence := c.sinceContact()
+	if silence > c.livenessTimeout() {
+		return true, false, fmt.Sprintf("no message from server for %s", silence.Round(time.Second))
+	}
+	if silence > c.heartbeatInterval()*3/2 {
+		return false, true, fmt.Sprintf("no message from server for %s", silence.Round(time.Second))
+	}
+	if failures > 0 {
+		return false, true, fmt.Sprintf("%d send failures on %s", failures, mode)
+	}
+	return false, false, ""
+}
+
+// reconnect 以指数退避重连，直到恢复会话、重新加入或达到最大次数
+// 自动模式下首次在当前传输上重试，之后依次切换到下一个候选
+func (c *Client) reconnect(reason string) error {
+	c.failoverMutex.Lock()
+	defer c.failoverMutex.Unlock()
+
+	c.setConnectionState(StateReconnecting, reason, nil)
+	if !c.config.AutoReconnect {
+		c.setConnectionState(StateFailed, "auto reconnect disabled", nil)
+		return fmt.Errorf("connection lost: %s", reason)
+	}
+
+	auto, isAuto := c.transport.(*transport.AutoTransport)
+	delay := c.config.ReconnectDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	maxDelay := c.config.ReconnectMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	var lastErr error
+	for attempt := 1; c.config.MaxReconnectAttempts <= 0 || attempt <= c.config.MaxReconnectAttempts; attempt++ {
+		if !c.IsRunning() {
+			return fmt.Errorf("client stopped")
+		}
+
+		var err error
+		if isAuto && attempt > 1 {
+			err = auto.Advance()
+		}
+		if err == nil {
+			var resumed bool
+			if resumed, err = c.restoreSession(c.rejoinTimeout()); err == nil {
+				c.onReconnected(resumed, map[string]interface{}{"attempts": attempt})
+				return nil
+			}
+		}
+		lastErr = err
+		c.logger.Warn("[Client] Reconnect attempt %d via %s failed: %v", attempt, c.transport.GetMode(), lastErr)
+
+		select {
+		case <-c.ctx.Done():
+			return fmt.Errorf("client stopped")
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	c.setConnectionState(StateFailed, fmt.Sprintf("giving up after %d attempts: %v", c.config.MaxReconnectAttempts, lastErr), nil)
+	return fmt.Errorf("reconnect failed: %w", lastErr)
+}
+
+// Reconnect 手动重连（通常在 failed 状态下由用户触发）
+func (c *Client) Reconnect() error {
+	if !c.IsRunning() {
+		return fmt.Errorf("client is not running")
+	}
+	return c.reconnect("manual reconnect")
+}
+
+// restoreSession 重新建立连接并恢复会话：优先续连，令牌缺失或被拒绝时以原成员ID重新加入
+func (c *Client) restoreSession(timeout time.Duration) (resumed bool, err error) {
+	if caps, ok := transport.GetCapabilities(c.transport.GetMode()); ok && caps.NeedConnect && !c.transport.IsConnected() {
+		_ = c.transport.Disconnect()
+		if err := c.connectTransport(); err != nil {
+			return false, err
+		}
+	}
+
+	err = c.resumeSession(timeout)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, errNoResumeToken) && !errors.Is(err, errResumeRejected) {
+		return false, err
+	}
+	c.logger.Info("[Client] Session resume unavailable (%v), rejoining", err)
+	if err := c.joinChannel(timeout); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// onReconnected 会话恢复后：回到 joined 状态，立即同步、发送离线队列并重新通告本地缓存
+func (c *Client) onReconnected(resumed bool, data map[string]interface{}) {
+	c.markContact()
+	data["resumed"] = resumed
+	data["mode"] = c.transport.GetMode()
+	how := "rejoined"
+	if resumed {
+		how = "resumed"
+	}
+	c.setConnectionState(StateJoined, fmt.Sprintf("session %s via %s", how, c.transport.GetMode()), data)
+
+	c.syncManager.ForceSync()
+	go c.offlineQueue.Flush()
+	go c.fileManager.AdvertiseCache()
+}
+
+// resumeSigningBytes 续连请求的签名内容（与服务端一致）
+func resumeSigningBytes(memberID, requestID, token string, timestamp int64) []byte {
+	return []byte(fmt.Sprintf("auth.resume|%s|%s|%s|%d", memberID, requestID, token, timestamp))
+}
+
+// resumeSession 以续连令牌恢复原会话
+func (c *Client) resumeSession(timeout time.Duration) error {
+	memberID := c.GetMemberID()
+	c.mutex.RLock()
+	token := c.resumeToken
+	c.mutex.RUnlock()
+	if token == "" || memberID == "" {
+		return errNoResumeToken
+	}
+
+	// 新的临时密钥：服务端以其加密下发轮换后的令牌
+	ephPriv, ephPub, err := c.crypto.GenerateX25519KeyPair()
+	if err != nil {
+		return fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	requestID := generateMessageID()
+	timestamp := time.Now().Unix()
+	req := map[string]interface{}{
+		"type":             "auth.resume",
+		"request_id":       requestID,
+		"channel_id":       c.config.ChannelID,
+		"member_id":        memberID,
+		"resume_token":     token,
+		"ephemeral_pubkey": ephPub,
+		"timestamp":        timestamp,
+		"signature":        ed25519.Sign(c.privateKey, resumeSigningBytes(memberID, requestID, token, timestamp)),
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resume request: %w", err)
+	}
+	reqData, err := c.crypto.EncryptMessage(reqJSON)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt resume request: %w", err)
+	}
+
+	result := make(chan bool, 1)
+	c.mutex.Lock()
+	c.pendingJoinID = requestID
+	c.ephemeralKey = ephPriv
+	c.resumeResult = result
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		c.resumeResult = nil
+		c.mutex.Unlock()
+	}()
+
+	msg := &transport.Message{
+		Type:      transport.MessageTypeAuth,
+		SenderID:  memberID,
+		Payload:   reqData,
+		Timestamp: time.Now(),
+	}
+	if err := c.transport.SendMessage(msg); err != nil {
+		c.setPendingJoinID("")
+		return fmt.Errorf("failed to send resume request: %w", err)
+	}
+
+	select {
+	case ok := <-result:
+		if !ok {
+			c.setResumeToken("")
+			return errResumeRejected
+		}
+		return nil
+	case <-time.After(timeout):
+		c.setPendingJoinID("")
+		return fmt.Errorf("resume response timeout")
+	}
+}
+
+// handleResumeResponse 处理续连响应（由 ReceiveManager 调用）
+func (c *Client) handleResumeResponse(payload map[string]interface{}) {
+	requestID, _ := payload["request_id"].(string)
+	c.mutex.Lock()
+	pending := c.pendingJoinID
+	result := c.resumeResult
+	if pending == "" || requestID != pending || result == nil {
+		c.mutex.Unlock()
+		return
+	}
+	c.pendingJoinID = ""
+	c.mutex.Unlock()
+
+	success, _ := payload["success"].(bool)
+	if success {
+		c.storeResumeToken(payload)
+		c.stats.mutex.Lock()
+		c.stats.ConnectedAt = time.Now()
+		c.stats.mutex.Unlock()
+		c.logger.Info("[Client] Session resumed as: %s", c.GetMemberID())
+	} else {
+		errMsg, _ := payload["error"].(string)
+		c.logger.Warn("[Client] Session resume rejected: %s", errMsg)
+	}
+
+	select {
+	case result <- success:
+	default:
+	}
+}
+
+// storeResumeToken 解密并保存加入/续连响应中的续连令牌（响应不含令牌时清空）
+func (c *Client) storeResumeToken(payload map[string]interface{}) {
+	sealed := payloadBytes(payload["resume_token"])
+	serverEph := payloadBytes(payload["resume_ephemeral"])
+
+	c.mutex.Lock()
+	ephPriv := c.ephemeralKey
+	c.ephemeralKey = nil
+	c.mutex.Unlock()
+
+	token := ""
+	if len(sealed) > 0 && len(serverEph) > 0 && len(ephPriv) > 0 {
+		key, err := c.crypto.X25519SharedSecret(ephPriv, serverEph)
+		if err == nil {
+			plain, derr := c.crypto.AESDecrypt(sealed, key)
+			err = derr
+			token = string(plain)
+		}
+		if err != nil {
+			c.logger.Warn("[Client] Failed to open resume token: %v", err)
+		}
+	}
+	c.setResumeToken(token)
+}
+
+// setResumeToken 设置续连令牌
+func (c *Client) setResumeToken(token string) {
+	c.mutex.Lock()
+	c.resumeToken = token
+	c.mutex.Unlock()
+}
+
+// payloadBytes 解析 JSON 负载中的字节字段（[]byte 经 JSON 编码为 base64 字符串）
+func payloadBytes(v interface{}) []byte {
+	switch b := v.(type) {
+	case []byte:
+		return b
+	case string:
+		data, err := base64.StdEncoding.DecodeString(b)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+	return nil
+}
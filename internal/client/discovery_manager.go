@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -20,6 +21,10 @@ type DiscoveryManager struct {
 	servers      map[string]*DiscoveredServer
 	serversMutex sync.RWMutex
 
+	// 辅助发现源（HTTPS信标、mDNS），首次扫描时创建并复用，Stop 时停止
+	auxSources map[models.TransportMode]transport.Transport
+	auxMutex   sync.Mutex
+
 	// 统计信息
 	stats      DiscoveryStats
 	statsMutex sync.RWMutex
@@ -38,6 +43,12 @@ type DiscoveredServer struct {
 	DiscoveredAt    time.Time
 	LastSeenAt      time.Time
 	TXT             map[string]string // TXT记录
+
+	// 多模式合并信息：同一频道可能同时通过多种传输模式被发现
+	ChannelIDHash   string
+	CertFingerprint string
+	Modes           []models.TransportMode
+	Endpoints       map[models.TransportMode]string // 各模式下的连接地址
 }
 
 // DiscoveryStats 服务发现统计
@@ -51,10 +62,11 @@ type DiscoveryStats struct {
 func NewDiscoveryManager(client *Client) *DiscoveryManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &DiscoveryManager{
-		client:  client,
-		ctx:     ctx,
-		cancel:  cancel,
-		servers: make(map[string]*DiscoveredServer),
+		client:     client,
+		ctx:        ctx,
+		cancel:     cancel,
+		servers:    make(map[string]*DiscoveredServer),
+		auxSources: make(map[models.TransportMode]transport.Transport),
 	}
 }
 
@@ -69,11 +81,22 @@ func (dm *DiscoveryManager) Start() error {
 func (dm *DiscoveryManager) Stop() error {
 	dm.client.logger.Info("[DiscoveryManager] Stopping...")
 	dm.cancel()
+
+	dm.auxMutex.Lock()
+	for mode, t := range dm.auxSources {
+		if err := t.Stop(); err != nil {
+			dm.client.logger.Warn("[DiscoveryManager] Failed to stop %s discovery source: %v", mode, err)
+		}
+	}
+	dm.auxSources = make(map[models.TransportMode]transport.Transport)
+	dm.auxMutex.Unlock()
+
 	dm.client.logger.Info("[DiscoveryManager] Stopped")
 	return nil
 }
 
 // Discover 扫描局域网中的服务器
+// 同时查询当前传输层与无需特权的辅助发现源（HTTPS信标、mDNS），并按频道合并结果
 func (dm *DiscoveryManager) Discover(timeout time.Duration) ([]*DiscoveredServer, error) {
 	dm.client.logger.Info("[DiscoveryManager] Starting discovery scan...")
 
@@ -83,8 +106,8 @@ func (dm *DiscoveryManager) Discover(timeout time.Duration) ([]*DiscoveredServer
 	dm.stats.LastScanTime = time.Now()
 	dm.statsMutex.Unlock()
 
-	// 使用Transport的Discover方法
-	if dm.client.transport == nil {
+	sources := dm.discoverySources()
+	if len(sources) == 0 {
 		return nil, fmt.Errorf("transport not initialized")
 	}
 
@@ -92,19 +115,58 @@ func (dm *DiscoveryManager) Discover(timeout time.Duration) ([]*DiscoveredServer
 	ctx, cancel := context.WithTimeout(dm.ctx, timeout)
 	defer cancel()
 
-	// 扫描
-	discoveries, err := dm.discoverWithTimeout(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("discovery failed: %w", err)
+	// 并发扫描各发现源
+	type result struct {
+		peers []*transport.PeerInfo
+		err   error
+	}
+	results := make(chan result, len(sources))
+	for _, src := range sources {
+		go func(t transport.Transport) {
+			peers, err := dm.discoverWithTimeout(ctx, t)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", t.GetMode(), err)
+			}
+			results <- result{peers: peers, err: err}
+		}(src)
+	}
+
+	var discoveries []*transport.PeerInfo
+	var errs []error
+	for range sources {
+		r := <-results
+		if r.err != nil {
+			dm.client.logger.Debug("[DiscoveryManager] Discovery source failed: %v", r.err)
+			errs = append(errs, r.err)
+			continue
+		}
+		discoveries = append(discoveries, r.peers...)
+	}
+	if len(errs) == len(sources) {
+		return nil, fmt.Errorf("discovery failed: %w", errors.Join(errs...))
+	}
+
+	// 合并同一频道在不同模式下的发现结果
+	merged := make(map[string]*DiscoveredServer)
+	order := make([]string, 0, len(discoveries))
+	for _, peer := range discoveries {
+		server := dm.peerInfoToServer(peer)
+		key := mergeKey(server)
+		if existing, ok := merged[key]; ok {
+			mergeServer(existing, server)
+			continue
+		}
+		merged[key] = server
+		order = append(order, key)
 	}
 
 	// 处理发现的服务器
-	servers := make([]*DiscoveredServer, 0, len(discoveries))
+	servers := make([]*DiscoveredServer, 0, len(merged))
 	now := time.Now()
 
 	dm.serversMutex.Lock()
-	for _, peer := range discoveries {
-		server := dm.peerInfoToServer(peer)
+	for _, key := range order {
+		server := merged[key]
 		server.LastSeenAt = now
 
 		// 如果是新服务器，设置DiscoveredAt
@@ -127,8 +189,54 @@ func (dm *DiscoveryManager) Discover(timeout time.Duration) ([]*DiscoveredServer
 	return servers, nil
 }
 
+// discoverySources 返回本次扫描使用的发现源
+// ARP 需要已打开的抓包句柄，只能使用当前传输层；HTTPS信标与mDNS查询无需初始化即可使用
+func (dm *DiscoveryManager) discoverySources() []transport.Transport {
+	sources := make([]transport.Transport, 0, 3)
+	current := models.TransportMode("")
+	if dm.client.transport != nil {
+		sources = append(sources, dm.client.transport)
+		current = dm.client.transport.GetMode()
+	}
+	for _, mode := range []models.TransportMode{models.TransportHTTPS, models.TransportMDNS} {
+		if mode == current {
+			continue
+		}
+		if t := dm.auxSource(mode); t != nil {
+			sources = append(sources, t)
+		}
+	}
+	return sources
+}
+
+// auxSource 返回指定模式的辅助发现源，首次使用时创建并初始化
+func (dm *DiscoveryManager) auxSource(mode models.TransportMode) transport.Transport {
+	dm.auxMutex.Lock()
+	defer dm.auxMutex.Unlock()
+
+	if t, ok := dm.auxSources[mode]; ok {
+		return t
+	}
+
+	var t transport.Transport
+	switch mode {
+	case models.TransportHTTPS:
+		t = transport.NewHTTPSTransport()
+	case models.TransportMDNS:
+		t = transport.NewMDNSTransport()
+	default:
+		return nil
+	}
+	if err := t.Init(&transport.Config{Mode: mode, Logger: dm.client.logger}); err != nil {
+		dm.client.logger.Warn("[DiscoveryManager] Failed to init %s discovery source: %v", mode, err)
+		return nil
+	}
+	dm.auxSources[mode] = t
+	return t
+}
+
 // discoverWithTimeout 带超时的服务发现
-func (dm *DiscoveryManager) discoverWithTimeout(ctx context.Context) ([]*transport.PeerInfo, error) {
+func (dm *DiscoveryManager) discoverWithTimeout(ctx context.Context, t transport.Transport) ([]*transport.PeerInfo, error) {
 	// 创建结果channel
 	resultCh := make(chan []*transport.PeerInfo, 1)
 	errCh := make(chan error, 1)
@@ -139,7 +247,7 @@ func (dm *DiscoveryManager) discoverWithTimeout(ctx context.Context) ([]*transpo
 
 	// 异步执行发现
 	go func() {
-		peers, err := dm.client.transport.Discover(timeout)
+		peers, err := t.Discover(timeout)
 		if err != nil {
 			errCh <- err
 			return
@@ -160,21 +268,84 @@ func (dm *DiscoveryManager) discoverWithTimeout(ctx context.Context) ([]*transpo
 
 // peerInfoToServer 将PeerInfo转换为DiscoveredServer
 func (dm *DiscoveryManager) peerInfoToServer(peer *transport.PeerInfo) *DiscoveredServer {
+	name := peer.Name
+	if name == "" {
+		name = peer.ID // 未提供名称时使用ID
+	}
+	mode := peer.Mode
+	if mode == "" {
+		mode = models.TransportAuto
+	}
+
 	server := &DiscoveredServer{
 		ID:              peer.ID,
-		Name:            peer.ID, // 使用ID作为名称
+		Name:            name,
 		Address:         peer.Address,
-		TransportMode:   models.TransportAuto, // 默认值
-		ProtocolVersion: "1.0",
+		Port:            peer.Port,
+		TransportMode:   mode,
+		ProtocolVersion: fmt.Sprintf("%d.0", max(peer.Version, 1)),
+		MemberCount:     peer.MemberCount,
 		TXT:             make(map[string]string),
+		ChannelIDHash:   peer.ChannelIDHash,
+		CertFingerprint: peer.CertFingerprint,
+		Modes:           []models.TransportMode{mode},
+		Endpoints:       map[models.TransportMode]string{mode: peer.Address},
 	}
 
 	// 解析元数据（如果 transport 提供）
-	// 目前 PeerInfo 未包含 Metadata 字段，保留占位
+	for k, v := range peer.Metadata {
+		server.TXT[k] = v
+	}
 
 	return server
 }
 
+// mergeKey 计算合并键：优先使用频道哈希，否则退化为节点ID
+func mergeKey(server *DiscoveredServer) string {
+	if server.ChannelIDHash != "" {
+		return "channel:" + server.ChannelIDHash
+	}
+	return "peer:" + string(server.TransportMode) + ":" + server.ID
+}
+
+// mergeServer 将同一频道的另一条发现记录合并到已有记录
+// HTTPS 提供的信息最完整（名称、端口、指纹、成员数），优先作为主记录
+func mergeServer(dst, src *DiscoveredServer) {
+	for _, m := range src.Modes {
+		if _, ok := dst.Endpoints[m]; !ok {
+			dst.Modes = append(dst.Modes, m)
+		}
+		dst.Endpoints[m] = src.Endpoints[m]
+	}
+	for k, v := range src.TXT {
+		if _, ok := dst.TXT[k]; !ok {
+			dst.TXT[k] = v
+		}
+	}
+
+	placeholderName := dst.Name == "" || dst.Name == dst.ID
+	if src.TransportMode == models.TransportHTTPS && dst.TransportMode != models.TransportHTTPS {
+		dst.ID = src.ID
+		dst.Address = src.Address
+		dst.Port = src.Port
+		dst.TransportMode = src.TransportMode
+	}
+	if placeholderName {
+		if src.Name != "" && src.Name != src.ID {
+			dst.Name = src.Name
+		}
+	}
+	if dst.CertFingerprint == "" {
+		dst.CertFingerprint = src.CertFingerprint
+	}
+	if src.MemberCount > dst.MemberCount {
+		dst.MemberCount = src.MemberCount
+	}
+	if len(dst.ServerPublicKey) == 0 {
+		dst.ServerPublicKey = src.ServerPublicKey
+	}
+}
+
 // GetDiscoveredServers 获取已发现的服务器列表
 func (dm *DiscoveryManager) GetDiscoveredServers() []*DiscoveredServer {
 	dm.serversMutex.RLock()
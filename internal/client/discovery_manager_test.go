@@ -0,0 +1,84 @@
+package client
+
+import (
+	"testing"
+
+	"crosswire/internal/models"
+)
+
+func discovered(id string, mode models.TransportMode, name, addr string) *DiscoveredServer {
+	return &DiscoveredServer{
+		ID:            id,
+		Name:          name,
+		Address:       addr,
+		TransportMode: mode,
+		ChannelIDHash: "a1b2c3d4",
+		TXT:           map[string]string{},
+		Modes:         []models.TransportMode{mode},
+		Endpoints:     map[models.TransportMode]string{mode: addr},
+	}
+}
+
+func TestMergeServer(t *testing.T) {
+	// mDNS 记录先到，HTTPS 信标后到：HTTPS 成为主记录
+	dst := discovered("crosswire-abc", models.TransportMDNS, "crosswire-abc", "192.168.1.10:5353")
+	dst.TXT["version"] = "mdns"
+	dst.MemberCount = 2
+
+	src := discovered("192.168.1.10:8443", models.TransportHTTPS, "红队", "192.168.1.10:8443")
+	src.Port = 8443
+	src.CertFingerprint = "ff00"
+	src.MemberCount = 5
+	src.TXT["version"] = "https"
+	src.TXT["max_members"] = "50"
+
+	mergeServer(dst, src)
+
+	if dst.TransportMode != models.TransportHTTPS || dst.ID != src.ID || dst.Address != src.Address || dst.Port != 8443 {
+		t.Fatalf("primary not switched to https: %+v", dst)
+	}
+	if dst.Name != "红队" {
+		t.Fatalf("placeholder name not replaced: %q", dst.Name)
+	}
+	if dst.CertFingerprint != "ff00" || dst.MemberCount != 5 {
+		t.Fatalf("fingerprint=%q members=%d", dst.CertFingerprint, dst.MemberCount)
+	}
+	if len(dst.Modes) != 2 || dst.Endpoints[models.TransportMDNS] == "" || dst.Endpoints[models.TransportHTTPS] != src.Address {
+		t.Fatalf("modes=%v endpoints=%v", dst.Modes, dst.Endpoints)
+	}
+	// 已有的 TXT 键保持不变，缺失的键补齐
+	if dst.TXT["version"] != "mdns" || dst.TXT["max_members"] != "50" {
+		t.Fatalf("txt = %v", dst.TXT)
+	}
+}
+
+func TestMergeServerKeepsHTTPSPrimary(t *testing.T) {
+	dst := discovered("192.168.1.10:8443", models.TransportHTTPS, "红队", "192.168.1.10:8443")
+	dst.MemberCount = 5
+	src := discovered("crosswire-abc", models.TransportMDNS, "另一个名称", "192.168.1.10:5353")
+	src.MemberCount = 1
+
+	mergeServer(dst, src)
+	mergeServer(dst, src) // 重复合并不产生重复模式
+
+	if dst.TransportMode != models.TransportHTTPS || dst.ID != "192.168.1.10:8443" {
+		t.Fatalf("https primary replaced: %+v", dst)
+	}
+	if dst.Name != "红队" || dst.MemberCount != 5 {
+		t.Fatalf("name=%q members=%d", dst.Name, dst.MemberCount)
+	}
+	if len(dst.Modes) != 2 {
+		t.Fatalf("modes = %v", dst.Modes)
+	}
+}
+
+func TestMergeKey(t *testing.T) {
+	s := discovered("peer-1", models.TransportARP, "", "aa:bb")
+	if got := mergeKey(s); got != "channel:a1b2c3d4" {
+		t.Fatalf("mergeKey = %q", got)
+	}
+	s.ChannelIDHash = ""
+	if got := mergeKey(s); got != "peer:arp:peer-1" {
+		t.Fatalf("mergeKey = %q", got)
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 本地文件缓存与对等分发
+// 下载完成的文件按 SHA-256 保存在本地内容存储（<DataDir>/blobs/<channelID>/），
+// 加入频道时与每次下载完成后以 file.have 向服务端通告（附带对等端点）；
+// 其他成员按服务端的分派直接连接本端拉取分块（见 file_peer.go）。
+// 参考: docs/PROTOCOL.md - 5.2.7 对等分发
+
+// cacheHas 检查内容是否已在本地缓存
+func (fm *FileManager) cacheHas(hash string) bool {
+	return fm.client.db.GetBlobStore().Has(hash)
+}
+
+// exportFromCache 把缓存内容复制到任务的保存路径（文件包任务解出所选条目），并让文件记录指向缓存
+func (fm *FileManager) exportFromCache(task *FileDownloadTask) error {
+	blobs := fm.client.db.GetBlobStore()
+
+	if file, err := fm.client.fileRepo.GetByID(task.FileID); err == nil && file != nil && file.StoragePath == "" {
+		file.StoragePath = blobs.Path(task.SHA256)
+		if err := fm.client.fileRepo.Update(file); err != nil {
+			fm.client.logger.Warn("[FileManager] Failed to update storage path of %s: %v", task.FileID, err)
+		}
+	}
+
+	if task.SavePath == "" {
+		return nil
+	}
+	if task.Bundle != nil {
+		return fm.extractBundle(task)
+	}
+
+	src, err := blobs.Open(task.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to open cached file: %w", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(task.SavePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	dst, err := os.Create(task.SavePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return dst.Close()
+}
+
+// AdvertiseCache 向服务端通告本地缓存的全部内容（替换此前的通告）
+func (fm *FileManager) AdvertiseCache() {
+	hashes, err := fm.client.db.GetBlobStore().List()
+	if err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to list cached files: %v", err)
+		return
+	}
+	if err := fm.sendHave(hashes, nil, true); err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to advertise cached files: %v", err)
+		return
+	}
+	fm.client.logger.Debug("[FileManager] Advertised %d cached file(s)", len(hashes))
+}
+
+// announceCached 通告新缓存的内容
+func (fm *FileManager) announceCached(hash string) {
+	if hash == "" {
+		return
+	}
+	if err := fm.sendHave([]string{hash}, nil, false); err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to announce cached file: %v", err)
+	}
+}
+
+// announceDropped 通告不再持有的内容
+func (fm *FileManager) announceDropped(hash string) {
+	if hash == "" {
+		return
+	}
+	if err := fm.sendHave(nil, []string{hash}, false); err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to announce dropped file: %v", err)
+	}
+}
+
+// sendHave 发送 file.have 通告
+func (fm *FileManager) sendHave(hashes, dropped []string, full bool) error {
+	if hashes == nil {
+		hashes = []string{}
+	}
+	announce := map[string]interface{}{
+		"type":      "file.have",
+		"hashes":    hashes,
+		"dropped":   dropped,
+		"full":      full,
+		"timestamp": time.Now().Unix(),
+	}
+	if addr := fm.peerAddr(); addr != "" {
+		announce["peer_addr"] = addr
+	}
+
+	payload, err := json.Marshal(announce)
+	if err != nil {
+		return fmt.Errorf("failed to marshal file.have: %w", err)
+	}
+	return fm.client.sendControlMessage(payload)
+}
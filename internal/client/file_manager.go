@@ -6,10 +6,12 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"mime"
+	"net"
 	"os"
 	"path/filepath"
 	"sync"
@@ -17,11 +19,19 @@ import (
 
 	"crosswire/internal/events"
 	"crosswire/internal/models"
+	"crosswire/internal/storage"
 	"crosswire/internal/transport"
 
 	"github.com/google/uuid"
 )
 
+// 下载进度检查
+const (
+	downloadCheckInterval = 200 * time.Millisecond
+	downloadStallTimeout  = 3 * time.Second // 持续无新分块多久后向服务端补要缺失分块
+	downloadMaxRetries    = 5
+)
+
 // FileManager 文件传输管理器
 type FileManager struct {
 	client *Client
@@ -39,6 +49,25 @@ type FileManager struct {
 	// 统计信息
 	stats      FileManagerStats
 	statsMutex sync.RWMutex
+
+	// 上传状态查询（request_id -> 等待者）
+	statusWaiters map[string]chan *UploadStatusReport
+	statusMutex   sync.Mutex
+
+	// 对等端点（为其他成员提供缓存分块）
+	peerListener net.Listener
+	peerSlots    chan struct{}
+}
+
+// UploadStatusReport 服务端对上传状态查询（file.status）的应答
+type UploadStatusReport struct {
+	FileID         string              `json:"file_id"`
+	RequestID      string              `json:"request_id"`
+	Found          bool                `json:"found"` // 服务端是否已登记该文件（否则需重发元数据）
+	Status         models.UploadStatus `json:"upload_status"`
+	TotalChunks    int                 `json:"total_chunks"`
+	UploadedChunks int                 `json:"uploaded_chunks"`
+	ChunkBitmap    []byte              `json:"chunk_bitmap"` // 服务端已接收分块位图
 }
 
 // FileUploadTask 文件上传任务
@@ -53,6 +82,8 @@ type FileUploadTask struct {
 	UploadedChunks int
 	Status         models.UploadStatus
 	SHA256         string
+	ChallengeID    string                 // 所属题目（为空表示主频道），服务端据此在题目聊天室展示并分析附件
+	Bundle         *models.BundleManifest // 目录上传的文件包清单（普通文件为 nil）
 	StartTime      time.Time
 	EndTime        *time.Time
 	Error          error
@@ -75,15 +106,23 @@ type FileDownloadTask struct {
 	ChunkSize      int
 	TotalChunks    int
 	ReceivedChunks int
+	PeerChunks     int // 由其他成员缓存直接提供的分块数
+	FromCache      bool
+	Bundle         *models.BundleManifest // 非 nil 时完成后解出条目到 SavePath 目录，而非保存整个包
+	Entries        []string               // 要解出的条目（为空表示全部）
 	Status         DownloadStatus
 	SHA256         string
 	StartTime      time.Time
 	EndTime        *time.Time
 	Error          error
 
-	// 分块数据
-	chunks      map[int][]byte
+	// 已接收分块：索引 -> 来源（server 或持有者成员ID）；内容按偏移写入本地缓存的临时文件
+	chunks      map[int]string
+	wanted      map[int]bool // 文件包只解出部分条目时需要的分块（nil 表示全部分块）
+	lastChunkAt time.Time
+	serverOnly  bool // 整体校验失败后不再向持有者请求
 	chunksMutex sync.RWMutex
+	mutex       sync.RWMutex // 保护 Status/Error/EndTime
 
 	// 进度回调
 	OnProgress func(task *FileDownloadTask)
@@ -109,6 +148,10 @@ type FileManagerStats struct {
 	FailedDownloads     int64
 	BytesUploaded       int64
 	BytesDownloaded     int64
+	CacheHits           int64 // 命中本地缓存的下载次数
+	PeerChunksReceived  int64 // 由其他成员提供的分块数
+	PeerChunksServed    int64 // 为其他成员提供的分块数
+	PeerChunksRejected  int64 // 未通过分块哈希校验而丢弃的对端分块数
 	mutex               sync.RWMutex
 }
 
@@ -116,11 +159,13 @@ type FileManagerStats struct {
 func NewFileManager(client *Client) *FileManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &FileManager{
-		client:    client,
-		ctx:       ctx,
-		cancel:    cancel,
-		uploads:   make(map[string]*FileUploadTask),
-		downloads: make(map[string]*FileDownloadTask),
+		client:        client,
+		ctx:           ctx,
+		cancel:        cancel,
+		uploads:       make(map[string]*FileUploadTask),
+		downloads:     make(map[string]*FileDownloadTask),
+		statusWaiters: make(map[string]chan *UploadStatusReport),
+		peerSlots:     make(chan struct{}, peerMaxSessions),
 	}
 }
 
@@ -134,6 +179,13 @@ func (fm *FileManager) Start() error {
 	fm.client.eventBus.Subscribe(events.EventFileDownloadProgress, fm.handleFileReceived)
 	fm.client.eventBus.Subscribe(events.EventFileDownloadCompleted, fm.handleFileReceived)
 
+	// 监听对等端点并通告本地缓存，供其他成员下载时分担分块
+	if err := fm.startPeerListener(); err != nil {
+		// 无法监听时仍可正常下载，只是不为其他成员提供分块
+		fm.client.logger.Warn("[FileManager] Peer exchange disabled: %v", err)
+	}
+	go fm.AdvertiseCache()
+
 	fm.client.logger.Info("[FileManager] Started successfully")
 	return nil
 }
@@ -142,23 +194,30 @@ func (fm *FileManager) Start() error {
 func (fm *FileManager) Stop() error {
 	fm.client.logger.Info("[FileManager] Stopping...")
 	fm.cancel()
+	if fm.peerListener != nil {
+		fm.peerListener.Close()
+	}
 
 	// 取消所有进行中的任务
 	fm.uploadsMutex.Lock()
 	for _, task := range fm.uploads {
+		task.mutex.Lock()
 		if task.Status == models.UploadStatusUploading {
 			task.Status = models.UploadStatusFailed
 			task.Error = fmt.Errorf("cancelled")
 		}
+		task.mutex.Unlock()
 	}
 	fm.uploadsMutex.Unlock()
 
 	fm.downloadsMutex.Lock()
 	for _, task := range fm.downloads {
+		task.mutex.Lock()
 		if task.Status == DownloadStatusDownloading {
 			task.Status = DownloadStatusFailed
 			task.Error = fmt.Errorf("cancelled")
 		}
+		task.mutex.Unlock()
 	}
 	fm.downloadsMutex.Unlock()
 
@@ -168,30 +227,42 @@ func (fm *FileManager) Stop() error {
 
 // UploadFile 上传文件
 func (fm *FileManager) UploadFile(filePath string) (*FileUploadTask, error) {
+	return fm.UploadFileToChallenge(filePath, "")
+}
+
+// UploadFileToChallenge 上传文件到题目聊天室（challengeID 为空时等同于 UploadFile）
+func (fm *FileManager) UploadFileToChallenge(filePath, challengeID string) (*FileUploadTask, error) {
 	fm.client.logger.Info("[FileManager] Uploading file: %s", filePath)
 
 	// 1. 打开文件
+	// 文件句柄交由 executeUpload 关闭，此处仅在出错时关闭
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	// 2. 获取文件信息
+	// 2. 获取文件信息（目录请使用 UploadFolder）
 	stat, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%s is a folder, use UploadFolder", filePath)
+	}
 
 	// 3. 计算文件哈希
 	hasher := sha256.New()
 	if _, err := io.Copy(hasher, file); err != nil {
+		file.Close()
 		return nil, fmt.Errorf("failed to calculate file hash: %w", err)
 	}
 	fileHash := hex.EncodeToString(hasher.Sum(nil))
 
 	// 重置文件指针
 	if _, err := file.Seek(0, 0); err != nil {
+		file.Close()
 		return nil, fmt.Errorf("failed to seek file: %w", err)
 	}
 
@@ -210,6 +281,7 @@ func (fm *FileManager) UploadFile(filePath string) (*FileUploadTask, error) {
 		UploadedChunks: 0,
 		Status:         models.UploadStatusPending,
 		SHA256:         fileHash,
+		ChallengeID:    challengeID,
 		StartTime:      time.Now(),
 		chunkStatus:    make([]bool, totalChunks),
 	}
@@ -225,13 +297,17 @@ func (fm *FileManager) UploadFile(filePath string) (*FileUploadTask, error) {
 	fm.statsMutex.Unlock()
 
 	// 7. 异步执行上传
-	go fm.executeUpload(task, file)
+	go fm.executeUpload(task, file, true)
 
 	return task, nil
 }
 
-// executeUpload 执行文件上传
-func (fm *FileManager) executeUpload(task *FileUploadTask, file *os.File) {
+// executeUpload 执行文件上传（sendMetadata 为 false 时服务端已登记该文件，仅补发分块）
+func (fm *FileManager) executeUpload(task *FileUploadTask, file *os.File, sendMetadata bool) {
+	if task.Bundle != nil {
+		// defer 逆序执行：先关闭文件，再提交或丢弃打包内容
+		defer fm.finishBundle(task)
+	}
 	defer file.Close()
 
 	task.mutex.Lock()
@@ -241,13 +317,64 @@ func (fm *FileManager) executeUpload(task *FileUploadTask, file *os.File) {
 	fm.client.logger.Debug("[FileManager] Starting upload task: %s", task.ID)
 
 	// 1. 发送文件元数据
-	if err := fm.sendFileMetadata(task); err != nil {
-		fm.pauseUpload(task, fmt.Errorf("failed to send metadata: %w", err))
+	if sendMetadata {
+		if err := fm.sendFileMetadata(task); err != nil {
+			fm.pauseUpload(task, fmt.Errorf("failed to send metadata: %w", err))
+			return
+		}
+	}
+
+	// 2. 并行分块上传
+	if err := fm.uploadChunks(task, file); err != nil {
+		if !task.isFailed() {
+			fm.pauseUpload(task, err)
+		}
 		return
 	}
 
-	// 2. 分块上传
-	buffer := make([]byte, task.ChunkSize)
+	// 3. 发送完成消息
+	if err := fm.sendFileComplete(task); err != nil {
+		fm.pauseUpload(task, fmt.Errorf("failed to send complete: %w", err))
+		return
+	}
+
+	// 4. 标记成功（上传过程中被服务端拒绝的不再标记）
+	if task.isFailed() {
+		return
+	}
+	fm.completeUpload(task)
+	fm.deleteUploadTaskState(task.ID) // 删除持久化状态
+}
+
+// uploadChunks 由多个工作协程并行发送尚未上传的分块（服务端按偏移写入，不要求有序）
+func (fm *FileManager) uploadChunks(task *FileUploadTask, file *os.File) error {
+	ctx, cancel := context.WithCancel(fm.ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	pending := make(chan int)
+	for w := 0; w < fm.uploadWorkers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buffer := make([]byte, task.ChunkSize)
+			for chunkIndex := range pending {
+				if err := fm.uploadChunk(task, file, chunkIndex, buffer); err != nil {
+					failOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+feed:
 	for chunkIndex := 0; chunkIndex < task.TotalChunks; chunkIndex++ {
 		// 检查是否已上传
 		task.mutex.RLock()
@@ -259,67 +386,76 @@ func (fm *FileManager) executeUpload(task *FileUploadTask, file *os.File) {
 			continue
 		}
 
-		// 检查取消
 		select {
-		case <-fm.ctx.Done():
-			fm.pauseUpload(task, fmt.Errorf("cancelled"))
-			return
-		default:
-		}
-
-		// 定位到正确的文件位置
-		offset := int64(chunkIndex) * int64(task.ChunkSize)
-		if _, err := file.Seek(offset, 0); err != nil {
-			fm.pauseUpload(task, fmt.Errorf("failed to seek chunk %d: %w", chunkIndex, err))
-			return
-		}
-
-		// 读取分块
-		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			fm.pauseUpload(task, fmt.Errorf("failed to read chunk %d: %w", chunkIndex, err))
-			return
+		case pending <- chunkIndex:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(pending)
+	wg.Wait()
 
-		chunkData := buffer[:n]
+	if firstErr != nil {
+		return firstErr
+	}
+	if fm.ctx.Err() != nil {
+		return fmt.Errorf("cancelled")
+	}
+	return nil
+}
 
-		// 计算分块哈希
-		chunkHash := sha256.Sum256(chunkData)
+// uploadChunk 读取并发送单个分块（buffer 为工作协程私有）
+func (fm *FileManager) uploadChunk(task *FileUploadTask, file *os.File, chunkIndex int, buffer []byte) error {
+	// 已被拒绝或取消的任务不再发送
+	if task.isFailed() {
+		task.mutex.RLock()
+		defer task.mutex.RUnlock()
+		return task.Error
+	}
 
-		// 发送分块
-		if err := fm.sendFileChunk(task, chunkIndex, chunkData, hex.EncodeToString(chunkHash[:])); err != nil {
-			fm.pauseUpload(task, fmt.Errorf("failed to send chunk %d: %w", chunkIndex, err))
-			return
-		}
+	// 读取分块（ReadAt 不共享文件偏移，可并发调用）
+	offset := int64(chunkIndex) * int64(task.ChunkSize)
+	n, err := file.ReadAt(buffer, offset)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read chunk %d: %w", chunkIndex, err)
+	}
+	chunkData := buffer[:n]
 
-		// 更新状态
-		task.mutex.Lock()
-		task.chunkStatus[chunkIndex] = true
-		task.UploadedChunks++
-		task.mutex.Unlock()
+	// 计算分块哈希
+	chunkHash := sha256.Sum256(chunkData)
 
-		// 持久化任务状态（每10个分块保存一次）
-		if chunkIndex%10 == 0 || chunkIndex == task.TotalChunks-1 {
-			fm.saveUploadTaskState(task)
-		}
+	// 发送分块
+	if err := fm.sendFileChunk(task, chunkIndex, chunkData, hex.EncodeToString(chunkHash[:])); err != nil {
+		return fmt.Errorf("failed to send chunk %d: %w", chunkIndex, err)
+	}
 
-		// 触发进度回调
-		if task.OnProgress != nil {
-			task.OnProgress(task)
-		}
+	// 更新状态
+	task.mutex.Lock()
+	task.chunkStatus[chunkIndex] = true
+	task.UploadedChunks++
+	uploaded := task.UploadedChunks
+	task.mutex.Unlock()
 
-		fm.client.logger.Debug("[FileManager] Upload progress: %d/%d chunks", task.UploadedChunks, task.TotalChunks)
+	// 持久化任务状态（每10个分块保存一次）
+	if uploaded%10 == 1 || uploaded == task.TotalChunks {
+		fm.saveUploadTaskState(task)
 	}
 
-	// 3. 发送完成消息
-	if err := fm.sendFileComplete(task); err != nil {
-		fm.pauseUpload(task, fmt.Errorf("failed to send complete: %w", err))
-		return
+	// 触发进度回调
+	if task.OnProgress != nil {
+		task.OnProgress(task)
 	}
 
-	// 4. 标记成功
-	fm.completeUpload(task)
-	fm.deleteUploadTaskState(task.ID) // 删除持久化状态
+	fm.client.logger.Debug("[FileManager] Upload progress: %d/%d chunks", uploaded, task.TotalChunks)
+	return nil
+}
+
+// uploadWorkers 并行上传的工作协程数
+func (fm *FileManager) uploadWorkers() int {
+	if fm.client.config.UploadWorkers > 0 {
+		return fm.client.config.UploadWorkers
+	}
+	return 1
 }
 
 // sendFileMetadata 发送文件元数据
@@ -335,26 +471,19 @@ func (fm *FileManager) sendFileMetadata(task *FileUploadTask) error {
 		"total_chunks": task.TotalChunks,
 		"timestamp":    time.Now().Unix(),
 	}
+	if task.ChallengeID != "" {
+		metadata["challenge_id"] = task.ChallengeID
+	}
+	if task.Bundle != nil {
+		metadata["bundle"] = task.Bundle
+	}
 
 	payload, err := json.Marshal(metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	encrypted, err := fm.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt metadata: %w", err)
-	}
-
-	msg := &transport.Message{
-		ID:        uuid.New().String(),
-		Type:      transport.MessageTypeData,
-		SenderID:  fm.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
-	}
-
-	return fm.client.transport.SendMessage(msg)
+	return fm.client.sendControlMessage(payload)
 }
 
 // sendFileChunk 发送文件分块
@@ -374,20 +503,7 @@ func (fm *FileManager) sendFileChunk(task *FileUploadTask, chunkIndex int, data
 		return fmt.Errorf("failed to marshal chunk: %w", err)
 	}
 
-	encrypted, err := fm.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt chunk: %w", err)
-	}
-
-	msg := &transport.Message{
-		ID:        uuid.New().String(),
-		Type:      transport.MessageTypeData,
-		SenderID:  fm.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
-	}
-
-	return fm.client.transport.SendMessage(msg)
+	return fm.client.sendControlMessage(payload)
 }
 
 // sendFileComplete 发送完成消息
@@ -403,20 +519,7 @@ func (fm *FileManager) sendFileComplete(task *FileUploadTask) error {
 		return fmt.Errorf("failed to marshal complete: %w", err)
 	}
 
-	encrypted, err := fm.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt complete: %w", err)
-	}
-
-	msg := &transport.Message{
-		ID:        uuid.New().String(),
-		Type:      transport.MessageTypeControl,
-		SenderID:  fm.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
-	}
-
-	return fm.client.transport.SendMessage(msg)
+	return fm.client.sendControlMessage(payload)
 }
 
 // failUpload 标记上传失败
@@ -478,6 +581,8 @@ func (fm *FileManager) completeUpload(task *FileUploadTask) {
 }
 
 // DownloadFile 下载文件
+// 内容已在本地缓存（按 SHA-256）时直接复制到 savePath，否则向服务端请求，
+// 服务端可将部分分块分派给持有缓存的其他成员
 func (fm *FileManager) DownloadFile(fileID string, savePath string) (*FileDownloadTask, error) {
 	fm.client.logger.Info("[FileManager] Downloading file: %s", fileID)
 
@@ -487,10 +592,18 @@ func (fm *FileManager) DownloadFile(fileID string, savePath string) (*FileDownlo
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
 
-	// 2. 创建下载任务
-	task := &FileDownloadTask{
+	// 2. 创建并启动下载任务
+	task := fm.newDownloadTask(fileInfo, savePath)
+	fm.startDownload(task)
+
+	return task, nil
+}
+
+// newDownloadTask 根据文件记录创建下载任务
+func (fm *FileManager) newDownloadTask(fileInfo *models.File, savePath string) *FileDownloadTask {
+	return &FileDownloadTask{
 		ID:             uuid.New().String(),
-		FileID:         fileID,
+		FileID:         fileInfo.ID,
 		Filename:       fileInfo.Filename,
 		Size:           fileInfo.Size,
 		SavePath:       savePath,
@@ -500,42 +613,62 @@ func (fm *FileManager) DownloadFile(fileID string, savePath string) (*FileDownlo
 		Status:         DownloadStatusPending,
 		SHA256:         fileInfo.SHA256,
 		StartTime:      time.Now(),
-		chunks:         make(map[int][]byte),
+		chunks:         make(map[int]string),
 	}
+}
 
-	// 3. 注册任务
+// startDownload 注册下载任务并异步执行
+func (fm *FileManager) startDownload(task *FileDownloadTask) {
 	fm.downloadsMutex.Lock()
 	fm.downloads[task.ID] = task
 	fm.downloadsMutex.Unlock()
 
-	// 4. 更新统计
 	fm.statsMutex.Lock()
 	fm.stats.TotalDownloads++
 	fm.statsMutex.Unlock()
 
-	// 5. 异步执行下载
 	go fm.executeDownload(task)
-
-	return task, nil
 }
 
 // executeDownload 执行文件下载
 func (fm *FileManager) executeDownload(task *FileDownloadTask) {
+	task.mutex.Lock()
 	task.Status = DownloadStatusDownloading
+	task.mutex.Unlock()
 	fm.client.logger.Debug("[FileManager] Starting download task: %s", task.ID)
 
-	// 1. 请求文件数据
-	if err := fm.requestFileData(task); err != nil {
-		fm.failDownload(task, fmt.Errorf("failed to request file: %w", err))
+	// 1. 命中本地缓存：无需网络传输
+	if fm.cacheHas(task.SHA256) {
+		task.FromCache = true
+		if err := fm.exportFromCache(task); err != nil {
+			fm.failDownload(task, fmt.Errorf("failed to export cached file: %w", err))
+			return
+		}
+		fm.statsMutex.Lock()
+		fm.stats.CacheHits++
+		fm.statsMutex.Unlock()
+		fm.completeDownload(task)
 		return
 	}
 
-	// 2. 等待所有分块接收完成（由 handleFileChunk 处理）
-	// 这里只是示例，实际需要实现等待机制
-	ticker := time.NewTicker(1 * time.Second)
+	// 2. 请求文件数据（恢复的任务只请求缺失分块）
+	task.chunksMutex.Lock()
+	task.lastChunkAt = time.Now()
+	task.chunksMutex.Unlock()
+	if missing := task.missingChunks(); len(missing) > 0 {
+		if err := fm.requestFileData(task, missing, true); err != nil {
+			fm.failDownload(task, fmt.Errorf("failed to request file: %w", err))
+			return
+		}
+	}
+
+	// 3. 等待分块到达（由 handleDownloadChunk 写入缓存临时文件）
+	// 持有者未响应或分块丢失时，持续无进展超过 downloadStallTimeout 后向服务端补要缺失分块
+	ticker := time.NewTicker(downloadCheckInterval)
 	defer ticker.Stop()
 
 	timeout := time.After(5 * time.Minute)
+	retries := 0
 
 	for {
 		select {
@@ -546,99 +679,205 @@ func (fm *FileManager) executeDownload(task *FileDownloadTask) {
 			fm.failDownload(task, fmt.Errorf("download timeout"))
 			return
 		case <-ticker.C:
+			if task.GetStatus() != DownloadStatusDownloading {
+				return
+			}
+
 			task.chunksMutex.RLock()
 			received := len(task.chunks)
+			stalled := time.Since(task.lastChunkAt) > downloadStallTimeout
 			task.chunksMutex.RUnlock()
 
-			if received >= task.TotalChunks {
+			if len(task.missingChunks()) == 0 {
 				// 所有分块接收完成
-				if err := fm.assembleFile(task); err != nil {
+				err := fm.assembleFile(task)
+				if errors.Is(err, storage.ErrBlobHashMismatch) && !task.serverOnly {
+					// 对端提供的内容无法通过整体校验：丢弃已接收分块，全部改由服务端发送
+					fm.client.logger.Warn("[FileManager] Download %s failed verification, refetching from server", task.ID)
+					task.chunksMutex.Lock()
+					task.chunks = make(map[int]string)
+					task.ReceivedChunks = 0
+					task.PeerChunks = 0
+					task.serverOnly = true
+					task.lastChunkAt = time.Now()
+					task.chunksMutex.Unlock()
+					if err := fm.requestFileData(task, task.missingChunks(), false); err != nil {
+						fm.failDownload(task, fmt.Errorf("failed to request file: %w", err))
+						return
+					}
+					continue
+				}
+				if err != nil {
 					fm.failDownload(task, fmt.Errorf("failed to assemble file: %w", err))
 					return
 				}
 				fm.completeDownload(task)
 				return
 			}
+
+			if stalled {
+				if retries >= downloadMaxRetries {
+					fm.failDownload(task, fmt.Errorf("download stalled at %d/%d chunks", received, task.neededChunks()))
+					return
+				}
+				retries++
+				missing := task.missingChunks()
+				fm.client.logger.Info("[FileManager] Download %s stalled, requesting %d missing chunk(s) from server", task.ID, len(missing))
+				task.chunksMutex.Lock()
+				task.lastChunkAt = time.Now()
+				task.chunksMutex.Unlock()
+				if err := fm.requestFileData(task, missing, false); err != nil {
+					fm.client.logger.Warn("[FileManager] Failed to request missing chunks: %v", err)
+				}
+			}
 		}
 	}
 }
 
 // requestFileData 请求文件数据
-func (fm *FileManager) requestFileData(task *FileDownloadTask) error {
+// chunks 为空表示请求整个文件；swarm 为 true 时允许服务端把部分分块分派给持有缓存的成员
+func (fm *FileManager) requestFileData(task *FileDownloadTask, chunks []int, swarm bool) error {
 	request := map[string]interface{}{
 		"type":         "file.request",
 		"file_id":      task.FileID,
 		"requester_id": fm.client.memberID,
+		"swarm":        swarm && !task.serverOnly,
 		"timestamp":    time.Now().Unix(),
 	}
+	if len(chunks) > 0 && len(chunks) < task.TotalChunks {
+		request["chunks"] = chunks
+	}
 
 	payload, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	encrypted, err := fm.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt request: %w", err)
-	}
+	return fm.client.sendControlMessage(payload)
+}
 
-	msg := &transport.Message{
-		ID:        uuid.New().String(),
-		Type:      transport.MessageTypeControl,
-		SenderID:  fm.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
+// handleDownloadChunk 登记下载分块并按偏移写入缓存临时文件，返回是否为新分块
+// source 为分块来源：server 或提供缓存的成员ID
+func (fm *FileManager) handleDownloadChunk(task *FileDownloadTask, index int, data []byte, source string) bool {
+	if index < 0 || index >= task.TotalChunks || task.GetStatus() != DownloadStatusDownloading ||
+		task.wanted != nil && !task.wanted[index] {
+		return false
 	}
 
-	return fm.client.transport.SendMessage(msg)
+	task.chunksMutex.Lock()
+	defer task.chunksMutex.Unlock()
+
+	if _, ok := task.chunks[index]; ok {
+		return false
+	}
+	blobs := fm.client.db.GetBlobStore()
+	if err := blobs.WriteAt(task.ID, int64(index)*int64(task.ChunkSize), data); err != nil {
+		fm.client.logger.Error("[FileManager] Failed to write chunk %d of %s: %v", index, task.FileID, err)
+		return false
+	}
+	task.chunks[index] = source
+	task.ReceivedChunks = len(task.chunks)
+	task.lastChunkAt = time.Now()
+	if source != "" && source != "server" {
+		task.PeerChunks++
+		fm.statsMutex.Lock()
+		fm.stats.PeerChunksReceived++
+		fm.statsMutex.Unlock()
+	}
+	return true
 }
 
-// assembleFile 组装文件
-func (fm *FileManager) assembleFile(task *FileDownloadTask) error {
-	fm.client.logger.Debug("[FileManager] Assembling file: %s", task.ID)
+// publishDownloadProgress 收到新分块后触发进度回调并发布下载进度事件
+func (fm *FileManager) publishDownloadProgress(task *FileDownloadTask, source string) {
+	if task.OnProgress != nil {
+		task.OnProgress(task)
+	}
 
-	// 1. 创建输出文件
-	file, err := os.Create(task.SavePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	task.chunksMutex.RLock()
+	received := task.ReceivedChunks
+	task.chunksMutex.RUnlock()
+	progress := int(float64(received) / float64(maxInt(task.TotalChunks, 1)) * 100)
+	var filePtr *models.File
+	if f, err := fm.client.fileRepo.GetByID(task.FileID); err == nil {
+		filePtr = f
 	}
-	defer file.Close()
 
-	// 2. 按顺序写入分块
+	fm.client.eventBus.Publish(events.EventFileDownloadProgress, events.FileEvent{
+		File:       filePtr,
+		ChannelID:  fm.client.config.ChannelID,
+		UploaderID: "",
+		Progress:   progress,
+	})
+
+	fm.client.logger.Debug("[FileManager] File download progress: %s [%d/%d] (%d%%) from %s",
+		task.FileID, received, task.TotalChunks, progress, source)
+}
+
+// missingChunks 返回尚未接收的分块索引（只解出部分条目时仅限所需分块）
+func (task *FileDownloadTask) missingChunks() []int {
 	task.chunksMutex.RLock()
 	defer task.chunksMutex.RUnlock()
 
-	hasher := sha256.New()
+	missing := make([]int, 0)
 	for i := 0; i < task.TotalChunks; i++ {
-		chunkData, ok := task.chunks[i]
-		if !ok {
-			return fmt.Errorf("missing chunk %d", i)
+		if task.wanted != nil && !task.wanted[i] {
+			continue
 		}
-
-		if _, err := file.Write(chunkData); err != nil {
-			return fmt.Errorf("failed to write chunk %d: %w", i, err)
+		if _, ok := task.chunks[i]; !ok {
+			missing = append(missing, i)
 		}
+	}
+	return missing
+}
 
-		if _, err := hasher.Write(chunkData); err != nil {
-			return fmt.Errorf("failed to hash chunk %d: %w", i, err)
-		}
+// neededChunks 返回本次下载需要的分块数
+func (task *FileDownloadTask) neededChunks() int {
+	if task.wanted != nil {
+		return len(task.wanted)
+	}
+	return task.TotalChunks
+}
+
+// GetStatus 获取下载状态
+func (task *FileDownloadTask) GetStatus() DownloadStatus {
+	task.mutex.RLock()
+	defer task.mutex.RUnlock()
+	return task.Status
+}
+
+// assembleFile 组装文件
+// 临时文件以文件消息中的 SHA-256 校验后移入本地缓存，再复制到保存路径；
+// 校验失败时返回 storage.ErrBlobHashMismatch（临时文件已丢弃）。
+// 只下载了部分分块的文件包直接从临时文件解出条目，不进入缓存
+func (fm *FileManager) assembleFile(task *FileDownloadTask) error {
+	fm.client.logger.Debug("[FileManager] Assembling file: %s", task.ID)
+
+	if task.wanted != nil {
+		return fm.extractPartialBundle(task)
 	}
 
-	// 3. 验证哈希
-	fileHash := hex.EncodeToString(hasher.Sum(nil))
-	if fileHash != task.SHA256 {
-		return fmt.Errorf("file hash mismatch: expected %s, got %s", task.SHA256, fileHash)
+	blobs := fm.client.db.GetBlobStore()
+	if _, err := blobs.Commit(task.ID, task.Size, task.SHA256); err != nil {
+		return err
 	}
+	fm.announceCached(task.SHA256)
 
-	return nil
+	return fm.exportFromCache(task)
 }
 
 // failDownload 标记下载失败
 func (fm *FileManager) failDownload(task *FileDownloadTask, err error) {
+	task.mutex.Lock()
 	task.Status = DownloadStatusFailed
 	task.Error = err
 	now := time.Now()
 	task.EndTime = &now
+	task.mutex.Unlock()
+
+	// 丢弃未完成的临时文件
+	if aerr := fm.client.db.GetBlobStore().Abort(task.ID); aerr != nil {
+		fm.client.logger.Warn("[FileManager] Failed to discard partial download %s: %v", task.ID, aerr)
+	}
 
 	fm.statsMutex.Lock()
 	fm.stats.FailedDownloads++
@@ -659,16 +898,24 @@ func (fm *FileManager) failDownload(task *FileDownloadTask, err error) {
 
 // completeDownload 标记下载完成
 func (fm *FileManager) completeDownload(task *FileDownloadTask) {
+	task.mutex.Lock()
 	task.Status = DownloadStatusCompleted
 	now := time.Now()
 	task.EndTime = &now
+	task.mutex.Unlock()
 
 	fm.statsMutex.Lock()
 	fm.stats.SuccessfulDownloads++
-	fm.stats.BytesDownloaded += task.Size
+	if !task.FromCache {
+		fm.stats.BytesDownloaded += task.Size
+	}
 	fm.statsMutex.Unlock()
 
-	fm.client.logger.Info("[FileManager] Download completed: %s (%d bytes)", task.ID, task.Size)
+	task.chunksMutex.RLock()
+	peerChunks := task.PeerChunks
+	task.chunksMutex.RUnlock()
+	fm.client.logger.Info("[FileManager] Download completed: %s (%d bytes, %d/%d chunks from peers, cached=%v)",
+		task.ID, task.Size, peerChunks, task.TotalChunks, task.FromCache)
 
 	// 发布完成事件
 	fileRecord, _ := fm.client.fileRepo.GetByID(task.FileID)
@@ -723,6 +970,10 @@ func (fm *FileManager) getOptimalChunkSize() int {
 	case models.TransportMDNS:
 		return 200 // 极小块
 	default:
+		// 其他注册的传输按 MTU 推算：分块经 base64 与加密封装后约膨胀至 2~3 倍
+		if caps, ok := transport.GetCapabilities(mode); ok && caps.MTU > 0 {
+			return min(caps.MTU/3, 32*1024)
+		}
 		return 32 * 1024 // 32KB
 	}
 }
@@ -743,16 +994,21 @@ func (fm *FileManager) GetDownloadTask(taskID string) (*FileDownloadTask, bool)
 	return task, ok
 }
 
-// GetDownloadTaskByFileID 通过文件ID获取下载任务
+// GetDownloadTaskByFileID 通过文件ID获取下载任务（优先返回进行中的任务）
 func (fm *FileManager) GetDownloadTaskByFileID(fileID string) (*FileDownloadTask, bool) {
 	fm.downloadsMutex.RLock()
 	defer fm.downloadsMutex.RUnlock()
+	var found *FileDownloadTask
 	for _, task := range fm.downloads {
-		if task != nil && task.FileID == fileID {
+		if task == nil || task.FileID != fileID {
+			continue
+		}
+		if task.GetStatus() == DownloadStatusDownloading {
 			return task, true
 		}
+		found = task
 	}
-	return nil, false
+	return found, found != nil
 }
 
 // GetStats 获取统计信息
@@ -769,6 +1025,10 @@ func (fm *FileManager) GetStats() FileManagerStats {
 		FailedDownloads:     fm.stats.FailedDownloads,
 		BytesUploaded:       fm.stats.BytesUploaded,
 		BytesDownloaded:     fm.stats.BytesDownloaded,
+		CacheHits:           fm.stats.CacheHits,
+		PeerChunksReceived:  fm.stats.PeerChunksReceived,
+		PeerChunksServed:    fm.stats.PeerChunksServed,
+		PeerChunksRejected:  fm.stats.PeerChunksRejected,
 	}
 }
 
@@ -786,33 +1046,41 @@ func (task *FileUploadTask) GetProgress() float64 {
 func (task *FileDownloadTask) GetProgress() float64 {
 	task.chunksMutex.RLock()
 	defer task.chunksMutex.RUnlock()
-	if task.TotalChunks == 0 {
+	needed := task.neededChunks()
+	if needed == 0 {
 		return 0
 	}
-	return float64(len(task.chunks)) / float64(task.TotalChunks)
+	return float64(len(task.chunks)) / float64(needed)
 }
 
 // ===== 断点续传功能 =====
 
 // ResumeUpload 恢复上传任务
+// 以服务端的分块位图为准（file.status 查询），只补发服务端缺少的分块；
+// 服务端重启后未完成的上传同样可以续传，服务端未登记该文件时重发元数据
 func (fm *FileManager) ResumeUpload(taskID string) error {
 	fm.client.logger.Info("[FileManager] Resuming upload task: %s", taskID)
 
-	// 1. 加载任务状态
+	// 1. 拒绝恢复仍在进行的任务
+	if active, ok := fm.GetUploadTask(taskID); ok {
+		active.mutex.RLock()
+		status := active.Status
+		active.mutex.RUnlock()
+		if status == models.UploadStatusUploading {
+			return fmt.Errorf("task already in progress")
+		}
+	}
+
+	// 2. 加载任务状态
 	task, err := fm.loadUploadTaskState(taskID)
 	if err != nil {
 		return fmt.Errorf("failed to load task state: %w", err)
 	}
 
-	// 2. 检查任务是否已完成
-	if task.Status == models.UploadStatusCompleted {
-		return fmt.Errorf("task already completed")
-	}
-
-	// 3. 打开文件
-	file, err := os.Open(task.FilePath)
+	// 3. 查询服务端已接收的分块
+	report, err := fm.QueryUploadStatus(taskID)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to query upload status: %w", err)
 	}
 
 	// 4. 注册任务
@@ -820,12 +1088,144 @@ func (fm *FileManager) ResumeUpload(taskID string) error {
 	fm.uploads[task.ID] = task
 	fm.uploadsMutex.Unlock()
 
-	// 5. 继续执行上传
-	go fm.executeUpload(task, file)
+	if report.Found && report.Status == models.UploadStatusCompleted {
+		fm.client.logger.Info("[FileManager] Upload already completed on server: %s", taskID)
+		fm.completeUpload(task)
+		fm.saveUploadTaskState(task)
+		return nil
+	}
+	task.applyServerStatus(report)
+
+	// 5. 打开文件
+	file, err := os.Open(task.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	fm.client.logger.Info("[FileManager] Resuming upload %s: server has %d/%d chunks",
+		taskID, task.UploadedChunks, task.TotalChunks)
+
+	// 6. 继续执行上传
+	go fm.executeUpload(task, file, !report.Found)
 
 	return nil
 }
 
+// QueryUploadStatus 向服务端查询文件的上传状态与已接收分块
+func (fm *FileManager) QueryUploadStatus(fileID string) (*UploadStatusReport, error) {
+	requestID := uuid.New().String()
+	ch := make(chan *UploadStatusReport, 1)
+
+	fm.statusMutex.Lock()
+	fm.statusWaiters[requestID] = ch
+	fm.statusMutex.Unlock()
+	defer func() {
+		fm.statusMutex.Lock()
+		delete(fm.statusWaiters, requestID)
+		fm.statusMutex.Unlock()
+	}()
+
+	query, err := json.Marshal(map[string]interface{}{
+		"type":       "file.status",
+		"file_id":    fileID,
+		"request_id": requestID,
+		"timestamp":  time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal status query: %w", err)
+	}
+	if err := fm.client.sendControlMessage(query); err != nil {
+		return nil, err
+	}
+
+	timeout := fm.client.config.SyncTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	select {
+	case report := <-ch:
+		return report, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("upload status query timed out")
+	case <-fm.ctx.Done():
+		return nil, fmt.Errorf("cancelled")
+	}
+}
+
+// handleFileStatus 处理服务端的上传状态应答（按 request_id 交给等待者，其他成员的应答忽略）
+func (fm *FileManager) handleFileStatus(data []byte) {
+	var report UploadStatusReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		fm.client.logger.Error("[FileManager] Failed to unmarshal file status: %v", err)
+		return
+	}
+
+	fm.statusMutex.Lock()
+	ch, ok := fm.statusWaiters[report.RequestID]
+	fm.statusMutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- &report:
+	default:
+	}
+}
+
+// handleFileRejected 处理服务端拒绝上传（file.rejected，如超出配额）：终止任务并标记失败
+func (fm *FileManager) handleFileRejected(data []byte) {
+	var rejection struct {
+		FileID string `json:"file_id"`
+		Reason string `json:"reason"`
+		Limit  int64  `json:"limit"`
+		Used   int64  `json:"used"`
+	}
+	if err := json.Unmarshal(data, &rejection); err != nil {
+		fm.client.logger.Error("[FileManager] Failed to unmarshal file rejection: %v", err)
+		return
+	}
+
+	// 其他成员的上传被拒绝时同样会收到（广播），按本地任务过滤
+	fm.uploadsMutex.RLock()
+	task, ok := fm.uploads[rejection.FileID]
+	fm.uploadsMutex.RUnlock()
+	if !ok {
+		return
+	}
+
+	err := fmt.Errorf("rejected by server: %s", rejection.Reason)
+	if rejection.Limit > 0 {
+		err = fmt.Errorf("rejected by server: %s (limit %d bytes, used %d bytes)", rejection.Reason, rejection.Limit, rejection.Used)
+	}
+	fm.failUpload(task, err)
+	fm.saveUploadTaskState(task)
+}
+
+// isFailed 任务是否已失败（被拒绝或取消）
+func (task *FileUploadTask) isFailed() bool {
+	task.mutex.RLock()
+	defer task.mutex.RUnlock()
+	return task.Status == models.UploadStatusFailed
+}
+
+// applyServerStatus 以服务端位图重建分块状态（服务端未登记文件时全部重传）
+func (task *FileUploadTask) applyServerStatus(report *UploadStatusReport) {
+	received := &models.File{TotalChunks: task.TotalChunks}
+	if report.Found {
+		received.ChunkBitmap = report.ChunkBitmap
+	}
+
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	task.UploadedChunks = 0
+	for i := range task.chunkStatus {
+		task.chunkStatus[i] = received.HasChunk(i)
+		if task.chunkStatus[i] {
+			task.UploadedChunks++
+		}
+	}
+}
+
 // ResumeDownload 恢复下载任务
 func (fm *FileManager) ResumeDownload(taskID string) error {
 	fm.client.logger.Info("[FileManager] Resuming download task: %s", taskID)
@@ -837,7 +1237,7 @@ func (fm *FileManager) ResumeDownload(taskID string) error {
 	}
 
 	// 2. 检查任务是否已完成
-	if task.Status == DownloadStatusCompleted {
+	if task.GetStatus() == DownloadStatusCompleted {
 		return fmt.Errorf("task already completed")
 	}
 
@@ -879,10 +1279,12 @@ func (fm *FileManager) pauseUpload(task *FileUploadTask, err error) {
 
 // pauseDownload 暂停下载（保存状态以便恢复）
 func (fm *FileManager) pauseDownload(task *FileDownloadTask, err error) {
+	task.mutex.Lock()
 	task.Status = "paused"
 	task.Error = err
 	now := time.Now()
 	task.EndTime = &now
+	task.mutex.Unlock()
 
 	// 保存任务状态
 	fm.saveDownloadTaskState(task)
@@ -905,6 +1307,14 @@ func (fm *FileManager) saveUploadTaskState(task *FileUploadTask) {
 	task.mutex.RLock()
 	defer task.mutex.RUnlock()
 
+	// 分块状态以位图保存，恢复时不再假设按序上传
+	bitmap := &models.File{TotalChunks: task.TotalChunks}
+	for i, done := range task.chunkStatus {
+		if done {
+			bitmap.MarkChunk(i)
+		}
+	}
+
 	// 创建或更新文件记录
 	file := &models.File{
 		ID:             task.ID,
@@ -921,22 +1331,68 @@ func (fm *FileManager) saveUploadTaskState(task *FileUploadTask) {
 		ChunkSize:      task.ChunkSize,
 		TotalChunks:    task.TotalChunks,
 		UploadedChunks: task.UploadedChunks,
+		ChunkBitmap:    bitmap.ChunkBitmap,
 		UploadStatus:   task.Status,
 		UploadedAt:     task.StartTime,
 	}
+	if task.ChallengeID != "" || task.Bundle != nil {
+		file.Metadata = models.JSONField{}
+		if task.ChallengeID != "" {
+			file.Metadata["challenge_id"] = task.ChallengeID
+		}
+		if task.Bundle != nil {
+			file.Metadata["bundle"] = task.Bundle
+		}
+	}
 
 	// 尝试更新，如果不存在则创建
 	existing, err := fm.client.fileRepo.GetByID(task.ID)
 	if err == nil && existing != nil {
-		fm.client.fileRepo.Update(file)
-	} else {
-		fm.client.fileRepo.Create(file)
+		err = fm.client.fileRepo.Update(file)
+	} else if err = fm.ensureUploadMessage(task); err == nil {
+		err = fm.client.fileRepo.Create(file)
+	}
+	if err != nil {
+		fm.client.logger.Error("[FileManager] Failed to save upload task state %s: %v", task.ID, err)
+		return
 	}
 
 	fm.client.logger.Debug("[FileManager] Saved upload task state: %s (%d/%d chunks)",
 		task.ID, task.UploadedChunks, task.TotalChunks)
 }
 
+// ensureUploadMessage 登记本地占位文件消息（调用方持有 task.mutex 读锁）
+// 文件记录外键引用消息，而服务端广播的文件消息（ID 与文件ID一致）可能尚未到达；到达后会覆盖占位
+func (fm *FileManager) ensureUploadMessage(task *FileUploadTask) error {
+	if existing, err := fm.client.messageRepo.GetByID(task.ID); err == nil && existing != nil {
+		return nil
+	}
+	msg := &models.Message{
+		ID:        task.ID,
+		ChannelID: fm.client.config.ChannelID,
+		SenderID:  fm.client.memberID,
+		Type:      models.MessageTypeFile,
+		Content: models.MessageContent{
+			"file_id":      task.ID,
+			"filename":     task.Filename,
+			"size":         task.Size,
+			"mime_type":    task.MimeType,
+			"sha256":       task.SHA256,
+			"chunk_size":   task.ChunkSize,
+			"total_chunks": task.TotalChunks,
+		},
+		Timestamp: task.StartTime,
+	}
+	if task.ChallengeID != "" {
+		msg.ChallengeID = task.ChallengeID
+		msg.RoomType = "challenge"
+	}
+	if task.Bundle != nil {
+		msg.Content["bundle"] = task.Bundle
+	}
+	return fm.client.messageRepo.Create(msg)
+}
+
 // loadUploadTaskState 从数据库加载上传任务状态
 func (fm *FileManager) loadUploadTaskState(taskID string) (*FileUploadTask, error) {
 	file, err := fm.client.fileRepo.GetByID(taskID)
@@ -959,10 +1415,16 @@ func (fm *FileManager) loadUploadTaskState(taskID string) (*FileUploadTask, erro
 		StartTime:      file.UploadedAt,
 		chunkStatus:    make([]bool, file.TotalChunks),
 	}
+	task.ChallengeID, _ = file.Metadata["challenge_id"].(string)
+	if _, ok := file.Metadata["bundle"]; ok {
+		if task.Bundle, err = bundleManifestOf(file); err != nil {
+			return nil, err
+		}
+	}
 
-	// 重建分块状态（假设前N个分块已上传）
-	for i := 0; i < file.UploadedChunks; i++ {
-		task.chunkStatus[i] = true
+	// 重建分块状态
+	for i := range task.chunkStatus {
+		task.chunkStatus[i] = file.HasChunk(i)
 	}
 
 	fm.client.logger.Debug("[FileManager] Loaded upload task state: %s (%d/%d chunks)",
@@ -996,6 +1458,8 @@ func (fm *FileManager) saveDownloadTaskState(task *FileDownloadTask) {
 		Received    []int          `json:"received"`
 		Status      DownloadStatus `json:"status"`
 		SHA256      string         `json:"sha256"`
+		Unpack      bool           `json:"unpack,omitempty"` // 文件包解出条目
+		Entries     []string       `json:"entries,omitempty"`
 		UpdatedAt   int64          `json:"updated_at"`
 	}
 
@@ -1016,8 +1480,10 @@ func (fm *FileManager) saveDownloadTaskState(task *FileDownloadTask) {
 		ChunkSize:   task.ChunkSize,
 		TotalChunks: task.TotalChunks,
 		Received:    received,
-		Status:      task.Status,
+		Status:      task.GetStatus(),
 		SHA256:      task.SHA256,
+		Unpack:      task.Bundle != nil,
+		Entries:     task.Entries,
 		UpdatedAt:   time.Now().Unix(),
 	}
 
@@ -1069,6 +1535,8 @@ func (fm *FileManager) loadDownloadTaskState(taskID string) (*FileDownloadTask,
 		Received    []int          `json:"received"`
 		Status      DownloadStatus `json:"status"`
 		SHA256      string         `json:"sha256"`
+		Unpack      bool           `json:"unpack,omitempty"`
+		Entries     []string       `json:"entries,omitempty"`
 	}
 
 	var st downloadState
@@ -1087,12 +1555,26 @@ func (fm *FileManager) loadDownloadTaskState(taskID string) (*FileDownloadTask,
 		ReceivedChunks: len(st.Received),
 		Status:         st.Status,
 		SHA256:         st.SHA256,
+		Entries:        st.Entries,
 		StartTime:      time.Now(),
-		chunks:         make(map[int][]byte),
+		chunks:         make(map[int]string),
 	}
-	// 仅恢复索引，不恢复实际分块内容（需要时可从网络重新请求缺失块）
+	if st.Unpack {
+		file, err := fm.client.fileRepo.GetByID(st.FileID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+		if task.Bundle, err = bundleManifestOf(file); err != nil {
+			return nil, err
+		}
+		if err := task.selectBundleChunks(); err != nil {
+			return nil, err
+		}
+	}
+	// 已接收分块的内容保存在缓存临时文件中，恢复后只请求缺失分块
+	// 临时文件丢失时组装校验失败，届时改由服务端重新发送全部分块
 	for _, idx := range st.Received {
-		task.chunks[idx] = []byte{} // 占位
+		task.chunks[idx] = "server"
 	}
 
 	fm.client.logger.Info("[FileManager] Loaded download task from cache: %s (%d/%d)", task.ID, task.ReceivedChunks, task.TotalChunks)
@@ -1127,8 +1609,12 @@ func (fm *FileManager) ListPendingUploads() ([]*FileUploadTask, error) {
 				StartTime:      f.UploadedAt,
 				chunkStatus:    make([]bool, f.TotalChunks),
 			}
-			for i := 0; i < f.UploadedChunks && i < len(t.chunkStatus); i++ {
-				t.chunkStatus[i] = true
+			t.ChallengeID, _ = f.Metadata["challenge_id"].(string)
+			if _, ok := f.Metadata["bundle"]; ok {
+				t.Bundle, _ = bundleManifestOf(f)
+			}
+			for i := range t.chunkStatus {
+				t.chunkStatus[i] = f.HasChunk(i)
 			}
 			tasks = append(tasks, t)
 		}
@@ -1168,8 +1654,13 @@ func (fm *FileManager) CancelDownload(taskID string) error {
 	}
 	fm.downloadsMutex.Unlock()
 
+	task.mutex.Lock()
 	task.Status = DownloadStatusFailed
 	task.Error = fmt.Errorf("cancelled by user")
+	task.mutex.Unlock()
+	if err := fm.client.db.GetBlobStore().Abort(task.ID); err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to discard partial download %s: %v", task.ID, err)
+	}
 
 	fm.client.logger.Info("[FileManager] Download task cancelled: %s", taskID)
 
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// 对等分块交换
+// 成员在 Config.PeerListenAddr 上监听 TCP 连接，为其他成员提供本地缓存中的分块。
+// 服务端把下载的一部分分块分派给持有者时，以 file.peer_assign 告知请求方持有者的端点
+// 与这些分块的 SHA-256，请求方直接连接持有者拉取并逐块校验，分块不经过服务端。
+// 连接上的每一帧为 4 字节长度（大端）+ 以频道密钥加密的 JSON，只有频道成员能发出请求或读懂应答。
+// 参考: docs/PROTOCOL.md - 5.2.7 对等分发
+
+const (
+	peerMaxFrame     = 4 << 20 // 单帧上限（加密后）
+	peerMaxChunkSize = 1 << 20 // 接受的最大分块大小
+	peerDialTimeout  = 3 * time.Second
+	peerIOTimeout    = 10 * time.Second // 每帧读写超时
+	peerMaxSessions  = 8                // 同时服务的请求数，超出时直接关闭连接，请求方改向服务端补要
+)
+
+// peerAssignment 服务端的对等分派（file.peer_assign）
+type peerAssignment struct {
+	FileID      string         `json:"file_id"`
+	SHA256      string         `json:"sha256"`
+	RequesterID string         `json:"requester_id"`
+	ChunkSize   int            `json:"chunk_size"`
+	TotalChunks int            `json:"total_chunks"`
+	Peers       []assignedPeer `json:"peers"`
+	ChunkHashes map[int]string `json:"chunk_hashes"`
+}
+
+// assignedPeer 分派中的一个持有者
+type assignedPeer struct {
+	MemberID string `json:"member_id"`
+	Addr     string `json:"addr"`
+	Chunks   []int  `json:"chunks"`
+}
+
+// peerFetchRequest 请求方发给持有者的分块请求
+type peerFetchRequest struct {
+	SHA256      string `json:"sha256"`
+	ChunkSize   int    `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	Chunks      []int  `json:"chunks"`
+}
+
+// peerChunkFrame 持有者逐块回传的分块
+type peerChunkFrame struct {
+	ChunkIndex int    `json:"chunk_index"`
+	Data       []byte `json:"data"`
+}
+
+// startPeerListener 开始监听对等端点（未配置监听地址时不提供分块）
+func (fm *FileManager) startPeerListener() error {
+	addr := fm.client.config.PeerListenAddr
+	if addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	fm.peerListener = ln
+	go fm.acceptPeers(ln)
+
+	fm.client.logger.Info("[FileManager] Serving cached chunks on %s", ln.Addr())
+	return nil
+}
+
+// peerAddr 返回随 file.have 通告的对等端点（未监听时为空）
+func (fm *FileManager) peerAddr() string {
+	if fm.peerListener == nil {
+		return ""
+	}
+	return fm.peerListener.Addr().String()
+}
+
+// acceptPeers 接受其他成员的连接
+func (fm *FileManager) acceptPeers(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fm.client.logger.Warn("[FileManager] Peer listener stopped: %v", err)
+			}
+			return
+		}
+		select {
+		case fm.peerSlots <- struct{}{}:
+			go func() {
+				defer func() { <-fm.peerSlots }()
+				fm.servePeer(conn)
+			}()
+		default:
+			conn.Close()
+		}
+	}
+}
+
+// servePeer 处理一个分块请求：从缓存读取并逐块回传（失败只记录日志，请求方会向服务端补要）
+func (fm *FileManager) servePeer(conn net.Conn) {
+	defer conn.Close()
+
+	var req peerFetchRequest
+	conn.SetDeadline(time.Now().Add(peerIOTimeout))
+	if err := fm.readPeerFrame(conn, &req); err != nil {
+		fm.client.logger.Debug("[FileManager] Invalid peer request from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	if req.ChunkSize <= 0 || req.ChunkSize > peerMaxChunkSize || req.TotalChunks <= 0 || len(req.Chunks) > req.TotalChunks {
+		return
+	}
+
+	content, err := fm.client.db.GetBlobStore().Open(req.SHA256)
+	if err != nil {
+		// 缓存已被清理：撤回通告，避免继续被分派
+		fm.client.logger.Warn("[FileManager] Requested content %s not in cache: %v", req.SHA256, err)
+		fm.announceDropped(req.SHA256)
+		return
+	}
+	defer content.Close()
+
+	served := 0
+	buffer := make([]byte, req.ChunkSize)
+	for _, index := range req.Chunks {
+		if fm.ctx.Err() != nil {
+			return
+		}
+		if index < 0 || index >= req.TotalChunks {
+			continue
+		}
+		n, err := content.ReadAt(buffer, int64(index)*int64(req.ChunkSize))
+		if err != nil && err != io.EOF {
+			fm.client.logger.Error("[FileManager] Failed to read cached chunk %d of %s: %v", index, req.SHA256, err)
+			return
+		}
+		conn.SetDeadline(time.Now().Add(peerIOTimeout))
+		if err := fm.writePeerFrame(conn, &peerChunkFrame{ChunkIndex: index, Data: buffer[:n]}); err != nil {
+			fm.client.logger.Warn("[FileManager] Failed to send chunk %d to %s: %v", index, conn.RemoteAddr(), err)
+			return
+		}
+		served++
+
+		fm.statsMutex.Lock()
+		fm.stats.PeerChunksServed++
+		fm.statsMutex.Unlock()
+	}
+
+	fm.client.logger.Info("[FileManager] Served %d chunk(s) of %s to %s", served, req.SHA256, conn.RemoteAddr())
+}
+
+// handlePeerAssign 处理服务端的对等分派：向各持有者直接拉取分块
+func (fm *FileManager) handlePeerAssign(data []byte) {
+	var assign peerAssignment
+	if err := json.Unmarshal(data, &assign); err != nil {
+		fm.client.logger.Error("[FileManager] Failed to unmarshal peer assignment: %v", err)
+		return
+	}
+	if assign.RequesterID != fm.client.memberID {
+		return
+	}
+	task, ok := fm.GetDownloadTaskByFileID(assign.FileID)
+	if !ok || !strings.EqualFold(task.SHA256, assign.SHA256) ||
+		assign.ChunkSize != task.ChunkSize || assign.TotalChunks != task.TotalChunks {
+		return
+	}
+
+	for _, peer := range assign.Peers {
+		go fm.fetchFromPeer(task, &assign, peer)
+	}
+}
+
+// fetchFromPeer 向一个持有者拉取分派的分块，未取得或未通过校验的分块立即向服务端补要
+func (fm *FileManager) fetchFromPeer(task *FileDownloadTask, assign *peerAssignment, peer assignedPeer) {
+	received := make(map[int]bool, len(peer.Chunks))
+	if err := fm.pullPeerChunks(task, assign, peer, received); err != nil {
+		fm.client.logger.Warn("[FileManager] Fetching from %s (%s) stopped: %v", peer.MemberID, peer.Addr, err)
+	}
+
+	missing := make([]int, 0)
+	for _, index := range peer.Chunks {
+		if !received[index] {
+			missing = append(missing, index)
+		}
+	}
+	if len(missing) == 0 || task.GetStatus() != DownloadStatusDownloading {
+		return
+	}
+	if err := fm.requestFileData(task, missing, false); err != nil {
+		fm.client.logger.Warn("[FileManager] Failed to request %d chunk(s) from server: %v", len(missing), err)
+	}
+}
+
+// pullPeerChunks 连接持有者并逐块接收，每块与分派中的 SHA-256 比对；
+// 出现不一致的分块即停止信任该持有者
+func (fm *FileManager) pullPeerChunks(task *FileDownloadTask, assign *peerAssignment, peer assignedPeer, received map[int]bool) error {
+	wanted := make(map[int]bool, len(peer.Chunks))
+	for _, index := range peer.Chunks {
+		if _, ok := assign.ChunkHashes[index]; ok {
+			wanted[index] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	dialer := net.Dialer{Timeout: peerDialTimeout}
+	conn, err := dialer.DialContext(fm.ctx, "tcp", peer.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(peerIOTimeout))
+	request := &peerFetchRequest{
+		SHA256:      assign.SHA256,
+		ChunkSize:   assign.ChunkSize,
+		TotalChunks: assign.TotalChunks,
+		Chunks:      peer.Chunks,
+	}
+	if err := fm.writePeerFrame(conn, request); err != nil {
+		return err
+	}
+
+	for len(received) < len(wanted) {
+		if task.GetStatus() != DownloadStatusDownloading {
+			return nil
+		}
+		var frame peerChunkFrame
+		conn.SetDeadline(time.Now().Add(peerIOTimeout))
+		if err := fm.readPeerFrame(conn, &frame); err != nil {
+			return err
+		}
+		if !wanted[frame.ChunkIndex] || received[frame.ChunkIndex] {
+			return fmt.Errorf("unexpected chunk %d", frame.ChunkIndex)
+		}
+		sum := sha256.Sum256(frame.Data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), assign.ChunkHashes[frame.ChunkIndex]) {
+			fm.statsMutex.Lock()
+			fm.stats.PeerChunksRejected++
+			fm.statsMutex.Unlock()
+			return fmt.Errorf("chunk %d failed verification", frame.ChunkIndex)
+		}
+
+		received[frame.ChunkIndex] = true
+		if fm.handleDownloadChunk(task, frame.ChunkIndex, frame.Data, peer.MemberID) {
+			fm.publishDownloadProgress(task, peer.MemberID)
+		}
+	}
+	return nil
+}
+
+// writePeerFrame 加密并写入一帧
+func (fm *FileManager) writePeerFrame(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	encrypted, err := fm.client.crypto.EncryptMessage(data)
+	if err != nil {
+		return err
+	}
+	if len(encrypted) > peerMaxFrame {
+		return fmt.Errorf("frame too large: %d bytes", len(encrypted))
+	}
+	frame := make([]byte, 4+len(encrypted))
+	binary.BigEndian.PutUint32(frame, uint32(len(encrypted)))
+	copy(frame[4:], encrypted)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readPeerFrame 读取并解密一帧
+func (fm *FileManager) readPeerFrame(r io.Reader, v interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size == 0 || size > peerMaxFrame {
+		return fmt.Errorf("invalid frame size: %d", size)
+	}
+	encrypted := make([]byte, size)
+	if _, err := io.ReadFull(r, encrypted); err != nil {
+		return err
+	}
+	data, err := fm.client.crypto.DecryptMessage(encrypted)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
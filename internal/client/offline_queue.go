@@ -2,14 +2,12 @@ package client
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
 	"crosswire/internal/events"
 	"crosswire/internal/models"
-	"crosswire/internal/transport"
 
 	"github.com/google/uuid"
 )
@@ -146,13 +144,13 @@ func (oq *OfflineQueue) processLoop() {
 	}
 }
 
-// processQueue 处理队列中的消息
-func (oq *OfflineQueue) processQueue() {
+// processQueue 处理队列中的第一条消息（返回是否发送成功）
+func (oq *OfflineQueue) processQueue() bool {
 	oq.queueMutex.Lock()
 
 	if len(oq.queue) == 0 {
 		oq.queueMutex.Unlock()
-		return
+		return false
 	}
 
 	// 获取第一条消息
@@ -160,9 +158,9 @@ func (oq *OfflineQueue) processQueue() {
 	oq.queueMutex.Unlock()
 
 	// 检查客户端是否在线
-	if !oq.client.IsRunning() {
+	if !oq.client.IsRunning() || !oq.client.isOnline() {
 		oq.client.logger.Debug("[OfflineQueue] Client offline, skipping send")
-		return
+		return false
 	}
 
 	// 尝试发送
@@ -203,6 +201,7 @@ func (oq *OfflineQueue) processQueue() {
 			oq.client.logger.Warn("[OfflineQueue] Message send failed (retry %d/%d): %s - %v",
 				msg.Retries, oq.maxRetries, msg.ID, err)
 		}
+		return false
 	} else {
 		// 发送成功，移除消息
 		oq.queue = oq.queue[1:]
@@ -227,51 +226,24 @@ func (oq *OfflineQueue) processQueue() {
 			SenderID:  oq.client.memberID,
 		})
 	}
+	return true
 }
 
-// sendMessage 发送消息
-func (oq *OfflineQueue) sendMessage(msg *QueuedMessage) error {
-	// 构造消息
-	content := models.MessageContent{
-		"text": msg.Content,
-	}
-	message := &models.Message{
-		ID:        msg.ID,
-		ChannelID: oq.client.config.ChannelID,
-		SenderID:  oq.client.memberID,
-		Type:      msg.Type,
-		Content:   content,
-		ReplyToID: func() *string {
-			if msg.ReplyTo == "" {
-				return nil
-			}
-			v := msg.ReplyTo
-			return &v
-		}(),
-		Timestamp: time.Now(),
-	}
-
-	// 序列化并加密
-	payload, err := json.Marshal(message)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
-
-	encrypted, err := oq.client.crypto.EncryptMessage(payload)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt message: %w", err)
+// Flush 按入队顺序发送全部排队消息（遇到发送失败即停止，剩余消息由处理循环重试）
+func (oq *OfflineQueue) Flush() {
+	for oq.processQueue() {
 	}
+}
 
-	// 发送
-	transportMsg := &transport.Message{
-		ID:        msg.ID,
-		Type:      transport.MessageTypeData,
-		SenderID:  oq.client.memberID,
-		Payload:   encrypted,
-		Timestamp: time.Now(),
+// sendMessage 发送消息（与在线发送相同：签名后加密，服务端拒收未签名的消息）
+func (oq *OfflineQueue) sendMessage(msg *QueuedMessage) error {
+	message := oq.client.newMessage(msg.Content, msg.Type, oq.client.config.ChannelID)
+	message.ID = msg.ID
+	if msg.ReplyTo != "" {
+		replyTo := msg.ReplyTo
+		message.ReplyToID = &replyTo
 	}
-
-	return oq.client.transport.SendMessage(transportMsg)
+	return oq.client.sendSignedMessage(message)
 }
 
 // GetQueueSize 获取队列大小
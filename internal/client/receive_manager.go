@@ -118,6 +118,9 @@ func (rm *ReceiveManager) handleTransportMessage(msg *transport.Message) {
 		decrypted = plain
 	}
 
+	// 能以频道密钥解开即视为来自服务端的有效消息（用于连接保活判定）
+	rm.client.markContact()
+
 	// 2. 根据消息类型处理
 	switch msg.Type {
 	case transport.MessageTypeAuth:
@@ -127,7 +130,7 @@ func (rm *ReceiveManager) handleTransportMessage(msg *transport.Message) {
 		rm.handleDataMessage(decrypted)
 
 	case transport.MessageTypeControl:
-		rm.handleControlMessage(decrypted)
+		rm.handleControlMessage(decrypted, transport.PeerKey(msg))
 
 	default:
 		rm.client.logger.Warn("[ReceiveManager] Unknown message type: %d", msg.Type)
@@ -155,6 +158,9 @@ func (rm *ReceiveManager) handleAuthMessage(data []byte) {
 	case "auth.join_response":
 		rm.handleJoinResponse(payload)
 
+	case "auth.resume_response":
+		rm.client.handleResumeResponse(payload)
+
 	default:
 		rm.client.logger.Warn("[ReceiveManager] Unknown auth message type: %s", msgType)
 	}
@@ -162,6 +168,15 @@ func (rm *ReceiveManager) handleAuthMessage(data []byte) {
 
 // handleJoinResponse 处理加入响应
 func (rm *ReceiveManager) handleJoinResponse(payload map[string]interface{}) {
+	// 加入响应经广播下发：仅处理与本端待决请求匹配的响应（旧版服务端不带 request_id，放行）
+	pending := rm.client.getPendingJoinID()
+	requestID, _ := payload["request_id"].(string)
+	if pending == "" || (requestID != "" && requestID != pending) {
+		rm.client.logger.Debug("[ReceiveManager] Ignoring join response for another request: %s", requestID)
+		return
+	}
+	rm.client.setPendingJoinID("")
+
 	success, ok := payload["success"].(bool)
 	if !ok || !success {
 		errMsg, _ := payload["error"].(string)
@@ -180,13 +195,9 @@ func (rm *ReceiveManager) handleJoinResponse(payload map[string]interface{}) {
 		rm.client.logger.Info("[ReceiveManager] Updating client ChannelID from response: %s", chID)
 		rm.client.config.ChannelID = chID
 
-		// 重新打开正确的频道数据库并刷新仓库，确保后续成员与消息落到正确库
-		if err := rm.client.db.OpenChannelDB(chID); err != nil {
-			rm.client.logger.Error("[ReceiveManager] Failed to reopen channel DB for %s: %v", chID, err)
-		} else {
-			if err := rm.client.initRepositories(); err != nil {
-				rm.client.logger.Error("[ReceiveManager] Failed to re-init repositories after channel switch: %v", err)
-			}
+		// 绑定到正确的频道数据库并刷新仓库，确保后续成员与消息落到正确库
+		if err := rm.client.initRepositories(); err != nil {
+			rm.client.logger.Error("[ReceiveManager] Failed to re-init repositories after channel switch: %v", err)
 		}
 	}
 
@@ -203,8 +214,9 @@ func (rm *ReceiveManager) handleJoinResponse(payload map[string]interface{}) {
 		return
 	}
 
-	// 设置成员ID
+	// 设置成员ID与续连令牌
 	rm.client.SetMemberID(memberID)
+	rm.client.storeResumeToken(payload)
 
 	// 若响应携带服务器公钥，则在 ARP 模式下启用广播验签
 	if pk, ok := payload["server_public_key"].([]byte); ok && len(pk) > 0 {
@@ -468,15 +480,36 @@ func (rm *ReceiveManager) handleDataMessage(data []byte) {
 						ExtraData: map[string]string{"nickname": solverName},
 					})
 				}
+			case "artifact_analysis":
+				// 附件分析结果：回写到本地文件记录
+				if extra, ok := msg.Content["extra"].(map[string]interface{}); ok {
+					rm.applyArtifactAnalysis(extra)
+				}
+			case "file_deleted":
+				// 文件已被服务端删除（过期回收或管理员删除）：移除本地文件记录
+				if fileID, ok := msg.Content["target_id"].(string); ok {
+					rm.removeFileRecord(fileID)
+				}
 			}
 		}
 	}
 
-	// 6. 保存到数据库
-	if err := rm.client.messageRepo.Create(&msg); err != nil {
+	// 6. 保存到数据库（自己上传的文件已有本地占位消息，以服务端版本覆盖）
+	save := rm.client.messageRepo.Create
+	if msg.Type == models.MessageTypeFile {
+		if existing, err := rm.client.messageRepo.GetByID(msg.ID); err == nil && existing != nil {
+			save = rm.client.messageRepo.Update
+		}
+	}
+	if err := save(&msg); err != nil {
 		rm.client.logger.Error("[ReceiveManager] Failed to save message: %v", err)
 	}
 
+	// 6.5 文件消息：登记文件记录，供后续下载使用
+	if msg.Type == models.MessageTypeFile {
+		rm.saveFileRecord(&msg)
+	}
+
 	// 7. 更新统计
 	rm.stats.mutex.Lock()
 	rm.stats.ValidMessages++
@@ -499,8 +532,8 @@ func (rm *ReceiveManager) handleDataMessage(data []byte) {
 	rm.client.logger.Debug("[ReceiveManager] Message received: %s from %s", msg.ID, msg.SenderID)
 }
 
-// handleControlMessage 处理控制消息
-func (rm *ReceiveManager) handleControlMessage(data []byte) {
+// handleControlMessage 处理控制消息（peer 为传输层对端统计键）
+func (rm *ReceiveManager) handleControlMessage(data []byte, peer string) {
 	var payload map[string]interface{}
 	if err := json.Unmarshal(data, &payload); err != nil {
 		rm.client.logger.Error("[ReceiveManager] Failed to unmarshal control message: %v", err)
@@ -522,7 +555,7 @@ func (rm *ReceiveManager) handleControlMessage(data []byte) {
 
 	case "member.status":
 		// 成员状态更新
-		rm.handleMemberStatus(payload)
+		rm.handleMemberStatus(payload, peer)
 
 	case "member.joined":
 		// 成员加入通知
@@ -536,10 +569,26 @@ func (rm *ReceiveManager) handleControlMessage(data []byte) {
 		// 文件下载请求
 		rm.handleFileRequest(payload)
 
+	case "file.chunk":
+		// 服务端应答下载请求的文件分块
+		rm.handleFileChunk(payload)
+
+	case "file.peer_assign":
+		// 服务端分派：向持有缓存的其他成员直接拉取分块
+		rm.client.fileManager.handlePeerAssign(data)
+
 	case "file.complete":
 		// 文件上传完成通知
 		rm.handleFileComplete(payload)
 
+	case "file.status":
+		// 上传状态查询应答：交给 FileManager 处理
+		rm.client.fileManager.handleFileStatus(data)
+
+	case "file.rejected":
+		// 上传被服务端拒绝（超出配额）：交给 FileManager 终止上传
+		rm.client.fileManager.handleFileRejected(data)
+
 	default:
 		rm.client.logger.Debug("[ReceiveManager] Unknown control message: %s", msgType)
 	}
@@ -608,12 +657,169 @@ func (rm *ReceiveManager) handleFileMetadata(data map[string]interface{}) {
 	})
 }
 
+// saveFileRecord 根据服务端广播的文件消息登记文件记录（已存在时保留本地记录，仅补充预览）
+func (rm *ReceiveManager) saveFileRecord(msg *models.Message) {
+	fileID, _ := msg.Content["file_id"].(string)
+	if fileID == "" {
+		return
+	}
+	if existing, err := rm.client.fileRepo.GetByID(fileID); err == nil && existing != nil {
+		rm.applyFilePreview(existing, msg.Content)
+		return
+	}
+
+	filename, _ := msg.Content["filename"].(string)
+	mimeType, _ := msg.Content["mime_type"].(string)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	sha256Hex, _ := msg.Content["sha256"].(string)
+
+	fileRecord := &models.File{
+		ID:           fileID,
+		MessageID:    msg.ID,
+		ChannelID:    rm.client.config.ChannelID,
+		SenderID:     msg.SenderID,
+		Filename:     filename,
+		OriginalName: filename,
+		Size:         int64(getFloat(msg.Content, "size")),
+		MimeType:     mimeType,
+		StorageType:  models.StorageFile,
+		SHA256:       sha256Hex,
+		ChunkSize:    int(getFloat(msg.Content, "chunk_size")),
+		TotalChunks:  int(getFloat(msg.Content, "total_chunks")),
+		UploadStatus: models.UploadStatusCompleted,
+		UploadedAt:   msg.Timestamp,
+		Encrypted:    true,
+	}
+	if challengeID := msg.ChallengeID; challengeID != "" {
+		fileRecord.Metadata = models.JSONField{"challenge_id": challengeID}
+	}
+	if bundle, ok := msg.Content["bundle"]; ok && bundle != nil {
+		// 文件包清单（服务端已校验），供浏览与按条目下载
+		if fileRecord.Metadata == nil {
+			fileRecord.Metadata = models.JSONField{}
+		}
+		fileRecord.Metadata["bundle"] = bundle
+	}
+	if err := rm.client.fileRepo.Create(fileRecord); err != nil {
+		rm.client.logger.Error("[ReceiveManager] Failed to save file record %s: %v", fileID, err)
+		return
+	}
+	rm.applyFilePreview(fileRecord, msg.Content)
+}
+
+// applyFilePreview 保存文件消息中携带的预览（缩略图、文本预览与预览元数据）
+func (rm *ReceiveManager) applyFilePreview(file *models.File, content models.MessageContent) {
+	kind, _ := content["preview_kind"].(string)
+	if kind == "" {
+		return
+	}
+
+	var thumbnail []byte
+	if encoded, _ := content["thumbnail"].(string); encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			rm.client.logger.Warn("[ReceiveManager] Invalid thumbnail for file %s: %v", file.ID, err)
+		} else {
+			thumbnail = data
+		}
+	}
+	previewText, _ := content["preview_text"].(string)
+
+	preview := map[string]interface{}{"kind": kind}
+	for _, key := range []string{"language", "width", "height"} {
+		if v, ok := content[key]; ok {
+			preview[key] = v
+		}
+	}
+	metadata := models.JSONField{}
+	for k, v := range file.Metadata {
+		metadata[k] = v
+	}
+	metadata["preview"] = preview
+
+	if err := rm.client.fileRepo.UpdatePreview(file.ID, thumbnail, previewText, metadata); err != nil {
+		rm.client.logger.Error("[ReceiveManager] Failed to save preview of %s: %v", file.ID, err)
+		return
+	}
+	file.Thumbnail = thumbnail
+	file.PreviewText = previewText
+	file.Metadata = metadata
+}
+
+// applyArtifactAnalysis 保存服务端的附件分析结果（元数据）
+func (rm *ReceiveManager) applyArtifactAnalysis(extra map[string]interface{}) {
+	fileID, _ := extra["file_id"].(string)
+	if fileID == "" {
+		return
+	}
+	file, err := rm.client.fileRepo.GetByID(fileID)
+	if err != nil || file == nil {
+		rm.client.logger.Debug("[ReceiveManager] Analysis for unknown file: %s", fileID)
+		return
+	}
+
+	metadata := models.JSONField{}
+	for k, v := range file.Metadata {
+		metadata[k] = v
+	}
+	metadata["analysis"] = extra["analysis"]
+	if challengeID, ok := extra["challenge_id"].(string); ok {
+		metadata["challenge_id"] = challengeID
+	}
+	if err := rm.client.fileRepo.UpdateAnalysis(fileID, metadata); err != nil {
+		rm.client.logger.Error("[ReceiveManager] Failed to save analysis of %s: %v", fileID, err)
+		return
+	}
+	file.Metadata = metadata
+
+	rm.client.eventBus.Publish(events.EventFileAnalyzed, events.FileEvent{
+		File:       file,
+		ChannelID:  rm.client.config.ChannelID,
+		UploaderID: file.SenderID,
+		Progress:   100,
+	})
+}
+
+// removeFileRecord 删除本地文件记录并释放本地缓存（不删除已下载到用户目录或上传者本地的文件）
+func (rm *ReceiveManager) removeFileRecord(fileID string) {
+	file, err := rm.client.fileRepo.GetByID(fileID)
+	if err != nil || file == nil {
+		return
+	}
+	if blobs := rm.client.db.GetBlobStore(); blobs.Owns(file.StoragePath) {
+		if err := rm.client.fileRepo.ReleaseContent(file); err != nil {
+			rm.client.logger.Warn("[ReceiveManager] Failed to release cached file %s: %v", fileID, err)
+		} else if !blobs.Has(file.SHA256) {
+			rm.client.fileManager.announceDropped(file.SHA256)
+		}
+	}
+	if err := rm.client.fileRepo.Delete(fileID); err != nil {
+		rm.client.logger.Error("[ReceiveManager] Failed to remove file record %s: %v", fileID, err)
+		return
+	}
+	rm.client.eventBus.Publish(events.EventFileDeleted, events.FileEvent{
+		File:       file,
+		ChannelID:  rm.client.config.ChannelID,
+		UploaderID: file.SenderID,
+	})
+}
+
 // handleFileChunk 处理文件分块
+// 下载同一文件的成员都会收到广播的分块，各自按 checksum 校验后收入自己的任务
 func (rm *ReceiveManager) handleFileChunk(data map[string]interface{}) {
 	fileID, _ := data["file_id"].(string)
 	chunkIndex := int(getFloat(data, "chunk_index"))
-	totalChunks := int(getFloat(data, "total_chunks"))
 	checksum, _ := data["checksum"].(string)
+	source, _ := data["source"].(string)
+
+	// 查找对应下载任务
+	task, ok := rm.client.fileManager.GetDownloadTaskByFileID(fileID)
+	if !ok {
+		rm.client.logger.Debug("[ReceiveManager] No download task for file %s, ignoring chunk", fileID)
+		return
+	}
 
 	// Base64 解码分块数据
 	chunkDataB64, _ := data["data"].(string)
@@ -630,43 +836,10 @@ func (rm *ReceiveManager) handleFileChunk(data map[string]interface{}) {
 		return
 	}
 
-	// 查找对应下载任务
-	task, ok := rm.client.fileManager.GetDownloadTaskByFileID(fileID)
-	if !ok {
-		rm.client.logger.Debug("[ReceiveManager] No download task for file %s, ignoring chunk", fileID)
-		return
-	}
-
-	// 添加分块
-	task.chunksMutex.Lock()
-	if task.chunks == nil {
-		task.chunks = make(map[int][]byte)
+	// 添加分块（重复分块直接忽略）
+	if rm.client.fileManager.handleDownloadChunk(task, chunkIndex, chunkData, source) {
+		rm.client.fileManager.publishDownloadProgress(task, source)
 	}
-	task.chunks[chunkIndex] = chunkData
-	task.ReceivedChunks = len(task.chunks)
-	task.chunksMutex.Unlock()
-
-	// 触发回调
-	if task.OnProgress != nil {
-		task.OnProgress(task)
-	}
-
-	// 发布下载进度事件
-	progress := int(float64(task.ReceivedChunks) / float64(maxInt(task.TotalChunks, 1)) * 100)
-	var filePtr *models.File
-	if f, err := rm.client.fileRepo.GetByID(fileID); err == nil {
-		filePtr = f
-	}
-
-	rm.client.eventBus.Publish(events.EventFileDownloadProgress, events.FileEvent{
-		File:       filePtr,
-		ChannelID:  rm.client.config.ChannelID,
-		UploaderID: "",
-		Progress:   progress,
-	})
-
-	rm.client.logger.Debug("[ReceiveManager] File download progress: %s [%d/%d] (%d%%)",
-		fileID, task.ReceivedChunks, totalChunks, progress)
 }
 
 // handleFileComplete 处理文件完成消息
@@ -705,6 +878,11 @@ func (rm *ReceiveManager) handleFileRequest(data map[string]interface{}) {
 		rm.client.logger.Error("[ReceiveManager] File has no local path: %s", fileID)
 		return
 	}
+	// 加密的缓存内容不能按路径重新上传（请求方可改由缓存持有者回传分块）
+	if blobs := rm.client.db.GetBlobStore(); blobs.Encrypted() && blobs.Owns(file.StoragePath) {
+		rm.client.logger.Warn("[ReceiveManager] Requested file is only in the encrypted cache: %s", fileID)
+		return
+	}
 
 	go func() {
 		if _, err := rm.client.fileManager.UploadFile(file.StoragePath); err != nil {
@@ -729,11 +907,15 @@ func maxInt(a, b int) int {
 }
 
 // handleMemberStatus 处理成员状态更新
-func (rm *ReceiveManager) handleMemberStatus(payload map[string]interface{}) {
+// 本成员心跳的回显（echo 为心跳发送时间）即一次 RTT 采样
+func (rm *ReceiveManager) handleMemberStatus(payload map[string]interface{}, peer string) {
 	memberID, ok := payload["member_id"].(string)
 	if !ok {
 		return
 	}
+	if echo, ok := payload["echo"].(float64); ok && memberID == rm.client.memberID {
+		rm.client.recordHeartbeatRTT(peer, time.Since(time.Unix(0, int64(echo))))
+	}
 
 	status, ok := payload["status"].(string)
 	if !ok {
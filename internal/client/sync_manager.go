@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"crosswire/internal/models"
-	"crosswire/internal/transport"
 )
 
 // SyncManager 同步管理器
@@ -248,7 +247,7 @@ func (sm *SyncManager) doSync() {
 		"request_id":      reqID,
 	}
 
-	// 2. 序列化并加密
+	// 2. 序列化
 	reqJSON, err := json.Marshal(syncReq)
 	if err != nil {
 		sm.client.logger.Error("[SyncManager] Failed to marshal sync request: %v", err)
@@ -258,24 +257,8 @@ func (sm *SyncManager) doSync() {
 		return
 	}
 
-	reqData, err := sm.client.crypto.EncryptMessage(reqJSON)
-	if err != nil {
-		sm.client.logger.Error("[SyncManager] Failed to encrypt sync request: %v", err)
-		sm.stats.mutex.Lock()
-		sm.stats.FailedSyncs++
-		sm.stats.mutex.Unlock()
-		return
-	}
-
-	// 3. 发送请求
-	msg := &transport.Message{
-		Type:      transport.MessageTypeControl,
-		SenderID:  sm.client.memberID,
-		Payload:   reqData,
-		Timestamp: time.Now(),
-	}
-
-	if err := sm.client.transport.SendMessage(msg); err != nil {
+	// 3. 签名、加密并发送请求
+	if err := sm.client.sendControlMessage(reqJSON); err != nil {
 		sm.client.logger.Error("[SyncManager] Failed to send sync request: %v", err)
 		sm.stats.mutex.Lock()
 		sm.stats.FailedSyncs++
@@ -579,6 +562,11 @@ func (sm *SyncManager) processSyncMessages(messagesData []interface{}) {
 			}
 		}
 
+		// 文件消息：登记文件记录与预览（离线期间上传的文件）
+		if msg.Type == models.MessageTypeFile {
+			sm.client.receiveManager.saveFileRecord(&msg)
+		}
+
 		// 推进水位：按时间戳最大（若相等按ID最大）
 		if msg.Timestamp.After(maxTimestamp) || (msg.Timestamp.Equal(maxTimestamp) && msg.ID > maxMsgID) {
 			maxTimestamp = msg.Timestamp
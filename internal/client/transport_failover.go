@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+
+	"crosswire/internal/transport"
+)
+
+// 自动传输模式的探测与切换
+// 参考: docs/ARCHITECTURE.md - 3.1.4 传输模块
+//
+// 切换只替换 AutoTransport 内部的真实传输，Client 及各子管理器保持不变，
+// 因此成员身份、离线队列与同步水位（SyncManager 的 lastSyncTime/lastMessageID）都不会丢失。
+// 何时切换由连接状态机（connection_state.go）决定。
+
+// probeTransports 按候选顺序逐一连接并加入频道，使用第一个完成加入的传输
+func (c *Client) probeTransports(auto *transport.AutoTransport) error {
+	var lastErr error
+	attempts := len(auto.Candidates())
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if err := auto.Advance(); err != nil {
+				lastErr = err
+				break
+			}
+		}
+
+		mode := auto.ActiveMode()
+		if err := c.connectTransport(); err != nil {
+			c.logger.Warn("[Client] Auto mode: %s connect failed: %v", mode, err)
+			lastErr = err
+			continue
+		}
+		if err := c.joinChannel(c.config.ProbeTimeout); err != nil {
+			c.logger.Warn("[Client] Auto mode: %s join failed: %v", mode, err)
+			lastErr = fmt.Errorf("%s: %w", mode, err)
+			continue
+		}
+
+		c.logger.Info("[Client] Auto mode: joined via %s", mode)
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no transport candidates")
+	}
+	return fmt.Errorf("no transport completed join: %w", lastErr)
+}
+
+// failoverThreshold 自动模式下视为连接丢失的连续发送失败次数
+func (c *Client) failoverThreshold() int {
+	if c.config.FailoverThreshold > 0 {
+		return c.config.FailoverThreshold
+	}
+	return 3
+}
+
+// SwitchTransport 切换到下一个可加入的候选传输（仅自动模式）
+// 优先以续连令牌恢复原会话，否则以原成员ID重新加入；完成后强制同步并立即发送离线队列
+func (c *Client) SwitchTransport() error {
+	auto, ok := c.transport.(*transport.AutoTransport)
+	if !ok {
+		return fmt.Errorf("transport switching requires auto mode")
+	}
+
+	c.failoverMutex.Lock()
+	defer c.failoverMutex.Unlock()
+
+	from := auto.ActiveMode()
+	c.logger.Warn("[Client] Switching transport away from %s", from)
+	c.setConnectionState(StateReconnecting, fmt.Sprintf("transport %s unhealthy, switching", from),
+		map[string]interface{}{"from": from})
+
+	var lastErr error
+	for i := 0; i < len(auto.Candidates()); i++ {
+		if err := auto.Advance(); err != nil {
+			lastErr = err
+			break
+		}
+		mode := auto.ActiveMode()
+		resumed, err := c.restoreSession(c.config.ProbeTimeout)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", mode, err)
+			continue
+		}
+
+		c.logger.Info("[Client] Transport switched: %s -> %s", from, mode)
+		c.onReconnected(resumed, map[string]interface{}{"from": from, "to": mode})
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no transport candidates")
+	}
+	c.setConnectionState(StateFailed, fmt.Sprintf("all transports failed: %v", lastErr), nil)
+	return fmt.Errorf("no transport completed join: %w", lastErr)
+}
+
+// GetActiveTransportMode 获取当前实际使用的传输模式（自动模式下为选中的候选）
+func (c *Client) GetActiveTransportMode() transport.TransportMode {
+	if c.transport == nil {
+		return ""
+	}
+	return c.transport.GetMode()
+}
@@ -26,18 +26,22 @@ func NewManager() (*Manager, error) {
 
 // ===== AES 加密 =====
 
+// AESOverhead AES-256-GCM 密文相对明文增加的字节数（12 字节 nonce + 16 字节认证标签）
+const AESOverhead = 12 + 16
+
 // AESEncrypt 使用AES-256-GCM加密数据
 func (m *Manager) AESEncrypt(plaintext, key []byte) ([]byte, error) {
-	if len(key) != 32 {
-		return nil, fmt.Errorf("key must be 32 bytes")
-	}
+	return m.AESEncryptWithAD(plaintext, key, nil)
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
+// AESDecrypt 使用AES-256-GCM解密数据
+func (m *Manager) AESDecrypt(ciphertext, key []byte) ([]byte, error) {
+	return m.AESDecryptWithAD(ciphertext, key, nil)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// AESEncryptWithAD 使用AES-256-GCM加密数据，additionalData 参与认证但不加密（如分段序号）
+func (m *Manager) AESEncryptWithAD(plaintext, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -48,22 +52,13 @@ func (m *Manager) AESEncrypt(plaintext, key []byte) ([]byte, error) {
 	}
 
 	// nonce + ciphertext
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, additionalData)
 	return ciphertext, nil
 }
 
-// AESDecrypt 使用AES-256-GCM解密数据
-func (m *Manager) AESDecrypt(ciphertext, key []byte) ([]byte, error) {
-	if len(key) != 32 {
-		return nil, fmt.Errorf("key must be 32 bytes")
-	}
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
+// AESDecryptWithAD 使用AES-256-GCM解密数据，additionalData 须与加密时一致
+func (m *Manager) AESDecryptWithAD(ciphertext, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +69,7 @@ func (m *Manager) AESDecrypt(ciphertext, key []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +77,20 @@ func (m *Manager) AESDecrypt(ciphertext, key []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// newGCM 创建AES-256-GCM实例
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
 // ===== X25519 密钥交换 =====
 
 // GenerateX25519KeyPair 生成X25519密钥对
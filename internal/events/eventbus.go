@@ -50,6 +50,7 @@ const (
 	EventFileDownloadCompleted EventType = "file:download:completed" // 文件下载完成
 	EventFileDownloadFailed    EventType = "file:download:failed"    // 文件下载失败
 	EventFileDeleted           EventType = "file:deleted"            // 文件被删除
+	EventFileAnalyzed          EventType = "file:analyzed"           // 附件自动分析完成
 
 	// ===== 频道相关事件 =====
 	EventChannelCreated EventType = "channel:created" // 频道创建
@@ -0,0 +1,147 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crosswire/internal/models"
+)
+
+// TestFlagSubmission 成员提交 Flag，服务端记录解题并广播给其他成员
+func TestFlagSubmission(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	challenge := &models.Challenge{
+		ID:         "chal-integration",
+		Title:      "warmup",
+		Category:   "misc",
+		Difficulty: "easy",
+		Points:     100,
+		FlagFormat: "flag{...}",
+		Status:     "open",
+		CreatedBy:  "server",
+	}
+	if err := c.server.CreateChallenge(challenge); err != nil {
+		t.Fatalf("create challenge: %v", err)
+	}
+	eventually(t, "alice to receive the challenge", func() bool {
+		_, ok := alice.GetChallenge(challenge.ID)
+		return ok
+	})
+
+	if err := alice.SubmitFlag(challenge.ID, "flag{integration}"); err != nil {
+		t.Fatalf("submit flag: %v", err)
+	}
+
+	eventually(t, "server to mark the challenge solved", func() bool {
+		ch, err := c.server.GetChallenge(challenge.ID)
+		if err != nil || ch.Status != "solved" {
+			return false
+		}
+		for _, id := range ch.SolvedBy {
+			if id == alice.GetMemberID() {
+				return true
+			}
+		}
+		return false
+	})
+	eventually(t, "bob to see the solve", func() bool {
+		ch, err := bob.db.ChallengeRepo().GetByID(challenge.ID)
+		return err == nil && ch.Status == "solved" && ch.Flag == "flag{integration}"
+	})
+}
+
+// TestArtifactAnalysis 上传到题目聊天室的附件在服务端完成后自动分析，结果写入文件记录并发布到题目聊天室
+func TestArtifactAnalysis(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	challenge := &models.Challenge{
+		ID:         "chal-artifact",
+		Title:      "matryoshka",
+		Category:   "forensics",
+		Difficulty: "easy",
+		Points:     100,
+		FlagFormat: "CTF{...}",
+		Status:     "open",
+		CreatedBy:  "server",
+	}
+	if err := c.server.CreateChallenge(challenge); err != nil {
+		t.Fatalf("create challenge: %v", err)
+	}
+	eventually(t, "alice to receive the challenge", func() bool {
+		_, ok := alice.GetChallenge(challenge.ID)
+		return ok
+	})
+
+	// 压缩后的条目中藏有 Flag，只能通过列出并扫描压缩包内容发现；其他格式的 Flag 不应命中
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "notes/readme.txt", Method: zip.Deflate})
+	if err != nil {
+		t.Fatalf("create zip entry: %v", err)
+	}
+	w.Write(bytes.Repeat([]byte("padding "), 64))
+	w.Write([]byte("\nflag{decoy} CTF{n3st3d_4rch1v3}\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	src := filepath.Join(alice.dataDir, "handout.zip")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	task, err := alice.UploadFileToChallenge(src, challenge.ID)
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+
+	// 服务端：文件记录带有分析结果与预览
+	var stored *models.File
+	eventually(t, "server to analyze the upload", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(task.ID)
+		stored = f
+		return err == nil && f.Metadata["analysis"] != nil
+	})
+	analysis, _ := stored.Metadata["analysis"].(map[string]interface{})
+	if analysis["type"] != "zip" {
+		t.Fatalf("expected zip detection, got %v", analysis["type"])
+	}
+	flags, _ := json.Marshal(analysis["flags"])
+	if string(flags) != `["CTF{n3st3d_4rch1v3}"]` {
+		t.Fatalf("unexpected flag candidates: %s", flags)
+	}
+	if !strings.HasPrefix(stored.PreviewText, "00000000: 504b 0304") {
+		t.Fatalf("expected a hex dump preview of the archive, got %q", stored.PreviewText)
+	}
+	msg, err := c.serverDB.MessageRepo().GetByID(task.ID)
+	if err != nil || msg.ChallengeID != challenge.ID || msg.RoomType != "challenge" {
+		t.Fatalf("file message not placed in the challenge room: %+v (%v)", msg, err)
+	}
+
+	// 其他成员：题目聊天室收到分析系统消息，本地文件记录同步分析结果
+	eventually(t, "bob to see the analysis in the challenge room", func() bool {
+		msgs, err := bob.db.MessageRepo().GetChallengeMessages(challenge.ID, 50)
+		if err != nil {
+			return false
+		}
+		for _, m := range msgs {
+			if m.Type == models.MessageTypeSystem && m.Content["event"] == "artifact_analysis" {
+				return true
+			}
+		}
+		return false
+	})
+	eventually(t, "bob's file record to carry the analysis", func() bool {
+		f, err := bob.db.FileRepo().GetByID(task.ID)
+		return err == nil && f.Metadata["analysis"] != nil && f.PreviewText != ""
+	})
+}
@@ -0,0 +1,712 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crosswire/internal/client"
+	"crosswire/internal/events"
+	"crosswire/internal/models"
+	"crosswire/internal/server"
+	"crosswire/internal/transport"
+)
+
+// TestFileTransfer 分块上传到服务端，另一成员下载后内容与哈希一致
+func TestFileTransfer(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	// 跨越多个分块的随机内容
+	content := make([]byte, 80*1024+123)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("generate content: %v", err)
+	}
+	src := filepath.Join(alice.dataDir, "payload.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	task, err := alice.UploadFile(src)
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	if task.TotalChunks < 2 {
+		t.Fatalf("expected a multi-chunk upload, got %d chunks", task.TotalChunks)
+	}
+
+	// 服务端收齐分块并校验
+	eventually(t, "server to complete upload", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(task.ID)
+		return err == nil && f.UploadStatus == models.UploadStatusCompleted
+	})
+	stored, err := c.serverDB.FileRepo().GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("load server file: %v", err)
+	}
+	if stored.StorageType != models.StorageFile || len(stored.Data) != 0 {
+		t.Fatalf("server kept content inline: storage=%s inline=%d bytes", stored.StorageType, len(stored.Data))
+	}
+	if got := readStored(t, c.serverDB, stored); !bytes.Equal(got, content) {
+		t.Fatalf("server stored %d bytes, want %d identical bytes", len(got), len(content))
+	}
+
+	// 其他成员收到文件消息后下载
+	eventually(t, "bob to learn about the file", func() bool {
+		_, err := bob.db.FileRepo().GetByID(task.ID)
+		return err == nil
+	})
+	done := make(chan struct{}, 1)
+	bob.bus.Subscribe(events.EventFileDownloadCompleted, func(ev *events.Event) {
+		if fe, ok := ev.Data.(events.FileEvent); ok && fe.File != nil && fe.File.ID == task.ID {
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+		}
+	})
+	dst := filepath.Join(bob.dataDir, "download.bin")
+	if _, err := bob.DownloadFile(task.ID, dst); err != nil {
+		t.Fatalf("download file: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for download to complete")
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read downloaded file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs from upload")
+	}
+}
+
+// TestFileDeduplication 相同内容的两次上传共用同一个 blob，删除一份不影响另一份
+func TestFileDeduplication(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	content := make([]byte, 40*1024+7)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("generate content: %v", err)
+	}
+
+	upload := func(n *node, name string) *models.File {
+		src := filepath.Join(n.dataDir, name)
+		if err := os.WriteFile(src, content, 0644); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+		task, err := n.UploadFile(src)
+		if err != nil {
+			t.Fatalf("upload file: %v", err)
+		}
+		var stored *models.File
+		eventually(t, "server to complete "+name, func() bool {
+			f, err := c.serverDB.FileRepo().GetByID(task.ID)
+			stored = f
+			return err == nil && f.UploadStatus == models.UploadStatusCompleted
+		})
+		return stored
+	}
+
+	first := upload(alice, "a.bin")
+	second := upload(bob, "b.bin")
+	if first.ID == second.ID {
+		t.Fatalf("expected two distinct file records")
+	}
+	if first.StoragePath == "" || first.StoragePath != second.StoragePath {
+		t.Fatalf("identical uploads not deduplicated: %q vs %q", first.StoragePath, second.StoragePath)
+	}
+
+	// 仍被引用的 blob 不会被删除
+	repo := c.serverDB.FileRepo()
+	if err := repo.ReleaseContent(first); err != nil {
+		t.Fatalf("release first: %v", err)
+	}
+	if err := repo.Delete(first.ID); err != nil {
+		t.Fatalf("delete first: %v", err)
+	}
+	if got := readStored(t, c.serverDB, second); !bytes.Equal(got, content) {
+		t.Fatalf("remaining file content changed after deleting its duplicate")
+	}
+
+	// 最后一个引用释放后 blob 被删除
+	if err := repo.ReleaseContent(second); err != nil {
+		t.Fatalf("release second: %v", err)
+	}
+	if _, err := os.Stat(second.StoragePath); !os.IsNotExist(err) {
+		t.Fatalf("blob still present after last reference released: %v", err)
+	}
+}
+
+// TestParallelUploadDuplicateChunks 多个上传协程并行发送、链路重复投递时，服务端按位图去重并正确拼装
+func TestParallelUploadDuplicateChunks(t *testing.T) {
+	c := newCluster(t)
+	alice := c.joinWith("alice", func(cfg *client.Config) {
+		cfg.UploadWorkers = 4
+	})
+
+	content := make([]byte, 320*1024+11)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("generate content: %v", err)
+	}
+	src := filepath.Join(alice.dataDir, "parallel.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	c.network.SetFaults(transport.LoopbackFaults{
+		Jitter:        2 * time.Millisecond,
+		DuplicateRate: 0.3,
+		Seed:          7,
+	})
+	task, err := alice.UploadFile(src)
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+
+	eventually(t, "server to complete upload", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(task.ID)
+		return err == nil && f.UploadStatus == models.UploadStatusCompleted
+	})
+	stored, err := c.serverDB.FileRepo().GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("load server file: %v", err)
+	}
+	if stored.UploadedChunks != stored.TotalChunks || len(stored.MissingChunks()) != 0 {
+		t.Fatalf("chunk accounting off: uploaded=%d total=%d missing=%v",
+			stored.UploadedChunks, stored.TotalChunks, stored.MissingChunks())
+	}
+	chunks, err := c.serverDB.FileRepo().GetChunksByFileID(task.ID)
+	if err != nil {
+		t.Fatalf("load chunks: %v", err)
+	}
+	if len(chunks) != stored.TotalChunks {
+		t.Fatalf("expected %d chunk rows, got %d", stored.TotalChunks, len(chunks))
+	}
+	if got := readStored(t, c.serverDB, stored); !bytes.Equal(got, content) {
+		t.Fatalf("server assembled %d bytes, want %d identical bytes", len(got), len(content))
+	}
+	if stats := c.network.FaultStats(); stats.Duplicated == 0 {
+		t.Fatalf("expected duplicated deliveries, got %+v", stats)
+	}
+}
+
+// TestResumeUploadAfterServerRestart 上传时丢失部分分块，服务端重启后按服务端位图续传并完成
+func TestResumeUploadAfterServerRestart(t *testing.T) {
+	c := newCluster(t)
+	alice := c.joinWith("alice", fastReconnect)
+
+	content := make([]byte, 640*1024+13)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("generate content: %v", err)
+	}
+	src := filepath.Join(alice.dataDir, "resume.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	// 有损链路上的上传：客户端认为已发送完毕，服务端缺少部分分块
+	sent := make(chan struct{}, 1)
+	alice.bus.Subscribe(events.EventFileUploaded, func(ev *events.Event) {
+		select {
+		case sent <- struct{}{}:
+		default:
+		}
+	})
+	c.network.SetFaults(transport.LoopbackFaults{LossRate: 0.3, Seed: 1})
+	task, err := alice.UploadFile(src)
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	select {
+	case <-sent:
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for lossy upload to finish sending")
+	}
+	c.network.SetFaults(transport.LoopbackFaults{})
+
+	if f, err := c.serverDB.FileRepo().GetByID(task.ID); err == nil && f.UploadStatus == models.UploadStatusCompleted {
+		t.Fatalf("expected the lossy upload to leave gaps on the server")
+	}
+
+	// 服务端重启：分块位图与临时文件均在磁盘上
+	reconnected := alice.reconnects()
+	c.restartServer()
+	awaitResumed(t, reconnected)
+
+	if err := alice.ResumeUpload(task.ID); err != nil {
+		t.Fatalf("resume upload: %v", err)
+	}
+	eventually(t, "server to complete resumed upload", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(task.ID)
+		return err == nil && f.UploadStatus == models.UploadStatusCompleted
+	})
+	stored, err := c.serverDB.FileRepo().GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("load server file: %v", err)
+	}
+	if got := readStored(t, c.serverDB, stored); !bytes.Equal(got, content) {
+		t.Fatalf("resumed upload stored %d bytes, want %d identical bytes", len(got), len(content))
+	}
+}
+
+// TestFilePreview 上传完成后服务端生成缩略图/文本预览，随文件消息广播到其他成员
+func TestFilePreview(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatalf("encode image: %v", err)
+	}
+	imgPath := filepath.Join(alice.dataDir, "screenshot.png")
+	if err := os.WriteFile(imgPath, pngData.Bytes(), 0644); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+	srcPath := filepath.Join(alice.dataDir, "solve.py")
+	source := "from pwn import *\n\nio = remote('chall', 1337)\nio.interactive()\n"
+	if err := os.WriteFile(srcPath, []byte(source), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	imgTask, err := alice.UploadFile(imgPath)
+	if err != nil {
+		t.Fatalf("upload image: %v", err)
+	}
+	srcTask, err := alice.UploadFile(srcPath)
+	if err != nil {
+		t.Fatalf("upload source: %v", err)
+	}
+
+	// 服务端：缩略图按最长边缩小，文本预览为前若干行
+	var storedImg *models.File
+	eventually(t, "server to generate the thumbnail", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(imgTask.ID)
+		storedImg = f
+		return err == nil && len(f.Thumbnail) > 0
+	})
+	cfg, err := png.DecodeConfig(bytes.NewReader(storedImg.Thumbnail))
+	if err != nil || cfg.Width != 128 || cfg.Height != 85 {
+		t.Fatalf("unexpected thumbnail: %+v (%v)", cfg, err)
+	}
+
+	// 其他成员：从广播的文件消息登记文件记录并保存预览
+	eventually(t, "bob to receive the thumbnail", func() bool {
+		f, err := bob.db.FileRepo().GetByID(imgTask.ID)
+		return err == nil && bytes.Equal(f.Thumbnail, storedImg.Thumbnail)
+	})
+	var bobSrc *models.File
+	eventually(t, "bob to receive the text preview", func() bool {
+		f, err := bob.db.FileRepo().GetByID(srcTask.ID)
+		bobSrc = f
+		return err == nil && f.PreviewText != ""
+	})
+	if bobSrc.PreviewText != strings.TrimRight(source, "\n") {
+		t.Fatalf("unexpected text preview: %q", bobSrc.PreviewText)
+	}
+	preview, _ := bobSrc.Metadata["preview"].(map[string]interface{})
+	if preview["kind"] != "text" || preview["language"] != "python" {
+		t.Fatalf("unexpected preview metadata: %v", preview)
+	}
+}
+
+// TestStorageQuotaAndRetention 超出成员配额的上传被拒绝；过期文件由回收删除并记入审计日志，成员随之移除记录
+func TestStorageQuotaAndRetention(t *testing.T) {
+	c := newClusterWith(t, func(cfg *server.ServerConfig) {
+		cfg.MaxMemberStorage = 1000
+		cfg.FileRetention = server.RetentionPolicy{MaxAge: time.Hour}
+	})
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	write := func(name string, size int) string {
+		path := filepath.Join(alice.dataDir, name)
+		if err := os.WriteFile(path, bytes.Repeat([]byte(name[:1]), size), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+
+	// 第一个文件在配额内，按保留策略设置过期时间
+	first, err := alice.UploadFile(write("a.bin", 600))
+	if err != nil {
+		t.Fatalf("upload first file: %v", err)
+	}
+	var stored *models.File
+	eventually(t, "first upload to complete", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(first.ID)
+		stored = f
+		return err == nil && f.UploadStatus == models.UploadStatusCompleted
+	})
+	if d := time.Until(stored.ExpiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("unexpected expiry: %v", stored.ExpiresAt)
+	}
+	eventually(t, "bob to register the first file", func() bool {
+		_, err := bob.db.FileRepo().GetByID(first.ID)
+		return err == nil
+	})
+
+	// 第二个文件超出成员配额：服务端不登记，上传者的任务失败
+	second, err := alice.UploadFile(write("b.bin", 600))
+	if err != nil {
+		t.Fatalf("upload second file: %v", err)
+	}
+	eventually(t, "the second upload to be rejected", func() bool {
+		f, err := alice.db.FileRepo().GetByID(second.ID)
+		return err == nil && f.UploadStatus == models.UploadStatusFailed
+	})
+	if _, err := c.serverDB.FileRepo().GetByID(second.ID); err == nil {
+		t.Fatalf("rejected file was registered on the server")
+	}
+
+	// 过期后回收：删除内容与记录，写审计日志，通知成员
+	if err := c.serverDB.GetChannelDB().Model(&models.File{}).Where("id = ?", first.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("backdate expiry: %v", err)
+	}
+	report, err := c.server.CollectGarbage()
+	if err != nil {
+		t.Fatalf("collect garbage: %v", err)
+	}
+	if report.ExpiredFiles != 1 || report.BytesReleased != 600 || !report.Vacuumed {
+		t.Fatalf("unexpected gc report: %+v", report)
+	}
+	if _, err := c.serverDB.FileRepo().GetByID(first.ID); err == nil {
+		t.Fatalf("expired file still registered")
+	}
+	if _, err := os.Stat(stored.StoragePath); !os.IsNotExist(err) {
+		t.Fatalf("expired blob still on disk: %v", err)
+	}
+	logs, err := c.serverDB.AuditRepo().GetByType(c.channelID, "file_deleted", 10, 0)
+	if err != nil || len(logs) != 1 || logs[0].TargetID != first.ID || logs[0].Reason != "expired" {
+		t.Fatalf("missing audit log for the deletion: %+v (%v)", logs, err)
+	}
+	eventually(t, "bob to drop the expired file", func() bool {
+		_, err := bob.db.FileRepo().GetByID(first.ID)
+		return err != nil
+	})
+
+	// 回收后配额释放，可以再次上传
+	third, err := alice.UploadFile(write("c.bin", 600))
+	if err != nil {
+		t.Fatalf("upload third file: %v", err)
+	}
+	eventually(t, "the third upload to complete", func() bool {
+		f, err := c.serverDB.FileRepo().GetByID(third.ID)
+		return err == nil && f.UploadStatus == models.UploadStatusCompleted
+	})
+}
+
+// TestUploadBoundedByDeclaredSize 分块写入不能超出元数据声明的大小（配额按声明大小计算），分块信息不一致的元数据被拒绝
+func TestUploadBoundedByDeclaredSize(t *testing.T) {
+	c := newCluster(t)
+	repo := c.serverDB.FileRepo()
+
+	for _, bad := range []models.File{
+		{Size: -1, ChunkSize: 8, TotalChunks: 0},
+		{Size: 10, ChunkSize: 0, TotalChunks: 2},
+		{Size: 10, ChunkSize: 8, TotalChunks: 1},
+		{Size: 10, ChunkSize: 8, TotalChunks: 100},
+	} {
+		if err := bad.ValidateLayout(); err == nil {
+			t.Fatalf("layout accepted: size=%d chunk_size=%d total_chunks=%d", bad.Size, bad.ChunkSize, bad.TotalChunks)
+		}
+	}
+
+	file := &models.File{ID: "declared-small", Size: 10, ChunkSize: 8, TotalChunks: 2}
+	if err := file.ValidateLayout(); err != nil {
+		t.Fatalf("valid layout rejected: %v", err)
+	}
+	content := []byte("0123456789")
+	if err := repo.WriteChunk(file, 0, content[:8]); err != nil {
+		t.Fatalf("write first chunk: %v", err)
+	}
+	// 最后一块按完整分块大小写入会越过声明的大小
+	if err := repo.WriteChunk(file, 1, bytes.Repeat([]byte{'x'}, 8)); err == nil {
+		t.Fatalf("write past the declared size was accepted")
+	}
+	if err := repo.WriteChunk(file, 1, content[8:]); err != nil {
+		t.Fatalf("write last chunk: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	file.SHA256 = hex.EncodeToString(sum[:])
+	if err := repo.CommitContent(file); err != nil {
+		t.Fatalf("commit content: %v", err)
+	}
+	if got := readStored(t, c.serverDB, file); !bytes.Equal(got, content) {
+		t.Fatalf("stored %q, want %q", got, content)
+	}
+}
+
+// TestPeerAssistedDownload 下载完成的文件缓存在本地并通告；后续下载由服务端与持有者分担分块，
+// 请求方直接向持有者拉取并逐块校验，重复下载直接命中缓存，篡改的分块被丢弃并改向服务端补要
+func TestPeerAssistedDownload(t *testing.T) {
+	c := newCluster(t)
+	// 回环传输没有 IP 地址可供服务端补全端点，对等端点显式监听本机地址
+	peer := func(cfg *client.Config) { cfg.PeerListenAddr = "127.0.0.1:0" }
+	alice := c.joinWith("alice", peer)
+	bob := c.joinWith("bob", peer)
+	carol := c.joinWith("carol", peer)
+	dave := c.joinWith("dave", peer)
+
+	content := make([]byte, 200*1024+17)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("generate content: %v", err)
+	}
+	src := filepath.Join(alice.dataDir, "attachment.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	task, err := alice.UploadFile(src)
+	if err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	download := func(n *node, name string) []byte {
+		eventually(t, n.GetMemberID()+" to learn about the file", func() bool {
+			_, err := n.db.FileRepo().GetByID(task.ID)
+			return err == nil
+		})
+		done := make(chan struct{}, 1)
+		n.bus.Subscribe(events.EventFileDownloadCompleted, func(ev *events.Event) {
+			if fe, ok := ev.Data.(events.FileEvent); ok && fe.File != nil && fe.File.ID == task.ID {
+				select {
+				case done <- struct{}{}:
+				default:
+				}
+			}
+		})
+		dst := filepath.Join(n.dataDir, name)
+		if _, err := n.DownloadFile(task.ID, dst); err != nil {
+			t.Fatalf("download file: %v", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for %s", name)
+		}
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatalf("read downloaded file: %v", err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("%s differs from upload", name)
+		}
+		return got
+	}
+	advertisements := func() uint64 {
+		return c.server.GetSwarmStats()["advertisements"].(uint64)
+	}
+	// 每个成员加入时通告一次本地缓存
+	eventually(t, "members to advertise their caches", func() bool { return advertisements() >= 4 })
+
+	// bob 没有可用的持有者，全部分块来自服务端；完成后缓存并通告
+	download(bob, "bob.bin")
+	if !bob.db.GetBlobStore().Has(hash) {
+		t.Fatalf("bob did not cache the download")
+	}
+	if got := bob.GetFileManagerStats().PeerChunksReceived; got != 0 {
+		t.Fatalf("bob received %d chunks from peers without any holder", got)
+	}
+	eventually(t, "bob to announce the cached file", func() bool { return advertisements() >= 5 })
+
+	// carol 的部分分块直接从 bob 的缓存拉取，服务端只发送其余分块
+	serverChunks := c.server.GetSwarmStats()["server_chunks"].(uint64)
+	download(carol, "carol.bin")
+	received := carol.GetFileManagerStats().PeerChunksReceived
+	if received == 0 {
+		t.Fatalf("carol fetched every chunk from the server")
+	}
+	if got := bob.GetFileManagerStats().PeerChunksServed; got < received {
+		t.Fatalf("bob served %d chunks, carol received %d from peers", got, received)
+	}
+	if assigned := c.server.GetSwarmStats()["peer_assigned"].(uint64); assigned != uint64(received) {
+		t.Fatalf("server assigned %d chunks to peers, carol received %d", assigned, received)
+	}
+	if sent := c.server.GetSwarmStats()["server_chunks"].(uint64) - serverChunks; sent+uint64(received) != uint64(task.TotalChunks) {
+		t.Fatalf("server sent %d chunks, peers %d, file has %d", sent, received, task.TotalChunks)
+	}
+
+	// 再次下载直接命中本地缓存
+	download(carol, "carol-again.bin")
+	if hits := carol.GetFileManagerStats().CacheHits; hits != 1 {
+		t.Fatalf("expected a cache hit, got %d", hits)
+	}
+	eventually(t, "carol to announce the cached file", func() bool { return advertisements() >= 6 })
+
+	// 持有者的缓存被篡改：请求方按分块哈希丢弃，改向服务端补要
+	for _, holder := range []*node{bob, carol} {
+		path := holder.db.GetBlobStore().Path(hash)
+		if err := os.WriteFile(path, bytes.Repeat([]byte{0x5a}, len(content)), 0644); err != nil {
+			t.Fatalf("corrupt cache: %v", err)
+		}
+	}
+	download(dave, "dave.bin")
+	stats := dave.GetFileManagerStats()
+	if stats.PeerChunksReceived != 0 || stats.FailedDownloads != 0 {
+		t.Fatalf("tampered chunks reached the requester: %d peer chunks, %d failures",
+			stats.PeerChunksReceived, stats.FailedDownloads)
+	}
+	if stats.PeerChunksRejected == 0 {
+		t.Fatalf("dave did not reject tampered peer chunks")
+	}
+}
+
+// TestFolderUpload 目录打包为一个文件包上传，成员可按清单只解出部分条目（只下载所需分块），也可下载整个 tar
+func TestFolderUpload(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	dir := filepath.Join(alice.dataDir, "exploit")
+	files := map[string][]byte{
+		"solve.py":        []byte("from pwn import *\nprint('flag{bundle}')\n"),
+		"lib/helpers.py":  bytes.Repeat([]byte("# helper\n"), 5000),
+		"lib/rop/gadgets": {0x7f, 'E', 'L', 'F', 0, 1, 2, 3},
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("create folder: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "solve.py"), 0755); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	// 符号链接不打包
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	task, err := alice.UploadFolder(dir)
+	if err != nil {
+		t.Fatalf("upload folder: %v", err)
+	}
+	if task.Filename != "exploit.tar" || task.Bundle.Files != len(files) || task.Bundle.Skipped != 1 {
+		t.Fatalf("unexpected bundle: %s, %d files, %d skipped", task.Filename, task.Bundle.Files, task.Bundle.Skipped)
+	}
+
+	// 成员收到带清单的文件消息
+	eventually(t, "bob to receive the bundle manifest", func() bool {
+		f, err := bob.db.FileRepo().GetByID(task.ID)
+		return err == nil && f.Metadata["bundle"] != nil
+	})
+	stored, err := c.serverDB.FileRepo().GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("server file record: %v", err)
+	}
+	if stored.MimeType != "application/x-tar" || stored.Metadata["bundle"] == nil {
+		t.Fatalf("server did not keep the bundle manifest: %s %v", stored.MimeType, stored.Metadata)
+	}
+	// 上传者把包提交到本地缓存
+	eventually(t, "alice to cache the bundle", func() bool { return alice.db.GetBlobStore().Has(task.SHA256) })
+
+	done := make(chan struct{}, 4)
+	bob.bus.Subscribe(events.EventFileDownloadCompleted, func(ev *events.Event) {
+		if fe, ok := ev.Data.(events.FileEvent); ok && fe.File != nil && fe.File.ID == task.ID {
+			done <- struct{}{}
+		}
+	})
+	wait := func(what string) {
+		select {
+		case <-done:
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	// 只解出单个条目：只请求覆盖该条目字节范围的分块，包不进入缓存
+	single := filepath.Join(bob.dataDir, "single")
+	entryTask, err := bob.DownloadBundle(task.ID, single, []string{"solve.py"})
+	if err != nil {
+		t.Fatalf("download bundle entry: %v", err)
+	}
+	wait("single entry extraction")
+	if got, err := os.ReadFile(filepath.Join(single, "solve.py")); err != nil || !bytes.Equal(got, files["solve.py"]) {
+		t.Fatalf("solve.py not extracted correctly: %v", err)
+	}
+	if entryTask.ReceivedChunks == 0 || entryTask.ReceivedChunks >= entryTask.TotalChunks {
+		t.Fatalf("single entry fetched %d/%d chunks", entryTask.ReceivedChunks, entryTask.TotalChunks)
+	}
+	if bob.db.GetBlobStore().Has(task.SHA256) {
+		t.Fatalf("partial download was committed to the cache")
+	}
+	if _, err := os.Stat(filepath.Join(single, "lib")); !os.IsNotExist(err) {
+		t.Fatalf("unselected entry was extracted")
+	}
+
+	// 只解出 lib 子目录
+	partial := filepath.Join(bob.dataDir, "partial")
+	if _, err := bob.DownloadBundle(task.ID, partial, []string{"lib"}); err != nil {
+		t.Fatalf("download bundle entries: %v", err)
+	}
+	wait("partial extraction")
+	for _, name := range []string{"lib/helpers.py", "lib/rop/gadgets"} {
+		got, err := os.ReadFile(filepath.Join(partial, filepath.FromSlash(name)))
+		if err != nil || !bytes.Equal(got, files[name]) {
+			t.Fatalf("%s not extracted correctly: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(partial, "solve.py")); !os.IsNotExist(err) {
+		t.Fatalf("unselected entry was extracted")
+	}
+
+	// 解出全部条目：包已在本地缓存，不再经网络；权限位随条目还原
+	full := filepath.Join(bob.dataDir, "full")
+	if _, err := bob.DownloadBundle(task.ID, full, nil); err != nil {
+		t.Fatalf("download bundle: %v", err)
+	}
+	wait("full extraction")
+	if hits := bob.GetFileManagerStats().CacheHits; hits != 1 {
+		t.Fatalf("expected the second extraction to hit the cache, got %d hits", hits)
+	}
+	info, err := os.Stat(filepath.Join(full, "solve.py"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("solve.py not restored with its mode: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(full, "passwd")); !os.IsNotExist(err) {
+		t.Fatalf("symlink was packed")
+	}
+
+	// 整个 tar 与上传者缓存的包一致
+	tarPath := filepath.Join(bob.dataDir, "exploit.tar")
+	if _, err := bob.DownloadFile(task.ID, tarPath); err != nil {
+		t.Fatalf("download tar: %v", err)
+	}
+	wait("tar download")
+	got, err := os.ReadFile(tarPath)
+	if err != nil {
+		t.Fatalf("read tar: %v", err)
+	}
+	sum := sha256.Sum256(got)
+	if hex.EncodeToString(sum[:]) != task.SHA256 {
+		t.Fatalf("downloaded tar differs from the uploaded bundle")
+	}
+}
@@ -0,0 +1,345 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"crosswire/internal/bridge"
+	"crosswire/internal/client"
+	"crosswire/internal/events"
+	"crosswire/internal/models"
+	"crosswire/internal/server"
+	"crosswire/internal/storage"
+	"crosswire/internal/transport"
+	"crosswire/internal/utils"
+)
+
+// 多节点集成测试夹具
+// 一个 server.Server 与若干 client.Client 通过进程内回环传输互联，
+// 各节点使用独立的临时 SQLite 数据库，可通过 LoopbackNetwork.SetFaults 注入链路故障。
+
+const (
+	testPassword = "integration-secret"
+	testTimeout  = 10 * time.Second
+	pollInterval = 20 * time.Millisecond
+	loopbackBase = 41000
+)
+
+var nextPort atomic.Int32
+
+// cluster 测试集群
+type cluster struct {
+	t         *testing.T
+	channelID string
+	port      int
+
+	network      *transport.LoopbackNetwork
+	server       *server.Server
+	serverDB     *storage.Database
+	serverConfig *server.ServerConfig
+	logger       *utils.Logger
+	clients      []*node
+}
+
+// node 集群中的客户端节点
+type node struct {
+	*client.Client
+	db       *storage.Database
+	bus      *events.EventBus
+	dataDir  string
+	sharedDB bool // 数据库由其他节点持有，关闭时不随节点关闭
+}
+
+// newCluster 启动服务端并返回集群（测试结束时自动清理）
+func newCluster(t *testing.T) *cluster {
+	t.Helper()
+	return newClusterWith(t, nil)
+}
+
+// newClusterWith 同 newCluster，启动前可调整服务端配置
+func newClusterWith(t *testing.T, configure func(cfg *server.ServerConfig)) *cluster {
+	t.Helper()
+
+	c := &cluster{
+		t:         t,
+		channelID: fmt.Sprintf("it-%s-%d", strings.ToLower(t.Name()), time.Now().UnixNano()),
+		port:      loopbackBase + int(nextPort.Add(1)),
+		network:   transport.DefaultLoopbackNetwork(),
+	}
+	c.channelID = strings.NewReplacer("/", "-", " ", "-").Replace(c.channelID)
+
+	dir := t.TempDir()
+	db, err := storage.NewDatabase(&storage.Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("open server database: %v", err)
+	}
+	c.serverDB = db
+
+	logger, err := utils.NewLogger(utils.LogLevelWarn, dir+"/logs")
+	if err != nil {
+		t.Fatalf("create server logger: %v", err)
+	}
+	c.logger = logger
+
+	cfg := &server.ServerConfig{
+		ChannelID:       c.channelID,
+		ChannelPassword: testPassword,
+		ChannelName:     "integration",
+		MaxMembers:      16,
+		TransportMode:   models.TransportLoopback,
+		TransportConfig: &transport.Config{
+			Mode:   models.TransportLoopback,
+			Port:   c.port,
+			Logger: logger,
+		},
+		SessionTimeout:  time.Hour,
+		MaxMessageSize:  1 << 20,
+		MessageTTL:      time.Hour,
+		EnableOffline:   true,
+		EnableRateLimit: false,
+		MaxMessageRate:  1000,
+		EnableSignature: true,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	c.serverConfig = cfg
+	c.startServer()
+
+	t.Cleanup(c.close)
+	return c
+}
+
+// startServer 以集群的数据库与配置创建并启动服务端
+func (c *cluster) startServer() {
+	c.t.Helper()
+
+	srv, err := server.NewServer(c.serverConfig, c.serverDB, events.NewEventBus(nil), c.logger)
+	if err != nil {
+		c.t.Fatalf("create server: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		c.t.Fatalf("start server: %v", err)
+	}
+	c.server = srv
+}
+
+// restartServer 停止服务端后以同一数据库重新启动（已连接的客户端随之断开）
+func (c *cluster) restartServer() {
+	c.t.Helper()
+
+	if err := c.server.Stop(); err != nil {
+		c.t.Fatalf("stop server: %v", err)
+	}
+	c.startServer()
+}
+
+// join 启动一个客户端并等待加入完成
+func (c *cluster) join(nickname string) *node {
+	c.t.Helper()
+	return c.joinWith(nickname, nil)
+}
+
+// joinWith 启动一个客户端并等待加入完成，configure 可在启动前调整客户端配置
+func (c *cluster) joinWith(nickname string, configure func(cfg *client.Config)) *node {
+	c.t.Helper()
+
+	dir := c.t.TempDir()
+	db, err := storage.NewDatabase(&storage.Config{DataDir: dir})
+	if err != nil {
+		c.t.Fatalf("open client database: %v", err)
+	}
+	n := c.joinOn(nickname, db, dir, configure)
+	n.sharedDB = false
+	return n
+}
+
+// joinOn 使用已有数据库启动一个客户端（同一数据库可同时打开多个频道库）
+func (c *cluster) joinOn(nickname string, db *storage.Database, dir string, configure func(cfg *client.Config)) *node {
+	c.t.Helper()
+
+	bus := events.NewEventBus(nil)
+
+	cfg := client.DefaultConfig()
+	cfg.ChannelID = c.channelID
+	cfg.ChannelPassword = testPassword
+	cfg.Nickname = nickname
+	cfg.TransportMode = models.TransportLoopback
+	cfg.TransportConfig = &transport.Config{
+		Mode: models.TransportLoopback,
+		Port: c.port,
+	}
+	cfg.JoinTimeout = testTimeout
+	cfg.SyncInterval = 200 * time.Millisecond
+	cfg.DataDir = dir
+	if configure != nil {
+		configure(cfg)
+	}
+
+	cli, err := client.NewClient(cfg, db, bus)
+	if err != nil {
+		c.t.Fatalf("create client %s: %v", nickname, err)
+	}
+	if err := cli.Start(); err != nil {
+		c.t.Fatalf("start client %s: %v", nickname, err)
+	}
+
+	n := &node{Client: cli, db: db, bus: bus, dataDir: dir, sharedDB: true}
+	c.clients = append(c.clients, n)
+	return n
+}
+
+// bridgeTo 启动桥接器：以客户端身份加入 upstream 频道，与本集群服务端双向转发
+func (c *cluster) bridgeTo(upstream *cluster) *bridge.Bridge {
+	c.t.Helper()
+
+	dir := c.t.TempDir()
+	db, err := storage.NewDatabase(&storage.Config{DataDir: dir})
+	if err != nil {
+		c.t.Fatalf("open bridge database: %v", err)
+	}
+	logger, err := utils.NewLogger(utils.LogLevelWarn, dir+"/logs")
+	if err != nil {
+		c.t.Fatalf("create bridge logger: %v", err)
+	}
+
+	cfg := bridge.DefaultConfig()
+	cfg.Logger = logger
+	cfg.RetryInterval = 200 * time.Millisecond
+	cfg.Upstream = client.DefaultConfig()
+	cfg.Upstream.ChannelID = upstream.channelID
+	cfg.Upstream.ChannelPassword = testPassword
+	cfg.Upstream.Nickname = "bridge"
+	cfg.Upstream.TransportMode = models.TransportLoopback
+	cfg.Upstream.TransportConfig = &transport.Config{
+		Mode: models.TransportLoopback,
+		Port: upstream.port,
+	}
+	cfg.Upstream.JoinTimeout = testTimeout
+	cfg.Upstream.SyncInterval = 200 * time.Millisecond
+	cfg.Upstream.DataDir = dir
+
+	br, err := bridge.New(cfg, c.server, db)
+	if err != nil {
+		c.t.Fatalf("create bridge: %v", err)
+	}
+	if err := br.Start(); err != nil {
+		c.t.Fatalf("start bridge: %v", err)
+	}
+	c.t.Cleanup(func() {
+		_ = br.Stop()
+		_ = db.Close()
+	})
+	return br
+}
+
+// close 停止所有节点并恢复理想链路
+func (c *cluster) close() {
+	for i := len(c.clients) - 1; i >= 0; i-- {
+		n := c.clients[i]
+		_ = n.Stop()
+		n.bus.Close()
+		if !n.sharedDB {
+			_ = n.db.Close()
+		}
+	}
+	if c.server != nil {
+		_ = c.server.Stop()
+	}
+	if c.serverDB != nil {
+		_ = c.serverDB.Close()
+	}
+	c.network.SetFaults(transport.LoopbackFaults{})
+}
+
+// fastReconnect 缩短心跳、存活超时与重连退避，使连接丢失在测试时限内被检测并恢复
+func fastReconnect(cfg *client.Config) {
+	cfg.HeartbeatInterval = 200 * time.Millisecond
+	cfg.LivenessTimeout = time.Second
+	cfg.ReconnectDelay = 100 * time.Millisecond
+	cfg.ReconnectMaxDelay = 200 * time.Millisecond
+	cfg.MaxReconnectAttempts = 0
+	cfg.JoinTimeout = 2 * time.Second
+}
+
+// reconnects 订阅节点回到 joined 状态的事件，通道中的值表示是否以续连令牌恢复了原会话
+func (n *node) reconnects() <-chan bool {
+	ch := make(chan bool, 4)
+	n.bus.Subscribe(events.EventSystemReconnect, func(ev *events.Event) {
+		se, ok := ev.Data.(*events.SystemEvent)
+		if !ok || se.Type != string(client.StateJoined) {
+			return
+		}
+		if data, ok := se.Data.(map[string]interface{}); ok {
+			resumed, _ := data["resumed"].(bool)
+			select {
+			case ch <- resumed:
+			default:
+			}
+		}
+	})
+	return ch
+}
+
+// awaitResumed 等待节点重新连接，并要求以续连令牌恢复了原会话
+func awaitResumed(t *testing.T, ch <-chan bool) {
+	t.Helper()
+
+	select {
+	case resumed := <-ch:
+		if !resumed {
+			t.Fatalf("expected session to be resumed, got rejoin")
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for reconnect")
+	}
+}
+
+// eventually 轮询直到条件成立或超时
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(pollInterval)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// countText 统计节点本地库中文本内容为 text 的消息数
+func (n *node) countText(text string) int {
+	msgs, err := n.GetMessages(1000, 0)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, m := range msgs {
+		if m.ContentText == text {
+			count++
+		}
+	}
+	return count
+}
+
+// readStored 通过文件仓库读取已存储的完整文件内容
+func readStored(t *testing.T, db *storage.Database, file *models.File) []byte {
+	t.Helper()
+
+	content, err := db.FileRepo().OpenContent(file)
+	if err != nil {
+		t.Fatalf("open stored file %s: %v", file.ID, err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatalf("read stored file %s: %v", file.ID, err)
+	}
+	return data
+}
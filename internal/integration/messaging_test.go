@@ -0,0 +1,139 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"crosswire/internal/models"
+	"crosswire/internal/server"
+)
+
+// TestJoinAndFanOut 多个客户端加入频道，消息经服务端签名后扇出到所有成员
+func TestJoinAndFanOut(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.join("bob")
+	carol := c.join("carol")
+
+	// 加入：各自获得不同的成员ID，服务端登记全部成员
+	ids := map[string]bool{}
+	for _, n := range []*node{alice, bob, carol} {
+		if n.GetMemberID() == "" {
+			t.Fatalf("client has no member ID after join")
+		}
+		ids[n.GetMemberID()] = true
+	}
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 distinct member IDs, got %v", ids)
+	}
+	eventually(t, "server to register all members", func() bool {
+		members, err := c.server.GetMembers()
+		if err != nil {
+			return false
+		}
+		found := 0
+		for _, m := range members {
+			if ids[m.ID] {
+				found++
+			}
+		}
+		return found == 3
+	})
+
+	// 扇出：每个成员都收到（含发送者本人的回显）
+	if err := alice.SendMessage("hello from alice", models.MessageTypeText); err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	for _, n := range []*node{alice, bob, carol} {
+		n := n
+		eventually(t, "fan-out to "+n.GetMemberID(), func() bool {
+			return n.countText("hello from alice") == 1
+		})
+	}
+}
+
+// TestSyncCatchUp 后加入的成员通过同步获取加入前的历史消息
+func TestSyncCatchUp(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+
+	for _, text := range []string{"history 1", "history 2", "history 3"} {
+		if err := alice.SendMessage(text, models.MessageTypeText); err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+	}
+	eventually(t, "history to reach alice", func() bool {
+		return alice.countText("history 3") == 1
+	})
+
+	late := c.join("late")
+	for _, text := range []string{"history 1", "history 2", "history 3"} {
+		text := text
+		eventually(t, "late joiner to sync "+text, func() bool {
+			return late.countText(text) == 1
+		})
+	}
+}
+
+// TestBridgeRelay 桥接器在两个频道间双向转发，保留消息ID并附带可校验的来源链，且不产生回环
+func TestBridgeRelay(t *testing.T) {
+	upstream := newCluster(t)
+	local := newCluster(t)
+	alice := upstream.join("alice")
+	bob := local.join("bob")
+	br := local.bridgeTo(upstream)
+
+	// 上游 → 本地
+	if err := alice.SendMessage("from upstream", models.MessageTypeText); err != nil {
+		t.Fatalf("send upstream message: %v", err)
+	}
+	eventually(t, "relay to local channel", func() bool {
+		return bob.countText("from upstream") == 1
+	})
+
+	// 本地 → 上游
+	if err := bob.SendMessage("from local", models.MessageTypeText); err != nil {
+		t.Fatalf("send local message: %v", err)
+	}
+	eventually(t, "relay to upstream channel", func() bool {
+		return alice.countText("from local") == 1
+	})
+
+	// 来源链：本地副本由本地服务器签名，原始发送者为 alice
+	msgs, err := bob.GetMessages(1000, 0)
+	if err != nil {
+		t.Fatalf("get messages: %v", err)
+	}
+	var relayed *models.Message
+	for _, m := range msgs {
+		if m.ContentText == "from upstream" {
+			relayed = m
+		}
+	}
+	if relayed == nil {
+		t.Fatalf("relayed message not found in local channel")
+	}
+	hops, err := server.VerifyProvenance(relayed)
+	if err != nil {
+		t.Fatalf("verify provenance: %v", err)
+	}
+	if len(hops) != 1 || hops[0].ServerID != local.channelID || hops[0].Origin != upstream.channelID {
+		t.Fatalf("unexpected provenance: %+v", hops)
+	}
+	if origin, _ := server.OriginSender(relayed); origin != alice.GetMemberID() {
+		t.Fatalf("expected origin sender %s, got %s", alice.GetMemberID(), origin)
+	}
+
+	// 回环：等待回显到达后两侧都只有一份
+	time.Sleep(500 * time.Millisecond)
+	if n := bob.countText("from upstream"); n != 1 {
+		t.Fatalf("local channel has %d copies of upstream message", n)
+	}
+	if n := alice.countText("from local"); n != 1 {
+		t.Fatalf("upstream channel has %d copies of local message", n)
+	}
+	stats := br.GetStats()
+	if stats.RelayedDown != 1 || stats.RelayedUp != 1 {
+		t.Fatalf("unexpected bridge stats: down=%d up=%d", stats.RelayedDown, stats.RelayedUp)
+	}
+}
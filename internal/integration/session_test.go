@@ -0,0 +1,138 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"crosswire/internal/client"
+	"crosswire/internal/crypto"
+	"crosswire/internal/models"
+	"crosswire/internal/server"
+	"crosswire/internal/transport"
+)
+
+// TestSessionResume 链路中断后客户端检测到连接丢失，恢复后以续连令牌恢复原会话并发送离线期间排队的消息
+func TestSessionResume(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.joinWith("bob", fastReconnect)
+	memberID := bob.GetMemberID()
+	reconnected := bob.reconnects()
+
+	c.network.SetFaults(transport.LoopbackFaults{LossRate: 1})
+	eventually(t, "bob to detect the lost connection", func() bool {
+		return bob.GetConnectionState() == client.StateReconnecting
+	})
+	if err := bob.SendMessage("while offline", models.MessageTypeText); err != nil {
+		t.Fatalf("send message while offline: %v", err)
+	}
+	c.network.SetFaults(transport.LoopbackFaults{})
+
+	awaitResumed(t, reconnected)
+	if bob.GetMemberID() != memberID {
+		t.Fatalf("member ID changed across resume: %s -> %s", memberID, bob.GetMemberID())
+	}
+	eventually(t, "queued message to reach alice", func() bool {
+		return alice.countText("while offline") == 1
+	})
+}
+
+// TestSessionPersistence 服务端重启后从数据库恢复会话：客户端续连原会话，其签名的控制消息继续被接受
+func TestSessionPersistence(t *testing.T) {
+	c := newCluster(t)
+	bob := c.joinWith("bob", fastReconnect)
+	memberID := bob.GetMemberID()
+	reconnected := bob.reconnects()
+
+	c.restartServer()
+	awaitResumed(t, reconnected)
+
+	if err := bob.UpdateStatus(models.StatusBusy); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	eventually(t, "status update to be accepted", func() bool {
+		m, err := c.serverDB.MemberRepo().GetByID(memberID)
+		return err == nil && m.Status == models.StatusBusy
+	})
+}
+
+// TestForgedControlRejected 持有频道密钥但没有成员签名私钥时，冒用成员身份的控制消息被拒绝
+func TestForgedControlRejected(t *testing.T) {
+	c := newCluster(t)
+	bob := c.join("bob")
+	memberID := bob.GetMemberID()
+
+	cm, err := crypto.NewManager()
+	if err != nil {
+		t.Fatalf("create crypto manager: %v", err)
+	}
+	key, err := cm.DeriveKey(testPassword, []byte(c.channelID))
+	if err != nil {
+		t.Fatalf("derive channel key: %v", err)
+	}
+	cm.SetChannelKey(key)
+
+	attacker := transport.NewLoopbackTransportOn(c.network)
+	if err := attacker.Init(&transport.Config{Mode: models.TransportLoopback, Port: c.port}); err != nil {
+		t.Fatalf("init attacker transport: %v", err)
+	}
+	attacker.SetMode("client")
+	if err := attacker.Start(); err != nil {
+		t.Fatalf("start attacker transport: %v", err)
+	}
+	defer attacker.Stop()
+	if err := attacker.Connect(""); err != nil {
+		t.Fatalf("connect attacker transport: %v", err)
+	}
+
+	update, _ := json.Marshal(map[string]interface{}{
+		"type":      "status.update",
+		"member_id": memberID,
+		"status":    models.StatusBusy,
+	})
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	forged, _ := json.Marshal(&server.SignedControl{
+		MemberID:  memberID,
+		Nonce:     "forged",
+		Timestamp: time.Now().Unix(),
+		Payload:   update,
+		Signature: ed25519.Sign(otherKey, update),
+	})
+
+	before := c.server.GetStats().RejectedMessages
+	for _, payload := range [][]byte{update, forged} {
+		encrypted, err := cm.EncryptMessage(payload)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		if err := attacker.SendMessage(&transport.Message{
+			Type:     transport.MessageTypeControl,
+			SenderID: memberID,
+			Payload:  encrypted,
+		}); err != nil {
+			t.Fatalf("send forged control: %v", err)
+		}
+	}
+
+	// 传输层文件帧绕过控制消息签名，同样被拒绝
+	if err := attacker.SendFile(&transport.FileTransfer{
+		FileID:      "forged-file",
+		ChunkIndex:  0,
+		TotalChunks: 1,
+		Data:        []byte("forged"),
+	}); err != nil {
+		t.Fatalf("send forged file frame: %v", err)
+	}
+
+	eventually(t, "forged control messages to be rejected", func() bool {
+		return c.server.GetStats().RejectedMessages >= before+3
+	})
+	if f, err := c.serverDB.FileRepo().GetByID("forged-file"); err == nil && f != nil {
+		t.Fatalf("forged file frame stored: %+v", f)
+	}
+	if m, err := c.serverDB.MemberRepo().GetByID(memberID); err != nil || m.Status == models.StatusBusy {
+		t.Fatalf("forged status update applied: %+v, %v", m, err)
+	}
+}
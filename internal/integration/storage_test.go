@@ -0,0 +1,345 @@
+package integration
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crosswire/internal/models"
+	"crosswire/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// TestSharedDatabaseMultipleChannels 同一数据库同时承载本频道服务端与另一频道的客户端，各自写入所属频道库
+func TestSharedDatabaseMultipleChannels(t *testing.T) {
+	a := newCluster(t)
+	b := newCluster(t)
+	alice := a.join("alice")
+	// bob 加入频道 b，但与频道 a 的服务端共用一个数据库
+	bob := b.joinOn("bob", a.serverDB, t.TempDir(), nil)
+
+	if got := a.serverDB.OpenChannels(); len(got) != 2 {
+		t.Fatalf("expected both channels open, got %v", got)
+	}
+	if got := a.server.GetDatabase().ChannelID(); got != a.channelID {
+		t.Fatalf("server bound to %s, want %s", got, a.channelID)
+	}
+	if got := bob.GetDatabase().ChannelID(); got != b.channelID {
+		t.Fatalf("client bound to %s, want %s", got, b.channelID)
+	}
+
+	if err := alice.SendMessage("in channel a", models.MessageTypeText); err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	if err := bob.SendMessage("in channel b", models.MessageTypeText); err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	eventually(t, "alice to receive her echo", func() bool { return alice.countText("in channel a") == 1 })
+	eventually(t, "bob to receive his echo", func() bool { return bob.countText("in channel b") == 1 })
+
+	// 两个频道库各自只包含本频道的消息
+	count := func(channelID, text string) int64 {
+		view, err := a.serverDB.ForChannel(channelID)
+		if err != nil {
+			t.Fatalf("bind channel %s: %v", channelID, err)
+		}
+		var n int64
+		view.GetChannelDB().Model(&models.Message{}).Where("content_text = ?", text).Count(&n)
+		return n
+	}
+	eventually(t, "channel a message to be stored", func() bool { return count(a.channelID, "in channel a") == 1 })
+	if n := count(a.channelID, "in channel b"); n != 0 {
+		t.Fatalf("channel b message leaked into channel a database (%d rows)", n)
+	}
+	if n := count(b.channelID, "in channel b"); n != 1 {
+		t.Fatalf("expected channel b message in its own database, got %d rows", n)
+	}
+	if n := count(b.channelID, "in channel a"); n != 0 {
+		t.Fatalf("channel a message leaked into channel b database (%d rows)", n)
+	}
+
+	// 未绑定访问仍指向首个打开的频道（服务端所在频道）
+	if got := a.serverDB.ChannelID(); got != a.channelID {
+		t.Fatalf("default channel changed to %s, want %s", got, a.channelID)
+	}
+}
+
+// TestSchemaMigrations 引入版本表之前的旧频道库升级到最新版本（先备份，补齐缺失的列，回填并删除兼容列），版本更高的库拒绝打开
+func TestSchemaMigrations(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*storage.Database, *storage.Database, error) {
+		db, err := storage.NewDatabase(&storage.Config{DataDir: dir})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		view, err := db.ForChannel("legacy")
+		return db, view, err
+	}
+
+	// 构造旧库：没有 schema_version，缺少较新的列，带有重复的兼容列，joined_at 只写在 join_time 中
+	db, view, err := open()
+	if err != nil {
+		t.Fatalf("open channel: %v", err)
+	}
+	joined := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, stmt := range []string{
+		"DROP TABLE schema_version",
+		"ALTER TABLE members ADD COLUMN join_time datetime",
+		"ALTER TABLE messages ADD COLUMN is_pinned integer DEFAULT 0",
+		"CREATE INDEX idx_messages_is_pinned ON messages(is_pinned)",
+		"ALTER TABLE files DROP COLUMN preview_text",
+	} {
+		if err := view.GetChannelDB().Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := view.GetChannelDB().Exec("UPDATE members SET join_time = ?, joined_at = ? WHERE id = 'system'",
+		joined, time.Time{}).Error; err != nil {
+		t.Fatalf("write legacy member: %v", err)
+	}
+	db.Close()
+
+	// 升级
+	db, view, err = open()
+	if err != nil {
+		t.Fatalf("upgrade legacy channel database: %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "channels", "legacy.db.v0-*.bak"))
+	if len(backups) != 1 {
+		t.Fatalf("expected one pre-migration backup, got %v", backups)
+	}
+	migrator := view.GetChannelDB().Migrator()
+	if migrator.HasColumn("members", "join_time") || migrator.HasColumn("messages", "is_pinned") {
+		t.Fatalf("legacy columns were not dropped")
+	}
+	if !migrator.HasColumn("files", "preview_text") {
+		t.Fatalf("baseline did not add the column missing from the legacy database")
+	}
+	sys, err := view.MemberRepo().GetByID("system")
+	if err != nil {
+		t.Fatalf("load system member: %v", err)
+	}
+	if !sys.JoinedAt.Equal(joined) || !sys.JoinTime.Equal(joined) {
+		t.Fatalf("joined_at not backfilled: joined_at=%v join_time=%v, want %v", sys.JoinedAt, sys.JoinTime, joined)
+	}
+
+	// 重新打开最新版本的库不再迁移，也不再备份
+	db.Close()
+	db, view, err = open()
+	if err != nil {
+		t.Fatalf("reopen channel database: %v", err)
+	}
+	if again, _ := filepath.Glob(filepath.Join(dir, "channels", "legacy.db.v*.bak")); len(again) != 1 {
+		t.Fatalf("unexpected backups after reopening: %v", again)
+	}
+
+	// 降级：库版本高于本程序已知的最新迁移
+	if err := view.GetChannelDB().Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		1000, "from_the_future", time.Now()).Error; err != nil {
+		t.Fatalf("write future schema version: %v", err)
+	}
+	db.Close()
+	db, _, err = open()
+	defer db.Close()
+	if !errors.Is(err, storage.ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew when opening a newer database, got %v", err)
+	}
+}
+
+// TestSchemaCoversModels 迁移建立的表结构覆盖模型的全部持久化字段（模型新增字段而未追加迁移时失败）
+func TestSchemaCoversModels(t *testing.T) {
+	db, err := storage.NewDatabase(&storage.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	view, err := db.ForChannel("schema")
+	if err != nil {
+		t.Fatalf("open channel: %v", err)
+	}
+
+	check := func(gdb *gorm.DB, values ...interface{}) {
+		for _, value := range values {
+			stmt := &gorm.Statement{DB: gdb}
+			if err := stmt.Parse(value); err != nil {
+				t.Fatalf("parse %T: %v", value, err)
+			}
+			if !gdb.Migrator().HasTable(stmt.Schema.Table) {
+				t.Errorf("table %s for %T is not created by any migration", stmt.Schema.Table, value)
+				continue
+			}
+			for _, field := range stmt.Schema.Fields {
+				if field.DBName == "" {
+					continue
+				}
+				if !gdb.Migrator().HasColumn(stmt.Schema.Table, field.DBName) {
+					t.Errorf("column %s.%s (%T.%s) is not created by any migration",
+						stmt.Schema.Table, field.DBName, value, field.Name)
+				}
+			}
+		}
+	}
+	check(view.GetChannelDB(),
+		&models.Channel{}, &models.Member{}, &models.MemberSession{}, &models.Message{},
+		&models.MessageReaction{}, &models.TypingStatus{}, &models.File{}, &models.FileChunk{},
+		&models.AuditLog{}, &models.MuteRecord{}, &models.PinnedMessage{}, &models.Challenge{},
+		&models.ChallengeAssignment{}, &models.ChallengeProgress{}, &models.ChallengeSubmission{})
+	check(db.GetUserDB(), &models.UserProfile{}, &models.RecentChannel{})
+	check(db.GetCacheDB(), &models.CacheEntry{})
+}
+
+// TestEncryptionAtRest 以口令打开明文数据目录后，已有与新写入的敏感列、文件内容均加密保存，经仓库读取仍为明文；
+// 未提供口令或口令错误时拒绝打开
+func TestEncryptionAtRest(t *testing.T) {
+	dir := t.TempDir()
+	const passphrase = "correct horse battery"
+	open := func(pass string) (*storage.Database, *storage.Database, error) {
+		db, err := storage.NewDatabase(&storage.Config{DataDir: dir, Passphrase: pass})
+		if err != nil {
+			return nil, nil, err
+		}
+		view, err := db.ForChannel("vault")
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return db, view, nil
+	}
+	newMessage := func(id, text string) *models.Message {
+		return &models.Message{
+			ID:             id,
+			ChannelID:      "vault",
+			SenderID:       "system",
+			SenderNickname: "System",
+			Type:           models.MessageTypeText,
+			Content:        models.MessageContent{"text": text},
+			ContentText:    text,
+			Timestamp:      time.Now(),
+		}
+	}
+	rawColumn := func(view *storage.Database, query string, args ...interface{}) string {
+		var raw string
+		if err := view.GetChannelDB().Raw(query, args...).Scan(&raw).Error; err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return raw
+	}
+
+	// 明文数据目录：写入消息、频道密钥、私钥与跨多个分段的文件内容
+	db, view, err := open("")
+	if err != nil {
+		t.Fatalf("open plaintext database: %v", err)
+	}
+	if err := view.MessageRepo().Create(newMessage("m1", "flag{before_encryption}")); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	channelKey := bytes.Repeat([]byte{0x42}, 32)
+	if err := view.ChannelRepo().RotateEncryptionKey("vault", channelKey, 2); err != nil {
+		t.Fatalf("rotate channel key: %v", err)
+	}
+	privateKey := []byte("ed25519-private-key-material")
+	if err := db.GetUserDB().Create(&models.UserProfile{ID: "default", Nickname: "me", PrivateKey: privateKey, PublicKey: []byte("pub")}).Error; err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	content := bytes.Repeat([]byte("SECRET-BLOB-CONTENT "), 10000) // 200000 字节，4 个分段
+	blobs := view.GetBlobStore()
+	if err := blobs.WriteAt("up1", 0, content); err != nil {
+		t.Fatalf("write blob: %v", err)
+	}
+	hash, err := blobs.Commit("up1", int64(len(content)), "")
+	if err != nil {
+		t.Fatalf("commit blob: %v", err)
+	}
+	db.Close()
+
+	// 以口令打开即启用加密：已有明文被加密
+	db, view, err = open(passphrase)
+	if err != nil {
+		t.Fatalf("enable encryption: %v", err)
+	}
+	if !storage.IsEncrypted(dir) || !db.Encrypted() {
+		t.Fatalf("data directory not marked encrypted")
+	}
+	if err := view.MessageRepo().Create(newMessage("m2", "flag{after_encryption}")); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	for _, id := range []string{"m1", "m2"} {
+		raw := rawColumn(view, "SELECT CAST(content_text AS TEXT) FROM messages WHERE id = ?", id) +
+			rawColumn(view, "SELECT CAST(content AS TEXT) FROM messages WHERE id = ?", id)
+		if strings.Contains(raw, "flag{") || !strings.HasPrefix(raw, "$cwenc1$") {
+			t.Fatalf("message %s stored in plaintext: %q", id, raw)
+		}
+	}
+	if raw := rawColumn(view, "SELECT CAST(encryption_key AS TEXT) FROM channels WHERE id = ?", "vault"); !strings.HasPrefix(raw, "$cwenc1$") {
+		t.Fatalf("channel key stored in plaintext: %q", raw)
+	}
+	var rawPrivate string
+	db.GetUserDB().Raw("SELECT CAST(private_key AS TEXT) FROM user_profiles").Scan(&rawPrivate)
+	if strings.Contains(rawPrivate, string(privateKey)) {
+		t.Fatalf("private key stored in plaintext")
+	}
+	onDisk, err := os.ReadFile(blobs.Path(hash))
+	if err != nil {
+		t.Fatalf("read blob file: %v", err)
+	}
+	if bytes.Contains(onDisk, []byte("SECRET-BLOB")) {
+		t.Fatalf("blob stored in plaintext")
+	}
+
+	// 经仓库与内容存储读取为明文
+	m1, err := view.MessageRepo().GetByID("m1")
+	if err != nil || m1.ContentText != "flag{before_encryption}" || m1.Content["text"] != "flag{before_encryption}" {
+		t.Fatalf("decrypt message: %+v err=%v", m1, err)
+	}
+	found, err := view.MessageRepo().Search("vault", "FLAG{AFTER", 10, 0)
+	if err != nil || len(found) != 1 || found[0].ID != "m2" {
+		t.Fatalf("search encrypted messages: %v err=%v", found, err)
+	}
+	ch, err := view.ChannelRepo().GetByID("vault")
+	if err != nil || !bytes.Equal(ch.EncryptionKey, channelKey) {
+		t.Fatalf("decrypt channel key: err=%v", err)
+	}
+	var profile models.UserProfile
+	if err := db.GetUserDB().First(&profile).Error; err != nil || !bytes.Equal(profile.PrivateKey, privateKey) {
+		t.Fatalf("decrypt private key: err=%v", err)
+	}
+	reader, err := view.GetBlobStore().Open(hash)
+	if err != nil {
+		t.Fatalf("open blob: %v", err)
+	}
+	part := make([]byte, 1000)
+	offset := int64(64*1024 - 500) // 跨越分段边界
+	if n, err := reader.ReadAt(part, offset); err != nil || !bytes.Equal(part[:n], content[offset:offset+1000]) {
+		t.Fatalf("read across segment boundary: n=%d err=%v", n, err)
+	}
+	var whole bytes.Buffer
+	if _, err := whole.ReadFrom(reader); err != nil || !bytes.Equal(whole.Bytes(), content) {
+		t.Fatalf("read whole blob: len=%d err=%v", whole.Len(), err)
+	}
+	reader.Close()
+	db.Close()
+
+	// 未提供口令、口令错误时拒绝打开；修改口令后以新口令打开
+	if _, _, err := open(""); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("expected ErrLocked without passphrase, got %v", err)
+	}
+	if _, _, err := open("wrong passphrase"); !errors.Is(err, storage.ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := storage.ChangePassphrase(dir, passphrase, "new passphrase"); err != nil {
+		t.Fatalf("change passphrase: %v", err)
+	}
+	db, view, err = open("new passphrase")
+	if err != nil {
+		t.Fatalf("open with new passphrase: %v", err)
+	}
+	defer db.Close()
+	if m2, err := view.MessageRepo().GetByID("m2"); err != nil || m2.ContentText != "flag{after_encryption}" {
+		t.Fatalf("decrypt after passphrase change: err=%v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"crosswire/internal/client"
+	"crosswire/internal/models"
+	"crosswire/internal/transport"
+)
+
+// TestImpairedLink 链路存在时延、抖动与重复投递时，加入与扇出仍然正确且去重
+func TestImpairedLink(t *testing.T) {
+	c := newCluster(t)
+	c.network.SetFaults(transport.LoopbackFaults{
+		Latency:       2 * time.Millisecond,
+		Jitter:        3 * time.Millisecond,
+		DuplicateRate: 0.3,
+		Seed:          42,
+	})
+
+	alice := c.join("alice")
+	bob := c.join("bob")
+
+	texts := []string{"impaired 1", "impaired 2", "impaired 3", "impaired 4", "impaired 5"}
+	for _, text := range texts {
+		if err := alice.SendMessage(text, models.MessageTypeText); err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+	}
+	for _, text := range texts {
+		text := text
+		eventually(t, "bob to receive "+text, func() bool {
+			return bob.countText(text) == 1
+		})
+	}
+
+	stats := c.network.FaultStats()
+	if stats.Duplicated == 0 {
+		t.Fatalf("expected duplicated deliveries, got %+v", stats)
+	}
+}
+
+// TestAutoTransportFallback 自动模式跳过不可用的候选，切换传输后保留成员身份并继续收发
+func TestAutoTransportFallback(t *testing.T) {
+	c := newCluster(t)
+	alice := c.join("alice")
+	bob := c.joinWith("bob", func(cfg *client.Config) {
+		cfg.TransportMode = models.TransportAuto
+		cfg.TransportConfig = &transport.Config{
+			Mode:          models.TransportAuto,
+			ServerAddress: "127.0.0.1",
+			Port:          c.port, // HTTPS 候选连接被拒绝，回退到回环
+			AutoCandidates: []transport.TransportMode{
+				models.TransportHTTPS,
+				models.TransportLoopback,
+			},
+		}
+		cfg.ProbeTimeout = testTimeout
+	})
+
+	if mode := bob.GetActiveTransportMode(); mode != models.TransportLoopback {
+		t.Fatalf("expected auto mode to settle on loopback, got %s", mode)
+	}
+	memberID := bob.GetMemberID()
+
+	// 切换：HTTPS 仍不可用，循环回到回环后以原成员ID重新加入
+	if err := bob.SwitchTransport(); err != nil {
+		t.Fatalf("switch transport: %v", err)
+	}
+	if bob.GetMemberID() != memberID {
+		t.Fatalf("member ID changed across switch: %s -> %s", memberID, bob.GetMemberID())
+	}
+
+	if err := alice.SendMessage("after switch", models.MessageTypeText); err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	eventually(t, "delivery after switch", func() bool {
+		return bob.countText("after switch") == 1
+	})
+}
@@ -42,7 +42,7 @@ type UserProfile struct {
 	ID         string         `gorm:"primaryKey;type:text" json:"id"`
 	Nickname   string         `gorm:"type:text;not null" json:"nickname"`
 	Avatar     string         `gorm:"type:text" json:"avatar,omitempty"`
-	PrivateKey []byte         `gorm:"type:blob;not null" json:"-"` // 加密存储
+	PrivateKey []byte         `gorm:"type:blob;not null;serializer:encrypted" json:"-"` // 启用静态加密时加密存储
 	PublicKey  []byte         `gorm:"type:blob;not null" json:"-"`
 	Skills     SkillTags      `gorm:"type:text" json:"skills,omitempty"`
 	Expertise  ExpertiseArray `gorm:"type:text" json:"expertise,omitempty"`
@@ -95,7 +95,7 @@ func (RecentChannel) TableName() string {
 // CacheEntry 本地缓存（cache.db）
 type CacheEntry struct {
 	Key       string    `gorm:"primaryKey;type:text" json:"key"`
-	Value     []byte    `gorm:"type:blob;not null" json:"-"`
+	Value     []byte    `gorm:"type:blob;not null;serializer:encrypted" json:"-"`
 	ExpiresAt time.Time `gorm:"not null;index:idx_cache_expires" json:"expires_at"`
 	CreatedAt time.Time `gorm:"not null" json:"created_at"`
 }
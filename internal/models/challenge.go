@@ -17,7 +17,7 @@ type Challenge struct {
 	Points       int         `gorm:"type:integer;not null" json:"points"`
 	Description  string      `gorm:"type:text;not null" json:"description"`
 	FlagFormat   string      `gorm:"type:text" json:"flag_format,omitempty"`
-	Flag         string      `gorm:"type:text" json:"flag"` // 协作平台：明文存储，对所有人可见
+	Flag         string      `gorm:"type:text;serializer:encrypted" json:"flag"` // 协作平台：对频道成员明文可见（启用静态加密时在本地加密保存）
 	URL          string      `gorm:"type:text" json:"url,omitempty"`
 	Attachments  StringArray `gorm:"type:text" json:"attachments,omitempty"`
 	Tags         StringArray `gorm:"type:text" json:"tags,omitempty"`
@@ -144,7 +144,7 @@ type ChallengeSubmission struct {
 	ID           string    `gorm:"primaryKey;type:text" json:"id"`
 	ChallengeID  string    `gorm:"type:text;not null;index:idx_submissions_challenge" json:"challenge_id"`
 	MemberID     string    `gorm:"type:text;not null;index:idx_submissions_member" json:"member_id"`
-	Flag         string    `gorm:"type:text;not null" json:"flag"` // 协作平台：Flag对所有人可见
+	Flag         string    `gorm:"type:text;not null;serializer:encrypted" json:"flag"` // 协作平台：Flag对所有人可见
 	SubmittedAt  time.Time `gorm:"not null;index:idx_submissions_time" json:"submitted_at"`
 	IPAddress    string    `gorm:"type:text" json:"ip_address,omitempty"`
 	ResponseTime int       `gorm:"type:integer" json:"response_time,omitempty"` // 毫秒
@@ -19,7 +19,7 @@ type Channel struct {
 	TransportMode   TransportMode `gorm:"type:text;default:'auto'" json:"transport_mode"`
 	Port            int           `gorm:"type:integer" json:"port,omitempty"`
 	Interface       string        `gorm:"type:text" json:"interface,omitempty"`
-	EncryptionKey   []byte        `gorm:"type:blob;not null" json:"-"`
+	EncryptionKey   []byte        `gorm:"type:blob;not null;serializer:encrypted" json:"-"`
 	KeyVersion      int           `gorm:"type:integer;default:1" json:"key_version"`
 	MessageCount    int64         `gorm:"type:integer;default:0" json:"message_count"`
 	FileCount       int64         `gorm:"type:integer;default:0" json:"file_count"`
@@ -1,6 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,19 +23,20 @@ type File struct {
 	MimeType       string       `gorm:"type:text;not null" json:"mime_type"`
 	StorageType    StorageType  `gorm:"type:text;not null" json:"storage_type"`
 	StoragePath    string       `gorm:"type:text" json:"storage_path,omitempty"`
-	Data           []byte       `gorm:"type:blob" json:"-"`
+	Data           []byte       `gorm:"type:blob;serializer:encrypted" json:"-"`
 	SHA256         string       `gorm:"type:text;not null" json:"sha256"`
 	Checksum       string       `gorm:"type:text;not null" json:"checksum"`
 	ChunkSize      int          `gorm:"type:integer;default:8192" json:"chunk_size"`
 	TotalChunks    int          `gorm:"type:integer;not null" json:"total_chunks"`
 	UploadedChunks int          `gorm:"type:integer;default:0" json:"uploaded_chunks"`
+	ChunkBitmap    []byte       `gorm:"type:blob" json:"-"` // 已接收分块位图（第 i 位对应 chunk_index=i）
 	UploadStatus   UploadStatus `gorm:"type:text;default:'pending'" json:"upload_status"`
-	Thumbnail      []byte       `gorm:"type:blob" json:"thumbnail,omitempty"`
-	PreviewText    string       `gorm:"type:text" json:"preview_text,omitempty"`
+	Thumbnail      []byte       `gorm:"type:blob;serializer:encrypted" json:"thumbnail,omitempty"`
+	PreviewText    string       `gorm:"type:text;serializer:encrypted" json:"preview_text,omitempty"`
 	UploadedAt     time.Time    `gorm:"not null;index:idx_files_uploaded_at" json:"uploaded_at"`
 	ExpiresAt      time.Time    `gorm:"not null;index:idx_files_expires" json:"expires_at"`
 	Encrypted      bool         `gorm:"type:integer;default:1" json:"encrypted"`
-	EncryptionKey  []byte       `gorm:"type:blob" json:"-"`
+	EncryptionKey  []byte       `gorm:"type:blob;serializer:encrypted" json:"-"`
 	Metadata       JSONField    `gorm:"type:text" json:"metadata,omitempty"`
 
 	// 关联
@@ -60,11 +66,62 @@ func (f *File) IsExpired() bool {
 	return time.Now().After(f.ExpiresAt)
 }
 
+// ValidateLayout 检查大小与分块信息一致：size >= 0，chunk_size > 0，total_chunks = ceil(size/chunk_size)
+func (f *File) ValidateLayout() error {
+	if f.Size < 0 {
+		return fmt.Errorf("invalid file size: %d", f.Size)
+	}
+	if f.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", f.ChunkSize)
+	}
+	want := f.Size / int64(f.ChunkSize)
+	if f.Size%int64(f.ChunkSize) != 0 {
+		want++
+	}
+	if int64(f.TotalChunks) != want {
+		return fmt.Errorf("total chunks %d does not match size %d with chunk size %d", f.TotalChunks, f.Size, f.ChunkSize)
+	}
+	return nil
+}
+
+// HasChunk 检查分块是否已接收
+func (f *File) HasChunk(index int) bool {
+	if index < 0 || index/8 >= len(f.ChunkBitmap) {
+		return false
+	}
+	return f.ChunkBitmap[index/8]&(1<<(index%8)) != 0
+}
+
+// MarkChunk 在位图中标记分块已接收，返回 false 表示此前已标记（重复分块）
+func (f *File) MarkChunk(index int) bool {
+	if index < 0 || f.HasChunk(index) {
+		return false
+	}
+	if need := index/8 + 1; len(f.ChunkBitmap) < need {
+		grown := make([]byte, max(need, (f.TotalChunks+7)/8))
+		copy(grown, f.ChunkBitmap)
+		f.ChunkBitmap = grown
+	}
+	f.ChunkBitmap[index/8] |= 1 << (index % 8)
+	return true
+}
+
+// MissingChunks 返回尚未接收的分块索引（升序）
+func (f *File) MissingChunks() []int {
+	missing := make([]int, 0)
+	for i := 0; i < f.TotalChunks; i++ {
+		if !f.HasChunk(i) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
 // FileChunk 文件分块状态
 type FileChunk struct {
 	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	FileID      string    `gorm:"type:text;not null;index:idx_chunks_file" json:"file_id"`
-	ChunkIndex  int       `gorm:"type:integer;not null" json:"chunk_index"`
+	FileID      string    `gorm:"type:text;not null;index:idx_chunks_file;uniqueIndex:idx_chunks_file_chunk" json:"file_id"`
+	ChunkIndex  int       `gorm:"type:integer;not null;uniqueIndex:idx_chunks_file_chunk" json:"chunk_index"`
 	Size        int       `gorm:"type:integer;not null" json:"size"`
 	Checksum    string    `gorm:"type:text;not null" json:"checksum"`
 	Uploaded    bool      `gorm:"type:integer;default:0" json:"uploaded"`
@@ -80,3 +137,116 @@ type FileChunk struct {
 func (FileChunk) TableName() string {
 	return "file_chunks"
 }
+
+// MaxBundleEntries 文件包清单的条目上限（清单随文件消息广播，需控制大小）
+const MaxBundleEntries = 4096
+
+// BundleFormatTar 文件包格式：未压缩 tar（条目内容在包内连续存放，可按偏移直接读取）
+const BundleFormatTar = "tar"
+
+// BundleManifest 文件包清单（目录上传）
+// 随 file.metadata 的 bundle 字段上传，服务端校验后写入文件消息与 Metadata["bundle"]
+type BundleManifest struct {
+	Format    string        `json:"format"`
+	Root      string        `json:"root"` // 上传的目录名
+	Entries   []BundleEntry `json:"entries"`
+	Files     int           `json:"files"`
+	TotalSize int64         `json:"total_size"`        // 全部条目内容大小之和
+	Skipped   int           `json:"skipped,omitempty"` // 未打包的条目（符号链接、设备文件等）
+}
+
+// BundleEntry 文件包条目
+type BundleEntry struct {
+	Path    string `json:"path"` // 以 / 分隔的相对路径
+	Size    int64  `json:"size"`
+	Mode    uint32 `json:"mode"` // 权限位
+	ModTime int64  `json:"mtime"`
+	IsDir   bool   `json:"is_dir,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Offset  int64  `json:"offset,omitempty"` // 内容在包内的字节偏移
+}
+
+// ParseBundleManifest 从消息内容或 Metadata 中的 bundle 字段解析清单
+func ParseBundleManifest(v interface{}) (*BundleManifest, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	var m BundleManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Validate 校验清单与包大小一致，条目路径均为包内相对路径且不重复
+func (m *BundleManifest) Validate(bundleSize int64) error {
+	if m.Format != BundleFormatTar {
+		return fmt.Errorf("unsupported bundle format: %q", m.Format)
+	}
+	if len(m.Entries) > MaxBundleEntries {
+		return fmt.Errorf("too many bundle entries: %d (max %d)", len(m.Entries), MaxBundleEntries)
+	}
+
+	seen := make(map[string]bool, len(m.Entries))
+	var files int
+	var total int64
+	for _, e := range m.Entries {
+		if e.Path == "" || e.Path != path.Clean(e.Path) || !filepath.IsLocal(filepath.FromSlash(e.Path)) || strings.Contains(e.Path, `\`) {
+			return fmt.Errorf("invalid bundle entry path: %q", e.Path)
+		}
+		if seen[e.Path] {
+			return fmt.Errorf("duplicate bundle entry: %s", e.Path)
+		}
+		seen[e.Path] = true
+		if e.IsDir {
+			continue
+		}
+		if e.Size < 0 || e.Offset < 0 || e.Offset > bundleSize-e.Size {
+			return fmt.Errorf("bundle entry %s out of range", e.Path)
+		}
+		if len(e.SHA256) != 64 {
+			return fmt.Errorf("bundle entry %s has no sha256", e.Path)
+		}
+		files++
+		total += e.Size
+	}
+	if files != m.Files || total != m.TotalSize {
+		return fmt.Errorf("bundle totals mismatch: %d files/%d bytes, declared %d/%d", files, total, m.Files, m.TotalSize)
+	}
+	return nil
+}
+
+// Select 按路径选取条目：目录路径包含其下全部条目，paths 为空时返回全部条目（按清单顺序）
+func (m *BundleManifest) Select(paths []string) ([]BundleEntry, error) {
+	if len(paths) == 0 {
+		return m.Entries, nil
+	}
+
+	wanted := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		found := false
+		for _, e := range m.Entries {
+			if p == "" || e.Path == p || strings.HasPrefix(e.Path, p+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("bundle entry not found: %s", p)
+		}
+		wanted = append(wanted, p)
+	}
+
+	selected := make([]BundleEntry, 0)
+	for _, e := range m.Entries {
+		for _, p := range wanted {
+			if p == "" || e.Path == p || strings.HasPrefix(e.Path, p+"/") {
+				selected = append(selected, e)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
@@ -28,7 +28,7 @@ type Member struct {
 	IsOnline   bool      `gorm:"type:integer;default:0;index:idx_members_online" json:"is_online"`
 	IsMuted    bool      `gorm:"type:integer;default:0;index:idx_members_muted" json:"is_muted"`
 	IsBanned   bool      `gorm:"type:integer;default:0;index:idx_members_banned" json:"is_banned"`
-	JoinTime   time.Time `gorm:"not null" json:"join_time"`
+	JoinTime   time.Time `gorm:"-" json:"join_time"` // 兼容字段，不入库，由 JoinedAt 派生
 	LastSeenAt time.Time `gorm:"not null" json:"last_seen_at"`
 
 	// 原有时间字段
@@ -49,11 +49,12 @@ func (Member) TableName() string {
 func (m *Member) BeforeCreate(tx *gorm.DB) error {
 	now := time.Now()
 	if m.JoinedAt.IsZero() {
-		m.JoinedAt = now
+		m.JoinedAt = m.JoinTime
 	}
-	if m.JoinTime.IsZero() {
-		m.JoinTime = now
+	if m.JoinedAt.IsZero() {
+		m.JoinedAt = now
 	}
+	m.JoinTime = m.JoinedAt
 	if m.LastSeenAt.IsZero() {
 		m.LastSeenAt = now
 	}
@@ -66,13 +67,7 @@ func (m *Member) BeforeCreate(tx *gorm.DB) error {
 // AfterFind GORM 钩子 - 同步兼容字段
 func (m *Member) AfterFind(tx *gorm.DB) error {
 	// 同步 JoinTime（向后兼容）
-	if m.JoinTime.IsZero() && !m.JoinedAt.IsZero() {
-		m.JoinTime = m.JoinedAt
-	}
-	// 同步 JoinedAt（向后兼容）
-	if m.JoinedAt.IsZero() && !m.JoinTime.IsZero() {
-		m.JoinedAt = m.JoinTime
-	}
+	m.JoinTime = m.JoinedAt
 	// 判断在线状态
 	m.IsOnline = m.Status != StatusOffline && time.Since(m.LastHeartbeat) < 30*time.Second
 	return nil
@@ -117,3 +112,19 @@ func (m *MuteRecord) IsExpired() bool {
 	}
 	return time.Now().After(m.ExpiresAt)
 }
+
+// MemberSession 成员会话（服务端持久化，重启后已加入的成员无需重新认证）
+type MemberSession struct {
+	MemberID    string    `gorm:"primaryKey;type:text" json:"member_id"`
+	ChannelID   string    `gorm:"type:text;not null;index:idx_sessions_channel" json:"channel_id"`
+	PublicKey   []byte    `gorm:"type:blob" json:"-"`
+	ResumeToken string    `gorm:"type:text;serializer:encrypted" json:"-"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	LastSeen    time.Time `gorm:"not null" json:"last_seen"`
+	ExpiresAt   time.Time `gorm:"not null;index:idx_sessions_expires" json:"expires_at"`
+}
+
+// TableName 指定表名
+func (MemberSession) TableName() string {
+	return "member_sessions"
+}
@@ -13,16 +13,16 @@ type Message struct {
 	SenderID       string         `gorm:"type:text;not null;index:idx_messages_sender" json:"sender_id"`
 	SenderNickname string         `gorm:"type:text;not null" json:"sender_nickname"`
 	Type           MessageType    `gorm:"type:text;not null" json:"type"`
-	Content        MessageContent `gorm:"type:text;not null" json:"content"`
-	ContentText    string         `gorm:"type:text" json:"content_text,omitempty"` // 用于全文搜索
+	Content        MessageContent `gorm:"type:text;not null;serializer:encrypted" json:"content"`
+	ContentText    string         `gorm:"type:text;serializer:encrypted" json:"content_text,omitempty"` // 用于全文搜索（启用静态加密时在内存中匹配）
 	ReplyToID      *string        `gorm:"type:text;index:idx_messages_reply_to" json:"reply_to_id,omitempty"`
 	ThreadID       string         `gorm:"type:text;index:idx_messages_thread" json:"thread_id,omitempty"`
 	Mentions       StringArray    `gorm:"type:text" json:"mentions,omitempty"`
 	Tags           StringArray    `gorm:"type:text" json:"tags,omitempty"`
 
-	// APP层使用的字段（兼容）
-	IsDeleted bool `gorm:"type:integer;default:0;index:idx_messages_is_deleted" json:"is_deleted"`
-	IsPinned  bool `gorm:"type:integer;default:0;index:idx_messages_is_pinned" json:"is_pinned"`
+	// APP层使用的字段（兼容，不入库，由 Deleted/Pinned 派生）
+	IsDeleted bool `gorm:"-" json:"is_deleted"`
+	IsPinned  bool `gorm:"-" json:"is_pinned"`
 
 	// 原有字段
 	Pinned     bool      `gorm:"type:integer;default:0;index:idx_messages_pinned" json:"pinned"`
@@ -81,9 +81,6 @@ func (m *Message) AfterFind(tx *gorm.DB) error {
 
 // BeforeUpdate GORM 钩子
 func (m *Message) BeforeUpdate(tx *gorm.DB) error {
-	// 同步兼容字段
-	m.IsDeleted = m.Deleted
-	m.IsPinned = m.Pinned
 	if m.EditedAt.IsZero() {
 		m.EditedAt = time.Now()
 	}
@@ -14,6 +14,9 @@ const (
 	TransportHTTPS TransportMode = "https"
 	TransportMDNS  TransportMode = "mdns"
 	TransportAuto  TransportMode = "auto"
+
+	TransportUDP      TransportMode = "udp"      // 简单局域网UDP传输
+	TransportLoopback TransportMode = "loopback" // 进程内回环传输（测试用）
 )
 
 // Role 成员角色
@@ -235,8 +238,15 @@ type FileContent struct {
 	Size      int64      `json:"size"`
 	MimeType  string     `json:"mime_type"`
 	SHA256    string     `json:"sha256"`
-	Thumbnail string     `json:"thumbnail,omitempty"` // Base64
+	Thumbnail string     `json:"thumbnail,omitempty"` // Base64 PNG
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// 预览（上传完成后由服务端生成）
+	PreviewKind string `json:"preview_kind,omitempty"` // image/text/hex
+	PreviewText string `json:"preview_text,omitempty"` // 文本前若干行或十六进制转储
+	Language    string `json:"language,omitempty"`
+	Width       int    `json:"width,omitempty"` // 图片原始尺寸
+	Height      int    `json:"height,omitempty"`
 }
 
 // SystemContent 系统消息内容
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"crosswire/internal/analysis"
+	"crosswire/internal/events"
+	"crosswire/internal/models"
+)
+
+// ArtifactAnalyzer 附件后处理器
+// 文件上传完成后在后台依次执行：
+//  1. 生成预览（图片缩略图/文本前若干行/十六进制转储），写入文件记录并随文件消息广播；
+//  2. file/checksec/strings/binwalk 式的初步分析，结果写入 Metadata["analysis"]，
+//     题目附件另在题目聊天室发布系统消息。
+//
+// 参考: docs/FEATURES.md - 5.3 文件预览与附件分析
+type ArtifactAnalyzer struct {
+	server *Server
+
+	queue chan *models.File
+
+	// 统计
+	stats ArtifactAnalyzerStats
+}
+
+// ArtifactAnalyzerStats 分析统计
+type ArtifactAnalyzerStats struct {
+	TotalAnalyzed uint64
+	TotalFailed   uint64
+	TotalDropped  uint64
+	FlagsFound    uint64
+	mutex         sync.RWMutex
+}
+
+// NewArtifactAnalyzer 创建附件分析器
+func NewArtifactAnalyzer(server *Server) *ArtifactAnalyzer {
+	return &ArtifactAnalyzer{
+		server: server,
+		queue:  make(chan *models.File, 64),
+	}
+}
+
+// Run 运行分析队列
+func (aa *ArtifactAnalyzer) Run() {
+	defer aa.server.wg.Done()
+
+	for {
+		select {
+		case <-aa.server.ctx.Done():
+			return
+		case file := <-aa.queue:
+			aa.process(file)
+		}
+	}
+}
+
+// Enqueue 提交一个已完成上传的文件
+// 队列满时跳过预览与分析，直接广播文件消息，不阻塞上传流程
+func (aa *ArtifactAnalyzer) Enqueue(file *models.File) {
+	// 拷贝一份，避免与调用方后续修改交错
+	snapshot := *file
+	select {
+	case aa.queue <- &snapshot:
+	default:
+		aa.server.logger.Warn("[ArtifactAnalyzer] Queue full, skipping preview and analysis of %s", file.ID)
+		aa.stats.mutex.Lock()
+		aa.stats.TotalDropped++
+		aa.stats.mutex.Unlock()
+		aa.server.messageRouter.publishFileMessage(&snapshot, nil)
+	}
+}
+
+// process 处理一个文件：先预览并广播文件消息，再分析
+// 解析器遇到畸形样本时 panic 只标记该文件分析失败，不影响分析协程继续处理队列；
+// 预览阶段 panic 时文件消息照常发布（不带预览）
+func (aa *ArtifactAnalyzer) process(file *models.File) {
+	published := false
+	defer func() {
+		if r := recover(); r != nil {
+			aa.fail(file, fmt.Errorf("analyzer panic: %v", r))
+			if !published {
+				aa.server.messageRouter.publishFileMessage(file, nil)
+			}
+		}
+	}()
+
+	preview := aa.preview(file)
+	aa.server.messageRouter.publishFileMessage(file, preview)
+	published = true
+	aa.analyze(file)
+}
+
+// preview 生成并保存文件预览（失败时返回 nil，文件消息不带预览）
+func (aa *ArtifactAnalyzer) preview(file *models.File) *analysis.FilePreview {
+	content, err := aa.server.fileRepo.OpenContent(file)
+	if err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to open %s for preview: %v", file.ID, err)
+		return nil
+	}
+	defer content.Close()
+	r, ok := content.(io.ReaderAt)
+	if !ok {
+		return nil
+	}
+
+	preview, err := analysis.GeneratePreview(r, file.Size, file.Filename, analysis.PreviewOptions{})
+	if err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to generate preview of %s: %v", file.ID, err)
+		return nil
+	}
+
+	metadata := models.JSONField{}
+	for k, v := range file.Metadata {
+		metadata[k] = v
+	}
+	metadata["preview"] = preview
+	if err := aa.server.fileRepo.UpdatePreview(file.ID, preview.Thumbnail, preview.Text, metadata); err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to save preview of %s: %v", file.ID, err)
+	}
+	file.Thumbnail = preview.Thumbnail
+	file.PreviewText = preview.Text
+	file.Metadata = metadata
+	return preview
+}
+
+// analyze 分析文件并发布结果
+func (aa *ArtifactAnalyzer) analyze(file *models.File) {
+	startTime := time.Now()
+
+	// 1. 确定所属题目（用于 Flag 格式与结果发布位置）
+	var challenge *models.Challenge
+	if challengeID, _ := file.Metadata["challenge_id"].(string); challengeID != "" {
+		if ch, err := aa.server.challengeRepo.GetByID(challengeID); err == nil {
+			challenge = ch
+		}
+	}
+	opts := analysis.Options{}
+	if challenge != nil {
+		opts.FlagFormat = challenge.FlagFormat
+	}
+
+	// 2. 读取内容并分析
+	report, err := aa.run(file, opts)
+	if err != nil {
+		aa.fail(file, err)
+		return
+	}
+
+	// 3. 保存到文件记录
+	metadata := models.JSONField{}
+	for k, v := range file.Metadata {
+		metadata[k] = v
+	}
+	metadata["analysis"] = report
+	if err := aa.server.fileRepo.UpdateAnalysis(file.ID, metadata); err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to save analysis of %s: %v", file.ID, err)
+	}
+	file.Metadata = metadata
+
+	aa.stats.mutex.Lock()
+	aa.stats.TotalAnalyzed++
+	aa.stats.FlagsFound += uint64(len(report.Flags))
+	aa.stats.mutex.Unlock()
+
+	aa.server.logger.Info("[ArtifactAnalyzer] Analyzed %s (%s) in %v: %s",
+		file.Filename, file.ID, time.Since(startTime), report.Summary())
+
+	// 4. 题目附件：在题目聊天室发布结果
+	if challenge != nil {
+		aa.broadcastAnalysis(file, challenge, report)
+	}
+
+	aa.server.eventBus.Publish(events.EventFileAnalyzed, events.FileEvent{
+		File:       file,
+		ChannelID:  aa.server.config.ChannelID,
+		UploaderID: file.SenderID,
+		Progress:   100,
+	})
+}
+
+// run 打开文件内容执行分析
+func (aa *ArtifactAnalyzer) run(file *models.File, opts analysis.Options) (*analysis.Report, error) {
+	content, err := aa.server.fileRepo.OpenContent(file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	r, ok := content.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("content of %s does not support random access", file.ID)
+	}
+	return analysis.Analyze(r, file.Size, opts)
+}
+
+// fail 记录分析失败，并在文件记录中写入 Metadata["analysis_error"]
+func (aa *ArtifactAnalyzer) fail(file *models.File, err error) {
+	aa.server.logger.Error("[ArtifactAnalyzer] Failed to analyze %s (%s): %v", file.Filename, file.ID, err)
+	aa.stats.mutex.Lock()
+	aa.stats.TotalFailed++
+	aa.stats.mutex.Unlock()
+
+	metadata := models.JSONField{}
+	for k, v := range file.Metadata {
+		metadata[k] = v
+	}
+	metadata["analysis_error"] = err.Error()
+	if err := aa.server.fileRepo.UpdateAnalysis(file.ID, metadata); err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to save analysis error of %s: %v", file.ID, err)
+	}
+	file.Metadata = metadata
+}
+
+// broadcastAnalysis 在题目聊天室发布分析结果系统消息
+// 消息中的报告不含字符串列表（完整结果见文件记录），避免系统消息过大
+func (aa *ArtifactAnalyzer) broadcastAnalysis(file *models.File, challenge *models.Challenge, report *analysis.Report) {
+	compact := *report
+	compact.Strings = nil
+
+	lines := []string{fmt.Sprintf("Analysis of %s: %s", file.Filename, report.Description)}
+	if report.Binary != nil {
+		lines = append(lines, report.Binary.Checksec())
+	}
+	if report.Archive != nil {
+		lines = append(lines, fmt.Sprintf("%s with %d entries", report.Archive.Format, report.Archive.Total))
+	}
+	if report.Pcap != nil {
+		lines = append(lines, report.Pcap.Describe())
+	}
+	if len(report.Flags) > 0 {
+		lines = append(lines, "Flag candidates: "+strings.Join(report.Flags, ", "))
+	}
+
+	systemMsg := &models.Message{
+		ID:          generateMessageID(),
+		ChannelID:   aa.server.config.ChannelID,
+		ChallengeID: challenge.ID,
+		RoomType:    "challenge",
+		SenderID:    "system",
+		Type:        models.MessageTypeSystem,
+		Timestamp:   time.Now(),
+	}
+	systemMsg.Content = models.MessageContent{
+		"event":     "artifact_analysis",
+		"actor_id":  file.SenderID,
+		"target_id": file.ID,
+		"extra": map[string]interface{}{
+			"challenge_id": challenge.ID,
+			"file_id":      file.ID,
+			"filename":     file.Filename,
+			"analysis":     compact,
+			"summary":      report.Preview(analysis.DefaultMaxPreviewLen),
+			"message":      strings.Join(lines, "\n"),
+		},
+	}
+
+	// 持久化后再广播：离线成员可通过同步看到题目聊天室中的分析结果
+	if err := aa.server.messageRepo.Create(systemMsg); err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to persist analysis message: %v", err)
+	}
+	if err := aa.server.broadcastManager.Broadcast(systemMsg); err != nil {
+		aa.server.logger.Error("[ArtifactAnalyzer] Failed to broadcast analysis of %s: %v", file.ID, err)
+	}
+}
+
+// GetStats 获取分析统计
+func (aa *ArtifactAnalyzer) GetStats() ArtifactAnalyzerStats {
+	aa.stats.mutex.RLock()
+	defer aa.stats.mutex.RUnlock()
+
+	return ArtifactAnalyzerStats{
+		TotalAnalyzed: aa.stats.TotalAnalyzed,
+		TotalFailed:   aa.stats.TotalFailed,
+		TotalDropped:  aa.stats.TotalDropped,
+		FlagsFound:    aa.stats.FlagsFound,
+	}
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,8 +21,10 @@ import (
 type AuthManager struct {
 	server *Server
 
-	// 会话管理
-	sessions      map[string]*Session // memberID -> Session
+	// 会话管理（持久化到频道数据库，重启后恢复）
+	sessions      map[string]*Session  // memberID -> Session
+	nonces        map[string]time.Time // memberID|nonce -> 过期时间（控制消息防重放）
+	replayFloors  map[string]int64     // memberID -> 恢复会话时的时间戳（秒），不接受此前签名的控制消息
 	sessionsMutex sync.RWMutex
 
 	// TODO: 认证挑战功能（高级安全特性，待实现）
@@ -29,12 +34,34 @@ type AuthManager struct {
 
 // Session 会话
 type Session struct {
-	MemberID   string
-	PublicKey  []byte
-	CreatedAt  time.Time
-	LastSeen   time.Time
-	ExpiresAt  time.Time
-	IsVerified bool
+	MemberID    string
+	PublicKey   []byte
+	ResumeToken string // 断线续连令牌（每次续连后轮换，离开频道时作废）
+	CreatedAt   time.Time
+	LastSeen    time.Time
+	ExpiresAt   time.Time
+	IsVerified  bool
+
+	persistedAt time.Time // 最近一次写库时间（活跃续期按间隔写库）
+}
+
+// 控制消息认证参数
+const (
+	controlMaxAge          = 5 * time.Minute // 控制消息时间戳允许的最大滞后
+	controlMaxSkew         = time.Minute     // 允许超前的时钟偏差
+	sessionPersistInterval = time.Minute     // 会话滑动续期后写库的最小间隔
+)
+
+var errUnsignedControl = errors.New("unsigned control message")
+
+// SignedControl 带成员签名的控制消息（与客户端对应）
+// 参考: docs/PROTOCOL.md - 2.2.5 控制消息认证
+type SignedControl struct {
+	MemberID  string          `json:"member_id"`
+	Nonce     string          `json:"nonce"`
+	Timestamp int64           `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`   // 原始控制消息JSON
+	Signature []byte          `json:"signature"` // 成员签名私钥对 controlSigningBytes 的签名
 }
 
 // AuthChallenge 认证挑战
@@ -52,7 +79,22 @@ type JoinRequest struct {
 	Nickname  string `json:"nickname"`
 	PublicKey []byte `json:"public_key"` // X25519公钥
 	Timestamp int64  `json:"timestamp"`
-	Signature []byte `json:"signature,omitempty"` // 可选的签名
+	Signature []byte `json:"signature,omitempty"`  // 可选的签名
+	RequestID string `json:"request_id,omitempty"` // 请求关联ID（响应原样带回，客户端据此识别属于自己的响应）
+	MemberID  string `json:"member_id,omitempty"`  // 重新加入时携带的原成员ID（公钥一致时沿用原成员身份）
+
+	EphemeralPubKey []byte `json:"ephemeral_pubkey,omitempty"` // 客户端临时X25519公钥（续连令牌以其派生的密钥加密下发）
+}
+
+// ResumeRequest 断线续连请求
+// 参考: docs/PROTOCOL.md - 2.2.4 断线续连
+type ResumeRequest struct {
+	RequestID       string `json:"request_id"`
+	MemberID        string `json:"member_id"`
+	ResumeToken     string `json:"resume_token"`
+	EphemeralPubKey []byte `json:"ephemeral_pubkey"` // 用于加密下发轮换后的新令牌
+	Timestamp       int64  `json:"timestamp"`
+	Signature       []byte `json:"signature"` // 成员签名私钥对 resumeSigningBytes 的签名
 }
 
 // JoinResponse 加入响应
@@ -64,6 +106,9 @@ type JoinResponse struct {
 	MemberID        string        `json:"member_id,omitempty"`
 	MemberList      []*MemberInfo `json:"member_list,omitempty"`
 	ServerPublicKey []byte        `json:"server_public_key,omitempty"`
+	ResumeToken     []byte        `json:"resume_token,omitempty"`     // 加密后的续连令牌
+	ResumeEphemeral []byte        `json:"resume_ephemeral,omitempty"` // 服务端临时X25519公钥（客户端据此派生解密密钥）
+	ResumeTTL       int64         `json:"resume_ttl,omitempty"`       // 令牌有效期（秒）
 	Timestamp       int64         `json:"timestamp"`
 }
 
@@ -78,8 +123,10 @@ type MemberInfo struct {
 // NewAuthManager 创建认证管理器
 func NewAuthManager(server *Server) *AuthManager {
 	am := &AuthManager{
-		server:   server,
-		sessions: make(map[string]*Session),
+		server:       server,
+		sessions:     make(map[string]*Session),
+		nonces:       make(map[string]time.Time),
+		replayFloors: make(map[string]int64),
 	}
 
 	// 启动会话清理协程
@@ -88,10 +135,10 @@ func NewAuthManager(server *Server) *AuthManager {
 	return am
 }
 
-// HandleJoinRequest 处理加入请求
+// HandleAuthMessage 处理认证消息（加入或断线续连）
 // 参考: docs/PROTOCOL.md - 2.2.2 认证握手
-func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
-	am.server.logger.Debug("[AuthManager] Join request from: %s addr=%s len=%d", transportMsg.SenderID, transportMsg.SenderAddr, len(transportMsg.Payload))
+func (am *AuthManager) HandleAuthMessage(transportMsg *transport.Message) {
+	am.server.logger.Debug("[AuthManager] Auth message from: %s addr=%s len=%d", transportMsg.SenderID, transportMsg.SenderAddr, len(transportMsg.Payload))
 
 	// 1. 解密请求（使用密码派生的密钥）
 	decrypted, err := am.server.crypto.DecryptMessage(transportMsg.Payload)
@@ -106,15 +153,28 @@ func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
 		ckHash := sha256.Sum256(ck)
 		am.server.logger.Error("[AuthManager] Failed to decrypt join request: %v | addr=%s cipher_len=%d cipher_head=%s channel_id=%s key_fp=%x",
 			err, transportMsg.SenderAddr, len(transportMsg.Payload), cipherHead, am.server.config.ChannelID, ckHash[:4])
-		am.sendJoinResponse(transportMsg.SenderID, false, "Invalid password or encryption", nil)
+		am.sendJoinResponse(transportMsg.SenderID, "", false, "Invalid password or encryption", nil)
 		return
 	}
 
+	var head struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(decrypted, &head)
+	if head.Type == "auth.resume" {
+		am.handleResumeRequest(decrypted)
+		return
+	}
+	am.handleJoinRequest(transportMsg, decrypted)
+}
+
+// handleJoinRequest 处理加入请求
+func (am *AuthManager) handleJoinRequest(transportMsg *transport.Message, decrypted []byte) {
 	// 2. 反序列化请求
 	var joinReq JoinRequest
 	if err := json.Unmarshal(decrypted, &joinReq); err != nil {
 		am.server.logger.Error("[AuthManager] Failed to unmarshal join request: %v", err)
-		am.sendJoinResponse(transportMsg.SenderID, false, "Invalid request format", nil)
+		am.sendJoinResponse(transportMsg.SenderID, "", false, "Invalid request format", nil)
 		return
 	}
 
@@ -122,41 +182,48 @@ func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
 	now := time.Now().Unix()
 	if now-joinReq.Timestamp > 300 || joinReq.Timestamp > now+60 {
 		am.server.logger.Warn("[AuthManager] Invalid timestamp in join request: %d", joinReq.Timestamp)
-		am.sendJoinResponse(transportMsg.SenderID, false, "Invalid timestamp", nil)
+		am.sendJoinResponse(transportMsg.SenderID, joinReq.RequestID, false, "Invalid timestamp", nil)
 		return
 	}
 
 	// 4. 验证昵称
 	if joinReq.Nickname == "" || len(joinReq.Nickname) > 50 {
 		am.server.logger.Warn("[AuthManager] Invalid nickname: %s", joinReq.Nickname)
-		am.sendJoinResponse(transportMsg.SenderID, false, "Invalid nickname", nil)
+		am.sendJoinResponse(transportMsg.SenderID, joinReq.RequestID, false, "Invalid nickname", nil)
 		return
 	}
 
-	// 5. 检查频道是否已满
-	if am.server.channelManager.GetTotalCount() >= am.server.config.MaxMembers {
-		am.server.logger.Warn("[AuthManager] Channel is full")
-		am.sendJoinResponse(transportMsg.SenderID, false, "Channel is full", nil)
-		return
-	}
+	// 5-7. 重新加入（如切换传输后）：公钥一致则沿用原成员身份，否则作为新成员加入
+	member := am.rejoiningMember(&joinReq)
+	if member != nil {
+		_ = am.server.channelManager.UpdateMemberStatus(member.ID, models.StatusOnline)
+		am.server.logger.Info("[AuthManager] Member rejoined: %s (%s)", member.Nickname, member.ID)
+	} else {
+		// 5. 检查频道是否已满
+		if am.server.channelManager.GetTotalCount() >= am.server.config.MaxMembers {
+			am.server.logger.Warn("[AuthManager] Channel is full")
+			am.sendJoinResponse(transportMsg.SenderID, joinReq.RequestID, false, "Channel is full", nil)
+			return
+		}
 
-	// 6. 创建新成员
-	member := &models.Member{
-		ID:         generateMemberID(),
-		ChannelID:  am.server.config.ChannelID,
-		Nickname:   joinReq.Nickname,
-		PublicKey:  joinReq.PublicKey,
-		Role:       models.RoleMember,
-		Status:     models.StatusOnline,
-		JoinedAt:   time.Now(),
-		LastSeenAt: time.Now(),
-	}
+		// 6. 创建新成员
+		member = &models.Member{
+			ID:         generateMemberID(),
+			ChannelID:  am.server.config.ChannelID,
+			Nickname:   joinReq.Nickname,
+			PublicKey:  joinReq.PublicKey,
+			Role:       models.RoleMember,
+			Status:     models.StatusOnline,
+			JoinedAt:   time.Now(),
+			LastSeenAt: time.Now(),
+		}
 
-	// 7. 添加成员到频道
-	if err := am.server.channelManager.AddMember(member); err != nil {
-		am.server.logger.Error("[AuthManager] Failed to add member: %v", err)
-		am.sendJoinResponse(transportMsg.SenderID, false, fmt.Sprintf("Failed to join: %v", err), nil)
-		return
+		// 7. 添加成员到频道
+		if err := am.server.channelManager.AddMember(member); err != nil {
+			am.server.logger.Error("[AuthManager] Failed to add member: %v", err)
+			am.sendJoinResponse(transportMsg.SenderID, joinReq.RequestID, false, fmt.Sprintf("Failed to join: %v", err), nil)
+			return
+		}
 	}
 
 	// 8. 创建会话
@@ -165,13 +232,18 @@ func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
 		PublicKey:  joinReq.PublicKey,
 		CreatedAt:  time.Now(),
 		LastSeen:   time.Now(),
-		ExpiresAt:  time.Now().Add(am.server.config.SessionTimeout),
+		ExpiresAt:  time.Now().Add(am.sessionTimeout()),
 		IsVerified: true,
 	}
 
+	// 续连令牌：客户端提供临时公钥时签发（旧版客户端不支持续连）
+	sealedToken, tokenEphemeral := am.issueResumeToken(session, joinReq.EphemeralPubKey)
+
 	am.sessionsMutex.Lock()
 	am.sessions[member.ID] = session
+	record := am.sessionRecord(session)
 	am.sessionsMutex.Unlock()
+	am.persistSession(record)
 
 	// 9. 获取成员列表（包含刚加入的成员）
 	members, err := am.server.channelManager.GetMembers()
@@ -199,11 +271,14 @@ func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
 		MemberID:        member.ID,
 		MemberList:      memberList,
 		ServerPublicKey: am.server.config.PublicKey,
+		ResumeToken:     sealedToken,
+		ResumeEphemeral: tokenEphemeral,
+		ResumeTTL:       int64(am.sessionTimeout() / time.Second),
 		Timestamp:       time.Now().Unix(),
 	}
 
 	// 11. 发送响应
-	am.sendJoinResponse(transportMsg.SenderID, true, "", response)
+	am.sendJoinResponse(transportMsg.SenderID, joinReq.RequestID, true, "", response)
 
 	am.server.logger.Info("[AuthManager] Member joined: %s (%s)", member.Nickname, member.ID)
 
@@ -211,8 +286,24 @@ func (am *AuthManager) HandleJoinRequest(transportMsg *transport.Message) {
 	am.broadcastMemberJoined(member)
 }
 
+// rejoiningMember 查找重新加入请求对应的原成员（成员仍在频道内且公钥一致）
+func (am *AuthManager) rejoiningMember(joinReq *JoinRequest) *models.Member {
+	if joinReq.MemberID == "" || len(joinReq.PublicKey) == 0 {
+		return nil
+	}
+	member := am.server.channelManager.GetMemberByID(joinReq.MemberID)
+	if member == nil || !bytes.Equal(member.PublicKey, joinReq.PublicKey) {
+		am.server.logger.Warn("[AuthManager] Rejoin rejected for %s, joining as new member", joinReq.MemberID)
+		return nil
+	}
+	if am.server.channelManager.IsBanned(member.ID) {
+		return nil
+	}
+	return member
+}
+
 // sendJoinResponse 发送加入响应
-func (am *AuthManager) sendJoinResponse(to string, success bool, errorMsg string, response *JoinResponse) {
+func (am *AuthManager) sendJoinResponse(to string, requestID string, success bool, errorMsg string, response *JoinResponse) {
 	// 为了兼容客户端，响应格式统一为：
 	// {
 	//   "type": "auth.join_response",
@@ -220,8 +311,10 @@ func (am *AuthManager) sendJoinResponse(to string, success bool, errorMsg string
 	//   "error": "...",           // 失败时
 	//   "member": {"id":"...","nickname":"..."},
 	//   "channel_id": "...",
+	//   "request_id": "...",      // 请求携带时原样带回
 	//   "timestamp": 169...
 	// }
+	// 响应经广播下发，客户端依据 request_id 过滤他人的响应。
 
 	resp := map[string]interface{}{
 		"type":       "auth.join_response",
@@ -229,16 +322,22 @@ func (am *AuthManager) sendJoinResponse(to string, success bool, errorMsg string
 		"channel_id": am.server.config.ChannelID,
 		"timestamp":  time.Now().Unix(),
 	}
+	if requestID != "" {
+		resp["request_id"] = requestID
+	}
 	if !success {
 		resp["error"] = errorMsg
 	}
 
 	if response != nil && success {
-		// 使用传入的成员信息
+		// 使用传入的成员信息（昵称取成员列表中对应本人的条目）
 		memberID := response.MemberID
 		nickname := ""
-		if len(response.MemberList) > 0 {
-			nickname = response.MemberList[0].Nickname
+		for _, mi := range response.MemberList {
+			if mi.ID == memberID {
+				nickname = mi.Nickname
+				break
+			}
 		}
 		resp["member"] = map[string]interface{}{
 			"id":       memberID,
@@ -259,30 +358,169 @@ func (am *AuthManager) sendJoinResponse(to string, success bool, errorMsg string
 			})
 		}
 		resp["member_list"] = list
+		if len(response.ResumeToken) > 0 {
+			resp["resume_token"] = response.ResumeToken
+			resp["resume_ephemeral"] = response.ResumeEphemeral
+			resp["resume_ttl"] = response.ResumeTTL
+		}
 	}
 
-	// 序列化并加密
-	bytes, err := json.Marshal(resp)
+	// 设置 SenderID 为该成员ID，便于客户端识别；失败时尚无成员ID
+	senderID := to
+	if response != nil {
+		senderID = response.MemberID
+	}
+	am.sendAuthResponse(senderID, resp)
+}
+
+// sendAuthResponse 加密并发送认证响应
+func (am *AuthManager) sendAuthResponse(senderID string, resp map[string]interface{}) {
+	data, err := json.Marshal(resp)
 	if err != nil {
-		am.server.logger.Error("[AuthManager] Failed to marshal join response: %v", err)
+		am.server.logger.Error("[AuthManager] Failed to marshal %v: %v", resp["type"], err)
 		return
 	}
-	encrypted, err := am.server.crypto.EncryptMessage(bytes)
+	encrypted, err := am.server.crypto.EncryptMessage(data)
 	if err != nil {
-		am.server.logger.Error("[AuthManager] Failed to encrypt join response: %v", err)
+		am.server.logger.Error("[AuthManager] Failed to encrypt %v: %v", resp["type"], err)
 		return
 	}
 
-	// 发送响应（单播给新成员）：设置 SenderID 为该成员ID，便于客户端识别
 	transportMsg := &transport.Message{
 		Type:      transport.MessageTypeAuth,
-		SenderID:  response.MemberID,
+		SenderID:  senderID,
 		Payload:   encrypted,
 		Timestamp: time.Now(),
 	}
 	if err := am.server.transport.SendMessage(transportMsg); err != nil {
-		am.server.logger.Error("[AuthManager] Failed to send join response: %v", err)
+		am.server.logger.Error("[AuthManager] Failed to send %v: %v", resp["type"], err)
+	}
+}
+
+// issueResumeToken 为会话生成新的续连令牌，并以客户端临时公钥协商的密钥加密
+// 响应经频道密钥加密后广播，其他成员同样能解开外层，因此令牌本身必须再单独加密
+func (am *AuthManager) issueResumeToken(session *Session, clientEphemeral []byte) (sealed, serverEphemeral []byte) {
+	if len(clientEphemeral) == 0 {
+		session.ResumeToken = ""
+		return nil, nil
+	}
+	token, err := am.server.crypto.GenerateRandomHex(32)
+	if err != nil {
+		am.server.logger.Error("[AuthManager] Failed to generate resume token: %v", err)
+		return nil, nil
+	}
+	ephPriv, ephPub, err := am.server.crypto.GenerateX25519KeyPair()
+	if err != nil {
+		am.server.logger.Error("[AuthManager] Failed to generate ephemeral key: %v", err)
+		return nil, nil
+	}
+	key, err := am.server.crypto.X25519SharedSecret(ephPriv, clientEphemeral)
+	if err != nil {
+		am.server.logger.Warn("[AuthManager] Invalid client ephemeral key: %v", err)
+		return nil, nil
+	}
+	sealed, err = am.server.crypto.AESEncrypt([]byte(token), key)
+	if err != nil {
+		am.server.logger.Error("[AuthManager] Failed to seal resume token: %v", err)
+		return nil, nil
+	}
+	session.ResumeToken = token
+	return sealed, ephPub
+}
+
+// resumeSigningBytes 续连请求的签名内容（客户端以成员签名私钥签名）
+func resumeSigningBytes(memberID, requestID, token string, timestamp int64) []byte {
+	return []byte(fmt.Sprintf("auth.resume|%s|%s|%s|%d", memberID, requestID, token, timestamp))
+}
+
+// handleResumeRequest 处理断线续连：校验令牌与成员签名后恢复原会话，无需重新加入
+// 参考: docs/PROTOCOL.md - 2.2.4 断线续连
+func (am *AuthManager) handleResumeRequest(decrypted []byte) {
+	var req ResumeRequest
+	if err := json.Unmarshal(decrypted, &req); err != nil {
+		am.server.logger.Error("[AuthManager] Failed to unmarshal resume request: %v", err)
+		return
+	}
+
+	reject := func(reason string) {
+		am.server.logger.Warn("[AuthManager] Resume rejected for %s: %s", req.MemberID, reason)
+		am.sendAuthResponse(req.MemberID, map[string]interface{}{
+			"type":       "auth.resume_response",
+			"success":    false,
+			"error":      "resume rejected",
+			"request_id": req.RequestID,
+			"channel_id": am.server.config.ChannelID,
+			"timestamp":  time.Now().Unix(),
+		})
+	}
+
+	now := time.Now()
+	if now.Unix()-req.Timestamp > 300 || req.Timestamp > now.Unix()+60 {
+		reject("invalid timestamp")
+		return
+	}
+
+	am.sessionsMutex.Lock()
+	session, exists := am.sessions[req.MemberID]
+	valid := exists && session.ResumeToken != "" && now.Before(session.ExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(session.ResumeToken), []byte(req.ResumeToken)) == 1 &&
+		len(session.PublicKey) == ed25519.PublicKeySize &&
+		ed25519.Verify(session.PublicKey, resumeSigningBytes(req.MemberID, req.RequestID, req.ResumeToken, req.Timestamp), req.Signature)
+	var sealed, ephemeral []byte
+	var record *models.MemberSession
+	if valid {
+		// 轮换令牌：旧令牌立即失效，防止重放
+		sealed, ephemeral = am.issueResumeToken(session, req.EphemeralPubKey)
+		session.LastSeen = now
+		session.ExpiresAt = now.Add(am.sessionTimeout())
+		record = am.sessionRecord(session)
+	}
+	am.sessionsMutex.Unlock()
+	if !valid {
+		reject("invalid or expired session")
+		return
+	}
+	am.persistSession(record)
+
+	member := am.server.channelManager.GetMemberByID(req.MemberID)
+	if member == nil || am.server.channelManager.IsBanned(req.MemberID) {
+		am.RemoveSession(req.MemberID)
+		reject("member no longer in channel")
+		return
+	}
+	_ = am.server.channelManager.UpdateMemberStatus(member.ID, models.StatusOnline)
+
+	resp := map[string]interface{}{
+		"type":       "auth.resume_response",
+		"success":    true,
+		"request_id": req.RequestID,
+		"channel_id": am.server.config.ChannelID,
+		"member": map[string]interface{}{
+			"id":       member.ID,
+			"nickname": member.Nickname,
+		},
+		"timestamp": now.Unix(),
+	}
+	if len(am.server.config.PublicKey) > 0 {
+		resp["server_public_key"] = am.server.config.PublicKey
+	}
+	if len(sealed) > 0 {
+		resp["resume_token"] = sealed
+		resp["resume_ephemeral"] = ephemeral
+		resp["resume_ttl"] = int64(am.sessionTimeout() / time.Second)
 	}
+	am.sendAuthResponse(member.ID, resp)
+
+	am.server.logger.Info("[AuthManager] Session resumed: %s (%s)", member.Nickname, member.ID)
+}
+
+// HandleLeave 处理离开频道：作废会话并标记离线
+// 控制消息已通过成员签名认证，transportMsg.SenderID 即离开的成员
+func (am *AuthManager) HandleLeave(transportMsg *transport.Message, payload []byte) {
+	memberID := transportMsg.SenderID
+	am.RemoveSession(memberID)
+	_ = am.server.channelManager.UpdateMemberStatus(memberID, models.StatusOffline)
+	am.server.logger.Info("[AuthManager] Member left: %s", memberID)
 }
 
 // broadcastMemberJoined 广播成员加入
@@ -353,14 +591,144 @@ func (am *AuthManager) GetSession(memberID string) (*Session, error) {
 // RemoveSession 移除会话
 func (am *AuthManager) RemoveSession(memberID string) {
 	am.sessionsMutex.Lock()
-	defer am.sessionsMutex.Unlock()
-
 	delete(am.sessions, memberID)
+	am.sessionsMutex.Unlock()
+
+	if err := am.server.sessionRepo.Delete(memberID); err != nil {
+		am.server.logger.Warn("[AuthManager] Failed to delete session %s: %v", memberID, err)
+	}
+}
+
+// LoadSessions 从频道数据库恢复未过期的会话（服务端重启后已加入的成员无需重新加入）
+// nonce 缓存只在内存中，重启前已接受的控制消息仍在时间窗口内，
+// 因此恢复的成员只接受启动之后（按秒计，不含启动当秒）签名的控制消息
+func (am *AuthManager) LoadSessions() error {
+	now := time.Now()
+	if err := am.server.sessionRepo.DeleteExpired(now); err != nil {
+		am.server.logger.Warn("[AuthManager] Failed to purge expired sessions: %v", err)
+	}
+	records, err := am.server.sessionRepo.GetActive(am.server.config.ChannelID, now)
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	am.sessionsMutex.Lock()
+	for _, r := range records {
+		am.replayFloors[r.MemberID] = now.Unix()
+		am.sessions[r.MemberID] = &Session{
+			MemberID:    r.MemberID,
+			PublicKey:   r.PublicKey,
+			ResumeToken: r.ResumeToken,
+			CreatedAt:   r.CreatedAt,
+			LastSeen:    r.LastSeen,
+			ExpiresAt:   r.ExpiresAt,
+			IsVerified:  true,
+			persistedAt: now,
+		}
+	}
+	am.sessionsMutex.Unlock()
+
+	am.server.logger.Info("[AuthManager] Restored %d sessions", len(records))
+	return nil
+}
+
+// sessionRecord 生成会话的持久化记录（需持有 sessionsMutex）
+func (am *AuthManager) sessionRecord(session *Session) *models.MemberSession {
+	session.persistedAt = time.Now()
+	return &models.MemberSession{
+		MemberID:    session.MemberID,
+		ChannelID:   am.server.config.ChannelID,
+		PublicKey:   session.PublicKey,
+		ResumeToken: session.ResumeToken,
+		CreatedAt:   session.CreatedAt,
+		LastSeen:    session.LastSeen,
+		ExpiresAt:   session.ExpiresAt,
+	}
+}
+
+// persistSession 写入会话记录
+func (am *AuthManager) persistSession(record *models.MemberSession) {
+	if record == nil {
+		return
+	}
+	if err := am.server.sessionRepo.Save(record); err != nil {
+		am.server.logger.Warn("[AuthManager] Failed to persist session %s: %v", record.MemberID, err)
+	}
+}
+
+// sessionTimeout 会话有效期（未配置时使用默认值）
+func (am *AuthManager) sessionTimeout() time.Duration {
+	if am.server.config.SessionTimeout > 0 {
+		return am.server.config.SessionTimeout
+	}
+	return DefaultServerConfig.SessionTimeout
 }
 
-// cleanupExpiredSessions 清理过期会话
+// controlSigningBytes 控制消息的签名内容（与客户端一致）
+func controlSigningBytes(memberID, nonce string, timestamp int64, payload []byte) []byte {
+	return append([]byte(fmt.Sprintf("control|%s|%s|%d|", memberID, nonce, timestamp)), payload...)
+}
+
+// VerifyControl 校验控制消息的成员签名，返回已认证的成员ID与原始控制消息
+// 要求会话有效、时间戳在窗口内、签名可由加入时登记的成员公钥验证且 nonce 未被使用过；
+// 通过后会话滑动续期
+// 参考: docs/PROTOCOL.md - 2.2.5 控制消息认证
+func (am *AuthManager) VerifyControl(decrypted []byte) (string, []byte, error) {
+	var env SignedControl
+	if err := json.Unmarshal(decrypted, &env); err != nil {
+		return "", nil, fmt.Errorf("invalid control envelope: %w", err)
+	}
+	if env.MemberID == "" || env.Nonce == "" || len(env.Signature) == 0 || len(env.Payload) == 0 {
+		return env.MemberID, nil, errUnsignedControl
+	}
+
+	now := time.Now()
+	sentAt := time.Unix(env.Timestamp, 0)
+	if now.Sub(sentAt) > controlMaxAge || sentAt.Sub(now) > controlMaxSkew {
+		return env.MemberID, nil, errors.New("timestamp out of window")
+	}
+
+	am.sessionsMutex.RLock()
+	session, exists := am.sessions[env.MemberID]
+	var publicKey []byte
+	if exists && now.Before(session.ExpiresAt) {
+		publicKey = session.PublicKey
+	}
+	floor, restored := am.replayFloors[env.MemberID]
+	am.sessionsMutex.RUnlock()
+	if publicKey == nil {
+		return env.MemberID, nil, errors.New("no active session")
+	}
+	if restored && env.Timestamp <= floor {
+		return env.MemberID, nil, errors.New("signed before server restart")
+	}
+	if len(publicKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(publicKey, controlSigningBytes(env.MemberID, env.Nonce, env.Timestamp, env.Payload), env.Signature) {
+		return env.MemberID, nil, errors.New("invalid signature")
+	}
+
+	nonceKey := env.MemberID + "|" + env.Nonce
+	var record *models.MemberSession
+	am.sessionsMutex.Lock()
+	if _, replayed := am.nonces[nonceKey]; replayed {
+		am.sessionsMutex.Unlock()
+		return env.MemberID, nil, errors.New("replayed nonce")
+	}
+	am.nonces[nonceKey] = sentAt.Add(controlMaxAge + controlMaxSkew)
+	session.LastSeen = now
+	session.ExpiresAt = now.Add(am.sessionTimeout())
+	if now.Sub(session.persistedAt) >= sessionPersistInterval {
+		record = am.sessionRecord(session)
+	}
+	am.sessionsMutex.Unlock()
+	am.persistSession(record)
+
+	return env.MemberID, env.Payload, nil
+}
+
+// cleanupExpiredSessions 清理过期会话与防重放 nonce
 func (am *AuthManager) cleanupExpiredSessions() {
-	ticker := time.NewTicker(10 * time.Minute)
+	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
 	for {
@@ -376,7 +744,22 @@ func (am *AuthManager) cleanupExpiredSessions() {
 					am.server.logger.Debug("[AuthManager] Session expired: %s", memberID)
 				}
 			}
+			for key, expiresAt := range am.nonces {
+				if now.After(expiresAt) {
+					delete(am.nonces, key)
+				}
+			}
+			// 超出时间窗口后，重启前签名的消息已由时间戳校验拒绝
+			for memberID, floor := range am.replayFloors {
+				if now.Sub(time.Unix(floor, 0)) > controlMaxAge+controlMaxSkew {
+					delete(am.replayFloors, memberID)
+				}
+			}
 			am.sessionsMutex.Unlock()
+
+			if err := am.server.sessionRepo.DeleteExpired(now); err != nil {
+				am.server.logger.Warn("[AuthManager] Failed to purge expired sessions: %v", err)
+			}
 		}
 	}
 }
@@ -155,20 +155,12 @@ func (cm *ChallengeManager) AssignChallenge(challengeID, memberID, assignedBy st
 }
 
 // HandleFlagSubmission 处理Flag提交
-func (cm *ChallengeManager) HandleFlagSubmission(transportMsg *transport.Message) {
-	cm.server.logger.Debug("[ChallengeManager] HandleFlagSubmission received: sender=%s ts=%v payload_len=%d", transportMsg.SenderID, transportMsg.Timestamp, len(transportMsg.Payload))
-
-	// 解密消息
-	decrypted, err := cm.server.crypto.DecryptMessage(transportMsg.Payload)
-	if err != nil {
-		cm.server.logger.Error("[ChallengeManager] Decrypt submission failed: %v", err)
-		return
-	}
-	cm.server.logger.Debug("[ChallengeManager] Submission decrypted: bytes=%d", len(decrypted))
+func (cm *ChallengeManager) HandleFlagSubmission(transportMsg *transport.Message, payload []byte) {
+	cm.server.logger.Debug("[ChallengeManager] HandleFlagSubmission received: sender=%s ts=%v payload_len=%d", transportMsg.SenderID, transportMsg.Timestamp, len(payload))
 
 	// 反序列化提交
 	var submission models.ChallengeSubmission
-	if err := json.Unmarshal(decrypted, &submission); err != nil {
+	if err := json.Unmarshal(payload, &submission); err != nil {
 		cm.server.logger.Error("[ChallengeManager] Unmarshal submission failed: %v", err)
 		return
 	}
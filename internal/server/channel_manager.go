@@ -27,6 +27,10 @@ type ChannelManager struct {
 	// 禁言记录
 	muteRecords map[string]*models.MuteRecord // memberID -> MuteRecord
 	muteMutex   sync.RWMutex
+
+	// 传输层对端 -> 成员（由心跳学习，用于对端统计展示）
+	peerMembers map[string]string // transport.PeerKey -> memberID
+	peersMutex  sync.RWMutex
 }
 
 // NewChannelManager 创建频道管理器
@@ -35,6 +39,7 @@ func NewChannelManager(server *Server) *ChannelManager {
 		server:      server,
 		members:     make(map[string]*models.Member),
 		muteRecords: make(map[string]*models.MuteRecord),
+		peerMembers: make(map[string]string),
 	}
 }
 
@@ -294,6 +299,7 @@ func (cm *ChannelManager) RemoveMember(memberID string, reason string) error {
 	cm.membersMutex.Lock()
 	delete(cm.members, memberID)
 	cm.membersMutex.Unlock()
+	cm.server.authManager.RemoveSession(memberID)
 
 	cm.server.logger.Info("[ChannelManager] Member removed: %s (%s), reason: %s",
 		member.Nickname, memberID, reason)
@@ -574,16 +580,10 @@ func (cm *ChannelManager) GetTotalCount() int {
 }
 
 // HandleMemberStatus 处理成员状态变化
-func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message) {
-	// 解密并解析状态更新
-	decrypted, err := cm.server.crypto.DecryptMessage(msg.Payload)
-	if err != nil {
-		cm.server.logger.Error("[ChannelManager] Failed to decrypt member status: %v", err)
-		return
-	}
-
+func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message, raw []byte) {
+	// 解析状态更新
 	var payload map[string]interface{}
-	if err := json.Unmarshal(decrypted, &payload); err != nil {
+	if err := json.Unmarshal(raw, &payload); err != nil {
 		cm.server.logger.Error("[ChannelManager] Failed to unmarshal member status: %v", err)
 		return
 	}
@@ -594,6 +594,11 @@ func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message) {
 		cm.server.logger.Warn("[ChannelManager] Invalid member status payload")
 		return
 	}
+	// 只能更新自己的状态
+	if memberID != msg.SenderID {
+		cm.server.logger.Warn("[ChannelManager] Status update for %s from member: %s", memberID, msg.SenderID)
+		return
+	}
 
 	// 更新内存与数据库
 	status := models.UserStatus(statusStr)
@@ -607,7 +612,18 @@ func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message) {
 		cm.server.logger.Warn("[ChannelManager] Failed to update heartbeat: %v", err)
 	}
 
-	// 广播成员状态
+	// 记录对端映射与客户端上报的 RTT
+	peer := transport.PeerKey(msg)
+	if peer != "" {
+		cm.peersMutex.Lock()
+		cm.peerMembers[peer] = memberID
+		cm.peersMutex.Unlock()
+		if rttMs, ok := payload["rtt_ms"].(float64); ok && rttMs > 0 {
+			cm.server.peerMetrics().RecordRTT(peer, time.Duration(rttMs*float64(time.Millisecond)))
+		}
+	}
+
+	// 广播成员状态（回显心跳发送时间，供该成员测量 RTT）
 	resp := map[string]interface{}{
 		"type":       "member.status",
 		"channel_id": cm.server.config.ChannelID,
@@ -615,6 +631,9 @@ func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message) {
 		"status":     statusStr,
 		"timestamp":  time.Now().Unix(),
 	}
+	if sentAt, ok := payload["sent_at"]; ok {
+		resp["echo"] = sentAt
+	}
 	data, _ := json.Marshal(resp)
 	enc, err := cm.server.crypto.EncryptMessage(data)
 	if err != nil {
@@ -632,6 +651,13 @@ func (cm *ChannelManager) HandleMemberStatus(msg *transport.Message) {
 	}
 }
 
+// GetMemberIDByPeer 根据传输层对端键查找成员 ID
+func (cm *ChannelManager) GetMemberIDByPeer(peer string) string {
+	cm.peersMutex.RLock()
+	defer cm.peersMutex.RUnlock()
+	return cm.peerMembers[peer]
+}
+
 // CheckOfflineMembers 检查超时未心跳成员并标记离线
 func (cm *ChannelManager) CheckOfflineMembers(threshold time.Duration) {
 	now := time.Now()
@@ -721,6 +747,7 @@ func (cm *ChannelManager) KickMember(memberID, reason string, kickedBy string) e
 	cm.membersMutex.Lock()
 	delete(cm.members, memberID)
 	cm.membersMutex.Unlock()
+	cm.server.authManager.RemoveSession(memberID)
 
 	cm.server.logger.Info("[ChannelManager] Member kicked: %s by %s, reason: %s",
 		member.Nickname, kicker.Nickname, reason)
@@ -5,11 +5,14 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"sync"
 	"time"
 
+	"crosswire/internal/analysis"
 	"crosswire/internal/events"
 	"crosswire/internal/models"
 	"crosswire/internal/transport"
@@ -30,6 +33,9 @@ type MessageRouter struct {
 	// 离线消息队列
 	offlineMessages map[string][]*models.Message // memberID -> messages
 	offlineMutex    sync.RWMutex
+
+	// 串行化分块登记（位图读改写与完成提交）
+	uploadMutex sync.Mutex
 }
 
 // MessageTask 消息任务
@@ -285,30 +291,23 @@ func (mr *MessageRouter) handleReaction(msg *models.Message) {
 
 // HandleFileUpload 处理文件上传
 // 参考: internal/client/file_manager.go 的上传实现
-func (mr *MessageRouter) HandleFileUpload(transportMsg *transport.Message) {
+func (mr *MessageRouter) HandleFileUpload(transportMsg *transport.Message, payload []byte) {
 	mr.server.logger.Debug("[MessageRouter] File upload request from: %s", transportMsg.SenderID)
 
-	// 1. 解密消息
-	decrypted, err := mr.server.crypto.DecryptMessage(transportMsg.Payload)
-	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to decrypt file upload: %v", err)
-		return
-	}
-
-	// 2. 反序列化消息
+	// 1. 反序列化消息
 	var msg models.Message
-	if err := json.Unmarshal(decrypted, &msg); err != nil {
+	if err := json.Unmarshal(payload, &msg); err != nil {
 		mr.server.logger.Error("[MessageRouter] Failed to unmarshal file message: %v", err)
 		return
 	}
 
-	// 3. 验证成员权限
-	if !mr.server.channelManager.HasMember(msg.SenderID) {
-		mr.server.logger.Warn("[MessageRouter] Non-member trying to upload file: %s", msg.SenderID)
+	// 2. 验证成员权限（消息发送者须为已认证的成员本人）
+	if msg.SenderID != transportMsg.SenderID || !mr.server.channelManager.HasMember(msg.SenderID) {
+		mr.server.logger.Warn("[MessageRouter] Rejected file upload for %s from: %s", msg.SenderID, transportMsg.SenderID)
 		return
 	}
 
-	// 4. 根据消息类型处理
+	// 3. 根据消息类型处理
 	switch msg.Type {
 	case models.MessageTypeFile:
 		// 文件元数据或完整小文件
@@ -318,6 +317,48 @@ func (mr *MessageRouter) HandleFileUpload(transportMsg *transport.Message) {
 	}
 }
 
+// HandleFileMetadata 处理客户端上传的文件元数据（file.metadata）
+// 参考: internal/client/file_manager.go 的 sendFileMetadata
+func (mr *MessageRouter) HandleFileMetadata(transportMsg *transport.Message, payload []byte) {
+	mr.server.logger.Debug("[MessageRouter] File metadata from: %s", transportMsg.SenderID)
+
+	// 1. 解析元数据
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(payload, &metadata); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to unmarshal file metadata: %v", err)
+		return
+	}
+
+	// 2. 验证成员权限
+	if !mr.server.channelManager.HasMember(transportMsg.SenderID) {
+		mr.server.logger.Warn("[MessageRouter] Non-member trying to upload file: %s", transportMsg.SenderID)
+		return
+	}
+
+	fileID, _ := metadata["file_id"].(string)
+	if fileID == "" {
+		mr.server.logger.Warn("[MessageRouter] File metadata without file_id from: %s", transportMsg.SenderID)
+		return
+	}
+
+	// 3. 转换为文件消息（消息ID与文件ID一致，客户端以此关联文件记录）
+	delete(metadata, "type")
+	delete(metadata, "timestamp")
+	msg := &models.Message{
+		ID:        fileID,
+		ChannelID: mr.server.config.ChannelID,
+		SenderID:  transportMsg.SenderID,
+		Type:      models.MessageTypeFile,
+		Content:   models.MessageContent(metadata),
+		Timestamp: time.Now(),
+	}
+	if member := mr.server.channelManager.GetMemberByID(transportMsg.SenderID); member != nil {
+		msg.SenderNickname = member.Nickname
+	}
+
+	mr.handleFileMetadata(msg)
+}
+
 // handleFileMetadata 处理文件元数据
 func (mr *MessageRouter) handleFileMetadata(msg *models.Message) {
 	mr.server.logger.Debug("[MessageRouter] Processing file metadata from: %s", msg.SenderID)
@@ -359,7 +400,7 @@ func (mr *MessageRouter) handleFileMetadata(msg *models.Message) {
 		Size:        fileContent.Size,
 		MimeType:    fileContent.MimeType,
 		SHA256:      fileContent.SHA256,
-		StorageType: models.StorageInline,
+		StorageType: models.StorageFile,
 		Encrypted:   true,
 		UploadedAt:  time.Now(),
 	}
@@ -373,13 +414,57 @@ func (mr *MessageRouter) handleFileMetadata(msg *models.Message) {
 	}
 	file.UploadStatus = models.UploadStatusUploading
 
-	// 3. 保存文件记录
-	if err := mr.server.fileRepo.Create(file); err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to save file metadata: %v", err)
+	// 可选字段：所属题目（文件消息显示在题目聊天室，完成后自动分析）
+	if challengeID, ok := msg.Content["challenge_id"].(string); ok && challengeID != "" {
+		if challenge, err := mr.server.challengeRepo.GetByID(challengeID); err == nil && challenge != nil {
+			msg.ChallengeID = challenge.ID
+			msg.RoomType = "challenge"
+			file.Metadata = models.JSONField{"challenge_id": challenge.ID}
+		} else {
+			mr.server.logger.Warn("[MessageRouter] File %s references unknown challenge: %s", file.ID, challengeID)
+			delete(msg.Content, "challenge_id")
+		}
+	}
+
+	// 可选字段：文件包清单（目录上传），校验失败时去掉清单，文件仍可作为整个 tar 下载
+	if raw, ok := msg.Content["bundle"]; ok {
+		manifest, err := models.ParseBundleManifest(raw)
+		if err == nil {
+			err = manifest.Validate(file.Size)
+		}
+		if err != nil {
+			mr.server.logger.Warn("[MessageRouter] Dropping invalid bundle manifest of %s: %v", file.ID, err)
+			delete(msg.Content, "bundle")
+		} else {
+			msg.Content["bundle"] = manifest
+			if file.Metadata == nil {
+				file.Metadata = models.JSONField{}
+			}
+			file.Metadata["bundle"] = manifest
+		}
+	}
+
+	// 重复的元数据（如续传时重发）不再登记与广播
+	if existing, err := mr.server.fileRepo.GetByID(file.ID); err == nil && existing != nil {
+		mr.server.logger.Debug("[MessageRouter] File metadata already known: %s", file.ID)
 		return
 	}
 
-	// 4. 持久化消息
+	// 大小/分块信息与配额检查：不通过时拒绝登记并通知上传者，之后到达的分块因无文件记录被丢弃
+	if err := mr.server.storageManager.CheckQuota(file); err != nil {
+		mr.server.logger.Warn("[MessageRouter] Rejected file %s from %s: %v", file.ID, file.SenderID, err)
+		mr.sendFileRejected(file, err)
+		return
+	}
+
+	// 保留策略：按类型确定过期时间，随文件消息告知成员
+	file.ExpiresAt = mr.server.storageManager.ExpiryFor(file)
+	if !file.ExpiresAt.IsZero() {
+		msg.Content["expires_at"] = file.ExpiresAt.Unix()
+	}
+
+	// 3. 持久化消息（文件记录外键引用消息，需先落库）
+	// 文件消息在上传完成、生成预览后才广播（见 publishFileMessage），成员收到时内容已可下载
 	msg.ChannelID = mr.server.config.ChannelID
 	if msg.Timestamp.IsZero() {
 		msg.Timestamp = time.Now()
@@ -389,13 +474,17 @@ func (mr *MessageRouter) handleFileMetadata(msg *models.Message) {
 		mr.server.logger.Error("[MessageRouter] Failed to persist file message: %v", err)
 	}
 
-	// 5. 广播文件消息
-	if err := mr.server.broadcastManager.Broadcast(msg); err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to broadcast file message: %v", err)
+	// 4. 保存文件记录
+	if err := mr.server.fileRepo.Create(file); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to save file metadata: %v", err)
 		return
 	}
+	if file.TotalChunks == 0 {
+		// 空文件没有分块，直接提交
+		mr.handleFileUploadComplete(file)
+	}
 
-	// 6. 发布文件上传事件
+	// 5. 发布文件上传事件
 	mr.server.eventBus.Publish(events.EventFileUploaded, events.FileEvent{
 		File:       file,
 		ChannelID:  mr.server.config.ChannelID,
@@ -403,22 +492,15 @@ func (mr *MessageRouter) handleFileMetadata(msg *models.Message) {
 		Progress:   100,
 	})
 
-	mr.server.logger.Info("[MessageRouter] File uploaded successfully: %s (%s)", file.Filename, file.ID)
+	mr.server.logger.Info("[MessageRouter] File registered: %s (%s)", file.Filename, file.ID)
 }
 
 // HandleFileChunk 处理文件分块
 // 参考: internal/client/file_manager.go 的分块上传实现
-func (mr *MessageRouter) HandleFileChunk(transportMsg *transport.Message) {
+func (mr *MessageRouter) HandleFileChunk(transportMsg *transport.Message, payload []byte) {
 	mr.server.logger.Debug("[MessageRouter] File chunk from: %s", transportMsg.SenderID)
 
-	// 1. 解密消息
-	decrypted, err := mr.server.crypto.DecryptMessage(transportMsg.Payload)
-	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to decrypt file chunk: %v", err)
-		return
-	}
-
-	// 2. 解析分块数据结构
+	// 1. 解析分块数据结构
 	type ChunkData struct {
 		FileID      string `json:"file_id"`
 		ChunkIndex  int    `json:"chunk_index"`
@@ -428,49 +510,27 @@ func (mr *MessageRouter) HandleFileChunk(transportMsg *transport.Message) {
 	}
 
 	var chunkData ChunkData
-	if err := json.Unmarshal(decrypted, &chunkData); err != nil {
+	if err := json.Unmarshal(payload, &chunkData); err != nil {
 		mr.server.logger.Error("[MessageRouter] Failed to unmarshal chunk data: %v", err)
 		return
 	}
 
-	// 3. 验证分块
+	// 2. 验证分块
 	if !mr.verifyChunkChecksum(chunkData.Data, chunkData.Checksum) {
 		mr.server.logger.Error("[MessageRouter] Chunk checksum mismatch for file: %s chunk: %d",
 			chunkData.FileID, chunkData.ChunkIndex)
 		return
 	}
 
-	// 4. 保存分块记录
-	chunk := &models.FileChunk{
-		FileID:     chunkData.FileID,
-		ChunkIndex: chunkData.ChunkIndex,
-		Size:       len(chunkData.Data),
-		Checksum:   chunkData.Checksum,
-		Uploaded:   true,
-		UploadedAt: time.Now(),
-	}
-
-	if err := mr.server.fileRepo.CreateChunk(chunk); err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to save chunk: %v", err)
-		return
-	}
-
-	// 5. 更新文件（累积数据）与上传进度
-	file, err := mr.server.fileRepo.GetByID(chunkData.FileID)
-	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to get file: %v", err)
+	// 3. 登记分块（位图去重：重复或已完成文件的分块直接忽略，不重复计数也不再转发）
+	mr.uploadMutex.Lock()
+	file, accepted := mr.acceptChunk(transportMsg.SenderID, chunkData.FileID, chunkData.ChunkIndex, chunkData.Data, chunkData.Checksum)
+	mr.uploadMutex.Unlock()
+	if !accepted {
 		return
 	}
 
-	// 累加数据（StorageInline）
-	file.Data = append(file.Data, chunkData.Data...)
-	file.UploadedChunks++
-	if err := mr.server.fileRepo.Update(file); err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to update file progress: %v", err)
-		return
-	}
-
-	// 6. 发布进度事件
+	// 4. 发布进度事件
 	progress := int(float64(file.UploadedChunks) / float64(file.TotalChunks) * 100)
 	mr.server.eventBus.Publish(events.EventFileProgress, events.FileEvent{
 		File:       file,
@@ -479,39 +539,87 @@ func (mr *MessageRouter) HandleFileChunk(transportMsg *transport.Message) {
 		Progress:   progress,
 	})
 
-	// 7. 检查是否完成
+	// 5. 收齐后提交（位图已满，后续重复分块不会再进入此处）
 	if file.UploadedChunks >= file.TotalChunks {
 		mr.handleFileUploadComplete(file)
 	}
 
-	// 8. 转发分块给其他客户端
-	transportMsg.Type = transport.MessageTypeData
-	if err := mr.server.transport.SendMessage(transportMsg); err != nil {
+	// 6. 转发分块给其他客户端（去掉签名信封，仅转发分块内容）
+	encrypted, err := mr.server.crypto.EncryptMessage(payload)
+	if err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to encrypt file chunk: %v", err)
+		return
+	}
+	forward := *transportMsg
+	forward.Type = transport.MessageTypeData
+	forward.Payload = encrypted
+	if err := mr.server.transport.SendMessage(&forward); err != nil {
 		mr.server.logger.Error("[MessageRouter] Failed to forward file chunk: %v", err)
 	}
 }
 
-// handleFileUploadComplete 处理文件上传完成
-func (mr *MessageRouter) handleFileUploadComplete(file *models.File) {
-	mr.server.logger.Info("[MessageRouter] File upload completed: %s (%s)", file.Filename, file.ID)
+// acceptChunk 写入并登记一个分块，返回更新后的文件记录与是否为新分块（调用方持有 uploadMutex）
+// 分块按偏移写入临时文件，因此允许乱序与并行到达
+func (mr *MessageRouter) acceptChunk(senderID, fileID string, index int, data []byte, checksum string) (*models.File, bool) {
+	// 1. 只接受文件上传者本人的分块
+	file, err := mr.server.fileRepo.GetByID(fileID)
+	if err != nil {
+		// 未登记（如超出配额被拒绝）的文件分块直接丢弃
+		mr.server.logger.Debug("[MessageRouter] Chunk for unknown file %s dropped: %v", fileID, err)
+		return nil, false
+	}
+	if file.SenderID != senderID {
+		mr.server.logger.Warn("[MessageRouter] Chunk for %s from non-uploader: %s", fileID, senderID)
+		return nil, false
+	}
 
-	// 1. 更新文件状态
-	file.UploadStatus = models.UploadStatusCompleted
-	if err := mr.server.fileRepo.Update(file); err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to update file status: %v", err)
-		return
+	// 2. 幂等：已完成的文件或已接收的分块不再处理
+	if file.UploadStatus == models.UploadStatusCompleted || file.HasChunk(index) {
+		mr.server.logger.Debug("[MessageRouter] Duplicate chunk %d for file %s ignored", index, fileID)
+		return file, false
 	}
 
-	// 2. 验证完整性（如果有SHA256）
-	if file.SHA256 != "" {
-		sum := sha256.Sum256(file.Data)
-		actual := fmt.Sprintf("%x", sum[:])
-		if actual != file.SHA256 {
-			mr.server.logger.Error("[MessageRouter] File SHA256 mismatch: expect=%s actual=%s", file.SHA256, actual)
-		} else {
-			mr.server.logger.Debug("[MessageRouter] File SHA256 verified: %s", actual)
+	// 3. 按偏移写入上传临时文件
+	if err := mr.server.fileRepo.WriteChunk(file, index, data); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to store chunk %d of %s: %v", index, fileID, err)
+		return nil, false
+	}
+
+	// 4. 更新位图并保存分块记录
+	file.MarkChunk(index)
+	file.UploadedChunks++
+	file.UploadStatus = models.UploadStatusUploading
+	chunk := &models.FileChunk{
+		FileID:     fileID,
+		ChunkIndex: index,
+		Size:       len(data),
+		Checksum:   checksum,
+		Uploaded:   true,
+		UploadedAt: time.Now(),
+	}
+	if err := mr.server.fileRepo.RecordChunk(file, chunk); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to record chunk %d of %s: %v", index, fileID, err)
+		return nil, false
+	}
+	return file, true
+}
+
+// handleFileUploadComplete 处理文件上传完成
+// 校验整体 SHA-256 后将内容移入内容存储（相同内容去重）
+func (mr *MessageRouter) handleFileUploadComplete(file *models.File) {
+	// 1. 校验并提交内容
+	if err := mr.server.fileRepo.CommitContent(file); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to commit file %s: %v", file.ID, err)
+		// 临时文件已丢弃，清空分块状态以便续传时完整重传
+		if err := mr.server.fileRepo.ResetChunks(file.ID, models.UploadStatusFailed); err != nil {
+			mr.server.logger.Error("[MessageRouter] Failed to update file status: %v", err)
 		}
+		return
 	}
+	mr.server.logger.Info("[MessageRouter] File upload completed: %s (%s) sha256=%s", file.Filename, file.ID, file.SHA256)
+
+	// 2. 提交后处理：生成预览、广播文件消息、附件分析（异步，不阻塞分块处理）
+	mr.server.artifactAnalyzer.Enqueue(file)
 
 	// 3. 发布完成事件
 	mr.server.eventBus.Publish(events.EventFileUploaded, events.FileEvent{
@@ -522,23 +630,149 @@ func (mr *MessageRouter) handleFileUploadComplete(file *models.File) {
 	})
 }
 
-// HandleFileDownloadRequest 处理文件下载请求
-func (mr *MessageRouter) HandleFileDownloadRequest(transportMsg *transport.Message) {
-	mr.server.logger.Debug("[MessageRouter] File download request from: %s", transportMsg.SenderID)
+// publishFileMessage 将预览写入文件消息并广播
+// 消息时间戳更新为完成时间：已通过同步拿到未完成版本的成员会在下次同步时收到更新
+func (mr *MessageRouter) publishFileMessage(file *models.File, preview *analysis.FilePreview) {
+	msg, err := mr.server.messageRepo.GetByID(file.MessageID)
+	if err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to load file message %s: %v", file.MessageID, err)
+		return
+	}
+	if msg.Content == nil {
+		msg.Content = models.MessageContent{}
+	}
+	msg.Content["sha256"] = file.SHA256
+	if preview != nil {
+		for k, v := range previewContent(preview) {
+			msg.Content[k] = v
+		}
+	}
+	msg.Timestamp = time.Now()
+	if err := mr.server.messageRepo.Update(msg); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to update file message %s: %v", msg.ID, err)
+	}
+
+	if err := mr.server.broadcastManager.Broadcast(msg); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to broadcast file message: %v", err)
+	}
+}
+
+// previewContent 文件消息中携带的预览字段（缩略图为 Base64 PNG）
+func previewContent(p *analysis.FilePreview) map[string]interface{} {
+	content := map[string]interface{}{"preview_kind": p.Kind}
+	if len(p.Thumbnail) > 0 {
+		content["thumbnail"] = base64.StdEncoding.EncodeToString(p.Thumbnail)
+		content["width"] = p.Width
+		content["height"] = p.Height
+	}
+	if p.Text != "" {
+		content["preview_text"] = p.Text
+	}
+	if p.Language != "" {
+		content["language"] = p.Language
+	}
+	return content
+}
+
+// sendFileRejected 通知上传者文件被拒绝（file.rejected）
+func (mr *MessageRouter) sendFileRejected(file *models.File, reason error) {
+	response := map[string]interface{}{
+		"type":    "file.rejected",
+		"file_id": file.ID,
+		"reason":  reason.Error(),
+	}
+	var quotaErr *QuotaError
+	if errors.As(reason, &quotaErr) {
+		response["reason"] = quotaErr.Err.Error()
+		response["limit"] = quotaErr.Limit
+		response["used"] = quotaErr.Used
+		response["size"] = quotaErr.Size
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to marshal file rejection: %v", err)
+		return
+	}
+	encrypted, err := mr.server.crypto.EncryptMessage(data)
+	if err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to encrypt file rejection: %v", err)
+		return
+	}
+	responseMsg := &transport.Message{
+		Type:      transport.MessageTypeControl,
+		SenderID:  "server",
+		Payload:   encrypted,
+		Timestamp: time.Now(),
+	}
+	if err := mr.server.transport.SendMessage(responseMsg); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to send file rejection: %v", err)
+	}
+}
+
+// HandleFileStatusQuery 处理上传状态查询（file.status）：返回服务端已接收的分块位图，供客户端续传
+func (mr *MessageRouter) HandleFileStatusQuery(transportMsg *transport.Message, payload []byte) {
+	// 1. 解析请求
+	var req struct {
+		FileID    string `json:"file_id"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to unmarshal file status query: %v", err)
+		return
+	}
+
+	// 2. 构建响应（文件未登记时 found=false，客户端需重发元数据）
+	response := map[string]interface{}{
+		"type":       "file.status",
+		"request_id": req.RequestID,
+		"file_id":    req.FileID,
+		"found":      false,
+	}
+	if file, err := mr.server.fileRepo.GetByID(req.FileID); err == nil && file.SenderID == transportMsg.SenderID {
+		response["found"] = true
+		response["upload_status"] = file.UploadStatus
+		response["total_chunks"] = file.TotalChunks
+		response["uploaded_chunks"] = file.UploadedChunks
+		response["chunk_bitmap"] = file.ChunkBitmap
+	}
 
-	// 1. 解密请求
-	decrypted, err := mr.server.crypto.DecryptMessage(transportMsg.Payload)
+	// 3. 加密并发送
+	data, err := json.Marshal(response)
+	if err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to marshal file status: %v", err)
+		return
+	}
+	encrypted, err := mr.server.crypto.EncryptMessage(data)
 	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to decrypt download request: %v", err)
+		mr.server.logger.Error("[MessageRouter] Failed to encrypt file status: %v", err)
 		return
 	}
+	responseMsg := &transport.Message{
+		Type:      transport.MessageTypeControl,
+		SenderID:  "server",
+		Payload:   encrypted,
+		Timestamp: time.Now(),
+	}
+	if err := mr.server.transport.SendMessage(responseMsg); err != nil {
+		mr.server.logger.Error("[MessageRouter] Failed to send file status: %v", err)
+	}
+}
 
+// HandleFileDownloadRequest 处理文件下载请求
+// chunks 为空时请求整个文件（先发送文件元数据）；swarm 为 true 时部分分块分派给持有缓存的成员
+func (mr *MessageRouter) HandleFileDownloadRequest(transportMsg *transport.Message, payload []byte) {
+	mr.server.logger.Debug("[MessageRouter] File download request from: %s", transportMsg.SenderID)
+
+	// 1. 解析请求
 	type DownloadRequest struct {
 		FileID string `json:"file_id"`
+		Chunks []int  `json:"chunks"`
+		Swarm  bool   `json:"swarm"`
 	}
 
 	var req DownloadRequest
-	if err := json.Unmarshal(decrypted, &req); err != nil {
+	if err := json.Unmarshal(payload, &req); err != nil {
 		mr.server.logger.Error("[MessageRouter] Failed to unmarshal download request: %v", err)
 		return
 	}
@@ -556,24 +790,67 @@ func (mr *MessageRouter) HandleFileDownloadRequest(transportMsg *transport.Messa
 		return
 	}
 
-	// 4. 获取文件分块
-	chunks, err := mr.server.fileRepo.GetChunksByFileID(req.FileID)
+	if file.UploadStatus != models.UploadStatusCompleted {
+		mr.server.logger.Warn("[MessageRouter] File not ready for download: %s (%s)", req.FileID, file.UploadStatus)
+		return
+	}
+	if file.ChunkSize <= 0 {
+		mr.server.logger.Warn("[MessageRouter] Invalid chunk size for file %s", file.ID)
+		return
+	}
+
+	// 4. 确定所需分块（忽略越界索引）
+	chunks := make([]int, 0, file.TotalChunks)
+	if len(req.Chunks) == 0 {
+		for index := 0; index < file.TotalChunks; index++ {
+			chunks = append(chunks, index)
+		}
+	} else {
+		for _, index := range req.Chunks {
+			if index >= 0 && index < file.TotalChunks {
+				chunks = append(chunks, index)
+			}
+		}
+	}
+
+	// 5. 打开文件内容（从磁盘流式读取）
+	content, err := mr.server.fileRepo.OpenContent(file)
 	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to get file chunks: %v", err)
+		mr.server.logger.Error("[MessageRouter] Failed to open file content: %v", err)
 		return
 	}
+	defer content.Close()
 
-	// 5. 发送文件元数据
-	mr.sendFileMetadataToMember(transportMsg.SenderID, file)
+	// 6. 发送文件元数据
+	if len(req.Chunks) == 0 {
+		mr.sendFileMetadataToMember(transportMsg.SenderID, file)
+	}
 
-	// 6. 逐块发送（如果有分块）
-	for _, chunk := range chunks {
-		mr.sendFileChunkToMember(transportMsg.SenderID, file, chunk)
+	// 7. 分派给持有者的分块由请求方直接向持有者拉取，其余逐块读取并发送
+	own := chunks
+	if req.Swarm {
+		own = mr.server.swarmManager.Assign(file, transportMsg.SenderID, chunks)
+	}
+	buffer := make([]byte, file.ChunkSize)
+	for _, index := range own {
+		if _, err := content.Seek(int64(index)*int64(file.ChunkSize), io.SeekStart); err != nil {
+			mr.server.logger.Error("[MessageRouter] Failed to seek chunk %d of %s: %v", index, file.ID, err)
+			return
+		}
+		n, err := io.ReadFull(content, buffer)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			mr.server.logger.Error("[MessageRouter] Failed to read chunk %d of %s: %v", index, file.ID, err)
+			return
+		}
+		if err := mr.sendFileChunkToMember(transportMsg.SenderID, file, index, buffer[:n], "server"); err != nil {
+			mr.server.logger.Error("[MessageRouter] Failed to send chunk %d of %s: %v", index, file.ID, err)
+			return
+		}
 		time.Sleep(10 * time.Millisecond) // 避免淹没接收方
 	}
 
-	mr.server.logger.Info("[MessageRouter] File sent to member: %s -> %s",
-		file.Filename, transportMsg.SenderID)
+	mr.server.logger.Info("[MessageRouter] File sent to member: %s -> %s (%d/%d chunks from server)",
+		file.Filename, transportMsg.SenderID, len(own), len(chunks))
 }
 
 // sendFileMetadataToMember 发送文件元数据给指定成员
@@ -602,34 +879,22 @@ func (mr *MessageRouter) sendFileMetadataToMember(memberID string, file *models.
 	return mr.server.broadcastManager.Broadcast(msg)
 }
 
-// sendFileChunkToMember 发送文件分块给指定成员
-func (mr *MessageRouter) sendFileChunkToMember(memberID string, file *models.File, chunk *models.FileChunk) error {
-	// 从内存数据切片中提取该分块
-	if file.ChunkSize <= 0 {
-		mr.server.logger.Warn("[MessageRouter] Invalid chunk size for file %s", file.ID)
-		return nil
-	}
-	offset := chunk.ChunkIndex * file.ChunkSize
-	if offset < 0 || offset >= len(file.Data) {
-		mr.server.logger.Warn("[MessageRouter] Chunk offset out of range: %d", chunk.ChunkIndex)
-		return nil
-	}
-	end := offset + file.ChunkSize
-	if end > len(file.Data) {
-		end = len(file.Data)
-	}
-	bytes := file.Data[offset:end]
+// sendFileChunkToMember 发送文件分块给指定成员（source 为分块来源）
+func (mr *MessageRouter) sendFileChunkToMember(memberID string, file *models.File, chunkIndex int, data []byte, source string) error {
+	sum := sha256.Sum256(data)
 
 	// base64 编码
-	b64 := base64.StdEncoding.EncodeToString(bytes)
+	b64 := base64.StdEncoding.EncodeToString(data)
 
 	payload := map[string]interface{}{
 		"type":         "file.chunk",
 		"file_id":      file.ID,
-		"chunk_index":  chunk.ChunkIndex,
+		"chunk_index":  chunkIndex,
 		"total_chunks": file.TotalChunks,
-		"checksum":     chunk.Checksum,
+		"checksum":     fmt.Sprintf("%x", sum[:]),
 		"data":         b64,
+		"requester_id": memberID,
+		"source":       source,
 		"timestamp":    time.Now().Unix(),
 	}
 
@@ -647,7 +912,7 @@ func (mr *MessageRouter) sendFileChunkToMember(memberID string, file *models.Fil
 		Payload:   enc,
 		Timestamp: time.Now(),
 	}
-	mr.server.logger.Debug("[MessageRouter] Sending chunk %d to member %s", chunk.ChunkIndex, memberID)
+	mr.server.logger.Debug("[MessageRouter] Sending chunk %d to member %s (from %s)", chunkIndex, memberID, source)
 	return mr.server.transport.SendMessage(tmsg)
 }
 
@@ -737,19 +1002,12 @@ func (rl *RateLimiter) Reset(memberID string) {
 // HandleSyncRequest 处理同步请求
 // 参考: internal/client/sync_manager.go 的客户端实现
 // 参考: docs/PROTOCOL.md - 5.3 消息同步
-func (mr *MessageRouter) HandleSyncRequest(transportMsg *transport.Message) {
+func (mr *MessageRouter) HandleSyncRequest(transportMsg *transport.Message, payload []byte) {
 	mr.server.logger.Debug("[MessageRouter] Sync request from: %s", transportMsg.SenderID)
 
-	// 1. 解密请求
-	decrypted, err := mr.server.crypto.DecryptMessage(transportMsg.Payload)
-	if err != nil {
-		mr.server.logger.Error("[MessageRouter] Failed to decrypt sync request: %v", err)
-		return
-	}
-
-	// 2. 解析请求
+	// 1-2. 解析请求
 	var req map[string]interface{}
-	if err := json.Unmarshal(decrypted, &req); err != nil {
+	if err := json.Unmarshal(payload, &req); err != nil {
 		mr.server.logger.Error("[MessageRouter] Failed to unmarshal sync request: %v", err)
 		return
 	}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crosswire/internal/models"
+)
+
+// 桥接来源链
+// 参考: docs/ARCHITECTURE.md - 3.2.4 桥接 (Bridge)
+//
+// 消息经桥接跨频道转发时保留原消息ID，每经过一个服务器就在 Metadata 中追加一跳：
+// 该服务器以自身密钥对"消息摘要 ‖ 上一跳签名"签名，形成可逐跳校验的来源链。
+// 消息摘要只覆盖跨跳不变的字段（ID、原始发送者、类型、内容、时间戳），
+// 因此各跳的成员ID映射不会破坏已有签名。
+
+const (
+	metaProvenance     = "provenance"       // 来源链
+	metaOriginSenderID = "origin_sender_id" // 原始发送者ID（首跳前的 SenderID）
+	metaOriginNickname = "origin_nickname"  // 原始发送者昵称
+)
+
+var (
+	// ErrRelayLoop 消息已经过本服务器（桥接环路）
+	ErrRelayLoop = errors.New("message already relayed through this server")
+	// ErrAlreadyRelayed 消息已存在于本频道
+	ErrAlreadyRelayed = errors.New("message already exists in channel")
+)
+
+// ProvenanceHop 来源链中的一跳
+type ProvenanceHop struct {
+	ServerID  string `json:"server_id"`  // 签名服务器（频道ID）
+	PublicKey []byte `json:"public_key"` // 签名服务器公钥
+	Origin    string `json:"origin"`     // 消息来自的频道ID
+	Signature []byte `json:"signature"`  // Ed25519(摘要 ‖ 上一跳签名)
+	RelayedAt int64  `json:"relayed_at"` // 签名时间
+}
+
+// StampProvenance 以本服务器身份在消息来源链上追加一跳
+// origin 为消息来自的频道ID；消息已经过本服务器时返回 ErrRelayLoop
+func (s *Server) StampProvenance(msg *models.Message, origin string) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+
+	hops, err := GetProvenance(msg)
+	if err != nil {
+		return err
+	}
+	if HasVisited(msg, s.config.ChannelID) {
+		return ErrRelayLoop
+	}
+
+	if msg.Metadata == nil {
+		msg.Metadata = models.JSONField{}
+	}
+	if _, ok := msg.Metadata[metaOriginSenderID]; !ok {
+		msg.Metadata[metaOriginSenderID] = msg.SenderID
+		msg.Metadata[metaOriginNickname] = msg.SenderNickname
+	}
+
+	var prev []byte
+	if len(hops) > 0 {
+		prev = hops[len(hops)-1].Signature
+	}
+	hop := ProvenanceHop{
+		ServerID:  s.config.ChannelID,
+		PublicKey: s.config.PublicKey,
+		Origin:    origin,
+		Signature: ed25519.Sign(s.config.PrivateKey, append(messageDigest(msg), prev...)),
+		RelayedAt: time.Now().Unix(),
+	}
+	msg.Metadata[metaProvenance] = append(hops, hop)
+	return nil
+}
+
+// GetProvenance 解析消息携带的来源链（无来源链时返回空）
+func GetProvenance(msg *models.Message) ([]ProvenanceHop, error) {
+	if msg == nil || msg.Metadata == nil {
+		return nil, nil
+	}
+	raw, ok := msg.Metadata[metaProvenance]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	// 经过JSON往返后为 []interface{}，统一重新编解码
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provenance: %w", err)
+	}
+	var hops []ProvenanceHop
+	if err := json.Unmarshal(data, &hops); err != nil {
+		return nil, fmt.Errorf("invalid provenance: %w", err)
+	}
+	return hops, nil
+}
+
+// VerifyProvenance 逐跳校验来源链签名，返回校验通过的来源链
+func VerifyProvenance(msg *models.Message) ([]ProvenanceHop, error) {
+	hops, err := GetProvenance(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := messageDigest(msg)
+	var prev []byte
+	for i, hop := range hops {
+		if len(hop.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("hop %d (%s): invalid public key", i, hop.ServerID)
+		}
+		if !ed25519.Verify(hop.PublicKey, append(append([]byte(nil), digest...), prev...), hop.Signature) {
+			return nil, fmt.Errorf("hop %d (%s): invalid signature", i, hop.ServerID)
+		}
+		prev = hop.Signature
+	}
+	return hops, nil
+}
+
+// HasVisited 检查消息是否来自或经过指定频道
+func HasVisited(msg *models.Message, channelID string) bool {
+	hops, err := GetProvenance(msg)
+	if err != nil {
+		return false
+	}
+	for _, hop := range hops {
+		if hop.ServerID == channelID || hop.Origin == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginSender 获取消息的原始发送者ID与昵称（未经桥接时即为当前发送者）
+func OriginSender(msg *models.Message) (string, string) {
+	if msg.Metadata != nil {
+		if id, ok := msg.Metadata[metaOriginSenderID].(string); ok && id != "" {
+			nickname, _ := msg.Metadata[metaOriginNickname].(string)
+			return id, nickname
+		}
+	}
+	return msg.SenderID, msg.SenderNickname
+}
+
+// messageDigest 计算跨跳不变字段的摘要
+func messageDigest(msg *models.Message) []byte {
+	senderID, _ := OriginSender(msg)
+	data, _ := json.Marshal(struct {
+		ID          string                `json:"id"`
+		SenderID    string                `json:"sender_id"`
+		Type        models.MessageType    `json:"type"`
+		Content     models.MessageContent `json:"content"`
+		ContentText string                `json:"content_text"`
+		Timestamp   int64                 `json:"timestamp"`
+	}{
+		ID:          msg.ID,
+		SenderID:    senderID,
+		Type:        msg.Type,
+		Content:     msg.Content,
+		ContentText: msg.ContentText,
+		Timestamp:   msg.Timestamp.Unix(),
+	})
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
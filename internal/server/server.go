@@ -35,6 +35,9 @@ type Server struct {
 	challengeManager *ChallengeManager
 	offlineManager   *OfflineManager
 	spamDetector     *SpamDetector
+	artifactAnalyzer *ArtifactAnalyzer
+	storageManager   *StorageManager
+	swarmManager     *SwarmManager
 	// 允许服务端发送用户消息
 	// 无需额外组件，复用 BroadcastManager + MessageRepository
 
@@ -52,6 +55,7 @@ type Server struct {
 	fileRepo      *storage.FileRepository
 	challengeRepo *storage.ChallengeRepository
 	auditRepo     *storage.AuditRepository
+	sessionRepo   *storage.SessionRepository
 
 	// 状态
 	isRunning bool
@@ -85,6 +89,13 @@ type ServerConfig struct {
 	MessageTTL     time.Duration
 	EnableOffline  bool
 
+	// 文件存储配置（配额为 0 表示不限制）
+	MaxFileSize       int64           // 单个文件大小上限（字节）
+	MaxMemberStorage  int64           // 每个成员上传文件总量上限（字节）
+	MaxChannelStorage int64           // 频道文件总量上限（字节）
+	FileRetention     RetentionPolicy // 文件保留策略
+	GCInterval        time.Duration   // 存储回收间隔（默认 1 小时）
+
 	// 安全配置
 	EnableRateLimit bool
 	MaxMessageRate  int  // 每分钟最多消息数
@@ -120,6 +131,13 @@ type ServerStatsDTO struct {
 	RejectedMessages uint64    `json:"rejected_messages"`
 }
 
+// PeerStatsDTO 传输层对端统计（附带已识别的成员信息）
+type PeerStatsDTO struct {
+	transport.PeerStats
+	MemberID string `json:"member_id,omitempty"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
 // DefaultServerConfig 默认配置
 var DefaultServerConfig = &ServerConfig{
 	MaxMembers:      100,
@@ -177,8 +195,9 @@ func NewServer(
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// 打开频道数据库
-	if err := db.OpenChannelDB(config.ChannelID); err != nil {
+	// 打开频道数据库，之后的仓库均绑定到本频道（同一进程可同时打开其他频道）
+	db, err := db.ForChannel(config.ChannelID)
+	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to open channel database: %w", err)
 	}
@@ -217,6 +236,7 @@ func NewServer(
 		fileRepo:      storage.NewFileRepository(db),
 		challengeRepo: storage.NewChallengeRepository(db),
 		auditRepo:     storage.NewAuditRepository(db),
+		sessionRepo:   storage.NewSessionRepository(db),
 	}
 
 	// 初始化子模块
@@ -227,6 +247,9 @@ func NewServer(
 	s.challengeManager = NewChallengeManager(s)
 	s.offlineManager = NewOfflineManager(s)
 	s.spamDetector = NewSpamDetector(s)
+	s.artifactAnalyzer = NewArtifactAnalyzer(s)
+	s.storageManager = NewStorageManager(s)
+	s.swarmManager = NewSwarmManager(s)
 
 	return s, nil
 }
@@ -260,6 +283,11 @@ func (s *Server) Start() error {
 	}
 	s.logger.Info("[Server] Channel manager initialized successfully")
 
+	// 恢复持久化的成员会话
+	if err := s.authManager.LoadSessions(); err != nil {
+		s.logger.Warn("[Server] Failed to restore sessions: %v", err)
+	}
+
 	// 启动传输层
 	s.logger.Info("[Server] Step 3: Starting transport layer...")
 	if err := s.transport.Start(); err != nil {
@@ -284,6 +312,12 @@ func (s *Server) Start() error {
 	s.wg.Add(1)
 	go s.messageRouter.Run()
 
+	s.wg.Add(1)
+	go s.artifactAnalyzer.Run()
+
+	s.wg.Add(1)
+	go s.storageManager.Run()
+
 	// 启动离线消息管理器
 	if err := s.offlineManager.Start(); err != nil {
 		s.logger.Error("[Server] Failed to start offline manager: %v", err)
@@ -399,62 +433,34 @@ func (s *Server) initTransport() error {
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
 
-	// 设置模式与密钥
-	switch tr := t.(type) {
-	case *transport.ARPTransport:
-		tr.SetMode("server")
-		tr.SetServerKeys(s.config.PrivateKey, s.config.PublicKey)
-	case *transport.MDNSTransport:
-		tr.SetMode("server")
-		tr.SetServerKeys(s.config.PrivateKey, s.config.PublicKey)
-		tr.SetChannelInfo(s.config.ChannelID, s.config.ChannelName)
-	case *transport.HTTPSTransport:
-		tr.SetMode("server")
-		tr.SetChannelInfo(s.config.ChannelID, s.config.ChannelName)
+	// 设置模式与密钥（传输层按需实现对应的可选接口）
+	if rs, ok := t.(transport.RoleSetter); ok {
+		rs.SetMode("server")
+	}
+	if ks, ok := t.(transport.ServerKeySetter); ok {
+		ks.SetServerKeys(s.config.PrivateKey, s.config.PublicKey)
+	}
+	if cs, ok := t.(transport.ChannelInfoSetter); ok {
+		cs.SetChannelInfo(s.config.ChannelID, s.config.ChannelName)
 	}
 
-	// 注册文件接收回调（将文件片段转换为服务器内部处理）
-	_ = t.OnFileReceived(func(ft *transport.FileTransfer) {
-		s.handleIncomingFile(ft)
-	})
+	// 文件只通过带成员签名的 file.* 控制消息上传，传输层文件帧无法认证发送者
+	_ = t.OnFileReceived(s.rejectTransportFile)
 
 	s.transport = t
 	return nil
 }
 
-// handleIncomingFile 处理来自传输层的文件分片（统一走 MessageRouter 文件处理）
-func (s *Server) handleIncomingFile(ft *transport.FileTransfer) {
+// rejectTransportFile 丢弃传输层直接投递的文件帧（SendFile）
+// 参考: docs/PROTOCOL.md - 2.2.5 控制消息认证
+func (s *Server) rejectTransportFile(ft *transport.FileTransfer) {
 	if ft == nil {
 		return
 	}
-	// 构造与 MessageRouter.HandleFileChunk 兼容的结构
-	chunk := struct {
-		FileID      string `json:"file_id"`
-		ChunkIndex  int    `json:"chunk_index"`
-		Data        []byte `json:"data"`
-		Checksum    string `json:"checksum"`
-		TotalChunks int    `json:"total_chunks"`
-	}{
-		FileID:      ft.FileID,
-		ChunkIndex:  ft.ChunkIndex,
-		Data:        ft.Data,
-		Checksum:    ft.ChunkChecksum,
-		TotalChunks: ft.TotalChunks,
-	}
-
-	// 序列化并加密
-	bytes, err := json.Marshal(&chunk)
-	if err != nil {
-		return
-	}
-	enc, err := s.crypto.EncryptMessage(bytes)
-	if err != nil {
-		return
-	}
-
-	// 投递到文件分块处理
-	msg := &transport.Message{Type: transport.MessageTypeControl, Payload: enc, Timestamp: time.Now()}
-	s.messageRouter.HandleFileChunk(msg)
+	s.logger.Warn("[Server] Rejected unsigned transport file frame: file=%s chunk=%d", ft.FileID, ft.ChunkIndex)
+	s.stats.mutex.Lock()
+	s.stats.RejectedMessages++
+	s.stats.mutex.Unlock()
 }
 
 // handleIncomingMessage 处理来自传输层的消息
@@ -471,7 +477,7 @@ func (s *Server) handleIncomingMessage(msg *transport.Message) {
 	// 根据消息类型路由
 	switch msg.Type {
 	case transport.MessageTypeAuth:
-		s.authManager.HandleJoinRequest(msg)
+		s.authManager.HandleAuthMessage(msg)
 
 	case transport.MessageTypeData:
 		s.messageRouter.HandleClientMessage(msg)
@@ -497,11 +503,25 @@ func (s *Server) handleControlMessage(msg *transport.Message) {
 		return
 	}
 
+	// 校验成员签名：传输层的 SenderID 只是发送方的声明，持有频道密钥的任何人都能伪造
+	memberID, payload, err := s.authManager.VerifyControl(decrypted)
+	if err != nil {
+		s.logger.Warn("[Server] Rejected control message from %s (claimed %s): %v", memberID, msg.SenderID, err)
+		s.stats.mutex.Lock()
+		s.stats.RejectedMessages++
+		s.stats.mutex.Unlock()
+		return
+	}
+
+	// 后续处理一律以认证后的成员ID为发送者
+	verified := *msg
+	verified.SenderID = memberID
+
 	// 简单解析获取类型字段
 	var msgType struct {
 		Type string `json:"type"`
 	}
-	if err := json.Unmarshal(decrypted, &msgType); err != nil {
+	if err := json.Unmarshal(payload, &msgType); err != nil {
 		s.logger.Error("[Server] Failed to unmarshal control message type: %v", err)
 		return
 	}
@@ -511,20 +531,31 @@ func (s *Server) handleControlMessage(msg *transport.Message) {
 	// 根据详细类型路由
 	switch msgType.Type {
 	case "sync.request":
-		s.messageRouter.HandleSyncRequest(msg)
+		s.messageRouter.HandleSyncRequest(&verified, payload)
 	case "status.update":
-		s.channelManager.HandleMemberStatus(msg)
+		s.channelManager.HandleMemberStatus(&verified, payload)
 	case "file.upload":
-		s.messageRouter.HandleFileUpload(msg)
+		s.messageRouter.HandleFileUpload(&verified, payload)
+	case "file.metadata":
+		s.messageRouter.HandleFileMetadata(&verified, payload)
 	case "file.chunk":
-		s.messageRouter.HandleFileChunk(msg)
-	case "file.download":
-		s.messageRouter.HandleFileDownloadRequest(msg)
+		s.messageRouter.HandleFileChunk(&verified, payload)
+	case "file.complete":
+		// 上传完成以分块计数为准，此处仅记录
+		s.logger.Debug("[Server] File upload complete notice from: %s", memberID)
+	case "file.status":
+		s.messageRouter.HandleFileStatusQuery(&verified, payload)
+	case "file.download", "file.request":
+		s.messageRouter.HandleFileDownloadRequest(&verified, payload)
+	case "file.have":
+		s.swarmManager.HandleHave(&verified, payload)
 	case "challenge.submit":
 		// 将 Flag 提交交给 ChallengeManager 统一处理
-		s.challengeManager.HandleFlagSubmission(msg)
+		s.challengeManager.HandleFlagSubmission(&verified, payload)
 	case "ack":
-		s.handleMessageAck(msg)
+		s.handleMessageAck(&verified, payload)
+	case "auth.leave":
+		s.authManager.HandleLeave(&verified, payload)
 	default:
 		s.logger.Warn("[Server] Unknown control message type: %s", msgType.Type)
 	}
@@ -597,6 +628,84 @@ func (s *Server) SendUserMessage(content string, msgType models.MessageType, _ s
 	return msg, nil
 }
 
+// RelayMessage 发布经桥接从其他频道转发来的消息
+// 保留原消息ID，以 senderID（本频道内的映射成员）作为发送者，
+// 追加本服务器的来源签名后复用 BroadcastManager 广播
+func (s *Server) RelayMessage(msg *models.Message, origin string, senderID string) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+	if !s.channelManager.HasMember(senderID) {
+		return fmt.Errorf("relay sender not found: %s", senderID)
+	}
+	if s.broadcastManager.IsSentByMe(msg.ID) {
+		return ErrAlreadyRelayed
+	}
+	if existing, err := s.messageRepo.GetByID(msg.ID); err == nil && existing != nil {
+		return ErrAlreadyRelayed
+	}
+
+	if err := s.StampProvenance(msg, origin); err != nil {
+		return err
+	}
+	msg.SenderID = senderID
+	msg.ChannelID = s.config.ChannelID
+	msg.RoomType = "main"
+	msg.ChallengeID = ""
+
+	if err := s.messageRepo.Create(msg); err != nil {
+		return fmt.Errorf("failed to persist message: %w", err)
+	}
+	if msg.Type == models.MessageTypeReaction {
+		s.messageRouter.handleReaction(msg)
+	}
+	if err := s.broadcastManager.Broadcast(msg); err != nil {
+		return fmt.Errorf("failed to broadcast message: %w", err)
+	}
+
+	s.eventBus.Publish(events.EventMessageReceived, events.NewMessageReceivedEvent(msg, s.config.ChannelID))
+	s.logger.Debug("[Server] Relayed message %s from %s as %s", msg.ID, origin, senderID)
+	return nil
+}
+
+// EnsureRelayMember 确保桥接映射成员存在（幂等），供 RelayMessage 作为发送者
+// 映射成员没有公钥，不能直接向本服务器发送消息
+func (s *Server) EnsureRelayMember(memberID, nickname, origin string) (*models.Member, error) {
+	if member := s.channelManager.GetMemberByID(memberID); member != nil {
+		return member, nil
+	}
+
+	now := time.Now()
+	member := &models.Member{
+		ID:            memberID,
+		ChannelID:     s.config.ChannelID,
+		Nickname:      nickname,
+		Role:          models.RoleMember,
+		Status:        models.StatusOnline,
+		JoinedAt:      now,
+		JoinTime:      now,
+		LastSeenAt:    now,
+		LastHeartbeat: now,
+		IsOnline:      true,
+		Metadata:      models.JSONField{"bridge_origin": origin},
+	}
+	if err := s.channelManager.AddMember(member); err != nil {
+		return nil, err
+	}
+	s.authManager.broadcastMemberJoined(member)
+	return member, nil
+}
+
+// GetEventBus 获取事件总线
+func (s *Server) GetEventBus() *events.EventBus {
+	return s.eventBus
+}
+
+// GetDatabase 获取绑定到本频道的数据库视图
+func (s *Server) GetDatabase() *storage.Database {
+	return s.db
+}
+
 // AddMember 添加成员
 func (s *Server) AddMember(member *models.Member) error {
 	return s.channelManager.AddMember(member)
@@ -706,6 +815,36 @@ func (s *Server) GetStats() ServerStatsDTO {
 	}
 }
 
+// peerMetrics 当前传输层的按对端统计（不支持时为 nil）
+func (s *Server) peerMetrics() *transport.PeerMetrics {
+	if p, ok := s.transport.(transport.PeerStatsProvider); ok {
+		return p.PeerMetrics()
+	}
+	return nil
+}
+
+// GetPeerStats 获取传输层按对端统计
+func (s *Server) GetPeerStats() []PeerStatsDTO {
+	snapshot := s.peerMetrics().Snapshot()
+	result := make([]PeerStatsDTO, 0, len(snapshot))
+	for _, ps := range snapshot {
+		dto := PeerStatsDTO{PeerStats: ps}
+		if memberID := s.channelManager.GetMemberIDByPeer(ps.Peer); memberID != "" {
+			dto.MemberID = memberID
+			if member := s.channelManager.GetMemberByID(memberID); member != nil {
+				dto.Nickname = member.Nickname
+			}
+		}
+		result = append(result, dto)
+	}
+	return result
+}
+
+// GetPeerStatsHistory 获取对端统计的时间序列
+func (s *Server) GetPeerStatsHistory(peer string, since time.Time) []transport.PeerSample {
+	return s.peerMetrics().History(peer, since)
+}
+
 // IsRunning 检查是否运行中
 func (s *Server) IsRunning() bool {
 	s.mutex.RLock()
@@ -745,14 +884,7 @@ func (s *Server) statsReporter() {
 
 // handleMessageAck 处理消息确认
 // 参考: docs/PROTOCOL.md - 消息确认机制
-func (s *Server) handleMessageAck(msg *transport.Message) {
-	// 解密ACK消息
-	decrypted, err := s.crypto.DecryptMessage(msg.Payload)
-	if err != nil {
-		s.logger.Error("[Server] Failed to decrypt ACK message: %v", err)
-		return
-	}
-
+func (s *Server) handleMessageAck(msg *transport.Message, payload []byte) {
 	// 解析ACK内容
 	var ackMsg struct {
 		Type      string `json:"type"`
@@ -761,14 +893,14 @@ func (s *Server) handleMessageAck(msg *transport.Message) {
 		Timestamp int64  `json:"timestamp"`
 	}
 
-	if err := json.Unmarshal(decrypted, &ackMsg); err != nil {
+	if err := json.Unmarshal(payload, &ackMsg); err != nil {
 		s.logger.Error("[Server] Failed to unmarshal ACK message: %v", err)
 		return
 	}
 
-	// 验证成员
-	if !s.channelManager.HasMember(ackMsg.MemberID) {
-		s.logger.Warn("[Server] ACK from unknown member: %s", ackMsg.MemberID)
+	// 验证成员（只能为自己确认）
+	if ackMsg.MemberID != msg.SenderID || !s.channelManager.HasMember(ackMsg.MemberID) {
+		s.logger.Warn("[Server] ACK for %s from member: %s", ackMsg.MemberID, msg.SenderID)
 		return
 	}
 
@@ -848,6 +980,44 @@ func (s *Server) GetSpamDetectorStats() map[string]interface{} {
 	}
 }
 
+// GetStorageUsage 获取频道存储用量与配额
+func (s *Server) GetStorageUsage() (*StorageUsage, error) {
+	return s.storageManager.GetUsage()
+}
+
+// GetStorageStats 获取存储回收统计
+func (s *Server) GetStorageStats() map[string]interface{} {
+	stats := s.storageManager.GetStats()
+	return map[string]interface{}{
+		"total_runs":      stats.TotalRuns,
+		"files_deleted":   stats.FilesDeleted,
+		"bytes_released":  stats.BytesReleased,
+		"uploads_expired": stats.UploadsExpired,
+		"quota_rejected":  stats.QuotaRejected,
+		"last_run":        stats.LastRun,
+	}
+}
+
+// CollectGarbage 立即执行一次存储回收
+func (s *Server) CollectGarbage() (*GCReport, error) {
+	return s.storageManager.Collect()
+}
+
+// DeleteFile 删除文件并通知成员（操作记入审计日志）
+func (s *Server) DeleteFile(fileID, reason, operatorID string) error {
+	return s.storageManager.DeleteFile(fileID, reason, operatorID)
+}
+
+// GetSwarmStats 获取对等分发统计
+func (s *Server) GetSwarmStats() map[string]interface{} {
+	stats := s.swarmManager.GetStats()
+	return map[string]interface{}{
+		"advertisements": stats.Advertisements,
+		"peer_assigned":  stats.PeerAssigned,
+		"server_chunks":  stats.ServerChunks,
+	}
+}
+
 // AddBlacklistWord 添加黑名单关键词
 func (s *Server) AddBlacklistWord(word string) {
 	s.spamDetector.AddBlacklistWord(word)
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"crosswire/internal/events"
+	"crosswire/internal/models"
+)
+
+// 存储回收默认值
+const (
+	DefaultGCInterval     = time.Hour
+	DefaultStaleUploadAge = 24 * time.Hour
+)
+
+// 配额错误（可用 errors.Is 判断，file.rejected 应答中的 reason 取其文本）
+var (
+	ErrFileTooLarge         = errors.New("file too large")
+	ErrMemberQuotaExceeded  = errors.New("member storage quota exceeded")
+	ErrChannelQuotaExceeded = errors.New("channel storage quota exceeded")
+)
+
+// RetentionPolicy 文件保留策略（零值表示永久保留）
+type RetentionPolicy struct {
+	MaxAge         time.Duration            // 默认保留时长（0 为永久）
+	ByType         map[string]time.Duration // 按 MIME 类型覆盖：精确匹配（"application/vnd.tcpdump.pcap"）或以 "/" 结尾的前缀（"image/"）；0 为永久
+	KeepPinned     bool                     // 置顶消息中的文件不过期
+	StaleUploadAge time.Duration            // 未完成上传的保留时长（默认 24 小时）
+}
+
+// QuotaError 上传超出配额
+type QuotaError struct {
+	Err   error
+	Limit int64 // 配额上限（字节）
+	Used  int64 // 已用量（字节，单文件上限时为 0）
+	Size  int64 // 本次上传大小
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %d + %d > %d bytes", e.Err, e.Used, e.Size, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// StorageManager 文件存储管理器
+// 职责：上传配额（单文件/每成员/每频道）、按保留策略设置过期时间、
+// 后台回收过期文件与未完成上传并整理数据库，每次删除写入审计日志。
+// 参考: docs/FEATURES.md - 5.4 存储配额与保留策略
+type StorageManager struct {
+	server *Server
+
+	// 回收互斥（定时回收与手动回收不并发）
+	gcMutex sync.Mutex
+
+	// 统计
+	stats StorageStats
+}
+
+// StorageStats 存储回收统计
+type StorageStats struct {
+	TotalRuns      uint64
+	FilesDeleted   uint64
+	BytesReleased  uint64
+	UploadsExpired uint64
+	QuotaRejected  uint64
+	LastRun        time.Time
+	mutex          sync.RWMutex
+}
+
+// GCReport 一次回收的结果
+type GCReport struct {
+	ExpiredFiles  int   `json:"expired_files"`
+	StaleUploads  int   `json:"stale_uploads"`
+	SkippedPinned int   `json:"skipped_pinned"`
+	BytesReleased int64 `json:"bytes_released"`
+	Vacuumed      bool  `json:"vacuumed"`
+}
+
+// StorageUsage 频道存储用量
+type StorageUsage struct {
+	ChannelBytes int64 `json:"channel_bytes"`
+	ChannelLimit int64 `json:"channel_limit,omitempty"`
+	MemberLimit  int64 `json:"member_limit,omitempty"`
+	FileLimit    int64 `json:"file_limit,omitempty"`
+}
+
+// NewStorageManager 创建存储管理器
+func NewStorageManager(server *Server) *StorageManager {
+	return &StorageManager{
+		server: server,
+	}
+}
+
+// Run 定期回收存储
+func (sm *StorageManager) Run() {
+	defer sm.server.wg.Done()
+
+	interval := sm.server.config.GCInterval
+	if interval <= 0 {
+		interval = DefaultGCInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sm.server.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sm.Collect(); err != nil {
+				sm.server.logger.Error("[StorageManager] Garbage collection failed: %v", err)
+			}
+		}
+	}
+}
+
+// CheckQuota 检查新上传是否超出配额（登记中的上传同样计入用量）
+// 超出配额时返回 *QuotaError
+func (sm *StorageManager) CheckQuota(file *models.File) error {
+	err := sm.checkQuota(file)
+	var quotaErr *QuotaError
+	if errors.As(err, &quotaErr) {
+		sm.stats.mutex.Lock()
+		sm.stats.QuotaRejected++
+		sm.stats.mutex.Unlock()
+	}
+	return err
+}
+
+// checkQuota 校验大小与分块信息后，依次检查单文件、成员与频道配额
+// 声明的大小是配额与写入范围的依据，分块信息不一致的元数据直接拒绝
+func (sm *StorageManager) checkQuota(file *models.File) error {
+	if err := file.ValidateLayout(); err != nil {
+		return err
+	}
+
+	config := sm.server.config
+
+	if config.MaxFileSize > 0 && file.Size > config.MaxFileSize {
+		return &QuotaError{Err: ErrFileTooLarge, Limit: config.MaxFileSize, Size: file.Size}
+	}
+
+	if config.MaxMemberStorage > 0 {
+		used, err := sm.server.fileRepo.GetMemberStorageUsage(file.ChannelID, file.SenderID)
+		if err != nil {
+			return fmt.Errorf("failed to query storage usage: %w", err)
+		}
+		if used+file.Size > config.MaxMemberStorage {
+			return &QuotaError{Err: ErrMemberQuotaExceeded, Limit: config.MaxMemberStorage, Used: used, Size: file.Size}
+		}
+	}
+
+	if config.MaxChannelStorage > 0 {
+		used, err := sm.server.fileRepo.GetStorageUsage(file.ChannelID)
+		if err != nil {
+			return fmt.Errorf("failed to query storage usage: %w", err)
+		}
+		if used+file.Size > config.MaxChannelStorage {
+			return &QuotaError{Err: ErrChannelQuotaExceeded, Limit: config.MaxChannelStorage, Used: used, Size: file.Size}
+		}
+	}
+
+	return nil
+}
+
+// ExpiryFor 按保留策略计算文件的过期时间（零值表示永久保留）
+func (sm *StorageManager) ExpiryFor(file *models.File) time.Time {
+	policy := sm.server.config.FileRetention
+	maxAge := policy.MaxAge
+
+	// 精确类型优先于前缀，较长前缀优先
+	mimeType := strings.ToLower(file.MimeType)
+	if age, ok := policy.ByType[mimeType]; ok {
+		maxAge = age
+	} else {
+		matched := ""
+		for prefix, age := range policy.ByType {
+			if strings.HasSuffix(prefix, "/") && strings.HasPrefix(mimeType, prefix) && len(prefix) > len(matched) {
+				matched, maxAge = prefix, age
+			}
+		}
+	}
+
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	uploadedAt := file.UploadedAt
+	if uploadedAt.IsZero() {
+		uploadedAt = time.Now()
+	}
+	return uploadedAt.Add(maxAge)
+}
+
+// Collect 执行一次回收：删除过期文件与超时未完成的上传，有删除时整理数据库
+func (sm *StorageManager) Collect() (*GCReport, error) {
+	sm.gcMutex.Lock()
+	defer sm.gcMutex.Unlock()
+
+	report := &GCReport{}
+	policy := sm.server.config.FileRetention
+
+	// 1. 过期文件（置顶消息中的文件按策略保留）
+	expired, err := sm.server.fileRepo.GetExpiredFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired files: %w", err)
+	}
+	for _, file := range expired {
+		if policy.KeepPinned && sm.isPinned(file) {
+			report.SkippedPinned++
+			continue
+		}
+		if err := sm.deleteFile(file, "expired", "system"); err != nil {
+			sm.server.logger.Error("[StorageManager] Failed to delete expired file %s: %v", file.ID, err)
+			continue
+		}
+		report.ExpiredFiles++
+		report.BytesReleased += file.Size
+	}
+
+	// 2. 超时未完成的上传（丢弃临时文件与分块记录）
+	staleAge := policy.StaleUploadAge
+	if staleAge <= 0 {
+		staleAge = DefaultStaleUploadAge
+	}
+	stale, err := sm.server.fileRepo.GetStaleUploads(time.Now().Add(-staleAge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale uploads: %w", err)
+	}
+	for _, file := range stale {
+		if err := sm.deleteFile(file, "stale_upload", "system"); err != nil {
+			sm.server.logger.Error("[StorageManager] Failed to delete stale upload %s: %v", file.ID, err)
+			continue
+		}
+		report.StaleUploads++
+	}
+
+	// 3. 有删除时整理数据库，回收空闲页
+	if report.ExpiredFiles+report.StaleUploads > 0 {
+		if err := sm.server.db.Vacuum(); err != nil {
+			sm.server.logger.Warn("[StorageManager] Failed to vacuum database: %v", err)
+		} else {
+			report.Vacuumed = true
+		}
+	}
+
+	sm.stats.mutex.Lock()
+	sm.stats.TotalRuns++
+	sm.stats.FilesDeleted += uint64(report.ExpiredFiles)
+	sm.stats.UploadsExpired += uint64(report.StaleUploads)
+	sm.stats.BytesReleased += uint64(report.BytesReleased)
+	sm.stats.LastRun = time.Now()
+	sm.stats.mutex.Unlock()
+
+	if report.ExpiredFiles+report.StaleUploads > 0 {
+		sm.server.logger.Info("[StorageManager] GC removed %d expired files (%d bytes) and %d stale uploads",
+			report.ExpiredFiles, report.BytesReleased, report.StaleUploads)
+	}
+	return report, nil
+}
+
+// DeleteFile 删除文件（内容、分块与记录），写入审计日志并通知成员
+func (sm *StorageManager) DeleteFile(fileID, reason, operatorID string) error {
+	file, err := sm.server.fileRepo.GetByID(fileID)
+	if err != nil {
+		return fmt.Errorf("file not found: %s", fileID)
+	}
+	return sm.deleteFile(file, reason, operatorID)
+}
+
+// deleteFile 删除文件并写入审计日志
+func (sm *StorageManager) deleteFile(file *models.File, reason, operatorID string) error {
+	// 1. 释放内容（内容存储中的 blob 仅在无其他引用时删除；未完成的上传丢弃临时文件）
+	if file.UploadStatus != models.UploadStatusCompleted {
+		if err := sm.server.db.GetBlobStore().Abort(file.ID); err != nil {
+			sm.server.logger.Warn("[StorageManager] Failed to discard upload %s: %v", file.ID, err)
+		}
+	}
+	if err := sm.server.fileRepo.ReleaseContent(file); err != nil {
+		return fmt.Errorf("failed to release content: %w", err)
+	}
+
+	// 2. 删除记录（分块记录级联删除）
+	if err := sm.server.fileRepo.Delete(file.ID); err != nil {
+		return fmt.Errorf("failed to delete file record: %w", err)
+	}
+
+	// 3. 审计日志
+	audit := &models.AuditLog{
+		ChannelID:  sm.server.config.ChannelID,
+		Type:       "file_deleted",
+		OperatorID: operatorID,
+		TargetID:   file.ID,
+		Reason:     reason,
+		Details: models.JSONField{
+			"filename":    file.Filename,
+			"size":        file.Size,
+			"mime_type":   file.MimeType,
+			"sha256":      file.SHA256,
+			"sender_id":   file.SenderID,
+			"uploaded_at": file.UploadedAt,
+			"status":      file.UploadStatus,
+		},
+	}
+	if !file.ExpiresAt.IsZero() {
+		audit.Details["expires_at"] = file.ExpiresAt
+	}
+	if err := sm.server.auditRepo.Log(audit); err != nil {
+		sm.server.logger.Error("[StorageManager] Failed to write audit log for %s: %v", file.ID, err)
+	}
+
+	sm.server.logger.Info("[StorageManager] Deleted file %s (%s): %s", file.Filename, file.ID, reason)
+
+	// 4. 已广播过的文件通知成员，未完成的上传成员无感知
+	if file.UploadStatus == models.UploadStatusCompleted {
+		sm.broadcastDeletion(file, reason, operatorID)
+	}
+	sm.server.eventBus.Publish(events.EventFileDeleted, events.FileEvent{
+		File:       file,
+		ChannelID:  sm.server.config.ChannelID,
+		UploaderID: file.SenderID,
+	})
+	return nil
+}
+
+// broadcastDeletion 发布文件删除系统消息（持久化，离线成员可通过同步获知）
+func (sm *StorageManager) broadcastDeletion(file *models.File, reason, operatorID string) {
+	systemMsg := &models.Message{
+		ID:        generateMessageID(),
+		ChannelID: sm.server.config.ChannelID,
+		SenderID:  "system",
+		Type:      models.MessageTypeSystem,
+		Timestamp: time.Now(),
+	}
+	systemMsg.Content = models.MessageContent{
+		"event":     "file_deleted",
+		"actor_id":  operatorID,
+		"target_id": file.ID,
+		"reason":    reason,
+		"extra": map[string]interface{}{
+			"file_id":  file.ID,
+			"filename": file.Filename,
+			"message":  fmt.Sprintf("File %s was removed (%s)", file.Filename, reason),
+		},
+	}
+
+	if err := sm.server.messageRepo.Create(systemMsg); err != nil {
+		sm.server.logger.Error("[StorageManager] Failed to persist deletion message: %v", err)
+	}
+	if err := sm.server.broadcastManager.Broadcast(systemMsg); err != nil {
+		sm.server.logger.Error("[StorageManager] Failed to broadcast deletion of %s: %v", file.ID, err)
+	}
+}
+
+// isPinned 文件消息是否被置顶
+func (sm *StorageManager) isPinned(file *models.File) bool {
+	msg, err := sm.server.messageRepo.GetByID(file.MessageID)
+	return err == nil && msg != nil && msg.Pinned
+}
+
+// GetUsage 获取频道存储用量与配额
+func (sm *StorageManager) GetUsage() (*StorageUsage, error) {
+	used, err := sm.server.fileRepo.GetStorageUsage(sm.server.config.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageUsage{
+		ChannelBytes: used,
+		ChannelLimit: sm.server.config.MaxChannelStorage,
+		MemberLimit:  sm.server.config.MaxMemberStorage,
+		FileLimit:    sm.server.config.MaxFileSize,
+	}, nil
+}
+
+// GetStats 获取回收统计
+func (sm *StorageManager) GetStats() StorageStats {
+	sm.stats.mutex.RLock()
+	defer sm.stats.mutex.RUnlock()
+
+	return StorageStats{
+		TotalRuns:      sm.stats.TotalRuns,
+		FilesDeleted:   sm.stats.FilesDeleted,
+		BytesReleased:  sm.stats.BytesReleased,
+		UploadsExpired: sm.stats.UploadsExpired,
+		QuotaRejected:  sm.stats.QuotaRejected,
+		LastRun:        sm.stats.LastRun,
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crosswire/internal/models"
+	"crosswire/internal/transport"
+)
+
+// 单次下载最多分派的持有者数量（其余分块由服务端发送）
+const maxSwarmPeers = 4
+
+// SwarmManager 对等分发协调器
+// 成员把下载完成的文件按 SHA-256 缓存在本地，并以 file.have 通告持有的内容与对等端点（peer_addr）；
+// 下载请求到来时，服务端把一部分分块分派给在线的持有者，以 file.peer_assign 告知请求方
+// 各持有者的端点与这些分块的 SHA-256（取自上传时校验过的分块记录），请求方直接向持有者拉取
+// 并逐块校验，其余分块仍由服务端发送。服务端只负责分派，不中继也不读取对端分块。
+// 参考: docs/PROTOCOL.md - 5.2.7 对等分发
+type SwarmManager struct {
+	server *Server
+
+	// sha256 -> memberID -> 通告时间
+	holders map[string]map[string]time.Time
+	// memberID -> 对等端点（host:port）
+	endpoints map[string]string
+	mutex     sync.RWMutex
+
+	// 统计
+	stats SwarmStats
+}
+
+// SwarmStats 对等分发统计
+type SwarmStats struct {
+	Advertisements uint64 // 收到的 file.have 通告数
+	PeerAssigned   uint64 // 分派给持有者的分块数
+	ServerChunks   uint64 // 服务端自行发送的分块数
+	mutex          sync.RWMutex
+}
+
+// swarmPeer file.peer_assign 中的一个持有者
+type swarmPeer struct {
+	MemberID string `json:"member_id"`
+	Addr     string `json:"addr"`
+	Chunks   []int  `json:"chunks"`
+}
+
+// NewSwarmManager 创建对等分发协调器
+func NewSwarmManager(server *Server) *SwarmManager {
+	return &SwarmManager{
+		server:    server,
+		holders:   make(map[string]map[string]time.Time),
+		endpoints: make(map[string]string),
+	}
+}
+
+// HandleHave 处理成员的缓存通告
+// full 为 true 时以本次列表替换该成员此前的全部通告（加入或重连时发送）；
+// 带 peer_addr 时更新该成员的对等端点，没有可用端点的成员不会被分派分块
+func (sw *SwarmManager) HandleHave(transportMsg *transport.Message, payload []byte) {
+	var req struct {
+		Hashes   []string `json:"hashes"`
+		Dropped  []string `json:"dropped"`
+		Full     bool     `json:"full"`
+		PeerAddr string   `json:"peer_addr"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		sw.server.logger.Error("[SwarmManager] Failed to unmarshal file.have: %v", err)
+		return
+	}
+
+	memberID := transportMsg.SenderID
+	if !sw.server.channelManager.HasMember(memberID) {
+		sw.server.logger.Warn("[SwarmManager] Non-member advertising files: %s", memberID)
+		return
+	}
+
+	now := time.Now()
+	sw.mutex.Lock()
+	if req.PeerAddr != "" {
+		if addr := resolvePeerAddr(req.PeerAddr, transportMsg.SenderAddr); addr != "" {
+			sw.endpoints[memberID] = addr
+		} else {
+			delete(sw.endpoints, memberID)
+		}
+	}
+	if req.Full {
+		for hash := range sw.holders {
+			sw.removeHolder(hash, memberID)
+		}
+	}
+	for _, hash := range req.Dropped {
+		sw.removeHolder(hash, memberID)
+	}
+	for _, hash := range req.Hashes {
+		if len(hash) != 64 {
+			continue
+		}
+		if sw.holders[hash] == nil {
+			sw.holders[hash] = make(map[string]time.Time)
+		}
+		sw.holders[hash][memberID] = now
+	}
+	sw.mutex.Unlock()
+
+	sw.stats.mutex.Lock()
+	sw.stats.Advertisements++
+	sw.stats.mutex.Unlock()
+
+	sw.server.logger.Debug("[SwarmManager] %s holds %d file(s) (full=%v, dropped=%d)",
+		memberID, len(req.Hashes), req.Full, len(req.Dropped))
+}
+
+// removeHolder 移除一条持有记录（调用方持有 mutex）
+func (sw *SwarmManager) removeHolder(hash, memberID string) {
+	members := sw.holders[hash]
+	if members == nil {
+		return
+	}
+	delete(members, memberID)
+	if len(members) == 0 {
+		delete(sw.holders, hash)
+	}
+}
+
+// resolvePeerAddr 规范化成员通告的对等端点
+// 监听地址未指定主机（如 ":40123" 或 "0.0.0.0:40123"）时取传输层观察到的发送方 IP，无法确定时返回空
+func resolvePeerAddr(advertised, senderAddr string) string {
+	host, port, err := net.SplitHostPort(advertised)
+	if err != nil {
+		return ""
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		observed, _, err := net.SplitHostPort(senderAddr)
+		if err != nil || net.ParseIP(observed) == nil {
+			return ""
+		}
+		host = observed
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Holders 返回持有指定内容、且有对等端点的在线成员及其端点（按最近通告排序，不含 exclude）
+func (sw *SwarmManager) Holders(hash, exclude string) []swarmPeer {
+	sw.mutex.RLock()
+	type holder struct {
+		id   string
+		addr string
+		at   time.Time
+	}
+	candidates := make([]holder, 0, len(sw.holders[hash]))
+	for id, at := range sw.holders[hash] {
+		if addr := sw.endpoints[id]; id != exclude && addr != "" {
+			candidates = append(candidates, holder{id, addr, at})
+		}
+	}
+	sw.mutex.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].at.Equal(candidates[j].at) {
+			return candidates[i].at.After(candidates[j].at)
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	result := make([]swarmPeer, 0, len(candidates))
+	for _, c := range candidates {
+		member := sw.server.channelManager.GetMemberByID(c.id)
+		if member == nil || member.Status == models.StatusOffline {
+			continue
+		}
+		result = append(result, swarmPeer{MemberID: c.id, Addr: c.addr})
+	}
+	return result
+}
+
+// Assign 为一次下载分派分块：服务端与各持有者轮流认领，返回服务端自行发送的分块
+// 只分派有分块哈希的分块；分派结果以 file.peer_assign 通知请求方，由其直接向持有者拉取
+func (sw *SwarmManager) Assign(file *models.File, requesterID string, chunks []int) []int {
+	holders := sw.Holders(file.SHA256, requesterID)
+	if len(holders) > maxSwarmPeers {
+		holders = holders[:maxSwarmPeers]
+	}
+	if len(holders) == 0 {
+		return chunks
+	}
+
+	hashes, err := sw.chunkHashes(file)
+	if err != nil {
+		sw.server.logger.Warn("[SwarmManager] Failed to load chunk hashes of %s: %v", file.ID, err)
+		return chunks
+	}
+
+	own := make([]int, 0, len(chunks)/(len(holders)+1)+1)
+	assigned := make(map[int]string)
+	slot := 0
+	for _, index := range chunks {
+		hash, ok := hashes[index]
+		if !ok {
+			own = append(own, index)
+			continue
+		}
+		if slot%(len(holders)+1) == 0 {
+			own = append(own, index)
+		} else {
+			peer := &holders[slot%(len(holders)+1)-1]
+			peer.Chunks = append(peer.Chunks, index)
+			assigned[index] = hash
+		}
+		slot++
+	}
+	if len(assigned) == 0 {
+		return chunks
+	}
+
+	peers := make([]swarmPeer, 0, len(holders))
+	for _, peer := range holders {
+		if len(peer.Chunks) > 0 {
+			peers = append(peers, peer)
+		}
+	}
+	if err := sw.sendAssignment(requesterID, file, peers, assigned); err != nil {
+		// 无法通知请求方时全部由服务端发送
+		sw.server.logger.Warn("[SwarmManager] Failed to send peer assignment to %s: %v", requesterID, err)
+		return chunks
+	}
+	sort.Ints(own)
+
+	sw.stats.mutex.Lock()
+	sw.stats.PeerAssigned += uint64(len(assigned))
+	sw.stats.ServerChunks += uint64(len(own))
+	sw.stats.mutex.Unlock()
+
+	sw.server.logger.Info("[SwarmManager] File %s -> %s: %d chunk(s) from server, %d from %d peer(s)",
+		file.ID, requesterID, len(own), len(assigned), len(peers))
+	return own
+}
+
+// chunkHashes 读取文件各分块的 SHA-256（上传时已与分块数据比对）
+func (sw *SwarmManager) chunkHashes(file *models.File) (map[int]string, error) {
+	chunks, err := sw.server.fileRepo.GetChunksByFileID(file.ID)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[int]string, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Checksum) == sha256.Size*2 && chunk.ChunkIndex >= 0 && chunk.ChunkIndex < file.TotalChunks {
+			hashes[chunk.ChunkIndex] = strings.ToLower(chunk.Checksum)
+		}
+	}
+	return hashes, nil
+}
+
+// sendAssignment 通知请求方向持有者拉取分块
+func (sw *SwarmManager) sendAssignment(requesterID string, file *models.File, peers []swarmPeer, hashes map[int]string) error {
+	assignment := map[string]interface{}{
+		"type":         "file.peer_assign",
+		"file_id":      file.ID,
+		"sha256":       file.SHA256,
+		"requester_id": requesterID,
+		"chunk_size":   file.ChunkSize,
+		"total_chunks": file.TotalChunks,
+		"peers":        peers,
+		"chunk_hashes": hashes,
+		"timestamp":    time.Now().Unix(),
+	}
+
+	data, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+	encrypted, err := sw.server.crypto.EncryptMessage(data)
+	if err != nil {
+		return err
+	}
+	msg := &transport.Message{
+		Type:      transport.MessageTypeControl,
+		SenderID:  "server",
+		Payload:   encrypted,
+		Timestamp: time.Now(),
+	}
+	return sw.server.transport.SendMessage(msg)
+}
+
+// GetStats 获取统计信息
+func (sw *SwarmManager) GetStats() SwarmStats {
+	sw.stats.mutex.RLock()
+	defer sw.stats.mutex.RUnlock()
+
+	return SwarmStats{
+		Advertisements: sw.stats.Advertisements,
+		PeerAssigned:   sw.stats.PeerAssigned,
+		ServerChunks:   sw.stats.ServerChunks,
+	}
+}
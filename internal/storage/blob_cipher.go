@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"crosswire/internal/crypto"
+)
+
+// 加密内容格式: 16 字节头（魔数 "CWBLOB1\n" + 明文大小，大端 uint64），
+// 之后是按 64KiB 明文分段加密的密文段（nonce + 密文 + 认证标签）。
+// 每段的附加数据为 明文大小 || 段序号，防止段被截断、重排或在文件间替换。
+// 分段使随机读取（缓存分块回传、文件包按偏移解出条目）只需解密所在的段。
+const (
+	blobMagic       = "CWBLOB1\n"
+	blobHeaderSize  = 16
+	blobSegmentSize = 64 * 1024
+)
+
+// sealedBlobSize 返回明文大小对应的加密文件大小
+func sealedBlobSize(size int64) int64 {
+	segments := (size + blobSegmentSize - 1) / blobSegmentSize
+	return blobHeaderSize + size + segments*crypto.AESOverhead
+}
+
+// blobSegmentAD 返回分段的附加认证数据
+func blobSegmentAD(size, index int64) []byte {
+	ad := make([]byte, 16)
+	binary.BigEndian.PutUint64(ad[:8], uint64(size))
+	binary.BigEndian.PutUint64(ad[8:], uint64(index))
+	return ad
+}
+
+// readBlobHeader 读取加密头，返回明文大小（不是加密内容时 ok 为 false）
+// 除魔数外还核对文件长度，避免恰好以魔数开头的明文内容被误判。
+func readBlobHeader(f *os.File) (size int64, ok bool, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if info.Size() < blobHeaderSize {
+		return 0, false, nil
+	}
+	header := make([]byte, blobHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return 0, false, err
+	}
+	if !bytes.Equal(header[:8], []byte(blobMagic)) {
+		return 0, false, nil
+	}
+	size = int64(binary.BigEndian.Uint64(header[8:]))
+	if size < 0 || sealedBlobSize(size) != info.Size() {
+		return 0, false, nil
+	}
+	return size, true, nil
+}
+
+// sealFile 将明文文件加密写入 dst（先写入 tmp 目录再替换，调用方持有 s.mu）
+func (s *BlobStore) sealFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open blob: %w", err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	tmp := filepath.Join(s.root, "tmp", filepath.Base(dst)+".sealing")
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create sealed blob: %w", err)
+	}
+	if err := writeSealedBlob(out, in, size, s.key); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to encrypt blob: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to sync sealed blob: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to move blob: %w", err)
+	}
+	return nil
+}
+
+// writeSealedBlob 写入加密头与各密文段
+func writeSealedBlob(w io.Writer, r io.Reader, size int64, key []byte) error {
+	header := make([]byte, blobHeaderSize)
+	copy(header, blobMagic)
+	binary.BigEndian.PutUint64(header[8:], uint64(size))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	cm, _ := crypto.NewManager()
+	buffer := make([]byte, blobSegmentSize)
+	for index, remaining := int64(0), size; remaining > 0; index++ {
+		n := int64(blobSegmentSize)
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(r, buffer[:n]); err != nil {
+			return err
+		}
+		segment, err := cm.AESEncryptWithAD(buffer[:n], key, blobSegmentAD(size, index))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// sealAll 加密启用加密前保存的明文内容（已加密的跳过，可重复执行），返回加密的数量
+func (s *BlobStore) sealAll() (int, error) {
+	if s.key == nil {
+		return 0, nil
+	}
+	hashes, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	sealed := 0
+	for _, hash := range hashes {
+		err := func() error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			path := s.Path(hash)
+			f, err := os.Open(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			_, ok, err := readBlobHeader(f)
+			f.Close()
+			if err != nil || ok {
+				return err
+			}
+			if err := s.sealFile(path, path); err != nil {
+				return err
+			}
+			sealed++
+			return nil
+		}()
+		if err != nil {
+			return sealed, fmt.Errorf("failed to encrypt blob %s: %w", hash, err)
+		}
+	}
+	return sealed, nil
+}
+
+// openSealedBlob 包装已打开的内容文件：加密内容返回解密读取器，明文内容（启用加密前保存）原样返回
+func openSealedBlob(f *os.File, key []byte) (BlobReader, error) {
+	size, ok, err := readBlobHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f, nil
+	}
+	if key == nil {
+		return nil, ErrLocked
+	}
+	cm, _ := crypto.NewManager()
+	return &sealedBlob{f: f, key: key, cm: cm, size: size, segment: -1}, nil
+}
+
+// sealedBlob 加密内容的读取器（缓存最近解密的一段，顺序读取时每段只解密一次）
+type sealedBlob struct {
+	f    *os.File
+	key  []byte
+	cm   *crypto.Manager
+	size int64
+
+	mu      sync.Mutex
+	offset  int64  // Read/Seek 的当前位置
+	segment int64  // 已缓存的段序号（-1 表示无）
+	plain   []byte // 已缓存段的明文
+}
+
+// ReadAt 从明文偏移 off 处读取
+func (b *sealedBlob) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.readAt(p, off)
+}
+
+// Read 顺序读取
+func (b *sealedBlob) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n, err := b.readAt(p, b.offset)
+	b.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek 设置顺序读取位置
+func (b *sealedBlob) Seek(offset int64, whence int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.offset = offset
+	return offset, nil
+}
+
+// Close 关闭底层文件
+func (b *sealedBlob) Close() error {
+	return b.f.Close()
+}
+
+// readAt 读取明文（调用方持有 b.mu）
+func (b *sealedBlob) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		if off >= b.size {
+			return n, io.EOF
+		}
+		index := off / blobSegmentSize
+		if err := b.loadSegment(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], b.plain[off-index*blobSegmentSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// loadSegment 读取并解密第 index 段
+func (b *sealedBlob) loadSegment(index int64) error {
+	if b.segment == index {
+		return nil
+	}
+	length := int64(blobSegmentSize)
+	if rest := b.size - index*blobSegmentSize; rest < length {
+		length = rest
+	}
+	segment := make([]byte, length+crypto.AESOverhead)
+	offset := blobHeaderSize + index*(blobSegmentSize+crypto.AESOverhead)
+	if _, err := b.f.ReadAt(segment, offset); err != nil {
+		return fmt.Errorf("failed to read blob segment %d: %w", index, err)
+	}
+	plain, err := b.cm.AESDecryptWithAD(segment, b.key, blobSegmentAD(b.size, index))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt blob segment %d: %w", index, err)
+	}
+	b.segment, b.plain = index, plain
+	return nil
+}
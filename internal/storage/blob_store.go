@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrBlobHashMismatch 上传内容与声明的 SHA-256 不一致
+var ErrBlobHashMismatch = errors.New("blob sha256 mismatch")
+
+// BlobStore 按 SHA-256 寻址的文件内容存储
+// 布局: <root>/<hash[:2]>/<hash>；上传中的内容写入 <root>/tmp/<uploadID>.part，
+// 分块按偏移写入（稀疏文件），因此不依赖到达顺序。相同内容只保存一份。
+// 启用静态加密时，提交后的内容以分段 AES-256-GCM 加密保存（见 blob_cipher.go），
+// 寻址哈希仍为明文的 SHA-256；上传中的临时文件在提交前为明文。
+type BlobStore struct {
+	root string
+	key  []byte     // 数据密钥（nil 表示明文存储）
+	mu   sync.Mutex // 串行化提交与删除，避免去重判断与 rename 交错
+}
+
+// BlobReader 内容读取接口（支持流式读取与按偏移随机读取）
+type BlobReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// NewBlobStore 创建内容存储（key 为 nil 时不加密）
+func NewBlobStore(root string, key []byte) (*BlobStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &BlobStore{root: root, key: key}, nil
+}
+
+// Encrypted 内容是否加密保存（加密后存储路径下的文件不能直接读取，须经 Open）
+func (s *BlobStore) Encrypted() bool {
+	return s.key != nil
+}
+
+// Root 返回存储根目录
+func (s *BlobStore) Root() string {
+	return s.root
+}
+
+// Path 返回内容哈希对应的存储路径
+func (s *BlobStore) Path(hash string) string {
+	return filepath.Join(s.root, hash[:2], hash)
+}
+
+// Has 检查内容是否已存在
+func (s *BlobStore) Has(hash string) bool {
+	if !validBlobHash(hash) {
+		return false
+	}
+	_, err := os.Stat(s.Path(hash))
+	return err == nil
+}
+
+// Owns 判断路径是否位于本存储内（区分客户端下载到用户目录的文件）
+func (s *BlobStore) Owns(path string) bool {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return false
+	}
+	hash := filepath.Base(rel)
+	return validBlobHash(hash) && rel == filepath.Join(hash[:2], hash)
+}
+
+// WriteAt 将数据写入上传临时文件的指定偏移
+func (s *BlobStore) WriteAt(uploadID string, offset int64, data []byte) error {
+	path, err := s.tempPath(uploadID)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open upload file: %w", err)
+	}
+	if _, err := f.WriteAt(data, offset); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write upload file: %w", err)
+	}
+	return f.Close()
+}
+
+// Create 创建（或截断）上传临时文件，用于在本地生成的内容（如目录打包），之后同样以 Commit 提交
+func (s *BlobStore) Create(uploadID string) (*os.File, error) {
+	path, err := s.tempPath(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	return f, nil
+}
+
+// Commit 校验上传临时文件并移入内容存储，返回内容哈希
+// size 为声明的文件大小（截断多余的尾部），expected 为声明的 SHA-256（为空则不校验）。
+// 校验失败时临时文件被删除；内容已存在时直接复用。
+func (s *BlobStore) Commit(uploadID string, size int64, expected string) (string, error) {
+	path, err := s.tempPath(uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload file: %w", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to truncate upload file: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to hash upload file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to sync upload file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if expected != "" && !strings.EqualFold(hash, expected) {
+		os.Remove(path)
+		return "", fmt.Errorf("%w: expect=%s actual=%s", ErrBlobHashMismatch, expected, hash)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dst := s.Path(hash)
+	if _, err := os.Stat(dst); err == nil {
+		// 去重：相同内容已存在
+		os.Remove(path)
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if s.key != nil {
+		if err := s.sealFile(path, dst); err != nil {
+			return "", err
+		}
+		os.Remove(path)
+		return hash, nil
+	}
+	if err := os.Rename(path, dst); err != nil {
+		return "", fmt.Errorf("failed to move blob: %w", err)
+	}
+	return hash, nil
+}
+
+// OpenTemp 打开上传临时文件用于读取（只下载了部分分块的文件包按偏移解出条目）
+func (s *BlobStore) OpenTemp(uploadID string) (*os.File, error) {
+	path, err := s.tempPath(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Abort 丢弃上传临时文件
+func (s *BlobStore) Abort(uploadID string) error {
+	path, err := s.tempPath(uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Open 打开内容用于读取（加密内容在读取时逐段解密）
+func (s *BlobStore) Open(hash string) (BlobReader, error) {
+	if !validBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %q", hash)
+	}
+	f, err := os.Open(s.Path(hash))
+	if err != nil {
+		return nil, err
+	}
+	r, err := openSealedBlob(f, s.key)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// List 列出已存储内容的哈希
+func (s *BlobStore) List() ([]string, error) {
+	dirs, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob directory: %w", err)
+	}
+	var hashes []string
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.root, dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob directory: %w", err)
+		}
+		for _, entry := range entries {
+			if hash := entry.Name(); !entry.IsDir() && validBlobHash(hash) && hash[:2] == dir.Name() {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// Remove 删除内容（调用方负责确认已无引用）
+func (s *BlobStore) Remove(hash string) error {
+	if !validBlobHash(hash) {
+		return fmt.Errorf("invalid blob hash: %q", hash)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.Path(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// tempPath 返回上传临时文件路径（uploadID 来自客户端，禁止路径分隔符）
+func (s *BlobStore) tempPath(uploadID string) (string, error) {
+	if uploadID == "" || uploadID == "." || uploadID == ".." ||
+		strings.ContainsAny(uploadID, `/\`) || filepath.Base(uploadID) != uploadID {
+		return "", fmt.Errorf("invalid upload id: %q", uploadID)
+	}
+	return filepath.Join(s.root, "tmp", uploadID+".part"), nil
+}
+
+// validBlobHash 检查是否为小写十六进制 SHA-256
+func validBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -129,7 +129,7 @@ func (r *ChannelRepository) GetPinnedMessagesWithContent(channelID string) ([]*s
 }, error) {
 	type pinnedWith struct {
 		models.PinnedMessage
-		ContentText    string `json:"content_text"`
+		ContentText    string `gorm:"serializer:encrypted" json:"content_text"`
 		SenderID       string `json:"sender_id"`
 		SenderNickname string `json:"sender_nickname"`
 	}
@@ -206,11 +206,13 @@ func (r *ChannelRepository) GetChannelConfig(channelID string) (map[string]inter
 }
 
 // RotateEncryptionKey 轮换加密密钥（仅更新版本与新密钥，调用方负责在内存中同步）
+// 密钥列在启用静态加密时加密保存，须以结构体更新
 func (r *ChannelRepository) RotateEncryptionKey(channelID string, newKey []byte, newVersion int) error {
 	return r.db.GetChannelDB().Model(&models.Channel{}).
 		Where("id = ?", channelID).
-		Updates(map[string]interface{}{
-			"encryption_key": newKey,
-			"key_version":    newVersion,
+		Select("encryption_key", "key_version").
+		Updates(&models.Channel{
+			EncryptionKey: newKey,
+			KeyVersion:    newVersion,
 		}).Error
 }
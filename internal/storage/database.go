@@ -6,6 +6,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"crosswire/internal/models"
@@ -16,23 +19,58 @@ import (
 )
 
 // Database 数据库管理器
+// 用户库与缓存库全局唯一；频道库按频道ID维护在连接池中，可同时打开多个。
+// ForChannel 返回绑定到指定频道的视图，由其创建的仓库始终访问该频道的库与文件内容存储，
+// 因此同一进程可以既作为一个频道的服务端，又作为另一个频道的客户端。
+// 未绑定的 Database 访问默认频道（最近一次 OpenChannelDB 的频道，未调用时为第一个打开的频道）。
+// 以口令打开时启用静态加密：敏感列（标签 serializer:encrypted）与文件内容以口令派生的数据密钥加密保存。
 type Database struct {
-	channelDB *gorm.DB // 频道数据库
-	userDB    *gorm.DB // 用户数据库
-	cacheDB   *gorm.DB // 缓存数据库
-	dataDir   string   // 数据目录
+	userDB   *gorm.DB       // 用户数据库
+	cacheDB  *gorm.DB       // 缓存数据库
+	dataDir  string         // 数据目录
+	key      []byte         // 静态加密数据密钥（nil 表示明文存储）
+	channels *channelPool   // 频道库连接池（与绑定视图共享）
+	bound    *channelHandle // 绑定的频道（未绑定时为 nil）
+}
+
+// channelHandle 一个已打开的频道库
+type channelHandle struct {
+	id    string
+	db    *gorm.DB   // 频道数据库
+	blobs *BlobStore // 频道文件内容存储
+}
+
+// channelPool 频道库连接池
+type channelPool struct {
+	mu        sync.RWMutex
+	handles   map[string]*channelHandle
+	defaultID string
 }
 
 // Config 数据库配置
 type Config struct {
 	DataDir   string
 	DebugMode bool
+	// Passphrase 静态加密口令：数据目录已加密时必须提供（否则返回 ErrLocked）；
+	// 尚未加密时提供口令即启用加密，已有的明文数据在打开时加密
+	Passphrase string
+}
+
+// channelSealedModels 频道库中含加密列的模型
+var channelSealedModels = []interface{}{
+	&models.Channel{},
+	&models.MemberSession{},
+	&models.Message{},
+	&models.File{},
+	&models.Challenge{},
+	&models.ChallengeSubmission{},
 }
 
 // NewDatabase 创建数据库实例
 func NewDatabase(config *Config) (*Database, error) {
 	db := &Database{
-		dataDir: config.DataDir,
+		dataDir:  config.DataDir,
+		channels: &channelPool{handles: make(map[string]*channelHandle)},
 	}
 
 	// 确保数据目录存在
@@ -40,6 +78,13 @@ func NewDatabase(config *Config) (*Database, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	// 解锁静态加密数据密钥
+	key, err := openKeyring(config.DataDir, config.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	db.key = key
+
 	// 配置 GORM
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
@@ -54,6 +99,7 @@ func NewDatabase(config *Config) (*Database, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open user database: %w", err)
 	}
+	userDB = withFieldKey(userDB, key)
 	db.userDB = userDB
 
 	// 配置 SQLite
@@ -67,6 +113,7 @@ func NewDatabase(config *Config) (*Database, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open cache database: %w", err)
 	}
+	cacheDB = withFieldKey(cacheDB, key)
 	db.cacheDB = cacheDB
 
 	// 配置 SQLite
@@ -74,61 +121,206 @@ func NewDatabase(config *Config) (*Database, error) {
 		return nil, err
 	}
 
-	// 自动迁移用户数据库
-	if err := db.migrateUserDB(); err != nil {
+	// 迁移用户数据库
+	if err := runMigrations(userDB, userDBPath, userMigrations); err != nil {
+		return nil, err
+	}
+	if err := sealDatabase(userDB, userDBPath, key, &models.UserProfile{}); err != nil {
 		return nil, err
 	}
 
-	// 自动迁移缓存数据库
-	if err := db.migrateCacheDB(); err != nil {
+	// 迁移缓存数据库
+	if err := runMigrations(cacheDB, cacheDBPath, cacheMigrations); err != nil {
+		return nil, err
+	}
+	if err := sealDatabase(cacheDB, cacheDBPath, key, &models.CacheEntry{}); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
 
-// OpenChannelDB 打开频道数据库
+// OpenChannelDB 打开频道数据库（已打开时复用），并设为未绑定访问的默认频道
 func (db *Database) OpenChannelDB(channelID string) error {
+	if _, err := db.openChannel(channelID); err != nil {
+		return err
+	}
+	db.channels.mu.Lock()
+	db.channels.defaultID = channelID
+	db.channels.mu.Unlock()
+	return nil
+}
+
+// ForChannel 打开频道数据库（已打开时复用），返回绑定到该频道的视图
+func (db *Database) ForChannel(channelID string) (*Database, error) {
+	h, err := db.openChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	view := *db
+	view.bound = h
+	return &view, nil
+}
+
+// ChannelID 返回绑定的频道ID（未绑定时为默认频道）
+func (db *Database) ChannelID() string {
+	if h := db.channel(); h != nil {
+		return h.id
+	}
+	return ""
+}
+
+// OpenChannels 返回已打开的频道ID列表
+func (db *Database) OpenChannels() []string {
+	db.channels.mu.RLock()
+	defer db.channels.mu.RUnlock()
+	ids := make([]string, 0, len(db.channels.handles))
+	for id := range db.channels.handles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CloseChannelDB 关闭频道数据库并移出连接池（绑定该频道的视图随之不可用）
+func (db *Database) CloseChannelDB(channelID string) error {
+	db.channels.mu.Lock()
+	h, ok := db.channels.handles[channelID]
+	if ok {
+		delete(db.channels.handles, channelID)
+		if db.channels.defaultID == channelID {
+			db.channels.defaultID = ""
+		}
+	}
+	db.channels.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return h.close()
+}
+
+// SealChannels 加密数据目录中全部频道库与文件内容（启用静态加密时调用）
+// 频道库平时在加入频道时才打开并加密其中的明文，这里逐个打开尚未打开的频道库，加密后关闭。
+func (db *Database) SealChannels() error {
+	if db.key == nil {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, "channels", "*.db"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		channelID := strings.TrimSuffix(filepath.Base(path), ".db")
+		db.channels.mu.RLock()
+		_, open := db.channels.handles[channelID]
+		db.channels.mu.RUnlock()
+		if open {
+			continue
+		}
+		if _, err := db.openChannel(channelID); err != nil {
+			return fmt.Errorf("failed to encrypt channel %s: %w", channelID, err)
+		}
+		if err := db.CloseChannelDB(channelID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channel 返回当前视图访问的频道库：绑定的频道，或默认频道
+// 绑定的频道被关闭后仍返回其句柄，数据库操作返回连接已关闭的错误，而不是落到其他频道
+func (db *Database) channel() *channelHandle {
+	if db.bound != nil {
+		return db.bound
+	}
+	db.channels.mu.RLock()
+	defer db.channels.mu.RUnlock()
+	return db.channels.handles[db.channels.defaultID]
+}
+
+// openChannel 打开频道库并登记到连接池（已打开时直接返回）
+func (db *Database) openChannel(channelID string) (*channelHandle, error) {
+	if channelID == "" {
+		return nil, fmt.Errorf("channel id is empty")
+	}
+
+	// 打开与迁移期间持有写锁，避免同一频道被并发打开两次
+	db.channels.mu.Lock()
+	defer db.channels.mu.Unlock()
+	if h, ok := db.channels.handles[channelID]; ok {
+		return h, nil
+	}
+
 	channelsDir := filepath.Join(db.dataDir, "channels")
 	if err := os.MkdirAll(channelsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create channels directory: %w", err)
+		return nil, fmt.Errorf("failed to create channels directory: %w", err)
 	}
 
-	channelDBPath := filepath.Join(channelsDir, fmt.Sprintf("%s.db", channelID))
+	channelDBPath := db.channelDBPath(channelID)
 	log.Printf("[DB] OpenChannelDB: channel_id=%s path=%s", channelID, channelDBPath)
 	channelDB, err := gorm.Open(sqlite.Open(channelDBPath), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to open channel database: %w", err)
+		return nil, fmt.Errorf("failed to open channel database: %w", err)
 	}
+	h := &channelHandle{id: channelID, db: withFieldKey(channelDB, db.key)}
 
-	db.channelDB = channelDB
+	if err := db.initChannel(h); err != nil {
+		h.close()
+		return nil, err
+	}
 
+	db.channels.handles[channelID] = h
+	if db.channels.defaultID == "" {
+		db.channels.defaultID = channelID
+	}
+	return h, nil
+}
+
+// initChannel 配置并迁移新打开的频道库
+func (db *Database) initChannel(h *channelHandle) error {
 	// 配置 SQLite
-	if err := db.configureSQLite(channelDB); err != nil {
+	if err := db.configureSQLite(h.db); err != nil {
+		return err
+	}
+
+	// 文件内容按频道存放：<dataDir>/blobs/<channelID>/
+	blobs, err := NewBlobStore(filepath.Join(db.dataDir, "blobs", h.id), db.key)
+	if err != nil {
 		return err
 	}
+	h.blobs = blobs
 
-	// 自动迁移频道数据库
-	if err := db.migrateChannelDB(); err != nil {
+	// 迁移频道数据库
+	if err := runMigrations(h.db, db.channelDBPath(h.id), channelMigrations); err != nil {
 		return err
 	}
 
+	// 加密启用加密前写入的明文数据
+	if err := sealDatabase(h.db, db.channelDBPath(h.id), db.key, channelSealedModels...); err != nil {
+		return err
+	}
+	if n, err := blobs.sealAll(); err != nil {
+		return err
+	} else if n > 0 {
+		log.Printf("[DB] Encrypted %d blob(s): channel_id=%s", n, h.id)
+	}
+
 	// 确保基础记录存在：频道占位与系统成员，避免后续外键错误
-	if err := db.ensureChannelInitialized(channelID); err != nil {
+	if err := ensureChannelInitialized(h.db, h.id); err != nil {
 		return err
 	}
 
 	// 诊断：验证占位是否存在
 	var chCount int64
-	if e := db.channelDB.Model(&models.Channel{}).Where("id = ?", channelID).Count(&chCount).Error; e != nil {
-		log.Printf("[DB] Verify channel placeholder failed: id=%s err=%v", channelID, e)
+	if e := h.db.Model(&models.Channel{}).Where("id = ?", h.id).Count(&chCount).Error; e != nil {
+		log.Printf("[DB] Verify channel placeholder failed: id=%s err=%v", h.id, e)
 	} else {
-		log.Printf("[DB] Verify channel placeholder: id=%s count=%d", channelID, chCount)
+		log.Printf("[DB] Verify channel placeholder: id=%s count=%d", h.id, chCount)
 	}
 	var sysCount int64
-	if e := db.channelDB.Model(&models.Member{}).Where("id = ?", "system").Count(&sysCount).Error; e != nil {
+	if e := h.db.Model(&models.Member{}).Where("id = ?", "system").Count(&sysCount).Error; e != nil {
 		log.Printf("[DB] Verify system member failed: err=%v", e)
 	} else {
 		log.Printf("[DB] Verify system member: count=%d", sysCount)
@@ -137,15 +329,25 @@ func (db *Database) OpenChannelDB(channelID string) error {
 	return nil
 }
 
-// ensureChannelInitialized 确保频道与系统成员的基础记录存在
-func (db *Database) ensureChannelInitialized(channelID string) error {
-	if db.channelDB == nil {
-		return fmt.Errorf("channel database is not opened")
+// channelDBPath 返回频道库文件路径
+func (db *Database) channelDBPath(channelID string) string {
+	return filepath.Join(db.dataDir, "channels", fmt.Sprintf("%s.db", channelID))
+}
+
+// close 关闭频道库连接
+func (h *channelHandle) close() error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
+}
 
+// ensureChannelInitialized 确保频道与系统成员的基础记录存在
+func ensureChannelInitialized(channelDB *gorm.DB, channelID string) error {
 	// 1) 确保 channels 表存在该频道占位记录
 	var ch models.Channel
-	if err := db.channelDB.Where("id = ?", channelID).First(&ch).Error; err != nil {
+	if err := channelDB.Where("id = ?", channelID).First(&ch).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			now := time.Now()
 			placeholder := &models.Channel{
@@ -161,7 +363,7 @@ func (db *Database) ensureChannelInitialized(channelID string) error {
 				KeyVersion:    1,
 				UpdatedAt:     now,
 			}
-			if e := db.channelDB.Create(placeholder).Error; e != nil {
+			if e := channelDB.Create(placeholder).Error; e != nil {
 				log.Printf("[DB] Create placeholder channel failed: id=%s err=%v", channelID, e)
 				return fmt.Errorf("failed to create placeholder channel: %w", e)
 			}
@@ -173,7 +375,7 @@ func (db *Database) ensureChannelInitialized(channelID string) error {
 
 	// 2) 确保存在 system 成员（用于系统消息外键）
 	var sys models.Member
-	if err := db.channelDB.Where("id = ?", "system").First(&sys).Error; err != nil {
+	if err := channelDB.Where("id = ?", "system").First(&sys).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			now := time.Now()
 			sysRec := &models.Member{
@@ -187,7 +389,7 @@ func (db *Database) ensureChannelInitialized(channelID string) error {
 				JoinedAt:      now,
 				LastHeartbeat: now,
 			}
-			if e := db.channelDB.Create(sysRec).Error; e != nil {
+			if e := channelDB.Create(sysRec).Error; e != nil {
 				log.Printf("[DB] Create system member failed: channel_id=%s err=%v", channelID, e)
 				return fmt.Errorf("failed to create system member: %w", e)
 			}
@@ -233,50 +435,17 @@ func (db *Database) configureSQLite(gdb *gorm.DB) error {
 	return nil
 }
 
-// migrateUserDB 迁移用户数据库
-func (db *Database) migrateUserDB() error {
-	return db.userDB.AutoMigrate(
-		&models.UserProfile{},
-		&models.RecentChannel{},
-	)
-}
-
-// migrateCacheDB 迁移缓存数据库
-func (db *Database) migrateCacheDB() error {
-	return db.cacheDB.AutoMigrate(
-		&models.CacheEntry{},
-	)
-}
-
-// migrateChannelDB 迁移频道数据库
-func (db *Database) migrateChannelDB() error {
-	// 迁移基础表
-	if err := db.channelDB.AutoMigrate(
-		&models.Channel{},
-		&models.Member{},
-		&models.Message{},
-		&models.MessageReaction{},
-		&models.TypingStatus{},
-		&models.File{},
-		&models.FileChunk{},
-		&models.AuditLog{},
-		&models.MuteRecord{},
-		&models.PinnedMessage{},
-		&models.Challenge{},
-		&models.ChallengeAssignment{},
-		&models.ChallengeProgress{},
-		&models.ChallengeSubmission{},
-	); err != nil {
-		return err
-	}
-
-	// 注意：不使用 FTS5，搜索功能使用 LIKE 查询实现
-	return nil
+// Encrypted 是否启用了静态加密
+func (db *Database) Encrypted() bool {
+	return db.key != nil
 }
 
-// GetChannelDB 获取频道数据库
+// GetChannelDB 获取频道数据库（绑定的频道或默认频道，未打开时为 nil）
 func (db *Database) GetChannelDB() *gorm.DB {
-	return db.channelDB
+	if h := db.channel(); h != nil {
+		return h.db
+	}
+	return nil
 }
 
 // GetUserDB 获取用户数据库
@@ -289,6 +458,14 @@ func (db *Database) GetCacheDB() *gorm.DB {
 	return db.cacheDB
 }
 
+// GetBlobStore 获取频道文件内容存储（绑定的频道或默认频道，未打开时为 nil）
+func (db *Database) GetBlobStore() *BlobStore {
+	if h := db.channel(); h != nil {
+		return h.blobs
+	}
+	return nil
+}
+
 // ==================== Repository方法 ====================
 
 // MessageRepo 获取消息仓库
@@ -321,22 +498,29 @@ func (db *Database) ChannelRepo() *ChannelRepository {
 	return NewChannelRepository(db)
 }
 
+// SessionRepo 获取会话仓库
+func (db *Database) SessionRepo() *SessionRepository {
+	return NewSessionRepository(db)
+}
+
 // AuditRepo 获取审计仓库
 func (db *Database) AuditRepo() *AuditRepository {
 	return NewAuditRepository(db)
 }
 
-// Close 关闭数据库连接
+// Close 关闭数据库连接（包括连接池中的全部频道库）
 func (db *Database) Close() error {
 	var errs []error
 
-	if db.channelDB != nil {
-		if sqlDB, err := db.channelDB.DB(); err == nil {
-			if err := sqlDB.Close(); err != nil {
-				errs = append(errs, err)
-			}
+	db.channels.mu.Lock()
+	for id, h := range db.channels.handles {
+		if err := h.close(); err != nil {
+			errs = append(errs, err)
 		}
+		delete(db.channels.handles, id)
 	}
+	db.channels.defaultID = ""
+	db.channels.mu.Unlock()
 
 	if sqlDB, err := db.userDB.DB(); err == nil {
 		if err := sqlDB.Close(); err != nil {
@@ -357,11 +541,23 @@ func (db *Database) Close() error {
 	return nil
 }
 
+// Vacuum 整理频道数据库，回收删除记录后留下的空闲页，并截断 WAL 文件
+func (db *Database) Vacuum() error {
+	channelDB := db.GetChannelDB()
+	if channelDB == nil {
+		return fmt.Errorf("channel database is not opened")
+	}
+	if err := channelDB.Exec("VACUUM").Error; err != nil {
+		return err
+	}
+	return channelDB.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error
+}
+
 // ==================== 频道（Channel） ====================
 
 // CreateChannel 创建频道
 func (db *Database) CreateChannel(channel *models.Channel) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().Create(channel)
@@ -369,7 +565,7 @@ func (db *Database) CreateChannel(channel *models.Channel) error {
 
 // GetChannel 获取频道信息
 func (db *Database) GetChannel(channelID string) (*models.Channel, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().GetByID(channelID)
@@ -377,7 +573,7 @@ func (db *Database) GetChannel(channelID string) (*models.Channel, error) {
 
 // UpdateChannel 更新频道
 func (db *Database) UpdateChannel(channel *models.Channel) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().Update(channel)
@@ -385,7 +581,7 @@ func (db *Database) UpdateChannel(channel *models.Channel) error {
 
 // DeleteChannel 删除频道
 func (db *Database) DeleteChannel(channelID string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().Delete(channelID)
@@ -395,7 +591,7 @@ func (db *Database) DeleteChannel(channelID string) error {
 
 // AddMember 添加成员
 func (db *Database) AddMember(member *models.Member) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().Create(member)
@@ -403,7 +599,7 @@ func (db *Database) AddMember(member *models.Member) error {
 
 // GetMembers 获取成员列表
 func (db *Database) GetMembers(channelID string) ([]*models.Member, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().GetByChannelID(channelID)
@@ -411,7 +607,7 @@ func (db *Database) GetMembers(channelID string) ([]*models.Member, error) {
 
 // UpdateMemberStatus 更新成员状态
 func (db *Database) UpdateMemberStatus(memberID string, status models.UserStatus) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().UpdateStatus(memberID, status)
@@ -419,7 +615,7 @@ func (db *Database) UpdateMemberStatus(memberID string, status models.UserStatus
 
 // RemoveMember 移除成员
 func (db *Database) RemoveMember(memberID string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().Delete(memberID)
@@ -429,7 +625,7 @@ func (db *Database) RemoveMember(memberID string) error {
 
 // SaveMessage 保存消息
 func (db *Database) SaveMessage(message *models.Message) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	if err := db.MessageRepo().Create(message); err != nil {
@@ -443,40 +639,24 @@ func (db *Database) SaveMessage(message *models.Message) error {
 
 // GetMessages 获取消息列表（分页）
 func (db *Database) GetMessages(channelID string, limit, offset int) ([]*models.Message, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.MessageRepo().GetByChannelID(channelID, limit, offset)
 }
 
-// SearchMessages 搜索消息（使用LIKE查询）
+// SearchMessages 搜索消息（见 MessageRepository.Search）
 func (db *Database) SearchMessages(channelID, keyword string, limit, offset int) ([]*models.Message, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 
-	if keyword == "" {
-		return db.GetMessages(channelID, limit, offset)
-	}
-
-	// 使用 LIKE 搜索（content_text / sender_nickname / tags）
-	like := "%" + keyword + "%"
-	var messages []*models.Message
-	err := db.channelDB.Where("channel_id = ? AND deleted = 0 AND (content_text LIKE ? OR sender_nickname LIKE ? OR tags LIKE ?)",
-		channelID, like, like, like).
-		Order("timestamp DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
-	if err != nil {
-		return nil, err
-	}
-	return messages, nil
+	return db.MessageRepo().Search(channelID, keyword, limit, offset)
 }
 
 // DeleteMessage 删除消息（软删除）
 func (db *Database) DeleteMessage(messageID, deletedBy string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MessageRepo().Delete(messageID, deletedBy)
@@ -486,7 +666,7 @@ func (db *Database) DeleteMessage(messageID, deletedBy string) error {
 
 // SaveFile 保存文件
 func (db *Database) SaveFile(file *models.File) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	if err := db.FileRepo().Create(file); err != nil {
@@ -500,7 +680,7 @@ func (db *Database) SaveFile(file *models.File) error {
 
 // GetFile 获取文件
 func (db *Database) GetFile(fileID string) (*models.File, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.FileRepo().GetByID(fileID)
@@ -508,7 +688,7 @@ func (db *Database) GetFile(fileID string) (*models.File, error) {
 
 // GetFiles 获取文件列表
 func (db *Database) GetFiles(channelID string, limit, offset int) ([]*models.File, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.FileRepo().GetByChannelID(channelID, limit, offset)
@@ -518,7 +698,7 @@ func (db *Database) GetFiles(channelID string, limit, offset int) ([]*models.Fil
 
 // CreateChallenge 创建题目
 func (db *Database) CreateChallenge(ch *models.Challenge) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChallengeRepo().Create(ch)
@@ -526,7 +706,7 @@ func (db *Database) CreateChallenge(ch *models.Challenge) error {
 
 // GetChallenges 获取题目列表
 func (db *Database) GetChallenges(channelID string) ([]*models.Challenge, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.ChallengeRepo().GetByChannelID(channelID)
@@ -534,7 +714,7 @@ func (db *Database) GetChallenges(channelID string) ([]*models.Challenge, error)
 
 // AssignChallenge 分配题目
 func (db *Database) AssignChallenge(assignment *models.ChallengeAssignment) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChallengeRepo().AssignChallenge(assignment)
@@ -542,7 +722,7 @@ func (db *Database) AssignChallenge(assignment *models.ChallengeAssignment) erro
 
 // SubmitFlag 提交 Flag
 func (db *Database) SubmitFlag(submission *models.ChallengeSubmission) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChallengeRepo().SubmitFlag(submission)
@@ -550,7 +730,7 @@ func (db *Database) SubmitFlag(submission *models.ChallengeSubmission) error {
 
 // UpdateProgress 更新进度
 func (db *Database) UpdateProgress(progress *models.ChallengeProgress) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChallengeRepo().UpdateProgress(progress)
@@ -560,7 +740,7 @@ func (db *Database) UpdateProgress(progress *models.ChallengeProgress) error {
 
 // SaveAuditLog 保存审计日志
 func (db *Database) SaveAuditLog(log *models.AuditLog) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.AuditRepo().Log(log)
@@ -568,7 +748,7 @@ func (db *Database) SaveAuditLog(log *models.AuditLog) error {
 
 // GetAuditLogs 获取审计日志
 func (db *Database) GetAuditLogs(channelID string, limit, offset int) ([]*models.AuditLog, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.AuditRepo().GetByChannelID(channelID, limit, offset)
@@ -578,7 +758,7 @@ func (db *Database) GetAuditLogs(channelID string, limit, offset int) ([]*models
 
 // MuteMember 禁言成员
 func (db *Database) MuteMember(record *models.MuteRecord) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().MuteMember(record)
@@ -586,7 +766,7 @@ func (db *Database) MuteMember(record *models.MuteRecord) error {
 
 // UnmuteMember 解除禁言
 func (db *Database) UnmuteMember(memberID, unmutedBy string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().UnmuteMember(memberID, unmutedBy)
@@ -594,7 +774,7 @@ func (db *Database) UnmuteMember(memberID, unmutedBy string) error {
 
 // IsMuted 检查是否被禁言
 func (db *Database) IsMuted(memberID string) (bool, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return false, fmt.Errorf("channel database is not opened")
 	}
 	return db.MemberRepo().IsMuted(memberID)
@@ -602,7 +782,7 @@ func (db *Database) IsMuted(memberID string) (bool, error) {
 
 // PinMessage 置顶消息
 func (db *Database) PinMessage(channelID, messageID, pinnedBy, reason string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().PinMessage(channelID, messageID, pinnedBy, reason)
@@ -610,7 +790,7 @@ func (db *Database) PinMessage(channelID, messageID, pinnedBy, reason string) er
 
 // UnpinMessage 取消置顶
 func (db *Database) UnpinMessage(channelID, messageID string) error {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().UnpinMessage(channelID, messageID)
@@ -618,7 +798,7 @@ func (db *Database) UnpinMessage(channelID, messageID string) error {
 
 // GetPinnedMessages 获取置顶消息
 func (db *Database) GetPinnedMessages(channelID string) ([]*models.PinnedMessage, error) {
-	if db.channelDB == nil {
+	if db.GetChannelDB() == nil {
 		return nil, fmt.Errorf("channel database is not opened")
 	}
 	return db.ChannelRepo().GetPinnedMessages(channelID)
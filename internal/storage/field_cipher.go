@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"crosswire/internal/crypto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// sealedPrefix 加密字段值的前缀；没有前缀的值是启用加密前写入的明文，读取时原样返回
+const sealedPrefix = "$cwenc1$"
+
+// fieldKeyContext 数据密钥在 gorm 会话 context 中的键
+type fieldKeyContext struct{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", fieldCipher{})
+}
+
+// fieldCipher 敏感列的字段级加密（模型标签 serializer:encrypted）
+// 值以 AES-256-GCM 加密后存为 "$cwenc1$" + base64。数据密钥随 gorm 会话的 context 传入，
+// 因此同一进程中的多个 Database 可以各自使用不同的密钥；未启用加密的会话读写明文。
+// 注意：只有经由模型结构体读写的值会被加解密，按列名的 map 更新须改用结构体。
+type fieldCipher struct{}
+
+// withFieldKey 返回携带数据密钥的会话（key 为 nil 时原样返回）
+func withFieldKey(gdb *gorm.DB, key []byte) *gorm.DB {
+	if key == nil {
+		return gdb
+	}
+	return gdb.WithContext(context.WithValue(context.Background(), fieldKeyContext{}, key))
+}
+
+// fieldKey 从 context 取出数据密钥
+func fieldKey(ctx context.Context) []byte {
+	key, _ := ctx.Value(fieldKeyContext{}).([]byte)
+	return key
+}
+
+// Scan 解密数据库中的值并写入字段
+func (fieldCipher) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+	if dbValue != nil {
+		var raw []byte
+		switch v := dbValue.(type) {
+		case []byte:
+			raw = v
+		case string:
+			raw = []byte(v)
+		default:
+			return fmt.Errorf("unsupported value type %T for encrypted field %s", dbValue, field.Name)
+		}
+		plain, err := openField(fieldKey(ctx), raw)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+		}
+		if err := assignField(fieldValue, plain); err != nil {
+			return fmt.Errorf("failed to scan field %s: %w", field.Name, err)
+		}
+	} else if scanner, ok := fieldValue.Interface().(sql.Scanner); ok {
+		if err := scanner.Scan(nil); err != nil {
+			return err
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value 将字段值加密后写入数据库（空值不加密）
+func (fieldCipher) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var raw interface{}
+	var plain []byte
+	switch v := fieldValue.(type) {
+	case string:
+		raw, plain = v, []byte(v)
+	case []byte:
+		raw, plain = v, v
+	case driver.Valuer:
+		dv, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		switch d := dv.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			raw, plain = d, []byte(d)
+		case []byte:
+			raw, plain = d, d
+		default:
+			return nil, fmt.Errorf("unsupported value type %T for encrypted field %s", dv, field.Name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported field type %T for encrypted field %s", fieldValue, field.Name)
+	}
+
+	key := fieldKey(ctx)
+	if key == nil || len(plain) == 0 {
+		return raw, nil
+	}
+	return sealField(key, plain)
+}
+
+// assignField 将明文写入 ptr 指向的字段值
+func assignField(ptr reflect.Value, plain []byte) error {
+	if scanner, ok := ptr.Interface().(sql.Scanner); ok {
+		return scanner.Scan(plain)
+	}
+	elem := ptr.Elem()
+	switch {
+	case elem.Kind() == reflect.String:
+		elem.SetString(string(plain))
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8:
+		elem.SetBytes(append([]byte(nil), plain...))
+	default:
+		return fmt.Errorf("unsupported field type %s", elem.Type())
+	}
+	return nil
+}
+
+// sealField 加密一个字段值
+func sealField(key, plain []byte) (string, error) {
+	cm, _ := crypto.NewManager()
+	ciphertext, err := cm.AESEncrypt(plain, key)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openField 解密一个字段值（没有前缀的明文原样返回）
+func openField(key, raw []byte) ([]byte, error) {
+	if !bytes.HasPrefix(raw, []byte(sealedPrefix)) {
+		return raw, nil
+	}
+	if key == nil {
+		return nil, ErrLocked
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(string(raw[len(sealedPrefix):]))
+	if err != nil {
+		return nil, err
+	}
+	cm, _ := crypto.NewManager()
+	return cm.AESDecrypt(ciphertext, key)
+}
+
+// sealDatabase 加密库中启用加密前写入的明文值
+// 有值被加密时整理数据库并截断 WAL，使旧的明文页不再留在文件中；
+// 迁移前留下的备份（<path>.v*.bak）是明文副本，一并删除。
+func sealDatabase(gdb *gorm.DB, path string, key []byte, models ...interface{}) error {
+	sealed, err := sealPlaintext(gdb, key, models...)
+	if err != nil {
+		return err
+	}
+	if sealed == 0 {
+		return nil
+	}
+	if err := gdb.Exec("VACUUM").Error; err != nil {
+		return fmt.Errorf("failed to vacuum %s: %w", path, err)
+	}
+	if err := gdb.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+		return fmt.Errorf("failed to checkpoint %s: %w", path, err)
+	}
+	backups, _ := filepath.Glob(path + ".v*.bak")
+	for _, backup := range backups {
+		if err := os.Remove(backup); err != nil {
+			log.Printf("[DB] Failed to remove plaintext backup: path=%s err=%v", backup, err)
+		}
+	}
+	log.Printf("[DB] Encrypted %d plaintext value(s): path=%s removed_backups=%d", sealed, path, len(backups))
+	return nil
+}
+
+// sealPlaintext 加密启用加密前写入的敏感列（按模型标签查找，已加密的值跳过，可重复执行），返回加密的值数量
+func sealPlaintext(gdb *gorm.DB, key []byte, models ...interface{}) (int, error) {
+	if key == nil {
+		return 0, nil
+	}
+	total := 0
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: gdb}
+		if err := stmt.Parse(model); err != nil {
+			return total, err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.TagSettings["SERIALIZER"] != "encrypted" {
+				continue
+			}
+			n, err := sealColumn(gdb, key, stmt.Schema.Table, field.DBName)
+			if err != nil {
+				return total, fmt.Errorf("failed to encrypt %s.%s: %w", stmt.Schema.Table, field.DBName, err)
+			}
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// sealColumn 加密一列中的明文值
+func sealColumn(gdb *gorm.DB, key []byte, table, column string) (int, error) {
+	var rows []struct {
+		RowID int64
+		Value []byte
+	}
+	query := fmt.Sprintf(`SELECT rowid AS row_id, CAST(%[1]s AS BLOB) AS value FROM %[2]s
+		WHERE %[1]s IS NOT NULL AND length(%[1]s) > 0 AND substr(CAST(%[1]s AS BLOB), 1, ?) <> CAST(? AS BLOB)`, column, table)
+	if err := gdb.Raw(query, len(sealedPrefix), sealedPrefix).Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		update := fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", table, column)
+		for _, row := range rows {
+			sealed, err := sealField(key, row.Value)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec(update, sealed, row.RowID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
@@ -1,9 +1,17 @@
 package storage
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"time"
 
 	"crosswire/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // FileRepository 文件数据仓库
@@ -74,6 +82,26 @@ func (r *FileRepository) UpdateUploadStatus(fileID string, status models.UploadS
 		}).Error
 }
 
+// UpdatePreview 保存文件预览（缩略图、文本预览与元数据）
+// 缩略图与文本预览是加密列，须以结构体更新（map 更新不经过字段加密）
+func (r *FileRepository) UpdatePreview(fileID string, thumbnail []byte, previewText string, metadata models.JSONField) error {
+	return r.db.GetChannelDB().Model(&models.File{}).
+		Where("id = ?", fileID).
+		Select("thumbnail", "preview_text", "metadata").
+		Updates(&models.File{
+			Thumbnail:   thumbnail,
+			PreviewText: previewText,
+			Metadata:    metadata,
+		}).Error
+}
+
+// UpdateAnalysis 保存附件分析结果（元数据）
+func (r *FileRepository) UpdateAnalysis(fileID string, metadata models.JSONField) error {
+	return r.db.GetChannelDB().Model(&models.File{}).
+		Where("id = ?", fileID).
+		Update("metadata", metadata).Error
+}
+
 // Delete 删除文件记录
 func (r *FileRepository) Delete(fileID string) error {
 	return r.db.GetChannelDB().Where("id = ?", fileID).Delete(&models.File{}).Error
@@ -91,6 +119,37 @@ func (r *FileRepository) GetExpiredFiles() ([]*models.File, error) {
 	return files, nil
 }
 
+// GetStaleUploads 获取在 before 之前登记、仍未完成的上传
+func (r *FileRepository) GetStaleUploads(before time.Time) ([]*models.File, error) {
+	var files []*models.File
+	err := r.db.GetChannelDB().Where("upload_status <> ? AND uploaded_at < ?", models.UploadStatusCompleted, before).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// GetStorageUsage 统计频道文件总大小（字节，含上传中的文件）
+func (r *FileRepository) GetStorageUsage(channelID string) (int64, error) {
+	var total int64
+	err := r.db.GetChannelDB().Model(&models.File{}).
+		Where("channel_id = ? AND upload_status <> ?", channelID, models.UploadStatusFailed).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// GetMemberStorageUsage 统计成员在频道中上传的文件总大小（字节，含上传中的文件）
+func (r *FileRepository) GetMemberStorageUsage(channelID, senderID string) (int64, error) {
+	var total int64
+	err := r.db.GetChannelDB().Model(&models.File{}).
+		Where("channel_id = ? AND sender_id = ? AND upload_status <> ?", channelID, senderID, models.UploadStatusFailed).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
 // CleanExpiredFiles 清理过期文件
 func (r *FileRepository) CleanExpiredFiles() error {
 	now := time.Now()
@@ -108,6 +167,42 @@ func (r *FileRepository) UpdateChunk(chunk *models.FileChunk) error {
 	return r.db.GetChannelDB().Save(chunk).Error
 }
 
+// RecordChunk 记录分块已接收：按 (file_id, chunk_index) 写入分块记录，并保存文件的分块位图与计数
+// 调用方须已通过 file.MarkChunk 更新位图
+func (r *FileRepository) RecordChunk(file *models.File, chunk *models.FileChunk) error {
+	return r.db.GetChannelDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}, {Name: "chunk_index"}},
+			UpdateAll: true,
+		}).Create(chunk).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.File{}).
+			Where("id = ?", file.ID).
+			Updates(map[string]interface{}{
+				"chunk_bitmap":    file.ChunkBitmap,
+				"uploaded_chunks": file.UploadedChunks,
+				"upload_status":   file.UploadStatus,
+			}).Error
+	})
+}
+
+// ResetChunks 清空文件的分块接收状态（内容校验失败后需完整重传）
+func (r *FileRepository) ResetChunks(fileID string, status models.UploadStatus) error {
+	return r.db.GetChannelDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", fileID).Delete(&models.FileChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.File{}).
+			Where("id = ?", fileID).
+			Updates(map[string]interface{}{
+				"chunk_bitmap":    nil,
+				"uploaded_chunks": 0,
+				"upload_status":   status,
+			}).Error
+	})
+}
+
 // GetChunksByFileID 获取文件的所有分块
 func (r *FileRepository) GetChunksByFileID(fileID string) ([]*models.FileChunk, error) {
 	var chunks []*models.FileChunk
@@ -152,6 +247,98 @@ func (r *FileRepository) GetUploadProgress(fileID string) (uploaded, total int,
 	return file.UploadedChunks, file.TotalChunks, nil
 }
 
+// WriteChunk 将分块写入上传临时文件（按 chunk_index*chunk_size 偏移，允许乱序到达）
+// 写入范围不能超出声明的文件大小（配额按声明大小计算）
+func (r *FileRepository) WriteChunk(file *models.File, chunkIndex int, data []byte) error {
+	if file.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size for file %s", file.ID)
+	}
+	if chunkIndex < 0 || (file.TotalChunks > 0 && chunkIndex >= file.TotalChunks) {
+		return fmt.Errorf("chunk index out of range: %d", chunkIndex)
+	}
+	if len(data) > file.ChunkSize {
+		return fmt.Errorf("chunk %d exceeds chunk size: %d > %d", chunkIndex, len(data), file.ChunkSize)
+	}
+	offset := int64(chunkIndex) * int64(file.ChunkSize)
+	if offset+int64(len(data)) > file.Size {
+		return fmt.Errorf("chunk %d exceeds file size: %d > %d", chunkIndex, offset+int64(len(data)), file.Size)
+	}
+	return r.db.GetBlobStore().WriteAt(file.ID, offset, data)
+}
+
+// CommitContent 校验上传内容并移入内容存储，文件记录改为 StorageFile
+// 校验失败返回 ErrBlobHashMismatch（可用 errors.Is 判断），临时文件已被丢弃
+func (r *FileRepository) CommitContent(file *models.File) error {
+	hash, err := r.db.GetBlobStore().Commit(file.ID, file.Size, file.SHA256)
+	if err != nil {
+		return err
+	}
+
+	file.StorageType = models.StorageFile
+	file.StoragePath = r.db.GetBlobStore().Path(hash)
+	file.SHA256 = hash
+	file.Data = nil
+	file.UploadStatus = models.UploadStatusCompleted
+	return r.db.GetChannelDB().Model(&models.File{}).
+		Where("id = ?", file.ID).
+		Updates(map[string]interface{}{
+			"storage_type":    file.StorageType,
+			"storage_path":    file.StoragePath,
+			"sha256":          file.SHA256,
+			"data":            nil,
+			"upload_status":   file.UploadStatus,
+			"uploaded_chunks": file.UploadedChunks,
+		}).Error
+}
+
+// AbortContent 丢弃未完成的上传内容
+func (r *FileRepository) AbortContent(fileID string) error {
+	return r.db.GetBlobStore().Abort(fileID)
+}
+
+// OpenContent 打开文件内容用于流式读取（兼容旧的内联存储）
+// 内容存储中的文件经 BlobStore 读取（启用静态加密时解密）
+func (r *FileRepository) OpenContent(file *models.File) (io.ReadSeekCloser, error) {
+	switch {
+	case file.StorageType == models.StorageFile && file.StoragePath != "":
+		if blobs := r.db.GetBlobStore(); blobs != nil && blobs.Owns(file.StoragePath) {
+			return blobs.Open(filepath.Base(file.StoragePath))
+		}
+		return os.Open(file.StoragePath)
+	case len(file.Data) > 0:
+		return inlineContent{bytes.NewReader(file.Data)}, nil
+	default:
+		return nil, fmt.Errorf("file %s has no content", file.ID)
+	}
+}
+
+// ReleaseContent 释放文件内容：内容存储中的 blob 仅在无其他文件引用时删除
+// 不在内容存储中的路径（如客户端下载到用户目录的文件）直接删除
+func (r *FileRepository) ReleaseContent(file *models.File) error {
+	if file.StorageType != models.StorageFile || file.StoragePath == "" {
+		return nil
+	}
+
+	blobs := r.db.GetBlobStore()
+	if blobs == nil || !blobs.Owns(file.StoragePath) {
+		if err := os.Remove(file.StoragePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var refs int64
+	if err := r.db.GetChannelDB().Model(&models.File{}).
+		Where("storage_path = ? AND id <> ?", file.StoragePath, file.ID).
+		Count(&refs).Error; err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	return blobs.Remove(filepath.Base(file.StoragePath))
+}
+
 // SearchFiles 按名称/类型搜索文件
 func (r *FileRepository) SearchFiles(channelID string, keyword string, mimeLike string, limit, offset int) ([]*models.File, error) {
 	var files []*models.File
@@ -231,3 +418,13 @@ func (r *FileRepository) GetPendingUploads(channelID string) ([]*models.File, er
 	}
 	return files, nil
 }
+
+// inlineContent 内联数据的只读流
+type inlineContent struct {
+	*bytes.Reader
+}
+
+// Close 实现 io.Closer
+func (inlineContent) Close() error {
+	return nil
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"crosswire/internal/crypto"
+)
+
+// ErrLocked 数据目录已启用静态加密，需要口令解锁
+var ErrLocked = errors.New("storage is locked")
+
+// ErrWrongPassphrase 口令无法解开数据密钥
+var ErrWrongPassphrase = errors.New("wrong storage passphrase")
+
+// keyringFileName 数据目录下的密钥文件
+const keyringFileName = "keyring.json"
+
+// keyringFile 静态加密密钥文件
+// 随机生成的数据密钥用口令派生的密钥（Argon2id）以 AES-256-GCM 包裹后保存，
+// 修改口令只需重新包裹数据密钥，已加密的数据不变。
+type keyringFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// IsEncrypted 检查数据目录是否已启用静态加密
+func IsEncrypted(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, keyringFileName))
+	return err == nil
+}
+
+// ChangePassphrase 修改静态加密口令（重新包裹数据密钥）
+func ChangePassphrase(dataDir, oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+	key, err := unlockKeyring(dataDir, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeyring(dataDir, key, newPassphrase)
+}
+
+// openKeyring 返回数据密钥
+// 未启用加密且未提供口令时返回 nil（明文存储）；提供口令而尚未启用时生成新的数据密钥并启用。
+func openKeyring(dataDir, passphrase string) ([]byte, error) {
+	if !IsEncrypted(dataDir) {
+		if passphrase == "" {
+			return nil, nil
+		}
+		cm, _ := crypto.NewManager()
+		key, err := cm.GenerateRandomBytes(32)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKeyring(dataDir, key, passphrase); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if passphrase == "" {
+		return nil, ErrLocked
+	}
+	return unlockKeyring(dataDir, passphrase)
+}
+
+// unlockKeyring 用口令解开数据密钥
+func unlockKeyring(dataDir, passphrase string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, keyringFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	var kf keyringFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	if kf.Version != 1 || kf.KDF != "argon2id" {
+		return nil, fmt.Errorf("unsupported keyring: version=%d kdf=%s", kf.Version, kf.KDF)
+	}
+
+	cm, _ := crypto.NewManager()
+	kek, err := cm.DeriveKey(passphrase, kf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := cm.AESDecrypt(kf.WrappedKey, kek)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// writeKeyring 以口令包裹数据密钥并写入密钥文件（先写临时文件再替换）
+func writeKeyring(dataDir string, key []byte, passphrase string) error {
+	cm, _ := crypto.NewManager()
+	salt, err := cm.GenerateSalt()
+	if err != nil {
+		return err
+	}
+	kek, err := cm.DeriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	wrapped, err := cm.AESEncrypt(key, kek)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&keyringFile{
+		Version:    1,
+		KDF:        "argon2id",
+		Salt:       salt,
+		WrappedKey: wrapped,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	path := filepath.Join(dataDir, keyringFileName)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"strings"
 	"time"
 
 	"crosswire/internal/models"
@@ -211,10 +212,14 @@ func (r *MessageRepository) CleanExpiredTypingStatus() error {
 }
 
 // Search 搜索消息（使用LIKE查询）
+// 启用静态加密时 content_text 为密文，改为逐条解密后在内存中匹配（与 LIKE 一样不区分大小写）
 func (r *MessageRepository) Search(channelID, keyword string, limit, offset int) ([]*models.Message, error) {
 	if keyword == "" {
 		return r.GetByChannelID(channelID, limit, offset)
 	}
+	if r.db.Encrypted() {
+		return r.searchDecrypted(channelID, keyword, limit, offset)
+	}
 
 	// 使用 LIKE 搜索
 	like := "%" + keyword + "%"
@@ -230,6 +235,47 @@ func (r *MessageRepository) Search(channelID, keyword string, limit, offset int)
 	return messages, nil
 }
 
+// searchDecrypted 在解密后的消息中匹配关键词（content_text / sender_nickname / tags）
+func (r *MessageRepository) searchDecrypted(channelID, keyword string, limit, offset int) ([]*models.Message, error) {
+	var all []*models.Message
+	if err := r.db.GetChannelDB().Where("channel_id = ? AND deleted = 0", channelID).
+		Order("timestamp DESC").
+		Find(&all).Error; err != nil {
+		return nil, err
+	}
+
+	needle := strings.ToLower(keyword)
+	matches := func(m *models.Message) bool {
+		if strings.Contains(strings.ToLower(m.ContentText), needle) ||
+			strings.Contains(strings.ToLower(m.SenderNickname), needle) {
+			return true
+		}
+		for _, tag := range m.Tags {
+			if strings.Contains(strings.ToLower(tag), needle) {
+				return true
+			}
+		}
+		return false
+	}
+
+	messages := make([]*models.Message, 0)
+	skipped := 0
+	for _, m := range all {
+		if !matches(m) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		if limit > 0 && len(messages) >= limit {
+			break
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
 // GetMessagesByTimeRange 按时间范围获取消息（含起止，按时间升序）
 func (r *MessageRepository) GetMessagesByTimeRange(channelID string, start, end time.Time, limit, offset int) ([]*models.Message, error) {
 	var messages []*models.Message
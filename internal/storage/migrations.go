@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaTooNew 数据库由更新版本的程序写入（版本高于本程序已知的最新迁移），拒绝降级打开
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// schemaVersion 已应用的迁移记录（每个数据库文件各自一张表）
+type schemaVersion struct {
+	Version   int       `gorm:"primaryKey"`
+	Name      string    `gorm:"type:text;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// migration 一个有序的升级步骤
+// 迁移只使用 SQL（基线表结构见 schema_baseline.go），不引用 models 中的模型：模型之后的变化不会改变
+// 已发布迁移的行为。已发布的迁移不得修改，只能追加；模型新增字段时须同时追加建列的迁移。
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// channelMigrations 频道库迁移
+var channelMigrations = []migration{
+	{1, "baseline", migrateChannelBaseline},
+	{2, "backfill_legacy_fields", backfillLegacyFields},
+	{3, "drop_legacy_columns", dropLegacyColumns},
+}
+
+// userMigrations 用户库迁移
+var userMigrations = []migration{
+	{1, "baseline", func(tx *gorm.DB) error {
+		return applyBaseline(tx, userBaselineSchema)
+	}},
+}
+
+// cacheMigrations 缓存库迁移
+var cacheMigrations = []migration{
+	{1, "baseline", func(tx *gorm.DB) error {
+		return applyBaseline(tx, cacheBaselineSchema)
+	}},
+}
+
+// runMigrations 将数据库升级到最新版本
+// 引入版本表之前创建的旧库视为版本 0，从基线迁移开始执行（基线对已有表只补齐缺失的列与索引）。
+// 有待执行的迁移且库中已有数据时，先以 VACUUM INTO 备份到 <path>.v<当前版本>-<时间>.bak；
+// 每个迁移与其版本记录在同一事务中提交，失败时回滚且不影响已完成的步骤。
+func runMigrations(gdb *gorm.DB, path string, migrations []migration) error {
+	if err := gdb.AutoMigrate(&schemaVersion{}); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var current int
+	if err := gdb.Model(&schemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&current).Error; err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("%w: %s is at version %d, this build supports up to %d", ErrSchemaTooNew, path, current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if err := backupBeforeMigration(gdb, path, current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaVersion{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed on %s: %w", m.version, m.name, path, err)
+		}
+	}
+	log.Printf("[DB] Migrated database: path=%s from=%d to=%d", path, current, latest)
+	return nil
+}
+
+// backupBeforeMigration 迁移前备份已有数据的库（新建的空库无需备份）
+func backupBeforeMigration(gdb *gorm.DB, path string, version int) error {
+	var tables int64
+	if err := gdb.Raw(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'
+		AND name NOT LIKE 'sqlite_%' AND name <> ?`, schemaVersion{}.TableName()).Scan(&tables).Error; err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if tables == 0 {
+		return nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().Format("20060102-150405"))
+	if _, err := os.Stat(backup); err == nil {
+		return fmt.Errorf("backup file already exists: %s", backup)
+	}
+	if err := gdb.Exec("VACUUM INTO ?", backup).Error; err != nil {
+		return fmt.Errorf("failed to back up database before migration: %w", err)
+	}
+	log.Printf("[DB] Backed up database before migration: path=%s backup=%s", path, backup)
+	return nil
+}
+
+// ==================== 频道库迁移 ====================
+
+// migrateChannelBaseline 基线：建立全部频道表
+func migrateChannelBaseline(tx *gorm.DB) error {
+	// 分块记录改为按 (file_id, chunk_index) 唯一，建索引前清理旧库中的重复行
+	if tx.Migrator().HasTable("file_chunks") {
+		if err := tx.Exec(`DELETE FROM file_chunks WHERE id NOT IN (
+			SELECT MIN(id) FROM file_chunks GROUP BY file_id, chunk_index)`).Error; err != nil {
+			return fmt.Errorf("failed to deduplicate file chunks: %w", err)
+		}
+	}
+
+	// 注意：不使用 FTS5，搜索功能使用 LIKE 查询实现
+	return applyBaseline(tx, channelBaselineSchema)
+}
+
+// backfillLegacyFields 以仓库实际读写的列为准补齐重复字段
+// messages.pinned/deleted 始终由仓库维护，is_pinned/is_deleted 只是钩子写入的副本，不回写；
+// members.joined_at 在旧客户端写入的记录中可能为零值，从 join_time 补齐。
+func backfillLegacyFields(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn("members", "join_time") {
+		if err := tx.Exec(`UPDATE members SET joined_at = join_time
+			WHERE (joined_at IS NULL OR joined_at LIKE '0001-01-01%')
+			AND join_time IS NOT NULL AND join_time NOT LIKE '0001-01-01%'`).Error; err != nil {
+			return fmt.Errorf("failed to backfill members.joined_at: %w", err)
+		}
+	}
+	return nil
+}
+
+// dropLegacyColumns 删除重复的兼容列（对应字段改为仅在内存中由钩子派生）
+func dropLegacyColumns(tx *gorm.DB) error {
+	legacy := []struct {
+		table, column, index string
+	}{
+		{"messages", "is_pinned", "idx_messages_is_pinned"},
+		{"messages", "is_deleted", "idx_messages_is_deleted"},
+		{"members", "join_time", ""},
+	}
+	for _, l := range legacy {
+		if !tx.Migrator().HasColumn(l.table, l.column) {
+			continue
+		}
+		if l.index != "" {
+			if err := tx.Exec("DROP INDEX IF EXISTS " + l.index).Error; err != nil {
+				return fmt.Errorf("failed to drop index %s: %w", l.index, err)
+			}
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", l.table, l.column)).Error; err != nil {
+			return fmt.Errorf("failed to drop column %s.%s: %w", l.table, l.column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,503 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// baselineTable 基线迁移中的一张表
+// 表结构在此冻结，不随 models 中的模型变化：之后的表结构变更只能追加新的迁移。
+type baselineTable struct {
+	name        string
+	columns     []string // 列定义，首个词为列名
+	constraints []string // 复合主键与外键
+	indexes     []string // 索引（CREATE INDEX IF NOT EXISTS）
+}
+
+// channelBaselineSchema 频道库基线（版本 1）
+var channelBaselineSchema = []baselineTable{
+	{
+		name: "channels",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"name text NOT NULL",
+			"parent_channel_id text",
+			"password_hash text NOT NULL",
+			"salt blob NOT NULL",
+			"created_at datetime NOT NULL",
+			"creator_id text NOT NULL",
+			"max_members integer DEFAULT 50",
+			"transport_mode text DEFAULT 'auto'",
+			"port integer",
+			"interface text",
+			"encryption_key blob NOT NULL",
+			"key_version integer DEFAULT 1",
+			"message_count integer DEFAULT 0",
+			"file_count integer DEFAULT 0",
+			"total_traffic integer DEFAULT 0",
+			"metadata text",
+			"updated_at datetime NOT NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_channels_parent ON channels(parent_channel_id)",
+		},
+	},
+	{
+		name: "members",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"channel_id text NOT NULL",
+			"nickname text NOT NULL",
+			"avatar text",
+			"role text NOT NULL",
+			"status text DEFAULT 'offline'",
+			"public_key blob",
+			"last_ip text",
+			"last_mac text",
+			"skills text",
+			"expertise text",
+			"current_task text",
+			"message_count integer DEFAULT 0",
+			"files_shared integer DEFAULT 0",
+			"online_time integer DEFAULT 0",
+			"is_online integer DEFAULT 0",
+			"is_muted integer DEFAULT 0",
+			"is_banned integer DEFAULT 0",
+			"last_seen_at datetime NOT NULL",
+			"joined_at datetime NOT NULL",
+			"last_heartbeat datetime NOT NULL",
+			"metadata text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_members_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_members_channel ON members(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_members_status ON members(status)",
+			"CREATE INDEX IF NOT EXISTS idx_members_online ON members(is_online)",
+			"CREATE INDEX IF NOT EXISTS idx_members_muted ON members(is_muted)",
+			"CREATE INDEX IF NOT EXISTS idx_members_banned ON members(is_banned)",
+		},
+	},
+	{
+		name: "member_sessions",
+		columns: []string{
+			"member_id text PRIMARY KEY",
+			"channel_id text NOT NULL",
+			"public_key blob",
+			"resume_token text",
+			"created_at datetime NOT NULL",
+			"last_seen datetime NOT NULL",
+			"expires_at datetime NOT NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_sessions_channel ON member_sessions(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_sessions_expires ON member_sessions(expires_at)",
+		},
+	},
+	{
+		name: "challenges",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"channel_id text NOT NULL",
+			"sub_channel_id text",
+			"title text NOT NULL",
+			"category text NOT NULL",
+			"difficulty text NOT NULL",
+			"points integer NOT NULL",
+			"description text NOT NULL",
+			"flag_format text",
+			"flag text",
+			"url text",
+			"attachments text",
+			"tags text",
+			"status text NOT NULL DEFAULT 'open'",
+			"solved_by text",
+			"solved_at datetime NOT NULL",
+			"assigned_to text",
+			"created_by text NOT NULL",
+			"created_at datetime NOT NULL",
+			"updated_at datetime NOT NULL",
+			"metadata text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_challenges_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_challenges_creator FOREIGN KEY (created_by) REFERENCES members(id) ON DELETE SET NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_challenges_channel ON challenges(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_challenges_category ON challenges(category)",
+			"CREATE INDEX IF NOT EXISTS idx_challenges_status ON challenges(status)",
+			"CREATE INDEX IF NOT EXISTS idx_challenges_created_at ON challenges(created_at)",
+		},
+	},
+	{
+		name: "messages",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"channel_id text NOT NULL",
+			"sender_id text NOT NULL",
+			"sender_nickname text NOT NULL",
+			"type text NOT NULL",
+			"content text NOT NULL",
+			"content_text text",
+			"reply_to_id text",
+			"thread_id text",
+			"mentions text",
+			"tags text",
+			"pinned integer DEFAULT 0",
+			"deleted integer DEFAULT 0",
+			"deleted_by text",
+			"deleted_at datetime NOT NULL",
+			"edited_at datetime NOT NULL",
+			"timestamp datetime NOT NULL",
+			"encrypted integer DEFAULT 1",
+			"key_version integer DEFAULT 1",
+			"metadata text",
+			"challenge_id text",
+			"room_type text DEFAULT 'main'",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_messages_reply_to FOREIGN KEY (reply_to_id) REFERENCES messages(id) ON DELETE SET NULL",
+			"CONSTRAINT fk_messages_challenge FOREIGN KEY (challenge_id) REFERENCES challenges(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_messages_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_messages_sender FOREIGN KEY (sender_id) REFERENCES members(id) ON DELETE SET NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_messages_channel_time ON messages(channel_id, timestamp)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages(sender_id)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to_id)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_thread ON messages(thread_id)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_pinned ON messages(pinned)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_deleted ON messages(deleted)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_challenge ON messages(challenge_id)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_room_type ON messages(room_type)",
+		},
+	},
+	{
+		name: "message_reactions",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"message_id text NOT NULL",
+			"user_id text NOT NULL",
+			"emoji text NOT NULL",
+			"created_at datetime NOT NULL",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_message_reactions_message FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_reactions_message ON message_reactions(message_id)",
+		},
+	},
+	{
+		name: "typing_status",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"channel_id text NOT NULL",
+			"user_id text NOT NULL",
+			"timestamp datetime NOT NULL",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_typing_status_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_typing_channel ON typing_status(channel_id)",
+		},
+	},
+	{
+		name: "files",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"message_id text NOT NULL",
+			"channel_id text NOT NULL",
+			"sender_id text NOT NULL",
+			"filename text NOT NULL",
+			"original_name text NOT NULL",
+			"size integer NOT NULL",
+			"mime_type text NOT NULL",
+			"storage_type text NOT NULL",
+			"storage_path text",
+			"data blob",
+			"sha256 text NOT NULL",
+			"checksum text NOT NULL",
+			"chunk_size integer DEFAULT 8192",
+			"total_chunks integer NOT NULL",
+			"uploaded_chunks integer DEFAULT 0",
+			"chunk_bitmap blob",
+			"upload_status text DEFAULT 'pending'",
+			"thumbnail blob",
+			"preview_text text",
+			"uploaded_at datetime NOT NULL",
+			"expires_at datetime NOT NULL",
+			"encrypted integer DEFAULT 1",
+			"encryption_key blob",
+			"metadata text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_files_message FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_files_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_files_sender FOREIGN KEY (sender_id) REFERENCES members(id) ON DELETE SET NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_files_message ON files(message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_files_channel ON files(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_files_sender ON files(sender_id)",
+			"CREATE INDEX IF NOT EXISTS idx_files_uploaded_at ON files(uploaded_at)",
+			"CREATE INDEX IF NOT EXISTS idx_files_expires ON files(expires_at)",
+		},
+	},
+	{
+		name: "file_chunks",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"file_id text NOT NULL",
+			"chunk_index integer NOT NULL",
+			"size integer NOT NULL",
+			"checksum text NOT NULL",
+			"uploaded integer DEFAULT 0",
+			"uploaded_at datetime NOT NULL",
+			"retry_count integer DEFAULT 0",
+			"last_attempt datetime NOT NULL",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_file_chunks_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_chunks_file ON file_chunks(file_id)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_chunks_file_chunk ON file_chunks(file_id, chunk_index)",
+		},
+	},
+	{
+		name: "audit_logs",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"channel_id text NOT NULL",
+			"type text NOT NULL",
+			"operator_id text NOT NULL",
+			"target_id text",
+			"reason text",
+			"details text",
+			"timestamp datetime NOT NULL",
+			"ip_address text",
+			"user_agent text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_audit_logs_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_audit_logs_operator FOREIGN KEY (operator_id) REFERENCES members(id)",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_audit_channel ON audit_logs(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_type ON audit_logs(type)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_operator ON audit_logs(operator_id)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_logs(timestamp)",
+		},
+	},
+	{
+		name: "mute_records",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"channel_id text NOT NULL",
+			"member_id text NOT NULL",
+			"muted_by text NOT NULL",
+			"reason text",
+			"muted_at datetime NOT NULL",
+			"duration integer",
+			"expires_at datetime",
+			"active integer DEFAULT 1",
+			"unmuted_at datetime",
+			"unmuted_by text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_mute_records_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_mute_records_member FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_mute_channel ON mute_records(channel_id)",
+			"CREATE INDEX IF NOT EXISTS idx_mute_member ON mute_records(member_id)",
+			"CREATE INDEX IF NOT EXISTS idx_mute_expires ON mute_records(expires_at)",
+			"CREATE INDEX IF NOT EXISTS idx_mute_active ON mute_records(active)",
+		},
+	},
+	{
+		name: "pinned_messages",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"channel_id text NOT NULL",
+			"message_id text NOT NULL",
+			"pinned_by text NOT NULL",
+			"reason text",
+			"pinned_at datetime NOT NULL",
+			"display_order integer DEFAULT 0",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_pinned_messages_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_pinned_messages_message FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE",
+		},
+		indexes: []string{
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_message ON pinned_messages(message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_pinned_channel ON pinned_messages(channel_id, display_order)",
+		},
+	},
+	{
+		name: "challenge_assignments",
+		columns: []string{
+			"challenge_id text NOT NULL",
+			"member_id text NOT NULL",
+			"assigned_by text NOT NULL",
+			"assigned_at datetime NOT NULL",
+			"role text NOT NULL DEFAULT 'member'",
+			"status text NOT NULL DEFAULT 'assigned'",
+			"notes text",
+		},
+		constraints: []string{
+			"PRIMARY KEY (challenge_id, member_id)",
+			"CONSTRAINT fk_challenge_assignments_member FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_challenges_assignments FOREIGN KEY (challenge_id) REFERENCES challenges(id)",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_assignments_challenge ON challenge_assignments(challenge_id)",
+			"CREATE INDEX IF NOT EXISTS idx_assignments_member ON challenge_assignments(member_id)",
+			"CREATE INDEX IF NOT EXISTS idx_assignments_status ON challenge_assignments(status)",
+		},
+	},
+	{
+		name: "challenge_progress",
+		columns: []string{
+			"id integer PRIMARY KEY AUTOINCREMENT",
+			"challenge_id text NOT NULL",
+			"member_id text NOT NULL",
+			"progress integer NOT NULL DEFAULT 0",
+			"status text NOT NULL DEFAULT 'not_started'",
+			"summary text",
+			"findings text",
+			"blockers text",
+			"updated_at datetime NOT NULL",
+			"metadata text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_challenge_progress_member FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_challenges_progress FOREIGN KEY (challenge_id) REFERENCES challenges(id)",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_progress_challenge ON challenge_progress(challenge_id)",
+			"CREATE INDEX IF NOT EXISTS idx_progress_member ON challenge_progress(member_id)",
+			"CREATE INDEX IF NOT EXISTS idx_progress_updated ON challenge_progress(updated_at)",
+		},
+	},
+	{
+		name: "challenge_submissions",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"challenge_id text NOT NULL",
+			"member_id text NOT NULL",
+			"flag text NOT NULL",
+			"submitted_at datetime NOT NULL",
+			"ip_address text",
+			"response_time integer",
+			"metadata text",
+		},
+		constraints: []string{
+			"CONSTRAINT fk_challenge_submissions_member FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE",
+			"CONSTRAINT fk_challenges_submissions FOREIGN KEY (challenge_id) REFERENCES challenges(id)",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_submissions_challenge ON challenge_submissions(challenge_id)",
+			"CREATE INDEX IF NOT EXISTS idx_submissions_member ON challenge_submissions(member_id)",
+			"CREATE INDEX IF NOT EXISTS idx_submissions_time ON challenge_submissions(submitted_at)",
+		},
+	},
+}
+
+// userBaselineSchema 用户库基线（版本 1）
+var userBaselineSchema = []baselineTable{
+	{
+		name: "user_profiles",
+		columns: []string{
+			"id text PRIMARY KEY",
+			"nickname text NOT NULL",
+			"avatar text",
+			"private_key blob NOT NULL",
+			"public_key blob NOT NULL",
+			"skills text",
+			"expertise text",
+			"bio text",
+			"theme text DEFAULT 'dark'",
+			"language text DEFAULT 'zh-CN'",
+			"auto_start integer DEFAULT 0",
+			"created_at datetime NOT NULL",
+			"updated_at datetime NOT NULL",
+		},
+	},
+	{
+		name: "recent_channels",
+		columns: []string{
+			"channel_id text PRIMARY KEY",
+			"channel_name text NOT NULL",
+			"server_address text",
+			"transport_mode text",
+			"last_joined datetime NOT NULL",
+			"pinned integer DEFAULT 0",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_recent_last_joined ON recent_channels(last_joined)",
+		},
+	},
+}
+
+// cacheBaselineSchema 缓存库基线（版本 1）
+var cacheBaselineSchema = []baselineTable{
+	{
+		name: "cache_entries",
+		columns: []string{
+			"key text PRIMARY KEY",
+			"value blob NOT NULL",
+			"expires_at datetime NOT NULL",
+			"created_at datetime NOT NULL",
+		},
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS idx_cache_expires ON cache_entries(expires_at)",
+		},
+	},
+}
+
+// applyBaseline 建立基线表结构
+// 新库直接建表；引入版本表之前创建的旧库只补齐缺失的列与索引，不改动已有列与数据。
+func applyBaseline(tx *gorm.DB, tables []baselineTable) error {
+	for _, t := range tables {
+		if !tx.Migrator().HasTable(t.name) {
+			defs := append(append([]string{}, t.columns...), t.constraints...)
+			stmt := fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", t.name, strings.Join(defs, ",\n\t"))
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to create table %s: %w", t.name, err)
+			}
+		} else {
+			for _, col := range t.columns {
+				name := strings.Fields(col)[0]
+				if tx.Migrator().HasColumn(t.name, name) {
+					continue
+				}
+				if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", t.name, addableColumn(col))).Error; err != nil {
+					return fmt.Errorf("failed to add column %s.%s: %w", t.name, name, err)
+				}
+			}
+		}
+		for _, idx := range t.indexes {
+			if err := tx.Exec(idx).Error; err != nil {
+				return fmt.Errorf("failed to create index on %s: %w", t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// addableColumn 返回可用于 ADD COLUMN 的列定义
+// SQLite 不允许向已有表添加没有默认值的 NOT NULL 列，此类列在旧库中补为可空列
+func addableColumn(def string) string {
+	if strings.Contains(def, "NOT NULL") && !strings.Contains(def, "DEFAULT") {
+		return strings.Replace(def, " NOT NULL", "", 1)
+	}
+	return def
+}
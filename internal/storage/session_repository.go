@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"time"
+
+	"crosswire/internal/models"
+)
+
+// SessionRepository 成员会话仓库
+type SessionRepository struct {
+	db *Database
+}
+
+// NewSessionRepository 创建会话仓库
+func NewSessionRepository(db *Database) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Save 保存会话（存在则覆盖）
+func (r *SessionRepository) Save(session *models.MemberSession) error {
+	return r.db.GetChannelDB().Save(session).Error
+}
+
+// GetActive 获取频道内未过期的会话
+func (r *SessionRepository) GetActive(channelID string, now time.Time) ([]*models.MemberSession, error) {
+	var sessions []*models.MemberSession
+	err := r.db.GetChannelDB().Where("channel_id = ? AND expires_at > ?", channelID, now).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete 删除会话
+func (r *SessionRepository) Delete(memberID string) error {
+	return r.db.GetChannelDB().Where("member_id = ?", memberID).Delete(&models.MemberSession{}).Error
+}
+
+// DeleteExpired 删除已过期的会话
+func (r *SessionRepository) DeleteExpired(now time.Time) error {
+	return r.db.GetChannelDB().Where("expires_at <= ?", now).Delete(&models.MemberSession{}).Error
+}
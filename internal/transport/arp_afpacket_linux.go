@@ -0,0 +1,192 @@
+//go:build linux
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"golang.org/x/sys/unix"
+)
+
+// AF_PACKET 原始套接字后端（Linux，纯 Go）
+// 套接字绑定到指定网卡并附加 BPF 过滤器（只接收本端封装方式的帧），
+// 接收设置读超时，便于 Stop 后接收循环及时退出。
+// 内核在 AF_PACKET 收包前剥离 VLAN 标签，过滤器按无标签帧的偏移匹配。
+
+const (
+	afpacketSnapLen     = 65536
+	afpacketReadTimeout = 200 * time.Millisecond
+)
+
+// afpacketHandle AF_PACKET 套接字
+type afpacketHandle struct {
+	fd       int
+	ifindex  int
+	protocol uint16
+	buf      []byte
+	readMu   sync.Mutex
+	once     sync.Once
+}
+
+// AFPacketAvailable 检查是否能创建 AF_PACKET 套接字（需要 root 或 CAP_NET_RAW）
+func AFPacketAvailable() bool {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(EtherTypeCustom)))
+	if err != nil {
+		return false
+	}
+	unix.Close(fd)
+	return true
+}
+
+// openAFPacket 在指定网卡上打开 AF_PACKET 套接字
+func openAFPacket(iface *net.Interface, framing arpFraming) (frameIO, error) {
+	if iface == nil {
+		return nil, fmt.Errorf("AF_PACKET requires a network interface")
+	}
+
+	framing = framing.orDefault()
+	protocol := afpacketProtocol(framing)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(protocol)))
+	if err != nil {
+		return nil, fmt.Errorf("打开 AF_PACKET 套接字失败（需要 root 或 CAP_NET_RAW）: %w", err)
+	}
+	h := &afpacketHandle{fd: fd, ifindex: iface.Index, protocol: protocol, buf: make([]byte, afpacketSnapLen)}
+
+	// 绑定网卡
+	addr := &unix.SockaddrLinklayer{Protocol: htons(protocol), Ifindex: iface.Index}
+	if err := unix.Bind(fd, addr); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to bind AF_PACKET socket to %s: %w", iface.Name, err)
+	}
+
+	// BPF过滤器（只接收CrossWire协议的帧）
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, framingFilter(framing)); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to attach BPF filter: %w", err)
+	}
+
+	// 混杂模式（与pcap后端一致）
+	mreq := &unix.PacketMreq{Ifindex: int32(iface.Index), Type: unix.PACKET_MR_PROMISC}
+	if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+		fmt.Printf("[ARPTransport] Warning: failed to enable promiscuous mode: %v\n", err)
+	}
+
+	// 读超时
+	tv := unix.NsecToTimeval(afpacketReadTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to set read timeout: %w", err)
+	}
+
+	fmt.Printf("[ARPTransport] Using AF_PACKET socket on %s (ifindex %d)\n", iface.Name, iface.Index)
+	return h, nil
+}
+
+// ReadPacketData 读取一个完整以太网帧
+func (h *afpacketHandle) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	h.readMu.Lock()
+	defer h.readMu.Unlock()
+
+	n, _, err := unix.Recvfrom(h.fd, h.buf, 0)
+	if err != nil {
+		if err == unix.EAGAIN || err == unix.EINTR {
+			return nil, gopacket.CaptureInfo{}, errReadTimeout
+		}
+		return nil, gopacket.CaptureInfo{}, err
+	}
+	data := append([]byte(nil), h.buf[:n]...)
+	ci := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: n, Length: n}
+	return data, ci, nil
+}
+
+// WritePacketData 发送一个完整以太网帧
+func (h *afpacketHandle) WritePacketData(data []byte) error {
+	if len(data) < 14 {
+		return fmt.Errorf("frame too short: %d bytes", len(data))
+	}
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(h.protocol),
+		Ifindex:  h.ifindex,
+		Halen:    6,
+	}
+	copy(addr.Addr[:], data[0:6])
+	return unix.Sendto(h.fd, data, 0, addr)
+}
+
+// Close 关闭套接字
+func (h *afpacketHandle) Close() {
+	h.once.Do(func() {
+		unix.Close(h.fd)
+	})
+}
+
+// afpacketProtocol 套接字协议：无 VLAN 标签的 EtherType/ARP 封装按协议号收包，其余收全部帧再由 BPF 过滤
+func afpacketProtocol(framing arpFraming) uint16 {
+	if framing.vlanID == 0 {
+		switch framing.encap {
+		case EncapEtherType:
+			return framing.etherType
+		case EncapARP:
+			return etherTypeARP
+		}
+	}
+	return unix.ETH_P_ALL
+}
+
+// bpfCond 过滤条件：16 位字段 [offset] 等于（或不大于）value
+type bpfCond struct {
+	offset uint32
+	value  uint16
+	le     bool
+}
+
+// framingFilter 构造只接受指定封装的经典 BPF 程序
+//
+//	ethertype: ldh [12] == etherType
+//	arp:       ldh [12] == 0x0806 && ldh [16] == etherType（ARP 协议类型字段）
+//	snap:      ldh [12] <= 1500 && ldh [14] == 0xAAAA && ldh [20] == etherType（SNAP 协议号）
+func framingFilter(framing arpFraming) *unix.SockFprog {
+	var conds []bpfCond
+	switch framing.encap {
+	case EncapARP:
+		conds = []bpfCond{{offset: 12, value: etherTypeARP}, {offset: 16, value: framing.etherType}}
+	case EncapSNAP:
+		conds = []bpfCond{{offset: 12, value: ethernetMTU, le: true}, {offset: 14, value: 0xAAAA}, {offset: 20, value: framing.etherType}}
+	default:
+		conds = []bpfCond{{offset: 12, value: framing.etherType}}
+	}
+	return bpfProgram(conds)
+}
+
+// bpfProgram 将条件串联为 BPF 程序：任一条件不满足即跳到 drop
+//
+//	ldh [offset]; jeq/jgt #value ...（每个条件两条指令）
+//	accept: ret #snaplen
+//	drop:   ret #0
+func bpfProgram(conds []bpfCond) *unix.SockFprog {
+	filter := make([]unix.SockFilter, 0, 2*len(conds)+2)
+	for i, c := range conds {
+		// 从本条件的跳转指令到 drop 需要跳过的指令数
+		toDrop := uint8(2*(len(conds)-i) - 1)
+		filter = append(filter, unix.SockFilter{Code: unix.BPF_LD | unix.BPF_H | unix.BPF_ABS, K: c.offset})
+		if c.le {
+			filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JGT | unix.BPF_K, Jt: toDrop, Jf: 0, K: uint32(c.value)})
+		} else {
+			filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: toDrop, K: uint32(c.value)})
+		}
+	}
+	filter = append(filter,
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: afpacketSnapLen},
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: 0},
+	)
+	return &unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+}
+
+// htons 主机序转网络序
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package transport
+
+import (
+	"bytes"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAFPacketLoopback(t *testing.T) {
+	if !AFPacketAvailable() {
+		t.Skip("AF_PACKET requires root or CAP_NET_RAW")
+	}
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface")
+	}
+
+	for _, config := range []Config{
+		{},
+		{Encapsulation: EncapSNAP},
+		{Encapsulation: EncapARP, VLANID: 10},
+	} {
+		framing, err := newARPFraming(&config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(framing.encap, func(t *testing.T) {
+			testAFPacketLoopback(t, lo, framing)
+		})
+	}
+}
+
+func testAFPacketLoopback(t *testing.T, lo *net.Interface, framing arpFraming) {
+	h, err := openAFPacket(lo, framing)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer h.Close()
+
+	src, _ := net.ParseMAC("02:00:00:00:00:01")
+	tr := &ARPTransport{framing: framing}
+	frame := &ARPFrame{
+		DstMAC:      broadcastMACForTest(),
+		SrcMAC:      src,
+		EtherType:   framing.etherType,
+		Version:     ProtocolVersion,
+		FrameType:   uint8(MessageTypeData),
+		Sequence:    7,
+		TotalChunks: 1,
+		Payload:     []byte("hello"),
+	}
+	frame.Checksum = crc32.ChecksumIEEE(frame.Payload)
+	frame.PayloadLen = uint16(len(frame.Payload))
+	if err := h.WritePacketData(tr.serializeFrame(frame)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _, err := h.ReadPacketData()
+		if err == errReadTimeout {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		got := tr.parseFrame(data)
+		if got != nil && got.Sequence == 7 && bytes.Equal(got.Payload, frame.Payload) {
+			return
+		}
+	}
+	t.Fatal("frame not received on loopback")
+}
+
+func broadcastMACForTest() net.HardwareAddr {
+	mac, _ := net.ParseMAC(BroadcastMAC)
+	return mac
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package transport
+
+import (
+	"fmt"
+	"net"
+)
+
+// AFPacketAvailable AF_PACKET 仅 Linux 可用
+func AFPacketAvailable() bool {
+	return false
+}
+
+// openAFPacket AF_PACKET 仅 Linux 可用
+func openAFPacket(iface *net.Interface, framing arpFraming) (frameIO, error) {
+	return nil, fmt.Errorf("AF_PACKET is only supported on Linux")
+}
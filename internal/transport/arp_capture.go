@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// ARP模式抓包与回放
+//
+// 抓包：设置 Config.CaptureFile 后，所有收发的 CrossWire 帧写入 pcapng 文件，可直接用 Wireshark 打开。
+// 回放：设置 Config.ReplayFile 后 Start 不打开网卡，而是把保存的 pcap/pcapng 按原顺序送入
+// parseFrame/handleFrame，重组、签名校验与上层解密与在线时一致；回放期间发送的帧只写入抓包文件。
+// 无网卡时用 Config.LocalMAC 指定本机MAC（即抓包时的本机地址）。
+
+// pcapng 段头块魔数
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// frameCapture pcapng 抓包写入器
+type frameCapture struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *pcapgo.NgWriter
+}
+
+// openFrameCapture 创建抓包文件
+func openFrameCapture(path string) (*frameCapture, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+	writer, err := pcapgo.NewNgWriter(file, layers.LinkTypeEthernet)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	return &frameCapture{file: file, writer: writer}, nil
+}
+
+// write 写入一帧（完整以太网帧）
+func (c *frameCapture) write(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writer == nil {
+		return
+	}
+	ci := gopacket.CaptureInfo{
+		Timestamp:     time.Now(),
+		CaptureLength: len(data),
+		Length:        len(data),
+	}
+	if err := c.writer.WritePacket(ci, data); err != nil {
+		fmt.Printf("[ARPTransport] Capture write failed: %v\n", err)
+	}
+}
+
+// close 刷新并关闭抓包文件
+func (c *frameCapture) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writer == nil {
+		return nil
+	}
+	err := c.writer.Flush()
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	c.writer = nil
+	return err
+}
+
+// packetReader pcap 与 pcapng 读取器的公共接口
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+}
+
+// openPacketReader 按文件头识别 pcap/pcapng 格式
+func openPacketReader(r io.Reader) (packetReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	if bytes.Equal(magic, pcapngMagic) {
+		return pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	}
+	return pcapgo.NewReader(br)
+}
+
+// capturePacket 记录一帧到抓包文件（未开启抓包时忽略）
+func (t *ARPTransport) capturePacket(data []byte) {
+	if t.capture != nil {
+		t.capture.write(data)
+	}
+}
+
+// handlePacket 处理一个原始以太网帧（在线接收与回放共用）
+func (t *ARPTransport) handlePacket(data []byte) {
+	// 解析自定义帧（封装不匹配时为 nil）
+	frame := t.parseFrame(data)
+	if frame == nil {
+		return
+	}
+
+	// 本机发出的帧已在发送时记录（pcap 会回显本机发出的帧）
+	if !bytes.Equal(frame.SrcMAC, t.localMAC) {
+		t.capturePacket(data)
+		t.metrics.RecordReceived(frame.SrcMAC.String(), len(data), 1)
+	}
+	t.handleFrame(frame)
+}
+
+// Replay 将保存的 pcap/pcapng 按顺序送入帧处理流程，返回处理的 CrossWire 帧数
+// 回放的消息跳过时间戳新鲜度检查（抓包通常早于回放），签名校验照常进行
+func (t *ARPTransport) Replay(r io.Reader) (int, error) {
+	reader, err := openPacketReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	t.replaying = true
+	defer func() { t.replaying = false }()
+
+	count := 0
+	for {
+		data, _, err := reader.ReadPacketData()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read packet %d: %w", count+1, err)
+		}
+		if t.isCrossWireFrame(data) {
+			count++
+		}
+		t.handlePacket(data)
+	}
+}
+
+// ReplayFile 回放抓包文件
+func (t *ARPTransport) ReplayFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer file.Close()
+	return t.Replay(file)
+}
+
+// startReplay 回放模式启动：不打开网卡，后台回放 Config.ReplayFile
+func (t *ARPTransport) startReplay() {
+	go func() {
+		n, err := t.ReplayFile(t.config.ReplayFile)
+		if err != nil {
+			fmt.Printf("[ARPTransport] Replay stopped after %d frames: %v\n", n, err)
+			return
+		}
+		fmt.Printf("[ARPTransport] Replayed %d frames from %s\n", n, t.config.ReplayFile)
+	}()
+}
+
+// isCrossWireFrame 检查以太网帧是否为本端封装方式下的 CrossWire 帧
+func (t *ARPTransport) isCrossWireFrame(data []byte) bool {
+	return t.framing.decode(data) >= 0
+}
@@ -0,0 +1,193 @@
+package transport
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ARP抓包解析器
+// 逐帧打印抓包中的 CrossWire 帧（序列号、分块、类型），消息收齐后尝试验签与解密，
+// 用于离线分析问题报告中附带的抓包文件
+
+// DissectOptions 解析选项
+type DissectOptions struct {
+	// Decrypt 使用频道密钥解密消息（为空时不解密）
+	Decrypt func(ciphertext []byte) ([]byte, error)
+	// ServerPublicKey 服务器公钥（可选，用于校验广播签名）
+	ServerPublicKey []byte
+	// EtherType 与 Encapsulation 为抓包时的封装方式（零值为默认 0x88B5，VLAN 标签自动识别）
+	EtherType     uint16
+	Encapsulation string
+}
+
+// Dissect 解析抓包并输出帧日志，返回解析的 CrossWire 帧数
+func Dissect(r io.Reader, w io.Writer, opts DissectOptions) (int, error) {
+	reader, err := openPacketReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	framing, err := newARPFraming(&Config{EtherType: opts.EtherType, Encapsulation: opts.Encapsulation})
+	if err != nil {
+		return 0, err
+	}
+	parser := &ARPTransport{framing: framing}
+	pending := make(map[string]*reassemblyState)
+	count := 0
+	for {
+		data, ci, err := reader.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read packet: %w", err)
+		}
+		if !parser.isCrossWireFrame(data) {
+			continue
+		}
+		count++
+
+		frame := parser.parseFrame(data)
+		if frame == nil {
+			fmt.Fprintf(w, "#%d %s <checksum mismatch>\n", count, ci.Timestamp.Format("15:04:05.000000"))
+			continue
+		}
+
+		line := fmt.Sprintf("#%d %s %s > %s %s seq=%d chunk=%d/%d len=%d",
+			count, ci.Timestamp.Format("15:04:05.000000"), frame.SrcMAC, frame.DstMAC,
+			messageTypeName(MessageType(frame.FrameType)), frame.Sequence,
+			frame.ChunkIndex, frame.TotalChunks, len(frame.Payload))
+		layout, hasFEC := parseFECLayout(frame.Reserved)
+		if hasFEC {
+			line += fmt.Sprintf(" fec=%d", layout.parity)
+			if frame.ChunkIndex >= frame.TotalChunks {
+				line += " (parity)"
+			}
+		}
+		if MessageType(frame.FrameType) == MessageTypeNACK {
+			if missing, err := decodeNACKPayload(frame.Payload); err == nil {
+				line += fmt.Sprintf(" missing=%v", missing)
+			}
+		}
+		fmt.Fprintln(w, line)
+
+		if payload, ok := dissectReassemble(pending, frame, layout, hasFEC); ok {
+			fmt.Fprintf(w, "    seq=%d complete: %s\n", frame.Sequence, describePayload(parser, payload, opts))
+		}
+	}
+
+	for key, st := range pending {
+		fmt.Fprintf(w, "incomplete %s: %d/%d chunks, missing %v\n", key, len(st.chunks), st.total, st.missingChunks(st.total))
+	}
+	return count, nil
+}
+
+// dissectReassemble 解析器内的简易重组（不发送 NACK）
+func dissectReassemble(pending map[string]*reassemblyState, frame *ARPFrame, layout fecLayout, hasFEC bool) ([]byte, bool) {
+	switch MessageType(frame.FrameType) {
+	case MessageTypeACK, MessageTypeNACK, MessageTypeDiscover:
+		return nil, false
+	}
+	if frame.TotalChunks <= 1 {
+		return frame.Payload, true
+	}
+
+	key := fmt.Sprintf("%s:%d", frame.SrcMAC, frame.Sequence)
+	st, ok := pending[key]
+	if !ok {
+		st = &reassemblyState{total: frame.TotalChunks, chunks: make(map[uint16][]byte)}
+		pending[key] = st
+	}
+	if hasFEC && st.parity == nil {
+		st.fec = layout
+		st.parity = make(map[uint16][]byte)
+	}
+	if frame.ChunkIndex >= st.total {
+		if st.parity == nil {
+			return nil, false
+		}
+		st.parity[frame.ChunkIndex] = append([]byte(nil), frame.Payload...)
+	} else {
+		st.chunks[frame.ChunkIndex] = append([]byte(nil), frame.Payload...)
+	}
+	if uint16(len(st.chunks)) < st.total && len(st.chunks)+len(st.parity) >= int(st.total) {
+		_ = st.recoverWithFEC()
+	}
+	if uint16(len(st.chunks)) < st.total {
+		return nil, false
+	}
+
+	buf := make([]byte, 0)
+	for i := uint16(0); i < st.total; i++ {
+		buf = append(buf, st.chunks[i]...)
+	}
+	delete(pending, key)
+	return buf, true
+}
+
+// describePayload 描述完整消息：签名、解密结果与消息类型
+func describePayload(parser *ARPTransport, payload []byte, opts DissectOptions) string {
+	desc := fmt.Sprintf("%d bytes", len(payload))
+	if opts.Decrypt == nil {
+		return desc
+	}
+
+	// 客户端单播为密文；服务器广播为签名载荷包裹的密文
+	if plain, err := opts.Decrypt(payload); err == nil {
+		return desc + ", decrypted: " + summarizePlaintext(plain)
+	}
+	signed := parser.parseSignedPayload(payload)
+	if signed == nil {
+		return desc + ", decrypt failed"
+	}
+	desc += ", signed"
+	if len(opts.ServerPublicKey) == ed25519.PublicKeySize {
+		if ed25519.Verify(opts.ServerPublicKey, signed.Message, signed.Signature) {
+			desc += " (verified)"
+		} else {
+			desc += " (INVALID signature)"
+		}
+	}
+	plain, err := opts.Decrypt(signed.Message)
+	if err != nil {
+		return desc + ", decrypt failed: " + err.Error()
+	}
+	return desc + ", decrypted: " + summarizePlaintext(plain)
+}
+
+// summarizePlaintext 概括明文（JSON 消息取 type 字段）
+func summarizePlaintext(plain []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(plain, &fields); err == nil {
+		if typ, ok := fields["type"].(string); ok {
+			return fmt.Sprintf("type=%s (%d bytes)", typ, len(plain))
+		}
+		return fmt.Sprintf("json (%d bytes)", len(plain))
+	}
+	if len(plain) > 48 {
+		return fmt.Sprintf("%q... (%d bytes)", plain[:48], len(plain))
+	}
+	return fmt.Sprintf("%q", plain)
+}
+
+// messageTypeName 帧类型名称
+func messageTypeName(t MessageType) string {
+	switch t {
+	case MessageTypeData:
+		return "DATA"
+	case MessageTypeACK:
+		return "ACK"
+	case MessageTypeNACK:
+		return "NACK"
+	case MessageTypeControl:
+		return "CONTROL"
+	case MessageTypeDiscover:
+		return "DISCOVER"
+	case MessageTypeAuth:
+		return "AUTH"
+	default:
+		return fmt.Sprintf("0x%02x", byte(t))
+	}
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// 原始帧收发后端
+// ARPTransport 只依赖 frameIO 收发完整以太网帧，底层可以是：
+//   - pcap：libpcap/Npcap（Windows/macOS/Linux，以 -tags nopcap 构建时不包含）
+//   - afpacket：Linux AF_PACKET 原始套接字（纯 Go，可静态编译，无需 libpcap）
+// Config.ARPBackend 为空时优先使用 pcap，pcap 不可用（未安装，或以 nopcap 标签构建）时自动改用 AF_PACKET
+
+const (
+	ARPBackendAuto     = ""         // 自动选择
+	ARPBackendPcap     = "pcap"     // libpcap/Npcap
+	ARPBackendAFPacket = "afpacket" // Linux AF_PACKET
+)
+
+// errReadTimeout 读超时（后端周期性返回，便于接收循环检查退出）
+var errReadTimeout = errors.New("frame read timeout")
+
+// frameIO 原始以太网帧收发接口
+type frameIO interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	WritePacketData(data []byte) error
+	Close()
+}
+
+// RawFramesAvailable 检查本机是否能收发原始帧（pcap 或 AF_PACKET 任一可用）
+func RawFramesAvailable() bool {
+	return PcapAvailable() || AFPacketAvailable()
+}
+
+// openFrameIO 按配置打开原始帧后端，返回实际使用的后端名
+func (t *ARPTransport) openFrameIO() (frameIO, string, error) {
+	switch t.config.ARPBackend {
+	case ARPBackendPcap:
+		handle, err := t.openPcap()
+		return handle, ARPBackendPcap, err
+	case ARPBackendAFPacket:
+		handle, err := openAFPacket(t.iface, t.framing)
+		return handle, ARPBackendAFPacket, err
+	case ARPBackendAuto:
+		if PcapAvailable() {
+			handle, err := t.openPcap()
+			if err == nil {
+				return handle, ARPBackendPcap, nil
+			}
+			fmt.Printf("[ARPTransport] pcap unavailable (%v), falling back to AF_PACKET\n", err)
+		}
+		handle, err := openAFPacket(t.iface, t.framing)
+		if err != nil {
+			return nil, "", fmt.Errorf("no raw frame backend available (pcap not available; AF_PACKET: %w)", err)
+		}
+		return handle, ARPBackendAFPacket, nil
+	default:
+		return nil, "", fmt.Errorf("unknown ARP backend: %q", t.config.ARPBackend)
+	}
+}
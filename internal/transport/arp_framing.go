@@ -0,0 +1,257 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ARP模式以太网封装
+// 参考: docs/PROTOCOL.md - 2.1.1 VLAN 与封装方式
+//
+// CrossWire 帧头与负载之前的以太网封装可配置：
+//   - ethertype（默认）：以太网头 + 自定义 EtherType（默认 0x88B5）
+//   - snap：802.3 长度字段 + LLC/SNAP 头（AA AA 03, OUI 00-00-00, 协议号为配置的 EtherType），
+//     用于只放行 802.3/LLC 帧的网络设备
+//   - arp：EtherType 0x0806 + 标准 ARP 请求体（协议类型字段为配置的 EtherType），CrossWire 帧头与负载作为尾部数据，
+//     用于只放行 IP/ARP 的网络
+//
+// 配置 VLAN ID 时在源MAC之后插入 802.1Q 标签（TPID 0x8100，TCI = 优先级<<13 | VID）。
+// 接收时带或不带 VLAN 标签的帧均接受（部分网卡/内核会剥离标签），带标签时 VID 必须与配置一致。
+
+const (
+	EncapEtherType = "ethertype" // 自定义 EtherType（默认）
+	EncapSNAP      = "snap"      // 802.3 LLC/SNAP
+	EncapARP       = "arp"       // 伪装为 ARP 请求
+
+	etherTypeVLAN   = 0x8100
+	etherTypeARP    = 0x0806
+	etherTypeMin    = 0x0600 // 小于该值的类型字段为 802.3 长度
+	maxVLANID       = 4094
+	maxVLANPriority = 7
+
+	ethernetHeaderSize = 14
+	vlanTagSize        = 4
+	snapHeaderSize     = 8  // LLC(3) + OUI(3) + 协议号(2)
+	arpBodySize        = 28 // IPv4/以太网 ARP 报文长度
+	customHeaderSize   = FrameHeaderSize - ethernetHeaderSize
+	ethernetMinFrame   = 60 // 不含 FCS 的以太网最小帧长
+	ethernetMTU        = 1500
+)
+
+// llcSNAPPrefix LLC(DSAP=AA, SSAP=AA, UI) + OUI 00-00-00
+var llcSNAPPrefix = []byte{0xAA, 0xAA, 0x03, 0x00, 0x00, 0x00}
+
+// arpFraming 以太网封装参数
+type arpFraming struct {
+	etherType    uint16
+	vlanID       uint16
+	vlanPriority uint8
+	encap        string
+}
+
+// defaultFraming 未配置时的封装（无 VLAN 标签的 0x88B5 帧）
+var defaultFraming = arpFraming{etherType: EtherTypeCustom, encap: EncapEtherType}
+
+// newARPFraming 根据配置构造并校验封装参数
+func newARPFraming(config *Config) (arpFraming, error) {
+	f := defaultFraming
+	if config == nil {
+		return f, nil
+	}
+	if config.EtherType != 0 {
+		if config.EtherType < etherTypeMin || config.EtherType == etherTypeVLAN {
+			return f, fmt.Errorf("invalid EtherType 0x%04x", config.EtherType)
+		}
+		f.etherType = config.EtherType
+	}
+	if config.VLANID > maxVLANID {
+		return f, fmt.Errorf("invalid VLAN ID %d (1-%d)", config.VLANID, maxVLANID)
+	}
+	if config.VLANPriority > maxVLANPriority {
+		return f, fmt.Errorf("invalid VLAN priority %d (0-%d)", config.VLANPriority, maxVLANPriority)
+	}
+	f.vlanID = config.VLANID
+	f.vlanPriority = config.VLANPriority
+	switch config.Encapsulation {
+	case "", EncapEtherType:
+	case EncapSNAP, EncapARP:
+		f.encap = config.Encapsulation
+	default:
+		return f, fmt.Errorf("unknown encapsulation: %q", config.Encapsulation)
+	}
+	if f.encap == EncapEtherType && f.etherType == etherTypeARP {
+		return f, fmt.Errorf("EtherType 0x%04x conflicts with ARP; use Encapsulation %q instead", f.etherType, EncapARP)
+	}
+	return f, nil
+}
+
+// orDefault 零值（未初始化的传输层，如解析器）使用默认封装
+func (f arpFraming) orDefault() arpFraming {
+	if f.etherType == 0 {
+		return defaultFraming
+	}
+	return f
+}
+
+// encapOverhead 以太网头之后、CrossWire 帧头之前的封装开销（不含 VLAN 标签）
+func (f arpFraming) encapOverhead() int {
+	switch f.encap {
+	case EncapSNAP:
+		return snapHeaderSize
+	case EncapARP:
+		return arpBodySize
+	}
+	return 0
+}
+
+// maxPayload 单帧可承载的负载大小（受 1500 字节 MTU 限制）
+func (f arpFraming) maxPayload() int {
+	n := ethernetMTU - customHeaderSize - f.orDefault().encapOverhead()
+	if n > MaxFramePayload {
+		n = MaxFramePayload
+	}
+	return n
+}
+
+// encode 封装一帧：inner 为 CrossWire 帧头 + 负载
+func (f arpFraming) encode(dst, src net.HardwareAddr, inner []byte) []byte {
+	f = f.orDefault()
+	size := ethernetHeaderSize + f.encapOverhead() + len(inner)
+	if f.vlanID != 0 {
+		size += vlanTagSize
+	}
+	buf := make([]byte, 12, size)
+	copy(buf[0:6], dst)
+	copy(buf[6:12], src)
+	if f.vlanID != 0 {
+		buf = binary.BigEndian.AppendUint16(buf, etherTypeVLAN)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(f.vlanPriority)<<13|f.vlanID)
+	}
+
+	switch f.encap {
+	case EncapSNAP:
+		// 802.3 长度字段：LLC/SNAP 头 + 数据
+		buf = binary.BigEndian.AppendUint16(buf, uint16(snapHeaderSize+len(inner)))
+		buf = append(buf, llcSNAPPrefix...)
+		buf = binary.BigEndian.AppendUint16(buf, f.etherType)
+	case EncapARP:
+		buf = binary.BigEndian.AppendUint16(buf, etherTypeARP)
+		buf = binary.BigEndian.AppendUint16(buf, 1) // HTYPE 以太网
+		buf = binary.BigEndian.AppendUint16(buf, f.etherType)
+		buf = append(buf, 6, 4)                     // HLEN, PLEN
+		buf = binary.BigEndian.AppendUint16(buf, 1) // OPER 请求
+		buf = append(buf, src[:6]...)               // SHA
+		buf = append(buf, 0, 0, 0, 0)               // SPA
+		buf = append(buf, 0, 0, 0, 0, 0, 0)         // THA
+		buf = append(buf, 0, 0, 0, 0)               // TPA
+	default:
+		buf = binary.BigEndian.AppendUint16(buf, f.etherType)
+	}
+	buf = append(buf, inner...)
+
+	// 填充到以太网最小帧长（接收方按 PayloadLen 截断）
+	for len(buf) < ethernetMinFrame {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// decode 解封装，返回 CrossWire 帧头 + 负载的起始偏移（不匹配时返回 -1）
+func (f arpFraming) decode(data []byte) int {
+	f = f.orDefault()
+	if len(data) < ethernetHeaderSize {
+		return -1
+	}
+	off := 12
+	typ := binary.BigEndian.Uint16(data[off:])
+	if typ == etherTypeVLAN {
+		if len(data) < ethernetHeaderSize+vlanTagSize {
+			return -1
+		}
+		vid := binary.BigEndian.Uint16(data[off+2:]) & 0x0fff
+		if f.vlanID != 0 && vid != f.vlanID {
+			return -1
+		}
+		off += vlanTagSize
+		typ = binary.BigEndian.Uint16(data[off:])
+	}
+	off += 2
+
+	switch f.encap {
+	case EncapSNAP:
+		if typ >= etherTypeMin || len(data) < off+snapHeaderSize ||
+			!bytes.Equal(data[off:off+6], llcSNAPPrefix) ||
+			binary.BigEndian.Uint16(data[off+6:]) != f.etherType {
+			return -1
+		}
+		off += snapHeaderSize
+	case EncapARP:
+		if typ != etherTypeARP || len(data) < off+arpBodySize ||
+			binary.BigEndian.Uint16(data[off+2:]) != f.etherType {
+			return -1
+		}
+		off += arpBodySize
+	default:
+		if typ != f.etherType {
+			return -1
+		}
+	}
+
+	if len(data) < off+customHeaderSize {
+		return -1
+	}
+	return off
+}
+
+// pcapFilter pcap 过滤表达式（VLAN 标签由 decode 校验）
+func (f arpFraming) pcapFilter() string {
+	f = f.orDefault()
+	switch f.encap {
+	case EncapSNAP:
+		return "ether[12:2] <= 1500 or vlan"
+	case EncapARP:
+		return "arp or (vlan and arp)"
+	}
+	return fmt.Sprintf("ether proto 0x%04x or (vlan and ether proto 0x%04x)", f.etherType, f.etherType)
+}
+
+// advertise 宣告中携带的封装参数：ethertype=0x88b5;vlan=0;priority=0;encap=ethertype
+func (f arpFraming) advertise() string {
+	f = f.orDefault()
+	return fmt.Sprintf("ethertype=0x%04x;vlan=%d;priority=%d;encap=%s", f.etherType, f.vlanID, f.vlanPriority, f.encap)
+}
+
+// parseFramingAdvert 解析宣告中的封装参数（键值对，未知键保留）
+func parseFramingAdvert(s string) map[string]string {
+	out := make(map[string]string)
+	for _, kv := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// matchesAdvert 宣告的封装参数是否与本端一致（缺失字段视为默认值）
+func (f arpFraming) matchesAdvert(meta map[string]string) bool {
+	f = f.orDefault()
+	if v, ok := meta["ethertype"]; ok {
+		et, err := strconv.ParseUint(strings.TrimPrefix(v, "0x"), 16, 16)
+		if err != nil || uint16(et) != f.etherType {
+			return false
+		}
+	}
+	if v, ok := meta["vlan"]; ok && v != strconv.Itoa(int(f.vlanID)) {
+		return false
+	}
+	if v, ok := meta["encap"]; ok && v != f.encap {
+		return false
+	}
+	return true
+}
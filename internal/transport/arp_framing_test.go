@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+)
+
+func testFrame(payload []byte) *ARPFrame {
+	src, _ := net.ParseMAC("02:00:00:00:00:01")
+	f := &ARPFrame{
+		DstMAC:      broadcastMACForFraming(),
+		SrcMAC:      src,
+		Version:     ProtocolVersion,
+		FrameType:   uint8(MessageTypeData),
+		Sequence:    42,
+		TotalChunks: 1,
+		Payload:     payload,
+	}
+	f.Checksum = crc32.ChecksumIEEE(f.Payload)
+	f.PayloadLen = uint16(len(f.Payload))
+	return f
+}
+
+func broadcastMACForFraming() net.HardwareAddr {
+	mac, _ := net.ParseMAC(BroadcastMAC)
+	return mac
+}
+
+func TestFramingRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		typ    uint16 // 期望的类型字段（snap 为长度，不检查）
+	}{
+		{"default", Config{}, EtherTypeCustom},
+		{"custom ethertype", Config{EtherType: 0x88B6}, 0x88B6},
+		{"vlan", Config{VLANID: 100, VLANPriority: 5}, etherTypeVLAN},
+		{"snap", Config{Encapsulation: EncapSNAP}, 0},
+		{"snap vlan", Config{Encapsulation: EncapSNAP, VLANID: 7}, etherTypeVLAN},
+		{"arp", Config{Encapsulation: EncapARP}, etherTypeARP},
+		{"arp vlan", Config{Encapsulation: EncapARP, VLANID: 4094, EtherType: 0x9000}, etherTypeVLAN},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			framing, err := newARPFraming(&tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := &ARPTransport{framing: framing}
+
+			for _, size := range []int{3, framing.maxPayload()} {
+				payload := bytes.Repeat([]byte{0x5a}, size)
+				data := tr.serializeFrame(testFrame(payload))
+				if len(data) < ethernetMinFrame {
+					t.Fatalf("frame not padded: %d bytes", len(data))
+				}
+				if len(data) > ethernetHeaderSize+vlanTagSize+ethernetMTU {
+					t.Fatalf("frame exceeds MTU: %d bytes", len(data))
+				}
+				if tc.typ != 0 && binary.BigEndian.Uint16(data[12:]) != tc.typ {
+					t.Fatalf("type field = 0x%04x, want 0x%04x", binary.BigEndian.Uint16(data[12:]), tc.typ)
+				}
+				if framing.vlanID != 0 {
+					tci := binary.BigEndian.Uint16(data[14:])
+					if tci&0x0fff != framing.vlanID || uint8(tci>>13) != framing.vlanPriority {
+						t.Fatalf("TCI = 0x%04x", tci)
+					}
+				}
+
+				got := tr.parseFrame(data)
+				if got == nil || got.Sequence != 42 || !bytes.Equal(got.Payload, payload) {
+					t.Fatalf("round trip failed for %d-byte payload", size)
+				}
+			}
+
+			// 默认封装的接收方不接受其他 EtherType/封装方式的帧
+			if framing.encap != EncapEtherType || framing.etherType != EtherTypeCustom {
+				if (&ARPTransport{}).parseFrame(tr.serializeFrame(testFrame([]byte("x")))) != nil {
+					t.Fatal("frame accepted under the default encapsulation")
+				}
+			}
+		})
+	}
+}
+
+func TestFramingVLANMismatch(t *testing.T) {
+	tagged, _ := newARPFraming(&Config{VLANID: 10})
+	data := (&ARPTransport{framing: tagged}).serializeFrame(testFrame([]byte("hi")))
+
+	// 未配置 VLAN 时接受任意标签；配置了不同 VLAN 时丢弃
+	if (&ARPTransport{}).parseFrame(data) == nil {
+		t.Fatal("untagged receiver should accept tagged frame")
+	}
+	other, _ := newARPFraming(&Config{VLANID: 11})
+	if (&ARPTransport{framing: other}).parseFrame(data) != nil {
+		t.Fatal("frame from another VLAN accepted")
+	}
+
+	// 标签被剥离的帧仍被接受
+	stripped := append(append([]byte(nil), data[:12]...), data[16:]...)
+	if (&ARPTransport{framing: tagged}).parseFrame(stripped) == nil {
+		t.Fatal("stripped frame rejected")
+	}
+}
+
+func TestFramingConfigValidation(t *testing.T) {
+	for _, c := range []Config{
+		{EtherType: 0x05DC},
+		{EtherType: etherTypeVLAN},
+		{EtherType: etherTypeARP},
+		{VLANID: 4095},
+		{VLANPriority: 8},
+		{Encapsulation: "gre"},
+	} {
+		if _, err := newARPFraming(&c); err == nil {
+			t.Errorf("config %+v accepted", c)
+		}
+	}
+}
+
+func TestFramingAdvert(t *testing.T) {
+	f, _ := newARPFraming(&Config{EtherType: 0x88B6, VLANID: 20, Encapsulation: EncapSNAP})
+	meta := parseFramingAdvert(f.advertise())
+	if meta["ethertype"] != "0x88b6" || meta["vlan"] != "20" || meta["encap"] != EncapSNAP {
+		t.Fatalf("advert = %v", meta)
+	}
+	if !f.matchesAdvert(meta) {
+		t.Fatal("framing does not match its own advert")
+	}
+	if defaultFraming.matchesAdvert(meta) {
+		t.Fatal("default framing matches snap advert")
+	}
+}
@@ -0,0 +1,265 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ARP模式发送节流与拥塞控制
+// 参考: docs/PROTOCOL.md - 2.3.2 流量控制
+//
+// 所有原始帧经过 pacer 发送：
+//   - 令牌桶限制发送速率，避免分块背靠背发送打满交换机与网卡队列
+//   - 大块传输（分块数超过 BulkChunkThreshold 的消息，主要是文件分块）受拥塞窗口限制：
+//     每帧占用窗口一个 RTT，收到 ACK 或 RTT 内未收到丢失信号即释放并加性增大窗口（AIMD），
+//     收到 NACK 时窗口减半（每个 RTT 至多一次）
+//   - 控制帧（ACK/NACK/发现/认证/控制）与聊天等小消息不受窗口限制，且优先于大块传输获取令牌
+
+const (
+	DefaultPacingRate    = 8 * 1024 * 1024 // 默认发送速率（字节/秒）
+	DefaultPacingBurst   = 64 * 1024       // 默认令牌桶容量（字节）
+	DefaultInitialWindow = 16              // 默认初始拥塞窗口（帧）
+	DefaultMaxWindow     = 256             // 默认最大拥塞窗口（帧）
+	MinWindow            = 2               // 最小拥塞窗口（帧）
+	DefaultPacingRTT     = 20 * time.Millisecond
+	BulkChunkThreshold   = 4 // 分块数超过该值的消息视为大块传输
+	minPacingHold        = 5 * time.Millisecond
+	maxPacingWait        = 10 * time.Millisecond
+)
+
+// framePriority 发送优先级
+type framePriority int
+
+const (
+	priorityInteractive framePriority = iota // 控制帧与小消息
+	priorityBulk                             // 大块传输
+)
+
+// framePriorityOf 根据帧类型与所属消息的分块数判断优先级
+func framePriorityOf(frame *ARPFrame) framePriority {
+	if MessageType(frame.FrameType) != MessageTypeData {
+		return priorityInteractive
+	}
+	if frame.TotalChunks > BulkChunkThreshold {
+		return priorityBulk
+	}
+	return priorityInteractive
+}
+
+// pacer 令牌桶 + AIMD 拥塞窗口
+type pacer struct {
+	mu sync.Mutex
+
+	// 令牌桶（字节）
+	rate   float64 // 每秒补充的令牌数，<=0 不限速
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// 拥塞窗口（帧，仅约束大块传输）
+	cwnd         float64
+	ssthresh     float64
+	maxCwnd      float64
+	inflight     []time.Time // 在途帧的发送时间（FIFO）
+	srtt         time.Duration
+	lastDecrease time.Time
+
+	// 等待中的高优先级发送数（大块传输让行）
+	waitingInteractive int
+}
+
+// newPacer 根据配置创建 pacer
+func newPacer(config *Config) *pacer {
+	rate, burst := DefaultPacingRate, DefaultPacingBurst
+	initial, maxWindow := DefaultInitialWindow, DefaultMaxWindow
+	if config != nil {
+		if config.PacingRate != 0 {
+			rate = config.PacingRate
+		}
+		if config.PacingBurst > 0 {
+			burst = config.PacingBurst
+		}
+		if config.InitialWindow > 0 {
+			initial = config.InitialWindow
+		}
+		if config.MaxWindow > 0 {
+			maxWindow = config.MaxWindow
+		}
+	}
+	if burst < MaxFramePayload+FrameHeaderSize {
+		burst = MaxFramePayload + FrameHeaderSize
+	}
+	if initial < MinWindow {
+		initial = MinWindow
+	}
+	if maxWindow < initial {
+		maxWindow = initial
+	}
+	return &pacer{
+		rate:     float64(rate),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+		cwnd:     float64(initial),
+		ssthresh: float64(maxWindow),
+		maxCwnd:  float64(maxWindow),
+		srtt:     DefaultPacingRTT,
+	}
+}
+
+// wait 阻塞直到允许发送 size 字节的帧
+func (p *pacer) wait(ctx context.Context, size int, prio framePriority) error {
+	p.mu.Lock()
+	if prio == priorityInteractive {
+		p.waitingInteractive++
+	}
+	for {
+		now := time.Now()
+		p.refill(now)
+		p.expire(now)
+
+		delay := p.delay(size, prio, now)
+		if delay <= 0 {
+			if p.rate > 0 {
+				p.tokens -= float64(size)
+			}
+			if prio == priorityBulk {
+				p.inflight = append(p.inflight, now)
+			} else {
+				p.waitingInteractive--
+			}
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if prio == priorityInteractive {
+				p.mu.Lock()
+				p.waitingInteractive--
+				p.mu.Unlock()
+			}
+			return ctx.Err()
+		case <-timer.C:
+		}
+		p.mu.Lock()
+	}
+}
+
+// delay 计算还需等待多久（0 表示可立即发送，调用方持有锁）
+func (p *pacer) delay(size int, prio framePriority, now time.Time) time.Duration {
+	var d time.Duration
+	if prio == priorityBulk {
+		if p.waitingInteractive > 0 {
+			return time.Millisecond
+		}
+		if len(p.inflight) >= int(p.cwnd) {
+			d = p.inflight[0].Add(p.hold()).Sub(now)
+			if d <= 0 {
+				d = time.Millisecond
+			}
+		}
+	}
+	if p.rate > 0 && p.tokens < float64(size) {
+		if td := time.Duration((float64(size) - p.tokens) / p.rate * float64(time.Second)); td > d {
+			d = td
+		}
+		if d <= 0 {
+			d = time.Millisecond
+		}
+	}
+	if d > maxPacingWait {
+		d = maxPacingWait
+	}
+	return d
+}
+
+// refill 补充令牌（调用方持有锁）
+func (p *pacer) refill(now time.Time) {
+	if p.rate <= 0 {
+		return
+	}
+	p.tokens += now.Sub(p.last).Seconds() * p.rate
+	if p.tokens > p.burst {
+		p.tokens = p.burst
+	}
+	p.last = now
+}
+
+// expire 释放已在途超过一个 RTT 且未收到丢失信号的帧（调用方持有锁）
+func (p *pacer) expire(now time.Time) {
+	cutoff := now.Add(-p.hold())
+	n := 0
+	for n < len(p.inflight) && p.inflight[n].Before(cutoff) {
+		n++
+	}
+	if n > 0 {
+		p.inflight = p.inflight[n:]
+		p.grow(n)
+	}
+}
+
+// hold 帧占用窗口的时长
+func (p *pacer) hold() time.Duration {
+	if p.srtt < minPacingHold {
+		return minPacingHold
+	}
+	return p.srtt
+}
+
+// grow 加性增大窗口：慢启动阶段每帧 +1，拥塞避免阶段每个窗口 +1（调用方持有锁）
+func (p *pacer) grow(frames int) {
+	for i := 0; i < frames; i++ {
+		if p.cwnd < p.ssthresh {
+			p.cwnd++
+		} else {
+			p.cwnd += 1 / p.cwnd
+		}
+	}
+	if p.cwnd > p.maxCwnd {
+		p.cwnd = p.maxCwnd
+	}
+}
+
+// onAck 收到ACK：释放对应帧并更新 RTT 估计
+func (p *pacer) onAck(frames int, rtt time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if rtt > 0 {
+		p.srtt = (7*p.srtt + rtt) / 8
+	}
+	if frames > len(p.inflight) {
+		frames = len(p.inflight)
+	}
+	p.inflight = p.inflight[frames:]
+	p.grow(frames)
+}
+
+// onLoss 收到丢失信号（NACK）：乘性减小窗口
+func (p *pacer) onLoss() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastDecrease) < p.hold() {
+		return
+	}
+	p.lastDecrease = now
+	p.cwnd /= 2
+	if p.cwnd < MinWindow {
+		p.cwnd = MinWindow
+	}
+	p.ssthresh = p.cwnd
+}
+
+// window 当前拥塞窗口（帧）
+func (p *pacer) window() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return int(p.cwnd)
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPacerTokenBucketLimitsRate(t *testing.T) {
+	const frame = 1000
+	p := newPacer(&Config{PacingRate: 100 * frame, PacingBurst: 2 * MaxFramePayload})
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if err := p.wait(context.Background(), frame, priorityInteractive); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// 桶内约 3 帧，其余 17 帧按 100 帧/秒补充
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Fatalf("20 frames sent in %v, pacing not applied", elapsed)
+	}
+}
+
+func TestPacerWindowAIMD(t *testing.T) {
+	p := newPacer(&Config{PacingRate: -1, InitialWindow: 8, MaxWindow: 64})
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		if err := p.wait(ctx, MaxFramePayload, priorityBulk); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// 窗口已满：下一帧需等待在途帧释放
+	ctx2, cancel := context.WithTimeout(ctx, 2*time.Millisecond)
+	defer cancel()
+	if err := p.wait(ctx2, MaxFramePayload, priorityBulk); err == nil {
+		t.Fatal("bulk frame sent beyond congestion window")
+	}
+
+	// 控制帧不受窗口限制
+	if err := p.wait(ctx, 64, priorityInteractive); err != nil {
+		t.Fatalf("interactive wait: %v", err)
+	}
+
+	p.onAck(8, 0)
+	if w := p.window(); w != 16 {
+		t.Fatalf("window after ack = %d, want 16 (slow start)", w)
+	}
+	p.onLoss()
+	if w := p.window(); w != 8 {
+		t.Fatalf("window after loss = %d, want 8", w)
+	}
+	// 同一 RTT 内的重复丢失信号只减半一次
+	p.onLoss()
+	if w := p.window(); w != 8 {
+		t.Fatalf("window after repeated loss = %d, want 8", w)
+	}
+}
+
+func TestFramePriority(t *testing.T) {
+	cases := []struct {
+		frame *ARPFrame
+		want  framePriority
+	}{
+		{&ARPFrame{FrameType: uint8(MessageTypeNACK), TotalChunks: 1}, priorityInteractive},
+		{&ARPFrame{FrameType: uint8(MessageTypeControl), TotalChunks: 40}, priorityInteractive},
+		{&ARPFrame{FrameType: uint8(MessageTypeData), TotalChunks: 1}, priorityInteractive},
+		{&ARPFrame{FrameType: uint8(MessageTypeData), TotalChunks: 40}, priorityBulk},
+	}
+	for i, c := range cases {
+		if got := framePriorityOf(c.frame); got != c.want {
+			t.Fatalf("case %d: priority = %d, want %d", i, got, c.want)
+		}
+	}
+}
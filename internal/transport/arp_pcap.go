@@ -0,0 +1,99 @@
+//go:build !nopcap
+
+package transport
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// PcapAvailable 检查本机是否可用pcap（已安装驱动且有权限枚举设备）
+func PcapAvailable() bool {
+	devices, err := pcap.FindAllDevs()
+	return err == nil && len(devices) > 0
+}
+
+// openPcap 打开pcap句柄（原始以太网包）
+// 注意：需要管理员/root权限
+func (t *ARPTransport) openPcap() (frameIO, error) {
+	// 在 Windows 上，需要将 net.Interface 名称转换为 pcap 设备名
+	// 因为 net.InterfaceByName 使用友好名称（如"以太网"），
+	// 而 pcap.OpenLive 需要设备名（如"\Device\NPF_{GUID}"）
+	pcapDeviceName, err := t.findPcapDeviceName()
+	if err != nil {
+		return nil, fmt.Errorf("无法找到对应的 pcap 设备: %w", err)
+	}
+
+	fmt.Printf("[ARPTransport] Using pcap device: '%s'\n", pcapDeviceName)
+	fmt.Printf("[ARPTransport] Interface MAC: %s, IP: %s\n", t.localMAC, t.localIP)
+
+	handle, err := pcap.OpenLive(
+		pcapDeviceName,
+		65536, // 快照长度
+		true,  // 混杂模式
+		pcap.BlockForever,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("打开网络接口失败（可能需要管理员权限）。pcap设备: '%s'。错误: %w", pcapDeviceName, err)
+	}
+
+	// 设置BPF过滤器（只接收本端封装方式的帧）
+	filter := t.framing.pcapFilter()
+	if err := handle.SetBPFFilter(filter); err != nil {
+		handle.Close()
+		return nil, fmt.Errorf("failed to set BPF filter: %w", err)
+	}
+	return handle, nil
+}
+
+// findPcapDeviceName 查找与当前 net.Interface 对应的 pcap 设备名
+// 在 Windows 上，通过匹配 IP 地址或 MAC 地址来找到对应的 pcap 设备
+func (t *ARPTransport) findPcapDeviceName() (string, error) {
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		return "", fmt.Errorf("failed to enumerate pcap devices: %w", err)
+	}
+
+	fmt.Printf("[ARPTransport] Searching for pcap device matching interface '%s' (MAC: %s, IP: %s)\n",
+		t.config.Interface, t.localMAC, t.localIP)
+	fmt.Printf("[ARPTransport] Available pcap devices:\n")
+
+	var candidateDevice *pcap.Interface
+
+	for _, dev := range devices {
+		fmt.Printf("  Device: %s\n", dev.Name)
+		fmt.Printf("    Description: %s\n", dev.Description)
+
+		// 优先通过 IP 地址匹配
+		if t.localIP != nil {
+			for _, addr := range dev.Addresses {
+				fmt.Printf("    Address: %v\n", addr.IP)
+				if addr.IP != nil && addr.IP.Equal(t.localIP) {
+					fmt.Printf("  ✓ Matched by IP address: %s\n", t.localIP)
+					return dev.Name, nil
+				}
+			}
+		}
+
+		// 如果没找到，记录第一个候选设备（有 IPv4 地址的）
+		if candidateDevice == nil && len(dev.Addresses) > 0 {
+			for _, addr := range dev.Addresses {
+				if addr.IP != nil && addr.IP.To4() != nil {
+					candidateDevice = &dev
+					break
+				}
+			}
+		}
+	}
+
+	// 如果通过 IP 没找到，但有候选设备，使用候选设备
+	if candidateDevice != nil {
+		fmt.Printf("[ARPTransport] Warning: No exact IP match found. Using first available device: %s\n",
+			candidateDevice.Name)
+		return candidateDevice.Name, nil
+	}
+
+	return "", fmt.Errorf("no matching pcap device found for interface '%s' (MAC: %s, IP: %s)",
+		t.config.Interface, t.localMAC, t.localIP)
+}
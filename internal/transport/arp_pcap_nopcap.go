@@ -0,0 +1,15 @@
+//go:build nopcap
+
+package transport
+
+import "fmt"
+
+// PcapAvailable 以 nopcap 标签构建时不包含 pcap 后端
+func PcapAvailable() bool {
+	return false
+}
+
+// openPcap 以 nopcap 标签构建时不包含 pcap 后端
+func (t *ARPTransport) openPcap() (frameIO, error) {
+	return nil, fmt.Errorf("pcap support not included (built with -tags nopcap)")
+}
@@ -0,0 +1,422 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sync"
+	"time"
+)
+
+// ARP模式选择性重传（NACK）
+// 参考: docs/PROTOCOL.md - 2.3 可靠性保证
+//
+// 接收方在重组时发现缺失分块（出现跳号，或分块停止到达后仍不完整）即向发送方回复 NACK，
+// 负载列出缺失的分块索引；发送方只重发这些分块：
+//   - 服务端广播：保留最近 RetransmitBufferSize 条广播的帧，收到客户端 NACK 后重新广播缺失分块
+//   - 客户端单播：使用待确认帧，收到服务端 NACK 后单播缺失分块；等待 ACK 超时只补发最后一块作为探测，
+//     促使服务端回复 ACK（已收齐）或 NACK（仍有缺失）
+//
+// NACK 帧只有 CRC 校验，网段内任何主机都能伪造。为避免伪造的 NACK 压低发送窗口或反复触发重发：
+// 每个来源在 NACKRateWindow 内最多处理 MaxNACKsPerWindow 个 NACK；只有指向仍在重传缓冲/待确认中的
+// 序列与分块的 NACK 才计入丢包并触发重发；客户端只接受服务器发来的 NACK。
+
+const (
+	RetransmitBufferSize = 256                    // 服务端重传缓冲（最近N条广播）
+	NACKDelay            = 30 * time.Millisecond  // 分块停止到达多久后发送 NACK
+	NACKInterval         = 150 * time.Millisecond // 同一序列两次 NACK 的最小间隔
+	MaxNACKAttempts      = 5                      // 同一序列最多 NACK 次数
+	RetransmitHoldoff    = 20 * time.Millisecond  // 同一分块两次重发的最小间隔（合并多个客户端的 NACK）
+	ReassemblyTimeout    = 10 * time.Second       // 未完成的重组状态保留时长
+	CompletedTTL         = 2 * time.Minute        // 已完成序列的保留时长（过滤迟到/重发的分块）
+	NACKRateWindow       = time.Second            // NACK 限速窗口
+	MaxNACKsPerWindow    = 20                     // 每个来源在一个窗口内最多处理的 NACK 数
+)
+
+// maxNACKIndexes 单个 NACK 最多列出的分块数（按容量最小的 ARP 封装计算，任何封装下均可单帧发送）
+const maxNACKIndexes = (ethernetMTU - customHeaderSize - arpBodySize - 2) / 2
+
+// retransmitEntry 重传缓冲中的一条广播
+type retransmitEntry struct {
+	frames   []*ARPFrame
+	lastSent []time.Time
+}
+
+// retransmitBuffer 有界重传缓冲（按序列号，超出容量时淘汰最旧的）
+type retransmitBuffer struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[uint32]*retransmitEntry
+	order    []uint32
+}
+
+// newRetransmitBuffer 创建重传缓冲
+func newRetransmitBuffer(capacity int) *retransmitBuffer {
+	if capacity <= 0 {
+		capacity = RetransmitBufferSize
+	}
+	return &retransmitBuffer{
+		capacity: capacity,
+		entries:  make(map[uint32]*retransmitEntry, capacity),
+		order:    make([]uint32, 0, capacity),
+	}
+}
+
+// put 保存一条广播的全部帧
+func (b *retransmitBuffer) put(seq uint32, frames []*ARPFrame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.entries[seq]; !exists {
+		if len(b.order) >= b.capacity {
+			delete(b.entries, b.order[0])
+			b.order = b.order[1:]
+		}
+		b.order = append(b.order, seq)
+	}
+	now := time.Now()
+	lastSent := make([]time.Time, len(frames))
+	for i := range lastSent {
+		lastSent[i] = now
+	}
+	b.entries[seq] = &retransmitEntry{frames: frames, lastSent: lastSent}
+}
+
+// take 取出需要重发的分块（跳过刚重发过的分块）
+func (b *retransmitBuffer) take(seq uint32, indexes []uint16) []*ARPFrame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[seq]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	frames := make([]*ARPFrame, 0, len(indexes))
+	for _, idx := range indexes {
+		if int(idx) >= len(entry.frames) || now.Sub(entry.lastSent[idx]) < RetransmitHoldoff {
+			continue
+		}
+		entry.lastSent[idx] = now
+		frames = append(frames, entry.frames[idx])
+	}
+	return frames
+}
+
+// frames 返回序列的分块数（序列不在缓冲中时为 0）
+func (b *retransmitBuffer) frames(seq uint32) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if entry, ok := b.entries[seq]; ok {
+		return len(entry.frames)
+	}
+	return 0
+}
+
+// len 缓冲中的广播条数
+func (b *retransmitBuffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.order)
+}
+
+// nackLimiter 按来源限制 NACK 处理频率（固定窗口计数）
+type nackLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*nackWindow
+}
+
+// nackWindow 一个来源的当前窗口
+type nackWindow struct {
+	start time.Time
+	count int
+}
+
+// newNACKLimiter 创建 NACK 限速器
+func newNACKLimiter() *nackLimiter {
+	return &nackLimiter{windows: make(map[string]*nackWindow)}
+}
+
+// allow 判断是否处理来自 src 的 NACK
+func (l *nackLimiter) allow(src string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[src]
+	if !ok || now.Sub(w.start) >= NACKRateWindow {
+		if !ok && len(l.windows) >= 1024 {
+			// 伪造大量来源地址时清理过期窗口，保持有界
+			for key, old := range l.windows {
+				if now.Sub(old.start) >= NACKRateWindow {
+					delete(l.windows, key)
+				}
+			}
+		}
+		l.windows[src] = &nackWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= MaxNACKsPerWindow {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// encodeNACKPayload 编码 NACK 负载：count(2) + index(2)*count
+func encodeNACKPayload(indexes []uint16) []byte {
+	if len(indexes) > maxNACKIndexes {
+		indexes = indexes[:maxNACKIndexes]
+	}
+	buf := make([]byte, 2+2*len(indexes))
+	binary.BigEndian.PutUint16(buf[0:2], uint16(len(indexes)))
+	for i, idx := range indexes {
+		binary.BigEndian.PutUint16(buf[2+2*i:], idx)
+	}
+	return buf
+}
+
+// decodeNACKPayload 解码 NACK 负载
+func decodeNACKPayload(data []byte) ([]uint16, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("nack payload too short")
+	}
+	count := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+2*count {
+		return nil, fmt.Errorf("nack payload truncated: want %d indexes", count)
+	}
+	indexes := make([]uint16, count)
+	for i := range indexes {
+		indexes[i] = binary.BigEndian.Uint16(data[2+2*i:])
+	}
+	return indexes, nil
+}
+
+// missingChunks 计算缺失的分块索引（upTo 之前，upTo 为 total 时检查全部）
+func (st *reassemblyState) missingChunks(upTo uint16) []uint16 {
+	missing := make([]uint16, 0)
+	for i := uint16(0); i < upTo && i < st.total; i++ {
+		if _, ok := st.chunks[i]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// ===== 接收方：缺失检测与 NACK =====
+
+// noteMissing 记录检测到缺失的分块，返回首次记录的数量（重复 NACK 不重复计入丢包）
+func (st *reassemblyState) noteMissing(indexes []uint16) int {
+	if st.missed == nil {
+		st.missed = make(map[uint16]bool, len(indexes))
+	}
+	n := 0
+	for _, idx := range indexes {
+		if !st.missed[idx] {
+			st.missed[idx] = true
+			n++
+		}
+	}
+	return n
+}
+
+// shouldNACK 判断是否需要为该帧所在的序列请求重传
+// 只对发给自己的数据负责：客户端只关心服务器的广播，服务端只关心发给自己的帧
+func (t *ARPTransport) shouldNACK(frame *ARPFrame) bool {
+	if bytes.Equal(frame.SrcMAC, t.localMAC) {
+		return false
+	}
+	if t.mode == "client" {
+		return t.serverMAC != nil && bytes.Equal(frame.SrcMAC, t.serverMAC)
+	}
+	broadcastMAC, _ := net.ParseMAC(BroadcastMAC)
+	return bytes.Equal(frame.DstMAC, t.localMAC) || bytes.Equal(frame.DstMAC, broadcastMAC)
+}
+
+// sendNACK 向发送方请求重传缺失分块
+func (t *ARPTransport) sendNACK(dst net.HardwareAddr, seq uint32, missing []uint16) {
+	if len(missing) == 0 {
+		return
+	}
+	f := &ARPFrame{
+		DstMAC:      dst,
+		SrcMAC:      t.localMAC,
+		EtherType:   t.framing.etherType,
+		Version:     uint8(ProtocolVersion),
+		FrameType:   uint8(MessageTypeNACK),
+		Sequence:    seq,
+		TotalChunks: 1,
+		ChunkIndex:  0,
+		Payload:     encodeNACKPayload(missing),
+	}
+	f.Checksum = crc32.ChecksumIEEE(f.Payload)
+	f.PayloadLen = uint16(len(f.Payload))
+	_ = t.sendRawFrame(f)
+}
+
+// nackLoop 定期检查停滞的重组状态：发送 NACK 请求尾部缺失的分块，清理超时的状态
+func (t *ARPTransport) nackLoop() {
+	ticker := time.NewTicker(NACKDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.checkStalledReassembly()
+		}
+	}
+}
+
+// checkStalledReassembly 检查停滞的重组状态
+func (t *ARPTransport) checkStalledReassembly() {
+	type nackRequest struct {
+		dst     net.HardwareAddr
+		seq     uint32
+		missing []uint16
+	}
+	var requests []nackRequest
+
+	now := time.Now()
+	t.reassemblyMu.Lock()
+	for key, st := range t.reassembly {
+		if now.Sub(st.updatedAt) > ReassemblyTimeout {
+			delete(t.reassembly, key)
+			t.metrics.RecordLostIn(st.srcMAC, st.noteMissing(st.missingChunks(st.total)))
+			t.metrics.RecordReassemblyTimeout(st.srcMAC)
+			continue
+		}
+		if !st.nackable || st.nacks >= MaxNACKAttempts {
+			continue
+		}
+		if now.Sub(st.updatedAt) < NACKDelay || now.Sub(st.lastNACK) < NACKInterval {
+			continue
+		}
+		st.nacks++
+		st.lastNACK = now
+		missing := st.missingChunks(st.total)
+		t.metrics.RecordLostIn(st.srcMAC, st.noteMissing(missing))
+		requests = append(requests, nackRequest{dst: st.src, seq: st.sequence, missing: missing})
+	}
+	t.reassemblyMu.Unlock()
+
+	for _, r := range requests {
+		t.sendNACK(r.dst, r.seq, r.missing)
+	}
+}
+
+// markCompleted 记录已完成重组的序列
+func (t *ARPTransport) markCompleted(key string) {
+	t.completedMu.Lock()
+	t.completed[key] = time.Now()
+	t.completedMu.Unlock()
+}
+
+// isCompleted 检查序列是否已完成重组（迟到或因他人 NACK 重发的分块）
+func (t *ARPTransport) isCompleted(key string) bool {
+	t.completedMu.Lock()
+	defer t.completedMu.Unlock()
+	_, ok := t.completed[key]
+	return ok
+}
+
+// cleanupCompleted 清理过期的已完成序列
+func (t *ARPTransport) cleanupCompleted() {
+	t.completedMu.Lock()
+	defer t.completedMu.Unlock()
+
+	cutoff := time.Now().Add(-CompletedTTL)
+	for key, ts := range t.completed {
+		if ts.Before(cutoff) {
+			delete(t.completed, key)
+		}
+	}
+}
+
+// ===== 发送方：选择性重发 =====
+
+// handleNACK 处理 NACK 帧
+// 只有指向未完成序列的有效分块才计入丢包（降低发送窗口）并重发，其余 NACK 直接丢弃
+func (t *ARPTransport) handleNACK(frame *ARPFrame) {
+	if !bytes.Equal(frame.DstMAC, t.localMAC) {
+		return
+	}
+	if t.mode == "client" && (t.serverMAC == nil || !bytes.Equal(frame.SrcMAC, t.serverMAC)) {
+		return
+	}
+	indexes, err := decodeNACKPayload(frame.Payload)
+	if err != nil || len(indexes) == 0 {
+		return
+	}
+	src := frame.SrcMAC.String()
+	if !t.nackLimit.allow(src, time.Now()) {
+		return
+	}
+
+	var frames []*ARPFrame
+	lost := 0
+	if t.mode == "server" {
+		// 服务端：从重传缓冲中重新广播缺失分块
+		total := t.retransmits.frames(frame.Sequence)
+		for _, idx := range indexes {
+			if int(idx) < total {
+				lost++
+			}
+		}
+		if lost > 0 {
+			frames = t.retransmits.take(frame.Sequence, indexes)
+		}
+	} else {
+		// 客户端：单播重发待确认消息的缺失分块
+		t.ackMu.Lock()
+		if p, ok := t.pendingAcks[frame.Sequence]; ok {
+			for _, idx := range indexes {
+				if int(idx) < len(p.frames) {
+					frames = append(frames, p.frames[idx])
+				}
+			}
+		}
+		t.ackMu.Unlock()
+		lost = len(frames)
+	}
+	if lost == 0 {
+		return
+	}
+
+	t.loss.addLost(lost)
+	t.pacer.onLoss()
+	t.metrics.RecordLostOut(src, lost)
+	t.statsMu.Lock()
+	t.stats.FramesLost += uint64(lost)
+	t.statsMu.Unlock()
+
+	for _, f := range frames {
+		_ = t.sendRawFrame(f)
+	}
+	if len(frames) > 0 {
+		t.statsMu.Lock()
+		t.stats.Retries += uint64(len(frames))
+		t.statsMu.Unlock()
+		t.metrics.RecordRetry(src, len(frames))
+	}
+}
+
+// sendAck 回复 ACK（服务端确认客户端单播）
+func (t *ARPTransport) sendAck(dst net.HardwareAddr, seq uint32) {
+	ack := &ARPFrame{
+		DstMAC:      dst,
+		SrcMAC:      t.localMAC,
+		EtherType:   t.framing.etherType,
+		Version:     uint8(ProtocolVersion),
+		FrameType:   uint8(MessageTypeACK),
+		Sequence:    seq,
+		TotalChunks: 1,
+		ChunkIndex:  0,
+		Payload:     nil,
+	}
+	ack.Checksum = crc32.ChecksumIEEE([]byte{})
+	ack.PayloadLen = 0
+	_ = t.sendRawFrame(ack)
+}
@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNACKPayloadRoundTrip(t *testing.T) {
+	indexes := []uint16{0, 3, 7, 65535}
+	got, err := decodeNACKPayload(encodeNACKPayload(indexes))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != len(indexes) {
+		t.Fatalf("got %v, want %v", got, indexes)
+	}
+	for i := range indexes {
+		if got[i] != indexes[i] {
+			t.Fatalf("got %v, want %v", got, indexes)
+		}
+	}
+
+	if _, err := decodeNACKPayload([]byte{0, 2, 0, 1}); err == nil {
+		t.Fatal("expected error for truncated payload")
+	}
+}
+
+func TestRetransmitBufferBounded(t *testing.T) {
+	b := newRetransmitBuffer(2)
+	frames := []*ARPFrame{{ChunkIndex: 0}, {ChunkIndex: 1}, {ChunkIndex: 2}}
+	b.put(1, frames)
+	b.put(2, frames)
+	b.put(3, frames)
+
+	if b.len() != 2 {
+		t.Fatalf("len = %d, want 2", b.len())
+	}
+	if got := b.take(1, []uint16{0}); len(got) != 0 {
+		t.Fatal("evicted sequence should not be retransmitted")
+	}
+
+	// 刚发送过的分块在 RetransmitHoldoff 内不重发
+	if got := b.take(3, []uint16{1}); len(got) != 0 {
+		t.Fatal("chunk sent within holdoff should be skipped")
+	}
+	time.Sleep(RetransmitHoldoff + 5*time.Millisecond)
+	got := b.take(3, []uint16{1, 2, 9})
+	if len(got) != 2 || got[0].ChunkIndex != 1 || got[1].ChunkIndex != 2 {
+		t.Fatalf("take returned %d frames, want chunks 1 and 2", len(got))
+	}
+}
+
+func TestReassemblyMissingChunks(t *testing.T) {
+	st := &reassemblyState{total: 5, chunks: map[uint16][]byte{0: nil, 2: nil, 4: nil}}
+	if got := st.missingChunks(3); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("missingChunks(3) = %v, want [1]", got)
+	}
+	if got := st.missingChunks(st.total); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("missingChunks(total) = %v, want [1 3]", got)
+	}
+}
+
+func TestNACKLimiter(t *testing.T) {
+	l := newNACKLimiter()
+	now := time.Now()
+	for i := 0; i < MaxNACKsPerWindow; i++ {
+		if !l.allow("a", now) {
+			t.Fatalf("NACK %d should be allowed", i)
+		}
+	}
+	if l.allow("a", now) {
+		t.Fatal("NACK over the per-window limit should be dropped")
+	}
+	if !l.allow("b", now) {
+		t.Fatal("other sources must not share the limit")
+	}
+	if !l.allow("a", now.Add(NACKRateWindow)) {
+		t.Fatal("limit should reset in the next window")
+	}
+}
+
+func TestHandleNACKOnlyCountsOutstanding(t *testing.T) {
+	const serverMAC = "02:00:00:00:00:01"
+	srv := NewARPTransport()
+	if err := srv.Init(&Config{LocalMAC: serverMAC, CaptureFile: filepath.Join(t.TempDir(), "nack.pcapng")}); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	defer srv.Stop()
+	srv.SetMode("server")
+	srv.retransmits.put(7, []*ARPFrame{{Sequence: 7, ChunkIndex: 0, TotalChunks: 2}, {Sequence: 7, ChunkIndex: 1, TotalChunks: 2}})
+
+	peer, _ := net.ParseMAC("02:00:00:00:00:02")
+	nack := func(seq uint32, indexes ...uint16) *ARPFrame {
+		return &ARPFrame{DstMAC: srv.localMAC, SrcMAC: peer, Sequence: seq, Payload: encodeNACKPayload(indexes)}
+	}
+
+	// 未知序列与越界分块不计入丢包
+	srv.handleNACK(nack(99, 0, 1))
+	srv.handleNACK(nack(7, 5))
+	if lost := srv.GetStats().FramesLost; lost != 0 {
+		t.Fatalf("FramesLost = %d after bogus NACKs, want 0", lost)
+	}
+
+	srv.handleNACK(nack(7, 1, 5))
+	if lost := srv.GetStats().FramesLost; lost != 1 {
+		t.Fatalf("FramesLost = %d, want 1", lost)
+	}
+
+	// 超出限速的 NACK 直接丢弃
+	for i := 0; i < 2*MaxNACKsPerWindow; i++ {
+		srv.handleNACK(nack(7, 0))
+	}
+	if lost := srv.GetStats().FramesLost; lost > MaxNACKsPerWindow {
+		t.Fatalf("FramesLost = %d, rate limit not applied", lost)
+	}
+}
@@ -7,17 +7,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"hash/crc32"
 	"net"
 	"sync"
 	"time"
-
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
 )
 
 // ARPTransport ARP传输层实现（服务器签名广播模式）
@@ -28,10 +23,12 @@ type ARPTransport struct {
 	mode   string // "server" or "client"
 
 	// 网卡
-	handle   *pcap.Handle
+	handle   frameIO
+	backend  string // 实际使用的原始帧后端
 	iface    *net.Interface
 	localMAC net.HardwareAddr
 	localIP  net.IP
+	framing  arpFraming // 以太网封装（VLAN/EtherType/SNAP/ARP）
 
 	// 服务器信息
 	serverMAC     net.HardwareAddr
@@ -67,6 +64,27 @@ type ARPTransport struct {
 	reassemblyMu sync.Mutex
 	reassembly   map[string]*reassemblyState
 
+	// 已完成重组的序列（过滤迟到或重发的分块）
+	completedMu sync.Mutex
+	completed   map[string]time.Time
+
+	// 重传缓冲（服务端广播，按 NACK 选择性重发）
+	retransmits *retransmitBuffer
+	nackLimit   *nackLimiter
+
+	// 丢包率估计（决定前向纠错的冗余比例）
+	loss lossEstimator
+
+	// 发送节流与拥塞控制
+	pacer *pacer
+
+	// 按对端统计（键为对端MAC）
+	metrics *PeerMetrics
+
+	// 抓包与回放
+	capture   *frameCapture
+	replaying bool
+
 	// ACK等待与重传（仅客户端单播使用）
 	ackMu       sync.Mutex
 	pendingAcks map[uint32]*pendingAck
@@ -84,12 +102,26 @@ type reassemblyState struct {
 	createdAt time.Time
 	updatedAt time.Time
 	srcMAC    string
+
+	// 选择性重传
+	sequence uint32
+	src      net.HardwareAddr
+	maxIndex uint16          // 已收到的最大分块索引
+	nackable bool            // 是否由本端负责请求重传
+	nacks    int             // 已发送的 NACK 次数
+	lastNACK time.Time       // 最近一次 NACK 时间
+	missed   map[uint16]bool // 检测到缺失过的分块（丢包统计去重）
+
+	// 前向纠错
+	fec    fecLayout
+	parity map[uint16][]byte // 校验分块（索引从 total 开始）
 }
 
 // 待确认发送项
 type pendingAck struct {
 	ch     chan struct{}
 	frames []*ARPFrame
+	sentAt time.Time
 }
 
 // ARPFrame ARP帧结构
@@ -122,10 +154,28 @@ type SignedPayload struct {
 	Timestamp int64  `json:"timestamp"` // 时间戳
 }
 
+func init() {
+	MustRegister(Registration{
+		Mode:        TransportModeARP,
+		DisplayName: "ARP",
+		Description: "原始以太网帧（自定义EtherType），需要pcap或AF_PACKET（Linux）与管理员权限",
+		New:         func() Transport { return NewARPTransport() },
+		Capabilities: Capabilities{
+			Unicast:       true,
+			Broadcast:     true,
+			FileTransfer:  true,
+			Discovery:     true,
+			MTU:           MaxFramePayload,
+			NeedInterface: true,
+		},
+	})
+}
+
 // NewARPTransport 创建ARP传输层
 func NewARPTransport() *ARPTransport {
 	return &ARPTransport{
 		seenMsgs: make(map[string]time.Time),
+		metrics:  NewPeerMetrics(),
 	}
 }
 
@@ -137,16 +187,45 @@ func (t *ARPTransport) Init(config *Config) error {
 		return fmt.Errorf("config cannot be nil")
 	}
 
-	if config.Interface == "" {
+	if config.Interface == "" && config.LocalMAC == "" {
 		return fmt.Errorf("interface name is required for ARP transport")
 	}
 
+	framing, err := newARPFraming(config)
+	if err != nil {
+		return err
+	}
+
 	t.config = config
+	t.framing = framing
 	t.ctx, t.cancel = context.WithCancel(context.Background())
 	t.stats.StartTime = time.Now()
 	t.reassembly = make(map[string]*reassemblyState)
+	t.completed = make(map[string]time.Time)
+	t.retransmits = newRetransmitBuffer(RetransmitBufferSize)
+	t.nackLimit = newNACKLimiter()
+	t.pacer = newPacer(config)
 	t.pendingAcks = make(map[uint32]*pendingAck)
 
+	if config.CaptureFile != "" {
+		capture, err := openFrameCapture(config.CaptureFile)
+		if err != nil {
+			return err
+		}
+		t.capture = capture
+		fmt.Printf("[ARPTransport] Capturing frames to %s\n", config.CaptureFile)
+	}
+
+	// 离线（回放）模式：使用指定的本机MAC，不访问网卡
+	if config.Interface == "" {
+		mac, err := net.ParseMAC(config.LocalMAC)
+		if err != nil {
+			return fmt.Errorf("invalid local MAC address: %w", err)
+		}
+		t.localMAC = mac
+		return nil
+	}
+
 	// 获取网卡信息
 	fmt.Printf("[ARPTransport] Init: Looking for interface '%s'\n", config.Interface)
 
@@ -167,6 +246,13 @@ func (t *ARPTransport) Init(config *Config) error {
 
 	t.iface = iface
 	t.localMAC = iface.HardwareAddr
+	if config.LocalMAC != "" {
+		mac, err := net.ParseMAC(config.LocalMAC)
+		if err != nil {
+			return fmt.Errorf("invalid local MAC address: %w", err)
+		}
+		t.localMAC = mac
+	}
 
 	// 获取本地IP
 	addrs, err := iface.Addrs()
@@ -191,57 +277,6 @@ func (t *ARPTransport) Init(config *Config) error {
 	return nil
 }
 
-// findPcapDeviceName 查找与当前 net.Interface 对应的 pcap 设备名
-// 在 Windows 上，通过匹配 IP 地址或 MAC 地址来找到对应的 pcap 设备
-func (t *ARPTransport) findPcapDeviceName() (string, error) {
-	devices, err := pcap.FindAllDevs()
-	if err != nil {
-		return "", fmt.Errorf("failed to enumerate pcap devices: %w", err)
-	}
-
-	fmt.Printf("[ARPTransport] Searching for pcap device matching interface '%s' (MAC: %s, IP: %s)\n",
-		t.config.Interface, t.localMAC, t.localIP)
-	fmt.Printf("[ARPTransport] Available pcap devices:\n")
-
-	var candidateDevice *pcap.Interface
-
-	for _, dev := range devices {
-		fmt.Printf("  Device: %s\n", dev.Name)
-		fmt.Printf("    Description: %s\n", dev.Description)
-
-		// 优先通过 IP 地址匹配
-		if t.localIP != nil {
-			for _, addr := range dev.Addresses {
-				fmt.Printf("    Address: %v\n", addr.IP)
-				if addr.IP != nil && addr.IP.Equal(t.localIP) {
-					fmt.Printf("  ✓ Matched by IP address: %s\n", t.localIP)
-					return dev.Name, nil
-				}
-			}
-		}
-
-		// 如果没找到，记录第一个候选设备（有 IPv4 地址的）
-		if candidateDevice == nil && len(dev.Addresses) > 0 {
-			for _, addr := range dev.Addresses {
-				if addr.IP != nil && addr.IP.To4() != nil {
-					candidateDevice = &dev
-					break
-				}
-			}
-		}
-	}
-
-	// 如果通过 IP 没找到，但有候选设备，使用候选设备
-	if candidateDevice != nil {
-		fmt.Printf("[ARPTransport] Warning: No exact IP match found. Using first available device: %s\n",
-			candidateDevice.Name)
-		return candidateDevice.Name, nil
-	}
-
-	return "", fmt.Errorf("no matching pcap device found for interface '%s' (MAC: %s, IP: %s)",
-		t.config.Interface, t.localMAC, t.localIP)
-}
-
 // Start 启动传输层
 func (t *ARPTransport) Start() error {
 	if t.started {
@@ -252,35 +287,23 @@ func (t *ARPTransport) Start() error {
 		return fmt.Errorf("transport not initialized")
 	}
 
-	// 在 Windows 上，需要将 net.Interface 名称转换为 pcap 设备名
-	// 因为 net.InterfaceByName 使用友好名称（如"以太网"），
-	// 而 pcap.OpenLive 需要设备名（如"\Device\NPF_{GUID}"）
-	pcapDeviceName, err := t.findPcapDeviceName()
-	if err != nil {
-		return fmt.Errorf("无法找到对应的 pcap 设备: %w", err)
+	// 回放模式：不打开网卡
+	if t.config.ReplayFile != "" {
+		t.started = true
+		go t.cleanupLoop()
+		go t.nackLoop()
+		t.startReplay()
+		fmt.Printf("ARP transport replaying %s (%s)\n", t.config.ReplayFile, t.localMAC)
+		return nil
 	}
 
-	fmt.Printf("[ARPTransport] Using pcap device: '%s'\n", pcapDeviceName)
-	fmt.Printf("[ARPTransport] Interface MAC: %s, IP: %s\n", t.localMAC, t.localIP)
-
-	// 打开pcap句柄（原始以太网包）
-	// 注意：需要管理员/root权限
-	handle, err := pcap.OpenLive(
-		pcapDeviceName,
-		65536, // 快照长度
-		true,  // 混杂模式
-		pcap.BlockForever,
-	)
+	// 打开原始帧后端（pcap 或 AF_PACKET）
+	handle, backend, err := t.openFrameIO()
 	if err != nil {
-		return fmt.Errorf("打开网络接口失败（可能需要管理员权限）。pcap设备: '%s'。错误: %w", pcapDeviceName, err)
+		return err
 	}
 	t.handle = handle
-
-	// 设置BPF过滤器（只接收CrossWire协议的帧）
-	filter := fmt.Sprintf("ether proto 0x%04x", EtherTypeCustom)
-	if err := handle.SetBPFFilter(filter); err != nil {
-		return fmt.Errorf("failed to set BPF filter: %w", err)
-	}
+	t.backend = backend
 
 	t.started = true
 
@@ -290,14 +313,23 @@ func (t *ARPTransport) Start() error {
 	// 启动清理协程（清理过期的seenMsgs）
 	go t.cleanupLoop()
 
-	fmt.Printf("ARP transport started on %s (%s)\n",
-		t.config.Interface, t.localMAC)
+	// 启动缺失分块检测（NACK）
+	go t.nackLoop()
+
+	fmt.Printf("ARP transport started on %s (%s, %s)\n",
+		t.config.Interface, t.localMAC, backend)
 
 	return nil
 }
 
 // Stop 停止传输层
 func (t *ARPTransport) Stop() error {
+	if t.capture != nil {
+		if err := t.capture.close(); err != nil {
+			fmt.Printf("[ARPTransport] Failed to close capture: %v\n", err)
+		}
+	}
+
 	if !t.started {
 		return nil
 	}
@@ -396,14 +428,14 @@ func (t *ARPTransport) sendToServer(msg *Message) error {
 	}
 
 	// 分块
-	chunks := t.chunkBytes(msg.Payload, MaxFramePayload)
+	chunks := t.chunkBytes(msg.Payload, t.framing.maxPayload())
 	seq := t.nextSequence()
 	frames := make([]*ARPFrame, 0, len(chunks))
 	for i, p := range chunks {
 		f := &ARPFrame{
 			DstMAC:      dstMAC,
 			SrcMAC:      t.localMAC,
-			EtherType:   EtherTypeCustom,
+			EtherType:   t.framing.etherType,
 			Version:     uint8(ProtocolVersion),
 			FrameType:   uint8(msg.Type),
 			Sequence:    seq,
@@ -453,13 +485,12 @@ func (t *ARPTransport) sendToServer(msg *Message) error {
 				t.unregisterPendingAck(seq)
 				return fmt.Errorf("ack timeout for seq=%d", seq)
 			}
-			// 重传所有分块
-			for _, f := range frames {
-				_ = t.sendRawFrame(f)
-			}
+			// 只补发最后一块作为探测：服务端已收齐则回复ACK，否则回复NACK列出缺失分块
+			_ = t.sendRawFrame(frames[len(frames)-1])
 			t.statsMu.Lock()
 			t.stats.Retries++
 			t.statsMu.Unlock()
+			t.metrics.RecordRetry(t.peerKey(dstMAC), 1)
 			retries--
 		case <-t.ctx.Done():
 			t.unregisterPendingAck(seq)
@@ -494,13 +525,14 @@ func (t *ARPTransport) signAndBroadcast(msg *Message) error {
 	// 构造广播帧
 	broadcastMAC, _ := net.ParseMAC(BroadcastMAC)
 	// 分块广播
-	chunks := t.chunkBytes(payloadBytes, MaxFramePayload)
+	chunks := t.chunkBytes(payloadBytes, t.framing.maxPayload())
 	seq := t.nextSequence()
+	frames := make([]*ARPFrame, 0, len(chunks))
 	for i, p := range chunks {
 		frame := &ARPFrame{
 			DstMAC:      broadcastMAC,
 			SrcMAC:      t.localMAC,
-			EtherType:   EtherTypeCustom,
+			EtherType:   t.framing.etherType,
 			Version:     uint8(ProtocolVersion),
 			FrameType:   uint8(msg.Type),
 			Sequence:    seq,
@@ -510,6 +542,18 @@ func (t *ARPTransport) signAndBroadcast(msg *Message) error {
 		}
 		frame.Checksum = crc32.ChecksumIEEE(frame.Payload)
 		frame.PayloadLen = uint16(len(frame.Payload))
+		frames = append(frames, frame)
+	}
+
+	// 前向纠错：追加校验帧
+	if t.config.FECEnabled {
+		frames = t.appendParityFrames(frames, chunks)
+	}
+
+	// 保留到重传缓冲，供客户端 NACK 时选择性重发
+	t.retransmits.put(seq, frames)
+
+	for _, frame := range frames {
 		if err := t.sendRawFrame(frame); err != nil {
 			return err
 		}
@@ -522,18 +566,29 @@ func (t *ARPTransport) sendRawFrame(frame *ARPFrame) error {
 	// 序列化帧
 	packet := t.serializeFrame(frame)
 
-	// 发送
-	err := t.handle.WritePacketData(packet)
-	if err != nil {
+	// 节流：等待令牌与拥塞窗口
+	if err := t.pacer.wait(t.ctx, len(packet), framePriorityOf(frame)); err != nil {
 		return fmt.Errorf("failed to send frame: %w", err)
 	}
 
+	// 发送（回放/离线模式只记录到抓包文件）
+	t.capturePacket(packet)
+	if t.handle != nil {
+		if err := t.handle.WritePacketData(packet); err != nil {
+			return fmt.Errorf("failed to send frame: %w", err)
+		}
+	} else if t.capture == nil {
+		return fmt.Errorf("failed to send frame: transport not started")
+	}
+
 	// 更新统计
 	t.statsMu.Lock()
 	t.stats.BytesSent += uint64(len(packet))
 	t.stats.MessagesSent++
 	t.stats.LastActivity = time.Now()
 	t.statsMu.Unlock()
+	t.loss.addSent(1)
+	t.metrics.RecordSent(t.peerKey(frame.DstMAC), len(packet), 1)
 
 	return nil
 }
@@ -541,39 +596,24 @@ func (t *ARPTransport) sendRawFrame(frame *ARPFrame) error {
 // receiveLoop 接收循环
 // 参考: docs/ARP_BROADCAST_MODE.md - 3. 消息接收
 func (t *ARPTransport) receiveLoop() {
-	packetSource := gopacket.NewPacketSource(t.handle, t.handle.LinkType())
-
 	for {
 		select {
 		case <-t.ctx.Done():
 			return
-		case packet := <-packetSource.Packets():
-			if packet == nil {
-				continue
-			}
-
-			// 解析以太网层
-			ethLayer := packet.Layer(layers.LayerTypeEthernet)
-			if ethLayer == nil {
-				continue
-			}
-
-			eth, _ := ethLayer.(*layers.Ethernet)
-
-			// 检查EtherType
-			if eth.EthernetType != layers.EthernetType(EtherTypeCustom) {
-				continue
-			}
+		default:
+		}
 
-			// 解析自定义帧
-			frame := t.parseFrame(packet.Data())
-			if frame == nil {
-				continue
+		data, _, err := t.handle.ReadPacketData()
+		if err != nil {
+			if err != errReadTimeout {
+				// 句柄关闭或临时错误：稍后重试，由 ctx 决定退出
+				time.Sleep(10 * time.Millisecond)
 			}
-
-			// 处理帧
-			t.handleFrame(frame)
+			continue
 		}
+
+		// 处理帧
+		t.handlePacket(data)
 	}
 }
 
@@ -588,6 +628,12 @@ func (t *ARPTransport) handleFrame(frame *ARPFrame) {
 		return
 	}
 
+	// NACK帧（请求重传缺失分块）
+	if MessageType(frame.FrameType) == MessageTypeNACK {
+		t.handleNACK(frame)
+		return
+	}
+
 	// 发现/宣告帧（简易）
 	if MessageType(frame.FrameType) == MessageTypeDiscover {
 		if t.mode == "server" {
@@ -622,13 +668,14 @@ func (t *ARPTransport) handleFrame(frame *ARPFrame) {
 		// 验证Ed25519签名
 		if len(t.serverPubKey) == ed25519.PublicKeySize {
 			if !ed25519.Verify(ed25519.PublicKey(t.serverPubKey), signedPayload.Message, signedPayload.Signature) {
+				t.metrics.RecordSignatureFailure(frame.SrcMAC.String())
 				fmt.Println("Invalid signature, possible attack!")
 				return
 			}
 		}
 
-		// 验证时间戳（防重放）
-		if !t.validateTimestamp(signedPayload.Timestamp) {
+		// 验证时间戳（防重放；回放抓包时跳过）
+		if !t.replaying && !t.validateTimestamp(signedPayload.Timestamp) {
 			fmt.Println("Message too old, possible replay attack")
 			return
 		}
@@ -714,20 +761,7 @@ func (t *ARPTransport) handleFrame(frame *ARPFrame) {
 		if MessageType(frame.FrameType) == MessageTypeData || MessageType(frame.FrameType) == MessageTypeControl || MessageType(frame.FrameType) == MessageTypeAuth {
 			// 只对单播帧回复ACK（目标是服务器自己的MAC，而非广播）
 			if bytes.Equal(frame.DstMAC, t.localMAC) {
-				ack := &ARPFrame{
-					DstMAC:      frame.SrcMAC,
-					SrcMAC:      t.localMAC,
-					EtherType:   EtherTypeCustom,
-					Version:     uint8(ProtocolVersion),
-					FrameType:   uint8(MessageTypeACK),
-					Sequence:    frame.Sequence,
-					TotalChunks: 1,
-					ChunkIndex:  0,
-					Payload:     nil,
-				}
-				ack.Checksum = crc32.ChecksumIEEE([]byte{})
-				ack.PayloadLen = 0
-				_ = t.sendRawFrame(ack)
+				t.sendAck(frame.SrcMAC, frame.Sequence)
 			}
 		}
 	}
@@ -799,6 +833,7 @@ func (t *ARPTransport) cleanupLoop() {
 			return
 		case <-ticker.C:
 			t.cleanupSeenMessages()
+			t.cleanupCompleted()
 		}
 	}
 }
@@ -816,40 +851,27 @@ func (t *ARPTransport) cleanupSeenMessages() {
 	}
 }
 
-// serializeFrame 序列化帧
+// serializeFrame 序列化帧（以太网封装见 arp_framing.go）
 func (t *ARPTransport) serializeFrame(frame *ARPFrame) []byte {
-	// 以太网头部（通过gopacket构造）
-	ethLayer := &layers.Ethernet{
-		SrcMAC:       frame.SrcMAC,
-		DstMAC:       frame.DstMAC,
-		EthernetType: layers.EthernetType(frame.EtherType),
-	}
-
 	// 自定义头部
-	header := make([]byte, FrameHeaderSize-14) // 减去以太网头部
-	header[0] = frame.Version
-	header[1] = frame.FrameType
-	binary.BigEndian.PutUint32(header[2:6], frame.Sequence)
-	binary.BigEndian.PutUint16(header[6:8], frame.TotalChunks)
-	binary.BigEndian.PutUint16(header[8:10], frame.ChunkIndex)
-	binary.BigEndian.PutUint16(header[10:12], frame.PayloadLen)
-	binary.BigEndian.PutUint32(header[12:16], frame.Checksum)
-	binary.BigEndian.PutUint32(header[16:20], frame.Reserved)
-
-	// 序列化以太网层
-	buffer := gopacket.NewSerializeBuffer()
-	opts := gopacket.SerializeOptions{}
-	gopacket.SerializeLayers(buffer, opts,
-		ethLayer,
-		gopacket.Payload(append(header, frame.Payload...)),
-	)
-
-	return buffer.Bytes()
+	buf := make([]byte, customHeaderSize, customHeaderSize+len(frame.Payload))
+	buf[0] = frame.Version
+	buf[1] = frame.FrameType
+	binary.BigEndian.PutUint32(buf[2:6], frame.Sequence)
+	binary.BigEndian.PutUint16(buf[6:8], frame.TotalChunks)
+	binary.BigEndian.PutUint16(buf[8:10], frame.ChunkIndex)
+	binary.BigEndian.PutUint16(buf[10:12], frame.PayloadLen)
+	binary.BigEndian.PutUint32(buf[12:16], frame.Checksum)
+	binary.BigEndian.PutUint32(buf[16:20], frame.Reserved)
+	buf = append(buf, frame.Payload...)
+
+	return t.framing.encode(frame.DstMAC, frame.SrcMAC, buf)
 }
 
-// parseFrame 解析帧
+// parseFrame 解析帧（封装不匹配或校验失败时返回 nil）
 func (t *ARPTransport) parseFrame(data []byte) *ARPFrame {
-	if len(data) < FrameHeaderSize {
+	off := t.framing.decode(data)
+	if off < 0 {
 		return nil
 	}
 
@@ -858,21 +880,25 @@ func (t *ARPTransport) parseFrame(data []byte) *ARPFrame {
 	// 以太网头部
 	frame.DstMAC = net.HardwareAddr(data[0:6])
 	frame.SrcMAC = net.HardwareAddr(data[6:12])
-	frame.EtherType = binary.BigEndian.Uint16(data[12:14])
+	frame.EtherType = t.framing.orDefault().etherType
 
 	// 自定义头部
-	frame.Version = data[14]
-	frame.FrameType = data[15]
-	frame.Sequence = binary.BigEndian.Uint32(data[16:20])
-	frame.TotalChunks = binary.BigEndian.Uint16(data[20:22])
-	frame.ChunkIndex = binary.BigEndian.Uint16(data[22:24])
-	frame.PayloadLen = binary.BigEndian.Uint16(data[24:26])
-	frame.Checksum = binary.BigEndian.Uint32(data[26:30])
-	frame.Reserved = binary.BigEndian.Uint32(data[30:34])
-
-	// 负载
-	if len(data) > FrameHeaderSize {
-		frame.Payload = data[FrameHeaderSize:]
+	header := data[off : off+customHeaderSize]
+	frame.Version = header[0]
+	frame.FrameType = header[1]
+	frame.Sequence = binary.BigEndian.Uint32(header[2:6])
+	frame.TotalChunks = binary.BigEndian.Uint16(header[6:8])
+	frame.ChunkIndex = binary.BigEndian.Uint16(header[8:10])
+	frame.PayloadLen = binary.BigEndian.Uint16(header[10:12])
+	frame.Checksum = binary.BigEndian.Uint32(header[12:16])
+	frame.Reserved = binary.BigEndian.Uint32(header[16:20])
+
+	// 负载（按 PayloadLen 截断：短帧发送时会被填充到以太网最小帧长 60 字节）
+	if rest := data[off+customHeaderSize:]; len(rest) > 0 {
+		frame.Payload = rest
+		if int(frame.PayloadLen) < len(frame.Payload) {
+			frame.Payload = frame.Payload[:frame.PayloadLen]
+		}
 	}
 
 	// 验证校验和
@@ -966,7 +992,7 @@ func (t *ARPTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
 	f := &ARPFrame{
 		DstMAC:      broadcastMAC,
 		SrcMAC:      t.localMAC,
-		EtherType:   EtherTypeCustom,
+		EtherType:   t.framing.etherType,
 		Version:     uint8(ProtocolVersion),
 		FrameType:   uint8(MessageTypeDiscover),
 		Sequence:    seq,
@@ -1018,11 +1044,29 @@ func (t *ARPTransport) GetMode() TransportMode {
 	return TransportModeARP
 }
 
+// PeerMetrics 按对端统计
+func (t *ARPTransport) PeerMetrics() *PeerMetrics {
+	return t.metrics
+}
+
+// peerKey 目标MAC对应的统计键（广播帧记在 BroadcastPeer 下）
+func (t *ARPTransport) peerKey(mac net.HardwareAddr) string {
+	broadcastMAC, _ := net.ParseMAC(BroadcastMAC)
+	if bytes.Equal(mac, broadcastMAC) {
+		return BroadcastPeer
+	}
+	return mac.String()
+}
+
 // GetStats 获取传输统计
 func (t *ARPTransport) GetStats() *TransportStats {
 	t.statsMu.RLock()
 	defer t.statsMu.RUnlock()
 	stats := t.stats
+	stats.LossRate = t.loss.rate()
+	if t.pacer != nil {
+		stats.SendWindow = t.pacer.window()
+	}
 	return &stats
 }
 
@@ -1070,11 +1114,25 @@ func (t *ARPTransport) chunkBytes(data []byte, chunkSize int) [][]byte {
 }
 
 // tryReassemble 根据 Sequence/ChunkIndex 重组完整负载
+// 分块出现跳号时立即向发送方 NACK 缺失的分块；尾部缺失由 nackLoop 在分块停止到达后补发 NACK
 func (t *ARPTransport) tryReassemble(frame *ARPFrame) ([]byte, bool) {
+	key := fmt.Sprintf("%s:%d", frame.SrcMAC.String(), frame.Sequence)
+	if t.isCompleted(key) {
+		// 已完成的序列：服务端对重复的单播再次确认（上次ACK可能丢失）
+		if t.mode == "server" && bytes.Equal(frame.DstMAC, t.localMAC) {
+			t.sendAck(frame.SrcMAC, frame.Sequence)
+		}
+		return nil, false
+	}
 	if frame.TotalChunks <= 1 {
+		t.markCompleted(key)
 		return frame.Payload, true
 	}
-	key := fmt.Sprintf("%s:%d", frame.SrcMAC.String(), frame.Sequence)
+	layout, hasFEC := parseFECLayout(frame.Reserved)
+	if frame.ChunkIndex >= frame.TotalChunks && (!hasFEC || int(frame.ChunkIndex) >= int(frame.TotalChunks)+layout.parity) {
+		return nil, false
+	}
+
 	t.reassemblyMu.Lock()
 	st, ok := t.reassembly[key]
 	if !ok {
@@ -1084,15 +1142,50 @@ func (t *ARPTransport) tryReassemble(frame *ARPFrame) ([]byte, bool) {
 			createdAt: time.Now(),
 			updatedAt: time.Now(),
 			srcMAC:    frame.SrcMAC.String(),
+			sequence:  frame.Sequence,
+			src:       append(net.HardwareAddr(nil), frame.SrcMAC...),
+			nackable:  t.shouldNACK(frame),
 		}
 		t.reassembly[key] = st
 	}
-	st.chunks[frame.ChunkIndex] = append([]byte(nil), frame.Payload...)
+	if hasFEC && st.parity == nil {
+		st.fec = layout
+		st.parity = make(map[uint16][]byte, layout.parity)
+	}
 	st.updatedAt = time.Now()
+	if frame.ChunkIndex >= st.total {
+		st.parity[frame.ChunkIndex] = append([]byte(nil), frame.Payload...)
+	} else {
+		st.chunks[frame.ChunkIndex] = append([]byte(nil), frame.Payload...)
+	}
+
+	// 跳号：请求重传跳过的分块（带校验帧的广播先等待 FEC 恢复，由 nackLoop 兜底）
+	var gap []uint16
+	if frame.ChunkIndex < st.total && frame.ChunkIndex > st.maxIndex {
+		if st.nackable && st.parity == nil && frame.ChunkIndex > st.maxIndex+1 && time.Since(st.lastNACK) >= NACKDelay {
+			gap = st.missingChunks(frame.ChunkIndex)
+			st.nacks++
+			st.lastNACK = time.Now()
+			t.metrics.RecordLostIn(st.srcMAC, st.noteMissing(gap))
+		}
+		st.maxIndex = frame.ChunkIndex
+	}
+
+	// 数据分块不全但校验帧足够时，用前向纠错恢复
+	recovered := 0
+	if uint16(len(st.chunks)) < st.total && len(st.chunks)+len(st.parity) >= int(st.total) {
+		before := len(st.chunks)
+		if err := st.recoverWithFEC(); err == nil {
+			recovered = len(st.chunks) - before
+		}
+	}
 
 	// 检查是否完整
 	if uint16(len(st.chunks)) < st.total {
 		t.reassemblyMu.Unlock()
+		if len(gap) > 0 {
+			t.sendNACK(st.src, frame.Sequence, gap)
+		}
 		return nil, false
 	}
 	// 拼接
@@ -1103,6 +1196,14 @@ func (t *ARPTransport) tryReassemble(frame *ARPFrame) ([]byte, bool) {
 	}
 	delete(t.reassembly, key)
 	t.reassemblyMu.Unlock()
+	t.markCompleted(key)
+
+	if recovered > 0 {
+		t.statsMu.Lock()
+		t.stats.FECRecovered += uint64(recovered)
+		t.statsMu.Unlock()
+		t.metrics.RecordLostIn(frame.SrcMAC.String(), recovered)
+	}
 	return buf, true
 }
 
@@ -1110,7 +1211,7 @@ func (t *ARPTransport) tryReassemble(frame *ARPFrame) ([]byte, bool) {
 func (t *ARPTransport) registerPendingAck(seq uint32, frames []*ARPFrame) chan struct{} {
 	ch := make(chan struct{}, 1)
 	t.ackMu.Lock()
-	t.pendingAcks[seq] = &pendingAck{ch: ch, frames: frames}
+	t.pendingAcks[seq] = &pendingAck{ch: ch, frames: frames, sentAt: time.Now()}
 	t.ackMu.Unlock()
 	return ch
 }
@@ -1124,6 +1225,16 @@ func (t *ARPTransport) signalAck(seq uint32) {
 		default:
 		}
 		delete(t.pendingAcks, seq)
+		if len(p.frames) > 0 {
+			t.metrics.RecordRTT(t.peerKey(p.frames[0].DstMAC), time.Since(p.sentAt))
+		}
+
+		// 释放大块传输占用的拥塞窗口
+		bulk := 0
+		if len(p.frames) > 0 && framePriorityOf(p.frames[0]) == priorityBulk {
+			bulk = len(p.frames)
+		}
+		t.pacer.onAck(bulk, time.Since(p.sentAt))
 	}
 	t.ackMu.Unlock()
 }
@@ -1137,20 +1248,16 @@ func (t *ARPTransport) unregisterPendingAck(seq uint32) {
 
 // replyAnnounce 服务器回复 ANNOUNCE 到指定 MAC
 func (t *ARPTransport) replyAnnounce(dst net.HardwareAddr) {
-	// 构造简易宣告载荷：ANNOUNCE|<hash8>|<version>
+	// 构造简易宣告载荷：ANNOUNCE|<hash8>|<version>|<封装参数>
 	hash8 := ""
-	if t.serviceInfo != nil && t.serviceInfo.ChannelID != "" {
-		sum := sha256.Sum256([]byte(t.serviceInfo.ChannelID))
-		hash8 = hex.EncodeToString(sum[:])
-		if len(hash8) > 8 {
-			hash8 = hash8[:8]
-		}
+	if t.serviceInfo != nil {
+		hash8 = channelIDHash(t.serviceInfo.ChannelID)
 	}
-	payload := []byte("ANNOUNCE|" + hash8 + "|" + fmt.Sprintf("%d", ProtocolVersion))
+	payload := []byte("ANNOUNCE|" + hash8 + "|" + fmt.Sprintf("%d", ProtocolVersion) + "|" + t.framing.advertise())
 	f := &ARPFrame{
 		DstMAC:      dst,
 		SrcMAC:      t.localMAC,
-		EtherType:   EtherTypeCustom,
+		EtherType:   t.framing.etherType,
 		Version:     uint8(ProtocolVersion),
 		FrameType:   uint8(MessageTypeDiscover),
 		Sequence:    t.nextSequence(),
@@ -1168,7 +1275,7 @@ func (t *ARPTransport) tryParseAnnounce(frame *ARPFrame) {
 	if frame == nil || frame.Payload == nil || len(frame.Payload) == 0 {
 		return
 	}
-	// 载荷形如：ANNOUNCE|<hash8>|<version>
+	// 载荷形如：ANNOUNCE|<hash8>|<version>|<封装参数>（旧版本没有封装参数）
 	if !bytes.HasPrefix(frame.Payload, []byte("ANNOUNCE|")) {
 		return
 	}
@@ -1185,6 +1292,14 @@ func (t *ARPTransport) tryParseAnnounce(frame *ARPFrame) {
 		ChannelIDHash: hash8,
 		Version:       ProtocolVersion,
 	}
+	if len(parts) >= 4 {
+		pi.Metadata = parseFramingAdvert(string(parts[3]))
+		// 能收到应答说明封装方式一致，但 VLAN 标签可能已被网卡/内核剥离，不一致时提示便于排查
+		if !t.framing.matchesAdvert(pi.Metadata) {
+			fmt.Printf("[ARPTransport] Server %s advertises framing %s, local is %s\n",
+				frame.SrcMAC, parts[3], t.framing.advertise())
+		}
+	}
 	t.discoverMu.Lock()
 	if t.discoverChan != nil {
 		select {
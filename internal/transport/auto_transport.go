@@ -0,0 +1,468 @@
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 自动传输
+// 参考: docs/ARCHITECTURE.md - 3.1.4 传输模块
+//
+// AutoTransport 本身不收发数据，而是按候选顺序逐一启动真实传输，
+// 对外始终是同一个 Transport 实例：上层持有的引用、订阅的回调、
+// 角色/密钥/频道信息在切换后自动转移到新的传输上。
+// 是否"可用"（能否完成加入）由上层判断，失败时调用 Advance 切换到下一个候选。
+
+func init() {
+	MustRegister(Registration{
+		Mode:        TransportModeAuto,
+		DisplayName: "自动",
+		Description: "依次尝试 HTTPS（已知地址）→ mDNS → ARP（pcap可用时），使用第一个可加入的传输",
+		New:         func() Transport { return NewAutoTransport() },
+		Capabilities: Capabilities{
+			Unicast:      true,
+			Broadcast:    true,
+			FileTransfer: true,
+			Discovery:    true,
+		},
+	})
+}
+
+// AutoTransport 自动选择传输
+type AutoTransport struct {
+	config     *Config
+	candidates []TransportMode
+	index      int       // 当前候选下标
+	active     Transport // 当前生效的传输（未启动时为nil）
+
+	// 需要转移到新传输上的状态
+	role        string
+	channelID   string
+	channelName string
+	privKey     []byte
+	pubKey      []byte
+	handler     MessageHandler
+	fileHandler FileHandler
+	reconnect   *bool // 上层设置的自动重连开关（nil 表示保持各传输默认值）
+
+	failures atomic.Int32 // 连续发送失败次数（成功一次即清零）
+
+	mu sync.RWMutex
+}
+
+// NewAutoTransport 创建自动传输
+func NewAutoTransport() *AutoTransport {
+	return &AutoTransport{}
+}
+
+// ===== 生命周期管理 =====
+
+// Init 初始化（仅记录配置并计算候选列表，真实传输在 Start 时创建）
+func (t *AutoTransport) Init(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.config = config
+	t.candidates = t.buildCandidates()
+	if len(t.candidates) == 0 {
+		return fmt.Errorf("no transport candidates available for auto mode")
+	}
+	return nil
+}
+
+// buildCandidates 计算候选顺序（调用方持有锁）
+func (t *AutoTransport) buildCandidates() []TransportMode {
+	if len(t.config.AutoCandidates) > 0 {
+		candidates := make([]TransportMode, 0, len(t.config.AutoCandidates))
+		for _, mode := range t.config.AutoCandidates {
+			if mode == TransportModeAuto {
+				continue
+			}
+			if _, ok := Lookup(mode); ok {
+				candidates = append(candidates, mode)
+			}
+		}
+		return candidates
+	}
+
+	candidates := make([]TransportMode, 0, 3)
+	// HTTPS：客户端需要已知服务器地址；服务端总是可以监听
+	if t.config.ServerAddress != "" || t.role == "server" {
+		candidates = append(candidates, TransportModeHTTPS)
+	}
+	candidates = append(candidates, TransportModeMDNS)
+	// ARP：需要指定网卡且可收发原始帧（pcap 或 AF_PACKET）
+	if t.config.Interface != "" && RawFramesAvailable() {
+		candidates = append(candidates, TransportModeARP)
+	}
+	return candidates
+}
+
+// Start 启动第一个能够成功初始化并启动的候选传输
+func (t *AutoTransport) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.config == nil {
+		return fmt.Errorf("transport not initialized")
+	}
+	if t.active != nil {
+		return fmt.Errorf("transport already started")
+	}
+
+	// 服务端角色可能在 Init 之后才设置，重新计算一次候选
+	t.candidates = t.buildCandidates()
+	return t.activateFrom(0)
+}
+
+// Stop 停止当前传输
+func (t *AutoTransport) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active == nil {
+		return nil
+	}
+	err := t.active.Stop()
+	t.active = nil
+	return err
+}
+
+// Advance 停止当前传输并切换到下一个可启动的候选（到达末尾后从头开始）
+// 订阅的回调与角色/密钥/频道信息会转移到新传输上
+func (t *AutoTransport) Advance() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.config == nil {
+		return fmt.Errorf("transport not initialized")
+	}
+	if len(t.candidates) == 0 {
+		return fmt.Errorf("no transport candidates available for auto mode")
+	}
+
+	if t.active != nil {
+		t.active.Unsubscribe()
+		_ = t.active.Stop()
+		t.active = nil
+	}
+	return t.activateFrom(t.index + 1)
+}
+
+// activateFrom 从下标 start 开始（循环）寻找第一个可启动的候选（调用方持有锁）
+func (t *AutoTransport) activateFrom(start int) error {
+	var lastErr error
+	for i := 0; i < len(t.candidates); i++ {
+		index := (start + i) % len(t.candidates)
+		tr, err := t.startCandidate(t.candidates[index])
+		if err != nil {
+			t.logf("candidate %s unavailable: %v", t.candidates[index], err)
+			lastErr = err
+			continue
+		}
+		t.index = index
+		t.active = tr
+		t.failures.Store(0)
+		t.logf("using %s transport", t.candidates[index])
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no transport candidates available for auto mode")
+	}
+	return fmt.Errorf("no transport could be started: %w", lastErr)
+}
+
+// startCandidate 创建、初始化并启动一个候选传输（调用方持有锁）
+func (t *AutoTransport) startCandidate(mode TransportMode) (Transport, error) {
+	reg, ok := Lookup(mode)
+	if !ok {
+		return nil, fmt.Errorf("unknown transport mode: %s", mode)
+	}
+
+	config := *t.config
+	config.Mode = mode
+	if config.Port == 0 {
+		config.Port = reg.Capabilities.DefaultPort
+	}
+
+	tr := reg.New()
+	if err := tr.Init(&config); err != nil {
+		return nil, err
+	}
+	if t.role != "" {
+		if rs, ok := tr.(RoleSetter); ok {
+			rs.SetMode(t.role)
+		}
+	}
+	if t.privKey != nil || t.pubKey != nil {
+		if ks, ok := tr.(ServerKeySetter); ok {
+			ks.SetServerKeys(t.privKey, t.pubKey)
+		}
+	}
+	if cs, ok := tr.(ChannelInfoSetter); ok {
+		cs.SetChannelInfo(t.channelID, t.channelName)
+	}
+	if t.reconnect != nil {
+		if rs, ok := tr.(ReconnectSetter); ok {
+			rs.SetAutoReconnect(*t.reconnect)
+		}
+	}
+	if t.fileHandler != nil {
+		_ = tr.OnFileReceived(t.fileHandler)
+	}
+	if err := tr.Start(); err != nil {
+		return nil, err
+	}
+	if t.handler != nil {
+		if err := tr.Subscribe(t.handler); err != nil {
+			_ = tr.Stop()
+			return nil, err
+		}
+	}
+	return tr, nil
+}
+
+// logf 输出调试日志（未配置日志时忽略）
+func (t *AutoTransport) logf(format string, args ...interface{}) {
+	if t.config != nil && t.config.Logger != nil {
+		t.config.Logger.Info("[AutoTransport] "+format, args...)
+	}
+}
+
+// current 获取当前传输
+func (t *AutoTransport) current() (Transport, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.active == nil {
+		return nil, fmt.Errorf("transport not started")
+	}
+	return t.active, nil
+}
+
+// ===== 状态查询 =====
+
+// ActiveMode 当前生效的传输模式（未启动时为空）
+func (t *AutoTransport) ActiveMode() TransportMode {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.active == nil {
+		return ""
+	}
+	return t.candidates[t.index]
+}
+
+// Candidates 候选传输列表（按尝试顺序）
+func (t *AutoTransport) Candidates() []TransportMode {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]TransportMode(nil), t.candidates...)
+}
+
+// ConsecutiveFailures 连续发送失败次数
+func (t *AutoTransport) ConsecutiveFailures() int {
+	return int(t.failures.Load())
+}
+
+// ===== 连接管理 =====
+
+// Connect 连接到目标
+func (t *AutoTransport) Connect(target string) error {
+	tr, err := t.current()
+	if err != nil {
+		return err
+	}
+	return tr.Connect(target)
+}
+
+// Disconnect 断开连接
+func (t *AutoTransport) Disconnect() error {
+	tr, err := t.current()
+	if err != nil {
+		return err
+	}
+	return tr.Disconnect()
+}
+
+// IsConnected 检查连接状态
+func (t *AutoTransport) IsConnected() bool {
+	tr, err := t.current()
+	if err != nil {
+		return false
+	}
+	return tr.IsConnected()
+}
+
+// ===== 消息收发 =====
+
+// SendMessage 发送消息（记录连续失败次数供上层判断是否需要切换）
+func (t *AutoTransport) SendMessage(msg *Message) error {
+	tr, err := t.current()
+	if err != nil {
+		t.failures.Add(1)
+		return err
+	}
+	if err := tr.SendMessage(msg); err != nil {
+		t.failures.Add(1)
+		return err
+	}
+	t.failures.Store(0)
+	return nil
+}
+
+// ReceiveMessage 接收消息（阻塞）
+func (t *AutoTransport) ReceiveMessage() (*Message, error) {
+	tr, err := t.current()
+	if err != nil {
+		return nil, err
+	}
+	return tr.ReceiveMessage()
+}
+
+// Subscribe 订阅消息（切换传输后自动重新订阅）
+func (t *AutoTransport) Subscribe(handler MessageHandler) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handler = handler
+	if t.active != nil {
+		return t.active.Subscribe(handler)
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func (t *AutoTransport) Unsubscribe() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handler = nil
+	if t.active != nil {
+		t.active.Unsubscribe()
+	}
+}
+
+// ===== 文件传输 =====
+
+// SendFile 发送文件
+func (t *AutoTransport) SendFile(file *FileTransfer) error {
+	tr, err := t.current()
+	if err != nil {
+		return err
+	}
+	return tr.SendFile(file)
+}
+
+// OnFileReceived 文件接收回调（切换传输后自动重新注册）
+func (t *AutoTransport) OnFileReceived(handler FileHandler) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.fileHandler = handler
+	if t.active != nil {
+		return t.active.OnFileReceived(handler)
+	}
+	return nil
+}
+
+// ===== 服务发现 =====
+
+// Discover 使用当前传输发现服务端
+func (t *AutoTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
+	tr, err := t.current()
+	if err != nil {
+		return nil, err
+	}
+	return tr.Discover(timeout)
+}
+
+// Announce 使用当前传输宣告服务
+func (t *AutoTransport) Announce(info *ServiceInfo) error {
+	tr, err := t.current()
+	if err != nil {
+		return err
+	}
+	return tr.Announce(info)
+}
+
+// ===== 元数据 =====
+
+// GetMode 获取当前生效的传输模式（未启动时为 auto），便于上层按真实传输选择分块大小等参数
+func (t *AutoTransport) GetMode() TransportMode {
+	if mode := t.ActiveMode(); mode != "" {
+		return mode
+	}
+	return TransportModeAuto
+}
+
+// GetStats 获取当前传输的统计
+func (t *AutoTransport) GetStats() *TransportStats {
+	tr, err := t.current()
+	if err != nil {
+		return &TransportStats{}
+	}
+	return tr.GetStats()
+}
+
+// PeerMetrics 当前传输的按对端统计（不支持时为 nil）
+func (t *AutoTransport) PeerMetrics() *PeerMetrics {
+	tr, err := t.current()
+	if err != nil {
+		return nil
+	}
+	if p, ok := tr.(PeerStatsProvider); ok {
+		return p.PeerMetrics()
+	}
+	return nil
+}
+
+// ===== 可选接口 =====
+
+// SetMode 设置角色（"server" or "client"）
+func (t *AutoTransport) SetMode(mode string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.role = mode
+	if rs, ok := t.active.(RoleSetter); ok {
+		rs.SetMode(mode)
+	}
+}
+
+// SetServerKeys 设置服务端签名密钥
+func (t *AutoTransport) SetServerKeys(privKey, pubKey []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.privKey, t.pubKey = privKey, pubKey
+	if ks, ok := t.active.(ServerKeySetter); ok {
+		ks.SetServerKeys(privKey, pubKey)
+	}
+}
+
+// SetAutoReconnect 设置传输层自身的断线重连开关
+func (t *AutoTransport) SetAutoReconnect(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reconnect = &enabled
+	if rs, ok := t.active.(ReconnectSetter); ok {
+		rs.SetAutoReconnect(enabled)
+	}
+}
+
+// SetChannelInfo 设置频道信息
+func (t *AutoTransport) SetChannelInfo(channelID, channelName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.channelID, t.channelName = channelID, channelName
+	if cs, ok := t.active.(ChannelInfoSetter); ok {
+		cs.SetChannelInfo(channelID, channelName)
+	}
+}
@@ -13,21 +13,13 @@ func NewFactory() *Factory {
 	return &Factory{}
 }
 
-// Create 创建传输层实例
+// Create 创建传输层实例（按注册表查找构造函数）
 func (f *Factory) Create(mode TransportMode) (Transport, error) {
-	switch mode {
-	case TransportModeHTTPS:
-		return NewHTTPSTransport(), nil
-
-	case TransportModeARP:
-		return NewARPTransport(), nil
-
-	case TransportModeMDNS:
-		return NewMDNSTransport(), nil
-
-	default:
+	reg, ok := Lookup(mode)
+	if !ok {
 		return nil, fmt.Errorf("unknown transport mode: %s", mode)
 	}
+	return reg.New(), nil
 }
 
 // CreateWithConfig 创建并初始化传输层
@@ -44,21 +36,21 @@ func (f *Factory) CreateWithConfig(mode TransportMode, config *Config) (Transpor
 	return transport, nil
 }
 
-// GetSupportedModes 获取支持的传输模式列表
+// GetSupportedModes 获取支持的传输模式列表（不含隐藏的测试传输）
 func (f *Factory) GetSupportedModes() []models.TransportMode {
-	return []models.TransportMode{
-		models.TransportHTTPS,
-		models.TransportARP,
-		models.TransportMDNS,
+	regs := Registrations()
+	modes := make([]models.TransportMode, 0, len(regs))
+	for _, reg := range regs {
+		if reg.Hidden {
+			continue
+		}
+		modes = append(modes, reg.Mode)
 	}
+	return modes
 }
 
-// IsModeSupported 检查传输模式是否支持
+// IsModeSupported 检查传输模式是否已注册且对用户可见（隐藏的测试传输视为不支持）
 func (f *Factory) IsModeSupported(mode models.TransportMode) bool {
-	for _, m := range f.GetSupportedModes() {
-		if m == mode {
-			return true
-		}
-	}
-	return false
+	reg, ok := Lookup(mode)
+	return ok && !reg.Hidden
 }
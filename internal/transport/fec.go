@@ -0,0 +1,396 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+)
+
+// 前向纠错（FEC）
+// 参考: docs/PROTOCOL.md - 2.3.3 前向纠错
+//
+// 广播没有逐接收方的确认，丢帧只能依赖 NACK 或重新同步。对分块较多的广播，
+// 发送方在数据分块之后追加若干校验帧（GF(2^8) 上基于 Cauchy 矩阵的系统 Reed-Solomon 码），
+// 接收方只要收到任意 k 帧（k 为数据分块数）即可无需回传地恢复完整消息。
+//
+// 校验帧与数据帧共用 Sequence，TotalChunks 仍为数据分块数，校验帧的 ChunkIndex 从 TotalChunks 开始，
+// 不支持 FEC 的旧接收方会将其当作越界分块丢弃。帧头 Reserved 字段描述校验布局：
+//
+//	bit 31      FEC 标记
+//	bit 16-23   校验帧数
+//	bit 0-15    最后一个数据分块的长度（其余分块等长）
+
+const (
+	FECMinChunks    = 4   // 数据分块数达到该值才追加校验帧
+	FECDefaultRatio = 0.1 // 无丢包时的基础冗余比例
+	FECMaxRatio     = 0.5 // 冗余比例上限
+	FECLossWeight   = 2.0 // 冗余比例 = 基础比例 + 丢包率 × 权重
+	fecMaxShards    = 256 // GF(2^8) 下数据帧 + 校验帧的上限
+	fecFlag         = 1 << 31
+	lossWindow      = 4096 // 丢包率统计窗口（帧），超出后计数减半
+)
+
+var errFECInsufficient = errors.New("fec: not enough shards to reconstruct")
+
+// ===== GF(2^8) 运算（本原多项式 x^8+x^4+x^3+x^2+1）=====
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfMulAdd dst ^= c * src
+func gfMulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	logC := int(gfLog[c])
+	for i, v := range src {
+		if v != 0 {
+			dst[i] ^= gfExp[logC+int(gfLog[v])]
+		}
+	}
+}
+
+// fecCoefficient 校验帧 row 对数据分块 col 的系数（Cauchy 矩阵 1/(x_row + y_col)）
+func fecCoefficient(dataCount, row, col int) byte {
+	return gfInv(byte(dataCount+row) ^ byte(col))
+}
+
+// ===== 编码与恢复 =====
+
+// fecLayout 校验布局（编码在帧头 Reserved 字段中）
+type fecLayout struct {
+	parity  int // 校验帧数
+	lastLen int // 最后一个数据分块的长度
+}
+
+// encode 编码为 Reserved 字段
+func (l fecLayout) encode() uint32 {
+	return fecFlag | uint32(l.parity&0xff)<<16 | uint32(l.lastLen&0xffff)
+}
+
+// parseFECLayout 从 Reserved 字段解析校验布局
+func parseFECLayout(reserved uint32) (fecLayout, bool) {
+	if reserved&fecFlag == 0 {
+		return fecLayout{}, false
+	}
+	l := fecLayout{parity: int(reserved >> 16 & 0xff), lastLen: int(reserved & 0xffff)}
+	return l, l.parity > 0
+}
+
+// fecEncode 为等长数据分块生成 parity 个校验分块
+func fecEncode(data [][]byte, parity int) ([][]byte, error) {
+	if len(data) == 0 || parity <= 0 {
+		return nil, nil
+	}
+	if len(data)+parity > fecMaxShards {
+		return nil, fmt.Errorf("fec: %d data + %d parity shards exceeds %d", len(data), parity, fecMaxShards)
+	}
+	size := len(data[0])
+	out := make([][]byte, parity)
+	for i := range out {
+		out[i] = make([]byte, size)
+		for j, shard := range data {
+			if len(shard) != size {
+				return nil, fmt.Errorf("fec: shard %d has length %d, want %d", j, len(shard), size)
+			}
+			gfMulAdd(out[i], shard, fecCoefficient(len(data), i, j))
+		}
+	}
+	return out, nil
+}
+
+// fecReconstruct 用任意 dataCount 个分块恢复缺失的数据分块
+// shards 以分块索引为键（校验分块索引从 dataCount 开始），所有分块须等长；恢复结果写回 shards
+func fecReconstruct(shards map[uint16][]byte, dataCount, parity, size int) error {
+	var missing []int
+	for j := 0; j < dataCount; j++ {
+		if _, ok := shards[uint16(j)]; !ok {
+			missing = append(missing, j)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// 选取 dataCount 个可用分块：优先数据分块，不足部分由校验分块补齐
+	rows := make([]int, 0, dataCount)
+	for j := 0; j < dataCount; j++ {
+		if _, ok := shards[uint16(j)]; ok {
+			rows = append(rows, j)
+		}
+	}
+	for i := 0; i < parity && len(rows) < dataCount; i++ {
+		if _, ok := shards[uint16(dataCount+i)]; ok {
+			rows = append(rows, dataCount+i)
+		}
+	}
+	if len(rows) < dataCount {
+		return errFECInsufficient
+	}
+
+	// 构造编码矩阵中对应的行并求逆
+	matrix := make([][]byte, dataCount)
+	for r, idx := range rows {
+		matrix[r] = make([]byte, dataCount)
+		if idx < dataCount {
+			matrix[r][idx] = 1
+			continue
+		}
+		for j := 0; j < dataCount; j++ {
+			matrix[r][j] = fecCoefficient(dataCount, idx-dataCount, j)
+		}
+	}
+	inverse, err := gfInvertMatrix(matrix)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range missing {
+		shard := make([]byte, size)
+		for r, idx := range rows {
+			src := shards[uint16(idx)]
+			if len(src) != size {
+				return fmt.Errorf("fec: shard %d has length %d, want %d", idx, len(src), size)
+			}
+			gfMulAdd(shard, src, inverse[j][r])
+		}
+		shards[uint16(j)] = shard
+	}
+	return nil
+}
+
+// gfInvertMatrix 高斯-约当消元求逆
+func gfInvertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	work := make([][]byte, n)
+	for i := range m {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if work[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("fec: singular matrix")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		inv := gfInv(work[col][col])
+		for k := range work[col] {
+			work[col][k] = gfMul(work[col][k], inv)
+		}
+		for r := 0; r < n; r++ {
+			if r != col && work[r][col] != 0 {
+				gfMulAdd(work[r], work[col], work[r][col])
+			}
+		}
+	}
+
+	out := make([][]byte, n)
+	for i := range work {
+		out[i] = work[i][n:]
+	}
+	return out, nil
+}
+
+// ===== 自适应冗余 =====
+
+// lossEstimator 根据已发送帧数与对端报告丢失的帧数估计丢包率
+type lossEstimator struct {
+	mu   sync.Mutex
+	sent float64
+	lost float64
+}
+
+// addSent 记录发送的帧数
+func (e *lossEstimator) addSent(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent += float64(n)
+	if e.sent > lossWindow {
+		// 衰减历史，使估计值跟随近期网络状况
+		e.sent /= 2
+		e.lost /= 2
+	}
+}
+
+// addLost 记录对端报告丢失的帧数
+func (e *lossEstimator) addLost(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lost += float64(n)
+}
+
+// rate 当前丢包率估计
+func (e *lossEstimator) rate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sent == 0 {
+		return 0
+	}
+	r := e.lost / e.sent
+	if r > 1 {
+		r = 1
+	}
+	return r
+}
+
+// fecParityCount 根据丢包率计算 dataCount 个数据分块需要的校验帧数
+func fecParityCount(dataCount int, baseRatio, lossRate float64) int {
+	if dataCount < FECMinChunks {
+		return 0
+	}
+	ratio := baseRatio + lossRate*FECLossWeight
+	if ratio > FECMaxRatio {
+		ratio = FECMaxRatio
+	}
+	if ratio <= 0 {
+		return 0
+	}
+	parity := int(float64(dataCount)*ratio + 0.999)
+	if parity < 1 {
+		parity = 1
+	}
+	if parity > 255 {
+		parity = 255
+	}
+	if dataCount+parity > fecMaxShards {
+		parity = fecMaxShards - dataCount
+	}
+	if parity <= 0 {
+		return 0
+	}
+	return parity
+}
+
+// ===== ARP 广播集成 =====
+
+// appendParityFrames 为一条广播追加校验帧，并在所有帧的 Reserved 字段写入校验布局
+func (t *ARPTransport) appendParityFrames(frames []*ARPFrame, chunks [][]byte) []*ARPFrame {
+	ratio := t.config.FECRatio
+	if ratio <= 0 {
+		ratio = FECDefaultRatio
+	}
+	parity := fecParityCount(len(chunks), ratio, t.loss.rate())
+	if parity == 0 {
+		return frames
+	}
+
+	size := len(chunks[0])
+	data := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		data[i] = c
+		if len(c) < size {
+			data[i] = make([]byte, size)
+			copy(data[i], c)
+		}
+	}
+	shards, err := fecEncode(data, parity)
+	if err != nil {
+		fmt.Printf("[ARPTransport] FEC encode failed: %v\n", err)
+		return frames
+	}
+
+	layout := fecLayout{parity: parity, lastLen: len(chunks[len(chunks)-1])}
+	for _, f := range frames {
+		f.Reserved = layout.encode()
+	}
+	first := frames[0]
+	for i, shard := range shards {
+		f := &ARPFrame{
+			DstMAC:      first.DstMAC,
+			SrcMAC:      first.SrcMAC,
+			EtherType:   first.EtherType,
+			Version:     first.Version,
+			FrameType:   first.FrameType,
+			Sequence:    first.Sequence,
+			TotalChunks: first.TotalChunks,
+			ChunkIndex:  first.TotalChunks + uint16(i),
+			Reserved:    layout.encode(),
+			Payload:     shard,
+		}
+		f.Checksum = crc32.ChecksumIEEE(f.Payload)
+		f.PayloadLen = uint16(len(f.Payload))
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// recoverWithFEC 用已收到的数据分块与校验分块恢复缺失的数据分块（调用方持有 reassemblyMu）
+func (st *reassemblyState) recoverWithFEC() error {
+	if len(st.parity) == 0 {
+		return errFECInsufficient
+	}
+	size := 0
+	for _, p := range st.parity {
+		size = len(p)
+		break
+	}
+
+	shards := make(map[uint16][]byte, len(st.chunks)+len(st.parity))
+	for idx, c := range st.chunks {
+		if len(c) > size {
+			return fmt.Errorf("fec: chunk %d longer than parity shard", idx)
+		}
+		if len(c) < size {
+			padded := make([]byte, size)
+			copy(padded, c)
+			c = padded
+		}
+		shards[idx] = c
+	}
+	for idx, p := range st.parity {
+		shards[idx] = p
+	}
+	if err := fecReconstruct(shards, int(st.total), st.fec.parity, size); err != nil {
+		return err
+	}
+
+	for j := uint16(0); j < st.total; j++ {
+		if _, ok := st.chunks[j]; ok {
+			continue
+		}
+		shard := shards[j]
+		if j == st.total-1 && st.fec.lastLen < len(shard) {
+			shard = shard[:st.fec.lastLen]
+		}
+		st.chunks[j] = shard
+	}
+	return nil
+}
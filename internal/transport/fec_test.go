@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestFECReconstruct(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const dataCount, parity, size = 10, 4, 64
+
+	data := make([][]byte, dataCount)
+	for i := range data {
+		data[i] = make([]byte, size)
+		rng.Read(data[i])
+	}
+	shards, err := fecEncode(data, parity)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	for trial := 0; trial < 50; trial++ {
+		received := make(map[uint16][]byte)
+		for i, d := range data {
+			received[uint16(i)] = d
+		}
+		for i, p := range shards {
+			received[uint16(dataCount+i)] = p
+		}
+		// 随机丢弃 parity 个分块（数据或校验）
+		for _, idx := range rng.Perm(dataCount + parity)[:parity] {
+			delete(received, uint16(idx))
+		}
+
+		if err := fecReconstruct(received, dataCount, parity, size); err != nil {
+			t.Fatalf("trial %d: reconstruct: %v", trial, err)
+		}
+		for i, d := range data {
+			if !bytes.Equal(received[uint16(i)], d) {
+				t.Fatalf("trial %d: shard %d mismatch", trial, i)
+			}
+		}
+	}
+
+	// 丢失超过校验帧数时无法恢复
+	received := map[uint16][]byte{0: data[0]}
+	if err := fecReconstruct(received, dataCount, parity, size); err != errFECInsufficient {
+		t.Fatalf("expected errFECInsufficient, got %v", err)
+	}
+}
+
+func TestFECReassemblyTruncatesLastChunk(t *testing.T) {
+	tr := &ARPTransport{config: &Config{FECEnabled: true}}
+	payload := make([]byte, 5*MaxFramePayload+100)
+	rand.New(rand.NewSource(2)).Read(payload)
+
+	chunks := tr.chunkBytes(payload, MaxFramePayload)
+	frames := make([]*ARPFrame, len(chunks))
+	for i, c := range chunks {
+		frames[i] = &ARPFrame{TotalChunks: uint16(len(chunks)), ChunkIndex: uint16(i), Payload: c}
+	}
+	frames = tr.appendParityFrames(frames, chunks)
+	layout, ok := parseFECLayout(frames[0].Reserved)
+	if !ok || len(frames) != len(chunks)+layout.parity {
+		t.Fatalf("expected parity frames, got %d frames", len(frames))
+	}
+
+	st := &reassemblyState{
+		total:  uint16(len(chunks)),
+		chunks: make(map[uint16][]byte),
+		fec:    layout,
+		parity: make(map[uint16][]byte),
+	}
+	// 丢失最后一个数据分块
+	for _, f := range frames {
+		switch {
+		case f.ChunkIndex == st.total-1:
+		case f.ChunkIndex >= st.total:
+			st.parity[f.ChunkIndex] = f.Payload
+		default:
+			st.chunks[f.ChunkIndex] = f.Payload
+		}
+	}
+	if err := st.recoverWithFEC(); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if got := st.chunks[st.total-1]; !bytes.Equal(got, chunks[len(chunks)-1]) {
+		t.Fatalf("last chunk: got %d bytes, want %d", len(got), len(chunks[len(chunks)-1]))
+	}
+}
+
+func TestFECParityCountAdapts(t *testing.T) {
+	if n := fecParityCount(FECMinChunks-1, FECDefaultRatio, 0); n != 0 {
+		t.Fatalf("small message got %d parity frames", n)
+	}
+	low := fecParityCount(40, FECDefaultRatio, 0)
+	high := fecParityCount(40, FECDefaultRatio, 0.1)
+	if low < 1 || high <= low {
+		t.Fatalf("parity should grow with loss: low=%d high=%d", low, high)
+	}
+	if capped := fecParityCount(40, FECDefaultRatio, 1); capped != int(40*FECMaxRatio) {
+		t.Fatalf("parity not capped: %d", capped)
+	}
+}
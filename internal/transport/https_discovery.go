@@ -0,0 +1,266 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// HTTPS 模式局域网发现（UDP 信标）
+// 参考: docs/PROTOCOL.md - 3. HTTPS传输协议
+//
+// 客户端向 DiscoveryBeaconPort 广播探测报文 "CWPROBE|<version>"，
+// 服务端以单播回复 "CWBEACON|<json>"，JSON 内容与 /info 字段保持一致。
+
+const (
+	DiscoveryBeaconPort = 38443 // 信标监听端口（UDP）
+
+	beaconProbePrefix = "CWPROBE|"
+	beaconReplyPrefix = "CWBEACON|"
+	beaconMaxSize     = 2048
+)
+
+// httpsBeacon HTTPS 服务端信标内容（与 /info 保持一致）
+type httpsBeacon struct {
+	ChannelIDHash   string `json:"channel_id_hash"`
+	ChannelName     string `json:"channel_name"`
+	Port            int    `json:"port"`
+	CertFingerprint string `json:"cert_fingerprint"`
+	MemberCount     int    `json:"member_count"`
+	MaxMembers      int    `json:"max_members"`
+	Mode            string `json:"mode"`
+	Version         int    `json:"version"`
+}
+
+// channelIDHash 计算频道ID哈希（SHA-256 前8个十六进制字符）
+func channelIDHash(channelID string) string {
+	if channelID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(channelID))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// buildBeacon 根据当前服务信息构造信标
+func (t *HTTPSTransport) buildBeacon() *httpsBeacon {
+	b := &httpsBeacon{
+		ChannelIDHash: channelIDHash(t.serverChannelID),
+		ChannelName:   t.serverChannelName,
+		Mode:          string(TransportModeHTTPS),
+		Version:       ProtocolVersion,
+		MemberCount:   t.GetClientCount(),
+	}
+	if t.config != nil {
+		b.Port = t.config.Port
+	}
+
+	t.beaconMu.RLock()
+	if t.serviceInfo != nil {
+		if b.ChannelIDHash == "" {
+			b.ChannelIDHash = channelIDHash(t.serviceInfo.ChannelID)
+		}
+		if b.ChannelName == "" {
+			b.ChannelName = t.serviceInfo.ChannelName
+		}
+		if t.serviceInfo.Port > 0 {
+			b.Port = t.serviceInfo.Port
+		}
+		b.MaxMembers = t.serviceInfo.MaxMembers
+	}
+	b.CertFingerprint = t.certFingerprint
+	t.beaconMu.RUnlock()
+
+	return b
+}
+
+// startBeaconResponder 启动信标应答协程（服务端模式）
+func (t *HTTPSTransport) startBeaconResponder() error {
+	t.beaconMu.Lock()
+	defer t.beaconMu.Unlock()
+
+	if t.beaconConn != nil {
+		return nil
+	}
+
+	// 同一主机上可能运行多个服务端，信标端口需开启复用
+	lc := net.ListenConfig{Control: beaconReuseControl}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", DiscoveryBeaconPort))
+	if err != nil {
+		return fmt.Errorf("listen beacon port: %w", err)
+	}
+	conn := pc.(*net.UDPConn)
+	t.beaconConn = conn
+
+	go t.beaconLoop(conn)
+
+	t.logInfo("Discovery beacon listening on udp/%d", DiscoveryBeaconPort)
+	return nil
+}
+
+// stopBeaconResponder 停止信标应答
+func (t *HTTPSTransport) stopBeaconResponder() {
+	t.beaconMu.Lock()
+	defer t.beaconMu.Unlock()
+
+	if t.beaconConn != nil {
+		t.beaconConn.Close()
+		t.beaconConn = nil
+	}
+}
+
+// beaconLoop 处理探测报文并回复信标
+func (t *HTTPSTransport) beaconLoop(conn *net.UDPConn) {
+	buf := make([]byte, beaconMaxSize)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// 连接关闭时退出
+			return
+		}
+		if !bytes.HasPrefix(buf[:n], []byte(beaconProbePrefix)) {
+			continue
+		}
+
+		data, err := json.Marshal(t.buildBeacon())
+		if err != nil {
+			continue
+		}
+		reply := append([]byte(beaconReplyPrefix), data...)
+		if _, err := conn.WriteToUDP(reply, from); err != nil {
+			t.logDebug("Beacon reply to %s failed: %v", from, err)
+		}
+	}
+}
+
+// discoverBeacons 广播探测报文并收集信标回复
+func (t *HTTPSTransport) discoverBeacons(timeout time.Duration) ([]*PeerInfo, error) {
+	return collectBeacons(DiscoveryBeaconPort, timeout)
+}
+
+// collectBeacons 向指定端口广播探测报文并收集回复（HTTPS 与 UDP 传输共用）
+func collectBeacons(port int, timeout time.Duration) ([]*PeerInfo, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("open discovery socket: %w", err)
+	}
+	defer conn.Close()
+
+	probe := []byte(fmt.Sprintf("%s%d", beaconProbePrefix, ProtocolVersion))
+	sent := 0
+	for _, ip := range beaconTargets() {
+		if _, err := conn.WriteToUDP(probe, &net.UDPAddr{IP: ip, Port: port}); err == nil {
+			sent++
+		}
+	}
+	if sent == 0 {
+		return nil, fmt.Errorf("failed to send discovery probe")
+	}
+
+	peers := make(map[string]*PeerInfo)
+	buf := make([]byte, beaconMaxSize)
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// 超时即结束收集
+			break
+		}
+		peer := parseBeacon(buf[:n], from)
+		if peer == nil {
+			continue
+		}
+		// 同一服务端可能通过多个广播地址（含回环）回复，按频道与端口去重，优先保留非回环地址
+		key := fmt.Sprintf("%s|%d|%s", peer.ChannelIDHash, peer.Port, peer.CertFingerprint)
+		if existing, ok := peers[key]; ok && !from.IP.IsLoopback() {
+			if host, _, _ := net.SplitHostPort(existing.Address); net.ParseIP(host).IsLoopback() {
+				peers[key] = peer
+			}
+			continue
+		} else if ok {
+			continue
+		}
+		peers[key] = peer
+	}
+
+	result := make([]*PeerInfo, 0, len(peers))
+	for _, p := range peers {
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// parseBeacon 解析信标回复
+func parseBeacon(data []byte, from *net.UDPAddr) *PeerInfo {
+	if !bytes.HasPrefix(data, []byte(beaconReplyPrefix)) {
+		return nil
+	}
+	var b httpsBeacon
+	if err := json.Unmarshal(data[len(beaconReplyPrefix):], &b); err != nil {
+		return nil
+	}
+	if b.Port <= 0 {
+		return nil
+	}
+
+	mode := TransportMode(b.Mode)
+	if mode == "" {
+		mode = TransportModeHTTPS
+	}
+
+	addr := net.JoinHostPort(from.IP.String(), fmt.Sprintf("%d", b.Port))
+	return &PeerInfo{
+		ID:              addr,
+		Address:         addr,
+		Mode:            mode,
+		LastSeen:        time.Now(),
+		ChannelIDHash:   b.ChannelIDHash,
+		Version:         b.Version,
+		Name:            b.ChannelName,
+		Port:            b.Port,
+		CertFingerprint: b.CertFingerprint,
+		MemberCount:     b.MemberCount,
+		Metadata: map[string]string{
+			"max_members": fmt.Sprintf("%d", b.MaxMembers),
+		},
+	}
+}
+
+// beaconTargets 返回探测报文的目标地址（受限广播、各网卡定向广播和本机回环）
+func beaconTargets() []net.IP {
+	targets := []net.IP{net.IPv4bcast, net.IPv4(127, 0, 0, 1)}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return targets
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip4 := ipnet.IP.To4()
+			if ip4 == nil || len(ipnet.Mask) != net.IPv4len {
+				continue
+			}
+			bcast := make(net.IP, net.IPv4len)
+			for i := range ip4 {
+				bcast[i] = ip4[i] | ^ipnet.Mask[i]
+			}
+			targets = append(targets, bcast)
+		}
+	}
+	return targets
+}
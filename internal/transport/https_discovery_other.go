@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package transport
+
+import (
+	"syscall"
+)
+
+// beaconReuseControl 当前平台不支持端口复用，信标端口只能由一个服务端占用
+func beaconReuseControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
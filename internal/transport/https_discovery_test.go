@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func beaconReply(t *testing.T, b httpsBeacon) []byte {
+	t.Helper()
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("marshal beacon: %v", err)
+	}
+	return append([]byte(beaconReplyPrefix), data...)
+}
+
+func TestParseBeacon(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50000}
+	valid := httpsBeacon{
+		ChannelIDHash:   "a1b2c3d4",
+		ChannelName:     "红队",
+		Port:            8443,
+		CertFingerprint: "ff00",
+		MemberCount:     3,
+		MaxMembers:      50,
+		Version:         ProtocolVersion,
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"valid", beaconReply(t, valid), true},
+		{"wrong prefix", append([]byte(beaconProbePrefix), '1'), false},
+		{"bad json", []byte(beaconReplyPrefix + "{"), false},
+		{"missing port", beaconReply(t, httpsBeacon{ChannelIDHash: "a1b2c3d4"}), false},
+		{"empty", nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			peer := parseBeacon(tc.data, from)
+			if (peer != nil) != tc.ok {
+				t.Fatalf("parseBeacon ok=%v, want %v", peer != nil, tc.ok)
+			}
+		})
+	}
+
+	peer := parseBeacon(beaconReply(t, valid), from)
+	if peer.Address != "192.168.1.10:8443" || peer.Port != 8443 {
+		t.Fatalf("address = %s port = %d", peer.Address, peer.Port)
+	}
+	if peer.Mode != TransportModeHTTPS {
+		t.Fatalf("mode = %s, want https default", peer.Mode)
+	}
+	if peer.Name != "红队" || peer.ChannelIDHash != "a1b2c3d4" || peer.CertFingerprint != "ff00" || peer.MemberCount != 3 {
+		t.Fatalf("unexpected peer: %+v", peer)
+	}
+	if peer.Metadata["max_members"] != "50" {
+		t.Fatalf("max_members = %q", peer.Metadata["max_members"])
+	}
+}
+
+// startTestResponder 在回环地址上模拟信标应答方，每个探测回复 replies 中的全部报文
+func startTestResponder(t *testing.T, replies ...[]byte) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, beaconMaxSize)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !bytes.HasPrefix(buf[:n], []byte(beaconProbePrefix)) {
+				continue
+			}
+			for _, r := range replies {
+				_, _ = conn.WriteToUDP(r, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestCollectBeacons(t *testing.T) {
+	a := httpsBeacon{ChannelIDHash: "aaaa0001", ChannelName: "A", Port: 8443, Version: ProtocolVersion}
+	b := httpsBeacon{ChannelIDHash: "bbbb0002", ChannelName: "B", Port: 9443, Version: ProtocolVersion}
+	// 重复的信标和无效报文应被去重或忽略
+	port := startTestResponder(t,
+		beaconReply(t, a), beaconReply(t, a), beaconReply(t, b), []byte("garbage"))
+
+	peers, err := collectBeacons(port, 300*time.Millisecond)
+	if err != nil {
+		t.Fatalf("collectBeacons: %v", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("got %d peers, want 2: %+v", len(peers), peers)
+	}
+	names := map[string]bool{}
+	for _, p := range peers {
+		names[p.Name] = true
+	}
+	if !names["A"] || !names["B"] {
+		t.Fatalf("unexpected peers: %+v", peers)
+	}
+}
+
+func TestCollectBeaconsNoReply(t *testing.T) {
+	port := startTestResponder(t)
+	peers, err := collectBeacons(port, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("collectBeacons: %v", err)
+	}
+	if len(peers) != 0 {
+		t.Fatalf("got %d peers, want none", len(peers))
+	}
+}
+
+func TestBeaconPortReuse(t *testing.T) {
+	lc := net.ListenConfig{Control: beaconReuseControl}
+	first, err := lc.ListenPacket(context.Background(), "udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("first listen: %v", err)
+	}
+	defer first.Close()
+
+	// 第二个服务端绑定同一端口不应失败
+	second, err := lc.ListenPacket(context.Background(), "udp4", first.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("second listen on %s: %v", first.LocalAddr(), err)
+	}
+	second.Close()
+}
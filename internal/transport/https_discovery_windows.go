@@ -0,0 +1,19 @@
+//go:build windows
+
+package transport
+
+import (
+	"syscall"
+)
+
+// beaconReuseControl 为信标套接字开启地址复用（Windows 无 SO_REUSEPORT，SO_REUSEADDR 即允许共享端口）
+func beaconReuseControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
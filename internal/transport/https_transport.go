@@ -5,7 +5,9 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +45,7 @@ type HTTPSTransport struct {
 	// 统计
 	stats   TransportStats
 	statsMu sync.RWMutex
+	metrics *PeerMetrics // 按对端统计（键为 IP:Port，RTT 由带时间戳的 PING/PONG 测量）
 
 	// 控制
 	ctx       context.Context
@@ -64,6 +67,12 @@ type HTTPSTransport struct {
 	// 服务端信息（仅server模式用于 /info）
 	serverChannelID   string
 	serverChannelName string
+
+	// 局域网发现（UDP信标，见 https_discovery.go）
+	serviceInfo     *ServiceInfo
+	certFingerprint string
+	beaconConn      *net.UDPConn
+	beaconMu        sync.RWMutex
 }
 
 // 轻量日志封装，避免nil检查分散在代码中
@@ -97,11 +106,29 @@ func (t *HTTPSTransport) logError(format string, args ...interface{}) {
 	}
 }
 
+func init() {
+	MustRegister(Registration{
+		Mode:        TransportModeHTTPS,
+		DisplayName: "HTTPS",
+		Description: "TLS WebSocket，适用于可路由网络",
+		New:         func() Transport { return NewHTTPSTransport() },
+		Capabilities: Capabilities{
+			Unicast:     true,
+			Broadcast:   true,
+			Discovery:   true,
+			NeedAddress: true,
+			NeedConnect: true,
+			DefaultPort: 8443,
+		},
+	})
+}
+
 // NewHTTPSTransport 创建HTTPS传输层
 func NewHTTPSTransport() *HTTPSTransport {
 	return &HTTPSTransport{
 		clients:      make(map[string]*websocket.Conn),
 		clientWriteM: make(map[string]*sync.Mutex),
+		metrics:      NewPeerMetrics(),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  4096,
 			WriteBufferSize: 4096,
@@ -162,6 +189,10 @@ func (t *HTTPSTransport) startServer() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", t.handleWebSocket)
 	mux.HandleFunc("/info", t.handleInfo)
+	if t.config.MetricsPath != "" {
+		mux.Handle(t.config.MetricsPath, MetricsHandler(t, t.config.MetricsToken))
+		t.logInfo("Prometheus metrics enabled at %s", t.config.MetricsPath)
+	}
 
 	// 自签名证书场景：启动 TLS 服务器，但允许客户端跳过校验（客户端侧已禁用验证）
 	t.server = &http.Server{
@@ -174,23 +205,36 @@ func (t *HTTPSTransport) startServer() error {
 		},
 	}
 
+	// 确定证书（外部提供或自动生成自签名证书），并计算指纹供发现信标使用
+	certPath, keyPath := t.config.TLSCert, t.config.TLSKey
+	if certPath == "" || keyPath == "" {
+		var genErr error
+		certPath, keyPath, genErr = utils.EnsureSelfSignedCert("./certs", nil, 365)
+		if genErr != nil {
+			// 回退到非TLS（仅当生成失败）
+			t.logWarn("Self-signed cert generation failed: %v, falling back to HTTP", genErr)
+			certPath, keyPath = "", ""
+		} else {
+			t.logInfo("Using self-signed TLS cert: %s", certPath)
+		}
+	}
+	if certPath != "" {
+		if fp, err := utils.CertFingerprint(certPath); err == nil {
+			t.beaconMu.Lock()
+			t.certFingerprint = fp
+			t.beaconMu.Unlock()
+		} else {
+			t.logWarn("Failed to compute cert fingerprint: %v", err)
+		}
+	}
+
 	// 启动服务器
 	go func() {
 		var err error
-		if t.config.TLSCert != "" && t.config.TLSKey != "" {
-			// TLS模式（外部提供证书）
-			err = t.server.ListenAndServeTLS(t.config.TLSCert, t.config.TLSKey)
+		if certPath != "" {
+			err = t.server.ListenAndServeTLS(certPath, keyPath)
 		} else {
-			// 自动生成自签名证书（优先走TLS）
-			certPath, keyPath, genErr := utils.EnsureSelfSignedCert("./certs", nil, 365)
-			if genErr == nil {
-				t.logInfo("Using self-signed TLS cert: %s", certPath)
-				err = t.server.ListenAndServeTLS(certPath, keyPath)
-			} else {
-				// 回退到非TLS（仅当生成失败）
-				t.logWarn("Self-signed cert generation failed: %v, falling back to HTTP", genErr)
-				err = t.server.ListenAndServe()
-			}
+			err = t.server.ListenAndServe()
 		}
 
 		if err != nil && err != http.ErrServerClosed {
@@ -211,6 +255,9 @@ func (t *HTTPSTransport) Stop() error {
 	t.started = false
 	t.cancel()
 
+	// 停止发现信标
+	t.stopBeaconResponder()
+
 	// 关闭服务器
 	if t.server != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -246,7 +293,10 @@ func (t *HTTPSTransport) Connect(target string) error {
 		return fmt.Errorf("already connected")
 	}
 
-	t.mode = "client"
+	// 重连时模式不变，避免与并发的 SendMessage 读取竞争
+	if t.mode != "client" {
+		t.mode = "client"
+	}
 
 	// 构造WebSocket URL：
 	// - 若调用方已提供完整的 ws:// 或 wss:// URL，直接使用
@@ -382,6 +432,7 @@ func (t *HTTPSTransport) send(data []byte) error {
 	t.stats.MessagesSent++
 	t.stats.LastActivity = time.Now()
 	t.statsMu.Unlock()
+	t.metrics.RecordSent(conn.RemoteAddr().String(), len(data), 1)
 
 	return nil
 }
@@ -403,6 +454,8 @@ func (t *HTTPSTransport) broadcast(data []byte) error {
 		}
 		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
 			errors = append(errors, fmt.Errorf("failed to send to %s: %w", clientID, err))
+		} else {
+			t.metrics.RecordSent(conn.RemoteAddr().String(), len(data), 1)
 		}
 		if m, ok := t.clientWriteM[clientID]; ok {
 			m.Unlock()
@@ -449,6 +502,10 @@ func (t *HTTPSTransport) ReceiveMessage() (*Message, error) {
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
 	}
+	if msg.SenderAddr == "" {
+		msg.SenderAddr = conn.RemoteAddr().String()
+	}
+	t.metrics.RecordReceived(conn.RemoteAddr().String(), len(data), 1)
 
 	// 更新统计
 	t.statsMu.Lock()
@@ -466,11 +523,13 @@ func (t *HTTPSTransport) setupPingPong(conn *websocket.Conn) error {
 		return nil
 	}
 
-	// 收到PONG时刷新读期限
-	conn.SetPongHandler(func(string) error {
+	// 收到PONG时刷新读期限，并由回显的时间戳计算 RTT
+	peer := conn.RemoteAddr().String()
+	conn.SetPongHandler(func(appData string) error {
 		if t.pongWait > 0 {
 			conn.SetReadDeadline(time.Now().Add(t.pongWait))
 		}
+		t.metrics.RecordRTT(peer, pongRTT(appData))
 		return nil
 	})
 
@@ -491,7 +550,7 @@ func (t *HTTPSTransport) setupPingPong(conn *websocket.Conn) error {
 						return
 					}
 					t.writeMu.Lock()
-					_ = c.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second))
+					_ = c.WriteControl(websocket.PingMessage, pingPayload(), time.Now().Add(5*time.Second))
 					t.writeMu.Unlock()
 				}
 			}
@@ -593,6 +652,14 @@ func (t *HTTPSTransport) receiveLoop() {
 
 		msg, err := t.ReceiveMessage()
 		if err != nil {
+			// 连接已失效：标记断开，使 IsConnected 如实反映状态（重连循环据此判断）
+			t.connMu.Lock()
+			if t.conn == conn {
+				conn.Close()
+				t.conn = nil
+				t.connected = false
+			}
+			t.connMu.Unlock()
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				return
 			}
@@ -641,13 +708,33 @@ func (t *HTTPSTransport) handleWebSocket(w http.ResponseWriter, r *http.Request)
 		t.logInfo("Client disconnected: %s", clientID)
 	}()
 
-	// Server端也设置心跳：收Pong刷新读期限
-	conn.SetPongHandler(func(string) error {
+	// Server端也设置心跳：收Pong刷新读期限，并周期PING测量 RTT
+	conn.SetPongHandler(func(appData string) error {
 		if t.pongWait > 0 {
 			conn.SetReadDeadline(time.Now().Add(t.pongWait))
 		}
+		t.metrics.RecordRTT(r.RemoteAddr, pongRTT(appData))
 		return nil
 	})
+	done := make(chan struct{})
+	defer close(done)
+	if t.pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(t.pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-t.ctx.Done():
+					return
+				case <-ticker.C:
+					// WriteControl 可与其他写操作并发调用
+					_ = conn.WriteControl(websocket.PingMessage, pingPayload(), time.Now().Add(5*time.Second))
+				}
+			}
+		}()
+	}
 
 	for {
 		if t.pongWait > 0 {
@@ -670,6 +757,7 @@ func (t *HTTPSTransport) handleWebSocket(w http.ResponseWriter, r *http.Request)
 
 		// 设置来源信息
 		msg.SenderAddr = r.RemoteAddr
+		t.metrics.RecordReceived(r.RemoteAddr, len(data), 1)
 
 		// 更新统计
 		t.statsMu.Lock()
@@ -701,16 +789,34 @@ func (t *HTTPSTransport) OnFileReceived(handler FileHandler) error {
 
 // ===== 服务发现 =====
 
-// Discover 发现可用的服务端
+// Discover 发现可用的服务端（UDP广播探测，见 https_discovery.go）
 func (t *HTTPSTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
-	// TODO: 实现服务发现（可以使用mDNS或广播）
-	return nil, fmt.Errorf("not implemented")
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	peers, err := t.discoverBeacons(timeout)
+	if err != nil {
+		return nil, err
+	}
+	t.logDebug("Discovered %d HTTPS servers", len(peers))
+	return peers, nil
 }
 
-// Announce 宣告服务
+// Announce 宣告服务（服务端模式下启动信标应答）
 func (t *HTTPSTransport) Announce(info *ServiceInfo) error {
-	// HTTPS 模式不参与局域网发现，宣告为无操作以避免上层警告
-	return nil
+	if info == nil {
+		return fmt.Errorf("service info cannot be nil")
+	}
+
+	t.beaconMu.Lock()
+	copied := *info
+	t.serviceInfo = &copied
+	t.beaconMu.Unlock()
+
+	if t.mode != "server" {
+		return nil
+	}
+	return t.startBeaconResponder()
 }
 
 // ===== 元数据 =====
@@ -730,6 +836,25 @@ func (t *HTTPSTransport) GetStats() *TransportStats {
 	return &stats
 }
 
+// PeerMetrics 按对端统计
+func (t *HTTPSTransport) PeerMetrics() *PeerMetrics {
+	return t.metrics
+}
+
+// pingPayload PING 携带发送时间（纳秒），对端在 PONG 中原样回显
+func pingPayload() []byte {
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+// pongRTT 由 PONG 回显的时间戳计算 RTT（旧版本对端的 "ping" 负载返回 0）
+func pongRTT(appData string) time.Duration {
+	sent, err := strconv.ParseInt(appData, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Since(time.Unix(0, sent))
+}
+
 // GetClientCount 获取客户端数量（服务端模式）
 func (t *HTTPSTransport) GetClientCount() int {
 	t.clientsMu.RLock()
@@ -756,6 +881,11 @@ func (t *HTTPSTransport) GetRemoteAddr() string {
 	return ""
 }
 
+// SetAutoReconnect 启用/禁用连接断开后的自动重连（由上层接管重连时禁用）
+func (t *HTTPSTransport) SetAutoReconnect(enabled bool) {
+	t.reconnect = enabled
+}
+
 // SetMode 设置模式（"server" or "client"）
 func (t *HTTPSTransport) SetMode(mode string) {
 	t.mode = mode
@@ -770,16 +900,25 @@ func (t *HTTPSTransport) SetChannelInfo(channelID, channelName string) {
 // handleInfo 返回服务端频道基础信息，供客户端在加入前获取 ChannelID
 func (t *HTTPSTransport) handleInfo(w http.ResponseWriter, _ *http.Request) {
 	type infoResp struct {
-		ChannelID   string `json:"channel_id"`
-		ChannelName string `json:"channel_name"`
-		Mode        string `json:"mode"`
-		Version     int    `json:"version"`
-	}
+		ChannelID       string `json:"channel_id"`
+		ChannelIDHash   string `json:"channel_id_hash"`
+		ChannelName     string `json:"channel_name"`
+		Port            int    `json:"port"`
+		CertFingerprint string `json:"cert_fingerprint"`
+		MemberCount     int    `json:"member_count"`
+		Mode            string `json:"mode"`
+		Version         int    `json:"version"`
+	}
+	b := t.buildBeacon()
 	resp := infoResp{
-		ChannelID:   t.serverChannelID,
-		ChannelName: t.serverChannelName,
-		Mode:        string(TransportModeHTTPS),
-		Version:     1,
+		ChannelID:       t.serverChannelID,
+		ChannelIDHash:   b.ChannelIDHash,
+		ChannelName:     b.ChannelName,
+		Port:            b.Port,
+		CertFingerprint: b.CertFingerprint,
+		MemberCount:     b.MemberCount,
+		Mode:            string(TransportModeHTTPS),
+		Version:         1,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newLoopbackPair 在独立虚拟网络上创建一对已连接的服务端/客户端
+func newLoopbackPair(t *testing.T, faults LoopbackFaults) (*LoopbackNetwork, *LoopbackTransport, *LoopbackTransport) {
+	t.Helper()
+
+	network := NewLoopbackNetwork()
+	network.SetFaults(faults)
+
+	srv := NewLoopbackTransportOn(network)
+	srv.SetMode("server")
+	cli := NewLoopbackTransportOn(network)
+	cli.SetMode("client")
+
+	for _, tr := range []*LoopbackTransport{srv, cli} {
+		if err := tr.Init(&Config{Mode: TransportModeLoopback, Port: 7000}); err != nil {
+			t.Fatalf("init: %v", err)
+		}
+		if err := tr.Start(); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+	}
+	if err := cli.Connect("loopback:7000"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Stop()
+		_ = srv.Stop()
+	})
+	return network, srv, cli
+}
+
+// collector 订阅并收集消息ID
+type collector struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (c *collector) handle(msg *Message) {
+	c.mu.Lock()
+	c.ids = append(c.ids, msg.ID)
+	c.mu.Unlock()
+}
+
+func (c *collector) snapshot() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.ids...)
+}
+
+// sendN 客户端向服务端发送 n 条消息，并等待收齐 want 条（或超时）
+func sendN(t *testing.T, cli *LoopbackTransport, col *collector, n, want int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := cli.SendMessage(&Message{ID: fmt.Sprintf("m%03d", i), Type: MessageTypeData}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && len(col.snapshot()) < want {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 留出时间让多余的投递（若有）到达
+	time.Sleep(30 * time.Millisecond)
+	return col.snapshot()
+}
+
+func TestLoopbackOrderedWithJitter(t *testing.T) {
+	_, srv, cli := newLoopbackPair(t, LoopbackFaults{Latency: time.Millisecond, Jitter: 2 * time.Millisecond, Seed: 1})
+	col := &collector{}
+	_ = srv.Subscribe(col.handle)
+
+	got := sendN(t, cli, col, 50, 50)
+	if len(got) != 50 {
+		t.Fatalf("expected 50 messages, got %d", len(got))
+	}
+	for i, id := range got {
+		if id != fmt.Sprintf("m%03d", i) {
+			t.Fatalf("jitter must not reorder: position %d got %s", i, id)
+		}
+	}
+}
+
+func TestLoopbackLoss(t *testing.T) {
+	network, srv, cli := newLoopbackPair(t, LoopbackFaults{LossRate: 1})
+	col := &collector{}
+	_ = srv.Subscribe(col.handle)
+
+	if got := sendN(t, cli, col, 20, 0); len(got) != 0 {
+		t.Fatalf("expected all messages dropped, got %d", len(got))
+	}
+	if stats := network.FaultStats(); stats.Dropped != 20 {
+		t.Fatalf("expected 20 drops, got %+v", stats)
+	}
+}
+
+func TestLoopbackDuplicate(t *testing.T) {
+	network, srv, cli := newLoopbackPair(t, LoopbackFaults{DuplicateRate: 1})
+	col := &collector{}
+	_ = srv.Subscribe(col.handle)
+
+	got := sendN(t, cli, col, 10, 20)
+	if len(got) != 20 {
+		t.Fatalf("expected every message twice (20), got %d", len(got))
+	}
+	if stats := network.FaultStats(); stats.Duplicated != 10 || stats.Delivered != 20 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLoopbackReorder(t *testing.T) {
+	network, srv, cli := newLoopbackPair(t, LoopbackFaults{ReorderRate: 0.3, ReorderDelay: 10 * time.Millisecond, Seed: 7})
+	col := &collector{}
+	_ = srv.Subscribe(col.handle)
+
+	got := sendN(t, cli, col, 50, 50)
+	if len(got) != 50 {
+		t.Fatalf("expected 50 messages, got %d", len(got))
+	}
+	inOrder := true
+	for i, id := range got {
+		if id != fmt.Sprintf("m%03d", i) {
+			inOrder = false
+			break
+		}
+	}
+	if inOrder || network.FaultStats().Reordered == 0 {
+		t.Fatalf("expected reordering, stats %+v", network.FaultStats())
+	}
+}
+
+func TestLoopbackStopWaitsForDelivery(t *testing.T) {
+	_, srv, cli := newLoopbackPair(t, LoopbackFaults{Latency: 50 * time.Millisecond})
+	stopped := make(chan struct{})
+	_ = srv.Subscribe(func(msg *Message) {
+		select {
+		case <-stopped:
+			t.Errorf("handler invoked after Stop returned")
+		default:
+		}
+	})
+
+	_ = cli.SendMessage(&Message{ID: "late", Type: MessageTypeData})
+	_ = srv.Stop()
+	close(stopped)
+	time.Sleep(80 * time.Millisecond)
+}
@@ -0,0 +1,630 @@
+package transport
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/miekg/dns"
+)
+
+// mDNS 服务发布（DNS-SD，RFC 6763）与查询应答（RFC 6762）
+// 参考: docs/PROTOCOL.md - 4.2 服务发现协议
+//
+// 服务端把频道发布为标准 DNS-SD 服务，avahi-browse -r _crosswire._udp 可直接查看：
+//
+//	_services._dns-sd._udp.local. PTR _crosswire._udp.local.
+//	_crosswire._udp.local.        PTR <频道名>._crosswire._udp.local.
+//	<频道名>._crosswire._udp.local. SRV 0 0 <单播旁路端口> crosswire-xxxxxx.local.
+//	<频道名>._crosswire._udp.local. TXT version=1 protocol=server-signed pubkey=... channel=<hash8> members=N
+//	crosswire-xxxxxx.local.        A   <服务端IP>
+//
+// 应答规则：
+//   - 已知答案抑制：查询方在 Answer 段列出的记录且剩余 TTL 不少于一半时不再应答
+//   - QU 问题（qclass 顶位）与来自非 5353 端口的传统单播查询直接单播回复；
+//     传统单播回复回显查询 ID 与问题，TTL 不超过 10 秒
+//   - 组播回复含共享记录（PTR）时随机延迟 20-120ms，同一记录 1 秒内不重复组播
+//   - 启动与记录变化时主动宣告两次（间隔 1 秒），停止时发送 TTL 为 0 的告别包
+//
+// 未实现名称冲突探测（主机名带随机后缀）与截断（TC）查询的已知答案续包。
+
+const (
+	mdnsPort            = 5353
+	mdnsGroup           = "224.0.0.251:5353"
+	mdnsServiceName     = "_crosswire._udp.local."
+	mdnsServicesEnum    = "_services._dns-sd._udp.local."
+	MDNSHostTTL         = 120  // SRV/A 记录 TTL（秒）
+	MDNSServiceTTL      = 4500 // PTR/TXT 记录 TTL（秒）
+	mdnsLegacyTTL       = 10   // 传统单播回复的 TTL 上限
+	mdnsCacheFlush      = 1 << 15
+	mdnsUnicastResponse = 1 << 15
+	mdnsMulticastHold   = time.Second
+	mdnsSharedDelayMin  = 20 * time.Millisecond
+	mdnsSharedDelayMax  = 120 * time.Millisecond
+	mdnsAnnounceCount   = 2
+)
+
+// mdnsResponder DNS-SD 记录与查询应答
+type mdnsResponder struct {
+	mu            sync.Mutex
+	records       []dns.RR
+	lastMulticast map[string]time.Time
+}
+
+// newMDNSResponder 创建应答器
+func newMDNSResponder() *mdnsResponder {
+	return &mdnsResponder{lastMulticast: make(map[string]time.Time)}
+}
+
+// escapeInstanceLabel 将频道名转为单个 DNS 标签（转义点与反斜杠，最长 63 字节）
+func escapeInstanceLabel(name string) string {
+	if name == "" {
+		name = "CrossWire"
+	}
+	for len(name) > 63 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if r == '.' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// serviceRecords 根据当前频道信息构造 DNS-SD 记录
+func (t *MDNSTransport) serviceRecords(memberCount int) []dns.RR {
+	instance := escapeInstanceLabel(t.channelName) + "." + mdnsServiceName
+	host := dns.Fqdn(t.hostname + ".local")
+	hdr := func(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+	}
+
+	port := 0
+	if t.sideConn != nil {
+		port = t.sideConn.LocalAddr().(*net.UDPAddr).Port
+	}
+	txt := []string{
+		"version=1",
+		"protocol=server-signed",
+		fmt.Sprintf("pubkey=%s", base64.StdEncoding.EncodeToString(t.serverPubKey)),
+		fmt.Sprintf("channel=%s", channelIDHash(t.channelID)),
+		fmt.Sprintf("members=%d", memberCount),
+		fmt.Sprintf("segment=%d", MDNSUnicastSegment),
+	}
+
+	records := []dns.RR{
+		&dns.PTR{Hdr: hdr(mdnsServicesEnum, dns.TypePTR, MDNSServiceTTL), Ptr: mdnsServiceName},
+		&dns.PTR{Hdr: hdr(mdnsServiceName, dns.TypePTR, MDNSServiceTTL), Ptr: instance},
+		&dns.SRV{Hdr: hdr(instance, dns.TypeSRV, MDNSHostTTL), Port: uint16(port), Target: host},
+		&dns.TXT{Hdr: hdr(instance, dns.TypeTXT, MDNSServiceTTL), Txt: txt},
+	}
+	if ip := t.localIP.To4(); ip != nil {
+		records = append(records, &dns.A{Hdr: hdr(host, dns.TypeA, MDNSHostTTL), A: ip})
+	}
+	return records
+}
+
+// setRecords 更新发布的记录，有变化时返回 true
+func (r *mdnsResponder) setRecords(records []dns.RR) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(records) == len(r.records) {
+		same := true
+		for i := range records {
+			if !sameRecord(records[i], r.records[i]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false
+		}
+	}
+	r.records = records
+	return true
+}
+
+// sameRecord 比较记录（忽略 TTL 与缓存刷新标记）
+func sameRecord(a, b dns.RR) bool {
+	a, b = dns.Copy(a), dns.Copy(b)
+	a.Header().Class &^= mdnsCacheFlush
+	b.Header().Class &^= mdnsCacheFlush
+	return dns.IsDuplicate(a, b)
+}
+
+// isSharedRecord PTR 为共享记录，其余为唯一记录
+func isSharedRecord(rr dns.RR) bool {
+	return rr.Header().Rrtype == dns.TypePTR
+}
+
+// mdnsReply 待发送的应答
+type mdnsReply struct {
+	msg     *dns.Msg
+	unicast bool          // 单播回复查询方
+	delay   time.Duration // 组播回复前的随机延迟
+}
+
+// respond 计算对一个查询的应答（无匹配记录时返回 nil）
+func (r *mdnsResponder) respond(query *dns.Msg, from *net.UDPAddr, now time.Time) *mdnsReply {
+	if query.Response || query.Opcode != dns.OpcodeQuery {
+		return nil
+	}
+	legacy := from != nil && from.Port != mdnsPort
+	reply := &mdnsReply{unicast: legacy}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var answers []dns.RR
+	for _, q := range query.Question {
+		if q.Qclass&mdnsUnicastResponse != 0 {
+			reply.unicast = true
+		}
+		for _, rr := range r.records {
+			if !matchesQuestion(rr, q) || containsRecord(answers, rr) || knownAnswer(query.Answer, rr) {
+				continue
+			}
+			answers = append(answers, rr)
+		}
+	}
+
+	// 组播：同一记录 1 秒内不重复发送（防止多个查询方同时查询时放大）
+	if !reply.unicast {
+		kept := answers[:0]
+		for _, rr := range answers {
+			if now.Sub(r.lastMulticast[rr.String()]) >= mdnsMulticastHold {
+				kept = append(kept, rr)
+			}
+		}
+		answers = kept
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+
+	// 附加记录：PTR 指向的实例补充 SRV/TXT，SRV 补充主机 A 记录
+	var extra []dns.RR
+	addExtra := func(name string, rrtype uint16) {
+		for _, rr := range r.records {
+			h := rr.Header()
+			if h.Rrtype == rrtype && strings.EqualFold(h.Name, name) &&
+				!containsRecord(answers, rr) && !containsRecord(extra, rr) && !knownAnswer(query.Answer, rr) {
+				extra = append(extra, rr)
+			}
+		}
+	}
+	for _, rr := range answers {
+		if ptr, ok := rr.(*dns.PTR); ok && !strings.EqualFold(ptr.Hdr.Name, mdnsServicesEnum) {
+			addExtra(ptr.Ptr, dns.TypeSRV)
+			addExtra(ptr.Ptr, dns.TypeTXT)
+		}
+	}
+	for _, rr := range append(append([]dns.RR(nil), answers...), extra...) {
+		if srv, ok := rr.(*dns.SRV); ok {
+			addExtra(srv.Target, dns.TypeA)
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Authoritative = true
+	if legacy {
+		// 传统单播（RFC 6762 6.7）：回显 ID 与问题，不带缓存刷新标记
+		msg.Id = query.Id
+		msg.Question = query.Question
+	}
+	msg.Answer = prepareRecords(answers, legacy)
+	msg.Extra = prepareRecords(extra, legacy)
+	reply.msg = msg
+
+	if !reply.unicast {
+		for _, rr := range answers {
+			r.lastMulticast[rr.String()] = now
+			if isSharedRecord(rr) {
+				reply.delay = mdnsSharedDelayMin + time.Duration(rand.Int63n(int64(mdnsSharedDelayMax-mdnsSharedDelayMin)))
+			}
+		}
+	}
+	return reply
+}
+
+// matchesQuestion 记录是否回答该问题
+func matchesQuestion(rr dns.RR, q dns.Question) bool {
+	h := rr.Header()
+	if !strings.EqualFold(h.Name, q.Name) {
+		return false
+	}
+	return q.Qtype == dns.TypeANY || q.Qtype == h.Rrtype
+}
+
+// containsRecord 列表中是否已有相同记录
+func containsRecord(list []dns.RR, rr dns.RR) bool {
+	for _, r := range list {
+		if sameRecord(r, rr) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownAnswer 查询方已缓存该记录且剩余 TTL 不少于一半（RFC 6762 7.1）
+func knownAnswer(known []dns.RR, rr dns.RR) bool {
+	for _, k := range known {
+		if k.Header().Ttl >= rr.Header().Ttl/2 && sameRecord(k, rr) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareRecords 复制记录用于发送：唯一记录带缓存刷新标记，传统单播限制 TTL
+func prepareRecords(records []dns.RR, legacy bool) []dns.RR {
+	out := make([]dns.RR, 0, len(records))
+	for _, rr := range records {
+		c := dns.Copy(rr)
+		h := c.Header()
+		if legacy {
+			if h.Ttl > mdnsLegacyTTL {
+				h.Ttl = mdnsLegacyTTL
+			}
+		} else if !isSharedRecord(c) {
+			h.Class |= mdnsCacheFlush
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// announcement 主动宣告（ttl 为 0 时为告别包）
+func (r *mdnsResponder) announcement(goodbye bool) *dns.Msg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return nil
+	}
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = prepareRecords(r.records, false)
+	if goodbye {
+		for _, rr := range msg.Answer {
+			rr.Header().Ttl = 0
+		}
+	}
+	now := time.Now()
+	for _, rr := range r.records {
+		r.lastMulticast[rr.String()] = now
+	}
+	return msg
+}
+
+// ===== 服务端：发布与应答 =====
+
+// publishService 更新发布的记录，变化时重新宣告
+func (t *MDNSTransport) publishService() {
+	if t.responder == nil || t.conn == nil {
+		return
+	}
+	if !t.responder.setRecords(t.serviceRecords(t.memberCount)) {
+		return
+	}
+	go func() {
+		for i := 0; i < mdnsAnnounceCount; i++ {
+			if msg := t.responder.announcement(false); msg != nil {
+				t.writeMulticast(msg)
+			}
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// sendGoodbye 停止前发送告别包，使缓存立即失效
+func (t *MDNSTransport) sendGoodbye() {
+	if t.responder == nil || t.conn == nil {
+		return
+	}
+	if msg := t.responder.announcement(true); msg != nil {
+		t.writeMulticast(msg)
+	}
+}
+
+// handleQuery 应答 DNS-SD 查询（组播或单播旁路收到）
+func (t *MDNSTransport) handleQuery(query *dns.Msg, from *net.UDPAddr, conn *net.UDPConn) {
+	if t.responder == nil {
+		return
+	}
+	reply := t.responder.respond(query, from, time.Now())
+	if reply == nil {
+		return
+	}
+	if reply.unicast {
+		t.writeTo(conn, reply.msg, from)
+		return
+	}
+	if reply.delay > 0 {
+		time.AfterFunc(reply.delay, func() { t.writeMulticast(reply.msg) })
+		return
+	}
+	t.writeMulticast(reply.msg)
+}
+
+// ===== 客户端：浏览与解析 =====
+
+// mdnsService 解析出的服务实例
+type mdnsService struct {
+	instance string
+	host     string
+	port     int
+	ip       net.IP
+	txt      map[string]string
+	ptr      *dns.PTR  // 用于后续查询的已知答案
+	expires  time.Time // PTR 记录过期时间
+}
+
+// parseServiceResponse 从应答中解析 CrossWire 服务实例
+func parseServiceResponse(msg *dns.Msg, from *net.UDPAddr) []*mdnsService {
+	records := append(append([]dns.RR(nil), msg.Answer...), msg.Extra...)
+	now := time.Now()
+
+	var services []*mdnsService
+	for _, rr := range records {
+		ptr, ok := rr.(*dns.PTR)
+		if !ok || !strings.EqualFold(ptr.Hdr.Name, mdnsServiceName) || ptr.Hdr.Ttl == 0 {
+			continue
+		}
+		svc := &mdnsService{
+			instance: ptr.Ptr,
+			txt:      make(map[string]string),
+			ptr:      ptr,
+			expires:  now.Add(time.Duration(ptr.Hdr.Ttl) * time.Second),
+		}
+		for _, rr := range records {
+			switch v := rr.(type) {
+			case *dns.SRV:
+				if strings.EqualFold(v.Hdr.Name, svc.instance) {
+					svc.host, svc.port = v.Target, int(v.Port)
+				}
+			case *dns.TXT:
+				if strings.EqualFold(v.Hdr.Name, svc.instance) {
+					for _, field := range v.Txt {
+						if k, val, ok := strings.Cut(field, "="); ok {
+							svc.txt[k] = val
+						}
+					}
+				}
+			}
+		}
+		for _, rr := range records {
+			if a, ok := rr.(*dns.A); ok && svc.host != "" && strings.EqualFold(a.Hdr.Name, svc.host) {
+				svc.ip = a.A
+			}
+		}
+		if svc.ip == nil && from != nil {
+			svc.ip = from.IP
+		}
+		services = append(services, svc)
+	}
+	return services
+}
+
+// peerInfo 转换为发现结果
+func (s *mdnsService) peerInfo() *PeerInfo {
+	name := unescapeLabel(strings.TrimSuffix(s.instance, "."+mdnsServiceName))
+	pi := &PeerInfo{
+		ID:            s.instance,
+		Address:       net.JoinHostPort(s.ip.String(), fmt.Sprintf("%d", s.port)),
+		Mode:          TransportModeMDNS,
+		LastSeen:      time.Now(),
+		ChannelIDHash: s.txt["channel"],
+		Version:       ProtocolVersion,
+		Name:          name,
+		Port:          s.port,
+		Metadata:      s.txt,
+	}
+	pi.MemberCount, _ = strconv.Atoi(s.txt["members"])
+	return pi
+}
+
+// unescapeLabel 还原 DNS 表示格式中的转义（\X 与 \DDD）
+func unescapeLabel(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c != '\\' || i+1 >= len(label) {
+			b.WriteByte(c)
+			continue
+		}
+		if i+3 < len(label) && isDigits(label[i+1:i+4]) {
+			n, _ := strconv.Atoi(label[i+1 : i+4])
+			b.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		b.WriteByte(label[i+1])
+		i++
+	}
+	return b.String()
+}
+
+// isDigits 是否全为十进制数字
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// browseQuery 构造服务浏览查询，附带仍有效的已知答案
+func browseQuery(unicastResponse bool, known []*mdnsService) *dns.Msg {
+	q := new(dns.Msg)
+	q.Id = 0
+	qclass := uint16(dns.ClassINET)
+	if unicastResponse {
+		qclass |= mdnsUnicastResponse
+	}
+	q.Question = []dns.Question{{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: qclass}}
+	now := time.Now()
+	for _, svc := range known {
+		remaining := svc.expires.Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+		ptr := dns.Copy(svc.ptr).(*dns.PTR)
+		ptr.Hdr.Ttl = uint32(remaining / time.Second)
+		q.Answer = append(q.Answer, ptr)
+	}
+	return q
+}
+
+// knownServices 已缓存的服务（作为查询的已知答案）
+func (t *MDNSTransport) knownServices() []*mdnsService {
+	t.servicesMu.Lock()
+	defer t.servicesMu.Unlock()
+	out := make([]*mdnsService, 0, len(t.services))
+	for _, svc := range t.services {
+		out = append(out, svc)
+	}
+	return out
+}
+
+// rememberService 缓存服务；客户端尚未确定服务端时据此建立单播旁路
+func (t *MDNSTransport) rememberService(svc *mdnsService) {
+	t.servicesMu.Lock()
+	t.services[strings.ToLower(svc.instance)] = svc
+	t.servicesMu.Unlock()
+
+	if t.mode == "server" || svc.port == 0 || svc.ip == nil {
+		return
+	}
+	if t.channelID != "" && svc.txt["channel"] != channelIDHash(t.channelID) {
+		return
+	}
+	t.peersMu.Lock()
+	adopt := t.serverAddr == nil
+	if adopt {
+		t.serverAddr = &net.UDPAddr{IP: svc.ip, Port: svc.port}
+	}
+	t.peersMu.Unlock()
+	if adopt {
+		fmt.Printf("[MDNSTransport] Resolved server %s via SRV, using unicast side channel %s\n", svc.instance, t.serverAddr)
+		t.registerWithServer()
+	}
+}
+
+// handleServiceResponse 处理包含 CrossWire 服务记录的应答
+func (t *MDNSTransport) handleServiceResponse(msg *dns.Msg, from *net.UDPAddr) bool {
+	services := parseServiceResponse(msg, from)
+	for _, svc := range services {
+		t.rememberService(svc)
+	}
+	return len(services) > 0
+}
+
+// resolveLoop 客户端持续浏览服务，确定服务端单播旁路地址
+// 未解析时每秒查询一次（QU），之后按 TTL 刷新，已知答案抑制使服务端在缓存有效期内不再应答
+func (t *MDNSTransport) resolveLoop() {
+	interval := time.Second
+	for {
+		t.peersMu.Lock()
+		resolved := t.serverAddr != nil
+		t.peersMu.Unlock()
+		if resolved {
+			interval = MDNSRequeryInterval
+		}
+		t.writeMulticast(browseQuery(!resolved, t.knownServices()))
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Discover 发现可用的服务端（DNS-SD 浏览）
+// 参考: docs/PROTOCOL.md - 4.2.2 频道查询
+// 使用临时端口发送传统单播查询，无需启动传输层，也不与本机其他 mDNS 应答器争用 5353 端口
+func (t *MDNSTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open discovery socket: %w", err)
+	}
+	defer conn.Close()
+
+	group, _ := net.ResolveUDPAddr("udp4", mdnsGroup)
+	// 不附带已知答案：一次性浏览需要全部服务端应答
+	query := browseQuery(false, nil)
+	query.Id = dns.Id()
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack query: %w", err)
+	}
+
+	// 按 RFC 6762 5.2 以倍增的间隔（1s、2s、4s）重复查询
+	retries := []time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	sent := 0
+	found := make(map[string]*PeerInfo)
+	var order []string
+	buf := make([]byte, 65536)
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			break
+		}
+		if sent < len(retries) && now.Sub(start) >= retries[sent] {
+			if _, err := conn.WriteToUDP(packet, group); err != nil {
+				return nil, fmt.Errorf("failed to send query: %w", err)
+			}
+			sent++
+		}
+		wait := deadline
+		if sent < len(retries) {
+			if at := start.Add(retries[sent]); at.Before(wait) {
+				wait = at
+			}
+		}
+		conn.SetReadDeadline(wait)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			continue
+		}
+		msg := new(dns.Msg)
+		if msg.Unpack(buf[:n]) != nil || !msg.Response {
+			continue
+		}
+		for _, svc := range parseServiceResponse(msg, from) {
+			t.servicesMu.Lock()
+			t.services[strings.ToLower(svc.instance)] = svc
+			t.servicesMu.Unlock()
+			key := strings.ToLower(svc.instance)
+			if _, ok := found[key]; !ok {
+				order = append(order, key)
+			}
+			found[key] = svc.peerInfo()
+		}
+	}
+
+	peers := make([]*PeerInfo, 0, len(order))
+	for _, key := range order {
+		peers = append(peers, found[key])
+	}
+	return peers, nil
+}
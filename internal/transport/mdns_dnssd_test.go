@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func testResponder(t *testing.T) (*MDNSTransport, *mdnsResponder) {
+	t.Helper()
+	tr := NewMDNSTransport()
+	tr.channelID = "channel-0001"
+	tr.channelName = "红队 Team.1"
+	tr.hostname = "crosswire-abc123"
+	tr.localIP = net.IPv4(192, 168, 1, 10)
+	r := newMDNSResponder()
+	r.setRecords(tr.serviceRecords(3))
+	return tr, r
+}
+
+func TestMDNSResponderBrowse(t *testing.T) {
+	_, r := testResponder(t)
+	peer := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: mdnsPort}
+
+	reply := r.respond(browseQuery(false, nil), peer, time.Now())
+	if reply == nil || reply.unicast {
+		t.Fatalf("expected multicast reply, got %+v", reply)
+	}
+	if reply.delay < mdnsSharedDelayMin || reply.delay > mdnsSharedDelayMax {
+		t.Fatalf("shared record delay = %v", reply.delay)
+	}
+	services := parseServiceResponse(reply.msg, peer)
+	if len(services) != 1 {
+		t.Fatalf("parsed %d services", len(services))
+	}
+	pi := services[0].peerInfo()
+	if pi.Name != "红队 Team.1" || pi.MemberCount != 3 || pi.ChannelIDHash != channelIDHash("channel-0001") {
+		t.Fatalf("peer info = %+v", pi)
+	}
+	if !services[0].ip.Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Fatalf("A record not included as additional record: %v", services[0].ip)
+	}
+	for _, rr := range reply.msg.Extra {
+		if rr.Header().Class&mdnsCacheFlush == 0 {
+			t.Fatalf("unique record without cache-flush bit: %s", rr)
+		}
+	}
+
+	// 1 秒内重复的组播查询不再应答
+	if r.respond(browseQuery(false, nil), peer, time.Now()) != nil {
+		t.Fatal("multicast answer repeated within hold time")
+	}
+}
+
+func TestMDNSResponderKnownAnswer(t *testing.T) {
+	_, r := testResponder(t)
+	peer := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: mdnsPort}
+	svc := parseServiceResponse(r.respond(browseQuery(true, nil), peer, time.Now()).msg, peer)[0]
+
+	// 剩余 TTL 不少于一半：抑制
+	if reply := r.respond(browseQuery(true, []*mdnsService{svc}), peer, time.Now()); reply != nil {
+		t.Fatalf("known answer not suppressed: %v", reply.msg)
+	}
+	// 剩余 TTL 不足一半：重新应答
+	svc.expires = time.Now().Add(time.Duration(MDNSServiceTTL/4) * time.Second)
+	if r.respond(browseQuery(true, []*mdnsService{svc}), peer, time.Now()) == nil {
+		t.Fatal("stale known answer suppressed the reply")
+	}
+}
+
+func TestMDNSResponderUnicast(t *testing.T) {
+	_, r := testResponder(t)
+
+	// QU 问题
+	reply := r.respond(browseQuery(true, nil), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: mdnsPort}, time.Now())
+	if reply == nil || !reply.unicast || reply.delay != 0 {
+		t.Fatalf("QU query: %+v", reply)
+	}
+
+	// 传统单播：回显 ID 与问题，TTL 不超过 10 秒，无缓存刷新标记
+	query := browseQuery(false, nil)
+	query.Id = 4242
+	reply = r.respond(query, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 40000}, time.Now())
+	if reply == nil || !reply.unicast {
+		t.Fatalf("legacy query: %+v", reply)
+	}
+	if reply.msg.Id != 4242 || len(reply.msg.Question) != 1 {
+		t.Fatalf("legacy reply header: id=%d questions=%d", reply.msg.Id, len(reply.msg.Question))
+	}
+	for _, rr := range append(reply.msg.Answer, reply.msg.Extra...) {
+		if rr.Header().Ttl > mdnsLegacyTTL || rr.Header().Class&mdnsCacheFlush != 0 {
+			t.Fatalf("legacy record: %s", rr)
+		}
+	}
+}
+
+func TestMDNSResponderGoodbye(t *testing.T) {
+	_, r := testResponder(t)
+	msg := r.announcement(true)
+	if msg == nil {
+		t.Fatal("no goodbye")
+	}
+	for _, rr := range msg.Answer {
+		if rr.Header().Ttl != 0 {
+			t.Fatalf("goodbye record with TTL: %s", rr)
+		}
+	}
+	if len(parseServiceResponse(msg, nil)) != 0 {
+		t.Fatal("goodbye parsed as live service")
+	}
+}
+
+func TestMDNSSegmentsRoundTrip(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	sender := NewMDNSTransport()
+	sender.serverPrivKey = priv
+	receiver := NewMDNSTransport()
+	receiver.serverPubKey = pub
+
+	payload := bytes.Repeat([]byte("crosswire-"), 400)
+	packets, err := sender.buildSegments(payload, MDNSMulticastSegment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != (len(payload)+MDNSMulticastSegment-1)/MDNSMulticastSegment {
+		t.Fatalf("got %d segments", len(packets))
+	}
+
+	var assembled string
+	for i := len(packets) - 1; i >= 0; i-- { // 乱序到达
+		if len(packets[i]) > ethernetMTU-28 {
+			t.Fatalf("segment %d is %d bytes, exceeds MTU", i, len(packets[i]))
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(packets[i]); err != nil {
+			t.Fatal(err)
+		}
+		if !receiver.verifySignature(msg) {
+			t.Fatalf("segment %d signature invalid", i)
+		}
+		seg, ok := parseSegment(msg)
+		if !ok || seg.size != len(payload) {
+			t.Fatalf("segment %d not parsed", i)
+		}
+		assembled = receiver.assembler.AddChunk(seg.msgID, seg.index, string(seg.data), seg.total)
+	}
+	if assembled != string(payload) {
+		t.Fatal("reassembled payload mismatch")
+	}
+
+	// 服务浏览应答不是消息段
+	_, r := testResponder(t)
+	if _, ok := parseSegment(r.announcement(false)); ok {
+		t.Fatal("service announcement parsed as segment")
+	}
+}
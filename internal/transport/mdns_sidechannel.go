@@ -0,0 +1,239 @@
+package transport
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mDNS 多段消息与单播旁路
+// 参考: docs/PROTOCOL.md - 4.3 消息传输流程
+//
+// 消息按段编码为 DNS 响应，每段一个报文：
+//
+//	<seg>.<msgid>._data._crosswire._udp.local.   TXT msgid=.. seg=i segs=n size=.. ts=..
+//	d.<seg>.<msgid>._data._crosswire._udp.local. TXT <base64 数据，每串不超过 255 字节>
+//
+// 数据名不挂在服务 PTR 下，DNS-SD 浏览器不会把它们当作服务实例。
+// 组播报文单段不超过 MDNSMulticastSegment；服务端的 SRV 记录给出单播旁路端口，
+// 客户端解析后所有消息单播给服务端，服务端对超过一段的广播改为逐个单播给已登记的客户端
+// （客户端解析服务后向旁路发送查询完成登记，之后定期续约），没有已登记客户端时仍分段组播。
+
+const (
+	MDNSMulticastSegment = 768       // 组播单段负载（字节），base64 与签名后不超过以太网 MTU
+	MDNSUnicastSegment   = 16 * 1024 // 单播旁路单段负载（字节）
+	MDNSRequeryInterval  = time.Minute
+	MDNSPeerTimeout      = 3 * time.Minute // 旁路客户端登记有效期
+	mdnsDataDomain       = "._data._crosswire._udp.local."
+	mdnsTXTStringMax     = 255
+	mdnsMaxPacket        = 65536
+)
+
+// mdnsSegment 解析出的消息段
+type mdnsSegment struct {
+	msgID string
+	index int
+	total int
+	size  int
+	data  []byte
+}
+
+// isDataName 是否为消息段记录名
+func isDataName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), mdnsDataDomain)
+}
+
+// segmentPacket 构造一个消息段报文
+func segmentPacket(msgID string, index, total, size int, chunk []byte) *dns.Msg {
+	base := fmt.Sprintf("%d.%s%s", index, msgID, mdnsDataDomain)
+	hdr := func(name string) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 10}
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(chunk)
+	var pieces []string
+	for len(encoded) > mdnsTXTStringMax {
+		pieces = append(pieces, encoded[:mdnsTXTStringMax])
+		encoded = encoded[mdnsTXTStringMax:]
+	}
+	pieces = append(pieces, encoded)
+
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = []dns.RR{
+		&dns.TXT{Hdr: hdr(base), Txt: []string{
+			"version=1",
+			fmt.Sprintf("msgid=%s", msgID),
+			fmt.Sprintf("seg=%d", index),
+			fmt.Sprintf("segs=%d", total),
+			fmt.Sprintf("size=%d", size),
+			fmt.Sprintf("ts=%d", time.Now().Unix()),
+		}},
+		&dns.TXT{Hdr: hdr("d." + base), Txt: pieces},
+	}
+	return msg
+}
+
+// parseSegment 解析消息段报文（非消息段时返回 false）
+func parseSegment(msg *dns.Msg) (*mdnsSegment, bool) {
+	seg := &mdnsSegment{}
+	var encoded strings.Builder
+	found := false
+	for _, rr := range msg.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok || !isDataName(txt.Hdr.Name) {
+			continue
+		}
+		found = true
+		if strings.HasPrefix(txt.Hdr.Name, "d.") {
+			for _, piece := range txt.Txt {
+				encoded.WriteString(piece)
+			}
+			continue
+		}
+		for _, field := range txt.Txt {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch k {
+			case "msgid":
+				seg.msgID = v
+			case "seg":
+				seg.index, _ = strconv.Atoi(v)
+			case "segs":
+				seg.total, _ = strconv.Atoi(v)
+			case "size":
+				seg.size, _ = strconv.Atoi(v)
+			}
+		}
+	}
+	if !found || seg.msgID == "" || seg.total <= 0 || seg.index < 0 || seg.index >= seg.total {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return nil, false
+	}
+	seg.data = data
+	return seg, true
+}
+
+// buildSegments 将负载切分为已签名的段报文
+func (t *MDNSTransport) buildSegments(payload []byte, segSize int) ([][]byte, error) {
+	msgID := generateMessageID()
+	chunks := chunkData(payload, segSize)
+	if len(chunks) == 0 {
+		chunks = [][]byte{{}}
+	}
+
+	packets := make([][]byte, 0, len(chunks))
+	for i, chunk := range chunks {
+		msg := segmentPacket(msgID, i, len(chunks), len(payload), chunk)
+		if len(t.serverPrivKey) == ed25519.PrivateKeySize {
+			t.addSignature(msg)
+		}
+		packet, err := msg.Pack()
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack segment %d: %w", i, err)
+		}
+		packets = append(packets, packet)
+	}
+
+	// 组播环回会把自己发出的段送回来
+	t.markMessageAsSeen(msgID)
+	return packets, nil
+}
+
+// unicastTargets 可走单播旁路的目标：客户端为服务端，服务端为已登记的客户端
+func (t *MDNSTransport) unicastTargets() []*net.UDPAddr {
+	t.peersMu.Lock()
+	defer t.peersMu.Unlock()
+
+	if t.mode != "server" {
+		if t.serverAddr == nil {
+			return nil
+		}
+		return []*net.UDPAddr{t.serverAddr}
+	}
+
+	cutoff := time.Now().Add(-MDNSPeerTimeout)
+	targets := make([]*net.UDPAddr, 0, len(t.sidePeers))
+	for key, peer := range t.sidePeers {
+		if peer.lastSeen.Before(cutoff) {
+			delete(t.sidePeers, key)
+			continue
+		}
+		targets = append(targets, peer.addr)
+	}
+	return targets
+}
+
+// sidePeer 通过单播旁路登记的客户端
+type sidePeer struct {
+	addr     *net.UDPAddr
+	lastSeen time.Time
+}
+
+// touchPeer 服务端记录旁路客户端（收到旁路报文即续约）
+func (t *MDNSTransport) touchPeer(addr *net.UDPAddr) {
+	if t.mode != "server" || addr == nil {
+		return
+	}
+	t.peersMu.Lock()
+	defer t.peersMu.Unlock()
+	key := addr.String()
+	if _, ok := t.sidePeers[key]; !ok {
+		fmt.Printf("[MDNSTransport] Client %s registered on unicast side channel\n", key)
+	}
+	t.sidePeers[key] = &sidePeer{addr: addr, lastSeen: time.Now()}
+}
+
+// registerWithServer 客户端向服务端旁路发送服务查询完成登记（服务端单播回复记录）
+func (t *MDNSTransport) registerWithServer() {
+	t.peersMu.Lock()
+	server := t.serverAddr
+	t.peersMu.Unlock()
+	if server == nil || t.sideConn == nil {
+		return
+	}
+	query := browseQuery(false, nil)
+	query.Id = dns.Id()
+	t.peersMu.Lock()
+	t.probeID, t.probeSentAt = query.Id, time.Now()
+	t.peersMu.Unlock()
+	t.writeTo(t.sideConn, query, server)
+}
+
+// handleProbeReply 服务端对登记查询的单播应答即一次 RTT 采样
+func (t *MDNSTransport) handleProbeReply(msg *dns.Msg, from *net.UDPAddr) {
+	t.peersMu.Lock()
+	var rtt time.Duration
+	if t.serverAddr != nil && from.String() == t.serverAddr.String() && msg.Id == t.probeID && !t.probeSentAt.IsZero() {
+		rtt = time.Since(t.probeSentAt)
+		t.probeSentAt = time.Time{}
+	}
+	t.peersMu.Unlock()
+	t.metrics.RecordRTT(from.String(), rtt)
+}
+
+// sideChannelLoop 客户端定期续约旁路登记
+func (t *MDNSTransport) sideChannelLoop() {
+	ticker := time.NewTicker(MDNSPeerTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.registerWithServer()
+		}
+	}
+}
@@ -13,27 +13,42 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hashicorp/mdns"
 	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
 )
 
-// MDNSTransport mDNS传输层实现（纯宣告包模式）
-// 使用DNS Response包（Announcement）进行所有通信
+// MDNSTransport mDNS传输层实现
+// 频道发布为标准 DNS-SD 服务（mdns_dnssd.go），消息分段编码为 DNS 响应（mdns_sidechannel.go）：
+// 小消息组播，大载荷经 SRV 协商的单播旁路发送
 // 参考: docs/PROTOCOL.md - 4. mDNS传输协议
 type MDNSTransport struct {
 	config *Config
 	mode   string // "server" or "client"
 
 	// UDP连接
-	conn *net.UDPConn
+	conn     *net.UDPConn // 组播 224.0.0.251:5353
+	sideConn *net.UDPConn // 单播旁路（服务端端口通过 SRV 宣告）
 
-	// mDNS服务器（仅用于服务发现）
-	mdnsServer *mdns.Server
+	// 服务发布与查询应答（仅服务端）
+	responder   *mdnsResponder
+	memberCount int
+
+	// 已发现的服务（客户端查询的已知答案）
+	services   map[string]*mdnsService
+	servicesMu sync.Mutex
 
 	// 服务器信息
-	serverAddr    *net.UDPAddr
-	serverPubKey  []byte // Ed25519公钥（验证签名用）
-	serverPrivKey []byte // Ed25519私钥（签名用）
+	serverAddr    *net.UDPAddr // 服务端单播旁路地址（客户端）
+	serverPubKey  []byte       // Ed25519公钥（验证签名用）
+	serverPrivKey []byte       // Ed25519私钥（签名用）
+
+	// 单播旁路登记的客户端（服务端）
+	sidePeers map[string]*sidePeer
+	peersMu   sync.Mutex
+
+	// 登记查询（客户端，应答用于测量 RTT）
+	probeID     uint16
+	probeSentAt time.Time
 
 	// 加密
 	channelKey []byte // AES-256密钥
@@ -58,16 +73,13 @@ type MDNSTransport struct {
 	// 统计
 	stats   TransportStats
 	statsMu sync.RWMutex
+	metrics *PeerMetrics // 按对端统计（键为 IP:Port）
 
 	// 控制
 	ctx       context.Context
 	cancel    context.CancelFunc
 	started   bool
 	connected bool
-
-	// mDNS监听（仅用于服务发现）
-	entriesCh chan *mdns.ServiceEntry
-	closeCh   chan struct{}
 }
 
 // MessageAssembler 消息重组器
@@ -82,6 +94,24 @@ type ChunkSet struct {
 	data     map[int]string
 	total    int
 	lastSeen time.Time
+	source   string // 发送方地址（超时统计用）
+}
+
+func init() {
+	MustRegister(Registration{
+		Mode:        TransportModeMDNS,
+		DisplayName: "mDNS",
+		Description: "mDNS/DNS-SD 服务记录传输，小消息组播，大载荷走 SRV 协商的单播旁路",
+		New:         func() Transport { return NewMDNSTransport() },
+		Capabilities: Capabilities{
+			Unicast:       true,
+			Broadcast:     true,
+			FileTransfer:  true,
+			Discovery:     true,
+			MTU:           MDNSMulticastSegment,
+			NeedInterface: true,
+		},
+	})
 }
 
 // NewMDNSTransport 创建mDNS传输层
@@ -89,8 +119,9 @@ func NewMDNSTransport() *MDNSTransport {
 	return &MDNSTransport{
 		seenMsgs:  make(map[string]time.Time),
 		assembler: NewMessageAssembler(),
-		entriesCh: make(chan *mdns.ServiceEntry, 100),
-		closeCh:   make(chan struct{}),
+		services:  make(map[string]*mdnsService),
+		sidePeers: make(map[string]*sidePeer),
+		metrics:   NewPeerMetrics(),
 	}
 }
 
@@ -130,85 +161,55 @@ func (t *MDNSTransport) Start() error {
 	t.localIP = t.getLocalIP()
 	t.hostname = fmt.Sprintf("crosswire-%s", generateShortID())
 
-	// 创建UDP连接（监听5353端口）
-	addr := &net.UDPAddr{
-		IP:   net.IPv4zero,
-		Port: 5353,
+	// 加入 mDNS 组播组（端口复用，可与系统的 avahi/Bonjour 共存）
+	var iface *net.Interface
+	if t.config.Interface != "" {
+		ifi, err := net.InterfaceByName(t.config.Interface)
+		if err != nil {
+			return fmt.Errorf("failed to find interface %s: %w", t.config.Interface, err)
+		}
+		iface = ifi
 	}
-	conn, err := net.ListenUDP("udp4", addr)
+	group, _ := net.ResolveUDPAddr("udp4", mdnsGroup)
+	conn, err := net.ListenMulticastUDP("udp4", iface, group)
 	if err != nil {
-		return fmt.Errorf("failed to create UDP connection: %w", err)
+		return fmt.Errorf("failed to join mDNS group: %w", err)
+	}
+	// 同机运行服务端与客户端时需要组播环回
+	if err := ipv4.NewPacketConn(conn).SetMulticastLoopback(true); err != nil {
+		fmt.Printf("[MDNSTransport] Warning: failed to enable multicast loopback: %v\n", err)
 	}
 	t.conn = conn
 
-	t.started = true
-
-	// 服务端模式：注册mDNS服务发现
-	if t.mode == "server" {
-		if err := t.startServer(); err != nil {
-			return err
-		}
+	// 单播旁路
+	sideConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open unicast side channel: %w", err)
 	}
+	t.sideConn = sideConn
 
-	// 启动宣告包接收循环（客户端和服务端都需要）
-	go t.receiveAnnouncements()
+	t.started = true
+
+	// 接收循环（组播与旁路）
+	go t.receiveLoop(t.conn)
+	go t.receiveLoop(t.sideConn)
 
 	// 启动清理协程
 	go t.cleanupLoop()
 
-	fmt.Printf("mDNS transport started in %s mode (IP: %s)\n", t.mode, t.localIP)
-	return nil
-}
-
-// startServer 启动服务端
-func (t *MDNSTransport) startServer() error {
-	// 注册频道发现服务
-	// 参考: docs/PROTOCOL.md - 4.2.1 服务注册
-	port := t.conn.LocalAddr().(*net.UDPAddr).Port
-
-	// 获取本地IP
-	localIP := t.getLocalIP()
-
-	// 编码服务器公钥
-	pubKeyB64 := base64.StdEncoding.EncodeToString(t.serverPubKey)
-
-	info := &mdns.MDNSService{
-		Instance: t.channelName,
-		Service:  "_crosswire._udp",
-		Domain:   "local",
-		HostName: "crosswire-server.local",
-		Port:     port,
-		IPs:      []net.IP{localIP},
-		TXT: []string{
-			"version=1",
-			"protocol=server-signed",
-			fmt.Sprintf("pubkey=%s", pubKeyB64),
-			fmt.Sprintf("channel=%s", t.channelID[:8]), // 前8字符
-		},
-	}
-
-	// 创建mDNS服务实例
-	server, err := mdns.NewMDNSService(
-		info.Instance,
-		info.Service,
-		info.Domain,
-		info.HostName,
-		info.Port,
-		nil,
-		info.TXT,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create mDNS service: %w", err)
-	}
-
-	mdnsServer, err := mdns.NewServer(&mdns.Config{Zone: server})
-	if err != nil {
-		return fmt.Errorf("failed to start mDNS server: %w", err)
+	if t.mode == "server" {
+		// 服务端：发布 DNS-SD 服务并应答查询
+		t.responder = newMDNSResponder()
+		t.publishService()
+	} else {
+		// 客户端：浏览服务，解析 SRV 得到服务端旁路地址
+		go t.resolveLoop()
+		go t.sideChannelLoop()
 	}
 
-	t.mdnsServer = mdnsServer
-
-	fmt.Printf("mDNS server registered: %s (port %d)\n", t.channelName, port)
+	fmt.Printf("mDNS transport started in %s mode (IP: %s, side channel: %s)\n",
+		t.mode, t.localIP, t.sideConn.LocalAddr())
 	return nil
 }
 
@@ -218,19 +219,19 @@ func (t *MDNSTransport) Stop() error {
 		return nil
 	}
 
+	// 告别包使其他主机立即清除缓存的服务记录
+	t.sendGoodbye()
+
 	t.started = false
 	t.cancel()
-	close(t.closeCh)
-
-	// 关闭mDNS服务器
-	if t.mdnsServer != nil {
-		t.mdnsServer.Shutdown()
-	}
 
 	// 关闭UDP连接
 	if t.conn != nil {
 		t.conn.Close()
 	}
+	if t.sideConn != nil {
+		t.sideConn.Close()
+	}
 
 	return nil
 }
@@ -238,17 +239,20 @@ func (t *MDNSTransport) Stop() error {
 // ===== 连接管理 =====
 
 // Connect 连接到服务器（客户端模式）
+// target 为服务端单播旁路地址 "IP:Port"（即 SRV 记录中的端口）；未调用时由服务浏览自动解析
 func (t *MDNSTransport) Connect(target string) error {
-	// target 格式: "IP:Port"
 	t.mode = "client"
 
 	addr, err := net.ResolveUDPAddr("udp4", target)
 	if err != nil {
 		return fmt.Errorf("invalid server address: %w", err)
 	}
+	t.peersMu.Lock()
 	t.serverAddr = addr
+	t.peersMu.Unlock()
 
 	t.connected = true
+	t.registerWithServer()
 
 	fmt.Printf("Connected to mDNS server %s\n", target)
 	return nil
@@ -257,7 +261,9 @@ func (t *MDNSTransport) Connect(target string) error {
 // Disconnect 断开连接
 func (t *MDNSTransport) Disconnect() error {
 	t.connected = false
+	t.peersMu.Lock()
 	t.serverAddr = nil
+	t.peersMu.Unlock()
 	return nil
 }
 
@@ -268,50 +274,57 @@ func (t *MDNSTransport) IsConnected() bool {
 
 // ===== 消息收发 =====
 
-// SendMessage 发送消息（纯宣告包模式）
+// SendMessage 发送消息
 // 参考: docs/PROTOCOL.md - 4.3 消息传输流程
+// 客户端已解析服务端时单播给服务端；服务端超过一段的广播单播给已登记的客户端；其余分段组播
 func (t *MDNSTransport) SendMessage(msg *Message) error {
-	if t.mode == "server" {
-		// 服务器模式：多播宣告给所有客户端
-		return t.sendAnnouncement(msg, true)
-	} else {
-		// 客户端模式：广播宣告（因为可能不知道服务器地址）
-		return t.sendAnnouncement(msg, true)
+	if t.conn == nil {
+		return fmt.Errorf("transport not started")
 	}
-}
 
-// sendAnnouncement 发送宣告包（核心方法）
-func (t *MDNSTransport) sendAnnouncement(msg *Message, multicast bool) error {
-	// 创建宣告包
-	announcement := t.createAnnouncementPacket(msg.Payload)
+	var targets []*net.UDPAddr
+	if t.mode != "server" || len(msg.Payload) > MDNSMulticastSegment {
+		targets = t.unicastTargets()
+	}
 
-	// 序列化
-	packet, err := announcement.Pack()
+	segSize := MDNSMulticastSegment
+	if len(targets) > 0 {
+		segSize = MDNSUnicastSegment
+	}
+	packets, err := t.buildSegments(msg.Payload, segSize)
 	if err != nil {
-		return fmt.Errorf("failed to pack announcement: %w", err)
+		return err
 	}
 
-	// 发送
-	var targetAddr *net.UDPAddr
-	if multicast {
-		// 多播到 224.0.0.251:5353
-		targetAddr, _ = net.ResolveUDPAddr("udp4", "224.0.0.251:5353")
-	} else if t.serverAddr != nil {
-		// 单播到服务器
-		targetAddr = t.serverAddr
+	sent := 0
+	if len(targets) == 0 {
+		group, _ := net.ResolveUDPAddr("udp4", mdnsGroup)
+		for _, packet := range packets {
+			if _, err := t.conn.WriteToUDP(packet, group); err != nil {
+				return fmt.Errorf("failed to send announcement: %w", err)
+			}
+			sent += len(packet)
+		}
+		t.metrics.RecordSent(BroadcastPeer, sent, len(packets))
 	} else {
-		// 默认多播
-		targetAddr, _ = net.ResolveUDPAddr("udp4", "224.0.0.251:5353")
-	}
-
-	_, err = t.conn.WriteToUDP(packet, targetAddr)
-	if err != nil {
-		return fmt.Errorf("failed to send announcement: %w", err)
+		for _, target := range targets {
+			n, frames := 0, 0
+			for _, packet := range packets {
+				if _, err := t.sideConn.WriteToUDP(packet, target); err != nil {
+					fmt.Printf("[MDNSTransport] Unicast to %s failed: %v\n", target, err)
+					break
+				}
+				n += len(packet)
+				frames++
+			}
+			sent += n
+			t.metrics.RecordSent(target.String(), n, frames)
+		}
 	}
 
 	// 更新统计
 	t.statsMu.Lock()
-	t.stats.BytesSent += uint64(len(packet))
+	t.stats.BytesSent += uint64(sent)
 	t.stats.MessagesSent++
 	t.stats.LastActivity = time.Now()
 	t.statsMu.Unlock()
@@ -319,96 +332,28 @@ func (t *MDNSTransport) sendAnnouncement(msg *Message, multicast bool) error {
 	return nil
 }
 
-// createAnnouncementPacket 创建宣告包
-func (t *MDNSTransport) createAnnouncementPacket(data []byte) *dns.Msg {
-	msg := new(dns.Msg)
-	msg.Response = true      // QR=1 (Response)
-	msg.Authoritative = true // AA=1 (Authoritative)
-	msg.RecursionDesired = false
-
-	msgID := generateMessageID()
-	serviceName := fmt.Sprintf("%s.msg._crosswire._tcp.local.", msgID)
-
-	// 1. PTR记录（服务类型）
-	ptr := &dns.PTR{
-		Hdr: dns.RR_Header{
-			Name:   "_crosswire._tcp.local.",
-			Rrtype: dns.TypePTR,
-			Class:  dns.ClassINET,
-			Ttl:    10, // 短TTL，消息快速过期
-		},
-		Ptr: serviceName,
-	}
-	msg.Answer = append(msg.Answer, ptr)
-
-	// 2. SRV记录（发送者信息）
-	srv := &dns.SRV{
-		Hdr: dns.RR_Header{
-			Name:   serviceName,
-			Rrtype: dns.TypeSRV,
-			Class:  dns.ClassINET,
-			Ttl:    10,
-		},
-		Priority: 0,
-		Weight:   0,
-		Port:     5353,
-		Target:   dns.Fqdn(t.hostname + ".local"),
-	}
-	msg.Answer = append(msg.Answer, srv)
-
-	// 3. A记录（发送者IP）
-	a := &dns.A{
-		Hdr: dns.RR_Header{
-			Name:   dns.Fqdn(t.hostname + ".local"),
-			Rrtype: dns.TypeA,
-			Class:  dns.ClassINET,
-			Ttl:    10,
-		},
-		A: t.localIP,
-	}
-	msg.Answer = append(msg.Answer, a)
+// writeMulticast 组播发送一个 DNS 报文
+func (t *MDNSTransport) writeMulticast(msg *dns.Msg) {
+	group, _ := net.ResolveUDPAddr("udp4", mdnsGroup)
+	t.writeTo(t.conn, msg, group)
+}
 
-	// 4. TXT记录-元数据
-	metaTXT := &dns.TXT{
-		Hdr: dns.RR_Header{
-			Name:   serviceName,
-			Rrtype: dns.TypeTXT,
-			Class:  dns.ClassINET,
-			Ttl:    10,
-		},
-		Txt: []string{
-			"version=1.0",
-			fmt.Sprintf("msgid=%s", msgID),
-			fmt.Sprintf("ts=%d", time.Now().Unix()),
-			fmt.Sprintf("size=%d", len(data)),
-		},
+// writeTo 经指定连接发送一个 DNS 报文
+func (t *MDNSTransport) writeTo(conn *net.UDPConn, msg *dns.Msg, addr *net.UDPAddr) {
+	if conn == nil || addr == nil {
+		return
 	}
-	msg.Answer = append(msg.Answer, metaTXT)
-
-	// 5-N. TXT记录-数据载荷
-	chunks := chunkData(data, 240) // 每块240字节
-	for i, chunk := range chunks {
-		dataTXT := &dns.TXT{
-			Hdr: dns.RR_Header{
-				Name:   dns.Fqdn(fmt.Sprintf("data%d.%s.local", i, msgID)),
-				Rrtype: dns.TypeTXT,
-				Class:  dns.ClassINET,
-				Ttl:    10,
-			},
-			Txt: []string{base64.StdEncoding.EncodeToString(chunk)},
-		}
-		msg.Answer = append(msg.Answer, dataTXT)
+	packet, err := msg.Pack()
+	if err != nil {
+		fmt.Printf("[MDNSTransport] Failed to pack DNS message: %v\n", err)
+		return
 	}
-
-	// 添加签名（如果有私钥）
-	if len(t.serverPrivKey) == ed25519.PrivateKeySize {
-		t.addSignature(msg)
+	if _, err := conn.WriteToUDP(packet, addr); err != nil {
+		fmt.Printf("[MDNSTransport] Failed to send to %s: %v\n", addr, err)
 	}
-
-	return msg
 }
 
-// addSignature 添加签名到宣告包
+// addSignature 添加签名到段报文
 func (t *MDNSTransport) addSignature(msg *dns.Msg) {
 	// 计算Answer Section的哈希
 	var data []byte
@@ -438,9 +383,9 @@ func (t *MDNSTransport) addSignature(msg *dns.Msg) {
 	msg.Extra = append(msg.Extra, sigTXT)
 }
 
-// receiveAnnouncements 接收宣告包
-func (t *MDNSTransport) receiveAnnouncements() {
-	buf := make([]byte, 2048)
+// receiveLoop 接收组播或单播旁路上的报文
+func (t *MDNSTransport) receiveLoop(conn *net.UDPConn) {
+	buf := make([]byte, mdnsMaxPacket)
 
 	for {
 		select {
@@ -449,8 +394,11 @@ func (t *MDNSTransport) receiveAnnouncements() {
 		default:
 		}
 
-		n, addr, err := t.conn.ReadFromUDP(buf)
+		n, addr, err := conn.ReadFromUDP(buf)
 		if err != nil {
+			if t.ctx.Err() != nil {
+				return
+			}
 			continue
 		}
 
@@ -460,103 +408,80 @@ func (t *MDNSTransport) receiveAnnouncements() {
 			continue
 		}
 
-		// 只处理响应包（宣告包）
-		if !msg.Response {
-			continue
-		}
-
-		// 过滤非CrossWire宣告
-		if !t.isCrossWireAnnouncement(msg) {
-			continue
-		}
-
-		// 提取数据
-		data, metadata := t.extractDataFromAnnouncement(msg)
-		if len(data) == 0 {
-			continue
-		}
-
-		// 检查是否已处理（防重放）
-		msgID := metadata["msgid"]
-		if t.hasSeenMessage(msgID) {
-			continue
+		// 旁路上的任何报文都视为客户端登记/续约
+		if conn == t.sideConn {
+			t.touchPeer(addr)
 		}
-		t.markMessageAsSeen(msgID)
 
-		// 验证签名
-		if len(t.serverPubKey) == ed25519.PublicKeySize {
-			if !t.verifySignature(msg) {
-				fmt.Println("Invalid signature, possible attack!")
-				continue
+		switch {
+		case !msg.Response:
+			t.handleQuery(msg, addr, conn)
+		default:
+			if seg, ok := parseSegment(msg); ok {
+				t.handleSegment(msg, seg, n, addr)
+			} else if t.mode != "server" {
+				if conn == t.sideConn {
+					t.handleProbeReply(msg, addr)
+				}
+				t.handleServiceResponse(msg, addr)
 			}
 		}
-
-		// 更新统计
-		t.statsMu.Lock()
-		t.stats.BytesReceived += uint64(n)
-		t.stats.MessagesRecv++
-		t.stats.LastActivity = time.Now()
-		t.statsMu.Unlock()
-
-		// 构造消息
-		message := &Message{
-			Payload:    data,
-			SenderAddr: addr.String(),
-			Timestamp:  time.Now(),
-		}
-
-		// 调用处理函数
-		if t.handler != nil {
-			go t.handler(message)
-		}
 	}
 }
 
-// isCrossWireAnnouncement 判断是否是CrossWire宣告
-func (t *MDNSTransport) isCrossWireAnnouncement(msg *dns.Msg) bool {
-	for _, rr := range msg.Answer {
-		if ptr, ok := rr.(*dns.PTR); ok {
-			if strings.Contains(ptr.Hdr.Name, "_crosswire") {
-				return true
-			}
+// handleSegment 处理一个消息段，收齐后交给上层
+func (t *MDNSTransport) handleSegment(msg *dns.Msg, seg *mdnsSegment, size int, addr *net.UDPAddr) {
+	// 检查是否已处理（防重放，也过滤组播环回的本机消息）
+	if t.hasSeenMessage(seg.msgID) {
+		return
+	}
+
+	// 客户端验证服务端签名（客户端发往服务端的消息不签名）
+	if t.mode != "server" && len(t.serverPubKey) == ed25519.PublicKeySize {
+		if !t.verifySignature(msg) {
+			t.metrics.RecordSignatureFailure(addr.String())
+			fmt.Println("Invalid signature, possible attack!")
+			return
 		}
 	}
-	return false
-}
 
-// extractDataFromAnnouncement 从宣告包提取数据
-func (t *MDNSTransport) extractDataFromAnnouncement(msg *dns.Msg) ([]byte, map[string]string) {
-	metadata := make(map[string]string)
-	var dataChunks []string
+	t.statsMu.Lock()
+	t.stats.BytesReceived += uint64(size)
+	t.statsMu.Unlock()
+	t.metrics.RecordReceived(addr.String(), size, 1)
 
-	for _, rr := range msg.Answer {
-		if txt, ok := rr.(*dns.TXT); ok {
-			// 判断是元数据还是数据
-			if strings.Contains(txt.Hdr.Name, "data") {
-				// 数据块
-				if len(txt.Txt) > 0 {
-					dataChunks = append(dataChunks, txt.Txt[0])
-				}
-			} else {
-				// 元数据
-				for _, field := range txt.Txt {
-					parts := strings.SplitN(field, "=", 2)
-					if len(parts) == 2 {
-						metadata[parts[0]] = parts[1]
-					}
-				}
-			}
+	data := seg.data
+	if seg.total > 1 {
+		assembled := t.assembler.AddChunkFrom(seg.msgID, addr.String(), seg.index, string(seg.data), seg.total)
+		if assembled == "" {
+			return
 		}
+		data = []byte(assembled)
 	}
+	if len(data) == 0 || len(data) != seg.size {
+		return
+	}
+	t.markMessageAsSeen(seg.msgID)
 
-	// 重组数据
-	var allData []byte
-	for _, chunk := range dataChunks {
-		decoded, _ := base64.StdEncoding.DecodeString(chunk)
-		allData = append(allData, decoded...)
+	// 更新统计
+	t.statsMu.Lock()
+	t.stats.MessagesRecv++
+	t.stats.LastActivity = time.Now()
+	t.statsMu.Unlock()
+
+	// 构造消息
+	message := &Message{
+		ID:          seg.msgID,
+		Payload:     data,
+		SenderAddr:  addr.String(),
+		Timestamp:   time.Now(),
+		TotalChunks: uint16(seg.total),
 	}
 
-	return allData, metadata
+	// 调用处理函数
+	if t.handler != nil {
+		go t.handler(message)
+	}
 }
 
 // verifySignature 验证签名
@@ -655,7 +580,9 @@ func (t *MDNSTransport) cleanupLoop() {
 			return
 		case <-ticker.C:
 			t.cleanupSeenMessages()
-			t.assembler.Cleanup()
+			for _, source := range t.assembler.Cleanup() {
+				t.metrics.RecordReassemblyTimeout(source)
+			}
 		}
 	}
 }
@@ -736,6 +663,11 @@ func splitIntoChunks(s string, chunkSize int) []string {
 
 // AddChunk 添加分块
 func (a *MessageAssembler) AddChunk(msgID string, seq int, data string, total int) string {
+	return a.AddChunkFrom(msgID, "", seq, data, total)
+}
+
+// AddChunkFrom 添加分块并记录发送方
+func (a *MessageAssembler) AddChunkFrom(msgID, source string, seq int, data string, total int) string {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
@@ -744,6 +676,7 @@ func (a *MessageAssembler) AddChunk(msgID string, seq int, data string, total in
 			data:     make(map[int]string),
 			total:    total,
 			lastSeen: time.Now(),
+			source:   source,
 		}
 	}
 
@@ -785,17 +718,20 @@ func (a *MessageAssembler) assemble(set *ChunkSet) string {
 	return assembled.String()
 }
 
-// Cleanup 清理超时的分块
-func (a *MessageAssembler) Cleanup() {
+// Cleanup 清理超时的分块，返回超时消息的发送方
+func (a *MessageAssembler) Cleanup() []string {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
+	var expired []string
 	cutoff := time.Now().Add(-5 * time.Minute)
 	for msgID, set := range a.chunks {
 		if set.lastSeen.Before(cutoff) {
 			delete(a.chunks, msgID)
+			expired = append(expired, set.source)
 		}
 	}
+	return expired
 }
 
 // ===== 其他接口实现 =====
@@ -820,63 +756,12 @@ func (t *MDNSTransport) OnFileReceived(handler FileHandler) error {
 	return nil
 }
 
-// Discover 发现可用的服务端
-// 参考: docs/PROTOCOL.md - 4.2.2 频道查询
-func (t *MDNSTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
-	var peers []*PeerInfo
-	entriesCh := make(chan *mdns.ServiceEntry, 10)
-
-	// 查询CrossWire服务
-	params := &mdns.QueryParam{
-		Service:             "_crosswire._udp",
-		Domain:              "local",
-		Timeout:             timeout,
-		Entries:             entriesCh,
-		WantUnicastResponse: false,
-	}
-
-	go mdns.Query(params)
-
-	// 收集结果
-	deadline := time.After(timeout)
-	for {
-		select {
-		case entry := <-entriesCh:
-			if entry == nil {
-				continue
-			}
-
-			// 解析服务器公钥和频道ID
-			var channelHash string
-			for _, txt := range entry.InfoFields {
-				if strings.HasPrefix(txt, "pubkey=") {
-					// pubKey = txt[7:] // TODO: 用于验证服务器签名
-				} else if strings.HasPrefix(txt, "channel=") {
-					channelHash = txt[8:]
-				}
-			}
-
-			peer := &PeerInfo{
-				ID:            entry.Name,
-				Address:       fmt.Sprintf("%s:%d", entry.AddrV4, entry.Port),
-				Mode:          TransportModeMDNS,
-				LastSeen:      time.Now(),
-				ChannelIDHash: channelHash,
-				Version:       1,
-			}
-			peers = append(peers, peer)
-
-		case <-deadline:
-			return peers, nil
-		}
-	}
-}
-
-// Announce 宣告服务
+// Announce 宣告服务（更新 DNS-SD 记录，有变化时重新宣告）
 func (t *MDNSTransport) Announce(info *ServiceInfo) error {
-	// 在Start()中已实现
 	t.channelID = info.ChannelID
 	t.channelName = info.ChannelName
+	t.memberCount = info.CurrentMembers
+	t.publishService()
 	return nil
 }
 
@@ -885,6 +770,11 @@ func (t *MDNSTransport) GetMode() TransportMode {
 	return TransportModeMDNS
 }
 
+// PeerMetrics 按对端统计
+func (t *MDNSTransport) PeerMetrics() *PeerMetrics {
+	return t.metrics
+}
+
 // GetStats 获取传输统计
 func (t *MDNSTransport) GetStats() *TransportStats {
 	t.statsMu.RLock()
@@ -918,10 +808,10 @@ func (t *MDNSTransport) SetChannelKey(key []byte) {
 func (t *MDNSTransport) SetChannelInfo(id, name string) {
 	t.channelID = id
 	t.channelName = name
+	t.publishService()
 }
 
 // TODO: 实现以下功能
 // - 与crypto.Manager集成（Ed25519签名验证）
-// - 文件传输
-// - 更高效的消息编码
+// - 单播旁路的丢段重传
 // - 流量控制
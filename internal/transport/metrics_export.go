@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Prometheus 文本格式导出
+// 参考: docs/PROTOCOL.md - 5.4 传输层指标
+// 只导出计数与速率，不含消息内容；对端标签为 PeerKey 的地址
+
+// promMetric 一个指标族
+type promMetric struct {
+	name string
+	help string
+	typ  string // counter | gauge
+}
+
+// WritePrometheus 以 Prometheus 文本格式（0.0.4）写出传输层与对端统计
+func WritePrometheus(w io.Writer, mode TransportMode, stats *TransportStats, peers []PeerStats) error {
+	bw := bufio.NewWriter(w)
+	modeLabel := fmt.Sprintf(`mode="%s"`, escapeLabelValue(string(mode)))
+
+	family := func(m promMetric) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+	}
+
+	if stats != nil {
+		global := []struct {
+			promMetric
+			value float64
+		}{
+			{promMetric{"crosswire_transport_bytes_sent_total", "Bytes sent by the transport.", "counter"}, float64(stats.BytesSent)},
+			{promMetric{"crosswire_transport_bytes_received_total", "Bytes received by the transport.", "counter"}, float64(stats.BytesReceived)},
+			{promMetric{"crosswire_transport_messages_sent_total", "Messages sent by the transport.", "counter"}, float64(stats.MessagesSent)},
+			{promMetric{"crosswire_transport_messages_received_total", "Messages received by the transport.", "counter"}, float64(stats.MessagesRecv)},
+			{promMetric{"crosswire_transport_errors_total", "Transport errors.", "counter"}, float64(stats.Errors)},
+			{promMetric{"crosswire_transport_retries_total", "Retransmitted frames.", "counter"}, float64(stats.Retries)},
+			{promMetric{"crosswire_transport_loss_ratio", "Estimated frame loss rate.", "gauge"}, stats.LossRate},
+		}
+		for _, g := range global {
+			family(g.promMetric)
+			fmt.Fprintf(bw, "%s{%s} %g\n", g.name, modeLabel, g.value)
+		}
+		if !stats.StartTime.IsZero() {
+			family(promMetric{"crosswire_transport_uptime_seconds", "Seconds since the transport started.", "gauge"})
+			fmt.Fprintf(bw, "crosswire_transport_uptime_seconds{%s} %g\n", modeLabel, time.Since(stats.StartTime).Seconds())
+		}
+	}
+
+	perPeer := []struct {
+		promMetric
+		value func(p *PeerStats) float64
+	}{
+		{promMetric{"crosswire_peer_bytes_sent_total", "Bytes sent to the peer.", "counter"}, func(p *PeerStats) float64 { return float64(p.BytesSent) }},
+		{promMetric{"crosswire_peer_bytes_received_total", "Bytes received from the peer.", "counter"}, func(p *PeerStats) float64 { return float64(p.BytesReceived) }},
+		{promMetric{"crosswire_peer_frames_lost_total", "Frames lost to or from the peer.", "counter"}, func(p *PeerStats) float64 { return float64(p.FramesLostOut + p.FramesLostIn) }},
+		{promMetric{"crosswire_peer_retries_total", "Frames retransmitted to the peer.", "counter"}, func(p *PeerStats) float64 { return float64(p.Retries) }},
+		{promMetric{"crosswire_peer_reassembly_timeouts_total", "Messages from the peer that timed out during reassembly.", "counter"}, func(p *PeerStats) float64 { return float64(p.ReassemblyTimeouts) }},
+		{promMetric{"crosswire_peer_signature_failures_total", "Messages from the peer that failed signature verification.", "counter"}, func(p *PeerStats) float64 { return float64(p.SignatureFailures) }},
+		{promMetric{"crosswire_peer_loss_ratio", "Frame loss rate to and from the peer.", "gauge"}, func(p *PeerStats) float64 { return p.LossRate }},
+		{promMetric{"crosswire_peer_rtt_seconds", "Smoothed round-trip time to the peer.", "gauge"}, func(p *PeerStats) float64 { return p.RTT / 1000 }},
+		{promMetric{"crosswire_peer_send_rate_bytes", "Send throughput to the peer over the last sample interval (bytes/s).", "gauge"}, func(p *PeerStats) float64 { return p.SendRate }},
+		{promMetric{"crosswire_peer_receive_rate_bytes", "Receive throughput from the peer over the last sample interval (bytes/s).", "gauge"}, func(p *PeerStats) float64 { return p.ReceiveRate }},
+	}
+	if len(peers) > 0 {
+		for _, m := range perPeer {
+			family(m.promMetric)
+			for i := range peers {
+				fmt.Fprintf(bw, "%s{%s,peer=\"%s\"} %g\n", m.name, modeLabel, escapeLabelValue(peers[i].Peer), m.value(&peers[i]))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// escapeLabelValue 转义标签值中的反斜杠、双引号与换行
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// MetricsHandler Prometheus 抓取端点（token 非空时要求 Authorization: Bearer <token>）
+func MetricsHandler(t Transport, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var peers []PeerStats
+		if p, ok := t.(PeerStatsProvider); ok {
+			peers = p.PeerMetrics().Snapshot()
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WritePrometheus(w, t.GetMode(), t.GetStats(), peers)
+	}
+}
@@ -0,0 +1,357 @@
+package transport
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 按对端统计
+// 参考: docs/PROTOCOL.md - 5.4 传输层指标
+//
+// 各传输层以对端地址为键记录收发字节、帧数、丢包、重传、重组超时、签名校验失败与 RTT
+// （键与 PeerKey(msg) 一致：ARP 为 MAC，其余为 IP:Port）。
+// 按 PeerMetricsInterval 切分为时间片，每个对端保留最近 PeerMetricsRetention 个采样点，
+// 不启动后台协程：记录或读取时补齐已结束的时间片。
+// 服务端广播（ARP/mDNS 组播）记在 BroadcastPeer 下，对端报告的丢失帧以其登记后的广播帧数为分母。
+
+const (
+	PeerMetricsInterval  = 10 * time.Second // 采样间隔
+	PeerMetricsRetention = 360              // 每个对端保留的采样点数（1 小时）
+	PeerMetricsIdle      = time.Hour        // 超过该时长无活动的对端被移除
+	BroadcastPeer        = "broadcast"      // 广播/组播发送的统计键
+	rttSmoothing         = 8                // 平滑 RTT 的 EWMA 系数（1/8）
+)
+
+// PeerStats 对端统计快照
+type PeerStats struct {
+	Peer               string    `json:"peer"`
+	BytesSent          uint64    `json:"bytes_sent"`
+	BytesReceived      uint64    `json:"bytes_received"`
+	FramesSent         uint64    `json:"frames_sent"`
+	FramesReceived     uint64    `json:"frames_received"`
+	FramesLostOut      uint64    `json:"frames_lost_out"` // 对端报告未收到的帧
+	FramesLostIn       uint64    `json:"frames_lost_in"`  // 本端检测到缺失的帧
+	Retries            uint64    `json:"retries"`
+	ReassemblyTimeouts uint64    `json:"reassembly_timeouts"`
+	SignatureFailures  uint64    `json:"signature_failures"`
+	LossRate           float64   `json:"loss_rate"`        // 双向丢包率（0-1）
+	RTT                float64   `json:"rtt_ms"`           // 平滑 RTT（毫秒，0 表示无采样）
+	MinRTT             float64   `json:"min_rtt_ms"`       // 最小 RTT（毫秒）
+	SendRate           float64   `json:"send_rate_bps"`    // 最近一个采样间隔的发送吞吐（字节/秒）
+	ReceiveRate        float64   `json:"receive_rate_bps"` // 最近一个采样间隔的接收吞吐（字节/秒）
+	FirstSeen          time.Time `json:"first_seen"`
+	LastSeen           time.Time `json:"last_seen"`
+}
+
+// PeerSample 一个采样间隔内的对端统计
+type PeerSample struct {
+	Time               time.Time `json:"time"` // 间隔结束时间
+	BytesSent          uint64    `json:"bytes_sent"`
+	BytesReceived      uint64    `json:"bytes_received"`
+	SendRate           float64   `json:"send_rate_bps"`
+	ReceiveRate        float64   `json:"receive_rate_bps"`
+	LossRate           float64   `json:"loss_rate"`
+	RTT                float64   `json:"rtt_ms"` // 间隔内 RTT 采样的平均值（无采样时为平滑 RTT）
+	Retries            uint64    `json:"retries"`
+	ReassemblyTimeouts uint64    `json:"reassembly_timeouts"`
+	SignatureFailures  uint64    `json:"signature_failures"`
+}
+
+// peerCounters 累计计数
+type peerCounters struct {
+	bytesSent, bytesRecv   uint64
+	framesSent, framesRecv uint64
+	lostOut, lostIn        uint64
+	retries                uint64
+	reassemblyTimeouts     uint64
+	signatureFailures      uint64
+	broadcastFrames        uint64 // 期间发出的广播帧（对端报告丢失的分母）
+}
+
+// peerState 单个对端的统计状态
+type peerState struct {
+	total      peerCounters
+	interval   peerCounters // 当前时间片的增量
+	srtt       time.Duration
+	minRTT     time.Duration
+	rttSum     time.Duration // 当前时间片的 RTT 采样
+	rttCount   int
+	samples    []PeerSample
+	lastSample PeerSample
+	firstSeen  time.Time
+	lastSeen   time.Time
+}
+
+// PeerMetrics 按对端统计
+type PeerMetrics struct {
+	mu          sync.Mutex
+	peers       map[string]*peerState
+	intervalEnd time.Time
+	now         func() time.Time
+}
+
+// NewPeerMetrics 创建对端统计
+func NewPeerMetrics() *PeerMetrics {
+	return &PeerMetrics{peers: make(map[string]*peerState), now: time.Now}
+}
+
+// PeerKey 消息对应的对端统计键
+func PeerKey(msg *Message) string {
+	switch {
+	case msg.SenderMAC != "":
+		return msg.SenderMAC
+	case msg.SenderAddr != "":
+		return msg.SenderAddr
+	}
+	return msg.SenderID
+}
+
+// peer 取得或创建对端状态（调用方持有锁）
+func (m *PeerMetrics) peer(key string, now time.Time) *peerState {
+	p, ok := m.peers[key]
+	if !ok {
+		p = &peerState{firstSeen: now}
+		m.peers[key] = p
+	}
+	p.lastSeen = now
+	return p
+}
+
+// update 推进时间片并修改对端状态
+func (m *PeerMetrics) update(key string, fn func(p *peerState)) {
+	if m == nil || key == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.advance(now)
+	fn(m.peer(key, now))
+}
+
+// RecordSent 记录发往对端的数据（frames 为链路层帧/段数）
+func (m *PeerMetrics) RecordSent(key string, bytes, frames int) {
+	m.update(key, func(p *peerState) {
+		p.total.bytesSent += uint64(bytes)
+		p.interval.bytesSent += uint64(bytes)
+		p.total.framesSent += uint64(frames)
+		p.interval.framesSent += uint64(frames)
+	})
+	if key == BroadcastPeer && m != nil {
+		m.mu.Lock()
+		for _, p := range m.peers {
+			p.total.broadcastFrames += uint64(frames)
+			p.interval.broadcastFrames += uint64(frames)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// RecordReceived 记录来自对端的数据
+func (m *PeerMetrics) RecordReceived(key string, bytes, frames int) {
+	m.update(key, func(p *peerState) {
+		p.total.bytesRecv += uint64(bytes)
+		p.interval.bytesRecv += uint64(bytes)
+		p.total.framesRecv += uint64(frames)
+		p.interval.framesRecv += uint64(frames)
+	})
+}
+
+// RecordLostOut 记录对端报告未收到的帧（NACK）
+func (m *PeerMetrics) RecordLostOut(key string, frames int) {
+	m.update(key, func(p *peerState) {
+		p.total.lostOut += uint64(frames)
+		p.interval.lostOut += uint64(frames)
+	})
+}
+
+// RecordLostIn 记录本端检测到缺失的帧（序列/分块缺口）
+func (m *PeerMetrics) RecordLostIn(key string, frames int) {
+	m.update(key, func(p *peerState) {
+		p.total.lostIn += uint64(frames)
+		p.interval.lostIn += uint64(frames)
+	})
+}
+
+// RecordRetry 记录向对端重传的帧
+func (m *PeerMetrics) RecordRetry(key string, frames int) {
+	m.update(key, func(p *peerState) {
+		p.total.retries += uint64(frames)
+		p.interval.retries += uint64(frames)
+	})
+}
+
+// RecordReassemblyTimeout 记录来自对端的消息重组超时
+func (m *PeerMetrics) RecordReassemblyTimeout(key string) {
+	m.update(key, func(p *peerState) {
+		p.total.reassemblyTimeouts++
+		p.interval.reassemblyTimeouts++
+	})
+}
+
+// RecordSignatureFailure 记录来自对端的签名校验失败
+func (m *PeerMetrics) RecordSignatureFailure(key string) {
+	m.update(key, func(p *peerState) {
+		p.total.signatureFailures++
+		p.interval.signatureFailures++
+	})
+}
+
+// RecordRTT 记录一次 RTT 采样
+func (m *PeerMetrics) RecordRTT(key string, rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+	m.update(key, func(p *peerState) {
+		if p.srtt == 0 {
+			p.srtt = rtt
+		} else {
+			p.srtt += (rtt - p.srtt) / rttSmoothing
+		}
+		if p.minRTT == 0 || rtt < p.minRTT {
+			p.minRTT = rtt
+		}
+		p.rttSum += rtt
+		p.rttCount++
+	})
+}
+
+// advance 结束已到期的时间片，为每个对端追加采样点（调用方持有锁）
+func (m *PeerMetrics) advance(now time.Time) {
+	if m.intervalEnd.IsZero() {
+		m.intervalEnd = now.Truncate(PeerMetricsInterval).Add(PeerMetricsInterval)
+		return
+	}
+	for steps := 0; !now.Before(m.intervalEnd); steps++ {
+		if steps >= PeerMetricsRetention {
+			// 长时间无访问：跳过中间的空时间片
+			m.intervalEnd = now.Truncate(PeerMetricsInterval).Add(PeerMetricsInterval)
+			break
+		}
+		for key, p := range m.peers {
+			if m.intervalEnd.Sub(p.lastSeen) > PeerMetricsIdle {
+				delete(m.peers, key)
+				continue
+			}
+			p.closeInterval(m.intervalEnd)
+		}
+		m.intervalEnd = m.intervalEnd.Add(PeerMetricsInterval)
+	}
+}
+
+// closeInterval 生成当前时间片的采样点并清零增量
+func (p *peerState) closeInterval(end time.Time) {
+	secs := PeerMetricsInterval.Seconds()
+	s := PeerSample{
+		Time:               end,
+		BytesSent:          p.interval.bytesSent,
+		BytesReceived:      p.interval.bytesRecv,
+		SendRate:           float64(p.interval.bytesSent) / secs,
+		ReceiveRate:        float64(p.interval.bytesRecv) / secs,
+		LossRate:           lossRate(p.interval),
+		RTT:                millis(p.srtt),
+		Retries:            p.interval.retries,
+		ReassemblyTimeouts: p.interval.reassemblyTimeouts,
+		SignatureFailures:  p.interval.signatureFailures,
+	}
+	if p.rttCount > 0 {
+		s.RTT = millis(p.rttSum / time.Duration(p.rttCount))
+	}
+	p.samples = append(p.samples, s)
+	if len(p.samples) > PeerMetricsRetention {
+		p.samples = p.samples[len(p.samples)-PeerMetricsRetention:]
+	}
+	p.lastSample = s
+	p.interval = peerCounters{}
+	p.rttSum, p.rttCount = 0, 0
+}
+
+// lossRate 双向丢包率：(对端报告丢失 + 本端检测缺失) / (发出 + 应收)
+func lossRate(c peerCounters) float64 {
+	sent := c.framesSent
+	if c.broadcastFrames > sent {
+		sent = c.broadcastFrames
+	}
+	total := sent + c.framesRecv + c.lostIn
+	if total == 0 {
+		return 0
+	}
+	rate := float64(c.lostOut+c.lostIn) / float64(total)
+	if rate > 1 {
+		rate = 1
+	}
+	return rate
+}
+
+// millis 转换为毫秒
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Snapshot 所有对端的当前统计（按最后活动时间倒序）
+func (m *PeerMetrics) Snapshot() []PeerStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(m.now())
+
+	out := make([]PeerStats, 0, len(m.peers))
+	for key, p := range m.peers {
+		out = append(out, PeerStats{
+			Peer:               key,
+			BytesSent:          p.total.bytesSent,
+			BytesReceived:      p.total.bytesRecv,
+			FramesSent:         p.total.framesSent,
+			FramesReceived:     p.total.framesRecv,
+			FramesLostOut:      p.total.lostOut,
+			FramesLostIn:       p.total.lostIn,
+			Retries:            p.total.retries,
+			ReassemblyTimeouts: p.total.reassemblyTimeouts,
+			SignatureFailures:  p.total.signatureFailures,
+			LossRate:           lossRate(p.total),
+			RTT:                millis(p.srtt),
+			MinRTT:             millis(p.minRTT),
+			SendRate:           p.lastSample.SendRate,
+			ReceiveRate:        p.lastSample.ReceiveRate,
+			FirstSeen:          p.firstSeen,
+			LastSeen:           p.lastSeen,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastSeen.Equal(out[j].LastSeen) {
+			return out[i].LastSeen.After(out[j].LastSeen)
+		}
+		return out[i].Peer < out[j].Peer
+	})
+	return out
+}
+
+// History 对端的采样序列（since 为零值时返回全部保留的采样点）
+func (m *PeerMetrics) History(key string, since time.Time) []PeerSample {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(m.now())
+
+	p, ok := m.peers[key]
+	if !ok {
+		return nil
+	}
+	out := make([]PeerSample, 0, len(p.samples))
+	for _, s := range p.samples {
+		if s.Time.After(since) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// PeerStatsProvider 支持按对端统计的传输层实现
+type PeerStatsProvider interface {
+	PeerMetrics() *PeerMetrics
+}
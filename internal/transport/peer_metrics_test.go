@@ -0,0 +1,158 @@
+package transport
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPeerMetrics(start time.Time) (*PeerMetrics, *time.Time) {
+	m := NewPeerMetrics()
+	now := start
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestPeerMetricsSampling(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, now := testPeerMetrics(start)
+	const peer = "aa:bb:cc:dd:ee:01"
+
+	m.RecordSent(peer, 1000, 1)
+	m.RecordReceived(peer, 500, 1)
+	*now = start.Add(PeerMetricsInterval + time.Second)
+	m.RecordSent(peer, 2000, 2)
+
+	history := m.History(peer, time.Time{})
+	if len(history) != 1 {
+		t.Fatalf("got %d samples, want 1", len(history))
+	}
+	s := history[0]
+	if s.BytesSent != 1000 || s.BytesReceived != 500 {
+		t.Fatalf("first sample = %+v", s)
+	}
+	if want := 1000 / PeerMetricsInterval.Seconds(); s.SendRate != want {
+		t.Fatalf("send rate = %v, want %v", s.SendRate, want)
+	}
+
+	// 空闲的时间片也产生采样点
+	*now = start.Add(4 * PeerMetricsInterval)
+	history = m.History(peer, time.Time{})
+	if len(history) != 4 || history[1].BytesSent != 2000 || history[2].BytesSent != 0 {
+		t.Fatalf("history = %+v", history)
+	}
+	if got := m.History(peer, history[1].Time); len(got) != 2 { // 不含 since 本身
+		t.Fatalf("history since = %d samples", len(got))
+	}
+
+	stats := m.Snapshot()
+	if len(stats) != 1 || stats[0].BytesSent != 3000 || stats[0].FramesSent != 3 {
+		t.Fatalf("snapshot = %+v", stats)
+	}
+
+	// 长时间无活动的对端被移除
+	*now = start.Add(PeerMetricsIdle + 2*PeerMetricsInterval)
+	if got := m.Snapshot(); len(got) != 0 {
+		t.Fatalf("idle peer kept: %+v", got)
+	}
+}
+
+func TestPeerMetricsLossAndRTT(t *testing.T) {
+	m, _ := testPeerMetrics(time.Now())
+	const peer = "10.0.0.2:7000"
+
+	m.RecordSent(peer, 100, 8)
+	m.RecordReceived(peer, 100, 2)
+	m.RecordLostOut(peer, 1)
+	m.RecordLostIn(peer, 1)
+	m.RecordRetry(peer, 1)
+
+	m.RecordRTT(peer, 10*time.Millisecond)
+	m.RecordRTT(peer, 18*time.Millisecond)
+	m.RecordRTT(peer, 0) // 忽略无效采样
+
+	ps := m.Snapshot()[0]
+	if want := 2.0 / 11.0; math.Abs(ps.LossRate-want) > 1e-9 {
+		t.Fatalf("loss rate = %v, want %v", ps.LossRate, want)
+	}
+	if ps.RTT != 11 || ps.MinRTT != 10 {
+		t.Fatalf("rtt = %v min = %v", ps.RTT, ps.MinRTT)
+	}
+	if ps.Retries != 1 {
+		t.Fatalf("retries = %d", ps.Retries)
+	}
+
+	// 广播帧作为对端报告丢失的分母
+	m.RecordSent(BroadcastPeer, 1000, 40)
+	for _, ps := range m.Snapshot() {
+		if ps.Peer == peer && math.Abs(ps.LossRate-2.0/43.0) > 1e-9 {
+			t.Fatalf("loss rate with broadcast = %v", ps.LossRate)
+		}
+	}
+
+	// nil 与空键安全
+	var nilMetrics *PeerMetrics
+	nilMetrics.RecordSent(peer, 1, 1)
+	if nilMetrics.Snapshot() != nil || nilMetrics.History(peer, time.Time{}) != nil {
+		t.Fatal("nil metrics returned data")
+	}
+	m.RecordSent("", 1, 1)
+	if len(m.Snapshot()) != 2 {
+		t.Fatal("empty key recorded")
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	stats := &TransportStats{BytesSent: 1024, MessagesSent: 3, StartTime: time.Now()}
+	peers := []PeerStats{{Peer: `we"ird\peer`, BytesSent: 7, RTT: 2.5}}
+	if err := WritePrometheus(&buf, TransportModeHTTPS, stats, peers); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE crosswire_transport_bytes_sent_total counter\n",
+		`crosswire_transport_bytes_sent_total{mode="https"} 1024` + "\n",
+		`crosswire_peer_bytes_sent_total{mode="https",peer="we\"ird\\peer"} 7` + "\n",
+		`crosswire_peer_rtt_seconds{mode="https",peer="we\"ird\\peer"} 0.0025` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "# TYPE crosswire_peer_rtt_seconds ") != 1 {
+		t.Fatal("metric family declared more than once")
+	}
+}
+
+func TestMetricsHandlerToken(t *testing.T) {
+	tr := NewLoopbackTransport()
+	h := MetricsHandler(tr, "s3cret")
+
+	for _, tc := range []struct {
+		auth string
+		code int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != tc.code {
+			t.Fatalf("auth %q: status %d, want %d", tc.auth, rec.Code, tc.code)
+		}
+		if tc.code == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+			t.Fatalf("content type = %q", rec.Header().Get("Content-Type"))
+		}
+	}
+}
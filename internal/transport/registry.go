@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 传输层注册表
+// 参考: docs/ARCHITECTURE.md - 3.1.4 传输模块
+//
+// 每种传输实现在自身文件的 init() 中调用 Register 完成注册，
+// Factory 与 APP 层的模式校验均以注册表为准，新增传输无需修改工厂代码。
+
+// Constructor 传输层构造函数
+type Constructor func() Transport
+
+// Capabilities 传输层能力描述
+type Capabilities struct {
+	Unicast       bool // 支持点对点发送
+	Broadcast     bool // 支持广播（服务端一次发送到达所有客户端）
+	FileTransfer  bool // 支持传输层文件发送（SendFile）
+	Discovery     bool // 支持局域网服务发现（Discover/Announce）
+	MTU           int  // 单帧/单报文最大负载（字节），0 表示无限制
+	NeedInterface bool // 需要指定网络接口
+	NeedAddress   bool // 客户端需要指定服务器地址
+	NeedConnect   bool // 客户端加入频道前需调用 Connect(addr:port)
+	DefaultPort   int  // 默认端口，0 表示不使用端口
+}
+
+// Registration 传输层注册信息
+type Registration struct {
+	Mode         TransportMode // 模式名称（唯一）
+	DisplayName  string        // 显示名称（用于提示信息）
+	Description  string        // 描述
+	New          Constructor   // 构造函数
+	Capabilities Capabilities  // 能力描述
+	Hidden       bool          // 不在支持列表中展示（如仅用于测试的传输）
+}
+
+var (
+	registryMu    sync.RWMutex
+	registry      = make(map[TransportMode]*Registration)
+	registryOrder []TransportMode
+)
+
+// Register 注册传输层实现
+func Register(reg Registration) error {
+	if reg.Mode == "" {
+		return fmt.Errorf("transport mode cannot be empty")
+	}
+	if reg.New == nil {
+		return fmt.Errorf("transport %s: constructor cannot be nil", reg.Mode)
+	}
+	if reg.DisplayName == "" {
+		reg.DisplayName = string(reg.Mode)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[reg.Mode]; exists {
+		return fmt.Errorf("transport %s already registered", reg.Mode)
+	}
+	registry[reg.Mode] = &reg
+	registryOrder = append(registryOrder, reg.Mode)
+	return nil
+}
+
+// MustRegister 注册传输层实现，失败时panic（用于 init）
+func MustRegister(reg Registration) {
+	if err := Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup 查询传输层注册信息
+func Lookup(mode TransportMode) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[mode]
+	if !ok {
+		return Registration{}, false
+	}
+	return *reg, true
+}
+
+// Registrations 按注册顺序返回所有注册信息（包含隐藏项）
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(registryOrder))
+	for _, mode := range registryOrder {
+		regs = append(regs, *registry[mode])
+	}
+	return regs
+}
+
+// GetCapabilities 获取传输模式的能力描述
+func GetCapabilities(mode TransportMode) (Capabilities, bool) {
+	reg, ok := Lookup(mode)
+	if !ok {
+		return Capabilities{}, false
+	}
+	return reg.Capabilities, true
+}
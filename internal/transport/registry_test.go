@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"testing"
+
+	"crosswire/internal/models"
+)
+
+// registerForTest 注册测试用传输，测试结束后从注册表移除
+func registerForTest(t *testing.T, reg Registration) {
+	t.Helper()
+	if err := Register(reg); err != nil {
+		t.Fatalf("register %s: %v", reg.Mode, err)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(registry, reg.Mode)
+		for i, m := range registryOrder {
+			if m == reg.Mode {
+				registryOrder = append(registryOrder[:i], registryOrder[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func TestRegisterValidation(t *testing.T) {
+	newLoopback := func() Transport { return NewLoopbackTransport() }
+
+	tests := []struct {
+		name string
+		reg  Registration
+	}{
+		{"empty mode", Registration{New: newLoopback}},
+		{"nil constructor", Registration{Mode: "test-nil"}},
+		{"duplicate", Registration{Mode: TransportModeUDP, New: newLoopback}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Register(tc.reg); err == nil {
+				t.Fatalf("Register(%+v) succeeded, want error", tc.reg)
+			}
+		})
+	}
+}
+
+func TestRegisterAndLookup(t *testing.T) {
+	mode := models.TransportMode("test-registry")
+	registerForTest(t, Registration{
+		Mode:         mode,
+		New:          func() Transport { return NewLoopbackTransport() },
+		Capabilities: Capabilities{Unicast: true, MTU: 1200},
+	})
+
+	reg, ok := Lookup(mode)
+	if !ok {
+		t.Fatalf("Lookup(%s) not found", mode)
+	}
+	if reg.DisplayName != string(mode) {
+		t.Fatalf("DisplayName = %q, want mode name as default", reg.DisplayName)
+	}
+	caps, ok := GetCapabilities(mode)
+	if !ok || !caps.Unicast || caps.MTU != 1200 {
+		t.Fatalf("GetCapabilities = %+v, %v", caps, ok)
+	}
+
+	regs := Registrations()
+	if last := regs[len(regs)-1]; last.Mode != mode {
+		t.Fatalf("Registrations not in registration order: last = %s", last.Mode)
+	}
+
+	if _, ok := Lookup("no-such-mode"); ok {
+		t.Fatal("Lookup of unknown mode succeeded")
+	}
+}
+
+func TestFactoryHidesHiddenModes(t *testing.T) {
+	f := NewFactory()
+
+	for _, mode := range f.GetSupportedModes() {
+		if mode == TransportModeLoopback {
+			t.Fatal("GetSupportedModes lists hidden loopback transport")
+		}
+	}
+	if f.IsModeSupported(TransportModeLoopback) {
+		t.Fatal("IsModeSupported accepts hidden loopback transport")
+	}
+	if f.IsModeSupported("no-such-mode") {
+		t.Fatal("IsModeSupported accepts unknown mode")
+	}
+	for _, mode := range []TransportMode{TransportModeARP, TransportModeHTTPS, TransportModeMDNS, TransportModeUDP} {
+		if !f.IsModeSupported(mode) {
+			t.Fatalf("IsModeSupported(%s) = false", mode)
+		}
+	}
+
+	// 隐藏传输仍可通过工厂显式创建（测试使用）
+	tr, err := f.Create(TransportModeLoopback)
+	if err != nil {
+		t.Fatalf("Create(loopback): %v", err)
+	}
+	if tr.GetMode() != TransportModeLoopback {
+		t.Fatalf("GetMode = %s", tr.GetMode())
+	}
+}
+
+func TestFactoryCreatesRegisteredModes(t *testing.T) {
+	f := NewFactory()
+	for _, reg := range Registrations() {
+		tr, err := f.Create(reg.Mode)
+		if err != nil {
+			t.Fatalf("Create(%s): %v", reg.Mode, err)
+		}
+		if tr.GetMode() != reg.Mode {
+			t.Fatalf("Create(%s) returned transport with mode %s", reg.Mode, tr.GetMode())
+		}
+	}
+	if _, err := f.Create("no-such-mode"); err == nil {
+		t.Fatal("Create of unknown mode succeeded")
+	}
+}
@@ -15,6 +15,10 @@ const (
 	TransportModeARP   = models.TransportARP
 	TransportModeHTTPS = models.TransportHTTPS
 	TransportModeMDNS  = models.TransportMDNS
+	TransportModeAuto  = models.TransportAuto
+
+	TransportModeUDP      = models.TransportUDP
+	TransportModeLoopback = models.TransportLoopback
 )
 
 // Transport 传输层统一接口
@@ -89,6 +93,15 @@ type Config struct {
 	TLSCert   string        // TLS证书路径（HTTPS模式）
 	TLSKey    string        // TLS私钥路径（HTTPS模式）
 
+	// 原始帧后端（ARP模式）："pcap" | "afpacket"，为空时自动选择（优先pcap，不可用时用AF_PACKET）
+	ARPBackend string
+
+	// 以太网封装（ARP模式），服务端在发现应答中宣告，客户端须使用相同配置
+	EtherType     uint16 // 自定义 EtherType（0 使用 EtherTypeCustom）
+	VLANID        uint16 // 802.1Q VLAN ID（0 不打标签）
+	VLANPriority  uint8  // 802.1Q 优先级（PCP，0-7）
+	Encapsulation string // "ethertype"（默认）| "snap" | "arp"
+
 	// 超时配置
 	ConnectTimeout time.Duration
 	ReadTimeout    time.Duration
@@ -112,6 +125,28 @@ type Config struct {
 	// TLS 相关（HTTPS客户端模式）
 	// 是否跳过证书校验（自签名场景用于开发/内网）
 	SkipTLSVerify bool
+
+	// 自动模式候选顺序（为空时使用默认顺序 HTTPS → mDNS → ARP）
+	AutoCandidates []TransportMode
+
+	// 前向纠错（ARP广播）：为分块较多的广播追加校验帧，冗余比例随丢包率自适应
+	FECEnabled bool
+	FECRatio   float64 // 基础冗余比例（0 使用 FECDefaultRatio）
+
+	// 发送节流（ARP模式）：令牌桶限速 + AIMD 拥塞窗口，0 使用默认值
+	PacingRate    int // 发送速率（字节/秒，<0 不限速）
+	PacingBurst   int // 令牌桶容量（字节）
+	InitialWindow int // 初始拥塞窗口（帧）
+	MaxWindow     int // 最大拥塞窗口（帧）
+
+	// Prometheus 指标端点（HTTPS服务端），为空时不启用；设置 MetricsToken 时要求 Bearer 认证
+	MetricsPath  string
+	MetricsToken string
+
+	// 抓包与回放（ARP模式调试）
+	CaptureFile string // 记录收发的全部帧（pcapng）
+	ReplayFile  string // 回放抓包文件代替网卡（pcap/pcapng）
+	LocalMAC    string // 覆盖本机MAC（回放时为抓包时的本机地址，可不指定网卡）
 }
 
 // Message 传输层消息
@@ -158,6 +193,29 @@ const (
 	MessageTypeAuth     MessageType = 0x06 // 认证握手
 )
 
+// ===== 可选接口 =====
+// 上层通过类型断言调用，传输实现按需提供
+
+// RoleSetter 区分服务端/客户端角色（"server" or "client"）
+type RoleSetter interface {
+	SetMode(mode string)
+}
+
+// ServerKeySetter 设置服务端签名密钥
+type ServerKeySetter interface {
+	SetServerKeys(privKey, pubKey []byte)
+}
+
+// ChannelInfoSetter 设置频道信息（用于宣告/发现）
+type ChannelInfoSetter interface {
+	SetChannelInfo(channelID, channelName string)
+}
+
+// ReconnectSetter 启用/禁用传输层自身的断线重连（上层有统一的重连状态机时禁用，避免重复拨号）
+type ReconnectSetter interface {
+	SetAutoReconnect(enabled bool)
+}
+
 // MessageHandler 消息处理回调函数
 type MessageHandler func(msg *Message)
 
@@ -185,6 +243,13 @@ type PeerInfo struct {
 	LastSeen      time.Time     // 最后发现时间
 	ChannelIDHash string        // 频道ID哈希（前8字符）
 	Version       int           // 协议版本
+
+	// 以下字段由支持富信息宣告的传输层填充（如HTTPS信标）
+	Name            string            // 频道名称
+	Port            int               // 服务端口
+	CertFingerprint string            // TLS证书SHA-256指纹
+	MemberCount     int               // 当前成员数
+	Metadata        map[string]string // 其他附加信息
 }
 
 // ServiceInfo 服务信息（用于宣告）
@@ -207,6 +272,10 @@ type TransportStats struct {
 	MessagesRecv  uint64    // 接收消息数
 	Errors        uint64    // 错误计数
 	Retries       uint64    // 重传次数
+	FramesLost    uint64    // 对端报告丢失的帧数（NACK）
+	FECRecovered  uint64    // 通过前向纠错恢复的帧数
+	LossRate      float64   // 估计丢包率（0-1）
+	SendWindow    int       // 当前拥塞窗口（帧，ARP模式）
 	StartTime     time.Time // 启动时间
 	LastActivity  time.Time // 最后活动时间
 }
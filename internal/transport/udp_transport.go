@@ -0,0 +1,527 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"crosswire/internal/utils"
+)
+
+// UDPTransport 简单局域网UDP传输实现
+// 每条消息序列化为一个JSON数据报：客户端单播到服务端，服务端向所有已知客户端逐个发送。
+// 服务端口同时应答 CWPROBE 探测（格式与 HTTPS 信标一致，见 https_discovery.go）。
+type UDPTransport struct {
+	config *Config
+	mode   string // "server" or "client"
+
+	// 套接字与连接状态，由 mu 保护（读循环、发送与 Stop/Disconnect 可能并发）
+	mu         sync.Mutex
+	conn       *net.UDPConn
+	serverAddr *net.UDPAddr // 客户端：服务端地址
+
+	// 服务端：已知客户端（以最近一次收到数据报的时间判断存活）
+	peers   map[string]*udpPeer
+	peersMu sync.RWMutex
+
+	// 消息处理
+	handler     MessageHandler
+	fileHandler FileHandler
+
+	// 统计
+	stats   TransportStats
+	statsMu sync.RWMutex
+	metrics *PeerMetrics // 按对端统计（键为 IP:Port）
+
+	// 服务信息
+	serviceInfo *ServiceInfo
+	serviceMu   sync.RWMutex
+
+	// 控制
+	ctx       context.Context
+	cancel    context.CancelFunc
+	started   bool
+	connected bool
+
+	logger *utils.Logger
+}
+
+// udpPeer 服务端记录的客户端
+type udpPeer struct {
+	addr     *net.UDPAddr
+	lastSeen time.Time
+}
+
+const (
+	UDPDefaultPort = 8444
+	UDPMaxPayload  = 60 * 1024 // 单个数据报最大长度（留出IP/UDP头余量）
+
+	udpPeerTimeout = 2 * time.Minute // 客户端心跳间隔为30秒
+)
+
+func init() {
+	MustRegister(Registration{
+		Mode:        TransportModeUDP,
+		DisplayName: "UDP",
+		Description: "UDP 数据报，适用于简单局域网",
+		New:         func() Transport { return NewUDPTransport() },
+		Capabilities: Capabilities{
+			Unicast:      true,
+			Broadcast:    true,
+			FileTransfer: true,
+			Discovery:    true,
+			MTU:          UDPMaxPayload,
+			NeedAddress:  true,
+			NeedConnect:  true,
+			DefaultPort:  UDPDefaultPort,
+		},
+	})
+}
+
+// NewUDPTransport 创建UDP传输层
+func NewUDPTransport() *UDPTransport {
+	return &UDPTransport{
+		peers:   make(map[string]*udpPeer),
+		metrics: NewPeerMetrics(),
+	}
+}
+
+// ===== 生命周期管理 =====
+
+// Init 初始化
+func (t *UDPTransport) Init(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	t.config = config
+	t.logger = config.Logger
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.stats.StartTime = time.Now()
+	return nil
+}
+
+// Start 启动传输层
+func (t *UDPTransport) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.started {
+		return fmt.Errorf("transport already started")
+	}
+	if t.config == nil {
+		return fmt.Errorf("transport not initialized")
+	}
+
+	if t.mode == "server" {
+		port := t.config.Port
+		if port == 0 {
+			port = UDPDefaultPort
+		}
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+		if err != nil {
+			return fmt.Errorf("failed to listen on udp/%d: %w", port, err)
+		}
+		t.conn = conn
+		go t.readLoop(conn)
+		go t.expireLoop()
+		t.logInfo("UDP transport server started on udp/%d", port)
+	}
+
+	t.started = true
+	return nil
+}
+
+// Stop 停止传输层
+func (t *UDPTransport) Stop() error {
+	t.mu.Lock()
+	if !t.started {
+		t.mu.Unlock()
+		return nil
+	}
+	t.started = false
+	t.connected = false
+	t.cancel()
+
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+// ===== 连接管理 =====
+
+// Connect 连接到服务端（UDP无连接，仅记录服务端地址并打开本地套接字）
+func (t *UDPTransport) Connect(target string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.connected {
+		return fmt.Errorf("already connected")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", target)
+	if err != nil {
+		return fmt.Errorf("invalid server address %q: %w", target, err)
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return fmt.Errorf("failed to open udp socket: %w", err)
+	}
+
+	t.conn = conn
+	t.serverAddr = addr
+	t.connected = true
+	go t.readLoop(conn)
+
+	t.logInfo("Connected to %s", addr)
+	return nil
+}
+
+// Disconnect 断开连接
+func (t *UDPTransport) Disconnect() error {
+	if t.mode == "server" {
+		return nil
+	}
+	t.mu.Lock()
+	t.connected = false
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+// IsConnected 是否已连接
+func (t *UDPTransport) IsConnected() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.mode == "server" {
+		return t.started
+	}
+	return t.connected
+}
+
+// ===== 消息收发 =====
+
+// SendMessage 发送消息
+func (t *UDPTransport) SendMessage(msg *Message) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if len(data) > UDPMaxPayload {
+		return fmt.Errorf("message too large for udp transport: %d > %d bytes", len(data), UDPMaxPayload)
+	}
+
+	t.mu.Lock()
+	conn, serverAddr := t.conn, t.serverAddr
+	t.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	if t.mode == "server" {
+		return t.broadcast(conn, data)
+	}
+
+	if _, err := conn.WriteToUDP(data, serverAddr); err != nil {
+		t.recordError()
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	t.recordSent(len(data), 1)
+	t.metrics.RecordSent(serverAddr.String(), len(data), 1)
+	return nil
+}
+
+// broadcast 发送到所有已知客户端（服务端模式）
+func (t *UDPTransport) broadcast(conn *net.UDPConn, data []byte) error {
+	t.peersMu.RLock()
+	addrs := make([]*net.UDPAddr, 0, len(t.peers))
+	for _, p := range t.peers {
+		addrs = append(addrs, p.addr)
+	}
+	t.peersMu.RUnlock()
+
+	var errs []error
+	for _, addr := range addrs {
+		if _, err := conn.WriteToUDP(data, addr); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send to %s: %w", addr, err))
+			continue
+		}
+		t.metrics.RecordSent(addr.String(), len(data), 1)
+	}
+	t.recordSent(len(data), len(addrs)-len(errs))
+
+	if len(errs) > 0 {
+		t.recordError()
+		return fmt.Errorf("broadcast errors: %v", errs)
+	}
+	return nil
+}
+
+// readLoop 接收数据报
+func (t *UDPTransport) readLoop(conn *net.UDPConn) {
+	// 服务端地址在 Connect 后不再变化，读循环启动时取一次即可
+	t.mu.Lock()
+	serverAddr := t.serverAddr
+	t.mu.Unlock()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-t.ctx.Done():
+			default:
+				if t.IsConnected() {
+					t.logDebug("Read loop exited: %v", err)
+				}
+			}
+			return
+		}
+		data := buf[:n]
+
+		// 服务端应答发现探测
+		if t.mode == "server" && bytes.HasPrefix(data, []byte(beaconProbePrefix)) {
+			t.replyProbe(conn, from)
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.logWarn("Invalid datagram from %s: %v", from, err)
+			continue
+		}
+
+		if t.mode == "server" {
+			t.touchPeer(from)
+		} else if serverAddr != nil && (!from.IP.Equal(serverAddr.IP) || from.Port != serverAddr.Port) {
+			// 客户端只接受来自服务端的数据报
+			continue
+		}
+		msg.SenderAddr = from.String()
+		t.metrics.RecordReceived(msg.SenderAddr, n, 1)
+
+		t.statsMu.Lock()
+		t.stats.BytesReceived += uint64(n)
+		t.stats.MessagesRecv++
+		t.stats.LastActivity = time.Now()
+		t.statsMu.Unlock()
+
+		if t.fileHandler != nil && msg.Type == MessageTypeData {
+			if ft := tryParseTransportFilePayload(msg.Payload); ft != nil {
+				go t.fileHandler(ft)
+				continue
+			}
+		}
+		if t.handler != nil {
+			go t.handler(&msg)
+		}
+	}
+}
+
+// touchPeer 记录/刷新客户端地址
+func (t *UDPTransport) touchPeer(addr *net.UDPAddr) {
+	key := addr.String()
+	t.peersMu.Lock()
+	if p, ok := t.peers[key]; ok {
+		p.lastSeen = time.Now()
+	} else {
+		t.peers[key] = &udpPeer{addr: addr, lastSeen: time.Now()}
+		t.logDebug("New peer: %s", key)
+	}
+	t.peersMu.Unlock()
+}
+
+// expireLoop 定期清理长时间无数据的客户端
+func (t *UDPTransport) expireLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-udpPeerTimeout)
+			t.peersMu.Lock()
+			for key, p := range t.peers {
+				if p.lastSeen.Before(cutoff) {
+					delete(t.peers, key)
+					t.logDebug("Peer expired: %s", key)
+				}
+			}
+			t.peersMu.Unlock()
+		}
+	}
+}
+
+// ReceiveMessage 接收消息（UDP传输仅支持订阅模式）
+func (t *UDPTransport) ReceiveMessage() (*Message, error) {
+	return nil, fmt.Errorf("not supported, use Subscribe")
+}
+
+// Subscribe 订阅消息
+func (t *UDPTransport) Subscribe(handler MessageHandler) error {
+	t.handler = handler
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func (t *UDPTransport) Unsubscribe() {
+	t.handler = nil
+}
+
+// ===== 文件传输 =====
+
+// SendFile 发送文件（封装为文件型负载复用消息通道）
+func (t *UDPTransport) SendFile(file *FileTransfer) error {
+	if file == nil {
+		return fmt.Errorf("file is nil")
+	}
+	payload, err := buildTransportFilePayload(file)
+	if err != nil {
+		return err
+	}
+	return t.SendMessage(&Message{Type: MessageTypeData, Payload: payload})
+}
+
+// OnFileReceived 文件接收回调
+func (t *UDPTransport) OnFileReceived(handler FileHandler) error {
+	t.fileHandler = handler
+	return nil
+}
+
+// ===== 服务发现 =====
+
+// Discover 广播探测报文并收集服务端应答
+func (t *UDPTransport) Discover(timeout time.Duration) ([]*PeerInfo, error) {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	port := UDPDefaultPort
+	if t.config != nil && t.config.Port > 0 {
+		port = t.config.Port
+	}
+	return collectBeacons(port, timeout)
+}
+
+// Announce 宣告服务（记录服务信息，由探测应答携带）
+func (t *UDPTransport) Announce(info *ServiceInfo) error {
+	if info == nil {
+		return fmt.Errorf("service info cannot be nil")
+	}
+	copied := *info
+	t.serviceMu.Lock()
+	t.serviceInfo = &copied
+	t.serviceMu.Unlock()
+	return nil
+}
+
+// replyProbe 应答发现探测
+func (t *UDPTransport) replyProbe(conn *net.UDPConn, to *net.UDPAddr) {
+	b := &httpsBeacon{
+		Mode:    string(TransportModeUDP),
+		Version: ProtocolVersion,
+		Port:    conn.LocalAddr().(*net.UDPAddr).Port,
+	}
+	t.peersMu.RLock()
+	b.MemberCount = len(t.peers)
+	t.peersMu.RUnlock()
+
+	t.serviceMu.RLock()
+	if info := t.serviceInfo; info != nil {
+		b.ChannelIDHash = channelIDHash(info.ChannelID)
+		b.ChannelName = info.ChannelName
+		b.MaxMembers = info.MaxMembers
+	}
+	t.serviceMu.RUnlock()
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return
+	}
+	_, _ = conn.WriteToUDP(append([]byte(beaconReplyPrefix), data...), to)
+}
+
+// ===== 元数据 =====
+
+// GetMode 获取传输模式
+func (t *UDPTransport) GetMode() TransportMode {
+	return TransportModeUDP
+}
+
+// GetStats 获取传输统计
+func (t *UDPTransport) GetStats() *TransportStats {
+	t.statsMu.RLock()
+	defer t.statsMu.RUnlock()
+	stats := t.stats
+	return &stats
+}
+
+// SetMode 设置模式（"server" or "client"）
+func (t *UDPTransport) SetMode(mode string) {
+	t.mode = mode
+}
+
+// GetClientCount 获取已知客户端数量（服务端模式）
+func (t *UDPTransport) GetClientCount() int {
+	t.peersMu.RLock()
+	defer t.peersMu.RUnlock()
+	return len(t.peers)
+}
+
+// PeerMetrics 按对端统计
+func (t *UDPTransport) PeerMetrics() *PeerMetrics {
+	return t.metrics
+}
+
+func (t *UDPTransport) recordSent(size, count int) {
+	if count <= 0 {
+		return
+	}
+	t.statsMu.Lock()
+	t.stats.BytesSent += uint64(size) * uint64(count)
+	t.stats.MessagesSent += uint64(count)
+	t.stats.LastActivity = time.Now()
+	t.statsMu.Unlock()
+}
+
+func (t *UDPTransport) recordError() {
+	t.statsMu.Lock()
+	t.stats.Errors++
+	t.statsMu.Unlock()
+}
+
+func (t *UDPTransport) logDebug(format string, args ...interface{}) {
+	if t.logger != nil {
+		t.logger.Debug("[UDP] "+format, args...)
+	}
+}
+
+func (t *UDPTransport) logInfo(format string, args ...interface{}) {
+	if t.logger != nil {
+		t.logger.Info("[UDP] "+format, args...)
+	}
+}
+
+func (t *UDPTransport) logWarn(format string, args ...interface{}) {
+	if t.logger != nil {
+		t.logger.Warn("[UDP] "+format, args...)
+	}
+}
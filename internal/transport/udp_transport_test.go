@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// freeUDPPort 返回一个当前空闲的本地UDP端口
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// newUDPPair 启动本地UDP服务端并连接一个客户端
+func newUDPPair(t *testing.T) (*UDPTransport, *UDPTransport, int) {
+	t.Helper()
+	port := freeUDPPort(t)
+
+	srv := NewUDPTransport()
+	srv.SetMode("server")
+	cli := NewUDPTransport()
+	cli.SetMode("client")
+	for _, tr := range []*UDPTransport{srv, cli} {
+		if err := tr.Init(&Config{Mode: TransportModeUDP, Port: port}); err != nil {
+			t.Fatalf("init: %v", err)
+		}
+		if err := tr.Start(); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+	}
+	if err := cli.Connect(net.JoinHostPort("127.0.0.1", strconv.Itoa(port))); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Stop()
+		_ = srv.Stop()
+	})
+	return srv, cli, port
+}
+
+func waitMessage(t *testing.T, ch <-chan *Message) *Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
+
+func TestUDPRoundTrip(t *testing.T) {
+	srv, cli, _ := newUDPPair(t)
+
+	srvRecv := make(chan *Message, 1)
+	cliRecv := make(chan *Message, 1)
+	_ = srv.Subscribe(func(m *Message) { srvRecv <- m })
+	_ = cli.Subscribe(func(m *Message) { cliRecv <- m })
+
+	if err := cli.SendMessage(&Message{ID: "up", Type: MessageTypeData, Payload: []byte("hello")}); err != nil {
+		t.Fatalf("client send: %v", err)
+	}
+	got := waitMessage(t, srvRecv)
+	if got.ID != "up" || string(got.Payload) != "hello" || got.SenderAddr == "" {
+		t.Fatalf("server received %+v", got)
+	}
+	if srv.GetClientCount() != 1 {
+		t.Fatalf("client count = %d, want 1", srv.GetClientCount())
+	}
+
+	// 服务端向已知客户端广播
+	if err := srv.SendMessage(&Message{ID: "down", Type: MessageTypeControl, Payload: []byte("world")}); err != nil {
+		t.Fatalf("server send: %v", err)
+	}
+	got = waitMessage(t, cliRecv)
+	if got.ID != "down" || string(got.Payload) != "world" {
+		t.Fatalf("client received %+v", got)
+	}
+
+	if s := cli.GetStats(); s.MessagesSent != 1 || s.MessagesRecv != 1 {
+		t.Fatalf("client stats sent=%d recv=%d", s.MessagesSent, s.MessagesRecv)
+	}
+}
+
+func TestUDPDiscover(t *testing.T) {
+	srv, cli, port := newUDPPair(t)
+	_ = srv.Announce(&ServiceInfo{ChannelID: "channel-udp", ChannelName: "UDP频道", MaxMembers: 10})
+
+	peers, err := cli.Discover(300 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	var found *PeerInfo
+	for _, p := range peers {
+		if p.ChannelIDHash == channelIDHash("channel-udp") {
+			found = p
+		}
+	}
+	if found == nil {
+		t.Fatalf("server not discovered: %+v", peers)
+	}
+	if found.Mode != TransportModeUDP || found.Port != port || found.Name != "UDP频道" {
+		t.Fatalf("unexpected peer: %+v", found)
+	}
+}
+
+func TestUDPRejectsOversizedMessage(t *testing.T) {
+	_, cli, _ := newUDPPair(t)
+	err := cli.SendMessage(&Message{Type: MessageTypeData, Payload: make([]byte, UDPMaxPayload)})
+	if err == nil {
+		t.Fatal("oversized message accepted")
+	}
+}
+
+func TestUDPStopDuringSend(t *testing.T) {
+	_, cli, _ := newUDPPair(t)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_ = cli.SendMessage(&Message{Type: MessageTypeData, Payload: []byte("x")})
+		}
+	}()
+	_ = cli.Stop()
+	wg.Wait()
+
+	if cli.IsConnected() {
+		t.Fatal("client still connected after Stop")
+	}
+	if err := cli.SendMessage(&Message{Type: MessageTypeData}); err == nil {
+		t.Fatal("send after Stop succeeded")
+	}
+}
@@ -3,8 +3,10 @@ package utils
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"math/big"
@@ -100,6 +102,20 @@ func EnsureSelfSignedCert(dir string, hosts []string, validDays int) (certPath,
 	return certPath, keyPath, nil
 }
 
+// CertFingerprint 计算PEM证书文件中首个证书的SHA-256指纹（小写十六进制）
+func CertFingerprint(certPath string) (string, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return "", fmt.Errorf("read cert: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("no certificate found in %s", certPath)
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func fileExists(path string) bool {
 	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
 		return true